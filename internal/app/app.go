@@ -2,23 +2,38 @@ package app
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/spf13/afero"
 	"golang.org/x/oauth2"
 
 	"github.com/gphotosuploader/gphotos-uploader-cli/internal/config"
 	"github.com/gphotosuploader/gphotos-uploader-cli/internal/datastore/filetracker"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/datastore/lastrun"
 	"github.com/gphotosuploader/gphotos-uploader-cli/internal/datastore/leveldbstore"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/datastore/quota"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/datastore/retryqueue"
 	"github.com/gphotosuploader/gphotos-uploader-cli/internal/datastore/tokenmanager"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/imagehash"
 	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/pathnorm"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/upload"
 )
 
 const (
 	// DefaultConfigFilename is the default config file name.
 	DefaultConfigFilename = "config.hjson"
+
+	// TokenStoreKeyEnvVar is the environment variable with the passphrase of the file token store.
+	TokenStoreKeyEnvVar = "GPHOTOS_CLI_TOKENSTORE_KEY"
 )
 
 // App represents a running application with all the dependant services.
@@ -29,10 +44,28 @@ type App struct {
 	TokenManager TokenManager
 	// UploadSessionTracker tracks uploads sessions to implement resumable uploads.
 	UploadSessionTracker UploadSessionTracker
+	// RetryQueue keeps the failed uploads to be retried on next runs.
+	RetryQueue RetryQueue
+	// RequestBudget counts the Google Photos API requests made every day.
+	RequestBudget RequestBudget
+	// LastRuns keeps when the last successful run of every folder started.
+	LastRuns LastRunStore
+	// ContentHashes keeps the SHA-256 of the files hashed in the run, so every file is read once to check its
+	// content, whatever checks it.
+	ContentHashes *upload.ContentHashes
 
 	// Client is the HTTP client after authentication.
 	Client *http.Client
 
+	// clients are the HTTP clients, by account, for jobs using other accounts.
+	clients map[string]*http.Client
+
+	// forceAuth ignores the stored tokens, asking for a new authorization.
+	forceAuth bool
+
+	// recoverTracker moves a corrupted tracking store aside, starting with an empty one, instead of failing.
+	recoverTracker bool
+
 	Logger log.Logger
 
 	// fs points to the file system.
@@ -42,25 +75,127 @@ type App struct {
 	// appDir is the directory application directory.
 	appDir string
 
+	// configFiles, if it isn't empty, are the configuration files merged instead of the one in appDir.
+	configFiles []string
+	// configLists is the way lists are merged when there are several configFiles.
+	configLists config.ListMerge
+
+	// stdin is read for the configuration, if configStdin is set, and the token, if tokenStdin is set.
+	stdin       io.Reader
+	configStdin bool
+	tokenStdin  bool
+	// stdinToken is the token read from stdin, used by the token manager instead of the configured TokenStore.
+	stdinToken *oauth2.Token
+
+	// authCodeReader, if it's set, is read for the authorization code of the OAuth consent flow instead of os.Stdin.
+	authCodeReader io.Reader
+
 	// Config keeps the application configuration.
 	Config *config.Config
 }
 
+// Option configures the application when it's started.
+type Option func(app *App)
+
+// WithRecoverTracker moves the tracking store aside if it's corrupted, starting with an empty one, instead of
+// failing. Files tracked as uploaded are uploaded again, unless they are found in the library.
+func WithRecoverTracker() Option {
+	return func(app *App) {
+		app.recoverTracker = true
+	}
+}
+
+// WithConfigFiles reads the configuration merging the files, in order, instead of the configuration file of
+// the application data folder. See config.FromFiles for the precedence rules.
+func WithConfigFiles(filenames []string, lists config.ListMerge) Option {
+	return func(app *App) {
+		app.configFiles = filenames
+		app.configLists = lists
+	}
+}
+
+// WithStdin reads the configuration, if config is set, and the token, if token is set, from r, e.g. the standard
+// input, instead of the configuration file and the configured TokenStore, so they are not kept on disk. The token
+// is only kept in memory, so refreshed tokens are not stored. If both are set, r is the token, encoded as JSON,
+// followed by the configuration.
+func WithStdin(r io.Reader, config bool, token bool) Option {
+	return func(app *App) {
+		app.stdin = r
+		app.configStdin = config
+		app.tokenStdin = token
+	}
+}
+
+// WithAuthCodeReader reads the authorization code of the OAuth consent flow from r, instead of the standard input.
+func WithAuthCodeReader(r io.Reader) Option {
+	return func(app *App) {
+		app.authCodeReader = r
+	}
+}
+
 // Start initializes the application with the services defined by a given configuration.
 // The provided path is the expanded and absolute path to the application data folder.
-func Start(ctx context.Context, path string) (*App, error) {
+func Start(ctx context.Context, path string, opts ...Option) (*App, error) {
+	return start(ctx, path, false, opts)
+}
+
+// StartWithNewAuth initializes the application like Start, but asking for a new authorization
+// instead of using the stored token. The new token replaces the stored one.
+func StartWithNewAuth(ctx context.Context, path string, opts ...Option) (*App, error) {
+	return start(ctx, path, true, opts)
+}
+
+// StartWithoutAuth initializes the application like Start, but without authenticating.
+// It's useful to work with the local data only, like the tracked files. Client is nil.
+func StartWithoutAuth(path string, opts ...Option) (*App, error) {
+	return newApp(path, false, opts)
+}
+
+func start(ctx context.Context, path string, forceAuth bool, opts []Option) (*App, error) {
+	app, err := newApp(path, forceAuth, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	app.Client, err = app.NewOAuth2Client(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return app, nil
+}
+
+// newApp reads the configuration and starts the services, but doesn't authenticate.
+func newApp(path string, forceAuth bool, opts []Option) (*App, error) {
 	var err error
 
 	app := &App{
-		appDir: path,
-		Logger: log.GetInstance(),
-		fs:     afero.NewOsFs(),
+		appDir:    path,
+		Logger:    log.GetInstance(),
+		fs:        afero.NewOsFs(),
+		forceAuth: forceAuth,
+	}
+	for _, opt := range opts {
+		opt(app)
 	}
 
-	app.Logger.Infof("Reading configuration from '%s'", app.configFilename())
-	app.Config, err = config.FromFile(app.fs, app.configFilename())
+	if app.tokenStdin {
+		if err := app.readStdinToken(); err != nil {
+			return nil, err
+		}
+	}
+
+	app.Logger.Infof("Reading configuration from '%s'", app.configSource())
+	app.Config, err = app.readConfig()
 	if err != nil {
-		return nil, fmt.Errorf("invalid configuration at '%s': %s", app.configFilename(), err)
+		return nil, fmt.Errorf("invalid configuration at '%s': %s", app.configSource(), err)
+	}
+
+	if len(app.Config.Migrations) > 0 {
+		app.Logger.Warnf("Configuration has been upgraded to version %d, run `config migrate --write` to update the file:", config.CurrentVersion)
+		for _, change := range app.Config.Migrations {
+			app.Logger.Warnf("  %s", change)
+		}
 	}
 
 	app.Logger.Debugf("Current configuration: %s", app.Config.SafePrint())
@@ -69,12 +204,28 @@ func Start(ctx context.Context, path string) (*App, error) {
 		return nil, err
 	}
 
-	app.Client, err = app.NewOAuth2Client(ctx)
+	return app, nil
+}
+
+// ClientForAccount returns the HTTP client authenticated for the given account.
+// The client of the configured Account is Client, other clients are created on demand once.
+func (app *App) ClientForAccount(ctx context.Context, account string) (*http.Client, error) {
+	if account == app.Config.Account && app.Client != nil {
+		return app.Client, nil
+	}
+	if client, exist := app.clients[account]; exist {
+		return client, nil
+	}
+
+	client, err := app.NewOAuth2ClientForAccount(ctx, account)
 	if err != nil {
 		return nil, err
 	}
-
-	return app, nil
+	if app.clients == nil {
+		app.clients = make(map[string]*http.Client)
+	}
+	app.clients[account] = client
+	return client, nil
 }
 
 // Start initializes the application without reading the configuration.
@@ -105,6 +256,24 @@ func (app App) Stop() error {
 		return err
 	}
 
+	// Close retry queue
+	app.Logger.Debug("Shutting down Retry Queue service...")
+	if err := app.RetryQueue.Close(); err != nil {
+		return err
+	}
+
+	// Close request budget
+	app.Logger.Debug("Shutting down Request Budget service...")
+	if err := app.RequestBudget.Close(); err != nil {
+		return err
+	}
+
+	// Close last runs store
+	app.Logger.Debug("Shutting down Last Runs service...")
+	if err := app.LastRuns.Close(); err != nil {
+		return err
+	}
+
 	// Close token manager
 	app.Logger.Debug("Shutting down Token Manager service...")
 	if err := app.TokenManager.Close(); err != nil {
@@ -129,6 +298,22 @@ func (app App) CreateAppDataDir() (string, error) {
 	return filename, nil
 }
 
+// CreateAppDataDirWithConfig is like CreateAppDataDir, but writes cfg instead of the defaults. It returns error,
+// keeping the previous application directory, if cfg is not valid.
+func (app App) CreateAppDataDirWithConfig(cfg config.Config) (string, error) {
+	if problems := cfg.Diagnose(app.fs); len(problems) > 0 {
+		return "", problems[0].Err
+	}
+	if err := app.emptyDir(app.appDir); err != nil {
+		return "", err
+	}
+	filename := app.configFilename()
+	if err := config.Write(app.fs, filename, cfg); err != nil {
+		return "", err
+	}
+	return filename, nil
+}
+
 // AppDataDirExists return true if the application data dir exists.
 func (app App) AppDataDirExists() bool {
 	exist, err := afero.Exists(app.fs, app.configFilename())
@@ -142,42 +327,164 @@ func (app App) configFilename() string {
 	return filepath.Join(app.appDir, DefaultConfigFilename)
 }
 
+// readConfig returns the configuration merging the configuration files, if any, or the one in appDir.
+func (app App) readConfig() (*config.Config, error) {
+	if app.configStdin {
+		return config.FromReader(app.fs, app.stdin)
+	}
+	if len(app.configFiles) > 0 {
+		return config.FromFiles(app.fs, app.configFiles, app.configLists)
+	}
+	return config.FromFile(app.fs, app.configFilename())
+}
+
+// configSource returns the names of the files the configuration is read from.
+func (app App) configSource() string {
+	if app.configStdin {
+		return "stdin"
+	}
+	if len(app.configFiles) > 0 {
+		return strings.Join(app.configFiles, "', '")
+	}
+	return app.configFilename()
+}
+
 func (app *App) startServices() error {
 	var err error
+	app.ContentHashes = upload.NewContentHashes()
 	app.FileTracker, err = app.defaultFileTracker()
 	if err != nil {
 		app.Logger.Errorf("File tracker could not be started, err: %s", err)
 		return fmt.Errorf("file tracker could not be started, err: %s", err)
 	}
-	app.TokenManager, err = app.defaultTokenManager(app.Config.SecretsBackendType)
+	app.TokenManager, err = app.defaultTokenManager()
 	if err != nil {
 		app.Logger.Errorf("Token manager could not be started, err: %s", err)
-		return fmt.Errorf("token manager could not be started, type:%s, err: %s", app.Config.SecretsBackendType, err)
+		return fmt.Errorf("token manager could not be started, type:%s, err: %s", app.tokenStoreType(), err)
 	}
 	app.UploadSessionTracker, err = app.defaultUploadsSessionTracker()
 	if err != nil {
 		app.Logger.Errorf("Uploads session tracker could not be started, err: %s", err)
 		return fmt.Errorf("uploads session tracker could not be started, err:%s", err)
 	}
+	app.RetryQueue, err = retryqueue.Open(filepath.Join(app.appDir, "retries.db"), app.Config.MaxUploadAttempts)
+	if err != nil {
+		app.Logger.Errorf("Retry queue could not be started, err: %s", err)
+		return fmt.Errorf("retry queue could not be started, err: %s", err)
+	}
+	app.RequestBudget, err = quota.Open(filepath.Join(app.appDir, "quota.db"), app.Config.DailyRequestBudget)
+	if err != nil {
+		app.Logger.Errorf("Request budget could not be started, err: %s", err)
+		return fmt.Errorf("request budget could not be started, err: %s", err)
+	}
+	app.LastRuns, err = lastrun.Open(filepath.Join(app.appDir, "lastrun.db"))
+	if err != nil {
+		app.Logger.Errorf("Last runs store could not be started, err: %s", err)
+		return fmt.Errorf("last runs store could not be started, err: %s", err)
+	}
 	return nil
 }
 
 func (app App) defaultFileTracker() (*filetracker.FileTracker, error) {
-	repo, err := filetracker.NewLevelDBRepository(filepath.Join(app.appDir, "uploads.db"))
+	backend, err := app.fileTrackerRepository()
 	if err != nil {
 		return nil, err
 	}
-	return filetracker.New(repo), nil
+	// writes are batched by a single writer, so concurrent uploads don't contend on the backend.
+	repo := filetracker.NewBatchedRepository(backend, filetracker.DefaultBatchSize, filetracker.DefaultBatchInterval)
+	ft := filetracker.New(repo)
+	if app.Config.DedupStrategy == "hash" {
+		ft = filetracker.NewWithContentDedup(repo, app.ContentHashes)
+	}
+	ft.UnicodeForm = pathnorm.Form(app.Config.UnicodeNormalization)
+	// perceptual hashes are only computed if they are used, since every image has to be decoded.
+	if app.Config.NearDuplicateThreshold > 0 {
+		ft.PerceptualHasher = imagehash.DHash{}
+		ft.NearDuplicateThreshold = app.Config.NearDuplicateThreshold
+	}
+	return ft, nil
 }
 
-func (app App) defaultTokenManager(backendType string) (*tokenmanager.TokenManager, error) {
-	kr, err := tokenmanager.NewKeyringRepository(backendType, nil, app.appDir)
+// fileTrackerRepository returns the repository of the configured TrackerBackend.
+// If it's corrupted, it's moved aside when recoverTracker is set, or it fails explaining how to recover it.
+func (app App) fileTrackerRepository() (filetracker.Repository, error) {
+	open := func(path string) (filetracker.Repository, error) {
+		return filetracker.NewLevelDBRepository(path)
+	}
+	path := filepath.Join(app.appDir, "uploads.db")
+	if app.Config.TrackerBackend == "sqlite" {
+		open = func(path string) (filetracker.Repository, error) {
+			return filetracker.NewSQLiteRepository(path)
+		}
+		path = app.Config.TrackerDBPath
+		if path == "" {
+			path = filepath.Join(app.appDir, "uploads.sqlite")
+		}
+	}
+
+	repo, err := open(path)
+	if !errors.Is(err, filetracker.ErrCorrupted) {
+		return repo, err
+	}
+	if !app.recoverTracker {
+		return nil, fmt.Errorf("%w, '%s': run again with --recover-tracker to move it aside and start with an empty one, tracked files would be uploaded again", err, path)
+	}
+	moved, err := filetracker.MoveAside(path, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("corrupted tracking store could not be moved aside: %w", err)
+	}
+	app.Logger.Warnf("The tracking store '%s' is corrupted, it has been moved to '%s'. Starting with an empty one, tracked files would be uploaded again.", path, moved)
+	return open(path)
+}
+
+// readStdinToken reads the token, encoded as JSON, from stdin. The rest of stdin could be read afterwards.
+func (app *App) readStdinToken() error {
+	dec := json.NewDecoder(app.stdin)
+	var token oauth2.Token
+	if err := dec.Decode(&token); err != nil {
+		return fmt.Errorf("invalid token at 'stdin': %s", err)
+	}
+	if token.AccessToken == "" && token.RefreshToken == "" {
+		return fmt.Errorf("invalid token at 'stdin': %s", tokenmanager.ErrInvalidToken)
+	}
+	app.stdinToken = &token
+	app.stdin = io.MultiReader(dec.Buffered(), app.stdin)
+	return nil
+}
+
+func (app App) defaultTokenManager() (*tokenmanager.TokenManager, error) {
+	if app.stdinToken != nil {
+		return tokenmanager.New(tokenmanager.NewMemoryRepository(app.stdinToken)), nil
+	}
+	switch app.Config.TokenStore {
+	case "file":
+		repo, err := tokenmanager.NewFileRepository(filepath.Join(app.appDir, "tokens.enc"), os.Getenv(TokenStoreKeyEnvVar))
+		if err != nil {
+			return nil, err
+		}
+		return tokenmanager.New(repo), nil
+	case "env":
+		return tokenmanager.New(tokenmanager.NewEnvRepository(app.Config.TokenStoreEnvVar)), nil
+	}
+
+	kr, err := tokenmanager.NewKeyringRepository(app.Config.SecretsBackendType, nil, app.appDir)
 	if err != nil {
 		return nil, err
 	}
 	return tokenmanager.New(kr), nil
 }
 
+// tokenStoreType returns the type of the configured token store.
+func (app App) tokenStoreType() string {
+	if app.stdinToken != nil {
+		return "stdin"
+	}
+	if app.Config.TokenStore == "" || app.Config.TokenStore == "keyring" {
+		return app.Config.SecretsBackendType
+	}
+	return app.Config.TokenStore
+}
+
 func (app App) defaultUploadsSessionTracker() (*leveldbstore.LevelDBStore, error) {
 	return leveldbstore.NewStore(filepath.Join(app.appDir, "resumable_uploads.db"))
 }
@@ -191,9 +498,45 @@ func (app App) emptyDir(path string) error {
 
 // FileTracker represents a service to track file already uploaded.
 type FileTracker interface {
-	Put(file string) error
+	Put(file string, mediaItemID string) error
 	Exist(file string) bool
 	Delete(file string) error
+	TrackedAlbums(file string) (string, []string, bool)
+	AddAlbum(file string, album string) error
+	MarkFavorite(file string) error
+	Iterate(fn func(file string, item filetracker.TrackedFile) error) error
+	Export(w io.Writer) (int, error)
+	Import(r io.Reader) (filetracker.ImportStats, error)
+	Reset(prefix string, dryRun bool) ([]string, error)
+	Close() error
+}
+
+// RetryQueue represents a service to keep the failed uploads to be retried.
+type RetryQueue interface {
+	Failed(path string, reason string) (retryqueue.Entry, bool, error)
+	Rejected(path string, reason string) (retryqueue.Entry, error)
+	Remove(path string) error
+	Get(path string) (retryqueue.Entry, bool, error)
+	Due(path string) (bool, error)
+	Pending() ([]retryqueue.Entry, error)
+	DeadLetters() ([]retryqueue.Entry, error)
+	ClearDeadLetters() (int, error)
+	Close() error
+}
+
+// RequestBudget represents a service to count the requests made every day, see quota.Budget.
+type RequestBudget interface {
+	Take(ctx context.Context) error
+	Remaining() (int, error)
+	Renewal() time.Time
+	SetWaitForRenewal(wait bool)
+	Close() error
+}
+
+// LastRunStore represents a service to keep the last successful run of every folder, see lastrun.Store.
+type LastRunStore interface {
+	Set(folder string, run lastrun.Run) error
+	ChangedSince(folder string, fingerprint string, margin time.Duration) (time.Time, bool, error)
 	Close() error
 }
 
@@ -2,6 +2,7 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -10,6 +11,8 @@ import (
 
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/transport"
 )
 
 type PromptFunc func(code string) string
@@ -21,21 +24,50 @@ var (
 	// PhotosLibraryScope is Google Photos OAuth2 scope.
 	PhotosLibraryScope = "https://www.googleapis.com/auth/photoslibrary"
 
+	// DefaultUserAgent is the User-Agent of the requests if UserAgent is not configured. It's set with the version
+	// of the tool by the cmd package.
+	DefaultUserAgent = "gphotos-uploader-cli"
+
 	// AskForAuthCodeFn is the function used to get the Authorization code.
 	// Useful for testing
 	AskForAuthCodeFn = askForAuthCodeInTerminal
 )
 
-// NewOAuth2Client returns a HTTP client authenticated in Google Photos.
+// NewOAuth2Client returns a HTTP client authenticated in Google Photos, using the configured Account.
 // NewOAuth2Client will get (from Token Manager) or create the token.
 func (app App) NewOAuth2Client(ctx context.Context) (*http.Client, error) {
-	account := app.Config.Account
-	app.Logger.Infof("Getting OAuth token for '%s'", account)
+	return app.NewOAuth2ClientForAccount(ctx, app.Config.Account)
+}
 
-	token, err := app.TokenManager.Get(account)
+// NewOAuth2ClientForAccount returns a HTTP client authenticated in Google Photos for the given account.
+// Tokens are kept by account in the Token Manager, so every account has its own token.
+// If a service account key is configured, it's used instead of the interactive OAuth flow.
+func (app App) NewOAuth2ClientForAccount(ctx context.Context, account string) (*http.Client, error) {
+	// tokens are requested, and refreshed, through the proxy too.
+	base, err := app.newHTTPTransport()
 	if err != nil {
-		app.Logger.Debugf("Unable to retrieve token from token manager: %s", err)
+		return nil, err
+	}
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Transport: base})
+
+	creds := app.Config.APIAppCredentials
+	if creds.UsesServiceAccount() {
+		if creds.ClientID != "" || creds.ClientSecret != "" {
+			return nil, errors.New("service account key could not be used with client ID and client secret")
+		}
+		return app.newServiceAccountClient(ctx, account)
+	}
+
+	app.Logger.Infof("Getting OAuth token for '%s'", account)
+
+	var token *oauth2.Token
+	if !app.forceAuth {
+		token, err = app.TokenManager.Get(account)
+		if err != nil {
+			app.Logger.Debugf("Unable to retrieve token from token manager: %s", err)
+		}
 	}
+	stored := token
 
 	oauth2Config := oauth2.Config{
 		ClientID:     app.Config.APIAppCredentials.ClientID,
@@ -47,42 +79,105 @@ func (app App) NewOAuth2Client(ctx context.Context) (*http.Client, error) {
 	switch {
 	case token == nil:
 		app.Logger.Debug("Getting OAuth2 token from prompt...")
-		token, err = getOfflineOAuth2Token(ctx, oauth2Config)
+		token, err = getOfflineOAuth2Token(ctx, oauth2Config, base, app.authCodeInput())
 		if err != nil {
 			return nil, fmt.Errorf("unable to get token: %s", err)
 		}
 
 	case !token.Valid():
 		app.Logger.Debug("Token has been expired, refreshing it...")
-		token, err = oauth2Config.TokenSource(ctx, token).Token()
-		if err != nil {
-			app.Logger.Errorf("Unable to refresh the token, err: %s", err)
-			return nil, fmt.Errorf("unable to refresh the token: %s", err)
+	}
+
+	// the token source refreshes the token when it expires, keeping the new one in the Token Manager.
+	ts := newPersistentTokenSource(oauth2Config.TokenSource(ctx, token), app.TokenManager, account, stored, app.Logger)
+	token, err = ts.Token()
+	if err != nil {
+		app.Logger.Errorf("Unable to refresh the token, err: %s", err)
+		if errors.Is(err, ErrInvalidGrant) {
+			return nil, err
 		}
+		return nil, fmt.Errorf("unable to refresh the token: %s", err)
 	}
 
 	app.Logger.Donef("Token is valid, expires at %s", token.Expiry.String())
 
-	if err := app.TokenManager.Put(account, token); err != nil {
-		app.Logger.Debugf("Failed to store token into token manager: %s", err)
+	client := oauth2.NewClient(ctx, ts)
+	client.Transport = app.newAPITransport(client.Transport)
+	return client, nil
+}
+
+// newAPITransport returns the round tripper of the Google Photos API requests, counting them against
+// the request budget and retrying transient errors.
+func (app App) newAPITransport(base http.RoundTripper) http.RoundTripper {
+	rt := app.newRetryTransport(base)
+	if app.RequestBudget == nil {
+		return rt
 	}
+	return transport.NewQuota(rt, app.RequestBudget)
+}
 
-	client := oauth2Config.Client(ctx, token)
-	return client, nil
+// newHTTPTransport returns the round tripper sending the requests through the configured ProxyURL, or through
+// the proxy set by the environment, see transport.NewHTTPTransport. Requests have the configured UserAgent, or
+// DefaultUserAgent, and RequestHeaders. Connections to every host are bounded by MaxConnections.
+func (app App) newHTTPTransport() (http.RoundTripper, error) {
+	base, err := transport.NewHTTPTransport(app.Config.ProxyURL)
+	if err != nil {
+		return nil, err
+	}
+	transport.LimitConnections(base, app.Config.MaxConnections)
+	userAgent := app.Config.UserAgent
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+	return transport.NewHeaders(base, userAgent, app.Config.RequestHeaders), nil
+}
+
+// newRetryTransport returns a round tripper retrying transient errors, as set in the configuration.
+func (app App) newRetryTransport(base http.RoundTripper) http.RoundTripper {
+	rt := transport.NewRetry(base, app.MaxRetries(), app.RetryBaseDelay())
+	rt.RetryableMessages = app.Config.RetryableMessages
+	return rt
+}
+
+// MaxRetries returns the maximum number of retries of a transient error, as set in the configuration.
+func (app App) MaxRetries() int {
+	switch {
+	case app.Config.MaxRetries < 0:
+		return 0
+	case app.Config.MaxRetries > 0:
+		return app.Config.MaxRetries
+	}
+	return transport.DefaultMaxRetries
+}
+
+// RetryBaseDelay returns the delay before the first retry of a transient error, as set in the configuration.
+func (app App) RetryBaseDelay() time.Duration {
+	if d, err := time.ParseDuration(app.Config.RetryBaseDelay); err == nil && d > 0 {
+		return d
+	}
+	return transport.DefaultRetryBaseDelay
+}
+
+// authCodeInput returns the reader of the authorization code, see WithAuthCodeReader.
+func (app App) authCodeInput() io.Reader {
+	if app.authCodeReader == nil {
+		return os.Stdin
+	}
+	return app.authCodeReader
 }
 
-func getOfflineOAuth2Token(ctx context.Context, oauth2Config oauth2.Config) (*oauth2.Token, error) {
+func getOfflineOAuth2Token(ctx context.Context, oauth2Config oauth2.Config, base http.RoundTripper, r io.Reader) (*oauth2.Token, error) {
 	oauth2Config.RedirectURL = "urn:ietf:wg:oauth:2.0:oob"
 
 	// Redirect user to consent page to ask for permission for the specified scopes.
 	url := oauth2Config.AuthCodeURL("state", oauth2.AccessTypeOffline)
-	code, err := AskForAuthCodeFn(os.Stdin, url)
+	code, err := AskForAuthCodeFn(r, url)
 	if err != nil {
 		return nil, err
 	}
 
 	// Use the custom HTTP client with a short timeout when requesting a token.
-	ctx = context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Timeout: 2 * time.Second})
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Timeout: 2 * time.Second, Transport: base})
 	return oauth2Config.Exchange(ctx, code)
 }
 
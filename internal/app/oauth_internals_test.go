@@ -2,7 +2,11 @@ package app
 
 import (
 	"bytes"
+	"net/http"
 	"testing"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/config"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/transport"
 )
 
 func TestAskForAuthCodeInTerminal(t *testing.T) {
@@ -33,3 +37,22 @@ func assertExpectedError(t *testing.T, errExpected bool, err error, ) {
 		t.Fatalf("error was not expected, err: %s", err)
 	}
 }
+
+func TestApp_NewHTTPTransport(t *testing.T) {
+	app := App{Config: &config.Config{MaxConnections: 4}}
+	rt, err := app.newHTTPTransport()
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	headers, ok := rt.(*transport.Headers)
+	if !ok {
+		t.Fatalf("want: %T, got: %T", headers, rt)
+	}
+	base, ok := headers.Base.(*http.Transport)
+	if !ok {
+		t.Fatalf("want: %T, got: %T", base, headers.Base)
+	}
+	if base.MaxConnsPerHost != 4 || base.MaxIdleConnsPerHost != 4 {
+		t.Errorf("want: %d connections per host, got: %d connections and %d idle ones", 4, base.MaxConnsPerHost, base.MaxIdleConnsPerHost)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"golang.org/x/oauth2"
 
@@ -23,6 +24,7 @@ const (
 	ScenarioFailedOAuth2           = "should-fail-oauth2"
 	ScenarioSuccessfulExpiredToken = "successful-expired-token"
 	ScenarioFailedExpiredToken     = "failed-expired-token"
+	ScenarioInvalidGrant           = "invalid-grant"
 )
 
 func TestApp_NewOAuth2Client(t *testing.T) {
@@ -35,6 +37,7 @@ func TestApp_NewOAuth2Client(t *testing.T) {
 		{"Should success if OAuth2 token is refreshed", ScenarioSuccessfulExpiredToken, false},
 		{"Should fail if OAuth2 token is not refreshed", ScenarioFailedExpiredToken, true},
 		{"Should fail if OAuth2 token fails", ScenarioFailedOAuth2, true},
+		{"Should fail if refresh token has been revoked", ScenarioInvalidGrant, true},
 	}
 
 	srv := NewMockedGoogleAuthServer()
@@ -63,6 +66,90 @@ func TestApp_NewOAuth2Client(t *testing.T) {
 	}
 }
 
+func TestApp_NewOAuth2Client_InvalidGrant(t *testing.T) {
+	srv := NewMockedGoogleAuthServer()
+	defer srv.Close()
+
+	app.GoogleAuthEndpoint = oauth2.Endpoint{
+		AuthURL:   srv.baseURL + "/auth",
+		TokenURL:  srv.baseURL + "/token",
+		AuthStyle: oauth2.AuthStyleInParams,
+	}
+
+	_, err := newTestApp(ScenarioInvalidGrant).NewOAuth2Client(context.Background())
+	if !errors.Is(err, app.ErrInvalidGrant) {
+		t.Errorf("want: %v, got: %v", app.ErrInvalidGrant, err)
+	}
+}
+
+func TestApp_ClientForAccount(t *testing.T) {
+	tokens := map[string]*oauth2.Token{
+		"personal@domain.com": {AccessToken: "personal-token", TokenType: "Bearer", Expiry: time.Now().Add(time.Hour)},
+		"family@domain.com":   {AccessToken: "family-token", TokenType: "Bearer", Expiry: time.Now().Add(time.Hour)},
+	}
+	requested := make(map[string]int)
+
+	testApp := &app.App{
+		TokenManager: &mockedTokenManager{
+			GetFn: func(email string) (*oauth2.Token, error) {
+				requested[email]++
+				token, ok := tokens[email]
+				if !ok {
+					return nil, errors.New("token not found")
+				}
+				return token, nil
+			},
+			PutFn: func(email string, token *oauth2.Token) error {
+				return nil
+			},
+		},
+		Logger: log.Discard,
+		Config: &config.Config{
+			Account:  "personal@domain.com",
+			Accounts: []config.NamedAccount{{Name: "family", Account: "family@domain.com"}},
+			Jobs: []config.FolderUploadJob{
+				{SourceFolder: "personal"},
+				{SourceFolder: "family", Account: "family"},
+			},
+		},
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer srv.Close()
+
+	want := []string{"Bearer personal-token", "Bearer family-token"}
+	for i, job := range testApp.Config.Jobs {
+		account, err := testApp.Config.JobAccount(job)
+		if err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+		// clients are requested twice to check they are created once.
+		for j := 0; j < 2; j++ {
+			client, err := testApp.ClientForAccount(context.Background(), account)
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			res, err := client.Get(srv.URL)
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			got, _ := ioutil.ReadAll(res.Body)
+			_ = res.Body.Close()
+			if string(got) != want[i] {
+				t.Errorf("want: %s, got: %s", want[i], got)
+			}
+		}
+	}
+
+	for email := range tokens {
+		if requested[email] != 1 {
+			t.Errorf("want: token requested %d times, got: %d, account: %s", 1, requested[email], email)
+		}
+	}
+}
+
 func newTestApp(scenario string) *app.App {
 	var tokenManagerValue *oauth2.Token
 	var tokenManagerErr error
@@ -78,6 +165,11 @@ func newTestApp(scenario string) *app.App {
 			RefreshToken: ScenarioFailedExpiredToken,
 		}
 		tokenManagerErr = nil
+	case ScenarioInvalidGrant:
+		tokenManagerValue = &oauth2.Token{
+			RefreshToken: ScenarioInvalidGrant,
+		}
+		tokenManagerErr = nil
 	default:
 		tokenManagerErr = errors.New("error-in-token-manager")
 	}
@@ -127,6 +219,10 @@ func (ms mockedGoogleAuthServer) handleToken(w http.ResponseWriter, r *http.Requ
 	switch {
 	case clientId == "" || clientId == ScenarioFailedOAuth2 || clientId == ScenarioFailedExpiredToken:
 		w.WriteHeader(http.StatusInternalServerError)
+	case clientId == ScenarioInvalidGrant:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error": "invalid_grant", "error_description": "Token has been expired or revoked."}`))
 	case clientId == ScenarioSuccessfulExpiredToken:
 		w.Header().Set("Content-Type", "application/json")
 		_, _ = w.Write([]byte(`{"access_token": "my-access-token", "scope": "user", "token_type": "bearer", "expires_in": 86400}`))
@@ -0,0 +1,48 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// newServiceAccountClient returns a HTTP client authenticated in Google Photos using the configured service account key.
+// Tokens are issued from the key, so they are not kept in the Token Manager.
+func (app App) newServiceAccountClient(ctx context.Context, account string) (*http.Client, error) {
+	creds := app.Config.APIAppCredentials
+	app.Logger.Infof("Getting service account token for '%s'", account)
+
+	ts, err := ServiceAccountTokenSource(ctx, creds.ServiceAccountKey, creds.Subject)
+	if err != nil {
+		return nil, err
+	}
+	token, err := ts.Token()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get service account token: %s", err)
+	}
+
+	app.Logger.Donef("Token is valid, expires at %s", token.Expiry.String())
+
+	client := oauth2.NewClient(ctx, oauth2.ReuseTokenSource(token, ts))
+	client.Transport = app.newAPITransport(client.Transport)
+	return client, nil
+}
+
+// ServiceAccountTokenSource returns a token source using the service account JSON key at keyFile.
+// If subject is set, tokens are issued on behalf of that user, which requires domain-wide delegation.
+func ServiceAccountTokenSource(ctx context.Context, keyFile string, subject string) (oauth2.TokenSource, error) {
+	b, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read service account key: %s", err)
+	}
+	jwtConfig, err := google.JWTConfigFromJSON(b, PhotosLibraryScope)
+	if err != nil {
+		return nil, fmt.Errorf("invalid service account key '%s': %s", keyFile, err)
+	}
+	jwtConfig.Subject = subject
+	return jwtConfig.TokenSource(ctx), nil
+}
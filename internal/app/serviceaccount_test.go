@@ -0,0 +1,186 @@
+package app_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/oauth2"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/app"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/config"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+)
+
+func TestApp_NewOAuth2Client_ServiceAccount(t *testing.T) {
+	var gotSubject string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			_ = r.ParseForm()
+			if r.PostForm.Get("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			gotSubject = jwtClaim(t, r.PostForm.Get("assertion"), "sub")
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"access_token": "service-account-token", "token_type": "Bearer", "expires_in": 3600}`))
+		default:
+			_, _ = w.Write([]byte(r.Header.Get("Authorization")))
+		}
+	}))
+	defer srv.Close()
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	keyFile := writeServiceAccountKey(t, dir, srv.URL+"/token")
+
+	testApp := &app.App{
+		TokenManager: &mockedTokenManager{
+			GetFn: func(email string) (*oauth2.Token, error) {
+				t.Errorf("token manager should not be used with a service account")
+				return nil, errors.New("unexpected call")
+			},
+		},
+		Logger: log.Discard,
+		Config: &config.Config{
+			APIAppCredentials: config.APIAppCredentials{
+				ServiceAccountKey: keyFile,
+				Subject:           "user@domain.com",
+			},
+			Account: "user@domain.com",
+		},
+	}
+
+	client, err := testApp.NewOAuth2Client(context.Background())
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if gotSubject != "user@domain.com" {
+		t.Errorf("want: %s, got: %s", "user@domain.com", gotSubject)
+	}
+
+	res, err := client.Get(srv.URL + "/photos")
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	defer res.Body.Close()
+	got, _ := ioutil.ReadAll(res.Body)
+	if want := "Bearer service-account-token"; string(got) != want {
+		t.Errorf("want: %s, got: %s", want, got)
+	}
+}
+
+func TestApp_NewOAuth2Client_ServiceAccountAndClientID(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	testApp := &app.App{
+		Logger: log.Discard,
+		Config: &config.Config{
+			APIAppCredentials: config.APIAppCredentials{
+				ClientID:          "client-id",
+				ClientSecret:      "client-secret",
+				ServiceAccountKey: writeServiceAccountKey(t, dir, "http://localhost/token"),
+			},
+			Account: "user@domain.com",
+		},
+	}
+
+	_, err := testApp.NewOAuth2Client(context.Background())
+	assertExpectedError(t, true, err)
+}
+
+func TestServiceAccountTokenSource(t *testing.T) {
+	testCases := []struct {
+		name          string
+		key           string
+		isErrExpected bool
+	}{
+		{"Should success", "valid", false},
+		{"Should fail if key file does not exist", "non-existent", true},
+		{"Should fail if key file is invalid", "invalid", true},
+	}
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			keyFile := filepath.Join(dir, "non-existent.json")
+			switch tc.key {
+			case "valid":
+				keyFile = writeServiceAccountKey(t, dir, "http://localhost/token")
+			case "invalid":
+				keyFile = filepath.Join(dir, "invalid.json")
+				if err := ioutil.WriteFile(keyFile, []byte(`{"type": "authorized_user"}`), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			_, err := app.ServiceAccountTokenSource(context.Background(), keyFile, "")
+			assertExpectedError(t, tc.isErrExpected, err)
+		})
+	}
+}
+
+func tempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "service-account")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// writeServiceAccountKey returns the path of a fake service account key, in dir, issuing tokens from tokenURL.
+func writeServiceAccountKey(t *testing.T, dir string, tokenURL string) string {
+	t.Helper()
+	pk, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _ := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"client_email":   "uploader@project.iam.gserviceaccount.com",
+		"private_key_id": "key-id",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(pk)})),
+		"token_uri":      tokenURL,
+	})
+	keyFile := filepath.Join(dir, "service-account.json")
+	if err := ioutil.WriteFile(keyFile, key, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return keyFile
+}
+
+// jwtClaim returns the claim of a JWT, without verifying its signature.
+func jwtClaim(t *testing.T, token string, claim string) string {
+	t.Helper()
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("invalid JWT: %s", token)
+	}
+	b, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := make(map[string]interface{})
+	if err := json.Unmarshal(b, &claims); err != nil {
+		t.Fatal(err)
+	}
+	value, _ := claims[claim].(string)
+	return value
+}
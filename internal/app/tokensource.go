@@ -0,0 +1,100 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"golang.org/x/oauth2"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/datastore/tokenmanager"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+)
+
+// ErrInvalidGrant is returned when the refresh token has expired or has been revoked.
+// The only way to recover is to authenticate again.
+var ErrInvalidGrant = errors.New("the authorization has expired or has been revoked")
+
+// persistentTokenSource is an oauth2.TokenSource keeping the tokens in the Token Manager.
+// Tokens are only written when they change, e.g. after a refresh or when the refresh token is rotated.
+type persistentTokenSource struct {
+	source  oauth2.TokenSource
+	store   TokenManager
+	account string
+	logger  log.Logger
+
+	mu   sync.Mutex
+	last *oauth2.Token
+}
+
+// newPersistentTokenSource returns a token source getting tokens from source and keeping them for the account.
+// The stored token, if any, is the one already kept in the store.
+func newPersistentTokenSource(source oauth2.TokenSource, store TokenManager, account string, stored *oauth2.Token, logger log.Logger) *persistentTokenSource {
+	return &persistentTokenSource{
+		source:  source,
+		store:   store,
+		account: account,
+		logger:  logger,
+		last:    stored,
+	}
+}
+
+// Token returns a valid token, refreshing it if it's needed.
+// It returns ErrInvalidGrant if the refresh token is no longer valid.
+func (s *persistentTokenSource) Token() (*oauth2.Token, error) {
+	token, err := s.source.Token()
+	if err != nil {
+		if isInvalidGrant(err) {
+			return nil, fmt.Errorf("%w, account '%s'", ErrInvalidGrant, s.account)
+		}
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sameToken(s.last, token) {
+		return token, nil
+	}
+	err = s.store.Put(s.account, token)
+	if errors.Is(err, tokenmanager.ErrReadOnlyRepository) {
+		// it could not be written on the next call either, e.g. the token read from the standard input.
+		s.logger.Debugf("Token for '%s' has changed, it's not stored since the token store is read only.", s.account)
+		s.last = token
+		return token, nil
+	}
+	if err != nil {
+		// last is not updated, so it will be written again on the next call.
+		s.logger.Debugf("Failed to store token into token manager: %s", err)
+		return token, nil
+	}
+	s.logger.Debugf("Token for '%s' has changed, it has been stored.", s.account)
+	s.last = token
+	return token, nil
+}
+
+// sameToken returns true if both tokens are equal.
+func sameToken(a, b *oauth2.Token) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.AccessToken == b.AccessToken &&
+		a.RefreshToken == b.RefreshToken &&
+		a.TokenType == b.TokenType &&
+		a.Expiry.Equal(b.Expiry)
+}
+
+// isInvalidGrant returns true if the error is an `invalid_grant` response from the token endpoint.
+func isInvalidGrant(err error) bool {
+	var retrieveErr *oauth2.RetrieveError
+	if !errors.As(err, &retrieveErr) {
+		return false
+	}
+	var body struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(retrieveErr.Body, &body); err != nil {
+		return false
+	}
+	return body.Error == "invalid_grant"
+}
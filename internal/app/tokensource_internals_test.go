@@ -0,0 +1,161 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/datastore/tokenmanager"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+)
+
+func TestPersistentTokenSource_Token(t *testing.T) {
+	expiry := time.Now().Add(time.Hour)
+	stored := &oauth2.Token{AccessToken: "access-1", RefreshToken: "refresh-1", Expiry: expiry}
+
+	// the refresh token is rotated on the third token.
+	source := &rotatingTokenSource{tokens: []*oauth2.Token{
+		{AccessToken: "access-1", RefreshToken: "refresh-1", Expiry: expiry},
+		{AccessToken: "access-1", RefreshToken: "refresh-1", Expiry: expiry},
+		{AccessToken: "access-2", RefreshToken: "refresh-2", Expiry: expiry.Add(time.Hour)},
+		{AccessToken: "access-2", RefreshToken: "refresh-2", Expiry: expiry.Add(time.Hour)},
+	}}
+	store := &memoryTokenManager{tokens: map[string]*oauth2.Token{"account": stored}}
+
+	ts := newPersistentTokenSource(source, store, "account", stored, log.Discard)
+
+	wantWrites := []int{0, 0, 1, 1}
+	for i, want := range wantWrites {
+		if _, err := ts.Token(); err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+		if store.writes != want {
+			t.Errorf("want: %d writes, got: %d, token: %d", want, store.writes, i)
+		}
+	}
+
+	if got := store.tokens["account"].RefreshToken; got != "refresh-2" {
+		t.Errorf("want: %s, got: %s", "refresh-2", got)
+	}
+}
+
+func TestPersistentTokenSource_TokenWithoutStoredToken(t *testing.T) {
+	source := &rotatingTokenSource{tokens: []*oauth2.Token{{AccessToken: "access-1", RefreshToken: "refresh-1"}}}
+	store := &memoryTokenManager{tokens: map[string]*oauth2.Token{}}
+
+	ts := newPersistentTokenSource(source, store, "account", nil, log.Discard)
+	if _, err := ts.Token(); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if store.writes != 1 {
+		t.Errorf("want: %d writes, got: %d", 1, store.writes)
+	}
+}
+
+func TestPersistentTokenSource_TokenReadOnlyStore(t *testing.T) {
+	expiry := time.Now().Add(time.Hour)
+	stored := &oauth2.Token{AccessToken: "access-1", RefreshToken: "refresh-1", Expiry: expiry}
+	source := &rotatingTokenSource{tokens: []*oauth2.Token{
+		{AccessToken: "access-2", RefreshToken: "refresh-1", Expiry: expiry.Add(time.Hour)},
+		{AccessToken: "access-2", RefreshToken: "refresh-1", Expiry: expiry.Add(time.Hour)},
+	}}
+	store := &memoryTokenManager{tokens: map[string]*oauth2.Token{"account": stored}, readOnly: true}
+
+	ts := newPersistentTokenSource(source, store, "account", stored, log.Discard)
+	for i := 0; i < 2; i++ {
+		token, err := ts.Token()
+		if err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+		if token.AccessToken != "access-2" {
+			t.Errorf("want: %s, got: %s", "access-2", token.AccessToken)
+		}
+	}
+	// the refreshed token is not written again, since it could not be.
+	if store.writes != 1 {
+		t.Errorf("want: %d writes, got: %d", 1, store.writes)
+	}
+}
+
+func TestPersistentTokenSource_TokenInvalidGrant(t *testing.T) {
+	testCases := []struct {
+		name           string
+		err            error
+		isInvalidGrant bool
+	}{
+		{"Should return ErrInvalidGrant if refresh token is revoked", &oauth2.RetrieveError{Response: &http.Response{StatusCode: http.StatusBadRequest}, Body: []byte(`{"error": "invalid_grant"}`)}, true},
+		{"Should return the error if it's another error", &oauth2.RetrieveError{Response: &http.Response{StatusCode: http.StatusBadRequest}, Body: []byte(`{"error": "invalid_client"}`)}, false},
+		{"Should return the error if it's not a token response", errors.New("connection refused"), false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			source := &rotatingTokenSource{err: tc.err}
+			store := &memoryTokenManager{tokens: map[string]*oauth2.Token{}}
+
+			_, err := newPersistentTokenSource(source, store, "account", nil, log.Discard).Token()
+			if err == nil {
+				t.Fatalf("error was expected, but not produced")
+			}
+			if got := errors.Is(err, ErrInvalidGrant); got != tc.isInvalidGrant {
+				t.Errorf("want: %t, got: %t, err: %s", tc.isInvalidGrant, got, err)
+			}
+			if store.writes != 0 {
+				t.Errorf("want: %d writes, got: %d", 0, store.writes)
+			}
+		})
+	}
+}
+
+// rotatingTokenSource returns its tokens in order, repeating the last one, or err if it's set.
+type rotatingTokenSource struct {
+	tokens []*oauth2.Token
+	err    error
+	calls  int
+}
+
+func (s *rotatingTokenSource) Token() (*oauth2.Token, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	i := s.calls
+	if i >= len(s.tokens) {
+		i = len(s.tokens) - 1
+	}
+	s.calls++
+	// returns a copy, as a refreshed token is a new value.
+	token := *s.tokens[i]
+	return &token, nil
+}
+
+// memoryTokenManager keeps tokens in memory, counting the writes.
+type memoryTokenManager struct {
+	tokens map[string]*oauth2.Token
+	writes int
+	// readOnly fails the writes with tokenmanager.ErrReadOnlyRepository.
+	readOnly bool
+}
+
+func (m *memoryTokenManager) Put(email string, token *oauth2.Token) error {
+	m.writes++
+	if m.readOnly {
+		return tokenmanager.ErrReadOnlyRepository
+	}
+	m.tokens[email] = token
+	return nil
+}
+
+func (m *memoryTokenManager) Get(email string) (*oauth2.Token, error) {
+	token, ok := m.tokens[email]
+	if !ok {
+		return nil, errors.New("token not found")
+	}
+	return token, nil
+}
+
+func (m *memoryTokenManager) Close() error {
+	return nil
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/app"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/cmd/flags"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/library"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/photosapi"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/prune"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/ratelimit"
+)
+
+// defaultPruneRate is the default number of requests per second made to find the empty albums.
+const defaultPruneRate = 5
+
+// AlbumsCmd holds the required data for the albums cmd
+type AlbumsCmd struct {
+	*flags.GlobalFlags
+
+	// prune-empty command flags
+	Delete bool
+	Rate   int
+}
+
+func NewAlbumsCmd(globalFlags *flags.GlobalFlags) *cobra.Command {
+	cmd := &AlbumsCmd{GlobalFlags: globalFlags}
+
+	albumsCmd := &cobra.Command{
+		Use:   "albums",
+		Short: "Manage the albums created in Google Photos",
+		Long:  `Manage the albums created in Google Photos by this tool.`,
+		Args:  cobra.NoArgs,
+	}
+
+	pruneCmd := &cobra.Command{
+		Use:   "prune-empty",
+		Short: "Report the albums without photos nor videos",
+		Long: `Report the albums created by this tool that have no media items, e.g. because their photos have been deleted.
+The media items of every album are searched, so it could take a while. Use --delete to remove them, and --dry-run
+to preview it. The Google Photos API doesn't allow to delete albums, so they are reported to be removed in Google Photos.`,
+		Args: cobra.NoArgs,
+		RunE: cmd.PruneEmpty,
+	}
+	pruneCmd.Flags().BoolVar(&cmd.Delete, "delete", false, "Delete the empty albums, if the Google Photos API allows it")
+	pruneCmd.Flags().IntVar(&cmd.Rate, "rate", defaultPruneRate, "Maximum number of requests per second")
+	albumsCmd.AddCommand(pruneCmd)
+
+	return albumsCmd
+}
+
+func (cmd *AlbumsCmd) PruneEmpty(cobraCmd *cobra.Command, args []string) error {
+	if cmd.Rate < 1 {
+		return fmt.Errorf("invalid rate: %d", cmd.Rate)
+	}
+
+	ctx := context.Background()
+	cli, err := app.Start(ctx, cmd.CfgDir, appOptions(cmd.GlobalFlags)...)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = cli.Stop()
+	}()
+
+	// all the accounts share the limiter, since quotas are per project.
+	limiter := ratelimit.NewLimiter(int64(cmd.Rate))
+	endpoints := library.NewEndpoints(cli.Config.PhotosAPIBaseURL)
+
+	var total prune.Report
+	for _, account := range accounts(cli.Config) {
+		client, err := cli.ClientForAccount(ctx, account)
+		if err != nil {
+			return err
+		}
+		// throttled requests are retried, since every album is searched.
+		retrying := photosapi.RetryingClient(client)
+		lister := library.NewAlbumsService(retrying)
+		lister.Endpoint = endpoints.Albums()
+		lister.Limiter = limiter
+		search := library.NewSearchService(retrying)
+		search.Endpoint = endpoints.MediaItemsSearch()
+		search.Limiter = limiter
+
+		cli.Logger.Infof("Looking for empty albums in account '%s'.", account)
+		e := prune.EmptyAlbums{Albums: lister, MediaItems: search, Logger: cli.Logger}
+		report, err := e.Find(ctx)
+		total.Checked += report.Checked
+		total.Empty = append(total.Empty, report.Empty...)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, album := range total.Empty {
+		switch {
+		case !cmd.Delete:
+			cli.Logger.Infof("Album '%s' is empty: %s", album.Title, album.ProductURL)
+		case cmd.DryRun:
+			cli.Logger.Infof("Would delete empty album '%s': %s", album.Title, album.ProductURL)
+		default:
+			cli.Logger.Warnf("Empty album '%s' could not be deleted, the Google Photos API doesn't allow it. Remove it in Google Photos: %s", album.Title, album.ProductURL)
+		}
+	}
+	cli.Logger.Donef("%d checked albums: %d empty", total.Checked, len(total.Empty))
+	return nil
+}
@@ -30,7 +30,7 @@ func NewAuthCmd(globalFlags *flags.GlobalFlags) *cobra.Command {
 
 func (cmd *AuthCmd) Run(cobraCmd *cobra.Command, args []string) error {
 	ctx := context.Background()
-	cli, err := app.Start(ctx, cmd.CfgDir)
+	cli, err := app.StartWithNewAuth(ctx, cmd.CfgDir, appOptions(cmd.GlobalFlags)...)
 	if err != nil {
 		return err
 	}
@@ -39,5 +39,12 @@ func (cmd *AuthCmd) Run(cobraCmd *cobra.Command, args []string) error {
 	}()
 
 	cli.Logger.Donef("Successful authentication for account '%s'", cli.Config.Account)
+
+	for _, account := range cli.Config.Accounts {
+		if _, err := cli.ClientForAccount(ctx, account.Account); err != nil {
+			return err
+		}
+		cli.Logger.Donef("Successful authentication for account '%s'", account.Account)
+	}
 	return nil
 }
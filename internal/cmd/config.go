@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/app"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/cmd/flags"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/config"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+)
+
+// ConfigCmd holds the required data for the config cmd
+type ConfigCmd struct {
+	*flags.GlobalFlags
+
+	// migrate command flags
+	Write bool
+}
+
+func NewConfigCmd(globalFlags *flags.GlobalFlags) *cobra.Command {
+	cmd := &ConfigCmd{GlobalFlags: globalFlags}
+
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Manage the configuration file",
+		Long:  `Manage the configuration file.`,
+		Args:  cobra.NoArgs,
+	}
+
+	configCmd.AddCommand(&cobra.Command{
+		Use:   "validate",
+		Short: "Validate the configuration file",
+		Long: `Validate the configuration file, reporting all the problems found with its location, e.g.
+
+  Jobs[1].IncludePatterns[2]: pattern is invalid, 're:IMG_[0-9': ...
+
+Jobs and patterns are counted from 0. It exits with a non-zero code if any problem is found.`,
+		Args: cobra.NoArgs,
+		RunE: cmd.Validate,
+	})
+
+	migrateCmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Upgrade the configuration file to the current version",
+		Long: `Upgrade the configuration file to the current version, listing the changes made.
+Older versions are upgraded in memory every time the configuration is read, use --write to update the file.
+The original file is kept as a backup, e.g. config.hjson.v1.bak. Comments are not kept in the updated file.`,
+		Args: cobra.NoArgs,
+		RunE: cmd.Migrate,
+	}
+	migrateCmd.Flags().BoolVar(&cmd.Write, "write", false, "Write the upgraded configuration, keeping a backup of the original file")
+	configCmd.AddCommand(migrateCmd)
+
+	return configCmd
+}
+
+func (cmd *ConfigCmd) Validate(cobraCmd *cobra.Command, args []string) error {
+	filenames := configFiles(cmd.GlobalFlags)
+	filename := strings.Join(filenames, "', '")
+	problems, err := config.ValidateFiles(Os, filenames, config.ListMerge(cmd.CfgLists))
+	if err != nil {
+		return fmt.Errorf("configuration at '%s' could not be read: %s", filename, err)
+	}
+	if len(problems) == 0 {
+		log.Donef("Configuration at '%s' is valid.", filename)
+		return nil
+	}
+	for _, p := range problems {
+		log.Failf("%s", p)
+	}
+	return fmt.Errorf("configuration at '%s' is invalid, %d problems found", filename, len(problems))
+}
+
+func (cmd *ConfigCmd) Migrate(cobraCmd *cobra.Command, args []string) error {
+	filenames := configFiles(cmd.GlobalFlags)
+	if len(filenames) > 1 {
+		return fmt.Errorf("configuration files are migrated one at a time, %d were set", len(filenames))
+	}
+	filename := filenames[0]
+	m, err := config.Migrate(Os, filename)
+	if err != nil {
+		return fmt.Errorf("configuration at '%s' could not be migrated: %s", filename, err)
+	}
+	if len(m.Changes) == 0 {
+		log.Donef("Configuration at '%s' is already at version %d.", filename, config.CurrentVersion)
+		return nil
+	}
+
+	log.Infof("Configuration at '%s' is upgraded from version %d to %d:", filename, m.From, config.CurrentVersion)
+	for _, change := range m.Changes {
+		log.Infof("  %s", change)
+	}
+	if !cmd.Write {
+		log.Info("Run with --write to update the file.")
+		return nil
+	}
+
+	backup, err := m.Write(Os, filename)
+	if err != nil {
+		return err
+	}
+	log.Donef("Configuration has been updated, the original one is kept at '%s'.", backup)
+	return nil
+}
+
+// configFiles returns the configuration files set using the `--config` flag, or the one in the config folder.
+func configFiles(globalFlags *flags.GlobalFlags) []string {
+	if len(globalFlags.CfgFiles) > 0 {
+		return globalFlags.CfgFiles
+	}
+	return []string{filepath.Join(globalFlags.CfgDir, app.DefaultConfigFilename)}
+}
+
+// appOptions returns the options to start the application, merging the configuration files set using the
+// `--config` flag, if any, and recovering the tracking store if `--recover-tracker` is set.
+func appOptions(globalFlags *flags.GlobalFlags) []app.Option {
+	var opts []app.Option
+	if len(globalFlags.CfgFiles) > 0 {
+		opts = append(opts, app.WithConfigFiles(globalFlags.CfgFiles, config.ListMerge(globalFlags.CfgLists)))
+	}
+	if globalFlags.RecoverTracker {
+		opts = append(opts, app.WithRecoverTracker())
+	}
+	if globalFlags.CfgStdin || globalFlags.TokenStdin {
+		opts = append(opts, app.WithStdin(globalFlags.StdinReader(), globalFlags.CfgStdin, globalFlags.TokenStdin))
+	}
+	return opts
+}
@@ -0,0 +1,125 @@
+package cmd_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/pflag"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/app"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/cmd"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/cmd/flags"
+)
+
+func TestConfigCmd_Validate(t *testing.T) {
+	testCases := []struct {
+		name          string
+		config        string
+		isErrExpected bool
+	}{
+		{"Should success if config is valid", "", false},
+		{"Should fail if config is invalid", `{
+  APIAppCredentials: { ClientID: "client-id", ClientSecret: "client-secret" }
+  Account: "youremail@domain.com"
+  SecretsBackendType: "auto"
+  UploadOrder: "random"
+  Jobs: [
+    { SourceFolder: "/non-existent", CreateAlbums: "Off", IncludePatterns: ["re:[a-"] }
+  ]
+}`, true},
+		{"Should fail if config is not HJSON", `{ Jobs: [ }`, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := createTrackerTestAppDir(t)
+			defer os.RemoveAll(dir)
+			if tc.config != "" {
+				if err := ioutil.WriteFile(filepath.Join(dir, app.DefaultConfigFilename), []byte(tc.config), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			c := cmd.NewConfigCmd(&flags.GlobalFlags{CfgDir: dir})
+			c.SetArgs([]string{"validate"})
+			assertExpectedError(t, tc.isErrExpected, c.Execute())
+		})
+	}
+}
+
+func TestConfigCmd_ValidateMergedFiles(t *testing.T) {
+	testCases := []struct {
+		name          string
+		local         string
+		isErrExpected bool
+	}{
+		{"Should success if merged config is valid", `{ UploadWorkerCount: 4 }`, false},
+		{"Should fail if merged config is invalid", `{ UploadOrder: "random" }`, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := createTrackerTestAppDir(t)
+			defer os.RemoveAll(dir)
+			local := filepath.Join(dir, "local.hjson")
+			if err := ioutil.WriteFile(local, []byte(tc.local), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+			globalFlags := flags.SetGlobalFlags(fs)
+			if err := fs.Parse([]string{"--config", filepath.Join(dir, app.DefaultConfigFilename), "--config", local}); err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			if len(globalFlags.CfgFiles) != 2 {
+				t.Fatalf("want: %d config files, got: %v", 2, globalFlags.CfgFiles)
+			}
+
+			c := cmd.NewConfigCmd(globalFlags)
+			c.SetArgs([]string{"validate"})
+			assertExpectedError(t, tc.isErrExpected, c.Execute())
+		})
+	}
+}
+
+func TestConfigCmd_Migrate(t *testing.T) {
+	testCases := []struct {
+		name       string
+		args       []string
+		wantBackup bool
+	}{
+		{"Should not write without --write", []string{"migrate"}, false},
+		{"Should write with --write", []string{"migrate", "--write"}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := createTrackerTestAppDir(t)
+			defer os.RemoveAll(dir)
+			filename := filepath.Join(dir, app.DefaultConfigFilename)
+			original, err := ioutil.ReadFile(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			c := cmd.NewConfigCmd(&flags.GlobalFlags{CfgDir: dir})
+			c.SetArgs(tc.args)
+			assertExpectedError(t, false, c.Execute())
+
+			_, err = os.Stat(filename + ".v1.bak")
+			if gotBackup := err == nil; gotBackup != tc.wantBackup {
+				t.Errorf("want backup: %t, got: %t", tc.wantBackup, gotBackup)
+			}
+			b, err := ioutil.ReadFile(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gotChanged := !bytes.Equal(b, original); gotChanged != tc.wantBackup {
+				t.Errorf("want changed: %t, got: %t", tc.wantBackup, gotChanged)
+			}
+		})
+	}
+}
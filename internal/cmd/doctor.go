@@ -0,0 +1,210 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/app"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/cmd/flags"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/config"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/library"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/pathnorm"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/upload"
+)
+
+// doctorSampleSize is the maximum number of files of every folder checked against its filter.
+const doctorSampleSize = 1000
+
+// errSampleComplete stops walking a folder once the sample is complete.
+var errSampleComplete = errors.New("sample is complete")
+
+// DoctorCmd holds the required data for the doctor cmd
+type DoctorCmd struct {
+	*flags.GlobalFlags
+}
+
+func NewDoctorCmd(globalFlags *flags.GlobalFlags) *cobra.Command {
+	cmd := &DoctorCmd{GlobalFlags: globalFlags}
+
+	doctorCmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check that the environment is ready to upload files",
+		Long: `Check that the configuration is valid, the local databases could be opened, every account is authorized
+to use Google Photos, and the source folders could be read. The filter of every job is applied to a sample
+of its files, reporting how many of them would be uploaded.
+It exits with a non-zero code if any critical check fails.`,
+		Args: cobra.NoArgs,
+		RunE: cmd.Run,
+	}
+
+	return doctorCmd
+}
+
+// diagnosis reports the checks made by the doctor, counting the critical ones failed.
+type diagnosis struct {
+	failed int
+}
+
+// report logs the result of a check. The run fails if it's critical and it has failed.
+func (d *diagnosis) report(name string, critical bool, detail string, err error) {
+	switch {
+	case err == nil:
+		log.Donef("%s: %s", name, detail)
+	case critical:
+		d.failed++
+		log.Failf("%s: %s", name, err)
+	default:
+		log.Failf("%s: %s (not critical)", name, err)
+	}
+}
+
+func (cmd *DoctorCmd) Run(cobraCmd *cobra.Command, args []string) error {
+	d := &diagnosis{}
+	cmd.diagnose(context.Background(), d)
+	if d.failed > 0 {
+		return fmt.Errorf("%d critical checks have failed", d.failed)
+	}
+	log.Done("All critical checks have passed, the environment is ready to upload files.")
+	return nil
+}
+
+// diagnose runs the checks, the ones depending on a failed check are not run.
+func (cmd *DoctorCmd) diagnose(ctx context.Context, d *diagnosis) {
+	filenames := configFiles(cmd.GlobalFlags)
+	filename := strings.Join(filenames, "', '")
+	problems, err := config.ValidateFiles(Os, filenames, config.ListMerge(cmd.CfgLists))
+	if err == nil && len(problems) > 0 {
+		for _, p := range problems {
+			log.Failf("  %s", p)
+		}
+		err = fmt.Errorf("%d problems found, run `config validate` for details", len(problems))
+	}
+	d.report("Configuration", true, fmt.Sprintf("'%s' is valid", filename), err)
+	if err != nil {
+		return
+	}
+
+	cli, err := app.StartWithoutAuth(cmd.CfgDir, appOptions(cmd.GlobalFlags)...)
+	d.report("Local databases", true, fmt.Sprintf("opened at '%s'", cmd.CfgDir), err)
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = cli.Stop()
+	}()
+
+	for _, account := range accounts(cli.Config) {
+		d.report(fmt.Sprintf("Account '%s'", account), true, "authorized to use Google Photos", checkAccount(ctx, cli, account))
+	}
+
+	for _, job := range cli.Config.Jobs {
+		readErr := checkFolderReadable(job.SourceFolder)
+		d.report(fmt.Sprintf("Folder '%s'", job.SourceFolder), true, "readable", readErr)
+		if readErr != nil {
+			continue
+		}
+		detail, err := sampleFilter(job, pathnorm.Form(cli.Config.UnicodeNormalization))
+		d.report(fmt.Sprintf("Filter of '%s'", job.SourceFolder), false, detail, err)
+	}
+}
+
+// checkAccount returns an error if the account has not been authorized, or Google Photos could not be used with it.
+// It doesn't ask for a new authorization if there is none, `auth` should be run instead.
+func checkAccount(ctx context.Context, cli *app.App, account string) error {
+	if !cli.Config.APIAppCredentials.UsesServiceAccount() {
+		if _, err := cli.TokenManager.Get(account); err != nil {
+			return fmt.Errorf("token could not be read, run `auth` to authorize it: %s", err)
+		}
+	}
+	client, err := cli.ClientForAccount(ctx, account)
+	if err != nil {
+		return err
+	}
+
+	// the request lists an album at most, it's the cheapest request needing the authorization.
+	probeURL := library.NewEndpoints(cli.Config.PhotosAPIBaseURL).Albums() + "?pageSize=1"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, probeURL, nil)
+	if err != nil {
+		return err
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Google Photos could not be reached: %s", err)
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, res.Body)
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("Google Photos has refused the request: %s", res.Status)
+	}
+	return nil
+}
+
+// checkFolderReadable returns an error if the entries of the folder could not be read.
+func checkFolderReadable(folder string) error {
+	f, err := os.Open(folder)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Readdirnames(1); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// sampleFilter applies the filter of the job to up to doctorSampleSize files of its folder, returning how
+// many of them would be uploaded. It returns an error if none would be.
+func sampleFilter(job config.FolderUploadJob, unicodeForm pathnorm.Form) (string, error) {
+	f, err := jobFilter(job, unicodeForm)
+	if err != nil {
+		return "", err
+	}
+
+	// hidden files are not part of the sample, they are skipped when uploading.
+	folder := upload.UploadFolderJob{SourceFolder: job.SourceFolder, IncludeHidden: job.IncludeHidden}
+	var sampled, allowed int
+	err = filepath.Walk(job.SourceFolder, func(fp string, fi os.FileInfo, err error) error {
+		if err != nil {
+			// unreadable subfolders are not part of the sample.
+			return nil
+		}
+		path := upload.RelativePath(job.SourceFolder, fp)
+		if fp != job.SourceFolder && folder.IsHidden(fp) {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if fi.IsDir() {
+			if fp != job.SourceFolder && !f.IsAllowedDir(path) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		sampled++
+		if f.IsAllowed(path) {
+			allowed++
+		}
+		if sampled == doctorSampleSize {
+			return errSampleComplete
+		}
+		return nil
+	})
+	if err != nil && err != errSampleComplete {
+		return "", err
+	}
+	if sampled > 0 && allowed == 0 {
+		return "", fmt.Errorf("none of %d sampled files would be uploaded, check IncludePatterns and ExcludePatterns", sampled)
+	}
+	return fmt.Sprintf("%d of %d sampled files would be uploaded", allowed, sampled), nil
+}
@@ -0,0 +1,87 @@
+package cmd_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/app"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/cmd"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/cmd/flags"
+)
+
+const testTokenEnvVar = "GPHOTOS_DOCTOR_TEST_TOKEN"
+
+func TestNewDoctorCmd(t *testing.T) {
+	testCases := []struct {
+		name          string
+		sourceFolder  string
+		token         string
+		apiStatus     int
+		isErrExpected bool
+	}{
+		{"Should success", "photos", `{"access_token":"access-token","token_type":"Bearer","expiry":"2999-01-01T00:00:00Z"}`, http.StatusOK, false},
+		{"Should fail if configuration is invalid", "non-existent", `{"access_token":"access-token","token_type":"Bearer","expiry":"2999-01-01T00:00:00Z"}`, http.StatusOK, true},
+		{"Should fail if account is not authorized", "photos", "", http.StatusOK, true},
+		{"Should fail if Google Photos refuses the requests", "photos", `{"access_token":"access-token","token_type":"Bearer","expiry":"2999-01-01T00:00:00Z"}`, http.StatusUnauthorized, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "doctor")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.apiStatus)
+			}))
+			defer srv.Close()
+			createTestEnvironment(t, dir, filepath.Join(dir, tc.sourceFolder), srv.URL)
+
+			if err := os.Setenv(testTokenEnvVar, tc.token); err != nil {
+				t.Fatal(err)
+			}
+			defer os.Unsetenv(testTokenEnvVar)
+
+			c := cmd.NewDoctorCmd(&flags.GlobalFlags{CfgDir: filepath.Join(dir, "config")})
+			c.SetArgs([]string{})
+
+			err = c.Execute()
+			assertExpectedError(t, tc.isErrExpected, err)
+		})
+	}
+}
+
+// createTestEnvironment creates the configuration in the config folder of dir, with a job uploading sourceFolder
+// to the Google Photos API at apiBaseURL, and the photos folder with a photo.
+func createTestEnvironment(t *testing.T, dir string, sourceFolder string, apiBaseURL string) {
+	t.Helper()
+	for _, d := range []string{"config", "photos"} {
+		if err := os.MkdirAll(filepath.Join(dir, d), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "photos", "IMG_0001.jpg"), []byte("photo"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfgContent := fmt.Sprintf(`{
+  ConfigVersion: 2
+  APIAppCredentials: { ClientID: "client-id", ClientSecret: "client-secret" }
+  Account: "youremail@domain.com"
+  SecretsBackendType: "auto"
+  TokenStore: "env"
+  TokenStoreEnvVar: %q
+  PhotosAPIBaseURL: %q
+  Jobs: [
+    { SourceFolder: %q, CreateAlbums: "Off", IncludePatterns: ["_ALL_FILES_"] }
+  ]
+}`, testTokenEnvVar, apiBaseURL, sourceFolder)
+	if err := ioutil.WriteFile(filepath.Join(dir, "config", app.DefaultConfigFilename), []byte(cfgContent), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/app"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/progress"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/upload"
+)
+
+// uploadEstimate is the number of files to be uploaded by the run, and the bytes to be transferred.
+type uploadEstimate struct {
+	Files int
+	Bytes int64
+}
+
+// estimate scans the jobs like the run does, counting the files that would be enqueued without uploading them.
+// The files are selected by the same filters, failed uploads and limit, and duplicates are counted once, like
+// the run skips them, see runDedups. Files already in the library are counted, since checking them requires calling the API.
+func (cmd *PushCmd) estimate(ctx context.Context, cli *app.App, limits upload.Limits, minFileAge time.Duration, dateRange upload.DateRange) (uploadEstimate, error) {
+	// the state of the run is not shared, so the files are not considered enqueued by the run, and nothing is recorded.
+	retry := newRetries(cli.RetryQueue, log.Discard, true)
+	limit := newUploadLimit(cmd.Limit)
+	dedups := newRunDedups(cli.Config, cli.ContentHashes)
+	var dedup *upload.RunDedup
+
+	var e uploadEstimate
+	count := func(item upload.FileItem) {
+		if !retry.claim(item.Path) || isDuplicate(dedup, item.Path, log.Discard) || !limit.reserve() {
+			return
+		}
+		e.Files++
+		// files only added to the album don't transfer their content.
+		if item.MediaItemID == "" {
+			e.Bytes += item.Size()
+		}
+	}
+
+	for _, config := range cli.Config.Jobs {
+		config = cmd.withFlags(config)
+		folder, err := newFolderJob(cli, config, limits, minFileAge)
+		if err != nil {
+			return e, err
+		}
+		dedup = dedups.forJob(config)
+		folder.FailOnError = cmd.FailOnError
+		folder.DateRange = dateRange
+		folder.OnlyAlbums = cmd.OnlyAlbums
+		if folder.ChangedFiles, err = cmd.changedFiles(ctx, config.SourceFolder); err != nil {
+			return e, err
+		}
+
+		for _, path := range retry.due(config.SourceFolder) {
+			_, _ = folder.VisitFile(log.Discard, path, count)
+		}
+		if !cmd.FullScan {
+			folder.ChangedSince = cmd.changedSince(cli, config.SourceFolder, jobFingerprint(cli.Config, config), minFileAge)
+		}
+		if _, err := walkFolder(ctx, folder, cli.Config.UploadOrder, log.Discard, count); err != nil {
+			return e, fmt.Errorf("unable to estimate the files of location '%s': %w", config.SourceFolder, err)
+		}
+	}
+	return e, nil
+}
+
+// confirmEstimate writes the estimate of the run, and returns if the upload should proceed. It asks for
+// confirmation, unless `--yes` or `--dry-run` is set. Anything but "y" or "yes" cancels it, also when the
+// standard input is not interactive.
+func (cmd *PushCmd) confirmEstimate(cobraCmd *cobra.Command, ctx context.Context, cli *app.App, limits upload.Limits, minFileAge time.Duration, dateRange upload.DateRange) (bool, error) {
+	cli.Logger.Infof("Estimating the files to be uploaded...")
+	e, err := cmd.estimate(ctx, cli, limits, minFileAge, dateRange)
+	if err != nil {
+		return false, err
+	}
+	out := cobraCmd.OutOrStdout()
+	_, _ = fmt.Fprintf(out, "This will upload %d files, %s.\n", e.Files, progress.FormatBytes(e.Bytes))
+	if cmd.Yes || cmd.DryRun || e.Files == 0 {
+		return true, nil
+	}
+
+	_, _ = fmt.Fprint(out, "Proceed? [y/N] ")
+	answer, _ := bufio.NewReader(cobraCmd.InOrStdin()).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
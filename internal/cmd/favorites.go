@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/upload"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/worker"
+)
+
+// withFavorite returns the job of the file which, if it's in the favorites folder, records it in the run
+// summary once it has been uploaded.
+func withFavorite(job worker.Job, item upload.FileItem, run *runSummary) worker.Job {
+	if !item.Favorite {
+		return job
+	}
+	return &favoriteJob{Job: job, path: item.Path, run: run}
+}
+
+// favoriteJob is the job of a file that should be marked as favorite.
+type favoriteJob struct {
+	worker.Job
+	path string
+	run  *runSummary
+}
+
+func (j *favoriteJob) Process() error {
+	err := j.Job.Process()
+	if err == nil {
+		j.run.addFavorite(j.path)
+	}
+	return err
+}
+
+// reportFavorites warns that the uploaded favorites have not been marked as favorites, since the Google Photos
+// API doesn't allow to do it, instead of silently ignoring them.
+func reportFavorites(favorites []string, logger log.Logger) {
+	if len(favorites) == 0 {
+		return
+	}
+	for _, path := range favorites {
+		logger.Debugf("File '%s' should be marked as favorite.", path)
+	}
+	logger.Warnf("%d uploaded files should be marked as favorites, but the Google Photos API doesn't allow it. They are listed in the run summary, and recorded in the tracking database, to be marked by other means.", len(favorites))
+}
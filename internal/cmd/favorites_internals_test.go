@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/runstats"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/upload"
+)
+
+func TestWithFavorite(t *testing.T) {
+	var uploaded int32
+	run := newRunSummary(runstats.New())
+
+	jobs := []struct {
+		item upload.FileItem
+		err  error
+	}{
+		{upload.FileItem{Path: "/photos/_favorites/IMG_0001.jpg", Favorite: true}, nil},
+		{upload.FileItem{Path: "/photos/_favorites/IMG_0002.jpg", Favorite: true}, errors.New("upload failed")},
+		{upload.FileItem{Path: "/photos/IMG_0003.jpg"}, nil},
+	}
+	for _, j := range jobs {
+		job := withFavorite(&countedJob{id: j.item.Path, uploaded: &uploaded, err: j.err}, j.item, run)
+		_ = job.Process()
+	}
+
+	// failed uploads are not recorded, they are recorded once they are uploaded.
+	if want, got := []string{"/photos/_favorites/IMG_0001.jpg"}, run.Summary().Favorites; !reflect.DeepEqual(want, got) {
+		t.Errorf("want: %v, got: %v", want, got)
+	}
+}
@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/cmd/flags"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/config"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/filter"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/pathnorm"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/upload"
+)
+
+// FilterCmd holds the required data for the filter cmd
+type FilterCmd struct {
+	*flags.GlobalFlags
+
+	// test command flags
+	Limit int
+}
+
+func NewFilterCmd(globalFlags *flags.GlobalFlags) *cobra.Command {
+	cmd := &FilterCmd{GlobalFlags: globalFlags}
+
+	filterCmd := &cobra.Command{
+		Use:   "filter",
+		Short: "Inspect the patterns of the jobs",
+		Long:  `Inspect the include and exclude patterns of the jobs.`,
+		Args:  cobra.NoArgs,
+	}
+
+	testCmd := &cobra.Command{
+		Use:   "test <dir>",
+		Short: "Report which patterns decide the files of a folder",
+		Long: `Report, for every file of the folder, if it's allowed or excluded by the patterns of the jobs whose source folder
+contains it, and the pattern that decided it. Then, the number of files decided by every include and exclude
+pattern, so patterns too broad or never used are found. Only the patterns are tested, not the other filters
+of the jobs, and every file is tested, even the ones in folders the scan would skip. Use --limit to test a
+sample of the files.`,
+		Args: cobra.ExactArgs(1),
+		RunE: cmd.Test,
+	}
+	testCmd.Flags().IntVar(&cmd.Limit, "limit", 0, "Maximum number of files to be tested, in the order they are found. 0 means all of them")
+	filterCmd.AddCommand(testCmd)
+
+	return filterCmd
+}
+
+func (cmd *FilterCmd) Test(cobraCmd *cobra.Command, args []string) error {
+	if cmd.Limit < 0 {
+		return fmt.Errorf("invalid limit: %d", cmd.Limit)
+	}
+	cfg, err := config.FromFiles(Os, configFiles(cmd.GlobalFlags), config.ListMerge(cmd.CfgLists))
+	if err != nil {
+		return err
+	}
+	dir, err := filepath.Abs(args[0])
+	if err != nil {
+		return err
+	}
+
+	tested := false
+	for _, job := range cfg.Jobs {
+		if !isInFolder(dir, job.SourceFolder) {
+			continue
+		}
+		filterFiles, err := jobFilter(job, pathnorm.Form(cfg.UnicodeNormalization))
+		if err != nil {
+			return err
+		}
+		result, err := testFilter(filterFiles, job.SourceFolder, dir, cmd.Limit)
+		if err != nil {
+			return err
+		}
+		if err := result.write(cobraCmd.OutOrStdout(), job.SourceFolder); err != nil {
+			return err
+		}
+		tested = true
+	}
+	if !tested {
+		return fmt.Errorf("folder '%s' is not in the source folder of any job", dir)
+	}
+	return nil
+}
+
+// isInFolder returns true if path is folder, or it's inside it.
+func isInFolder(path string, folder string) bool {
+	rel, err := filepath.Rel(folder, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// filterDecision is the result of the filter for a file, by its path relative to the source folder.
+type filterDecision struct {
+	path   string
+	result filter.FilterResult
+}
+
+// filterTestResult is the result of the filter of a job for the files of a folder, see testFilter.
+type filterTestResult struct {
+	decisions []filterDecision
+	// allowed and excluded are the patterns of the filter, and allowedFiles and excludedFiles the number of files
+	// decided by every one of them.
+	allowed       []string
+	excluded      []string
+	allowedFiles  []int
+	excludedFiles []int
+}
+
+// errFilterLimit stops the walk of testFilter once the limit of files is reached.
+var errFilterLimit = errors.New("limit of files reached")
+
+// testFilter returns the decisions of f for the files of dir, at most limit of them if it's greater than 0.
+// Their paths are relative to sourceFolder, like the scan of the job matches them.
+func testFilter(f *filter.Filter, sourceFolder string, dir string, limit int) (filterTestResult, error) {
+	r := filterTestResult{}
+	r.allowed, r.excluded = f.Patterns()
+	r.allowedFiles = make([]int, len(r.allowed))
+	r.excludedFiles = make([]int, len(r.excluded))
+
+	err := filepath.WalkDir(dir, func(fp string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if limit > 0 && len(r.decisions) == limit {
+			return errFilterLimit
+		}
+		relativePath := upload.RelativePath(sourceFolder, fp)
+		result := f.Explain(relativePath)
+		switch {
+		case result.Excluded:
+			r.excludedFiles[result.Index]++
+		case result.Allowed && result.Index >= 0:
+			r.allowedFiles[result.Index]++
+		}
+		r.decisions = append(r.decisions, filterDecision{path: relativePath, result: result})
+		return nil
+	})
+	if errors.Is(err, errFilterLimit) {
+		err = nil
+	}
+	return r, err
+}
+
+// write writes the decision of every file, the totals, and the number of files decided by every pattern.
+func (r filterTestResult) write(w io.Writer, sourceFolder string) error {
+	allowed, excluded := 0, 0
+	for _, d := range r.decisions {
+		if d.result.Allowed {
+			allowed++
+		} else if d.result.Excluded {
+			excluded++
+		}
+	}
+	lines := []string{fmt.Sprintf("Job '%s':", sourceFolder)}
+	for _, d := range r.decisions {
+		lines = append(lines, fmt.Sprintf("  %s: %s", d.path, d.result))
+	}
+	lines = append(lines, fmt.Sprintf("%d files: %d allowed, %d excluded, %d not allowed", len(r.decisions), allowed, excluded, len(r.decisions)-allowed-excluded))
+	if _, err := fmt.Fprintln(w, strings.Join(lines, "\n")); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "KIND\t#\tFILES\tPATTERN")
+	for i, pattern := range r.allowed {
+		_, _ = fmt.Fprintf(tw, "include\t%d\t%d\t%s\n", i, r.allowedFiles[i], pattern)
+	}
+	for i, pattern := range r.excluded {
+		_, _ = fmt.Fprintf(tw, "exclude\t%d\t%d\t%s\n", i, r.excludedFiles[i], pattern)
+	}
+	return tw.Flush()
+}
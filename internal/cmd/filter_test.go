@@ -0,0 +1,112 @@
+package cmd_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/app"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/cmd"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/cmd/flags"
+)
+
+// createFilterTestEnvironment creates the config of a job with include and exclude patterns, and a tree of files.
+func createFilterTestEnvironment(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	src := filepath.Join(dir, "photos")
+	createTestEnvironment(t, dir, src, "http://localhost")
+	cfgFile := filepath.Join(dir, "config", app.DefaultConfigFilename)
+	b, err := ioutil.ReadFile(cfgFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the pattern of GIF files is never used.
+	cfg := strings.Replace(string(b), `IncludePatterns: ["_ALL_FILES_"]`, `IncludePatterns: ["**/*.jpg", "**/*.png"], ExcludePatterns: ["tmp/**", "**/*_thumb.jpg", "**/*.gif"]`, 1)
+	if err := ioutil.WriteFile(cfgFile, []byte(cfg), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"IMG_0002_thumb.jpg", "notes.txt", "2023/IMG_0003.png", "2023/IMG_0004.jpg", "tmp/IMG_0005.jpg"} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(src, name)), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(src, name), []byte("photo"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestFilterCmd_Test(t *testing.T) {
+	dir := createFilterTestEnvironment(t)
+
+	var out bytes.Buffer
+	c := cmd.NewFilterCmd(&flags.GlobalFlags{CfgDir: filepath.Join(dir, "config")})
+	c.SetOut(&out)
+	c.SetArgs([]string{"test", filepath.Join(dir, "photos")})
+	if err := c.Execute(); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	got := out.String()
+	for _, want := range []string{
+		"  2023/IMG_0003.png: allowed by include pattern '**/*.png' (#1)\n",
+		"  2023/IMG_0004.jpg: allowed by include pattern '**/*.jpg' (#0)\n",
+		"  IMG_0001.jpg: allowed by include pattern '**/*.jpg' (#0)\n",
+		"  IMG_0002_thumb.jpg: excluded by exclude pattern '**/*_thumb.jpg' (#1)\n",
+		"  notes.txt: not allowed, no include pattern matches\n",
+		"  tmp/IMG_0005.jpg: excluded by exclude pattern 'tmp/**' (#0)\n",
+		"6 files: 3 allowed, 2 excluded, 1 not allowed\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("want: %q, got: %s", want, got)
+		}
+	}
+	// the files decided by every pattern, the GIF one is never used.
+	for _, want := range [][]string{
+		{"include", "0", "2", "**/*.jpg"},
+		{"include", "1", "1", "**/*.png"},
+		{"exclude", "0", "1", "tmp/**"},
+		{"exclude", "1", "1", "**/*_thumb.jpg"},
+		{"exclude", "2", "0", "**/*.gif"},
+	} {
+		if !containsFields(got, want) {
+			t.Errorf("want: %v, got: %s", want, got)
+		}
+	}
+}
+
+func TestFilterCmd_TestLimit(t *testing.T) {
+	dir := createFilterTestEnvironment(t)
+
+	var out bytes.Buffer
+	c := cmd.NewFilterCmd(&flags.GlobalFlags{CfgDir: filepath.Join(dir, "config")})
+	c.SetOut(&out)
+	c.SetArgs([]string{"test", "--limit", "2", filepath.Join(dir, "photos", "2023")})
+	if err := c.Execute(); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if want := "2 files: 2 allowed, 0 excluded, 0 not allowed\n"; !strings.Contains(out.String(), want) {
+		t.Errorf("want: %q, got: %s", want, out.String())
+	}
+
+	c = cmd.NewFilterCmd(&flags.GlobalFlags{CfgDir: filepath.Join(dir, "config")})
+	c.SetOut(&out)
+	c.SetArgs([]string{"test", "--limit", "1", dir})
+	if err := c.Execute(); err == nil {
+		t.Errorf("error was expected, but not produced")
+	}
+}
+
+// containsFields returns true if a line of s has exactly the fields, separated by spaces.
+func containsFields(s string, fields []string) bool {
+	for _, line := range strings.Split(s, "\n") {
+		if strings.Join(strings.Fields(line), " ") == strings.Join(fields, " ") {
+			return true
+		}
+	}
+	return false
+}
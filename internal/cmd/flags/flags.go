@@ -1,6 +1,12 @@
 package flags
 
 import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
 	"github.com/mitchellh/go-homedir"
 	flag "github.com/spf13/pflag"
 )
@@ -15,6 +21,32 @@ type GlobalFlags struct {
 	Silent bool
 	Debug  bool
 	CfgDir string
+	DryRun bool
+
+	// CfgFiles, if it isn't empty, are the configuration files to merge, in order, instead of the
+	// configuration file in CfgDir.
+	CfgFiles []string
+	// CfgLists is the way lists are merged when there are several CfgFiles, replace or append.
+	CfgLists string
+	// CfgStdin, if it's true, reads the configuration from Stdin, instead of any configuration file.
+	CfgStdin bool
+
+	// TokenStdin, if it's true, reads the OAuth token, encoded as JSON, from Stdin, instead of the configured
+	// token store. It's kept in memory only, so refreshed tokens are not stored. If CfgStdin is set too, the
+	// token goes first, followed by the configuration.
+	TokenStdin bool
+	// Stdin is read for CfgStdin and TokenStdin. It's os.Stdin by default.
+	Stdin io.Reader
+
+	// LogFormat is the format of the log output, text or json.
+	LogFormat string
+	// LogLevel is the minimum level of the logged entries: error, warn, info or debug.
+	LogLevel string
+	// DebugModules are the modules whose debug entries are logged whatever LogLevel is, e.g. filter or scan.
+	DebugModules []string
+
+	// RecoverTracker, if it's true, moves the tracking store aside if it's corrupted, starting with an empty one.
+	RecoverTracker bool
 }
 
 // SetGlobalFlags applies the global flags
@@ -24,7 +56,22 @@ func SetGlobalFlags(flags *flag.FlagSet) *GlobalFlags {
 	flags.BoolVar(&globalFlags.Debug, "debug", false, "Logs very verbose information. Useful for troubleshooting.")
 	flags.BoolVar(&globalFlags.Silent, "silent", false, "Run in silent mode and prevents any log output except panics & fatals.")
 
-	flags.StringVar(&globalFlags.CfgDir, "config", defaultApplicationDataPath(), "Sets config folder path. All configuration will be keep in this folder.")
+	flags.StringVar(&globalFlags.LogFormat, "log-format", "text", "Log output format: text or json. Use json to emit one JSON object per event.")
+	flags.StringVar(&globalFlags.LogLevel, "log-level", "", "Minimum level of the logged entries: error, warn, info or debug. It's info by default.")
+	flags.StringSliceVar(&globalFlags.DebugModules, "debug-modules", nil, "Logs the debug entries of the given modules only, e.g. filter,scan. Modules are filter, scan and upload.")
+
+	flags.BoolVar(&globalFlags.DryRun, "dry-run", false, "Shows what would be done, without uploading files nor changing the local tracking data.")
+	flags.BoolVar(&globalFlags.RecoverTracker, "recover-tracker", false, "Moves the tracking data aside if it's corrupted, starting with an empty one. Tracked files would be uploaded again.")
+
+	globalFlags.CfgDir = defaultApplicationDataPath()
+	flags.Var(&configValue{flags: globalFlags}, "config", "Sets config folder path. All configuration will be keep in this folder. "+
+		"It could be repeated with configuration files instead, e.g. --config base.hjson --config local.hjson, to merge them in order: "+
+		"later files override the values of earlier ones, and objects are merged recursively. "+
+		"Repeated values, and values ending in .hjson or .json, must be existing files. "+
+		"Use --config - to read the configuration from the standard input.")
+	flags.BoolVar(&globalFlags.TokenStdin, "token-stdin", false, "Reads the OAuth token, encoded as JSON, from the standard input instead of the token store, without keeping it on disk. "+
+		"Use it before the configuration when using --config - too.")
+	flags.StringVar(&globalFlags.CfgLists, "config-lists", "replace", "How lists, like Jobs, are merged when using several configuration files: replace or append.")
 
 	return globalFlags
 }
@@ -37,3 +84,69 @@ func defaultApplicationDataPath() string {
 	}
 	return absPath
 }
+
+// configFileExtensions are the extensions of the configuration files, a `--config` value with any
+// of them is never taken as the config folder path.
+var configFileExtensions = []string{".hjson", ".json"}
+
+// configValue is the value of the `--config` flag. It could be repeated, every value is a configuration
+// file if it's an existing file, the standard input if it's "-", or the config folder path otherwise.
+// When it's repeated, every value but "-" must be an existing file.
+type configValue struct {
+	flags *GlobalFlags
+	// count is the number of values set, but "-".
+	count int
+	// dirSet is true if a value was taken as the config folder path.
+	dirSet bool
+}
+
+func (v *configValue) Set(value string) error {
+	if value == "-" {
+		v.flags.CfgStdin = true
+		return nil
+	}
+	v.count++
+	if v.dirSet {
+		return fmt.Errorf("--config is repeated, every value must be a configuration file, but '%s' is not", v.flags.CfgDir)
+	}
+	if fi, err := os.Stat(value); err == nil && fi.Mode().IsRegular() {
+		v.flags.CfgFiles = append(v.flags.CfgFiles, value)
+		return nil
+	}
+	switch {
+	case v.count > 1:
+		return fmt.Errorf("--config is repeated, every value must be a configuration file, but '%s' is not", value)
+	case hasConfigFileExtension(value):
+		return fmt.Errorf("configuration file '%s' does not exist", value)
+	}
+	v.flags.CfgDir = value
+	v.dirSet = true
+	return nil
+}
+
+// hasConfigFileExtension returns true if path has any of the configFileExtensions.
+func hasConfigFileExtension(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, e := range configFileExtensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *configValue) String() string {
+	return v.flags.CfgDir
+}
+
+func (v *configValue) Type() string {
+	return "string"
+}
+
+// StdinReader returns Stdin, or os.Stdin if it's not set.
+func (f *GlobalFlags) StdinReader() io.Reader {
+	if f.Stdin == nil {
+		return os.Stdin
+	}
+	return f.Stdin
+}
@@ -0,0 +1,70 @@
+package flags_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/cmd/flags"
+)
+
+func TestSetGlobalFlags_Config(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flags")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	base := filepath.Join(dir, "base.hjson")
+	local := filepath.Join(dir, "local.hjson")
+	for _, f := range []string{base, local} {
+		if err := ioutil.WriteFile(f, []byte(`{}`), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	missing := filepath.Join(dir, "missing.hjson")
+
+	testCases := []struct {
+		name          string
+		args          []string
+		wantDir       string
+		wantFiles     int
+		isErrExpected bool
+	}{
+		{"Should set the config folder", []string{"--config", dir}, dir, 0, false},
+		{"Should set a non existing config folder", []string{"--config", filepath.Join(dir, "new")}, filepath.Join(dir, "new"), 0, false},
+		{"Should set a config file", []string{"--config", base}, "", 1, false},
+		{"Should set repeated config files", []string{"--config", base, "--config", local}, "", 2, false},
+		{"Should set config files and stdin", []string{"--config", base, "--config", "-"}, "", 1, false},
+		{"Should fail if a config file does not exist", []string{"--config", missing}, "", 0, true},
+		{"Should fail if a repeated config file does not exist", []string{"--config", base, "--config", missing}, "", 0, true},
+		{"Should fail if a repeated value is a folder", []string{"--config", base, "--config", dir}, "", 0, true},
+		{"Should fail if a folder is repeated with a file", []string{"--config", dir, "--config", base}, "", 0, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.SetOutput(ioutil.Discard)
+			globalFlags := flags.SetGlobalFlags(fs)
+			err := fs.Parse(tc.args)
+			if tc.isErrExpected {
+				if err == nil {
+					t.Fatalf("error was expected, but not produced")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			if tc.wantDir != "" && globalFlags.CfgDir != tc.wantDir {
+				t.Errorf("want: %s, got: %s", tc.wantDir, globalFlags.CfgDir)
+			}
+			if len(globalFlags.CfgFiles) != tc.wantFiles {
+				t.Errorf("want: %d config files, got: %v", tc.wantFiles, globalFlags.CfgFiles)
+			}
+		})
+	}
+}
@@ -1,13 +1,22 @@
 package cmd
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/mgutz/ansi"
+	"github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
 
 	"github.com/gphotosuploader/gphotos-uploader-cli/internal/app"
 	"github.com/gphotosuploader/gphotos-uploader-cli/internal/cmd/flags"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/config"
 	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
 )
 
@@ -16,7 +25,15 @@ type InitCmd struct {
 	*flags.GlobalFlags
 
 	// command flags
-	Force bool
+	Force        bool
+	Defaults     bool
+	ClientID     string
+	ClientSecret string
+	Account      string
+	SourceFolder string
+	CreateAlbums string
+	TokenStore   string
+	NoAuth       bool
 }
 
 func NewInitCmd(globalFlags *flags.GlobalFlags) *cobra.Command {
@@ -25,12 +42,25 @@ func NewInitCmd(globalFlags *flags.GlobalFlags) *cobra.Command {
 	initCmd := &cobra.Command{
 		Use:   "init",
 		Short: "Initializes the configuration",
-		Long:  `Initializes a new configuration with defaults.`,
-		Args:  cobra.NoArgs,
-		RunE:  cmd.Run,
+		Long: `Initializes a new configuration, asking for the Google Photos API credentials, the account, the folder to
+upload and how albums are created. Every answer is validated before the configuration is written. Then, it
+authenticates the account, asking for the authorization code of the OAuth consent page, and stores the token.
+
+Answers could be given using flags instead, e.g. to script it. Only the ones not given are asked.
+Use --defaults to write a configuration with placeholders to be edited instead.`,
+		Args: cobra.NoArgs,
+		RunE: cmd.Run,
 	}
 
 	initCmd.Flags().BoolVar(&cmd.Force, "force", false, "Overwrite existing configuration")
+	initCmd.Flags().BoolVar(&cmd.Defaults, "defaults", false, "Write a configuration with default settings and placeholders to be edited, without asking")
+	initCmd.Flags().StringVar(&cmd.ClientID, "client-id", "", "Client ID of the Google Photos API credentials")
+	initCmd.Flags().StringVar(&cmd.ClientSecret, "client-secret", "", "Client secret of the Google Photos API credentials")
+	initCmd.Flags().StringVar(&cmd.Account, "account", "", "Google Photos account to upload the files to")
+	initCmd.Flags().StringVar(&cmd.SourceFolder, "source-folder", "", "Folder to upload")
+	initCmd.Flags().StringVar(&cmd.CreateAlbums, "create-albums", "", "How albums are created: Off, folderPath, folderName, exifDate or mediaType")
+	initCmd.Flags().StringVar(&cmd.TokenStore, "token-store", "", "Where the OAuth token is kept: keyring or file")
+	initCmd.Flags().BoolVar(&cmd.NoAuth, "no-auth", false, "Don't authenticate the account, run the auth command afterwards")
 
 	return initCmd
 }
@@ -48,16 +78,178 @@ func (cmd *InitCmd) Run(cobraCmd *cobra.Command, args []string) error {
 		return fmt.Errorf("application data already exists at %s", cmd.CfgDir)
 	}
 
-	filename, err := cli.CreateAppDataDir()
+	if cmd.Defaults {
+		filename, err := cli.CreateAppDataDir()
+		if err != nil {
+			log.Failf("Unable to create application data dir, err: %s", err)
+			return err
+		}
+
+		log.Done("Application data dir created successfully.")
+		log.Infof("\r         \nPlease edit: \n- `%s` to add your configuration.\n",
+			ansi.Color(filename, "cyan+b"),
+		)
+		return nil
+	}
+
+	in := newInitPrompter(cmd.StdinReader(), cobraCmd.OutOrStdout())
+	cfg, err := cmd.askConfig(in)
+	if err != nil {
+		return err
+	}
+
+	filename, err := cli.CreateAppDataDirWithConfig(cfg)
 	if err != nil {
 		log.Failf("Unable to create application data dir, err: %s", err)
 		return err
 	}
+	log.Donef("Configuration has been written to '%s'.", filename)
 
-	log.Done("Application data dir created successfully.")
-	log.Infof("\r         \nPlease edit: \n- `%s` to add your configuration.\n",
-		ansi.Color(filename, "cyan+b"),
-	)
+	if cmd.NoAuth {
+		log.Infof("Run `%s` to authenticate the account '%s'.", ansi.Color("auth", "white+b"), cfg.Account)
+		return nil
+	}
 
+	// the authorization code is the next answer of the input.
+	authCli, err := app.StartWithNewAuth(context.Background(), cmd.CfgDir, app.WithAuthCodeReader(in.r))
+	if err != nil {
+		log.Failf("Unable to authenticate the account '%s', run `%s` to try again, err: %s", cfg.Account, ansi.Color("auth", "white+b"), err)
+		return err
+	}
+	defer func() {
+		_ = authCli.Stop()
+	}()
+	log.Donef("Successful authentication for account '%s', the configuration is ready. Run `%s` to upload the files.", cfg.Account, ansi.Color("push", "white+b"))
 	return nil
 }
+
+// askConfig returns the configuration with the answers of the flags, or the ones read from in otherwise.
+// Every answer is validated when it's given, invalid answers are asked again.
+func (cmd *InitCmd) askConfig(in *initPrompter) (config.Config, error) {
+	cfg := config.Default()
+	cfg.APIAppCredentials = config.APIAppCredentials{}
+	cfg.Account = ""
+	job := &cfg.Jobs[0]
+	job.SourceFolder = ""
+
+	// answers are validated like the configuration is, see config.Diagnose.
+	problem := func(field string) func() error {
+		return func() error {
+			for _, p := range cfg.Diagnose(Os) {
+				if p.Field == field {
+					return p.Err
+				}
+			}
+			return nil
+		}
+	}
+
+	questions := []struct {
+		question string
+		value    string
+		def      string
+		set      func(string)
+		validate func() error
+	}{
+		{"Client ID of the Google Photos API credentials", cmd.ClientID, "", func(s string) { cfg.APIAppCredentials.ClientID = s }, func() error {
+			if cfg.APIAppCredentials.ClientID == "" {
+				return errors.New("client ID could not be empty")
+			}
+			return nil
+		}},
+		{"Client secret of the Google Photos API credentials", cmd.ClientSecret, "", func(s string) { cfg.APIAppCredentials.ClientSecret = s }, problem("APIAppCredentials")},
+		{"Google Photos account", cmd.Account, "", func(s string) { cfg.Account = s }, problem("Account")},
+		{"Folder to upload", cmd.SourceFolder, "", func(s string) { job.SourceFolder = absolutePath(s) }, problem("Jobs[0].SourceFolder")},
+		{"How albums are created (Off, folderPath, folderName, exifDate, mediaType)", cmd.CreateAlbums, job.CreateAlbums, func(s string) { job.CreateAlbums = s }, problem("Jobs[0].CreateAlbums")},
+		{"Where the OAuth token is kept (keyring, file)", cmd.TokenStore, "keyring", func(s string) { cfg.TokenStore = s }, func() error {
+			switch cfg.TokenStore {
+			case "keyring":
+				return nil
+			case "file":
+				if os.Getenv(app.TokenStoreKeyEnvVar) == "" {
+					return fmt.Errorf("token store 'file' requires the passphrase in the %s environment variable", app.TokenStoreKeyEnvVar)
+				}
+				return nil
+			}
+			return fmt.Errorf("token store '%s' is invalid, it should be keyring or file", cfg.TokenStore)
+		}},
+	}
+	for _, q := range questions {
+		if err := in.ask(q.question, q.value, q.def, q.set, q.validate); err != nil {
+			return config.Config{}, err
+		}
+	}
+	// the default token store is keyring.
+	if cfg.TokenStore == "keyring" {
+		cfg.TokenStore = ""
+	}
+	return cfg, nil
+}
+
+// absolutePath returns the absolute path of path, expanding the home folder, or path if it could not be expanded.
+func absolutePath(path string) string {
+	if path == "" {
+		return path
+	}
+	expanded, err := homedir.Expand(path)
+	if err != nil {
+		return path
+	}
+	if abs, err := filepath.Abs(expanded); err == nil {
+		return abs
+	}
+	return expanded
+}
+
+// initPrompter asks the questions of the init cmd, reading the answers line by line.
+type initPrompter struct {
+	// r is buffered, so the rest of the input after the answers, like the authorization code, should be read from it.
+	r   *bufio.Reader
+	out io.Writer
+}
+
+func newInitPrompter(r io.Reader, out io.Writer) *initPrompter {
+	return &initPrompter{r: bufio.NewReader(r), out: out}
+}
+
+// ask sets the answer of the question using set, asking again while validate fails. If value is set, it's the
+// answer instead, without asking, and it fails if it's not valid. Empty answers are def, if it's set.
+func (p *initPrompter) ask(question string, value string, def string, set func(string), validate func() error) error {
+	if value != "" {
+		set(value)
+		if err := validate(); err != nil {
+			return fmt.Errorf("invalid answer for '%s': %s", question, err)
+		}
+		return nil
+	}
+	for {
+		answer, err := p.readAnswer(question, def)
+		if err != nil {
+			return err
+		}
+		set(answer)
+		err = validate()
+		if err == nil {
+			return nil
+		}
+		fmt.Fprintf(p.out, "Invalid answer: %s\n", err)
+	}
+}
+
+// readAnswer prints the question, and returns the next line of the input, or def if the line is empty.
+func (p *initPrompter) readAnswer(question string, def string) (string, error) {
+	if def != "" {
+		fmt.Fprintf(p.out, "%s [%s]: ", question, def)
+	} else {
+		fmt.Fprintf(p.out, "%s: ", question)
+	}
+	line, err := p.r.ReadString('\n')
+	if err != nil && (!errors.Is(err, io.EOF) || line == "") {
+		return "", fmt.Errorf("unable to read the answer for '%s': %s", question, err)
+	}
+	answer := strings.TrimSpace(line)
+	if answer == "" {
+		return def, nil
+	}
+	return answer, nil
+}
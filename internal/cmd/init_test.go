@@ -1,14 +1,21 @@
 package cmd_test
 
 import (
+	"io/ioutil"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/spf13/afero"
+	"golang.org/x/oauth2"
 
 	"github.com/gphotosuploader/gphotos-uploader-cli/internal/app"
 	"github.com/gphotosuploader/gphotos-uploader-cli/internal/cmd"
 	"github.com/gphotosuploader/gphotos-uploader-cli/internal/cmd/flags"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/config"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/datastore/tokenmanager"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/photosapi/photosapitest"
 )
 
 func TestNewInitCmd(t *testing.T) {
@@ -18,9 +25,9 @@ func TestNewInitCmd(t *testing.T) {
 		args          []string
 		isErrExpected bool
 	}{
-		{"Should success", "", []string{}, false},
-		{"Should fail if input exists", "/foo", []string{}, true},
-		{"Should success if input exists and force is set", "/foo", []string{"--force"}, false},
+		{"Should success", "", []string{"--defaults"}, false},
+		{"Should fail if input exists", "/foo", []string{"--defaults"}, true},
+		{"Should success if input exists and force is set", "/foo", []string{"--defaults", "--force"}, false},
 	}
 
 	t.Cleanup(func() {
@@ -41,6 +48,139 @@ func TestNewInitCmd(t *testing.T) {
 	}
 }
 
+func TestNewInitCmd_Wizard(t *testing.T) {
+	api := photosapitest.NewServer()
+	defer api.Close()
+	defaultEndpoint := app.GoogleAuthEndpoint
+	app.GoogleAuthEndpoint = oauth2.Endpoint{TokenURL: api.URL + "/token", AuthStyle: oauth2.AuthStyleInParams}
+	defer func() {
+		app.GoogleAuthEndpoint = defaultEndpoint
+	}()
+	if err := os.Setenv(app.TokenStoreKeyEnvVar, "passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv(app.TokenStoreKeyEnvVar)
+
+	testCases := []struct {
+		name             string
+		args             []string
+		stdin            string
+		wantCreateAlbums string
+		wantTokenStore   string
+		wantToken        bool
+	}{
+		{"Should write the answers and store the token", nil, "client-id\nclient-secret\nyouremail@domain.com\n{photos}\n\nfile\nauth-code\n", "folderName", "file", true},
+		{"Should ask again invalid answers", nil, "\nclient-id\nclient-secret\n\nyouremail@domain.com\n{photos}/missing\n{photos}\nalbums\nexifDate\nenv\nfile\nauth-code\n", "exifDate", "file", true},
+		{"Should use the answers of the flags", []string{"--client-id", "client-id", "--client-secret", "client-secret", "--account", "youremail@domain.com", "--source-folder", "{photos}", "--create-albums", "Off", "--token-store", "file"}, "auth-code\n", "Off", "file", true},
+		{"Should ask the answers not given by the flags", []string{"--client-id", "client-id", "--client-secret", "client-secret", "--token-store", "file"}, "youremail@domain.com\n{photos}\nmediaType\nauth-code\n", "mediaType", "file", true},
+		{"Should not authenticate if no-auth is set", []string{"--no-auth"}, "client-id\nclient-secret\nyouremail@domain.com\n{photos}\nfolderPath\n\n", "folderPath", "", false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "init")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			photos := filepath.Join(dir, "photos")
+			if err := os.MkdirAll(photos, 0700); err != nil {
+				t.Fatal(err)
+			}
+			args := make([]string, len(tc.args))
+			for i, arg := range tc.args {
+				args[i] = strings.ReplaceAll(arg, "{photos}", photos)
+			}
+			cfgDir := filepath.Join(dir, "config")
+
+			c := cmd.NewInitCmd(&flags.GlobalFlags{CfgDir: cfgDir, Stdin: strings.NewReader(strings.ReplaceAll(tc.stdin, "{photos}", photos))})
+			c.SetArgs(args)
+			c.SetOut(ioutil.Discard)
+			if err := c.Execute(); err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+
+			cfg, err := config.FromFile(afero.NewOsFs(), filepath.Join(cfgDir, app.DefaultConfigFilename))
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			if want := (config.APIAppCredentials{ClientID: "client-id", ClientSecret: "client-secret"}); want != cfg.APIAppCredentials {
+				t.Errorf("want: %v, got: %v", want, cfg.APIAppCredentials)
+			}
+			if want := "youremail@domain.com"; want != cfg.Account {
+				t.Errorf("want: %v, got: %v", want, cfg.Account)
+			}
+			if len(cfg.Jobs) != 1 || cfg.Jobs[0].SourceFolder != photos || cfg.Jobs[0].CreateAlbums != tc.wantCreateAlbums {
+				t.Errorf("want: a job uploading '%s' with CreateAlbums %s, got: %+v", photos, tc.wantCreateAlbums, cfg.Jobs)
+			}
+			if tc.wantTokenStore != cfg.TokenStore {
+				t.Errorf("want: %v, got: %v", tc.wantTokenStore, cfg.TokenStore)
+			}
+
+			tokens := filepath.Join(cfgDir, "tokens.enc")
+			if !tc.wantToken {
+				if _, err := os.Stat(tokens); !os.IsNotExist(err) {
+					t.Errorf("token store should not exist, err: %v", err)
+				}
+				return
+			}
+			repo, err := tokenmanager.NewFileRepository(tokens, "passphrase")
+			if err != nil {
+				t.Fatal(err)
+			}
+			token, err := repo.Get("youremail@domain.com")
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			if want := "refreshed-token"; want != token.AccessToken {
+				t.Errorf("want: %v, got: %v", want, token.AccessToken)
+			}
+		})
+	}
+}
+
+func TestNewInitCmd_WizardFailure(t *testing.T) {
+	testCases := []struct {
+		name  string
+		args  []string
+		stdin string
+	}{
+		{"Should fail if there are no answers", nil, ""},
+		{"Should fail if the input ends before the last answer", nil, "client-id\nclient-secret\n"},
+		{"Should fail if the answer of a flag is invalid", []string{"--create-albums", "albums"}, "client-id\nclient-secret\nyouremail@domain.com\n{photos}\n"},
+		{"Should fail if the source folder of the flag does not exist", []string{"--source-folder", "{photos}/missing"}, "client-id\nclient-secret\nyouremail@domain.com\n"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "init")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			photos := filepath.Join(dir, "photos")
+			if err := os.MkdirAll(photos, 0700); err != nil {
+				t.Fatal(err)
+			}
+			args := make([]string, len(tc.args))
+			for i, arg := range tc.args {
+				args[i] = strings.ReplaceAll(arg, "{photos}", photos)
+			}
+			cfgDir := filepath.Join(dir, "config")
+
+			c := cmd.NewInitCmd(&flags.GlobalFlags{CfgDir: cfgDir, Stdin: strings.NewReader(strings.ReplaceAll(tc.stdin, "{photos}", photos))})
+			c.SetArgs(args)
+			c.SetOut(ioutil.Discard)
+			if err := c.Execute(); err == nil {
+				t.Errorf("error was expected, but not produced")
+			}
+
+			// the configuration is not written if any answer is not valid.
+			if _, err := os.Stat(filepath.Join(cfgDir, app.DefaultConfigFilename)); !os.IsNotExist(err) {
+				t.Errorf("configuration should not exist, err: %v", err)
+			}
+		})
+	}
+}
+
 func createTestConfigurationFile(t *testing.T, fs afero.Fs, path string) {
 	if path == "" {
 		return
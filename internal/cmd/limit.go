@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"sync"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/worker"
+)
+
+// uploadLimit caps the number of files uploaded in a run. A file is reserved before it's enqueued, so the files
+// being uploaded and the ones uploaded already are never more than the limit, whatever the number of workers.
+// The reservation is released if the upload fails, so another file could be uploaded instead.
+// It's safe for concurrent use.
+type uploadLimit struct {
+	// max is the maximum number of files to be uploaded, there is no limit if it's not greater than 0.
+	max int
+
+	mu       sync.Mutex
+	reserved int
+	// refused is true once a file has not been enqueued because of the limit.
+	refused bool
+}
+
+func newUploadLimit(max int) *uploadLimit {
+	return &uploadLimit{max: max}
+}
+
+// reserve returns true if the file could be enqueued, false if the limit doesn't allow more uploads.
+func (l *uploadLimit) reserve() bool {
+	if l.max <= 0 {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.reserved >= l.max {
+		l.refused = true
+		return false
+	}
+	l.reserved++
+	return true
+}
+
+// release releases the reservation of a file that has not been uploaded.
+func (l *uploadLimit) release() {
+	if l.max <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.reserved--
+}
+
+// hit returns true if any file has not been enqueued because of the limit, so it should be uploaded on the next run.
+func (l *uploadLimit) hit() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.refused
+}
+
+// wrap returns the job of a reserved file, that releases its reservation if it's not processed successfully.
+func (l *uploadLimit) wrap(job worker.Job) worker.Job {
+	if l.max <= 0 {
+		return job
+	}
+	return &limitedJob{Job: job, limit: l}
+}
+
+// limitedJob is the job of a file reserved by an uploadLimit.
+type limitedJob struct {
+	worker.Job
+	limit *uploadLimit
+}
+
+func (j *limitedJob) Process() error {
+	err := j.Job.Process()
+	if err != nil {
+		j.limit.release()
+	}
+	return err
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/worker"
+)
+
+// countedJob is a job that takes some time to be processed, counting the uploads done.
+type countedJob struct {
+	id       string
+	uploaded *int32
+	err      error
+}
+
+func (j *countedJob) Process() error {
+	time.Sleep(5 * time.Millisecond)
+	if j.err != nil {
+		return j.err
+	}
+	atomic.AddInt32(j.uploaded, 1)
+	return nil
+}
+
+func (j *countedJob) ID() string {
+	return j.id
+}
+
+func TestUploadLimit_Workers(t *testing.T) {
+	q := worker.NewJobQueue(4, log.Discard)
+	q.Start()
+	defer q.Stop()
+
+	limit := newUploadLimit(10)
+	var uploaded int32
+	var enqueued int
+	for i := 0; i < 100; i++ {
+		if !limit.reserve() {
+			continue
+		}
+		q.Submit(limit.wrap(&countedJob{id: fmt.Sprintf("file-%d", i), uploaded: &uploaded}))
+		enqueued++
+	}
+	for i := 0; i < enqueued; i++ {
+		<-q.ChanJobResults()
+	}
+
+	if got := atomic.LoadInt32(&uploaded); got != 10 {
+		t.Errorf("want: %d, got: %d", 10, got)
+	}
+	if !limit.hit() {
+		t.Errorf("want: limit hit, got: not hit")
+	}
+}
+
+func TestUploadLimit_ReleasesFailed(t *testing.T) {
+	limit := newUploadLimit(1)
+	var uploaded int32
+
+	if !limit.reserve() {
+		t.Fatalf("want: first file reserved, got: refused")
+	}
+	if err := limit.wrap(&countedJob{uploaded: &uploaded, err: errors.New("failed")}).Process(); err == nil {
+		t.Fatalf("error was expected at this point")
+	}
+	// the failed file doesn't count, so another one could be uploaded instead.
+	if !limit.reserve() {
+		t.Fatalf("want: file reserved after a failure, got: refused")
+	}
+	if err := limit.wrap(&countedJob{uploaded: &uploaded}).Process(); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if limit.reserve() {
+		t.Errorf("want: file refused once the limit is reached, got: reserved")
+	}
+	if got := atomic.LoadInt32(&uploaded); got != 1 {
+		t.Errorf("want: %d, got: %d", 1, got)
+	}
+}
+
+func TestUploadLimit_NoLimit(t *testing.T) {
+	limit := newUploadLimit(0)
+	for i := 0; i < 100; i++ {
+		if !limit.reserve() {
+			t.Fatalf("want: all files reserved, got: file %d refused", i)
+		}
+	}
+	if limit.hit() {
+		t.Errorf("want: limit not hit, got: hit")
+	}
+}
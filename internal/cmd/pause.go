@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/worker"
+)
+
+const (
+	// pauseFilename is the name of the file of ControlDir pausing the uploads while it exists.
+	pauseFilename = "pause"
+
+	// pauseCheckInterval is how often the pause file is checked.
+	pauseCheckInterval = time.Second
+)
+
+// pauseControl pauses and resumes the uploads of a run: new uploads are not started while it's paused, but the
+// uploads in progress finish and files keep being scanned and enqueued. It's paused while the pause file exists,
+// and the pause signal, SIGUSR1 where it's available, toggles it. It's resumed once the run is stopping, so the
+// files enqueued are not waited for forever.
+type pauseControl struct {
+	pause *worker.Pause
+
+	// file is the pause file, it's empty if there is no ControlDir.
+	file     string
+	interval time.Duration
+	signals  chan os.Signal
+	stopping <-chan struct{}
+	logger   log.Logger
+
+	// bySignal and byFile are true if it's paused by the signal or the file, they are only used by handle.
+	bySignal bool
+	byFile   bool
+
+	done     chan struct{}
+	finished chan struct{}
+}
+
+// newPauseControl returns the control of the pause of the run, handling the pause file of controlDir, if it's set,
+// and the pause signal. The file is checked before it returns, so no upload is started if it exists. Call
+// release once the run has finished to restore the default behavior of the signal.
+func newPauseControl(controlDir string, stopping <-chan struct{}, logger log.Logger) *pauseControl {
+	c := newPauseControlWithoutSignals(controlDir, stopping, pauseCheckInterval, logger)
+	if len(pauseSignals) > 0 {
+		signal.Notify(c.signals, pauseSignals...)
+	}
+	c.start()
+	return c
+}
+
+func newPauseControlWithoutSignals(controlDir string, stopping <-chan struct{}, interval time.Duration, logger log.Logger) *pauseControl {
+	c := &pauseControl{
+		pause:    worker.NewPause(),
+		interval: interval,
+		signals:  make(chan os.Signal, 1),
+		stopping: stopping,
+		logger:   logger,
+		done:     make(chan struct{}),
+		finished: make(chan struct{}),
+	}
+	if controlDir != "" {
+		c.file = filepath.Join(controlDir, pauseFilename)
+	}
+	return c
+}
+
+func (c *pauseControl) start() {
+	if c.file != "" {
+		c.checkFile()
+	}
+	go c.handle()
+}
+
+func (c *pauseControl) handle() {
+	defer close(c.finished)
+	var ticks <-chan time.Time
+	if c.file != "" {
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+	for {
+		select {
+		case <-c.signals:
+			c.bySignal = !c.bySignal
+			c.update()
+		case <-ticks:
+			c.checkFile()
+		case <-c.stopping:
+			if c.pause.Resume() {
+				c.logger.Info("Resuming the uploads, the run is stopping.")
+			}
+			return
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// checkFile pauses the uploads if the pause file exists, or resumes them once it's removed.
+func (c *pauseControl) checkFile() {
+	_, err := os.Stat(c.file)
+	c.byFile = err == nil
+	c.update()
+}
+
+// update pauses the uploads if they are paused by the signal or the file, or resumes them otherwise.
+func (c *pauseControl) update() {
+	if !c.bySignal && !c.byFile {
+		if c.pause.Resume() {
+			c.logger.Info("Uploads resumed.")
+		}
+		return
+	}
+	if !c.pause.Pause() {
+		return
+	}
+	if c.byFile {
+		c.logger.Warnf("Uploads paused, the uploads in progress finish. Remove '%s' to resume them.", c.file)
+	} else {
+		c.logger.Warn("Uploads paused, the uploads in progress finish. Send the signal again to resume them.")
+	}
+}
+
+// release stops handling the pause signal and the pause file.
+func (c *pauseControl) release() {
+	signal.Stop(c.signals)
+	close(c.done)
+	<-c.finished
+}
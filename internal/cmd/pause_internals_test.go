@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+)
+
+// waitForPaused waits until the pause of c is paused, or resumed.
+func waitForPaused(t *testing.T, c *pauseControl, paused bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for c.pause.Paused() != paused {
+		if time.Now().After(deadline) {
+			t.Fatalf("want: paused %v, got: %v", paused, !paused)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestPauseControl_File(t *testing.T) {
+	dir := t.TempDir()
+	pauseFile := filepath.Join(dir, pauseFilename)
+	if err := os.WriteFile(pauseFile, nil, 0600); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	c := newPauseControlWithoutSignals(dir, nil, 10*time.Millisecond, log.Discard)
+	c.start()
+	defer c.release()
+
+	// the file is checked before starting, so no upload is started while it exists.
+	if !c.pause.Paused() {
+		t.Fatalf("want: paused by the existing file, got: resumed")
+	}
+
+	if err := os.Remove(pauseFile); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	waitForPaused(t, c, false)
+
+	if err := os.WriteFile(pauseFile, nil, 0600); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	waitForPaused(t, c, true)
+}
+
+func TestPauseControl_Signal(t *testing.T) {
+	dir := t.TempDir()
+	c := newPauseControlWithoutSignals(dir, nil, 10*time.Millisecond, log.Discard)
+	c.start()
+	defer c.release()
+
+	c.signals <- os.Interrupt
+	waitForPaused(t, c, true)
+
+	// it's still paused by the file once the signal toggles it again.
+	if err := os.WriteFile(filepath.Join(dir, pauseFilename), nil, 0600); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	c.signals <- os.Interrupt
+	time.Sleep(50 * time.Millisecond)
+	if !c.pause.Paused() {
+		t.Fatalf("want: paused by the file, got: resumed")
+	}
+
+	if err := os.Remove(filepath.Join(dir, pauseFilename)); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	waitForPaused(t, c, false)
+}
+
+func TestPauseControl_Stopping(t *testing.T) {
+	stopping := make(chan struct{})
+	c := newPauseControlWithoutSignals("", stopping, 10*time.Millisecond, log.Discard)
+	c.start()
+	defer c.release()
+
+	c.signals <- os.Interrupt
+	waitForPaused(t, c, true)
+
+	// the enqueued files are not waited for forever once the run is stopping.
+	close(stopping)
+	waitForPaused(t, c, false)
+}
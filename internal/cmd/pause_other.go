@@ -0,0 +1,12 @@
+//go:build !windows
+// +build !windows
+
+package cmd
+
+import (
+	"os"
+	"syscall"
+)
+
+// pauseSignals are the signals toggling the pause of the uploads.
+var pauseSignals = []os.Signal{syscall.SIGUSR1}
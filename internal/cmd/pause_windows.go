@@ -0,0 +1,7 @@
+package cmd
+
+import "os"
+
+// pauseSignals are the signals toggling the pause of the uploads. There is no SIGUSR1 on Windows, so the uploads
+// are only paused by the pause file of ControlDir.
+var pauseSignals []os.Signal
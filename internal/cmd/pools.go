@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/config"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/worker"
+)
+
+// uploadPools are the pools of workers uploading the files of the jobs. Jobs setting Workers have a pool
+// of their own, the other ones share the default pool. All the pools send the results to the same channel.
+type uploadPools struct {
+	results chan worker.JobResult
+	shared  *worker.JobQueue
+	// byJob are the pools of the jobs, by their position in the configuration.
+	byJob []*worker.JobQueue
+	all   []*worker.JobQueue
+}
+
+// newUploadPools returns the pools of workers of the jobs, the default pool has defaultWorkers workers.
+func newUploadPools(defaultWorkers int, jobs []config.FolderUploadJob, logger log.Logger) *uploadPools {
+	total := defaultWorkers
+	for _, job := range jobs {
+		total += job.Workers
+	}
+	// the results channel must be big enough to fulfill the needs of all the workers.
+	p := &uploadPools{results: make(chan worker.JobResult, total*10)}
+	p.shared = worker.NewJobQueueWithResults(defaultWorkers, p.results, logger)
+	p.all = append(p.all, p.shared)
+	for _, job := range jobs {
+		if job.Workers <= 0 {
+			p.byJob = append(p.byJob, p.shared)
+			continue
+		}
+		q := worker.NewJobQueueWithResults(job.Workers, p.results, logger)
+		p.byJob = append(p.byJob, q)
+		p.all = append(p.all, q)
+	}
+	return p
+}
+
+// forJob returns the pool uploading the files of the job at position i.
+func (p *uploadPools) forJob(i int) *worker.JobQueue {
+	return p.byJob[i]
+}
+
+// SetPause sets the pause of all the pools, it must be called before Start.
+func (p *uploadPools) SetPause(pause *worker.Pause) {
+	for _, q := range p.all {
+		q.SetPause(pause)
+	}
+}
+
+// Start starts the workers of all the pools.
+func (p *uploadPools) Start() {
+	for _, q := range p.all {
+		q.Start()
+	}
+}
+
+// Stop stops the workers of all the pools.
+func (p *uploadPools) Stop() {
+	for _, q := range p.all {
+		q.Stop()
+	}
+}
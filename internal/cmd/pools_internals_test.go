@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/config"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+)
+
+func TestNewUploadPools(t *testing.T) {
+	const cfgContent = `{
+  APIAppCredentials: { ClientID: "client-id", ClientSecret: "client-secret" }
+  Account: "youremail@domain.com"
+  SecretsBackendType: "auto"
+  UploadWorkerCount: 2
+  Jobs: [
+    { SourceFolder: "/ssd", CreateAlbums: "Off", Workers: 8 }
+    { SourceFolder: "/nas", CreateAlbums: "Off", RateLimit: "1MB/s" }
+    { SourceFolder: "/usb", CreateAlbums: "Off", Workers: 1, RateLimit: "0" }
+  ]
+}`
+	fs := afero.NewMemMapFs()
+	for _, dir := range []string{"/ssd", "/nas", "/usb"} {
+		if err := fs.MkdirAll(dir, 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := afero.WriteFile(fs, "/config.hjson", []byte(cfgContent), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.FromFile(fs, "/config.hjson")
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	pools := newUploadPools(cfg.UploadWorkerCount, cfg.Jobs, log.Discard)
+	pools.Start()
+	defer pools.Stop()
+
+	for i, want := range []int{8, 2, 1} {
+		if got := pools.forJob(i).Workers(); got != want {
+			t.Errorf("want: %d, got: %d, job: %s", want, got, cfg.Jobs[i].SourceFolder)
+		}
+	}
+	if pools.forJob(1) != pools.shared {
+		t.Errorf("want: job without Workers using the shared pool, got: a pool of its own")
+	}
+	if len(pools.all) != 3 {
+		t.Errorf("want: 3 pools, got: %d", len(pools.all))
+	}
+}
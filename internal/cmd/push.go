@@ -2,21 +2,70 @@ package cmd
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"sync"
+	"syscall"
 	"time"
 
 	gphotos "github.com/gphotosuploader/google-photos-api-client-go/v2"
-	"github.com/gphotosuploader/google-photos-api-client-go/v2/uploader/resumable"
-	"github.com/schollz/progressbar/v3"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 
 	"github.com/gphotosuploader/gphotos-uploader-cli/internal/app"
 	"github.com/gphotosuploader/gphotos-uploader-cli/internal/cmd/flags"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/config"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/convert"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/datastore/lastrun"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/exif"
 	"github.com/gphotosuploader/gphotos-uploader-cli/internal/filter"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/gitchanges"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/hook"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/library"
 	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/metrics"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/notify"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/pathnorm"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/photosapi"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/progress"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/ratelimit"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/runlock"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/runstats"
 	"github.com/gphotosuploader/gphotos-uploader-cli/internal/task"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/tempdir"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/trash"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/units"
 	"github.com/gphotosuploader/gphotos-uploader-cli/internal/upload"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/watcher"
 	"github.com/gphotosuploader/gphotos-uploader-cli/internal/worker"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/xmp"
+	"github.com/gphotosuploader/gphotos-uploader-cli/pkg/uploader"
+)
+
+const (
+	// progressBarInterval is how often the progress bar is rendered.
+	progressBarInterval = 500 * time.Millisecond
+
+	// progressLogInterval is how often the progress is logged when the output is not a terminal.
+	progressLogInterval = 30 * time.Second
+
+	// stableSizeCheckDelay is the time between the two checks of the size of a file when StableSizeCheck is set.
+	stableSizeCheckDelay = 1 * time.Second
+
+	// clockSkewMargin moves back the start of the last run when scanning files changed since then,
+	// so files whose modification time is set by a clock behind this one, e.g. on a NAS, are not missed.
+	clockSkewMargin = 1 * time.Hour
+
+	// runLockInterval is how often the lock of the config folder is checked when `--wait-lock` is set.
+	runLockInterval = 1 * time.Second
 )
 
 // PushCmd holds the required data for the push cmd
@@ -25,7 +74,35 @@ type PushCmd struct {
 
 	// command flags
 	NumberOfWorkers int
-	DryRunMode      bool
+	ExplainFilter   string
+	RateLimit       string
+	NoProgress      bool
+	Watch           bool
+	WatchInterval   time.Duration
+	WatchPoll       bool
+	MetricsAddr     string
+
+	RetryDeadLetters bool
+	ShutdownTimeout  time.Duration
+	Timeout          time.Duration
+	PerFileTimeout   time.Duration
+	MaxDuration      time.Duration
+	FullScan         bool
+	ChangedSinceRef  string
+	Since            string
+	Until            string
+	Limit            int
+	WaitLock         bool
+	FailOnError      bool
+	NoFilter         bool
+	IncludeHidden    bool
+	TempDir          string
+	SummaryFormat    string
+	Estimate         bool
+	Yes              bool
+	OnlyAlbums       []string
+	ReportCSV        string
+	SkippedSamples   int
 }
 
 func NewPushCmd(globalFlags *flags.GlobalFlags) *cobra.Command {
@@ -39,149 +116,1545 @@ func NewPushCmd(globalFlags *flags.GlobalFlags) *cobra.Command {
 		RunE:  cmd.Run,
 	}
 
-	pushCmd.Flags().IntVar(&cmd.NumberOfWorkers, "workers", 1, "Number of workers")
-	pushCmd.Flags().BoolVar(&cmd.DryRunMode, "dry-run", false, "Dry run mode")
+	pushCmd.Flags().IntVar(&cmd.NumberOfWorkers, "workers", 1, "Number of files to be uploaded concurrently (overrides UploadWorkerCount)")
+	pushCmd.Flags().StringVar(&cmd.RateLimit, "rate-limit", "", "Maximum upload rate, e.g. 2MB/s, 0 means unlimited (overrides UploadRateLimit)")
+	pushCmd.Flags().BoolVar(&cmd.NoProgress, "no-progress", false, "Disable the interactive progress bar, progress is logged periodically instead")
+	pushCmd.Flags().BoolVar(&cmd.Watch, "watch", false, "Keep running after the upload, uploading new or modified files as they appear")
+	pushCmd.Flags().DurationVar(&cmd.WatchInterval, "watch-interval", watcher.DefaultInterval, "Time between checks for new files when --watch is set")
+	pushCmd.Flags().BoolVar(&cmd.WatchPoll, "watch-poll", false, "Scan the folders every --watch-interval when --watch is set, instead of being notified of their changes, e.g. for network shares")
+	pushCmd.Flags().StringVar(&cmd.MetricsAddr, "metrics-addr", "", "Address to expose Prometheus metrics while running, e.g. :9091")
+	pushCmd.Flags().DurationVar(&cmd.ShutdownTimeout, "shutdown-timeout", defaultShutdownTimeout, "Time the uploads in progress have to finish once Ctrl+C is pressed, before aborting them")
+	pushCmd.Flags().DurationVar(&cmd.Timeout, "timeout", 0, "Maximum duration of the run, e.g. 2h, uploads in progress are aborted once it's reached. 0 means no limit")
+	pushCmd.Flags().DurationVar(&cmd.PerFileTimeout, "per-file-timeout", 0, "Maximum duration of the upload of a file, e.g. 10m, it's attempted again once it's reached (overrides PerFileTimeout)")
+	pushCmd.Flags().DurationVar(&cmd.MaxDuration, "max-duration", 0, "Maximum duration of the run, e.g. 2h, no more files are uploaded once it's reached, but the uploads in progress finish and the run exits successfully. 0 means no limit")
+	pushCmd.Flags().BoolVar(&cmd.RetryDeadLetters, "retry-dead-letters", false, "Attempt again the files that have failed MaxUploadAttempts times")
+	pushCmd.Flags().BoolVar(&cmd.FullScan, "full-scan", false, "Check all the files, not only the ones changed since the last successful run")
+	pushCmd.Flags().StringVar(&cmd.ChangedSinceRef, "changed-since", "", "Upload only the files added or modified since the git ref, e.g. a commit or a tag, as listed by git diff --name-only in the repository of every location")
+	pushCmd.Flags().StringVar(&cmd.Since, "since", "", "Upload only the files taken on or after the date, YYYY-MM-DD in the local time zone or RFC 3339")
+	pushCmd.Flags().StringVar(&cmd.Until, "until", "", "Upload only the files taken on or before the date, YYYY-MM-DD in the local time zone or RFC 3339")
+	pushCmd.Flags().IntVar(&cmd.Limit, "limit", 0, "Maximum number of files to be uploaded in the run, the next run continues with the other ones. 0 means no limit")
+	pushCmd.Flags().StringVar(&cmd.ExplainFilter, "explain-filter", "", "Explain which pattern allows or excludes the given path, and exit")
+	pushCmd.Flags().BoolVar(&cmd.WaitLock, "wait-lock", false, "Wait for another instance using the same config folder to finish, instead of exiting")
+	pushCmd.Flags().BoolVar(&cmd.FailOnError, "fail-on-error", false, "Fail processing a location if any of its files or directories could not be read, instead of skipping them")
+	pushCmd.Flags().BoolVar(&cmd.NoFilter, "no-filter", false, "Upload all the files not excluded, bypassing the include patterns (sets IncludeAll)")
+	pushCmd.Flags().StringVar(&cmd.SummaryFormat, "summary-format", notify.FormatText, "Format of the summary written to the standard output at the end of the run: text, json or yaml. It's only logged if it's not set")
+	pushCmd.Flags().StringVar(&cmd.TempDir, "temp-dir", "", "Folder of the intermediate files, like converted photos (overrides TempDir)")
+	pushCmd.Flags().BoolVar(&cmd.IncludeHidden, "include-hidden", false, "Upload hidden files, and scan hidden directories (sets IncludeHidden)")
+	pushCmd.Flags().BoolVar(&cmd.Estimate, "estimate", false, "Count the files and bytes to be uploaded before uploading them, asking for confirmation")
+	pushCmd.Flags().BoolVarP(&cmd.Yes, "yes", "y", false, "Upload without asking for confirmation when --estimate is set")
+	pushCmd.Flags().StringVar(&cmd.ReportCSV, "report-csv", "", "CSV file where a row is appended for every processed file: uploaded, skipped or failed")
+	pushCmd.Flags().IntVar(&cmd.SkippedSamples, "skipped-samples", notify.DefaultSkippedSamples, "Maximum number of paths kept as samples for every category of skipped files in the summary. 0 means no samples")
+	pushCmd.Flags().StringArrayVar(&cmd.OnlyAlbums, "only-album", nil, "Upload only the files of the album, skipping the ones of other albums. It could be repeated")
 
 	return pushCmd
 }
 
 func (cmd *PushCmd) Run(cobraCmd *cobra.Command, args []string) error {
+	if cmd.ExplainFilter != "" {
+		return cmd.explainFilter(cmd.ExplainFilter)
+	}
+
+	if cmd.NumberOfWorkers < 1 {
+		return fmt.Errorf("invalid number of workers: %d", cmd.NumberOfWorkers)
+	}
+
+	if cmd.Watch && cmd.DryRun {
+		return errors.New("--watch and --dry-run cannot be specified at the same time")
+	}
+
+	if cmd.Watch && cmd.WatchInterval <= 0 {
+		return fmt.Errorf("invalid watch interval: %s", cmd.WatchInterval)
+	}
+
+	if cmd.ShutdownTimeout <= 0 {
+		return fmt.Errorf("invalid shutdown timeout: %s", cmd.ShutdownTimeout)
+	}
+
+	if cmd.Timeout < 0 {
+		return fmt.Errorf("invalid timeout: %s", cmd.Timeout)
+	}
+
+	if cmd.PerFileTimeout < 0 {
+		return fmt.Errorf("invalid per-file timeout: %s", cmd.PerFileTimeout)
+	}
+
+	if cmd.MaxDuration < 0 {
+		return fmt.Errorf("invalid max duration: %s", cmd.MaxDuration)
+	}
+
+	if cmd.Limit < 0 {
+		return fmt.Errorf("invalid limit: %d", cmd.Limit)
+	}
+
+	if cmd.Watch && cmd.Limit > 0 {
+		return errors.New("--watch and --limit cannot be specified at the same time")
+	}
+
+	if cmd.Watch && cmd.ChangedSinceRef != "" {
+		return errors.New("--watch and --changed-since cannot be specified at the same time")
+	}
+
+	if cmd.DryRun && cmd.ReportCSV != "" {
+		return errors.New("--dry-run and --report-csv cannot be specified at the same time")
+	}
+
+	if !notify.IsValidFormat(cmd.SummaryFormat) {
+		return fmt.Errorf("invalid summary format: %s", cmd.SummaryFormat)
+	}
+
+	if cmd.SkippedSamples < 0 {
+		return fmt.Errorf("invalid number of skipped samples: %d", cmd.SkippedSamples)
+	}
+
+	// files are dated by their EXIF capture date, or their modification time if it's missing.
+	dateRange, err := upload.ParseDateRange(cmd.Since, cmd.Until)
+	if err != nil {
+		return err
+	}
+
 	ctx := context.Background()
-	cli, err := app.Start(ctx, cmd.CfgDir)
+	// only one instance uses the config folder at a time, so concurrent runs don't corrupt the tracking data.
+	lock, err := lockRun(ctx, cmd.CfgDir, cmd.WaitLock)
 	if err != nil {
 		return err
 	}
+	defer func() {
+		_ = lock.Release()
+	}()
+
+	cli, err := app.Start(ctx, cmd.CfgDir, appOptions(cmd.GlobalFlags)...)
+	if err != nil {
+		return err
+	}
+	// stopping the app flushes the tracked files, even if the run has been interrupted.
 	defer func() {
 		_ = cli.Stop()
 	}()
 
-	uploadQueue := worker.NewJobQueue(cmd.NumberOfWorkers, cli.Logger)
-	uploadQueue.Start()
-	defer uploadQueue.Stop()
+	// intermediate files, like converted photos, are written in a folder of the run, removed once it has finished.
+	tempBase := cli.Config.TempDir
+	if cobraCmd.Flags().Changed("temp-dir") {
+		tempBase = cmd.TempDir
+	}
+	tempDir, err := tempdir.New(tempBase)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tempDir.Remove(); err != nil {
+			cli.Logger.Warnf("Unable to remove the temporary folder '%s': %s", tempDir.Path, err)
+		}
+	}()
+	// the converted photos are not written once the free space of the volume is below the minimum.
+	minFreeSpace, _ := units.ParseSize(cli.Config.MinFreeSpace)
+	spaceGuard := &tempdir.SpaceGuard{Path: tempDir.Path, MinFree: uint64(minFreeSpace)}
+	// folders left by runs that could not remove them, e.g. because they crashed, are removed too.
+	if removed, err := tempdir.RemoveStale(tempBase, time.Now()); err != nil {
+		cli.Logger.Warnf("Unable to remove leftover temporary folders: %s", err)
+	} else if len(removed) > 0 {
+		cli.Logger.Infof("Removed %d leftover temporary folders.", len(removed))
+	}
+
+	// a hung upload is attempted again, instead of blocking its worker until the end of the run.
+	perFileTimeout, _ := time.ParseDuration(cli.Config.PerFileTimeout)
+	if cobraCmd.Flags().Changed("per-file-timeout") {
+		perFileTimeout = cmd.PerFileTimeout
+	}
+
+	// on interruption, no more files are enqueued and uploads in progress are given time to finish.
+	// Once the run timeout is reached, the scan and the uploads in progress are aborted.
+	runCtx := ctx
+	if cmd.Timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, cmd.Timeout)
+		defer cancel()
+	}
+	sd := newShutdown(runCtx, cmd.ShutdownTimeout, cli.Logger)
+	defer sd.release()
+	if cmd.MaxDuration > 0 {
+		sd.stopAfter(cmd.MaxDuration)
+	}
+	sd.exit = func(code int) {
+		_ = tempDir.Remove()
+		_ = lock.Release()
+		os.Exit(code)
+	}
+
+	if cmd.MetricsAddr != "" {
+		srv, err := metrics.Serve(cmd.MetricsAddr)
+		if err != nil {
+			return fmt.Errorf("unable to expose metrics at '%s': %s", cmd.MetricsAddr, err)
+		}
+		defer func() {
+			_ = srv.Stop()
+		}()
+		cli.Logger.Infof("Exposing metrics at http://%s/metrics", srv.Addr())
+	}
+
+	// when watching, uploads are paused until the next day once the request budget is exhausted.
+	if cmd.Watch {
+		cli.RequestBudget.SetWaitForRenewal(true)
+	}
+
+	// jobs setting Workers have a pool of their own, the other ones share the default one.
+	pools := newUploadPools(cmd.numberOfWorkers(cobraCmd, cli.Config.UploadWorkerCount), cli.Config.Jobs, cli.Logger)
+	pause := newPauseControl(cli.Config.ControlDir, sd.stopping, cli.Logger)
+	defer pause.release()
+	pools.SetPause(pause.pause)
+	pools.Start()
+	defer pools.Stop()
 	time.Sleep(1 * time.Second) // sleeps to avoid log messages colliding with output.
 
-	photosService, err := newPhotosService(cli.Client, cli.UploadSessionTracker, cli.Logger)
+	limiter, err := cmd.rateLimiter(cobraCmd, cli.Config.UploadRateLimit)
 	if err != nil {
 		return err
 	}
 
+	tracker := progress.NewTracker()
+	stats := runstats.New()
+	run := newRunSummary(stats)
+	run.skippedSamples = cmd.SkippedSamples
+	retry := newRetries(cli.RetryQueue, cli.Logger, cmd.DryRun)
+	limit := newUploadLimit(cmd.Limit)
+	storage := newStorageGuard(sd, cli.Logger)
+
+	// files with the same content than another one enqueued in this run are skipped, whatever job they belong to,
+	// or if it's in the same library.
+	dedups := newRunDedups(cli.Config, cli.ContentHashes)
+
+	var onUpload task.UploadHook
+	if cli.Config.OnUploadCommand != "" {
+		timeout, _ := time.ParseDuration(cli.Config.OnUploadTimeout)
+		onUpload = hook.NewCommand(cli.Config.OnUploadCommand, timeout)
+	}
+
+	if cmd.RetryDeadLetters && !cmd.DryRun {
+		n, err := cli.RetryQueue.ClearDeadLetters()
+		if err != nil {
+			return err
+		}
+		cli.Logger.Infof("%d files that have failed too many times will be attempted again.", n)
+	}
+
+	// the configuration has been validated already.
+	minFileAge, _ := time.ParseDuration(cli.Config.MinFileAge)
+	limits := uploadLimits(cli.Config)
+
+	if cmd.Estimate {
+		proceed, err := cmd.confirmEstimate(cobraCmd, sd.ctx, cli, limits, minFileAge, dateRange)
+		if err != nil {
+			return err
+		}
+		if !proceed {
+			cli.Logger.Infof("Upload cancelled, no file has been uploaded.")
+			return nil
+		}
+	}
+
+	// the report is written while the files are processed, so the rows are kept if the run crashes.
+	var rep *runReport
+	if cmd.ReportCSV != "" {
+		rep, err = openRunReport(cmd.ReportCSV, cli.Logger)
+		if err != nil {
+			return fmt.Errorf("unable to open the report '%s': %w", cmd.ReportCSV, err)
+		}
+		defer func() {
+			if err := rep.Close(); err != nil {
+				cli.Logger.Warnf("Unable to write the report '%s': %s", cmd.ReportCSV, err)
+			}
+		}()
+	}
+
+	// services are created once per account, jobs using the same account share them.
+	services := make(map[string]*accountServices)
+
+	// files changed during the run could have been missed, so the next run checks the ones changed since it started.
+	runStart := time.Now()
+	var scannedJobs []scannedJob
+
 	// launch all folder upload jobs
 	var totalItems int
-	for _, config := range cli.Config.Jobs {
+	var summary upload.WalkStats
+	var watchedJobs []watchedJob
+	// unsupportedErr, if it's set, is the unsupported file that has aborted the run, see OnUnsupported.
+	var unsupportedErr error
+	for i, config := range cli.Config.Jobs {
+		config = cmd.withFlags(config)
+		if sd.stopped() {
+			break
+		}
 		srcFolder := config.SourceFolder
 
-		filterFiles, err := filter.Compile(config.IncludePatterns, config.ExcludePatterns)
+		account, err := cli.Config.JobAccount(config)
+		if err != nil {
+			return err
+		}
+		folder, err := newFolderJob(cli, config, limits, minFileAge)
 		if err != nil {
 			return err
 		}
 
-		folder := upload.UploadFolderJob{
-			FileTracker: cli.FileTracker,
+		// files are uploaded to the account of the job, or to the ones of its routes.
+		destinations := make(map[string]jobDestination)
+		for _, a := range jobAccounts(account, folder.Routes) {
+			service, exist := services[a]
+			if !exist {
+				service, err = newAccountServices(ctx, cli, a, limiter, tracker)
+				if err != nil {
+					return err
+				}
+				service.sharedAlbums.DryRun = cmd.DryRun
+				services[a] = service
+			}
+			destinations[a], err = newJobDestination(service, config, tracker)
+			if err != nil {
+				return err
+			}
+		}
+		if err := cmd.resolveSharedAlbum(ctx, config, destinations, &folder); err != nil {
+			return err
+		}
+		uploadQueue := pools.forJob(i)
+
+		folder.FailOnError = cmd.FailOnError
+		folder.DateRange = dateRange
+		folder.OnlyAlbums = cmd.OnlyAlbums
+		if folder.ChangedFiles, err = cmd.changedFiles(ctx, srcFolder); err != nil {
+			return err
+		}
+		folder.OnSkipped = func(path string, reason string) {
+			run.addSkipped(path, reason)
+			if rep != nil {
+				rep.skipped(path, reason)
+			}
+		}
+		// files could only be deleted or moved if their file system supports it.
+		deleteOnSuccess := config.DeleteAfterUpload || config.AfterUpload == "delete"
+		if (deleteOnSuccess || config.AfterUpload == "move" || config.AfterUpload == "trash") && !folder.Writable() {
+			return fmt.Errorf("files of folder '%s' could not be deleted or moved after upload them", srcFolder)
+		}
+
+		// every destination prepares the uploads of its files, adding them to its albums.
+		config := config
+		dedup := dedups.forJob(config)
+		pipelines := make(map[string]*uploader.Pipeline, len(destinations))
+		for a, dest := range destinations {
+			service := dest.service
+			pipeline := &uploader.Pipeline{
+				Uploads:     dest.uploads,
+				FileTracker: cli.FileTracker,
+				Logger:      log.WithModule(cli.Logger, log.ModuleUpload),
+				Dedup:       dedup,
+			}
+			// albums are not created in dry-run mode.
+			if dest.sharedAlbum.ID != "" {
+				pipeline.Albums = fixedAlbum(dest.sharedAlbum.ID)
+			} else if !cmd.DryRun {
+				pipeline.Albums = service.albums
+			}
+			pipeline.Build = func(uploadItem *task.EnqueuedUpload, item upload.FileItem) {
+				uploadItem.AlbumItems = service.photos.Albums
+				uploadItem.AlbumBatch = service.albumBatch
+				uploadItem.DeleteOnSuccess = deleteOnSuccess
+				uploadItem.DryRun = cmd.DryRun
+				uploadItem.Covers = service.covers
+				uploadItem.Stats = stats
+				uploadItem.OnUpload = onUpload
+				uploadItem.OnUploadFatal = cli.Config.OnUploadFatal
+				// files not added to their album are attempted again, once the batches have been sent.
+				uploadItem.OnAttachFailed = func(path string, err error) {
+					run.addAttachFailure(path, err)
+					retry.attachFailed(path, err)
+				}
+				if config.CaptureDateDescription != "" {
+					uploadItem.Descriptions = task.CaptureDateDescriptions{Descriptions: xmp.Reader{}, Dates: &folder, Layout: config.CaptureDateDescription}
+				}
+				if service.matcher != nil {
+					uploadItem.Library = service.matcher
+				}
+				uploadItem.Reporter = run.reporter(rep)
+				uploadItem.PerFileTimeout = perFileTimeout
+				uploadItem.TimeoutRetries = cli.MaxRetries()
+				if config.ConvertHEIC {
+					uploadItem.Converter = convert.HeifConvert
+					uploadItem.TempDir = tempDir.Path
+					uploadItem.SpaceGuard = spaceGuard
+				}
+				if config.AfterUpload == "trash" {
+					uploadItem.Trash = trash.Bin{}
+				}
+				if config.AfterUpload == "move" {
+					uploadItem.MoveToDir = config.MoveToDir
+					uploadItem.SourceFolder = config.SourceFolder
+				}
+			}
+			pipelines[a] = pipeline
+		}
+
+		// submit enqueues a file to be uploaded, it returns false if it could not be enqueued.
+		submit := func(item upload.FileItem) bool {
+			if sd.stopped() || !retry.claim(item.Path) {
+				return false
+			}
+			// files not enqueued because of the limit are uploaded on the next run.
+			if !limit.reserve() {
+				retry.release(item.Path)
+				return false
+			}
+			pipeline, routed := pipelines[item.Account]
+			if !routed {
+				pipeline = pipelines[account]
+			}
+			uploadItem, err := pipeline.Prepare(sd.ctx, item)
+			var duplicateErr *uploader.DuplicateError
+			var albumErr *uploader.AlbumError
+			switch {
+			case errors.As(err, &duplicateErr):
+				logDuplicate(cli.Logger, item.Path, duplicateErr.Of)
+				run.addSkipped(item.Path, log.ReasonDuplicate)
+				if rep != nil {
+					rep.skipped(item.Path, log.ReasonDuplicate)
+				}
+				stats.AddSkipped(1)
+			case errors.As(err, &albumErr) && interrupted(err):
+				cli.Logger.Debugf("Skipping file '%s', album '%s' could not be created: %s", item.Path, item.AlbumName, albumErr.Err)
+			case errors.As(err, &albumErr):
+				cli.Logger.Debugf("Skipping file '%s', album '%s' could not be created: %s", item.Path, item.AlbumName, albumErr.Err)
+				run.addError(fmt.Sprintf("%s: album '%s' could not be created: %s", item.Path, item.AlbumName, albumErr.Err))
+				if rep != nil {
+					rep.failed(item.Path, item.AlbumName, log.ReasonAlbumFailed)
+				}
+			}
+			if err != nil {
+				retry.release(item.Path)
+				limit.release()
+				return false
+			}
 
-			SourceFolder: srcFolder,
-			CreateAlbums: config.CreateAlbums,
-			Filter:       filterFiles,
+			// files only added to the album don't transfer their content.
+			size := item.Size()
+			if item.MediaItemID != "" {
+				size = 0
+			}
+			tracker.AddFile(item.Path, size)
+			uploadQueue.Submit(limit.wrap(sd.wrap(storage.wrap(withFavorite(uploadItem, item, run)))))
+			return true
+		}
+		// watched and due files have changed, or failed, so they are always checked.
+		watched := watchedJob{folder: folder, submit: submit}
+		if cli.Config.StableSizeCheck {
+			watched.sizeCheckDelay = stableSizeCheckDelay
 		}
+		if cmd.Watch {
+			// files created or modified while the existing ones are uploaded are reported once watching.
+			watched.watcher = cmd.newWatcher(watched)
+			if err := watched.watcher.Snapshot(); err != nil {
+				cli.Logger.Debugf("Unable to take the snapshot of location '%s', it's taken once watching: %s", srcFolder, err)
+			}
+		}
+		watchedJobs = append(watchedJobs, watched)
+
+		// enqueue files to be uploaded as soon as they are found, unless an upload order is set.
+		// The workers will receive it via channel.
+		cli.Logger.WithFields(log.Fields{"event": log.EventScanStart, "path": srcFolder}).Infof("Scanning location '%s'.", srcFolder)
+		var foundItems int
 
-		// get UploadItem{} to be uploaded to Google Photos.
-		itemsToUpload, err := folder.ScanFolder(cli.Logger)
+		// files failed on previous runs are attempted first, the walk doesn't enqueue them again.
+		for _, path := range retry.due(srcFolder) {
+			stats, err := folder.VisitFile(cli.Logger, path, func(item upload.FileItem) {
+				if submit(item) {
+					foundItems++
+				}
+			})
+			if os.IsNotExist(err) || stats.SkippedFiltered+stats.SkippedTracked+stats.SkippedRejected+stats.SkippedUnreadable > 0 {
+				retry.forget(path)
+			}
+			if errors.Is(err, upload.ErrUnsupportedFile) {
+				unsupportedErr = err
+				break
+			}
+		}
+		if unsupportedErr != nil {
+			logUnsupportedFile(cli.Logger, run, unsupportedErr)
+			break
+		}
+
+		fingerprint := jobFingerprint(cli.Config, config)
+		scanned := folder
+		if !cmd.FullScan {
+			scanned.ChangedSince = cmd.changedSince(cli, srcFolder, fingerprint, minFileAge)
+		}
+
+		stats, err := walkFolder(sd.ctx, scanned, cli.Config.UploadOrder, cli.Logger, func(item upload.FileItem) {
+			if submit(item) {
+				foundItems++
+			}
+		})
+		totalItems += foundItems
+		summary.Found += stats.Found
+		summary.SkippedFiltered += stats.SkippedFiltered
+		summary.SkippedTracked += stats.SkippedTracked
+		summary.SkippedRecent += stats.SkippedRecent
+		summary.SkippedRejected += stats.SkippedRejected
+		summary.SkippedUnchanged += stats.SkippedUnchanged
+		summary.SkippedUnreadable += stats.SkippedUnreadable
+		run.addWalkStats(stats)
+		if interrupted(err) {
+			cli.Logger.Debugf("Interrupted processing location '%s': %s", config.SourceFolder, err)
+			continue
+		}
+		// the files enqueued already are uploaded, but no more files are scanned.
+		if errors.Is(err, upload.ErrUnsupportedFile) {
+			unsupportedErr = err
+			logUnsupportedFile(cli.Logger, run, err)
+			break
+		}
 		if err != nil {
-			cli.Logger.Fatalf("Failed to process location '%s': %s", config.SourceFolder, err)
+			run.addError(fmt.Sprintf("%s: %s", config.SourceFolder, err))
+			cli.Logger.WithFields(log.Fields{"event": log.EventError, "path": config.SourceFolder, "reason": log.ReasonScanFailed, "error": err}).Failf("Failed to process location '%s': %s", config.SourceFolder, err)
 			continue
 		}
+		scannedJobs = append(scannedJobs, scannedJob{folder: srcFolder, fingerprint: fingerprint})
 
-		cli.Logger.Infof("Found %d items to be uploaded processing location '%s'.", len(itemsToUpload), config.SourceFolder)
+		cli.Logger.Infof("Found %d items to be uploaded processing location '%s'.", foundItems, config.SourceFolder)
+	}
 
-		// If dry-run-mode, stop here.
-		if cmd.DryRunMode {
-			cli.Logger.Info("Running in dry run mode. No changes has been made.")
-			return nil
+	if cmd.DryRun {
+		for i := 0; i < totalItems; i++ {
+			retry.record(<-pools.results)
 		}
+		cli.Logger.Infof("%d files would be uploaded, %d skipped by filters, %d skipped as already uploaded, %d skipped as recently modified, %d skipped as not accepted by Google Photos, %d skipped as not changed since the last run, %d skipped as unreadable.", summary.Found, summary.SkippedFiltered, summary.SkippedTracked, summary.SkippedRecent, summary.SkippedRejected, summary.SkippedUnchanged, summary.SkippedUnreadable)
+		cli.Logger.Info("Running in dry run mode. No changes has been made.")
+		return unsupportedErr
+	}
 
-		// Get items to be uploaded by album name, this reduce a lot the calls to Google Photos API to get albums ID.
-		itemsByAlbum := make(map[string][]string)
-		for _, i := range itemsToUpload {
-			itemsByAlbum[i.AlbumName] = append(itemsByAlbum[i.AlbumName], i.Path)
+	err = cmd.waitForUploads(pools.results, tracker, run, retry, totalItems, cli.Logger)
+	flushAlbumBatches(sd.ctx, services)
+	retry.recordAttachFailures()
+	if err == nil {
+		err = unsupportedErr
+	}
+	if err == nil && cmd.Watch && !sd.stopped() {
+		err = cmd.watch(watchedJobs, pools.results, tracker, run, retry, sd, services, cli.Logger)
+		flushAlbumBatches(sd.ctx, services)
+		retry.recordAttachFailures()
+	}
+	if storage.isFull() {
+		// the other uploads have not been attempted, so they are not reported as failed.
+		if err == nil {
+			err = errStorageFull
+		}
+	} else if storage.isLowSpace() {
+		if err == nil {
+			err = errLowFreeSpace
 		}
+	} else if sd.reachedMaxDuration() {
+		// it's a clean stop, not an error: the uploaded files are tracked, and the next run continues with the other ones.
+		run.setMaxDurationReached()
+		cli.Logger.Infof("The maximum duration of %s has been reached, files not uploaded will be uploaded on the next run.", cmd.MaxDuration)
+	} else if sd.stopped() {
+		cli.Logger.Warn("The run has been interrupted, files not uploaded will be uploaded on the next run.")
+	}
+	if limit.hit() {
+		run.setLimitReached()
+		cli.Logger.Warnf("The limit of %d files has been reached, files not uploaded will be uploaded on the next run.", cmd.Limit)
+	}
+	reportFavorites(run.Summary().Favorites, cli.Logger)
+	if n := run.Summary().PermissionErrors; n > 0 {
+		cli.Logger.Warnf("%d files or directories could not be read and have been skipped, check their permissions.", n)
+	}
+	exhausted := false
+	if remaining, err := cli.RequestBudget.Remaining(); err == nil && remaining == 0 {
+		exhausted = true
+		cli.Logger.Warnf("The daily request budget of %d requests has been exhausted, files not uploaded will be uploaded on a run after %s.", cli.Config.DailyRequestBudget, cli.RequestBudget.Renewal().Local().Format("2006-01-02 15:04:05"))
+	}
 
-		for albumName, files := range itemsByAlbum {
-			albumId, err := getOrCreateAlbum(ctx, photosService.Albums, albumName)
-			if err != nil {
-				cli.Logger.Failf("Unable to create album '%s': %s", albumName, err)
-				continue
-			}
-			for _, file := range files {
-				// enqueue files to be uploaded. The workers will receive it via channel.
-				totalItems++
-				uploadQueue.Submit(&task.EnqueuedUpload{
-					Context:     ctx,
-					Uploads:     photosService,
-					FileTracker: cli.FileTracker,
-					Logger:      cli.Logger,
-
-					Path:            file,
-					AlbumID:         albumId,
-					DeleteOnSuccess: config.DeleteAfterUpload,
-				})
-			}
+	// cover photos are set once the albums are populated, even if the run has been interrupted.
+	for _, service := range services {
+		service.covers.Apply(ctx)
+	}
+
+	retry.reportDeadLetters(run)
+
+	// the next run only checks the files changed since this one started, if all of them have been uploaded.
+	// Files out of the date range have not been uploaded, so they are checked again.
+	// Files not enqueued because of the limit have not been uploaded either, nor the ones of other albums, nor
+	// the ones not changed since the git ref.
+	if err == nil && !sd.stopped() && !exhausted && !limit.hit() && run.Summary().Failed == 0 && stats.Snapshot().Failed == 0 && dateRange.IsZero() && len(cmd.OnlyAlbums) == 0 && cmd.ChangedSinceRef == "" {
+		recordLastRuns(cli, scannedJobs, runStart)
+	}
+
+	logRunStats(cli.Logger, stats.Snapshot())
+	logSkipped(cli.Logger, run.Summary().SkippedByCategory)
+	// the summary is written for scripts only if they ask for it, the text one is logged already.
+	if cobraCmd.Flags().Changed("summary-format") {
+		if err := notify.WriteReport(cobraCmd.OutOrStdout(), run.Summary(), cmd.SummaryFormat); err != nil {
+			cli.Logger.Warnf("Unable to write the summary: %s", err)
 		}
 	}
+	notifyWebhook(cli.Config, run.Summary(), cli.Logger)
+	return err
+}
+
+// lockRun acquires the lock of the config folder, waiting for it if wait (`--wait-lock`) is set. It returns a nil
+// lock if the folder doesn't exist, starting the application reports it.
+func lockRun(ctx context.Context, cfgDir string, wait bool) (*runlock.Lock, error) {
+	path := filepath.Join(cfgDir, runlock.DefaultFilename)
+	lock, err := runlock.TryAcquire(path)
+	if errors.Is(err, runlock.ErrLocked) && wait {
+		log.Warnf("Waiting for another instance using the config folder to finish: %s", err)
+		// the wait is interrupted by a signal, since uploads have not started yet.
+		waitCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		lock, err = runlock.Acquire(waitCtx, path, runLockInterval)
+	}
+	if errors.Is(err, runlock.ErrLocked) && !wait {
+		return nil, fmt.Errorf("%w, use --wait-lock to wait for it to finish", err)
+	}
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if lock.StalePID != 0 {
+		log.Warnf("The previous run (pid %d) didn't release the lock at '%s', it probably crashed.", lock.StalePID, path)
+	}
+	return lock, nil
+}
+
+// scannedJob is a job whose folder has been scanned without errors.
+type scannedJob struct {
+	folder      string
+	fingerprint string
+}
+
+// withFlags returns the job with the options set by the flags, which apply to all the jobs.
+func (cmd *PushCmd) withFlags(job config.FolderUploadJob) config.FolderUploadJob {
+	// the flag bypasses the include patterns of all the jobs.
+	if cmd.NoFilter {
+		job.IncludeAll = true
+	}
+	if cmd.IncludeHidden {
+		job.IncludeHidden = true
+	}
+	return job
+}
+
+// changedFiles returns the files of the folder changed since the git ref of `--changed-since`, by their path
+// relative to the folder, or nil if it's not set.
+func (cmd *PushCmd) changedFiles(ctx context.Context, folder string) (map[string]bool, error) {
+	if cmd.ChangedSinceRef == "" {
+		return nil, nil
+	}
+	files, err := gitchanges.Files(ctx, folder, cmd.ChangedSinceRef)
+	if errors.Is(err, gitchanges.ErrNotRepository) {
+		return nil, fmt.Errorf("--changed-since could not be used with location '%s', it's not in a git repository", folder)
+	}
+	if err != nil {
+		return nil, err
+	}
+	changed := make(map[string]bool, len(files))
+	for _, f := range files {
+		changed[f] = true
+	}
+	return changed, nil
+}
+
+// changedSince returns the time since when the files of the folder should be checked, given its last successful run.
+// It's moved back by the MinFileAge too, since files modified more recently were skipped. A zero time checks all the files.
+func (cmd *PushCmd) changedSince(cli *app.App, folder string, fingerprint string, minFileAge time.Duration) time.Time {
+	margin := clockSkewMargin
+	if minFileAge > margin {
+		margin = minFileAge
+	}
+	since, ok, err := cli.LastRuns.ChangedSince(folder, fingerprint, margin)
+	if err != nil {
+		cli.Logger.Warnf("Unable to read the last run of '%s', checking all the files: %s", folder, err)
+		return time.Time{}
+	}
+	if !ok {
+		return time.Time{}
+	}
+	cli.Logger.Infof("Checking files changed since %s in '%s', use --full-scan to check all of them.", since.Local().Format("2006-01-02 15:04:05"), folder)
+	return since
+}
 
+// recordLastRuns records the successful run of the scanned jobs.
+func recordLastRuns(cli *app.App, jobs []scannedJob, start time.Time) {
+	for _, job := range jobs {
+		if err := cli.LastRuns.Set(job.folder, lastrun.Run{StartedAt: start, Fingerprint: job.fingerprint}); err != nil {
+			cli.Logger.Warnf("Unable to record the last run of '%s': %s", job.folder, err)
+		}
+	}
+}
+
+// jobFingerprint identifies the settings deciding which files of the job are uploaded. Files skipped
+// with other settings could be uploaded now, even if they have not changed, so all of them are checked then.
+func jobFingerprint(cfg *config.Config, job config.FolderUploadJob) string {
+	// how fast the files are uploaded, and how they are described, doesn't decide which ones are uploaded.
+	job.Workers, job.RateLimit, job.CaptureDateDescription = 0, "", ""
+	b, _ := json.Marshal(struct {
+		Job           config.FolderUploadJob
+		MaxPhotoSize  string
+		MaxVideoSize  string
+		MIMEDetection string `json:",omitempty"`
+	}{job, cfg.MaxPhotoSize, cfg.MaxVideoSize, cfg.MIMEDetection})
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// waitForUploads gets the results of the enqueued uploads, reporting the progress and the failed ones.
+// It returns an error if the authorization has expired, since it requires to authenticate again.
+func (cmd *PushCmd) waitForUploads(results chan worker.JobResult, tracker *progress.Tracker, run *runSummary, retry *retries, totalItems int, logger log.Logger) error {
 	if totalItems == 0 {
 		return nil
 	}
 
-	bar := progressbar.NewOptions(totalItems,
-		progressbar.OptionFullWidth(),
-		progressbar.OptionSetDescription("Uploading files..."),
-		progressbar.OptionSetPredictTime(false),
-		progressbar.OptionShowCount(),
-	)
+	reporter := cmd.progressReporter(tracker, logger)
+	reporter.Start()
 
-	// get responses from the enqueued jobs
-	var uploadedItems int
+	// get responses from the enqueued jobs, errors on a file don't stop the others.
+	var failedItems []worker.JobResult
+	var interruptedItems int
 	for i := 0; i < totalItems; i++ {
-		r := <-uploadQueue.ChanJobResults()
+		r := <-results
 
-		_ = bar.Add(1)
+		tracker.Done(r.ID)
 
-		if r.Err != nil {
-			cli.Logger.Failf("Error processing %s", r.ID)
+		run.addResult(r)
+		retry.record(r)
+		if interrupted(r.Err) {
+			interruptedItems++
+			logger.Debugf("Interrupted processing %s: %s", r.ID, r.Err)
+		} else if r.Err != nil {
+			failedItems = append(failedItems, r)
 		} else {
-			uploadedItems++
-			cli.Logger.Debugf("Successfully processing %s", r.ID)
+			logger.Debugf("Successfully processing %s", r.ID)
 		}
 	}
 
-	_ = bar.Finish()
+	reporter.Stop()
 
-	cli.Logger.Donef("%d processed files: %d successfully, %d with errors", totalItems, uploadedItems, totalItems-uploadedItems)
+	var authErr error
+	for _, r := range failedItems {
+		logUploadError(logger, r)
+		if errors.Is(r.Err, app.ErrInvalidGrant) {
+			authErr = r.Err
+		}
+	}
+
+	if interruptedItems > 0 {
+		logger.Donef("%d processed files: %d successfully, %d with errors, %d interrupted", totalItems, totalItems-len(failedItems)-interruptedItems, len(failedItems), interruptedItems)
+		return authErr
+	}
+	logger.Donef("%d processed files: %d successfully, %d with errors", totalItems, totalItems-len(failedItems), len(failedItems))
+	return authErr
+}
+
+// logUnsupportedFile logs the unsupported file aborting the run, as set by OnUnsupported.
+func logUnsupportedFile(logger log.Logger, run *runSummary, err error) {
+	run.addError(err.Error())
+	logger.WithFields(log.Fields{"event": log.EventError, "reason": log.ReasonUnsupportedType, "error": err}).Failf("Aborting the run, %s. Set OnUnsupported to skip or warn to omit these files.", err)
+}
+
+// logUploadError logs the failed upload, with a stable reason code.
+func logUploadError(logger log.Logger, r worker.JobResult) {
+	logger.WithFields(log.Fields{"event": log.EventError, "path": r.ID, "reason": errorReason(r.Err), "error": r.Err}).Failf("Error processing %s: %s", r.ID, r.Err)
+}
+
+// errorReason returns the reason code of an upload error.
+func errorReason(err error) string {
+	switch {
+	case errors.Is(err, app.ErrInvalidGrant):
+		return log.ReasonAuthExpired
+	case errors.Is(err, os.ErrNotExist):
+		return log.ReasonFileNotFound
+	case errors.Is(err, os.ErrPermission):
+		return log.ReasonUnreadable
+	case errors.Is(err, upload.ErrStorageFull):
+		return log.ReasonStorageFull
+	case errors.Is(err, tempdir.ErrLowFreeSpace):
+		return log.ReasonLowFreeSpace
+	case errors.Is(err, task.ErrUploadTimeout):
+		return log.ReasonTimeout
+	case errors.Is(err, upload.ErrQuotaExceeded):
+		return log.ReasonQuotaExceeded
+	case errors.Is(err, upload.ErrUnauthorized):
+		return log.ReasonUnauthorized
+	case errors.Is(err, upload.ErrUnsupportedMedia):
+		return log.ReasonUnsupported
+	case errors.Is(err, upload.ErrNetwork):
+		return log.ReasonNetwork
+	default:
+		return log.ReasonUploadFailed
+	}
+}
+
+// watchedJob is a folder upload job watched for new or modified files.
+type watchedJob struct {
+	folder upload.UploadFolderJob
+	submit func(item upload.FileItem) bool
+	// sizeCheckDelay, if it's set, is the time between the two checks of the size of a file before uploading it.
+	sizeCheckDelay time.Duration
+	// watcher, if it's set, is the watcher of the folder, created before it's scanned.
+	watcher *watcher.Watcher
+}
+
+// newWatcher returns the watcher of the job folder.
+func (cmd *PushCmd) newWatcher(job watchedJob) *watcher.Watcher {
+	w := watcher.New(job.folder.SourceFolder)
+	w.Interval = cmd.WatchInterval
+	w.Poll = cmd.WatchPoll
+	// recently modified files are reported once they are old enough, instead of being skipped.
+	w.MinAge = job.folder.MinFileAge
+	w.SizeCheckDelay = job.sizeCheckDelay
+	// files are watched like they are scanned, through the file system of the job and its symbolic links.
+	w.Walk = job.folder.Walk
+	w.Stat = job.folder.Stat
+	w.SkipDir = func(path string) bool {
+		return !job.folder.Filter.IsAllowedDir(upload.RelativePath(job.folder.SourceFolder, path)) || job.folder.IsHidden(path)
+	}
+	return w
+}
+
+// watch uploads new or modified files in the jobs folders until the run is interrupted.
+// Uploads in progress are completed, or aborted after the shutdown timeout, before returning.
+func (cmd *PushCmd) watch(jobs []watchedJob, results chan worker.JobResult, tracker *progress.Tracker, run *runSummary, retry *retries, sd *shutdown, services map[string]*accountServices, logger log.Logger) error {
+	// watching stops too once the run timeout is reached.
+	ctx, cancel := context.WithCancel(sd.ctx)
+	defer cancel()
+
+	go func() {
+		select {
+		case <-sd.stopping:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	// inFlight are the enqueued uploads, results are collected while watching.
+	var inFlight sync.WaitGroup
+	// stopErr is the error stopping the watch, an expired authorization or an unsupported file.
+	var stopErr error
+	var stopErrOnce sync.Once
+	collectorDone := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case r := <-results:
+				tracker.Done(r.ID)
+				run.addResult(r)
+				retry.record(r)
+				if interrupted(r.Err) {
+					logger.Debugf("Interrupted processing %s: %s", r.ID, r.Err)
+				} else if r.Err != nil {
+					logUploadError(logger, r)
+					if errors.Is(r.Err, app.ErrInvalidGrant) {
+						stopErrOnce.Do(func() { stopErr = r.Err })
+						cancel()
+					}
+				} else {
+					logger.Donef("Successfully processing %s", r.ID)
+				}
+				// files added to albums don't wait for a full batch, once there are no more results.
+				if len(results) == 0 {
+					flushAlbumBatches(sd.ctx, services)
+					retry.recordAttachFailures()
+				}
+				inFlight.Done()
+			case <-collectorDone:
+				return
+			}
+		}
+	}()
+
+	var watchers sync.WaitGroup
+	for _, job := range jobs {
+		job := job
+		w := job.watcher
+		if w == nil {
+			w = cmd.newWatcher(job)
+		}
+
+		watchers.Add(1)
+		go func() {
+			defer watchers.Done()
+			err := w.Run(ctx, func(path string) {
+				stats, err := job.folder.VisitFile(logger, path, func(item upload.FileItem) {
+					inFlight.Add(1)
+					if !job.submit(item) {
+						inFlight.Done()
+					}
+				})
+				run.addWalkStats(stats)
+				if errors.Is(err, upload.ErrUnsupportedFile) {
+					logUnsupportedFile(logger, run, err)
+					stopErrOnce.Do(func() { stopErr = err })
+					cancel()
+				} else if err != nil {
+					logger.Debugf("Skipping file '%s': %s", path, err)
+				}
+			})
+			if err != nil {
+				logger.Failf("Failed to watch location '%s': %s", job.folder.SourceFolder, err)
+			}
+		}()
+	}
+	logger.Infof("Watching %d locations for new files, press Ctrl+C to stop.", len(jobs))
+
+	watchers.Wait()
+	inFlight.Wait()
+	close(collectorDone)
+	return stopErr
+}
+
+// runSummary counts the results of the run, to be notified once it has finished. Totals are kept by stats,
+// and it keeps the messages of the errors and dead letters.
+// It's safe for concurrent use, since watched jobs are handled concurrently.
+type runSummary struct {
+	stats *runstats.RunStats
+
+	mu     sync.Mutex
+	errors []string
+	// partialBatchErrors are the files that could not be added to their album, while others of the batch were.
+	partialBatchErrors []string
+	deadLetters        []string
+	// reasons are the number of failed uploads by their reason.
+	reasons map[string]int
+	// limitReached is true if files have not been uploaded because of the --limit flag.
+	limitReached bool
+	// maxDurationReached is true if files have not been uploaded because of the --max-duration flag.
+	maxDurationReached bool
+	// favorites are the uploaded files that should be marked as favorites.
+	favorites []string
+	// permissionErrors are the files and directories skipped because they could not be read.
+	permissionErrors int
+	// skipped are the skipped files by the category of their reason, with up to skippedSamples of their paths.
+	skipped        map[notify.SkipCategory]notify.SkippedFiles
+	skippedSamples int
+}
+
+func newRunSummary(stats *runstats.RunStats) *runSummary {
+	return &runSummary{stats: stats, reasons: make(map[string]int), skipped: make(map[notify.SkipCategory]notify.SkippedFiles), skippedSamples: notify.DefaultSkippedSamples}
+}
+
+// addWalkStats counts the files found and skipped when walking a folder.
+func (r *runSummary) addWalkStats(stats upload.WalkStats) {
+	skipped := stats.SkippedFiltered + stats.SkippedTracked + stats.SkippedRecent + stats.SkippedRejected + stats.SkippedUnchanged + stats.SkippedUnreadable
+	r.stats.AddScanned(stats.Found + skipped)
+	r.stats.AddSkipped(skipped)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.permissionErrors += stats.SkippedUnreadable
+}
+
+// addResult counts the result of an upload. Uploaded files are counted, with their size, by the upload itself.
+func (r *runSummary) addResult(result worker.JobResult) {
+	if interrupted(result.Err) {
+		r.stats.AddSkipped(1)
+		r.addSkippedTo(notify.SkipInterrupted, result.ID)
+		return
+	}
+	if result.Err != nil {
+		r.addError(fmt.Sprintf("%s: %s", result.ID, result.Err))
+		r.mu.Lock()
+		r.reasons[errorReason(result.Err)]++
+		r.mu.Unlock()
+	}
+}
+
+// addAttachFailure keeps the failure of a file that could not be added to its album in a batch, which has been
+// counted as failed by the upload itself. Failures of partially added batches are kept apart from the others.
+func (r *runSummary) addAttachFailure(path string, err error) {
+	message := fmt.Sprintf("%s: %s", path, err)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if task.IsPartialBatch(err) {
+		r.reasons[log.ReasonPartialBatch]++
+		if len(r.partialBatchErrors) < notify.MaxErrors {
+			r.partialBatchErrors = append(r.partialBatchErrors, message)
+		}
+		return
+	}
+	r.reasons[errorReason(err)]++
+	if len(r.errors) < notify.MaxErrors {
+		r.errors = append(r.errors, message)
+	}
+}
+
+// addSkipped groups the file skipped for the reason, one of the log.Reason* codes, by its category.
+// It's only grouped here, it's counted as skipped by whoever skips it.
+func (r *runSummary) addSkipped(path string, reason string) {
+	r.addSkippedTo(notify.SkipCategoryOf(reason), path)
+}
+
+func (r *runSummary) addSkippedTo(category notify.SkipCategory, path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	files := r.skipped[category]
+	files.Count++
+	if len(files.Samples) < r.skippedSamples {
+		files.Samples = append(files.Samples, path)
+	}
+	r.skipped[category] = files
+}
+
+// setLimitReached records that files have not been uploaded because of the --limit flag.
+func (r *runSummary) setLimitReached() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.limitReached = true
+}
+
+// setMaxDurationReached records that files have not been uploaded because of the --max-duration flag.
+func (r *runSummary) setMaxDurationReached() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.maxDurationReached = true
+}
+
+// addFavorite adds an uploaded file that should be marked as favorite.
+func (r *runSummary) addFavorite(path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.favorites = append(r.favorites, path)
+}
+
+// addDeadLetter adds a file that has failed too many times.
+func (r *runSummary) addDeadLetter(message string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deadLetters = append(r.deadLetters, message)
+}
+
+// addError counts a failure, keeping its message if there are less than notify.MaxErrors.
+func (r *runSummary) addError(message string) {
+	r.stats.AddFailed()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.errors) < notify.MaxErrors {
+		r.errors = append(r.errors, message)
+	}
+}
+
+// Summary returns the summary of the run, until now.
+func (r *runSummary) Summary() notify.Summary {
+	s := notify.NewSummary(r.stats.Snapshot())
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s.Errors = append([]string(nil), r.errors...)
+	if len(r.partialBatchErrors) > 0 {
+		s.PartialBatchErrors = append([]string(nil), r.partialBatchErrors...)
+	}
+	s.DeadLetters = append([]string(nil), r.deadLetters...)
+	s.LimitReached = r.limitReached
+	s.MaxDurationReached = r.maxDurationReached
+	s.Favorites = append([]string(nil), r.favorites...)
+	s.PermissionErrors = r.permissionErrors
+	if albums := r.stats.Albums(); len(albums) > 0 {
+		s.Albums = albums
+	}
+	if len(r.skipped) > 0 {
+		s.SkippedByCategory = make(map[notify.SkipCategory]notify.SkippedFiles, len(r.skipped))
+		for c, files := range r.skipped {
+			s.SkippedByCategory[c] = notify.SkippedFiles{Count: files.Count, Samples: append([]string(nil), files.Samples...)}
+		}
+	}
+	if len(r.reasons) > 0 {
+		s.FailureReasons = make(map[string]int, len(r.reasons))
+		for reason, n := range r.reasons {
+			s.FailureReasons[reason] = n
+		}
+	}
+	return s
+}
+
+// logRunStats logs the totals of the run, with their values as fields for structured logs.
+func logRunStats(logger log.Logger, s runstats.Snapshot) {
+	logger.WithFields(log.Fields{
+		"event":       log.EventRunSummary,
+		"scanned":     s.Scanned,
+		"uploaded":    s.Uploaded,
+		"skipped":     s.Skipped,
+		"failed":      s.Failed,
+		"bytes":       s.BytesUploaded,
+		"duration_ms": s.Duration.Milliseconds(),
+	}).Infof("Run summary: %d files scanned, %d uploaded (%d bytes), %d skipped, %d failed, in %s.", s.Scanned, s.Uploaded, s.BytesUploaded, s.Skipped, s.Failed, s.Duration.Round(time.Second))
+}
+
+// logSkipped logs the skipped files grouped by category, with their counts as fields for structured logs.
+func logSkipped(logger log.Logger, skipped map[notify.SkipCategory]notify.SkippedFiles) {
+	if len(skipped) == 0 {
+		return
+	}
+	fields := log.Fields{"event": log.EventRunSummary}
+	for c, files := range skipped {
+		fields["skipped_"+string(c)] = files.Count
+	}
+	logger.WithFields(fields).Infof("Skipped files: %s.", notify.SkippedSummary(skipped))
+}
+
+// notifyWebhook posts the summary to the configured NotifyWebhook, if any.
+// Errors are logged, since they should not fail the run.
+func notifyWebhook(cfg *config.Config, s notify.Summary, logger log.Logger) {
+	if cfg.NotifyWebhook == "" {
+		return
+	}
+	timeout, _ := time.ParseDuration(cfg.NotifyTimeout)
+	w := notify.NewWebhook(cfg.NotifyWebhook, cfg.NotifyOn, timeout)
+	if !w.ShouldNotify(s) {
+		return
+	}
+	if err := w.Notify(context.Background(), s); err != nil {
+		logger.Warnf("Unable to notify webhook: %s", err)
+		return
+	}
+	logger.Debug("Webhook has been notified")
+}
+
+// accountServices groups the Google Photos services authenticated for an account.
+type accountServices struct {
+	photos *gphotos.Client
+	albums *task.AlbumCache
+	// sharedAlbums are the albums shared with the account, found apart from its albums.
+	sharedAlbums *task.SharedAlbums
+	covers       *task.AlbumCovers
+	// albumBatch adds the files already uploaded to their albums, in batches.
+	albumBatch *task.AlbumBatch
+	// matcher, if it's set, finds the media items of the library matching the files, before uploading them.
+	matcher *library.Matcher
+	// finder finds the media items that could have been created by failed attempts, before creating them again.
+	finder *library.Matcher
+	// sharedTokens, if it's set, shares the upload tokens of the files with the same content uploaded to the account.
+	sharedTokens *upload.SharedTokens
+
+	client *http.Client
+	cli    *app.App
+}
+
+// newAccountServices returns the Google Photos services for the account. Uploads of all the accounts
+// share the rate limiter and the progress tracker.
+func newAccountServices(ctx context.Context, cli *app.App, account string, limiter *ratelimit.Limiter, tracker *progress.Tracker) (*accountServices, error) {
+	client, err := cli.ClientForAccount(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+
+	photosService, err := newPhotosClient(client, cli, limiter, tracker)
+	if err != nil {
+		return nil, err
+	}
+
+	albums, metadata := photosapi.NewAlbumCache(client, photosService, cli.Config.PhotosAPIBaseURL, cli.Logger)
+
+	services := &accountServices{
+		photos:       photosService,
+		albums:       albums,
+		sharedAlbums: photosapi.NewSharedAlbums(client, cli.Config.PhotosAPIBaseURL, cli.Logger),
+		covers:       task.NewAlbumCovers(metadata, cli.Logger),
+		albumBatch:   task.NewAlbumBatch(photosService.Albums),
+		client:       client,
+		cli:          cli,
+	}
+	services.finder = photosapi.NewMatcher(client, cli.Config.PhotosAPIBaseURL)
+	if cli.Config.ShareUploadTokens {
+		services.sharedTokens = upload.NewSharedTokens()
+		services.sharedTokens.Hashes = cli.ContentHashes
+	}
+	if cli.Config.DedupLibrarySearch {
+		services.matcher = photosapi.NewMatcher(client, cli.Config.PhotosAPIBaseURL)
+	}
+	return services, nil
+}
+
+// jobDestination is an account where a job uploads files.
+type jobDestination struct {
+	service *accountServices
+	uploads *upload.TokenReusingUploads
+	// sharedAlbum, if the job sets SharedAlbum, is the shared album of the account where the files are added.
+	sharedAlbum library.SharedAlbum
+}
+
+// newJobDestination returns the destination of the files of the job uploaded to the account of the service.
+func newJobDestination(service *accountServices, job config.FolderUploadJob, tracker *progress.Tracker) (jobDestination, error) {
+	// jobs setting RateLimit upload their files with a limiter of their own.
+	photos := service.photos
+	if job.RateLimit != "" {
+		var err error
+		photos, err = service.photosWithRateLimit(job.RateLimit, tracker)
+		if err != nil {
+			return jobDestination{}, err
+		}
+	}
+	setAlbumMetadata(service, job)
+	// files whose media item could not be created are not uploaded again when they are attempted later in the run.
+	return jobDestination{service: service, uploads: service.newUploads(photos)}, nil
+}
+
+// resolveSharedAlbum finds the shared album of the job, if it sets SharedAlbum, in every account where it uploads
+// files, so the files are added to it. It returns an error if any of the accounts could not add files to it.
+func (cmd *PushCmd) resolveSharedAlbum(ctx context.Context, job config.FolderUploadJob, destinations map[string]jobDestination, folder *upload.UploadFolderJob) error {
+	if job.SharedAlbum == nil {
+		return nil
+	}
+	ref := task.SharedAlbumRef{ShareToken: job.SharedAlbum.ShareToken, Title: job.SharedAlbum.Title}
+	for account, dest := range destinations {
+		album, err := dest.service.sharedAlbums.Resolve(ctx, ref)
+		if err != nil {
+			return fmt.Errorf("shared album %s of folder '%s' could not be used by account '%s': %w", ref, job.SourceFolder, account, err)
+		}
+		dest.sharedAlbum = album
+		destinations[account] = dest
+		folder.SharedAlbum = album.Title
+	}
 	return nil
 }
 
-func newPhotosService(client *http.Client, sessionTracker app.UploadSessionTracker, logger log.Logger) (*gphotos.Client, error) {
-	u, err := resumable.NewResumableUploader(client, sessionTracker, resumable.WithLogger(logger))
+// jobAccounts returns the accounts where a job uploads files: the account of the job, and the ones of its routes.
+func jobAccounts(account string, routes []upload.Route) []string {
+	accounts := []string{account}
+	for _, r := range routes {
+		if r.Account != "" && !slices.Contains(accounts, r.Account) {
+			accounts = append(accounts, r.Account)
+		}
+	}
+	return accounts
+}
+
+// newUploads returns the uploads of the files using the client, see upload.TokenReusingUploads.
+func (s *accountServices) newUploads(photos *gphotos.Client) *upload.TokenReusingUploads {
+	// media items are created again by the uploads, since the transport doesn't retry them.
+	uploads := photosapi.NewUploads(photos, s.finder, s.cli.MaxRetries(), s.cli.RetryBaseDelay())
+	uploads.Shared = s.sharedTokens
+	return uploads
+}
+
+// flushAlbumBatches adds the files waiting in the album batches of all the accounts.
+func flushAlbumBatches(ctx context.Context, services map[string]*accountServices) {
+	for _, service := range services {
+		service.albumBatch.Flush(ctx)
+	}
+}
+
+// photosWithRateLimit returns a Google Photos client of the account uploading files at the rate, instead of the
+// rate shared by all the accounts. The rate has been validated already.
+func (s *accountServices) photosWithRateLimit(rate string, tracker *progress.Tracker) (*gphotos.Client, error) {
+	bytesPerSecond, err := ratelimit.Parse(rate)
 	if err != nil {
 		return nil, err
 	}
-	return gphotos.NewClient(client, gphotos.WithUploader(u))
+	return newPhotosClient(s.client, s.cli, ratelimit.NewLimiter(bytesPerSecond), tracker)
+}
+
+// newPhotosClient returns a Google Photos client uploading files with the resumable uploader, throttled by the limiter.
+// Requests are sent to the configured PhotosAPIBaseURL, if it's set.
+func newPhotosClient(client *http.Client, cli *app.App, limiter *ratelimit.Limiter, tracker *progress.Tracker) (*gphotos.Client, error) {
+	// the configuration has been validated already.
+	chunkSize, _ := units.ParseSize(cli.Config.UploadChunkSize)
+	return photosapi.NewClient(client, photosapi.Options{
+		BaseURL:    cli.Config.PhotosAPIBaseURL,
+		Sessions:   cli.UploadSessionTracker,
+		Limiter:    limiter,
+		OnProgress: tracker.Transferred,
+		ChunkSize:  chunkSize,
+		FSRetry:    fsRetry(cli.Config),
+		Logger:     cli.Logger,
+	})
+}
+
+// fsRetry returns the retries of the transient file system errors, as set in the configuration.
+func fsRetry(cfg *config.Config) upload.FSRetry {
+	r := upload.FSRetry{Retries: upload.DefaultFSRetries, Delay: upload.DefaultFSRetryDelay}
+	switch {
+	case cfg.FSRetries < 0:
+		r.Retries = 0
+	case cfg.FSRetries > 0:
+		r.Retries = cfg.FSRetries
+	}
+	// the configuration has been validated already.
+	if d, err := time.ParseDuration(cfg.FSRetryDelay); err == nil && d > 0 {
+		r.Delay = d
+	}
+	return r
+}
+
+// numberOfWorkers returns the number of concurrent uploads. The `--workers` flag,
+// if it's set, takes precedence over the configured value.
+func (cmd *PushCmd) numberOfWorkers(cobraCmd *cobra.Command, configured int) int {
+	if cobraCmd.Flags().Changed("workers") || configured < 1 {
+		return cmd.NumberOfWorkers
+	}
+	return configured
+}
+
+// rateLimiter returns the limiter for the uploaded content, nil if it's unlimited. The `--rate-limit` flag,
+// if it's set, takes precedence over the configured value.
+func (cmd *PushCmd) rateLimiter(cobraCmd *cobra.Command, configured string) (*ratelimit.Limiter, error) {
+	value := configured
+	if cobraCmd.Flags().Changed("rate-limit") {
+		value = cmd.RateLimit
+	}
+	bytesPerSecond, err := ratelimit.Parse(value)
+	if err != nil {
+		return nil, err
+	}
+	return ratelimit.NewLimiter(bytesPerSecond), nil
+}
+
+// uploadLimits returns the limits of the files accepted by Google Photos, with the configured sizes.
+func uploadLimits(cfg *config.Config) upload.Limits {
+	limits := upload.DefaultLimits()
+	// the configuration has been validated already.
+	photoSize, _ := units.ParseSize(cfg.MaxPhotoSize)
+	limits.SetMaxSize("photo", photoSize)
+	videoSize, _ := units.ParseSize(cfg.MaxVideoSize)
+	limits.SetMaxSize("video", videoSize)
+	return limits
+}
+
+// albumFilters returns the filters of the albums where the job routes files.
+func albumFilters(albums []config.AlbumMapping) ([]upload.AlbumFilter, error) {
+	var filters []upload.AlbumFilter
+	for _, album := range albums {
+		f, err := upload.NewAlbumFilter(album.Name, album.IncludePatterns, album.ExcludePatterns)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+	}
+	return filters, nil
+}
+
+// jobRoutes returns the routes of the files of the job to its accounts.
+func jobRoutes(cfg *config.Config, job config.FolderUploadJob) ([]upload.Route, error) {
+	var routes []upload.Route
+	for _, route := range job.Routes {
+		account, err := cfg.RouteAccount(job, route)
+		if err != nil {
+			return nil, err
+		}
+		r, err := upload.NewRoute(account, route.Album, route.Match, route.MimeTypes)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, r)
+	}
+	return routes, nil
+}
+
+// jobFilter returns the filter of the files of the job.
+func jobFilter(job config.FolderUploadJob, unicodeForm pathnorm.Form) (*filter.Filter, error) {
+	return filter.CompileWithOptions(job.IncludePatterns, job.ExcludePatterns, filter.FilterOptions{
+		UnicodeForm: unicodeForm,
+		IncludeAll:  job.IncludeAll,
+		MinDepth:    job.MinDepth,
+		MaxDepth:    job.MaxDepth,
+		Logger:      log.WithModule(log.GetInstance(), log.ModuleFilter),
+	})
+}
+
+// newFolderJob returns the scan of the source folder of the job, as set in the configuration.
+func newFolderJob(cli *app.App, job config.FolderUploadJob, limits upload.Limits, minFileAge time.Duration) (upload.UploadFolderJob, error) {
+	filterFiles, err := jobFilter(job, pathnorm.Form(cli.Config.UnicodeNormalization))
+	if err != nil {
+		return upload.UploadFolderJob{}, err
+	}
+	albums, err := albumFilters(job.Albums)
+	if err != nil {
+		return upload.UploadFolderJob{}, err
+	}
+	routes, err := jobRoutes(cli.Config, job)
+	if err != nil {
+		return upload.UploadFolderJob{}, err
+	}
+
+	folder := upload.UploadFolderJob{
+		FileTracker: cli.FileTracker,
+
+		SourceFolder:            job.SourceFolder,
+		CreateAlbums:            job.CreateAlbums,
+		AlbumPathSeparator:      job.AlbumPathSeparator,
+		AlbumHierarchySeparator: job.AlbumHierarchySeparator,
+		AlbumHierarchyDepth:     job.AlbumHierarchyDepth,
+		AlbumDateFormat:         job.AlbumDateFormat,
+		PhotoAlbum:              job.PhotoAlbum,
+		VideoAlbum:              job.VideoAlbum,
+		OtherAlbum:              job.OtherAlbum,
+		DefaultAlbum:            job.DefaultAlbum,
+		FavoritesFolder:         job.FavoritesFolder,
+		FollowSymlinks:          job.FollowSymlinks,
+		IncludeHidden:           job.IncludeHidden,
+		DetectLivePhotos:        job.DetectLivePhotos,
+		Filter:                  filterFiles,
+		Albums:                  albums,
+		Routes:                  routes,
+		Limits:                  limits,
+		OnUnsupported:           upload.UnsupportedPolicy(cli.Config.OnUnsupported),
+		MIMEDetection:           upload.MIMEDetection(cli.Config.MIMEDetection),
+		ScanWorkers:             cli.Config.ScanWorkerCount,
+		MinFileAge:              minFileAge,
+		MinWidth:                job.MinWidth,
+		MinHeight:               job.MinHeight,
+	}
+	if job.AlbumNameTemplate != "" {
+		// the configuration has been validated already.
+		folder.AlbumNameTemplate, _ = upload.NewAlbumNameTemplate(job.AlbumNameTemplate)
+		folder.AlbumNameTemplate.HierarchySeparator = job.AlbumHierarchySeparator
+		folder.AlbumNameTemplate.HierarchyDepth = job.AlbumHierarchyDepth
+	}
+	if len(job.AllowedMimeTypes) > 0 || len(job.ExcludedMimeTypes) > 0 {
+		// the configuration has been validated already.
+		folder.MIMEFilter, _ = filter.NewMIMEFilter(job.AllowedMimeTypes, job.ExcludedMimeTypes)
+	}
+	if f := job.ExifFilters; f != nil {
+		folder.ExifFilter = exif.Filter{Make: f.Make, Model: f.Model, GPS: f.GPS}
+	}
+	if d := job.DateFromFilename; d != nil {
+		// the configuration has been validated already.
+		folder.FilenameDate, _ = upload.NewFilenameDate(d.Pattern, d.Layout)
+	}
+	return folder, nil
+}
+
+// setAlbumMetadata sets the description and the cover photo of the albums where the job routes files.
+func setAlbumMetadata(service *accountServices, job config.FolderUploadJob) {
+	for _, album := range job.Albums {
+		service.albums.SetDescription(album.Name, album.Description)
+		cover := task.CoverPhoto{Strategy: album.CoverPhotoStrategy}
+		if album.CoverPhotoPath != "" {
+			cover.Path = album.CoverPhotoPath
+			if !filepath.IsAbs(cover.Path) {
+				cover.Path = filepath.Join(job.SourceFolder, cover.Path)
+			}
+		}
+		service.covers.Set(album.Name, cover)
+	}
+}
+
+// runDedups are the RunDedup of a run: the one of all the jobs if DedupWithinRun is set, or the ones of the
+// jobs of every library, see config.FolderUploadJob.SourceFolders, if DedupStrategy is hash. Files skipped are
+// not tracked, so they are only skipped by the next runs if DedupStrategy is hash.
+type runDedups struct {
+	all       *upload.RunDedup
+	libraries map[string]*upload.RunDedup
+	// hashes keeps the hashes of the files checked for the run.
+	hashes *upload.ContentHashes
+}
+
+func newRunDedups(cfg *config.Config, hashes *upload.ContentHashes) *runDedups {
+	d := &runDedups{hashes: hashes}
+	if cfg.DedupWithinRun {
+		d.all = upload.NewRunDedup()
+		d.all.Hashes = hashes
+	} else if cfg.DedupStrategy == "hash" {
+		d.libraries = make(map[string]*upload.RunDedup)
+	}
+	return d
+}
+
+// forJob returns the RunDedup of the job, or nil if its files are not checked.
+func (d *runDedups) forJob(job config.FolderUploadJob) *upload.RunDedup {
+	if d.all != nil || d.libraries == nil || job.Library() == "" {
+		return d.all
+	}
+	dedup, ok := d.libraries[job.Library()]
+	if !ok {
+		dedup = upload.NewRunDedup()
+		dedup.Hashes = d.hashes
+		d.libraries[job.Library()] = dedup
+	}
+	return dedup
+}
+
+// isDuplicate returns true if a file with the same content has been enqueued before in the run.
+// Files that could not be hashed are not considered duplicated.
+func isDuplicate(dedup *upload.RunDedup, path string, logger log.Logger) bool {
+	if dedup == nil {
+		return false
+	}
+	first, ok, err := dedup.Claim(path)
+	if err != nil {
+		logger.Warnf("Unable to check if '%s' is a duplicate of other file: %s", path, err)
+		return false
+	}
+	if ok {
+		return false
+	}
+	logDuplicate(logger, path, first)
+	return true
 }
 
-// getOrCreateAlbum returns the created (or existent) album in PhotosService.
-func getOrCreateAlbum(ctx context.Context, service task.AlbumsService, title string) (string, error) {
-	// Returns if empty to avoid a PhotosService call.
-	if title == "" {
-		return "", nil
+// logDuplicate logs the file skipped because it has the same content than the first one, counting it.
+func logDuplicate(logger log.Logger, path string, first string) {
+	logger.WithFields(log.Fields{"event": log.EventFileSkipped, "path": path, "reason": log.ReasonDuplicate, "duplicate_of": first}).Infof("Skipping file '%s', it has the same content than '%s'.", path, first)
+	metrics.FilesSkipped.Inc(log.ReasonDuplicate)
+}
+
+// fixedAlbum is the album where all the files are added, whatever their album is, e.g. the shared album of a job.
+type fixedAlbum string
+
+func (a fixedAlbum) GetOrCreate(ctx context.Context, title string) (string, error) {
+	return string(a), nil
+}
+
+// walkFolder calls fn for every item to be uploaded in the folder. If an order is set, items are
+// sorted once the whole folder has been scanned, otherwise fn is called as soon as an item is found.
+func walkFolder(ctx context.Context, folder upload.UploadFolderJob, order string, logger log.Logger, fn func(item upload.FileItem)) (upload.WalkStats, error) {
+	if order == "" || order == upload.OrderNone {
+		return folder.WalkFolderContext(ctx, logger, fn)
 	}
 
-	if album, err := service.GetByTitle(ctx, title); err == nil {
-		return album.ID, nil
+	var items []upload.FileItem
+	stats, walkErr := folder.WalkFolderContext(ctx, logger, func(item upload.FileItem) {
+		items = append(items, item)
+	})
+	if err := upload.SortFileItems(items, order); err != nil {
+		return stats, err
+	}
+	for _, item := range items {
+		fn(item)
 	}
+	return stats, walkErr
+}
 
-	album, err := service.Create(ctx, title)
+// progressReporter returns the reporter of the uploads progress. It renders an interactive progress bar
+// when the output is a terminal, and periodic log lines otherwise or if `--no-progress` or `--log-format json` are set.
+func (cmd *PushCmd) progressReporter(tracker *progress.Tracker, logger log.Logger) *progress.Reporter {
+	if !cmd.NoProgress && cmd.LogFormat != "json" && term.IsTerminal(int(os.Stdout.Fd())) {
+		return progress.NewReporter(tracker, progress.NewBarRenderer(os.Stdout), progressBarInterval)
+	}
+	return progress.NewReporter(tracker, progress.NewLogRenderer(logger), progressLogInterval)
+}
+
+// explainFilter prints, for every job, which pattern allows or excludes the given path.
+func (cmd *PushCmd) explainFilter(path string) error {
+	cfg, err := config.FromFiles(Os, configFiles(cmd.GlobalFlags), config.ListMerge(cmd.CfgLists))
 	if err != nil {
-		return "", err
+		return err
 	}
 
-	return album.ID, nil
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	for _, job := range cfg.Jobs {
+		relativePath := upload.RelativePath(job.SourceFolder, absPath)
+		if relativePath == absPath {
+			log.Infof("Job '%s': path is not in the source folder", job.SourceFolder)
+			continue
+		}
+
+		if cmd.NoFilter {
+			job.IncludeAll = true
+		}
+		filterFiles, err := jobFilter(job, pathnorm.Form(cfg.UnicodeNormalization))
+		if err != nil {
+			return err
+		}
+
+		log.Infof("Job '%s': '%s' is %s", job.SourceFolder, relativePath, filterFiles.Explain(relativePath))
+	}
+	return nil
 }
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/datastore/retryqueue"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/notify"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/report"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/runstats"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/task"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/upload"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/worker"
+)
+
+func TestRunSummary_PermissionErrors(t *testing.T) {
+	run := newRunSummary(runstats.New())
+
+	// the first folder has an unreadable file, and the second one an unreadable directory.
+	run.addWalkStats(upload.WalkStats{Found: 2, SkippedUnreadable: 1})
+	run.addWalkStats(upload.WalkStats{Found: 1, SkippedFiltered: 1, SkippedUnreadable: 1})
+
+	s := run.Summary()
+	if s.PermissionErrors != 2 {
+		t.Errorf("want: %d, got: %d", 2, s.PermissionErrors)
+	}
+	// unreadable files are skipped, they don't fail the run.
+	if s.Scanned != 6 || s.Skipped != 3 || s.Failed != 0 {
+		t.Errorf("want: %d scanned, %d skipped and %d failed, got: %+v", 6, 3, 0, s)
+	}
+}
+
+func TestRunSummary_AttachFailures(t *testing.T) {
+	run := newRunSummary(runstats.New())
+
+	run.addAttachFailure("IMG_0001.jpg", &task.PartialBatchError{Err: errors.New("invalid media item")})
+	run.addAttachFailure("IMG_0002.jpg", upload.ErrQuotaExceeded)
+
+	s := run.Summary()
+	if len(s.PartialBatchErrors) != 1 || s.PartialBatchErrors[0] != "IMG_0001.jpg: invalid media item (other media items of the batch have been added)" {
+		t.Errorf("want: %s partial batch error, got: %v", "IMG_0001.jpg", s.PartialBatchErrors)
+	}
+	if len(s.Errors) != 1 || s.Errors[0] != "IMG_0002.jpg: "+upload.ErrQuotaExceeded.Error() {
+		t.Errorf("want: %s error, got: %v", "IMG_0002.jpg", s.Errors)
+	}
+	if s.FailureReasons[log.ReasonPartialBatch] != 1 || s.FailureReasons[log.ReasonQuotaExceeded] != 1 {
+		t.Errorf("want: a failure of every reason, got: %v", s.FailureReasons)
+	}
+}
+
+func TestRunSummary_SkippedByCategory(t *testing.T) {
+	run := newRunSummary(runstats.New())
+	run.skippedSamples = 2
+
+	run.addSkipped("IMG_0001.jpg", log.ReasonAlreadyUploaded)
+	run.addSkipped("IMG_0002.jpg", log.ReasonNearDuplicate)
+	run.addSkipped("IMG_0003.jpg", log.ReasonAlreadyUploaded)
+	run.addSkipped(".IMG_0004.jpg", log.ReasonHidden)
+	run.addSkipped("notes.txt", log.ReasonUnsupportedType)
+	run.reporter(nil).Report(task.UploadResult{Path: "IMG_0005.jpg", Status: report.StatusSkipped, Reason: log.ReasonInLibrary})
+	run.reporter(nil).Report(task.UploadResult{Path: "IMG_0006.jpg", Status: report.StatusUploaded})
+	run.addResult(worker.JobResult{ID: "IMG_0007.jpg", Err: context.Canceled})
+
+	want := map[notify.SkipCategory]notify.SkippedFiles{
+		notify.SkipAlreadyUploaded: {Count: 4, Samples: []string{"IMG_0001.jpg", "IMG_0002.jpg"}},
+		notify.SkipFiltered:        {Count: 1, Samples: []string{".IMG_0004.jpg"}},
+		notify.SkipUnsupported:     {Count: 1, Samples: []string{"notes.txt"}},
+		notify.SkipInterrupted:     {Count: 1, Samples: []string{"IMG_0007.jpg"}},
+	}
+	if got := run.Summary().SkippedByCategory; !reflect.DeepEqual(want, got) {
+		t.Errorf("want: %v, got: %v", want, got)
+	}
+
+	// samples are not kept if they are disabled.
+	run = newRunSummary(runstats.New())
+	run.skippedSamples = 0
+	run.addSkipped("IMG_0001.jpg", log.ReasonAlreadyUploaded)
+	if got := run.Summary().SkippedByCategory[notify.SkipAlreadyUploaded]; got.Count != 1 || len(got.Samples) != 0 {
+		t.Errorf("want: 1 file without samples, got: %+v", got)
+	}
+}
+
+func TestRetries_RecordAttachFailures(t *testing.T) {
+	queue, err := retryqueue.Open(filepath.Join(t.TempDir(), "retries.db"), 0)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	defer queue.Close()
+	retry := newRetries(queue, log.Discard, false)
+
+	// the uploads of the files succeed once they are enqueued in the batch, then one of them is not added.
+	for _, path := range []string{"IMG_0001.jpg", "IMG_0002.jpg", "IMG_0003.jpg"} {
+		retry.claim(path)
+	}
+	retry.attachFailed("IMG_0002.jpg", &task.PartialBatchError{Err: errors.New("invalid media item")})
+	for _, path := range []string{"IMG_0001.jpg", "IMG_0002.jpg", "IMG_0003.jpg"} {
+		retry.record(worker.JobResult{ID: path})
+	}
+	retry.recordAttachFailures()
+
+	entries, err := queue.Pending()
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if len(entries) != 1 || entries[0].Path != "IMG_0002.jpg" {
+		t.Errorf("want: %s to be retried, got: %v", "IMG_0002.jpg", entries)
+	}
+	// failures are recorded once.
+	retry.recordAttachFailures()
+	if e, _, _ := queue.Get("IMG_0002.jpg"); e.Attempts != 1 {
+		t.Errorf("want: %d, got: %d", 1, e.Attempts)
+	}
+}
@@ -0,0 +1,1581 @@
+package cmd_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/app"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/cmd"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/cmd/flags"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/datastore/tokenmanager"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/notify"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/photosapi/photosapitest"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/report"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/runlock"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/tempdir"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/upload"
+)
+
+func TestNewPushCmd_PhotosAPIBaseURL(t *testing.T) {
+	dir, err := ioutil.TempDir("", "push")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	api := photosapitest.NewServer()
+	defer api.Close()
+	createTestEnvironment(t, dir, filepath.Join(dir, "photos"), api.URL)
+	// the content starts as a JPEG file, in order to be accepted by the content type checks.
+	photo := "\xff\xd8\xff\xe0\x00\x10JFIF\x00photo"
+	if err := ioutil.WriteFile(filepath.Join(dir, "photos", "IMG_0001.jpg"), []byte(photo), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Setenv(testTokenEnvVar, `{"access_token":"access-token","token_type":"Bearer","expiry":"2999-01-01T00:00:00Z"}`); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv(testTokenEnvVar)
+
+	// the second run doesn't upload the file again, since it has been tracked.
+	for i := 0; i < 2; i++ {
+		c := cmd.NewPushCmd(&flags.GlobalFlags{CfgDir: filepath.Join(dir, "config")})
+		c.SetArgs([]string{})
+		if err := c.Execute(); err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+	}
+
+	api.Lock()
+	defer api.Unlock()
+	if len(api.Uploaded) != 1 || api.Uploaded[0] != photo {
+		t.Errorf("want: [%q], got: %q", photo, api.Uploaded)
+	}
+	if len(api.Created) != 1 || api.Created[0] != "upload-token-1" {
+		t.Errorf("want: [upload-token-1], got: %v", api.Created)
+	}
+	if len(api.Unexpected) > 0 {
+		t.Errorf("unexpected requests: %v", api.Unexpected)
+	}
+}
+
+func TestNewPushCmd_LostCreateResponse(t *testing.T) {
+	dir, err := ioutil.TempDir("", "push")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the media item is created, but the response of the request is lost.
+	api := photosapitest.NewServer()
+	api.DropCreates = 1
+	defer api.Close()
+	createTestEnvironment(t, dir, filepath.Join(dir, "photos"), api.URL)
+	filename := filepath.Join(dir, "config", app.DefaultConfigFilename)
+	cfg, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg = []byte(strings.Replace(string(cfg), `Jobs: [`, "RetryBaseDelay: \"1ms\"\n  Jobs: [", 1))
+	if err := ioutil.WriteFile(filename, cfg, 0600); err != nil {
+		t.Fatal(err)
+	}
+	photo := "\xff\xd8\xff\xe0\x00\x10JFIF\x00photo"
+	if err := ioutil.WriteFile(filepath.Join(dir, "photos", "IMG_0001.jpg"), []byte(photo), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Setenv(testTokenEnvVar, `{"access_token":"access-token","token_type":"Bearer","expiry":"2999-01-01T00:00:00Z"}`); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv(testTokenEnvVar)
+
+	c := cmd.NewPushCmd(&flags.GlobalFlags{CfgDir: filepath.Join(dir, "config")})
+	c.SetArgs([]string{})
+	if err := c.Execute(); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	// the request is not sent again by the transport, the media item is found in the library instead.
+	api.Lock()
+	defer api.Unlock()
+	if len(api.Created) != 1 {
+		t.Errorf("want: [upload-token-1], got: %v", api.Created)
+	}
+	searched := false
+	for _, r := range api.Requests {
+		searched = searched || r == "POST /v1/mediaItems:search"
+	}
+	if !searched {
+		t.Errorf("want: library searched, got: %v", api.Requests)
+	}
+	if len(api.Unexpected) > 0 {
+		t.Errorf("unexpected requests: %v", api.Unexpected)
+	}
+}
+
+func TestNewPushCmd_ProxyURL(t *testing.T) {
+	dir, err := ioutil.TempDir("", "push")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the fake API is the proxy of the requests to the API host, which doesn't exist.
+	api := photosapitest.NewServer()
+	defer api.Close()
+	createTestEnvironment(t, dir, filepath.Join(dir, "photos"), "http://photoslibrary.example.com")
+	filename := filepath.Join(dir, "config", app.DefaultConfigFilename)
+	cfg, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg = []byte(strings.Replace(string(cfg), `Jobs: [`, fmt.Sprintf("ProxyURL: %q\n  Jobs: [", api.URL), 1))
+	if err := ioutil.WriteFile(filename, cfg, 0600); err != nil {
+		t.Fatal(err)
+	}
+	photo := "\xff\xd8\xff\xe0\x00\x10JFIF\x00photo"
+	if err := ioutil.WriteFile(filepath.Join(dir, "photos", "IMG_0001.jpg"), []byte(photo), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Setenv(testTokenEnvVar, `{"access_token":"access-token","token_type":"Bearer","expiry":"2999-01-01T00:00:00Z"}`); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv(testTokenEnvVar)
+
+	c := cmd.NewPushCmd(&flags.GlobalFlags{CfgDir: filepath.Join(dir, "config")})
+	c.SetArgs([]string{})
+	if err := c.Execute(); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	api.Lock()
+	defer api.Unlock()
+	if len(api.Uploaded) != 1 || len(api.Created) != 1 {
+		t.Errorf("want: 1 upload, got: %q, %v", api.Uploaded, api.Created)
+	}
+	// the upload session URL is the one of the fake API, so only the other requests are proxied.
+	if len(api.Proxied) == 0 {
+		t.Errorf("want: proxied requests, got: none")
+	}
+	for _, host := range api.Proxied {
+		if host != "photoslibrary.example.com" {
+			t.Errorf("want: %s, got: %s", "photoslibrary.example.com", host)
+		}
+	}
+}
+
+func TestNewPushCmd_OnUnsupported(t *testing.T) {
+	var testCases = []struct {
+		policy  string
+		wantErr bool
+	}{
+		{"skip", false},
+		{"warn", false},
+		{"fail", true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.policy, func(t *testing.T) {
+			dir := t.TempDir()
+			api := photosapitest.NewServer()
+			defer api.Close()
+			createTestEnvironment(t, dir, filepath.Join(dir, "photos"), api.URL)
+			filename := filepath.Join(dir, "config", app.DefaultConfigFilename)
+			cfg, err := ioutil.ReadFile(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg = []byte(strings.Replace(string(cfg), `Jobs: [`, fmt.Sprintf("OnUnsupported: %s\n  Jobs: [", tc.policy), 1))
+			if err := ioutil.WriteFile(filename, cfg, 0600); err != nil {
+				t.Fatal(err)
+			}
+			photo := "\xff\xd8\xff\xe0\x00\x10JFIF\x00photo"
+			if err := ioutil.WriteFile(filepath.Join(dir, "photos", "IMG_0001.jpg"), []byte(photo), 0600); err != nil {
+				t.Fatal(err)
+			}
+			// text files are not supported by Google Photos.
+			if err := ioutil.WriteFile(filepath.Join(dir, "photos", "notes.txt"), []byte("notes"), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Setenv(testTokenEnvVar, `{"access_token":"access-token","token_type":"Bearer","expiry":"2999-01-01T00:00:00Z"}`); err != nil {
+				t.Fatal(err)
+			}
+			defer os.Unsetenv(testTokenEnvVar)
+
+			c := cmd.NewPushCmd(&flags.GlobalFlags{CfgDir: filepath.Join(dir, "config")})
+			c.SetArgs([]string{})
+			err = c.Execute()
+			if tc.wantErr && !errors.Is(err, upload.ErrUnsupportedFile) {
+				t.Errorf("want: %v, got: %v", upload.ErrUnsupportedFile, err)
+			}
+			if !tc.wantErr && err != nil {
+				t.Errorf("error was not expected at this point: %s", err)
+			}
+
+			// the photo found before the unsupported file is uploaded in any case, the unsupported file never is.
+			api.Lock()
+			defer api.Unlock()
+			if len(api.Uploaded) != 1 || api.Uploaded[0] != photo {
+				t.Errorf("want: [%q], got: %q", photo, api.Uploaded)
+			}
+		})
+	}
+}
+
+func TestNewPushCmd_SourceFolders(t *testing.T) {
+	dir, err := ioutil.TempDir("", "push")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	api := photosapitest.NewServer()
+	defer api.Close()
+	createTestEnvironment(t, dir, filepath.Join(dir, "photos"), api.URL)
+	filename := filepath.Join(dir, "config", app.DefaultConfigFilename)
+	cfg, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := []string{filepath.Join(dir, "disk-1"), filepath.Join(dir, "disk-2")}
+	cfg = []byte(strings.Replace(string(cfg), fmt.Sprintf(`SourceFolder: %q, CreateAlbums: "Off"`, filepath.Join(dir, "photos")), fmt.Sprintf(`SourceFolders: [%q, %q], CreateAlbums: "folderName"`, roots[0], roots[1]), 1))
+	cfg = []byte(strings.Replace(string(cfg), `Jobs: [`, "DedupStrategy: hash\n  Jobs: [", 1))
+	if err := ioutil.WriteFile(filename, cfg, 0600); err != nil {
+		t.Fatal(err)
+	}
+	// the same photo is in both roots, albums are named after the folders relative to every root.
+	photo := "\xff\xd8\xff\xe0\x00\x10JFIF\x00photo"
+	other := "\xff\xd8\xff\xe0\x00\x10JFIF\x00other"
+	files := map[string]string{
+		filepath.Join(roots[0], "Trip", "IMG_0001.jpg"):  photo,
+		filepath.Join(roots[1], "Trip", "IMG_0001.jpg"):  photo,
+		filepath.Join(roots[1], "Italy", "IMG_0002.jpg"): other,
+	}
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := os.Setenv(testTokenEnvVar, `{"access_token":"access-token","token_type":"Bearer","expiry":"2999-01-01T00:00:00Z"}`); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv(testTokenEnvVar)
+
+	// the copy is not uploaded on the next runs either.
+	for i := 0; i < 2; i++ {
+		c := cmd.NewPushCmd(&flags.GlobalFlags{CfgDir: filepath.Join(dir, "config")})
+		c.SetArgs([]string{"--full-scan"})
+		if err := c.Execute(); err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+	}
+
+	api.Lock()
+	defer api.Unlock()
+	if want := []string{photo, other}; !sameElements(want, api.Uploaded) {
+		t.Errorf("want: %q, got: %q", want, api.Uploaded)
+	}
+	if want := []string{"Trip", "Italy"}; !sameElements(want, api.Albums) {
+		t.Errorf("want: %v, got: %v", want, api.Albums)
+	}
+}
+
+func TestNewPushCmd_RequestHeaders(t *testing.T) {
+	dir, err := ioutil.TempDir("", "push")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	api := photosapitest.NewServer()
+	defer api.Close()
+	createTestEnvironment(t, dir, filepath.Join(dir, "photos"), api.URL)
+	filename := filepath.Join(dir, "config", app.DefaultConfigFilename)
+	cfg, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg = []byte(strings.Replace(string(cfg), `Jobs: [`, "UserAgent: \"uploader/1.0 (nas)\"\n  RequestHeaders: { \"X-Request-Source\": \"nas\" }\n  Jobs: [", 1))
+	if err := ioutil.WriteFile(filename, cfg, 0600); err != nil {
+		t.Fatal(err)
+	}
+	photo := "\xff\xd8\xff\xe0\x00\x10JFIF\x00photo"
+	if err := ioutil.WriteFile(filepath.Join(dir, "photos", "IMG_0001.jpg"), []byte(photo), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Setenv(testTokenEnvVar, `{"access_token":"access-token","token_type":"Bearer","expiry":"2999-01-01T00:00:00Z"}`); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv(testTokenEnvVar)
+
+	c := cmd.NewPushCmd(&flags.GlobalFlags{CfgDir: filepath.Join(dir, "config")})
+	c.SetArgs([]string{})
+	if err := c.Execute(); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	api.Lock()
+	defer api.Unlock()
+	if len(api.Uploaded) != 1 || len(api.Created) != 1 {
+		t.Errorf("want: 1 upload, got: %q, %v", api.Uploaded, api.Created)
+	}
+	// every request has the headers, without replacing the authorization.
+	for i, h := range api.Headers {
+		if h.Get("User-Agent") != "uploader/1.0 (nas)" || h.Get("X-Request-Source") != "nas" || h.Get("Authorization") != "Bearer access-token" {
+			t.Errorf("want: configured headers, got: %s %v", api.Requests[i], h)
+		}
+	}
+}
+
+func TestNewPushCmd_ChangedSince(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir, err := ioutil.TempDir("", "push")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	api := photosapitest.NewServer()
+	defer api.Close()
+	photos := filepath.Join(dir, "photos")
+	createTestEnvironment(t, dir, photos, api.URL)
+	git := func(args ...string) {
+		t.Helper()
+		c := exec.Command("git", append([]string{"-C", photos, "-c", "user.name=test", "-c", "user.email=test@domain.com", "-c", "commit.gpgsign=false"}, args...)...)
+		if out, err := c.CombinedOutput(); err != nil {
+			t.Fatalf("git %v has failed: %s, %s", args, err, out)
+		}
+	}
+	writePhotos := func(files map[string]string) {
+		t.Helper()
+		for name, content := range files {
+			if err := ioutil.WriteFile(filepath.Join(photos, name), []byte(content), 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	writePhotos(map[string]string{
+		"IMG_0002.jpg": "\xff\xd8\xff\xe0\x00\x10JFIF\x00photo 2",
+		"IMG_0003.jpg": "\xff\xd8\xff\xe0\x00\x10JFIF\x00photo 3",
+	})
+	git("init", "-q")
+	git("add", ".")
+	git("commit", "-q", "-m", "first")
+	git("tag", "v1")
+	// only the edited photo, and the added one, have changed since the tag.
+	changed := map[string]string{
+		"IMG_0002.jpg": "\xff\xd8\xff\xe0\x00\x10JFIF\x00edited photo 2",
+		"IMG_0004.jpg": "\xff\xd8\xff\xe0\x00\x10JFIF\x00photo 4",
+	}
+	writePhotos(changed)
+	git("add", ".")
+	git("commit", "-q", "-m", "second")
+
+	if err := os.Setenv(testTokenEnvVar, `{"access_token":"access-token","token_type":"Bearer","expiry":"2999-01-01T00:00:00Z"}`); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv(testTokenEnvVar)
+
+	c := cmd.NewPushCmd(&flags.GlobalFlags{CfgDir: filepath.Join(dir, "config")})
+	c.SetArgs([]string{"--changed-since", "v1"})
+	if err := c.Execute(); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	api.Lock()
+	if want := []string{changed["IMG_0002.jpg"], changed["IMG_0004.jpg"]}; !sameElements(want, api.Uploaded) {
+		t.Errorf("want: %q, got: %q", want, api.Uploaded)
+	}
+	api.Unlock()
+
+	// the location must be in a git repository.
+	if err := os.RemoveAll(filepath.Join(photos, ".git")); err != nil {
+		t.Fatal(err)
+	}
+	c = cmd.NewPushCmd(&flags.GlobalFlags{CfgDir: filepath.Join(dir, "config")})
+	c.SetArgs([]string{"--changed-since", "v1"})
+	err = c.Execute()
+	if err == nil || !strings.Contains(err.Error(), "not in a git repository") {
+		t.Errorf("want: not in a git repository error, got: %v", err)
+	}
+}
+
+func TestNewPushCmd_NoFilter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "push")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	api := photosapitest.NewServer()
+	defer api.Close()
+	createTestEnvironment(t, dir, filepath.Join(dir, "photos"), api.URL)
+	// the photo doesn't match the include patterns of the job.
+	filename := filepath.Join(dir, "config", app.DefaultConfigFilename)
+	cfg, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg = []byte(strings.Replace(string(cfg), `IncludePatterns: ["_ALL_FILES_"]`, `IncludePatterns: ["**/*.png"]`, 1))
+	if err := ioutil.WriteFile(filename, cfg, 0600); err != nil {
+		t.Fatal(err)
+	}
+	photo := "\xff\xd8\xff\xe0\x00\x10JFIF\x00photo"
+	if err := ioutil.WriteFile(filepath.Join(dir, "photos", "IMG_0001.jpg"), []byte(photo), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Setenv(testTokenEnvVar, `{"access_token":"access-token","token_type":"Bearer","expiry":"2999-01-01T00:00:00Z"}`); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv(testTokenEnvVar)
+
+	// the photo is only uploaded once the include patterns are bypassed.
+	for i, args := range [][]string{{}, {"--no-filter"}} {
+		c := cmd.NewPushCmd(&flags.GlobalFlags{CfgDir: filepath.Join(dir, "config")})
+		c.SetArgs(args)
+		if err := c.Execute(); err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+		api.Lock()
+		uploaded := len(api.Uploaded)
+		api.Unlock()
+		if uploaded != i {
+			t.Errorf("want: %d uploads, got: %d", i, uploaded)
+		}
+	}
+}
+
+func TestNewPushCmd_TempDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "push")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	api := photosapitest.NewServer()
+	defer api.Close()
+	createTestEnvironment(t, dir, filepath.Join(dir, "photos"), api.URL)
+	photo := "\xff\xd8\xff\xe0\x00\x10JFIF\x00photo"
+	if err := ioutil.WriteFile(filepath.Join(dir, "photos", "IMG_0001.jpg"), []byte(photo), 0600); err != nil {
+		t.Fatal(err)
+	}
+	// the folder left by a crashed run is removed.
+	tempDir := filepath.Join(dir, "tmp")
+	stale := filepath.Join(tempDir, tempdir.Prefix+"crashed")
+	if err := os.MkdirAll(filepath.Join(stale, "gphotos-convert"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Now().Add(-2 * tempdir.StaleAge)
+	if err := os.Chtimes(stale, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Setenv(testTokenEnvVar, `{"access_token":"access-token","token_type":"Bearer","expiry":"2999-01-01T00:00:00Z"}`); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv(testTokenEnvVar)
+
+	c := cmd.NewPushCmd(&flags.GlobalFlags{CfgDir: filepath.Join(dir, "config")})
+	c.SetArgs([]string{"--temp-dir", tempDir})
+	if err := c.Execute(); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if entries, err := ioutil.ReadDir(tempDir); err != nil || len(entries) != 0 {
+		t.Errorf("want: empty %s, got: %v, err: %v", tempDir, entries, err)
+	}
+
+	// the run fails before uploading anything if the folder is not writable.
+	c = cmd.NewPushCmd(&flags.GlobalFlags{CfgDir: filepath.Join(dir, "config")})
+	c.SetArgs([]string{"--temp-dir", filepath.Join(dir, "missing")})
+	if err := c.Execute(); err == nil {
+		t.Errorf("error was expected, but not produced")
+	}
+}
+
+func TestNewPushCmd_SummaryFormat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "push")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	api := photosapitest.NewServer()
+	defer api.Close()
+	createTestEnvironment(t, dir, filepath.Join(dir, "photos"), api.URL)
+	photo := "\xff\xd8\xff\xe0\x00\x10JFIF\x00photo"
+	if err := ioutil.WriteFile(filepath.Join(dir, "photos", "IMG_0001.jpg"), []byte(photo), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Setenv(testTokenEnvVar, `{"access_token":"access-token","token_type":"Bearer","expiry":"2999-01-01T00:00:00Z"}`); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv(testTokenEnvVar)
+
+	var out bytes.Buffer
+	c := cmd.NewPushCmd(&flags.GlobalFlags{CfgDir: filepath.Join(dir, "config")})
+	c.SetOut(&out)
+	c.SetArgs([]string{"--summary-format", "json"})
+	if err := c.Execute(); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	var got notify.Report
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("error was not expected at this point: %s, output: %s", err, out.String())
+	}
+	if got.Version != notify.ReportVersion || got.Status != notify.OnSuccess || got.Scanned != 1 || got.Uploaded != 1 {
+		t.Errorf("want: 1 file scanned and uploaded, got: %+v", got)
+	}
+
+	c = cmd.NewPushCmd(&flags.GlobalFlags{CfgDir: filepath.Join(dir, "config")})
+	c.SetArgs([]string{"--summary-format", "xml"})
+	if err := c.Execute(); err == nil {
+		t.Errorf("error was expected, but not produced")
+	}
+}
+
+func TestNewPushCmd_SkippedByCategory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "push")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	api := photosapitest.NewServer()
+	defer api.Close()
+	createTestEnvironment(t, dir, filepath.Join(dir, "photos"), api.URL)
+	files := map[string]string{
+		"IMG_0001.jpg":  "\xff\xd8\xff\xe0\x00\x10JFIF\x00photo1",
+		"IMG_0002.jpg":  "\xff\xd8\xff\xe0\x00\x10JFIF\x00photo2",
+		".IMG_0003.jpg": "\xff\xd8\xff\xe0\x00\x10JFIF\x00photo3",
+		".IMG_0004.jpg": "\xff\xd8\xff\xe0\x00\x10JFIF\x00photo4",
+		".IMG_0005.jpg": "\xff\xd8\xff\xe0\x00\x10JFIF\x00photo5",
+		"notes.txt":     "not a photo",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, "photos", name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := os.Setenv(testTokenEnvVar, `{"access_token":"access-token","token_type":"Bearer","expiry":"2999-01-01T00:00:00Z"}`); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv(testTokenEnvVar)
+
+	push := func() notify.Report {
+		var out bytes.Buffer
+		c := cmd.NewPushCmd(&flags.GlobalFlags{CfgDir: filepath.Join(dir, "config")})
+		c.SetOut(&out)
+		c.SetArgs([]string{"--summary-format", "json", "--skipped-samples", "2"})
+		if err := c.Execute(); err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+		var got notify.Report
+		if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+			t.Fatalf("error was not expected at this point: %s, output: %s", err, out.String())
+		}
+		return got
+	}
+
+	// the hidden files are filtered, and the samples of the category are capped.
+	got := push()
+	filtered := got.SkippedByCategory[notify.SkipFiltered]
+	if filtered.Count != 3 || len(filtered.Samples) != 2 {
+		t.Errorf("want: 3 filtered files and 2 samples, got: %+v", filtered)
+	}
+	unsupported := got.SkippedByCategory[notify.SkipUnsupported]
+	if unsupported.Count != 1 || len(unsupported.Samples) != 1 || unsupported.Samples[0] != filepath.Join(dir, "photos", "notes.txt") {
+		t.Errorf("want: %s unsupported, got: %+v", "notes.txt", unsupported)
+	}
+	if len(got.SkippedByCategory) != 2 {
+		t.Errorf("want: 2 categories, got: %+v", got.SkippedByCategory)
+	}
+
+	// the uploaded files are skipped on the next run.
+	got = push()
+	if uploaded := got.SkippedByCategory[notify.SkipAlreadyUploaded]; uploaded.Count != 2 || len(uploaded.Samples) != 2 {
+		t.Errorf("want: 2 files uploaded already, got: %+v", uploaded)
+	}
+	if want := got.SkippedByCategory[notify.SkipAlreadyUploaded].Count + got.SkippedByCategory[notify.SkipFiltered].Count + got.SkippedByCategory[notify.SkipUnsupported].Count; got.Skipped != want {
+		t.Errorf("want: %d skipped, got: %d", want, got.Skipped)
+	}
+}
+
+func TestNewPushCmd_Estimate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "push")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	api := photosapitest.NewServer()
+	defer api.Close()
+	createTestEnvironment(t, dir, filepath.Join(dir, "photos"), api.URL)
+	// hidden files are skipped by the estimate, like by the upload.
+	photo := "\xff\xd8\xff\xe0\x00\x10JFIF\x00photo"
+	for _, name := range []string{"IMG_0001.jpg", "IMG_0002.jpg", ".IMG_0003.jpg"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, "photos", name), []byte(photo), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := os.Setenv(testTokenEnvVar, `{"access_token":"access-token","token_type":"Bearer","expiry":"2999-01-01T00:00:00Z"}`); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv(testTokenEnvVar)
+
+	testCases := []struct {
+		name         string
+		args         []string
+		input        string
+		wantUploaded int
+	}{
+		{"Should not upload if it's not confirmed", []string{"--estimate"}, "n\n", 0},
+		{"Should not upload if there is no answer", []string{"--estimate"}, "", 0},
+		{"Should upload if it's confirmed", []string{"--estimate"}, "y\n", 2},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			c := cmd.NewPushCmd(&flags.GlobalFlags{CfgDir: filepath.Join(dir, "config")})
+			c.SetOut(&out)
+			c.SetIn(strings.NewReader(tc.input))
+			c.SetArgs(tc.args)
+			if err := c.Execute(); err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			api.Lock()
+			uploaded := len(api.Uploaded)
+			api.Unlock()
+			if tc.wantUploaded != uploaded {
+				t.Errorf("want: %d uploads, got: %d", tc.wantUploaded, uploaded)
+			}
+			if want := "This will upload 2 files"; !strings.Contains(out.String(), want) {
+				t.Errorf("want: %q, got: %q", want, out.String())
+			}
+		})
+	}
+
+	// tracked files are not counted, and the estimate matches the files uploaded, without asking with --yes.
+	if err := ioutil.WriteFile(filepath.Join(dir, "photos", "IMG_0004.jpg"), []byte(photo), 0600); err != nil {
+		t.Fatal(err)
+	}
+	api.Lock()
+	api.Uploaded = nil
+	api.Unlock()
+	var out bytes.Buffer
+	c := cmd.NewPushCmd(&flags.GlobalFlags{CfgDir: filepath.Join(dir, "config")})
+	c.SetOut(&out)
+	c.SetIn(strings.NewReader(""))
+	c.SetArgs([]string{"--estimate", "--yes", "--full-scan"})
+	if err := c.Execute(); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	api.Lock()
+	uploaded := len(api.Uploaded)
+	api.Unlock()
+	if want := fmt.Sprintf("This will upload %d files", uploaded); uploaded != 1 || !strings.Contains(out.String(), want) {
+		t.Errorf("want: %q for 1 upload, got: %q for %d uploads", want, out.String(), uploaded)
+	}
+}
+
+func TestNewPushCmd_MaxDuration(t *testing.T) {
+	dir, err := ioutil.TempDir("", "push")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	api := photosapitest.NewServer()
+	defer api.Close()
+	api.UploadDelay = 400 * time.Millisecond
+	createTestEnvironment(t, dir, filepath.Join(dir, "photos"), api.URL)
+	photo := "\xff\xd8\xff\xe0\x00\x10JFIF\x00photo"
+	var files []string
+	for i := 1; i <= 5; i++ {
+		content := fmt.Sprintf("%s-%d", photo, i)
+		if err := ioutil.WriteFile(filepath.Join(dir, "photos", fmt.Sprintf("IMG_000%d.jpg", i)), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, content)
+	}
+
+	if err := os.Setenv(testTokenEnvVar, `{"access_token":"access-token","token_type":"Bearer","expiry":"2999-01-01T00:00:00Z"}`); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv(testTokenEnvVar)
+
+	// the run stops cleanly once the max duration is reached, the upload in progress is finished. The uploads start
+	// a second after the run, and take 2s.
+	var out bytes.Buffer
+	c := cmd.NewPushCmd(&flags.GlobalFlags{CfgDir: filepath.Join(dir, "config")})
+	c.SetOut(&out)
+	c.SetArgs([]string{"--max-duration", "1500ms", "--workers", "1", "--summary-format", "json"})
+	if err := c.Execute(); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	var got notify.Report
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("error was not expected at this point: %s, output: %s", err, out.String())
+	}
+	if !got.MaxDurationReached || got.Status != notify.OnSuccess || got.Failed != 0 {
+		t.Errorf("want: max duration reached without failures, got: %+v", got)
+	}
+	api.Lock()
+	first := append([]string(nil), api.Uploaded...)
+	if len(first) == 0 || len(first) >= len(files) || len(api.Created) != len(first) {
+		t.Errorf("want: some files uploaded and created, got: %d uploaded, %d created", len(first), len(api.Created))
+	}
+	api.Unlock()
+
+	// the next run uploads the other files only, since the uploaded ones have been tracked.
+	api.UploadDelay = 0
+	c = cmd.NewPushCmd(&flags.GlobalFlags{CfgDir: filepath.Join(dir, "config")})
+	c.SetArgs([]string{})
+	if err := c.Execute(); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	api.Lock()
+	defer api.Unlock()
+	if !sameElements(files, api.Uploaded) {
+		t.Errorf("want: %q, got: %q", files, api.Uploaded)
+	}
+	if len(api.Unexpected) > 0 {
+		t.Errorf("unexpected requests: %v", api.Unexpected)
+	}
+
+	c = cmd.NewPushCmd(&flags.GlobalFlags{CfgDir: filepath.Join(dir, "config")})
+	c.SetArgs([]string{"--max-duration", "-1s"})
+	if err := c.Execute(); err == nil {
+		t.Errorf("error was expected, but not produced")
+	}
+}
+
+func TestNewPushCmd_WatchFilesCreatedWhileUploading(t *testing.T) {
+	dir, err := ioutil.TempDir("", "push")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	api := photosapitest.NewServer()
+	defer api.Close()
+	api.UploadDelay = 500 * time.Millisecond
+	createTestEnvironment(t, dir, filepath.Join(dir, "photos"), api.URL)
+	photo := "\xff\xd8\xff\xe0\x00\x10JFIF\x00photo"
+	existing, created := photo+"-existing", photo+"-created"
+	if err := ioutil.WriteFile(filepath.Join(dir, "photos", "IMG_0001.jpg"), []byte(existing), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Setenv(testTokenEnvVar, `{"access_token":"access-token","token_type":"Bearer","expiry":"2999-01-01T00:00:00Z"}`); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv(testTokenEnvVar)
+
+	c := cmd.NewPushCmd(&flags.GlobalFlags{CfgDir: filepath.Join(dir, "config")})
+	c.SetArgs([]string{"--watch", "--watch-interval", "20ms", "--max-duration", "3s"})
+	done := make(chan error)
+	go func() {
+		done <- c.Execute()
+	}()
+
+	// the file is created while the existing one is being uploaded, before the folder is watched.
+	deadline := time.Now().Add(5 * time.Second)
+	for !api.UploadStarted() {
+		if time.Now().After(deadline) {
+			t.Fatal("want: upload started, got: not started")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "photos", "IMG_0002.jpg"), []byte(created), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	api.Lock()
+	defer api.Unlock()
+	if want := []string{existing, created}; !sameElements(want, api.Uploaded) {
+		t.Errorf("want: %q, got: %q", want, api.Uploaded)
+	}
+}
+
+func TestNewPushCmd_StorageFull(t *testing.T) {
+	dir, err := ioutil.TempDir("", "push")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	api := photosapitest.NewServer()
+	defer api.Close()
+	api.CreateFailure = "The remaining storage in the user's account is not enough to perform this operation."
+	createTestEnvironment(t, dir, filepath.Join(dir, "photos"), api.URL)
+	photo := "\xff\xd8\xff\xe0\x00\x10JFIF\x00photo"
+	for i := 1; i <= 5; i++ {
+		if err := ioutil.WriteFile(filepath.Join(dir, "photos", fmt.Sprintf("IMG_%04d.jpg", i)), []byte(photo), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := os.Setenv(testTokenEnvVar, `{"access_token":"access-token","token_type":"Bearer","expiry":"2999-01-01T00:00:00Z"}`); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv(testTokenEnvVar)
+
+	// the run is stopped once the first file fails, the other ones are not attempted.
+	c := cmd.NewPushCmd(&flags.GlobalFlags{CfgDir: filepath.Join(dir, "config")})
+	c.SetArgs([]string{"--workers", "1"})
+	if err := c.Execute(); err == nil || !strings.Contains(err.Error(), "storage") {
+		t.Fatalf("want: storage full error, got: %v", err)
+	}
+	api.Lock()
+	if len(api.Uploaded) != 1 {
+		t.Errorf("want: %d uploads, got: %d", 1, len(api.Uploaded))
+	}
+	// none of the files is tracked as uploaded, nor recorded as failed.
+	api.Uploaded = nil
+	api.CreateFailure = ""
+	api.Unlock()
+
+	c = cmd.NewPushCmd(&flags.GlobalFlags{CfgDir: filepath.Join(dir, "config")})
+	c.SetArgs([]string{"--workers", "1"})
+	if err := c.Execute(); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	api.Lock()
+	defer api.Unlock()
+	if len(api.Uploaded) != 5 {
+		t.Errorf("want: %d uploads, got: %d", 5, len(api.Uploaded))
+	}
+}
+
+func TestNewPushCmd_PerFileTimeout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "push")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	api := photosapitest.NewServer()
+	defer api.Close()
+	api.Stalls = 1
+	createTestEnvironment(t, dir, filepath.Join(dir, "photos"), api.URL)
+	photo := "\xff\xd8\xff\xe0\x00\x10JFIF\x00photo"
+	for i := 1; i <= 2; i++ {
+		if err := ioutil.WriteFile(filepath.Join(dir, "photos", fmt.Sprintf("IMG_%04d.jpg", i)), []byte(fmt.Sprintf("%s-%d", photo, i)), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := os.Setenv(testTokenEnvVar, `{"access_token":"access-token","token_type":"Bearer","expiry":"2999-01-01T00:00:00Z"}`); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv(testTokenEnvVar)
+
+	// the hung upload is cancelled and attempted again, the run is not aborted.
+	c := cmd.NewPushCmd(&flags.GlobalFlags{CfgDir: filepath.Join(dir, "config")})
+	c.SetArgs([]string{"--workers", "1", "--per-file-timeout", "200ms"})
+	if err := c.Execute(); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	// closing the server waits for the cancelled request to be handled.
+	api.Close()
+	api.Lock()
+	defer api.Unlock()
+	if api.Cancelled != 1 {
+		t.Errorf("want: %d cancelled uploads, got: %d", 1, api.Cancelled)
+	}
+	if len(api.Uploaded) != 2 {
+		t.Errorf("want: %d uploads, got: %d", 2, len(api.Uploaded))
+	}
+}
+
+func TestNewPushCmd_Routes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "push")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	api := photosapitest.NewServer()
+	defer api.Close()
+	for _, d := range []string{"config", "photos"} {
+		if err := os.MkdirAll(filepath.Join(dir, d), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := map[string]string{
+		"IMG_0001.jpg": "\xff\xd8\xff\xe0\x00\x10JFIF\x00photo-1",
+		"IMG_0002.raf": "FUJIFILMCCD-RAW raw-2",
+		"IMG_0003.CR2": "IIRO raw-3",
+		"IMG_0004.jpg": "\xff\xd8\xff\xe0\x00\x10JFIF\x00photo-4",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, "photos", name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfgContent := fmt.Sprintf(`{
+  ConfigVersion: 2
+  APIAppCredentials: { ClientID: "client-id", ClientSecret: "client-secret" }
+  Account: "youremail@domain.com"
+  Accounts: [
+    { Name: "archive", Account: "archive@domain.com" }
+  ]
+  SecretsBackendType: "auto"
+  TokenStore: "file"
+  PhotosAPIBaseURL: %q
+  Jobs: [
+    {
+      SourceFolder: %q
+      CreateAlbums: "Off"
+      IncludePatterns: ["_ALL_FILES_"]
+      Routes: [
+        { Match: ["_RAW_EXTENSIONS_"], Account: "archive" }
+      ]
+    }
+  ]
+}`, api.URL, filepath.Join(dir, "photos"))
+	if err := ioutil.WriteFile(filepath.Join(dir, "config", app.DefaultConfigFilename), []byte(cfgContent), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// every account has its own token, so the account of the uploads is known by their access token.
+	if err := os.Setenv("GPHOTOS_CLI_TOKENSTORE_KEY", "passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv("GPHOTOS_CLI_TOKENSTORE_KEY")
+	repo, err := tokenmanager.NewFileRepository(filepath.Join(dir, "config", "tokens.enc"), "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := tokenmanager.New(repo)
+	for _, account := range []string{"youremail@domain.com", "archive@domain.com"} {
+		if err := tokens.Put(account, &oauth2.Token{AccessToken: account, TokenType: "Bearer", Expiry: time.Now().Add(time.Hour)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tokens.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	c := cmd.NewPushCmd(&flags.GlobalFlags{CfgDir: filepath.Join(dir, "config")})
+	c.SetArgs([]string{})
+	if err := c.Execute(); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	api.Lock()
+	defer api.Unlock()
+	want := map[string]string{
+		"IMG_0001.jpg": "youremail@domain.com",
+		"IMG_0002.raf": "archive@domain.com",
+		"IMG_0003.CR2": "archive@domain.com",
+		"IMG_0004.jpg": "youremail@domain.com",
+	}
+	for name, account := range want {
+		if got := api.UploadedBy[files[name]]; got != account {
+			t.Errorf("%s: want: %s, got: %s", name, account, got)
+		}
+	}
+	if len(api.Unexpected) > 0 {
+		t.Errorf("unexpected requests: %v", api.Unexpected)
+	}
+}
+
+func TestNewPushCmd_OnlyAlbum(t *testing.T) {
+	dir, err := ioutil.TempDir("", "push")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	api := photosapitest.NewServer()
+	defer api.Close()
+	for _, d := range []string{"config", "photos/Trips", "photos/Family", "photos/Pets"} {
+		if err := os.MkdirAll(filepath.Join(dir, d), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := map[string]string{
+		"Trips/IMG_0001.jpg":  "\xff\xd8\xff\xe0\x00\x10JFIF\x00trips",
+		"Family/IMG_0002.jpg": "\xff\xd8\xff\xe0\x00\x10JFIF\x00family",
+		"Pets/IMG_0003.jpg":   "\xff\xd8\xff\xe0\x00\x10JFIF\x00pets",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, "photos", name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfgContent := fmt.Sprintf(`{
+  ConfigVersion: 2
+  APIAppCredentials: { ClientID: "client-id", ClientSecret: "client-secret" }
+  Account: "youremail@domain.com"
+  SecretsBackendType: "auto"
+  TokenStore: "env"
+  TokenStoreEnvVar: %q
+  PhotosAPIBaseURL: %q
+  Jobs: [
+    {
+      SourceFolder: %q
+      CreateAlbums: "folderName"
+      Albums: [
+        { Name: "Trips", IncludePatterns: ["Trips/**"] }
+        { Name: "Family", IncludePatterns: ["Family/**"] }
+      ]
+    }
+    {
+      SourceFolder: %q
+      CreateAlbums: "folderName"
+      IncludePatterns: ["Pets/**"]
+    }
+  ]
+}`, testTokenEnvVar, api.URL, filepath.Join(dir, "photos"), filepath.Join(dir, "photos"))
+	if err := ioutil.WriteFile(filepath.Join(dir, "config", app.DefaultConfigFilename), []byte(cfgContent), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Setenv(testTokenEnvVar, `{"access_token":"access-token","token_type":"Bearer","expiry":"2999-01-01T00:00:00Z"}`); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv(testTokenEnvVar)
+
+	// the albums of the mappings and of the folder names could be selected.
+	c := cmd.NewPushCmd(&flags.GlobalFlags{CfgDir: filepath.Join(dir, "config")})
+	c.SetArgs([]string{"--only-album", "Family", "--only-album", "Pets"})
+	if err := c.Execute(); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	api.Lock()
+	if want := []string{files["Family/IMG_0002.jpg"], files["Pets/IMG_0003.jpg"]}; !sameElements(want, api.Uploaded) {
+		t.Errorf("want: %q, got: %q", want, api.Uploaded)
+	}
+	if want := []string{"Family", "Pets"}; !sameElements(want, api.Albums) {
+		t.Errorf("want: %v, got: %v", want, api.Albums)
+	}
+	api.Uploaded = nil
+	api.Unlock()
+
+	// the files of the other albums are uploaded by the next run, even if they have not changed.
+	c = cmd.NewPushCmd(&flags.GlobalFlags{CfgDir: filepath.Join(dir, "config")})
+	c.SetArgs([]string{})
+	if err := c.Execute(); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	api.Lock()
+	defer api.Unlock()
+	if want := []string{files["Trips/IMG_0001.jpg"]}; !sameElements(want, api.Uploaded) {
+		t.Errorf("want: %q, got: %q", want, api.Uploaded)
+	}
+	if len(api.Unexpected) > 0 {
+		t.Errorf("unexpected requests: %v", api.Unexpected)
+	}
+}
+
+func TestNewPushCmd_ShareUploadTokens(t *testing.T) {
+	dir, err := ioutil.TempDir("", "push")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	api := photosapitest.NewServer()
+	defer api.Close()
+	// the uploads of both files are in progress at the same time.
+	api.UploadDelay = 200 * time.Millisecond
+	for _, d := range []string{"config", "photos/Trips", "photos/Family"} {
+		if err := os.MkdirAll(filepath.Join(dir, d), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	photo := "\xff\xd8\xff\xe0\x00\x10JFIF\x00photo"
+	for _, name := range []string{"Trips/IMG_0001.jpg", "Family/IMG_0001.jpg"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, "photos", name), []byte(photo), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfgContent := fmt.Sprintf(`{
+  ConfigVersion: 2
+  APIAppCredentials: { ClientID: "client-id", ClientSecret: "client-secret" }
+  Account: "youremail@domain.com"
+  SecretsBackendType: "auto"
+  TokenStore: "env"
+  TokenStoreEnvVar: %q
+  PhotosAPIBaseURL: %q
+  ShareUploadTokens: true
+  Jobs: [
+    {
+      SourceFolder: %q
+      CreateAlbums: "folderName"
+    }
+  ]
+}`, testTokenEnvVar, api.URL, filepath.Join(dir, "photos"))
+	if err := ioutil.WriteFile(filepath.Join(dir, "config", app.DefaultConfigFilename), []byte(cfgContent), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Setenv(testTokenEnvVar, `{"access_token":"access-token","token_type":"Bearer","expiry":"2999-01-01T00:00:00Z"}`); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv(testTokenEnvVar)
+
+	c := cmd.NewPushCmd(&flags.GlobalFlags{CfgDir: filepath.Join(dir, "config")})
+	c.SetArgs([]string{"--workers", "2"})
+	if err := c.Execute(); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	api.Lock()
+	defer api.Unlock()
+	// the bytes are uploaded once, but both files are created in their own album.
+	if want := []string{photo}; !sameElements(want, api.Uploaded) {
+		t.Errorf("want: %q, got: %q", want, api.Uploaded)
+	}
+	if want := 2; len(api.Created) != want {
+		t.Errorf("want: %d, got: %d", want, len(api.Created))
+	}
+	if want := []string{"Trips", "Family"}; !sameElements(want, api.Albums) {
+		t.Errorf("want: %v, got: %v", want, api.Albums)
+	}
+	if len(api.Unexpected) > 0 {
+		t.Errorf("unexpected requests: %v", api.Unexpected)
+	}
+}
+
+func TestNewPushCmd_Stdin(t *testing.T) {
+	dir, err := ioutil.TempDir("", "push")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	api := photosapitest.NewServer()
+	defer api.Close()
+	// the token has expired, so it's refreshed before uploading the file.
+	defaultEndpoint := app.GoogleAuthEndpoint
+	app.GoogleAuthEndpoint = oauth2.Endpoint{TokenURL: api.URL + "/token", AuthStyle: oauth2.AuthStyleInParams}
+	defer func() {
+		app.GoogleAuthEndpoint = defaultEndpoint
+	}()
+	for _, d := range []string{"config", "photos"} {
+		if err := os.MkdirAll(filepath.Join(dir, d), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	photo := "\xff\xd8\xff\xe0\x00\x10JFIF\x00photo"
+	if err := ioutil.WriteFile(filepath.Join(dir, "photos", "IMG_0001.jpg"), []byte(photo), 0600); err != nil {
+		t.Fatal(err)
+	}
+	// the token goes first, followed by the configuration.
+	stdin := fmt.Sprintf(`{"access_token":"expired-token","refresh_token":"refresh-token","token_type":"Bearer","expiry":"2001-01-01T00:00:00Z"}
+{
+  ConfigVersion: 2
+  APIAppCredentials: { ClientID: "client-id", ClientSecret: "client-secret" }
+  Account: "youremail@domain.com"
+  SecretsBackendType: "auto"
+  TokenStore: "file"
+  PhotosAPIBaseURL: %q
+  Jobs: [
+    { SourceFolder: %q, CreateAlbums: "Off" }
+  ]
+}`, api.URL, filepath.Join(dir, "photos"))
+
+	c := cmd.NewPushCmd(&flags.GlobalFlags{
+		CfgDir:     filepath.Join(dir, "config"),
+		CfgStdin:   true,
+		TokenStdin: true,
+		Stdin:      strings.NewReader(stdin),
+	})
+	c.SetArgs([]string{})
+	if err := c.Execute(); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	api.Lock()
+	defer api.Unlock()
+	if want := []string{photo}; !sameElements(want, api.Uploaded) {
+		t.Errorf("want: %q, got: %q", want, api.Uploaded)
+	}
+	if got := api.UploadedBy[photo]; got != "refreshed-token" {
+		t.Errorf("want: %v, got: %v", "refreshed-token", got)
+	}
+	// neither the configuration nor the refreshed token are written.
+	for _, name := range []string{app.DefaultConfigFilename, "tokens.enc"} {
+		if _, err := os.Stat(filepath.Join(dir, "config", name)); !os.IsNotExist(err) {
+			t.Errorf("want: '%s' not written, got: %v", name, err)
+		}
+	}
+}
+
+func TestNewPushCmd_DefaultAlbum(t *testing.T) {
+	dir, err := ioutil.TempDir("", "push")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	api := photosapitest.NewServer()
+	defer api.Close()
+	for _, d := range []string{"config", "photos/Trips"} {
+		if err := os.MkdirAll(filepath.Join(dir, d), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := map[string]string{
+		"Trips/IMG_0001.jpg": "\xff\xd8\xff\xe0\x00\x10JFIF\x00trips",
+		"IMG_0002.jpg":       "\xff\xd8\xff\xe0\x00\x10JFIF\x00photo-2",
+		"IMG_0003.jpg":       "\xff\xd8\xff\xe0\x00\x10JFIF\x00photo-3",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, "photos", name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfgContent := fmt.Sprintf(`{
+  ConfigVersion: 2
+  APIAppCredentials: { ClientID: "client-id", ClientSecret: "client-secret" }
+  Account: "youremail@domain.com"
+  SecretsBackendType: "auto"
+  TokenStore: "env"
+  TokenStoreEnvVar: %q
+  PhotosAPIBaseURL: %q
+  Jobs: [
+    {
+      SourceFolder: %q
+      CreateAlbums: "folderName"
+      DefaultAlbum: "Uploaded by CLI"
+    }
+  ]
+}`, testTokenEnvVar, api.URL, filepath.Join(dir, "photos"))
+	if err := ioutil.WriteFile(filepath.Join(dir, "config", app.DefaultConfigFilename), []byte(cfgContent), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Setenv(testTokenEnvVar, `{"access_token":"access-token","token_type":"Bearer","expiry":"2999-01-01T00:00:00Z"}`); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv(testTokenEnvVar)
+
+	c := cmd.NewPushCmd(&flags.GlobalFlags{CfgDir: filepath.Join(dir, "config")})
+	if err := c.Execute(); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	api.Lock()
+	defer api.Unlock()
+	// the default album is created once, for the files not in any folder album.
+	if want := []string{"Trips", "Uploaded by CLI"}; !sameElements(want, api.Albums) {
+		t.Errorf("want: %v, got: %v", want, api.Albums)
+	}
+	albumIDs := make(map[string]string)
+	for i, title := range api.Albums {
+		albumIDs[title] = fmt.Sprintf("album-%d", i+1)
+	}
+	want := []string{albumIDs["Trips"], albumIDs["Uploaded by CLI"], albumIDs["Uploaded by CLI"]}
+	if !sameElements(want, api.CreatedIn) {
+		t.Errorf("want: %v, got: %v", want, api.CreatedIn)
+	}
+}
+
+// sameElements returns if both lists have the same elements, in any order.
+func sameElements(want []string, got []string) bool {
+	w := append([]string(nil), want...)
+	g := append([]string(nil), got...)
+	sort.Strings(w)
+	sort.Strings(g)
+	return strings.Join(w, "\n") == strings.Join(g, "\n")
+}
+
+func TestNewPushCmd_ReportCSV(t *testing.T) {
+	dir, err := ioutil.TempDir("", "push")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	api := photosapitest.NewServer()
+	defer api.Close()
+	createTestEnvironment(t, dir, filepath.Join(dir, "photos"), api.URL)
+	photo := "\xff\xd8\xff\xe0\x00\x10JFIF\x00photo"
+	api.UploadFailure = photo + "-failed"
+	files := map[string]string{
+		"IMG_0001.jpg":  photo,
+		"IMG_0002.jpg":  api.UploadFailure,
+		".IMG_0003.jpg": photo + "-hidden",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, "photos", name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := os.Setenv(testTokenEnvVar, `{"access_token":"access-token","token_type":"Bearer","expiry":"2999-01-01T00:00:00Z"}`); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv(testTokenEnvVar)
+
+	reportPath := filepath.Join(dir, "report.csv")
+	c := cmd.NewPushCmd(&flags.GlobalFlags{CfgDir: filepath.Join(dir, "config")})
+	c.SetArgs([]string{"--report-csv", reportPath})
+	// the failed upload doesn't fail the run.
+	_ = c.Execute()
+
+	f, err := os.Open(reportPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if len(rows) == 0 || fmt.Sprint(rows[0]) != fmt.Sprint(report.Header) {
+		t.Fatalf("want: %v, got: %v", report.Header, rows)
+	}
+	type result struct{ mediaItemID, bytes, status, reason string }
+	want := map[string]result{
+		"IMG_0001.jpg":  {"media-item-1", fmt.Sprint(len(photo)), report.StatusUploaded, ""},
+		"IMG_0002.jpg":  {"", fmt.Sprint(len(api.UploadFailure)), report.StatusFailed, log.ReasonUnsupported},
+		".IMG_0003.jpg": {"", "0", report.StatusSkipped, log.ReasonHidden},
+	}
+	got := make(map[string]result)
+	for _, row := range rows[1:] {
+		if _, err := time.Parse(time.RFC3339, row[4]); err != nil {
+			t.Errorf("want: RFC 3339 timestamp, got: %s", row[4])
+		}
+		got[filepath.Base(row[0])] = result{row[2], row[3], row[5], row[6]}
+	}
+	if len(rows)-1 != len(want) {
+		t.Errorf("want: %d rows, got: %d", len(want), len(rows)-1)
+	}
+	for name, r := range want {
+		if got[name] != r {
+			t.Errorf("%s: want: %v, got: %v", name, r, got[name])
+		}
+	}
+}
+
+func TestNewPushCmd_RunLock(t *testing.T) {
+	testCases := []struct {
+		name          string
+		args          []string
+		isErrExpected bool
+	}{
+		{"Should fail if another instance is running", []string{}, true},
+		{"Should wait for another instance with --wait-lock", []string{"--wait-lock"}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "push")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			api := photosapitest.NewServer()
+			defer api.Close()
+			createTestEnvironment(t, dir, filepath.Join(dir, "photos"), api.URL)
+			if err := os.Setenv(testTokenEnvVar, `{"access_token":"access-token","token_type":"Bearer","expiry":"2999-01-01T00:00:00Z"}`); err != nil {
+				t.Fatal(err)
+			}
+			defer os.Unsetenv(testTokenEnvVar)
+
+			// the lock is held by another instance, that finishes after a while.
+			held, err := runlock.TryAcquire(filepath.Join(dir, "config", runlock.DefaultFilename))
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			released := make(chan struct{})
+			go func() {
+				time.Sleep(100 * time.Millisecond)
+				_ = held.Release()
+				close(released)
+			}()
+
+			c := cmd.NewPushCmd(&flags.GlobalFlags{CfgDir: filepath.Join(dir, "config")})
+			c.SetArgs(tc.args)
+			err = c.Execute()
+			<-released
+			if tc.isErrExpected {
+				if !errors.Is(err, runlock.ErrLocked) {
+					t.Errorf("want: %v, got: %v", runlock.ErrLocked, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+		})
+	}
+}
+
+func TestNewPushCmd_PauseFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "push")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	api := photosapitest.NewServer()
+	defer api.Close()
+	createTestEnvironment(t, dir, filepath.Join(dir, "photos"), api.URL)
+	controlDir := filepath.Join(dir, "control")
+	filename := filepath.Join(dir, "config", app.DefaultConfigFilename)
+	cfg, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg = []byte(strings.Replace(string(cfg), `Jobs: [`, fmt.Sprintf("ControlDir: %q\n  Jobs: [", controlDir), 1))
+	if err := ioutil.WriteFile(filename, cfg, 0600); err != nil {
+		t.Fatal(err)
+	}
+	photo := "\xff\xd8\xff\xe0\x00\x10JFIF\x00photo"
+	if err := ioutil.WriteFile(filepath.Join(dir, "photos", "IMG_0001.jpg"), []byte(photo), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(controlDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	pauseFile := filepath.Join(controlDir, "pause")
+	if err := ioutil.WriteFile(pauseFile, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Setenv(testTokenEnvVar, `{"access_token":"access-token","token_type":"Bearer","expiry":"2999-01-01T00:00:00Z"}`); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv(testTokenEnvVar)
+
+	c := cmd.NewPushCmd(&flags.GlobalFlags{CfgDir: filepath.Join(dir, "config")})
+	c.SetArgs([]string{})
+	done := make(chan error, 1)
+	go func() { done <- c.Execute() }()
+
+	// no upload is started while the pause file exists.
+	time.Sleep(2 * time.Second)
+	api.Lock()
+	uploaded := len(api.Uploaded)
+	api.Unlock()
+	if uploaded != 0 {
+		t.Fatalf("want: no uploads while paused, got: %d", uploaded)
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("want: push waiting for the paused uploads, got: finished with %v", err)
+	default:
+	}
+
+	if err := os.Remove(pauseFile); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("want: push finished once resumed, got: still paused")
+	}
+	if want, got := []string{photo}, api.Uploaded; fmt.Sprint(want) != fmt.Sprint(got) {
+		t.Errorf("want: %v, got: %v", want, got)
+	}
+}
+
+func TestNewPushCmd_SharedAlbum(t *testing.T) {
+	testCases := []struct {
+		name          string
+		sharedAlbum   string
+		wantAlbum     string
+		isErrExpected bool
+	}{
+		{"Should add files to writable shared album by share token", `{ ShareToken: "family-token" }`, "shared-family", false},
+		{"Should add files to writable shared album by title", `{ Title: "Family" }`, "shared-family", false},
+		{"Should fail if shared album is read-only", `{ ShareToken: "friends-token" }`, "", true},
+		{"Should fail if album is not shared", `{ Title: "Trips" }`, "", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "push")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			api := photosapitest.NewServer()
+			defer api.Close()
+			createTestEnvironment(t, dir, filepath.Join(dir, "photos"), api.URL)
+			filename := filepath.Join(dir, "config", app.DefaultConfigFilename)
+			cfg, err := ioutil.ReadFile(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the shared album is used instead of the album of the folder.
+			cfg = []byte(strings.Replace(string(cfg), `CreateAlbums: "Off"`, fmt.Sprintf(`CreateAlbums: "folderName", SharedAlbum: %s`, tc.sharedAlbum), 1))
+			if err := ioutil.WriteFile(filename, cfg, 0600); err != nil {
+				t.Fatal(err)
+			}
+			photo := "\xff\xd8\xff\xe0\x00\x10JFIF\x00photo"
+			if err := ioutil.WriteFile(filepath.Join(dir, "photos", "IMG_0001.jpg"), []byte(photo), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := os.Setenv(testTokenEnvVar, `{"access_token":"access-token","token_type":"Bearer","expiry":"2999-01-01T00:00:00Z"}`); err != nil {
+				t.Fatal(err)
+			}
+			defer os.Unsetenv(testTokenEnvVar)
+
+			c := cmd.NewPushCmd(&flags.GlobalFlags{CfgDir: filepath.Join(dir, "config")})
+			c.SetArgs([]string{})
+			err = c.Execute()
+
+			api.Lock()
+			defer api.Unlock()
+			if tc.isErrExpected {
+				if err == nil {
+					t.Fatalf("error was expected, but not produced")
+				}
+				if len(api.Uploaded) != 0 {
+					t.Errorf("want: no uploads, got: %q", api.Uploaded)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			if want := []string{tc.wantAlbum}; fmt.Sprint(want) != fmt.Sprint(api.CreatedIn) {
+				t.Errorf("want: %v, got: %v", want, api.CreatedIn)
+			}
+			// albums of the account are neither searched nor created.
+			if len(api.Albums) != 0 {
+				t.Errorf("want: no albums created, got: %v", api.Albums)
+			}
+		})
+	}
+}
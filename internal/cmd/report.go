@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"sync"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/report"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/task"
+)
+
+// runReport records the files processed by the run in the CSV report of `--report-csv`: the uploaded ones, and
+// the ones skipped or failed, with their reason. It's safe for concurrent use.
+type runReport struct {
+	csv    *report.CSV
+	logger log.Logger
+
+	// warnOnce logs only once that the rows could not be written, instead of once per file.
+	warnOnce sync.Once
+}
+
+// openRunReport returns the report appending the rows to the CSV file at path.
+func openRunReport(path string, logger log.Logger) (*runReport, error) {
+	csv, err := report.OpenCSV(path)
+	if err != nil {
+		return nil, err
+	}
+	return &runReport{csv: csv, logger: logger}, nil
+}
+
+// Report records the result of an upload, the reason of the failed ones is derived from their error.
+func (r *runReport) Report(u task.UploadResult) {
+	reason := u.Reason
+	if u.Err != nil {
+		reason = errorReason(u.Err)
+	}
+	r.add(report.Row{Path: u.Path, Album: u.AlbumName, MediaItemID: u.MediaItemID, Bytes: u.Bytes, Status: u.Status, Reason: reason})
+}
+
+// skipped records the file skipped for the reason, one of the log.Reason* codes.
+func (r *runReport) skipped(path string, reason string) {
+	r.add(report.Row{Path: path, Status: report.StatusSkipped, Reason: reason})
+}
+
+// failed records the file that has failed before being uploaded, for the reason.
+func (r *runReport) failed(path string, album string, reason string) {
+	r.add(report.Row{Path: path, Album: album, Status: report.StatusFailed, Reason: reason})
+}
+
+func (r *runReport) add(row report.Row) {
+	if err := r.csv.Add(row); err != nil {
+		r.warnOnce.Do(func() {
+			r.logger.Warnf("Unable to write the report of the run: %s", err)
+		})
+	}
+}
+
+// Close writes the rows not written yet.
+func (r *runReport) Close() error {
+	return r.csv.Close()
+}
+
+// reporter returns the reporter of the uploads, grouping the files skipped by the upload itself, e.g. because
+// they are in the library already, and recording all the results in rep too, if it's not nil.
+func (r *runSummary) reporter(rep *runReport) task.UploadReporter {
+	return skippedReporter{run: r, rep: rep}
+}
+
+// skippedReporter is the reporter returned by runSummary.reporter.
+type skippedReporter struct {
+	run *runSummary
+	rep *runReport
+}
+
+func (s skippedReporter) Report(u task.UploadResult) {
+	if u.Status == report.StatusSkipped {
+		s.run.addSkipped(u.Path, u.Reason)
+	}
+	if s.rep != nil {
+		s.rep.Report(u)
+	}
+}
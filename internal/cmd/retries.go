@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/app"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/metrics"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/tempdir"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/upload"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/worker"
+)
+
+// retries decides which files are attempted in the run, given the failed uploads of previous runs,
+// and records the failed uploads of this run.
+// It's safe for concurrent use, since watched jobs are handled concurrently.
+type retries struct {
+	queue  app.RetryQueue
+	logger log.Logger
+	// failures are not recorded in dry-run mode.
+	dryRun bool
+
+	mu sync.Mutex
+	// claimed are the files enqueued in this run and not finished yet.
+	claimed map[string]bool
+	// attachFailures are the files that could not be added to their album in a batch, see recordAttachFailures.
+	attachFailures []worker.JobResult
+}
+
+func newRetries(queue app.RetryQueue, logger log.Logger, dryRun bool) *retries {
+	return &retries{
+		queue:   queue,
+		logger:  logger,
+		dryRun:  dryRun,
+		claimed: make(map[string]bool),
+	}
+}
+
+// due returns the files in the folder that have failed on previous runs, and should be attempted now.
+func (r *retries) due(folder string) []string {
+	entries, err := r.queue.Pending()
+	if err != nil {
+		r.logger.Warnf("Unable to read the failed uploads of previous runs: %s", err)
+		return nil
+	}
+	var paths []string
+	for _, e := range entries {
+		if upload.RelativePath(folder, e.Path) == e.Path {
+			continue
+		}
+		if due, err := r.queue.Due(e.Path); err == nil && due {
+			paths = append(paths, e.Path)
+		}
+	}
+	return paths
+}
+
+// claim returns true if the file should be attempted: it's not being attempted in this run,
+// it's not waiting for its backoff and it has not failed too many times.
+// The file is attempted until release is called.
+func (r *retries) claim(path string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.claimed[path] {
+		return false
+	}
+	due, err := r.queue.Due(path)
+	if err != nil {
+		r.logger.Warnf("Unable to read the failed uploads of '%s': %s", path, err)
+		due = true
+	}
+	if !due {
+		r.logSkipped(path)
+		return false
+	}
+	r.claimed[path] = true
+	return true
+}
+
+// release marks the file as not being attempted.
+func (r *retries) release(path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.claimed, path)
+}
+
+// logSkipped logs why a failed file is not attempted.
+func (r *retries) logSkipped(path string) {
+	e, dead, err := r.queue.Get(path)
+	if err == nil && dead {
+		r.logger.WithFields(log.Fields{"event": log.EventFileSkipped, "path": path, "reason": log.ReasonDeadLetter}).Debugf("Skipping file '%s', it has failed %d times.", path, e.Attempts)
+		metrics.FilesSkipped.Inc(log.ReasonDeadLetter)
+		return
+	}
+	r.logger.WithFields(log.Fields{"event": log.EventFileSkipped, "path": path, "reason": log.ReasonRetryBackoff}).Debugf("Skipping file '%s', it has failed recently and will be retried later.", path)
+	metrics.FilesSkipped.Inc(log.ReasonRetryBackoff)
+}
+
+// forget removes the file from the failed uploads, e.g. when it doesn't exist anymore.
+func (r *retries) forget(path string) {
+	if r.dryRun {
+		return
+	}
+	if err := r.queue.Remove(path); err != nil {
+		r.logger.Warnf("Unable to update the failed uploads of '%s': %s", path, err)
+	}
+}
+
+// record updates the failed uploads given the result of an upload, releasing the file.
+// Interrupted uploads, unauthorized requests, exceeded quotas, a full storage and a low free space are not recorded, since they
+// are not a failure of the file. Files rejected by Google Photos are not retried, since they would fail again.
+func (r *retries) record(result worker.JobResult) {
+	defer r.release(result.ID)
+
+	switch {
+	case r.dryRun, interrupted(result.Err):
+	case result.Err == nil, errors.Is(result.Err, os.ErrNotExist):
+		r.forget(result.ID)
+	case errors.Is(result.Err, app.ErrInvalidGrant), errors.Is(result.Err, upload.ErrUnauthorized), errors.Is(result.Err, upload.ErrQuotaExceeded), errors.Is(result.Err, upload.ErrStorageFull), errors.Is(result.Err, tempdir.ErrLowFreeSpace):
+	case errors.Is(result.Err, upload.ErrUnsupportedMedia):
+		if _, err := r.queue.Rejected(result.ID, result.Err.Error()); err != nil {
+			r.logger.Warnf("Unable to update the failed uploads of '%s': %s", result.ID, err)
+			return
+		}
+		r.logger.Warnf("File '%s' has been rejected by Google Photos, it will not be attempted again.", result.ID)
+	default:
+		e, dead, err := r.queue.Failed(result.ID, result.Err.Error())
+		if err != nil {
+			r.logger.Warnf("Unable to update the failed uploads of '%s': %s", result.ID, err)
+			return
+		}
+		if dead {
+			r.logger.Warnf("File '%s' has failed %d times, it will not be attempted again.", result.ID, e.Attempts)
+			return
+		}
+		r.logger.Debugf("File '%s' has failed %d times, it will be retried after %s.", result.ID, e.Attempts, e.NextAttempt().Local().Format("2006-01-02 15:04:05"))
+	}
+}
+
+// reportDeadLetters logs the files that have failed too many times, adding them to the run summary.
+func (r *retries) reportDeadLetters(run *runSummary) {
+	entries, err := r.queue.DeadLetters()
+	if err != nil {
+		r.logger.Warnf("Unable to read the failed uploads: %s", err)
+		return
+	}
+	if len(entries) == 0 {
+		return
+	}
+	for _, e := range entries {
+		r.logger.Failf("File '%s' has failed %d times and is not attempted anymore, last error: %s", e.Path, e.Attempts, e.Reason)
+		run.addDeadLetter(fmt.Sprintf("%s: %s", e.Path, e.Reason))
+	}
+	r.logger.Warnf("%d files are not attempted anymore, use --retry-dead-letters to attempt them again.", len(entries))
+}
+
+// attachFailed keeps the failure of a file that could not be added to its album in a batch, to be recorded by
+// recordAttachFailures. The upload of the file has succeeded already, once it was enqueued in the batch.
+func (r *retries) attachFailed(path string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.attachFailures = append(r.attachFailures, worker.JobResult{ID: path, Err: err})
+}
+
+// recordAttachFailures records the failures kept by attachFailed, once the results of their uploads have been
+// recorded, so only the files that could not be added are attempted again.
+func (r *retries) recordAttachFailures() {
+	r.mu.Lock()
+	failures := r.attachFailures
+	r.attachFailures = nil
+	r.mu.Unlock()
+	for _, result := range failures {
+		r.record(result)
+	}
+}
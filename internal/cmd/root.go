@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
@@ -9,6 +10,7 @@ import (
 	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
 
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/app"
 	"github.com/gphotosuploader/gphotos-uploader-cli/internal/cmd/flags"
 	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
 )
@@ -23,12 +25,35 @@ var rootCmd = &cobra.Command{
 		if globalFlags.Silent && globalFlags.Debug {
 			return fmt.Errorf("%s and %s cannot be specified at the same time", ansi.Color("--silent", "white+b"), ansi.Color("--debug", "white+b"))
 		}
+		switch globalFlags.LogFormat {
+		case "text":
+			// the default logger writes text.
+		case "json":
+			log.SetInstance(log.NewJSONLogger(os.Stdout))
+		default:
+			return fmt.Errorf("%s is invalid, '%s'", ansi.Color("--log-format", "white+b"), globalFlags.LogFormat)
+		}
+		if globalFlags.LogLevel != "" && (globalFlags.Silent || globalFlags.Debug) {
+			return fmt.Errorf("%s cannot be specified with %s or %s", ansi.Color("--log-level", "white+b"), ansi.Color("--silent", "white+b"), ansi.Color("--debug", "white+b"))
+		}
+		level := logrus.InfoLevel
+		if globalFlags.LogLevel != "" {
+			var err error
+			if level, err = log.ParseLevel(globalFlags.LogLevel); err != nil {
+				return fmt.Errorf("%s is invalid, '%s'", ansi.Color("--log-level", "white+b"), globalFlags.LogLevel)
+			}
+		}
 		if globalFlags.Silent {
-			log.GetInstance().SetLevel(logrus.FatalLevel)
+			level = logrus.FatalLevel
 		}
 		if globalFlags.Debug {
-			log.GetInstance().SetLevel(logrus.DebugLevel)
+			level = logrus.DebugLevel
 		}
+		if err := log.ValidateModules(globalFlags.DebugModules); err != nil {
+			return fmt.Errorf("%s is invalid: %s", ansi.Color("--debug-modules", "white+b"), err)
+		}
+		// levels are enforced by the instance used by all the commands.
+		log.SetInstance(log.NewLeveledLogger(log.GetInstance(), level, globalFlags.DebugModules))
 		return nil
 	},
 	Long: `
@@ -56,12 +81,19 @@ func Execute() {
 	// Execute command
 	err := rootCmd.Execute()
 	if err != nil {
+		if errors.Is(err, app.ErrInvalidGrant) {
+			log.Error(err)
+			log.Fatalf("The stored token could not be refreshed. Authenticate again running `%s auth`, and then run the command again.", rootCmd.Use)
+		}
 		log.Fatal(err)
 		os.Exit(1)
 	}
 }
 
 func init() {
+	// requests to Google are identified by the version of the tool.
+	app.DefaultUserAgent = "gphotos-uploader-cli/" + version
+
 	persistentFlags := rootCmd.PersistentFlags()
 	globalFlags = flags.SetGlobalFlags(persistentFlags)
 
@@ -69,7 +101,17 @@ func init() {
 	rootCmd.AddCommand(NewVersionCmd())
 	rootCmd.AddCommand(NewInitCmd(globalFlags))
 	rootCmd.AddCommand(NewPushCmd(globalFlags))
+	rootCmd.AddCommand(NewScanCmd(globalFlags))
+	rootCmd.AddCommand(NewUploadCmd(globalFlags))
+	rootCmd.AddCommand(NewRunsCmd(globalFlags))
 	rootCmd.AddCommand(NewAuthCmd(globalFlags))
+	rootCmd.AddCommand(NewVerifyCmd(globalFlags))
+	rootCmd.AddCommand(NewAlbumsCmd(globalFlags))
+	rootCmd.AddCommand(NewTrackerCmd(globalFlags))
+	rootCmd.AddCommand(NewConfigCmd(globalFlags))
+	rootCmd.AddCommand(NewFilterCmd(globalFlags))
+	rootCmd.AddCommand(NewDoctorCmd(globalFlags))
+	rootCmd.AddCommand(NewSelfTestCmd(globalFlags))
 }
 
 // GetRoot returns the root command
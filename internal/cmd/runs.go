@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/cmd/flags"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/manifest"
+)
+
+// RunsCmd holds the required data for the runs cmd
+type RunsCmd struct {
+	*flags.GlobalFlags
+}
+
+func NewRunsCmd(globalFlags *flags.GlobalFlags) *cobra.Command {
+	cmd := &RunsCmd{GlobalFlags: globalFlags}
+
+	runsCmd := &cobra.Command{
+		Use:   "runs",
+		Short: "Manage the runs of the upload command",
+		Long:  `Manage the runs of the upload command. Interrupted runs are continued using 'upload --resume-from <ID>'.`,
+		Args:  cobra.NoArgs,
+	}
+
+	runsCmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List the runs of the upload command",
+		Long: `List the runs of the upload command, the most recent first, with their ID, when they started, how many files of
+their manifest have been completed, and the manifest. Runs not completed could be continued using 'upload --resume-from <ID>'.`,
+		Args: cobra.NoArgs,
+		RunE: cmd.List,
+	})
+
+	return runsCmd
+}
+
+func (cmd *RunsCmd) List(cobraCmd *cobra.Command, args []string) error {
+	runs, err := manifest.ListRuns(filepath.Join(cmd.CfgDir, manifest.RunsFolder))
+	if err != nil {
+		return err
+	}
+	if len(runs) == 0 {
+		_, _ = fmt.Fprintln(cobraCmd.OutOrStdout(), "There are no runs.")
+		return nil
+	}
+	w := tabwriter.NewWriter(cobraCmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ID\tSTARTED\tCOMPLETED\tMANIFEST")
+	for _, r := range runs {
+		completed := fmt.Sprintf("%d/%d", r.Completed, r.Entries)
+		if r.Completed == r.Entries {
+			completed += " (done)"
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.ID, r.StartedAt.Local().Format(time.RFC3339), completed, r.Manifest)
+	}
+	return w.Flush()
+}
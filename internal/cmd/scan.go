@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/app"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/cmd/flags"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/manifest"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/upload"
+)
+
+// ScanCmd holds the required data for the scan cmd
+type ScanCmd struct {
+	*flags.GlobalFlags
+
+	// command flags
+	Output      string
+	Since       string
+	Until       string
+	WaitLock    bool
+	FailOnError bool
+}
+
+func NewScanCmd(globalFlags *flags.GlobalFlags) *cobra.Command {
+	cmd := &ScanCmd{GlobalFlags: globalFlags}
+
+	scanCmd := &cobra.Command{
+		Use:   "scan",
+		Short: "Write the files to be uploaded to a manifest, without uploading them",
+		Long: `Scan the source folders like push does, writing the files to be uploaded to a manifest instead of uploading them.
+Every entry of the manifest, in JSON, has the path, size and SHA-256 hash of a file, and the account and album where it
+would be uploaded. Once reviewed, the files are uploaded running 'upload --manifest <file>'.
+All the files are checked, not only the ones changed since the last run, and failed files are not attempted first.`,
+		Args: cobra.NoArgs,
+		RunE: cmd.Run,
+	}
+
+	scanCmd.Flags().StringVar(&cmd.Output, "output", "", "File where the manifest is written (required)")
+	scanCmd.Flags().StringVar(&cmd.Since, "since", "", "Select only the files taken on or after the date, YYYY-MM-DD in the local time zone or RFC 3339")
+	scanCmd.Flags().StringVar(&cmd.Until, "until", "", "Select only the files taken on or before the date, YYYY-MM-DD in the local time zone or RFC 3339")
+	scanCmd.Flags().BoolVar(&cmd.WaitLock, "wait-lock", false, "Wait for another instance using the same config folder to finish, instead of exiting")
+	scanCmd.Flags().BoolVar(&cmd.FailOnError, "fail-on-error", false, "Fail if any file or directory could not be read, instead of skipping it")
+
+	return scanCmd
+}
+
+func (cmd *ScanCmd) Run(cobraCmd *cobra.Command, args []string) error {
+	if cmd.Output == "" {
+		return errors.New("--output is required")
+	}
+	dateRange, err := upload.ParseDateRange(cmd.Since, cmd.Until)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	lock, err := lockRun(ctx, cmd.CfgDir, cmd.WaitLock)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = lock.Release()
+	}()
+
+	// files are only scanned, the tracked ones are read from the local data.
+	cli, err := app.StartWithoutAuth(cmd.CfgDir, appOptions(cmd.GlobalFlags)...)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = cli.Stop()
+	}()
+
+	// files with the same content than another one selected in this scan are skipped, whatever job they belong to,
+	// or if it's in the same library.
+	dedups := newRunDedups(cli.Config, cli.ContentHashes)
+
+	// the configuration has been validated already.
+	minFileAge, _ := time.ParseDuration(cli.Config.MinFileAge)
+	limits := uploadLimits(cli.Config)
+
+	m := manifest.Manifest{Version: manifest.Version, CreatedAt: time.Now().UTC(), Entries: []manifest.Entry{}}
+	for _, job := range cli.Config.Jobs {
+		account, err := cli.Config.JobAccount(job)
+		if err != nil {
+			return err
+		}
+		folder, err := newFolderJob(cli, job, limits, minFileAge)
+		if err != nil {
+			return err
+		}
+		folder.FailOnError = cmd.FailOnError
+		folder.DateRange = dateRange
+		dedup := dedups.forJob(job)
+
+		cli.Logger.WithFields(log.Fields{"event": log.EventScanStart, "path": job.SourceFolder}).Infof("Scanning location '%s'.", job.SourceFolder)
+		var entryErr error
+		found := 0
+		_, err = walkFolder(ctx, folder, cli.Config.UploadOrder, cli.Logger, func(item upload.FileItem) {
+			if entryErr != nil || isDuplicate(dedup, item.Path, cli.Logger) {
+				return
+			}
+			// files matching a route of the job are uploaded to its account.
+			itemAccount := account
+			if item.Account != "" {
+				itemAccount = item.Account
+			}
+			e, err := manifest.NewEntry(item, itemAccount, cli.ContentHashes)
+			if err != nil {
+				entryErr = fmt.Errorf("unable to read '%s': %w", item.Path, err)
+				return
+			}
+			m.Entries = append(m.Entries, e)
+			found++
+		})
+		if err == nil {
+			err = entryErr
+		}
+		if err != nil {
+			return fmt.Errorf("failed to process location '%s': %w", job.SourceFolder, err)
+		}
+		cli.Logger.Infof("Found %d items to be uploaded processing location '%s'.", found, job.SourceFolder)
+	}
+
+	if err := manifest.WriteFile(cmd.Output, m); err != nil {
+		return fmt.Errorf("unable to write the manifest: %w", err)
+	}
+	cli.Logger.Donef("%d files to be uploaded written to '%s'.", len(m.Entries), cmd.Output)
+	return nil
+}
@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/gphotosuploader/google-photos-api-client-go/v2/media_items"
+	"github.com/spf13/cobra"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/app"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/cmd/flags"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/progress"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/tempdir"
+)
+
+// selfTestImageSize is the width and height, in pixels, of the probe image uploaded by the selftest command.
+const selfTestImageSize = 8
+
+// SelfTestCmd holds the required data for the selftest cmd
+type SelfTestCmd struct {
+	*flags.GlobalFlags
+
+	// command flags
+	Account string
+}
+
+func NewSelfTestCmd(globalFlags *flags.GlobalFlags) *cobra.Command {
+	cmd := &SelfTestCmd{GlobalFlags: globalFlags}
+
+	selfTestCmd := &cobra.Command{
+		Use:   "selftest",
+		Short: "Upload a probe image to check that files could be uploaded to Google Photos",
+		Long: `Upload a tiny generated image to Google Photos, going through the same steps as every uploaded file:
+authentication, upload of its content, creation of its media item, and confirmation that it exists in the library.
+It reports the time taken by every step, and exits with a non-zero code if any of them fails.
+The Google Photos API doesn't allow deleting media items, so the probe image is kept in the library, it should
+be deleted manually.`,
+		Args: cobra.NoArgs,
+		RunE: cmd.Run,
+	}
+
+	selfTestCmd.Flags().StringVar(&cmd.Account, "account", "", "Account where the probe image is uploaded (default is the Account setting)")
+
+	return selfTestCmd
+}
+
+func (cmd *SelfTestCmd) Run(cobraCmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	cli, err := app.Start(ctx, cmd.CfgDir, appOptions(cmd.GlobalFlags)...)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = cli.Stop()
+	}()
+
+	// the default Account is the first one.
+	account := cmd.Account
+	if account == "" {
+		account = cli.Config.Account
+	}
+	if !slices.Contains(accounts(cli.Config), account) {
+		return fmt.Errorf("account '%s' is not configured", account)
+	}
+
+	// the probe image is generated, but the uploader reads files, so it's written in a temporary folder.
+	tempDir, err := tempdir.New(cli.Config.TempDir)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tempDir.Remove(); err != nil {
+			cli.Logger.Warnf("Unable to remove the temporary folder '%s': %s", tempDir.Path, err)
+		}
+	}()
+	now := time.Now()
+	probe := filepath.Join(tempDir.Path, fmt.Sprintf("gphotos-uploader-cli-selftest-%s.png", now.Format("20060102-150405")))
+	content, err := selfTestImage(now)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(probe, content, 0600); err != nil {
+		return err
+	}
+
+	step := func(name string, fn func() error) error {
+		start := time.Now()
+		if err := fn(); err != nil {
+			cli.Logger.Failf("%s: %s", name, err)
+			return fmt.Errorf("selftest has failed: %w", err)
+		}
+		cli.Logger.Donef("%s (%s)", name, time.Since(start).Round(time.Millisecond))
+		return nil
+	}
+
+	started := time.Now()
+	var services *accountServices
+	if err := step("Authentication of account '"+account+"'", func() error {
+		services, err = newAccountServices(ctx, cli, account, nil, progress.NewTracker())
+		return err
+	}); err != nil {
+		return err
+	}
+	var token string
+	if err := step(fmt.Sprintf("Upload of the probe image, %d bytes", len(content)), func() error {
+		token, err = services.photos.Uploader.UploadFile(ctx, probe)
+		return err
+	}); err != nil {
+		return err
+	}
+	var item media_items.MediaItem
+	if err := step("Creation of its media item", func() error {
+		item, err = services.photos.MediaItems.Create(ctx, media_items.SimpleMediaItem{
+			UploadToken: token,
+			FileName:    filepath.Base(probe),
+		})
+		return err
+	}); err != nil {
+		return err
+	}
+	if err := step("Confirmation that it's in the library", func() error {
+		found, err := services.photos.MediaItems.Get(ctx, item.ID)
+		if err != nil {
+			return err
+		}
+		if found.ID != item.ID {
+			return fmt.Errorf("media item '%s' was not found", item.ID)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	cli.Logger.Donef("Files could be uploaded to account '%s', selftest took %s.", account, time.Since(started).Round(time.Millisecond))
+	// media items could not be deleted using the API.
+	location := item.ProductURL
+	if location == "" {
+		location = "media item '" + item.ID + "'"
+	}
+	cli.Logger.Infof("The Google Photos API doesn't allow deleting media items, delete the probe image '%s' manually from the library: %s", filepath.Base(probe), location)
+	return nil
+}
+
+// selfTestImage returns a PNG image of selfTestImageSize pixels, whose color depends on the time, so probe
+// images uploaded at different times are not considered duplicates by Google Photos.
+func selfTestImage(t time.Time) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, selfTestImageSize, selfTestImageSize))
+	c := color.RGBA{R: uint8(t.Unix()), G: uint8(t.Unix() >> 8), B: uint8(t.Unix() >> 16), A: 0xff}
+	for x := 0; x < selfTestImageSize; x++ {
+		for y := 0; y < selfTestImageSize; y++ {
+			img.Set(x, y, c)
+		}
+	}
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
@@ -0,0 +1,103 @@
+package cmd_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/cmd"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/cmd/flags"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/photosapi/photosapitest"
+)
+
+func TestNewSelfTestCmd(t *testing.T) {
+	dir, err := ioutil.TempDir("", "selftest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	api := photosapitest.NewServer()
+	defer api.Close()
+	createTestEnvironment(t, dir, filepath.Join(dir, "photos"), api.URL)
+	if err := os.Setenv(testTokenEnvVar, `{"access_token":"access-token","token_type":"Bearer","expiry":"2999-01-01T00:00:00Z"}`); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv(testTokenEnvVar)
+
+	c := cmd.NewSelfTestCmd(&flags.GlobalFlags{CfgDir: filepath.Join(dir, "config")})
+	c.SetArgs([]string{})
+	if err := c.Execute(); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	api.Lock()
+	defer api.Unlock()
+	want := []string{
+		"POST /v1/uploads",
+		"POST /v1/uploads/session-1",
+		"POST /v1/mediaItems:batchCreate",
+		"GET /v1/mediaItems/media-item-1",
+	}
+	if !reflect.DeepEqual(want, api.Requests) {
+		t.Errorf("want: %v, got: %v", want, api.Requests)
+	}
+	// the probe image is a PNG image, uploaded with the token of the account.
+	if len(api.Uploaded) != 1 || !strings.HasPrefix(api.Uploaded[0], "\x89PNG") {
+		t.Fatalf("want: a PNG image uploaded, got: %q", api.Uploaded)
+	}
+	if got := api.UploadedBy[api.Uploaded[0]]; got != "access-token" {
+		t.Errorf("want: %v, got: %v", "access-token", got)
+	}
+	if want := []string{"upload-token-1"}; !reflect.DeepEqual(want, api.Created) {
+		t.Errorf("want: %v, got: %v", want, api.Created)
+	}
+}
+
+func TestNewSelfTestCmd_Failure(t *testing.T) {
+	testCases := []struct {
+		name          string
+		token         string
+		args          []string
+		createFailure string
+		want          []string
+	}{
+		{"Should fail if account is not authorized", "", nil, "", nil},
+		{"Should fail if account is not configured", `{"access_token":"access-token","token_type":"Bearer","expiry":"2999-01-01T00:00:00Z"}`, []string{"--account", "other@domain.com"}, "", nil},
+		{"Should fail if media item is not created", `{"access_token":"access-token","token_type":"Bearer","expiry":"2999-01-01T00:00:00Z"}`, nil, "Quota exceeded", []string{"POST /v1/uploads", "POST /v1/uploads/session-1", "POST /v1/mediaItems:batchCreate"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "selftest")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			api := photosapitest.NewServer()
+			defer api.Close()
+			api.CreateFailure = tc.createFailure
+			createTestEnvironment(t, dir, filepath.Join(dir, "photos"), api.URL)
+			if err := os.Setenv(testTokenEnvVar, tc.token); err != nil {
+				t.Fatal(err)
+			}
+			defer os.Unsetenv(testTokenEnvVar)
+
+			c := cmd.NewSelfTestCmd(&flags.GlobalFlags{CfgDir: filepath.Join(dir, "config")})
+			c.SetArgs(tc.args)
+			if err := c.Execute(); err == nil {
+				t.Errorf("error was expected, but not produced")
+			}
+
+			api.Lock()
+			defer api.Unlock()
+			// the probe image is not confirmed once a step has failed.
+			if !reflect.DeepEqual(tc.want, api.Requests) {
+				t.Errorf("want: %v, got: %v", tc.want, api.Requests)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/datastore/quota"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/worker"
+)
+
+const (
+	// defaultShutdownTimeout is the time uploads in progress have to finish once the run is interrupted.
+	defaultShutdownTimeout = time.Minute
+
+	// forcedExitCode is the exit code when a second signal is received, 128 + SIGINT.
+	forcedExitCode = 130
+)
+
+// errInterrupted is the result of the uploads not started when the run was interrupted.
+var errInterrupted = errors.New("not uploaded, the run was interrupted")
+
+// shutdown handles the interrupt signals of a run. On the first signal new uploads are not
+// started anymore, and the uploads in progress have a grace timeout to finish, before the
+// uploads context is cancelled aborting them. A second signal exits immediately.
+// Once the deadline of the run, if any, is reached, the run is interrupted and the uploads in progress are aborted.
+type shutdown struct {
+	// ctx is the context of the uploads, cancelled once the uploads in progress should be aborted.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// stopping is closed on the first signal.
+	stopping chan struct{}
+	once     sync.Once
+
+	// maxDuration, if it's set, stops the run once its duration has passed, see stopAfter.
+	maxDuration *time.Timer
+	// maxDurationReached is closed once the maximum duration of the run has passed.
+	maxDurationReached chan struct{}
+
+	timeout time.Duration
+	signals chan os.Signal
+	logger  log.Logger
+
+	// exit terminates the process.
+	// Useful for testing.
+	exit func(code int)
+}
+
+// newShutdown returns the handler of the interrupt signals, SIGINT and SIGTERM. The uploads context
+// derives from parent, so its deadline applies to the uploads. Call release once the run has finished
+// to restore the default behavior.
+func newShutdown(parent context.Context, timeout time.Duration, logger log.Logger) *shutdown {
+	s := newShutdownWithoutSignals(parent, timeout, logger)
+	signal.Notify(s.signals, os.Interrupt, syscall.SIGTERM)
+	go s.handle()
+	return s
+}
+
+func newShutdownWithoutSignals(parent context.Context, timeout time.Duration, logger log.Logger) *shutdown {
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	ctx, cancel := context.WithCancel(parent)
+	return &shutdown{
+		ctx:                ctx,
+		cancel:             cancel,
+		stopping:           make(chan struct{}),
+		maxDurationReached: make(chan struct{}),
+		timeout:            timeout,
+		signals:            make(chan os.Signal, 2),
+		logger:             logger,
+		exit:               os.Exit,
+	}
+}
+
+func (s *shutdown) handle() {
+	select {
+	case <-s.signals:
+	case <-s.ctx.Done():
+		if s.timedOut() {
+			s.logger.Warn("The run timeout has been reached, uploads in progress are aborted.")
+			s.stop()
+		}
+		return
+	}
+	s.logger.Warnf("Stopping, waiting up to %s for the uploads in progress to finish. Press Ctrl+C again to exit immediately.", s.timeout)
+	s.stop()
+
+	select {
+	case <-s.signals:
+		s.logger.Warn("Exiting immediately, uploads in progress are aborted.")
+		s.exit(forcedExitCode)
+	case <-time.After(s.timeout):
+		s.logger.Warn("Uploads in progress have not finished in time, aborting them.")
+		s.cancel()
+	case <-s.ctx.Done():
+	}
+}
+
+// stop stops starting new uploads, like the first signal does.
+func (s *shutdown) stop() {
+	s.once.Do(func() { close(s.stopping) })
+}
+
+// stopAfter stops starting new uploads once the duration has passed, like the first signal does, but the uploads in
+// progress are not aborted, so the run finishes cleanly. The next run uploads the files not uploaded.
+func (s *shutdown) stopAfter(d time.Duration) {
+	s.maxDuration = time.AfterFunc(d, func() {
+		s.logger.Infof("The maximum duration of the run, %s, has been reached, waiting for the uploads in progress to finish.", d)
+		close(s.maxDurationReached)
+		s.stop()
+	})
+}
+
+// reachedMaxDuration returns true once the run has been stopped because its maximum duration has passed.
+func (s *shutdown) reachedMaxDuration() bool {
+	select {
+	case <-s.maxDurationReached:
+		return true
+	default:
+		return false
+	}
+}
+
+// stopped returns true once the run has been interrupted, by a signal or by its deadline.
+func (s *shutdown) stopped() bool {
+	select {
+	case <-s.stopping:
+		return true
+	default:
+		return s.timedOut()
+	}
+}
+
+// timedOut returns true once the deadline of the run has been reached.
+func (s *shutdown) timedOut() bool {
+	return errors.Is(s.ctx.Err(), context.DeadlineExceeded)
+}
+
+// release stops handling the signals, cancelling the uploads context.
+func (s *shutdown) release() {
+	if s.maxDuration != nil {
+		s.maxDuration.Stop()
+	}
+	signal.Stop(s.signals)
+	s.cancel()
+}
+
+// wrap returns the job, that is not processed if the run is interrupted before it starts.
+func (s *shutdown) wrap(job worker.Job) worker.Job {
+	return &interruptibleJob{Job: job, shutdown: s}
+}
+
+// interruptibleJob is a job that is not processed once the run has been interrupted.
+type interruptibleJob struct {
+	worker.Job
+	shutdown *shutdown
+}
+
+func (j *interruptibleJob) Process() error {
+	if j.shutdown.stopped() {
+		return errInterrupted
+	}
+	return j.Job.Process()
+}
+
+// interrupted returns true if the upload has not been done because the run was interrupted:
+// it was not started, or it was aborted. The run is interrupted too when its deadline is reached, or the
+// daily request budget is exhausted.
+func interrupted(err error) bool {
+	return errors.Is(err, errInterrupted) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, quota.ErrExhausted)
+}
@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/datastore/quota"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+)
+
+type fakeJob struct {
+	processed bool
+}
+
+func (j *fakeJob) Process() error {
+	j.processed = true
+	return nil
+}
+
+func (j *fakeJob) ID() string {
+	return "fake"
+}
+
+func TestShutdown_FirstSignal(t *testing.T) {
+	s := newShutdownWithoutSignals(context.Background(), 20*time.Millisecond, log.Discard)
+	defer s.release()
+	go s.handle()
+
+	s.signals <- os.Interrupt
+
+	select {
+	case <-s.stopping:
+	case <-time.After(time.Second):
+		t.Fatal("want: stopping after the first signal, got: running")
+	}
+	if s.ctx.Err() != nil {
+		t.Errorf("want: uploads context not cancelled during the grace timeout, got: %v", s.ctx.Err())
+	}
+
+	job := &fakeJob{}
+	if err := s.wrap(job).Process(); !errors.Is(err, errInterrupted) {
+		t.Errorf("want: %v, got: %v", errInterrupted, err)
+	}
+	if job.processed {
+		t.Errorf("want: job not processed once stopping, got: processed")
+	}
+
+	select {
+	case <-s.ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("want: uploads context cancelled after the grace timeout, got: not cancelled")
+	}
+}
+
+func TestShutdown_SecondSignal(t *testing.T) {
+	s := newShutdownWithoutSignals(context.Background(), time.Hour, log.Discard)
+	defer s.release()
+	exited := make(chan int, 1)
+	s.exit = func(code int) { exited <- code }
+	go s.handle()
+
+	s.signals <- os.Interrupt
+	s.signals <- os.Interrupt
+
+	select {
+	case code := <-exited:
+		if code != forcedExitCode {
+			t.Errorf("want: %d, got: %d", forcedExitCode, code)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("want: exit after the second signal, got: running")
+	}
+}
+
+func TestShutdown_NotInterrupted(t *testing.T) {
+	s := newShutdownWithoutSignals(context.Background(), time.Hour, log.Discard)
+	defer s.release()
+
+	job := &fakeJob{}
+	if err := s.wrap(job).Process(); err != nil {
+		t.Errorf("error was not expected at this point: %s", err)
+	}
+	if !job.processed {
+		t.Errorf("want: job processed, got: not processed")
+	}
+}
+
+func TestInterrupted(t *testing.T) {
+	var testCases = []struct {
+		err  error
+		want bool
+	}{
+		{errInterrupted, true},
+		{fmt.Errorf("uploading: %w", context.Canceled), true},
+		{fmt.Errorf("uploading: %w", context.DeadlineExceeded), true},
+		{fmt.Errorf("uploading: %w", quota.ErrExhausted), true},
+		{errors.New("503 Service Unavailable"), false},
+		{nil, false},
+	}
+
+	for _, tc := range testCases {
+		if got := interrupted(tc.err); got != tc.want {
+			t.Errorf("want: %t, got: %t, err: %v", tc.want, got, tc.err)
+		}
+	}
+}
+
+func TestShutdown_Deadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	s := newShutdownWithoutSignals(ctx, time.Hour, log.Discard)
+	defer s.release()
+	go s.handle()
+
+	select {
+	case <-s.stopping:
+	case <-time.After(time.Second):
+		t.Fatal("want: stopping once the deadline is reached, got: running")
+	}
+	if !errors.Is(s.ctx.Err(), context.DeadlineExceeded) {
+		t.Errorf("want: %v, got: %v", context.DeadlineExceeded, s.ctx.Err())
+	}
+
+	job := &fakeJob{}
+	if err := s.wrap(job).Process(); !errors.Is(err, errInterrupted) {
+		t.Errorf("want: %v, got: %v", errInterrupted, err)
+	}
+	if job.processed {
+		t.Errorf("want: job not processed once the deadline is reached, got: processed")
+	}
+}
+
+func TestShutdown_StopAfter(t *testing.T) {
+	s := newShutdownWithoutSignals(context.Background(), time.Hour, log.Discard)
+	defer s.release()
+	if s.reachedMaxDuration() {
+		t.Errorf("want: max duration not reached, got: reached")
+	}
+
+	s.stopAfter(20 * time.Millisecond)
+
+	select {
+	case <-s.stopping:
+	case <-time.After(time.Second):
+		t.Fatal("want: stopping once the max duration is reached, got: running")
+	}
+	if !s.reachedMaxDuration() {
+		t.Errorf("want: max duration reached, got: not reached")
+	}
+	if s.ctx.Err() != nil {
+		t.Errorf("want: uploads context not cancelled, got: %v", s.ctx.Err())
+	}
+
+	job := &fakeJob{}
+	if err := s.wrap(job).Process(); !errors.Is(err, errInterrupted) {
+		t.Errorf("want: %v, got: %v", errInterrupted, err)
+	}
+	if job.processed {
+		t.Errorf("want: job not processed once the max duration is reached, got: processed")
+	}
+}
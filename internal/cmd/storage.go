@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/tempdir"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/upload"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/worker"
+)
+
+// errStorageFull is returned by the run once it has been stopped because the storage of the account is full.
+var errStorageFull = errors.New("the storage of the Google account is full, free up space or upgrade the storage, and run the command again")
+
+// errLowFreeSpace is returned by the run once it has been stopped because the free space of the temporary folder is
+// below MinFreeSpace.
+var errLowFreeSpace = errors.New("the free space of the temporary folder is below MinFreeSpace, free up space or use another TempDir, and run the command again")
+
+// storageGuard stops the run once an upload fails because the storage of the account is full, or the free space of
+// the temporary folder is below the minimum, since the uploads not started would fail too. They are not attempted,
+// like when the run is interrupted. It's safe for concurrent use.
+type storageGuard struct {
+	sd     *shutdown
+	logger log.Logger
+
+	mu       sync.Mutex
+	full     bool
+	lowSpace bool
+}
+
+func newStorageGuard(sd *shutdown, logger log.Logger) *storageGuard {
+	return &storageGuard{sd: sd, logger: logger}
+}
+
+// wrap returns the job, stopping the run if it fails because the storage is full, or the free space is low.
+func (g *storageGuard) wrap(job worker.Job) worker.Job {
+	return &storageGuardedJob{Job: job, guard: g}
+}
+
+// isFull returns true once an upload has failed because the storage is full.
+func (g *storageGuard) isFull() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.full
+}
+
+// isLowSpace returns true once an upload has failed because the free space of the temporary folder is low.
+func (g *storageGuard) isLowSpace() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.lowSpace
+}
+
+func (g *storageGuard) stop() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.full || g.lowSpace {
+		return
+	}
+	g.full = true
+	g.logger.Error("The storage of the Google account is full, stopping the run. Files not uploaded will be uploaded on the next run.")
+	g.sd.stop()
+}
+
+func (g *storageGuard) stopLowSpace(err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.full || g.lowSpace {
+		return
+	}
+	g.lowSpace = true
+	g.logger.Errorf("Stopping the run, %s. Files not uploaded will be uploaded on the next run.", err)
+	g.sd.stop()
+}
+
+// storageGuardedJob is a job stopping the run if it fails because the storage is full, or the free space is low.
+type storageGuardedJob struct {
+	worker.Job
+	guard *storageGuard
+}
+
+func (j *storageGuardedJob) Process() error {
+	err := j.Job.Process()
+	switch {
+	case errors.Is(err, upload.ErrStorageFull):
+		j.guard.stop()
+	case errors.Is(err, tempdir.ErrLowFreeSpace):
+		j.guard.stopLowSpace(err)
+	}
+	return err
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/tempdir"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/upload"
+)
+
+type failingJob struct {
+	err error
+}
+
+func (j *failingJob) Process() error {
+	return j.err
+}
+
+func (j *failingJob) ID() string {
+	return "failing"
+}
+
+func TestStorageGuard(t *testing.T) {
+	testCases := []struct {
+		name         string
+		err          error
+		wantFull     bool
+		wantLowSpace bool
+	}{
+		{name: "Should stop if storage is full", err: &upload.StorageFullError{}, wantFull: true},
+		{name: "Should stop if free space is low", err: fmt.Errorf("%w in the volume of '/tmp'", tempdir.ErrLowFreeSpace), wantLowSpace: true},
+		{name: "Should not stop on other failures", err: errors.New("invalid image")},
+		{name: "Should not stop on success"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sd := newShutdownWithoutSignals(context.Background(), time.Hour, log.Discard)
+			defer sd.release()
+			g := newStorageGuard(sd, log.Discard)
+
+			if err := g.wrap(&failingJob{err: tc.err}).Process(); err != tc.err {
+				t.Errorf("want: %v, got: %v", tc.err, err)
+			}
+			if got := g.isFull(); tc.wantFull != got {
+				t.Errorf("want: %t, got: %t", tc.wantFull, got)
+			}
+			if got := g.isLowSpace(); tc.wantLowSpace != got {
+				t.Errorf("want: %t, got: %t", tc.wantLowSpace, got)
+			}
+			if want, got := tc.wantFull || tc.wantLowSpace, sd.stopped(); want != got {
+				t.Errorf("want: %t, got: %t", want, got)
+			}
+		})
+	}
+}
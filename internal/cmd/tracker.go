@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/app"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/cmd/flags"
+)
+
+// trackerFileMode is the mode of the exported tracking file, it's only readable by the owner.
+const trackerFileMode = 0600
+
+// TrackerCmd holds the required data for the tracker cmd
+type TrackerCmd struct {
+	*flags.GlobalFlags
+
+	// reset command flags
+	Prefix string
+	All    bool
+}
+
+func NewTrackerCmd(globalFlags *flags.GlobalFlags) *cobra.Command {
+	cmd := &TrackerCmd{GlobalFlags: globalFlags}
+
+	trackerCmd := &cobra.Command{
+		Use:   "tracker",
+		Short: "Manage the tracking of uploaded files",
+		Long:  `Manage the tracking of uploaded files. Tracked files are not uploaded again.`,
+		Args:  cobra.NoArgs,
+	}
+
+	trackerCmd.AddCommand(&cobra.Command{
+		Use:   "export <file>",
+		Short: "Export the tracked files to a file",
+		Long: `Export the tracked files to a file, as newline-delimited JSON. Every line is a tracked file, like:
+
+  {"path":"/photos/IMG_0001.jpg","hash":"3842009590","mediaItemId":"AGj1epU...","uploadedAt":"2020-05-17T10:30:00Z"}
+
+The mediaItemId and uploadedAt fields are omitted for files tracked by previous versions.`,
+		Args: cobra.ExactArgs(1),
+		RunE: cmd.Export,
+	})
+
+	trackerCmd.AddCommand(&cobra.Command{
+		Use:   "import <file>",
+		Short: "Import the tracked files from a file",
+		Long: `Import the tracked files from a file created by the export command, e.g. to migrate to another computer.
+Entries are merged into the tracked files. If a file is already tracked, the most recently uploaded entry is kept.`,
+		Args: cobra.ExactArgs(1),
+		RunE: cmd.Import,
+	})
+
+	resetCmd := &cobra.Command{
+		Use:   "reset",
+		Short: "Stop tracking uploaded files, so they are uploaded again",
+		Long: `Stop tracking the uploaded files whose path starts with the given prefix, so they are uploaded again on the next push.
+The prefix is matched literally, e.g. '/photos/trip' matches '/photos/trip2' too, use '/photos/trip/' to match only the folder.
+Use --dry-run to list the affected files. Use --all, without prefix, to stop tracking all the files.
+It should not be run while files are being uploaded.`,
+		Args: cobra.NoArgs,
+		RunE: cmd.Reset,
+	}
+	resetCmd.Flags().StringVar(&cmd.Prefix, "prefix", "", "Path prefix of the files to stop tracking")
+	resetCmd.Flags().BoolVar(&cmd.All, "all", false, "Stop tracking all the files, required if no prefix is set")
+	trackerCmd.AddCommand(resetCmd)
+
+	return trackerCmd
+}
+
+func (cmd *TrackerCmd) Export(cobraCmd *cobra.Command, args []string) error {
+	cli, err := app.StartWithoutAuth(cmd.CfgDir, appOptions(cmd.GlobalFlags)...)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = cli.Stop()
+	}()
+
+	f, err := Os.OpenFile(args[0], os.O_CREATE|os.O_TRUNC|os.O_WRONLY, trackerFileMode)
+	if err != nil {
+		return err
+	}
+	n, err := cli.FileTracker.Export(f)
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	cli.Logger.Donef("%d tracked files exported to '%s'", n, args[0])
+	return nil
+}
+
+func (cmd *TrackerCmd) Import(cobraCmd *cobra.Command, args []string) error {
+	cli, err := app.StartWithoutAuth(cmd.CfgDir, appOptions(cmd.GlobalFlags)...)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = cli.Stop()
+	}()
+
+	f, err := Os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	stats, err := cli.FileTracker.Import(f)
+	if err != nil {
+		return fmt.Errorf("unable to import '%s': %s", args[0], err)
+	}
+
+	cli.Logger.Donef("%d tracked files imported from '%s': %d added, %d updated, %d already up to date", stats.Added+stats.Updated, args[0], stats.Added, stats.Updated, stats.Kept)
+	return nil
+}
+
+func (cmd *TrackerCmd) Reset(cobraCmd *cobra.Command, args []string) error {
+	if cmd.Prefix == "" && !cmd.All {
+		return errors.New("--all is required to stop tracking all the files, use --prefix to stop tracking some of them")
+	}
+	if cmd.Prefix != "" && cmd.All {
+		return errors.New("--prefix and --all cannot be specified at the same time")
+	}
+
+	prefix, err := absolutePrefix(cmd.Prefix)
+	if err != nil {
+		return err
+	}
+
+	cli, err := app.StartWithoutAuth(cmd.CfgDir, appOptions(cmd.GlobalFlags)...)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = cli.Stop()
+	}()
+
+	files, err := cli.FileTracker.Reset(prefix, cmd.DryRun)
+	if err != nil {
+		return err
+	}
+
+	if cmd.DryRun {
+		for _, f := range files {
+			cli.Logger.Infof("Would stop tracking '%s'", f)
+		}
+		cli.Logger.Infof("%d tracked files would be reset.", len(files))
+		cli.Logger.Info("Running in dry run mode. No changes has been made.")
+		return nil
+	}
+	cli.Logger.Donef("%d tracked files have been reset, they will be uploaded again.", len(files))
+	return nil
+}
+
+// absolutePrefix returns the absolute path of prefix, like tracked files, keeping the trailing separator.
+func absolutePrefix(prefix string) (string, error) {
+	if prefix == "" {
+		return "", nil
+	}
+	abs, err := filepath.Abs(prefix)
+	if err != nil {
+		return "", err
+	}
+	if strings.HasSuffix(prefix, string(filepath.Separator)) && !strings.HasSuffix(abs, string(filepath.Separator)) {
+		abs += string(filepath.Separator)
+	}
+	return abs, nil
+}
@@ -0,0 +1,104 @@
+package cmd_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/app"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/cmd"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/cmd/flags"
+)
+
+func TestTrackerCmd_Reset(t *testing.T) {
+	tracked := []string{"/photos/trip/a.jpg", "/photos/trip/b.jpg", "/photos/trip2/c.jpg", "/photos/other.jpg"}
+
+	testCases := []struct {
+		name          string
+		args          []string
+		dryRun        bool
+		remaining     int
+		isErrExpected bool
+	}{
+		{"Should reset files with the prefix", []string{"--prefix", "/photos/trip/"}, false, 2, false},
+		{"Should not reset files in dry run mode", []string{"--prefix", "/photos/trip/"}, true, 4, false},
+		{"Should reset all files with --all", []string{"--all"}, false, 0, false},
+		{"Should fail without prefix nor --all", []string{}, false, 4, true},
+		{"Should fail with prefix and --all", []string{"--prefix", "/photos/", "--all"}, false, 4, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := createTrackerTestAppDir(t)
+			defer os.RemoveAll(dir)
+			importTrackedFiles(t, dir, tracked)
+
+			err := executeTrackerCmd(&flags.GlobalFlags{CfgDir: dir, DryRun: tc.dryRun}, append([]string{"reset"}, tc.args...))
+			assertExpectedError(t, tc.isErrExpected, err)
+
+			if got := len(exportTrackedFiles(t, dir)); got != tc.remaining {
+				t.Errorf("want: %d, got: %d", tc.remaining, got)
+			}
+		})
+	}
+}
+
+func executeTrackerCmd(globalFlags *flags.GlobalFlags, args []string) error {
+	c := cmd.NewTrackerCmd(globalFlags)
+	c.SetArgs(args)
+	return c.Execute()
+}
+
+// createTrackerTestAppDir returns an application data dir, with a configuration that doesn't require a keyring.
+func createTrackerTestAppDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "tracker-cmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join(dir, "src")
+	if err := os.Mkdir(src, 0700); err != nil {
+		t.Fatal(err)
+	}
+	cfg := fmt.Sprintf(`{
+  APIAppCredentials: { ClientID: "client-id", ClientSecret: "client-secret" }
+  Account: "youremail@domain.com"
+  SecretsBackendType: "auto"
+  TokenStore: "env"
+  Jobs: [
+    { SourceFolder: %q, CreateAlbums: "Off" }
+  ]
+}`, src)
+	if err := ioutil.WriteFile(filepath.Join(dir, app.DefaultConfigFilename), []byte(cfg), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func importTrackedFiles(t *testing.T, dir string, files []string) {
+	var b bytes.Buffer
+	for _, f := range files {
+		fmt.Fprintf(&b, "{\"path\":%q,\"hash\":\"hash\"}\n", f)
+	}
+	filename := filepath.Join(dir, "import.ndjson")
+	if err := ioutil.WriteFile(filename, b.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := executeTrackerCmd(&flags.GlobalFlags{CfgDir: dir}, []string{"import", filename}); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+}
+
+func exportTrackedFiles(t *testing.T, dir string) [][]byte {
+	filename := filepath.Join(dir, "export.ndjson")
+	if err := executeTrackerCmd(&flags.GlobalFlags{CfgDir: dir}, []string{"export", filename}); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.Fields(b)
+}
@@ -0,0 +1,274 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/app"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/cmd/flags"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/manifest"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/progress"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/ratelimit"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/task"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/upload"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/worker"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/xmp"
+)
+
+// UploadCmd holds the required data for the upload cmd
+type UploadCmd struct {
+	*flags.GlobalFlags
+
+	// command flags
+	Manifest        string
+	ResumeFrom      string
+	NumberOfWorkers int
+	WaitLock        bool
+}
+
+func NewUploadCmd(globalFlags *flags.GlobalFlags) *cobra.Command {
+	cmd := &UploadCmd{GlobalFlags: globalFlags}
+
+	uploadCmd := &cobra.Command{
+		Use:   "upload",
+		Short: "Upload the files of a manifest written by the scan command",
+		Long: `Upload exactly the files of a manifest written by the scan command, without scanning the source folders again.
+Files that have changed since they were scanned fail, they should be scanned again. Files already uploaded are skipped.
+Files are neither deleted nor moved after being uploaded.
+
+Every run has an ID, printed when it starts, and the files completed by the run are recorded as soon as they are.
+If the run is interrupted, e.g. it crashes, it's continued using --resume-from with its ID, instead of --manifest:
+the files of its manifest completed before are skipped. Runs are listed by the 'runs list' command.`,
+		Args: cobra.NoArgs,
+		RunE: cmd.Run,
+	}
+
+	uploadCmd.Flags().StringVar(&cmd.Manifest, "manifest", "", "Manifest with the files to be uploaded (required, unless --resume-from is set)")
+	uploadCmd.Flags().StringVar(&cmd.ResumeFrom, "resume-from", "", "ID of an interrupted run to be continued, skipping the files it has completed")
+	uploadCmd.Flags().IntVar(&cmd.NumberOfWorkers, "workers", 1, "Number of files to be uploaded concurrently (overrides UploadWorkerCount)")
+	uploadCmd.Flags().BoolVar(&cmd.WaitLock, "wait-lock", false, "Wait for another instance using the same config folder to finish, instead of exiting")
+
+	return uploadCmd
+}
+
+func (cmd *UploadCmd) Run(cobraCmd *cobra.Command, args []string) error {
+	if cmd.Manifest == "" && cmd.ResumeFrom == "" {
+		return errors.New("--manifest is required")
+	}
+	if cmd.Manifest != "" && cmd.ResumeFrom != "" {
+		return errors.New("--manifest and --resume-from could not be used together, the manifest of the run is resumed")
+	}
+	if cmd.NumberOfWorkers < 1 {
+		return fmt.Errorf("invalid number of workers: %d", cmd.NumberOfWorkers)
+	}
+	var m manifest.Manifest
+	if cmd.Manifest != "" {
+		var err error
+		m, err = manifest.ReadFile(cmd.Manifest)
+		if err != nil {
+			return fmt.Errorf("unable to read the manifest: %w", err)
+		}
+	}
+
+	ctx := context.Background()
+	lock, err := lockRun(ctx, cmd.CfgDir, cmd.WaitLock)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = lock.Release()
+	}()
+
+	cli, err := app.Start(ctx, cmd.CfgDir, appOptions(cmd.GlobalFlags)...)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = cli.Stop()
+	}()
+
+	// nothing is uploaded in dry-run mode, so there is no run to be resumed, and a resumed one is not changed.
+	var run, recording *manifest.Run
+	if !cmd.DryRun || cmd.ResumeFrom != "" {
+		run, err = cmd.startRun(cli, m)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = run.Close()
+		}()
+		m.Entries = run.Entries
+		if !cmd.DryRun {
+			recording = run
+		}
+	}
+
+	configured := make(map[string]bool)
+	for _, account := range accounts(cli.Config) {
+		configured[account] = true
+	}
+
+	workers := cmd.NumberOfWorkers
+	if !cobraCmd.Flags().Changed("workers") && cli.Config.UploadWorkerCount > 0 {
+		workers = cli.Config.UploadWorkerCount
+	}
+	results := make(chan worker.JobResult, workers*10)
+	queue := worker.NewJobQueueWithResults(workers, results, cli.Logger)
+	queue.Start()
+	defer queue.Stop()
+
+	// the configuration has been validated already.
+	bytesPerSecond, _ := ratelimit.Parse(cli.Config.UploadRateLimit)
+	limiter := ratelimit.NewLimiter(bytesPerSecond)
+	perFileTimeout, _ := time.ParseDuration(cli.Config.PerFileTimeout)
+	tracker := progress.NewTracker()
+
+	// services are created once per account, entries of the same account share them.
+	services := make(map[string]*accountServices)
+	uploads := make(map[string]*upload.TokenReusingUploads)
+
+	var submitted, skipped, albumFailures int
+	var failed []worker.JobResult
+	// submittedEntries are the entries submitted, by their path, to record them as completed once uploaded.
+	submittedEntries := make(map[string]manifest.Entry)
+	for _, e := range m.Entries {
+		if !configured[e.Account] {
+			return fmt.Errorf("account '%s' of file '%s' is not configured", e.Account, e.Path)
+		}
+		if run != nil && run.Completed(e) {
+			cli.Logger.WithFields(log.Fields{"event": log.EventFileSkipped, "path": e.Path, "reason": log.ReasonCompletedInRun}).Infof("Skipping file '%s', it has been completed by the run already.", e.Path)
+			skipped++
+			continue
+		}
+		if e.MediaItemID == "" && cli.FileTracker.Exist(e.Path) {
+			cli.Logger.WithFields(log.Fields{"event": log.EventFileSkipped, "path": e.Path, "reason": log.ReasonAlreadyUploaded}).Infof("Skipping file '%s', it has been uploaded already.", e.Path)
+			completeEntry(cli, recording, e)
+			skipped++
+			continue
+		}
+
+		service, exist := services[e.Account]
+		if !exist {
+			service, err = newAccountServices(ctx, cli, e.Account, limiter, tracker)
+			if err != nil {
+				return err
+			}
+			services[e.Account] = service
+			uploads[e.Account] = service.newUploads(service.photos)
+		}
+
+		job := &task.EnqueuedUpload{
+			Context:     ctx,
+			Uploads:     uploads[e.Account],
+			FileTracker: cli.FileTracker,
+			Logger:      cli.Logger,
+
+			Path:         e.Path,
+			AlbumName:    e.Album,
+			MediaItemID:  e.MediaItemID,
+			AlbumItems:   service.photos.Albums,
+			Favorite:     e.Favorite,
+			DryRun:       cmd.DryRun,
+			Descriptions: xmp.Reader{},
+
+			PerFileTimeout: perFileTimeout,
+			TimeoutRetries: cli.MaxRetries(),
+		}
+		// albums are not created in dry-run mode.
+		if !cmd.DryRun {
+			job.AlbumID, err = service.albums.GetOrCreate(ctx, e.Album)
+			if err != nil {
+				failed = append(failed, worker.JobResult{ID: e.Path, Err: fmt.Errorf("album '%s' could not be created: %w", e.Album, err)})
+				albumFailures++
+				continue
+			}
+		}
+		queue.Submit(&manifestJob{Job: job, entry: e, hashes: cli.ContentHashes})
+		submittedEntries[e.Path] = e
+		submitted++
+	}
+
+	for i := 0; i < submitted; i++ {
+		r := <-results
+		if r.Err != nil {
+			failed = append(failed, r)
+			continue
+		}
+		completeEntry(cli, recording, submittedEntries[r.ID])
+	}
+	for _, r := range failed {
+		logUploadError(cli.Logger, r)
+	}
+	// files whose album could not be created have failed without being submitted.
+	total := submitted + albumFailures
+	cli.Logger.Donef("%d processed files: %d successfully, %d with errors, %d skipped as already uploaded", total, total-len(failed), len(failed), skipped)
+	if cmd.DryRun {
+		cli.Logger.Info("Running in dry run mode. No changes has been made.")
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d files of the manifest could not be uploaded", len(failed))
+	}
+	return nil
+}
+
+// startRun returns the run of the manifest m, recording the entries completed, or the run resumed if
+// --resume-from is set. Its ID is printed, so it could be resumed if it's interrupted.
+func (cmd *UploadCmd) startRun(cli *app.App, m manifest.Manifest) (*manifest.Run, error) {
+	folder := filepath.Join(cmd.CfgDir, manifest.RunsFolder)
+	if cmd.ResumeFrom != "" {
+		run, err := manifest.ResumeRun(folder, cmd.ResumeFrom)
+		if err != nil {
+			return nil, fmt.Errorf("unable to resume the run, use 'runs list' to find its ID: %w", err)
+		}
+		completed := 0
+		for _, e := range run.Entries {
+			if run.Completed(e) {
+				completed++
+			}
+		}
+		cli.Logger.Infof("Resuming run '%s' of manifest '%s': %d of %d files have been completed already.", run.ID, run.Manifest, completed, len(run.Entries))
+		return run, nil
+	}
+	filename, err := filepath.Abs(cmd.Manifest)
+	if err != nil {
+		return nil, err
+	}
+	run, err := manifest.StartRun(folder, filename, m)
+	if err != nil {
+		return nil, fmt.Errorf("unable to write the state of the run: %w", err)
+	}
+	cli.Logger.Infof("Run '%s' started, use `upload --resume-from %s` to continue it if it's interrupted.", run.ID, run.ID)
+	return run, nil
+}
+
+// completeEntry records the entry as completed by the run, if any. The entry is uploaded again when the run is
+// resumed if it could not be recorded, which is harmless since it's tracked.
+func completeEntry(cli *app.App, run *manifest.Run, e manifest.Entry) {
+	if run == nil {
+		return
+	}
+	if err := run.Complete(e); err != nil {
+		cli.Logger.Warnf("Unable to record file '%s' as completed by the run: %s", e.Path, err)
+	}
+}
+
+// manifestJob is the job of a file of the manifest, that fails if it has changed since it was scanned.
+type manifestJob struct {
+	worker.Job
+	entry manifest.Entry
+	// hashes keeps the hash of the file checked, so it's not read again to upload it.
+	hashes *upload.ContentHashes
+}
+
+func (j *manifestJob) Process() error {
+	if err := j.entry.Check(j.hashes); err != nil {
+		return err
+	}
+	return j.Job.Process()
+}
@@ -0,0 +1,387 @@
+package cmd_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/app"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/cmd"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/cmd/flags"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/manifest"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/photosapi/photosapitest"
+)
+
+func TestNewUploadCmd_Manifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	api := photosapitest.NewServer()
+	defer api.Close()
+	createTestEnvironment(t, dir, filepath.Join(dir, "photos"), api.URL)
+	// the content starts as a JPEG file, in order to be accepted by the content type checks.
+	photos := map[string]string{
+		"IMG_0001.jpg": "\xff\xd8\xff\xe0\x00\x10JFIF\x00photo-1",
+		"IMG_0002.jpg": "\xff\xd8\xff\xe0\x00\x10JFIF\x00photo-2",
+	}
+	for name, content := range photos {
+		if err := ioutil.WriteFile(filepath.Join(dir, "photos", name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := os.Setenv(testTokenEnvVar, `{"access_token":"access-token","token_type":"Bearer","expiry":"2999-01-01T00:00:00Z"}`); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv(testTokenEnvVar)
+	globalFlags := &flags.GlobalFlags{CfgDir: filepath.Join(dir, "config")}
+
+	filename := filepath.Join(dir, "manifest.json")
+	c := cmd.NewScanCmd(globalFlags)
+	c.SetArgs([]string{"--output", filename})
+	if err := c.Execute(); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	m, err := manifest.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if len(m.Entries) != 2 {
+		t.Fatalf("want: %d entries, got: %v", 2, m.Entries)
+	}
+	for _, e := range m.Entries {
+		if want := int64(len(photos[filepath.Base(e.Path)])); want != e.Size {
+			t.Errorf("want: %v, got: %v", want, e.Size)
+		}
+		if want := "youremail@domain.com"; want != e.Account {
+			t.Errorf("want: %v, got: %v", want, e.Account)
+		}
+	}
+	if len(api.Uploaded) > 0 {
+		t.Errorf("want: no uploads while scanning, got: %q", api.Uploaded)
+	}
+
+	// only the reviewed entries are uploaded, files not in the manifest are not scanned.
+	var reviewed []manifest.Entry
+	for _, e := range m.Entries {
+		if filepath.Base(e.Path) == "IMG_0001.jpg" {
+			reviewed = append(reviewed, e)
+		}
+	}
+	m.Entries = reviewed
+	if err := manifest.WriteFile(filename, m); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "photos", "IMG_0003.jpg"), []byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00photo-3"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// the second upload doesn't upload the file again, since it has been tracked.
+	for i := 0; i < 2; i++ {
+		c := cmd.NewUploadCmd(globalFlags)
+		c.SetArgs([]string{"--manifest", filename})
+		if err := c.Execute(); err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+	}
+
+	api.Lock()
+	defer api.Unlock()
+	if want := photos["IMG_0001.jpg"]; len(api.Uploaded) != 1 || api.Uploaded[0] != want {
+		t.Errorf("want: [%q], got: %q", want, api.Uploaded)
+	}
+	if len(api.Unexpected) > 0 {
+		t.Errorf("unexpected requests: %v", api.Unexpected)
+	}
+}
+
+func TestNewUploadCmd_ManifestChanged(t *testing.T) {
+	dir, err := ioutil.TempDir("", "upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	api := photosapitest.NewServer()
+	defer api.Close()
+	createTestEnvironment(t, dir, filepath.Join(dir, "photos"), api.URL)
+	photo := filepath.Join(dir, "photos", "IMG_0001.jpg")
+	if err := ioutil.WriteFile(photo, []byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00photo"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Setenv(testTokenEnvVar, `{"access_token":"access-token","token_type":"Bearer","expiry":"2999-01-01T00:00:00Z"}`); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv(testTokenEnvVar)
+	globalFlags := &flags.GlobalFlags{CfgDir: filepath.Join(dir, "config")}
+
+	filename := filepath.Join(dir, "manifest.json")
+	c := cmd.NewScanCmd(globalFlags)
+	c.SetArgs([]string{"--output", filename})
+	if err := c.Execute(); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	// the file changes once it has been reviewed.
+	if err := ioutil.WriteFile(photo, []byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00edited"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	c = cmd.NewUploadCmd(globalFlags)
+	c.SetArgs([]string{"--manifest", filename})
+	if err := c.Execute(); err == nil {
+		t.Errorf("error was expected, but not produced")
+	}
+
+	api.Lock()
+	defer api.Unlock()
+	if len(api.Uploaded) > 0 {
+		t.Errorf("want: no uploads, got: %q", api.Uploaded)
+	}
+}
+
+func TestNewScanCmd_Routes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, d := range []string{"config", "photos"} {
+		if err := os.MkdirAll(filepath.Join(dir, d), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := map[string]string{
+		"IMG_0001.jpg": "\xff\xd8\xff\xe0\x00\x10JFIF\x00photo-1",
+		"IMG_0002.raf": "FUJIFILMCCD-RAW raw-2",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, "photos", name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfgContent := fmt.Sprintf(`{
+  ConfigVersion: 2
+  APIAppCredentials: { ClientID: "client-id", ClientSecret: "client-secret" }
+  Account: "youremail@domain.com"
+  Accounts: [
+    { Name: "archive", Account: "archive@domain.com" }
+  ]
+  SecretsBackendType: "auto"
+  TokenStore: "env"
+  TokenStoreEnvVar: %q
+  Jobs: [
+    {
+      SourceFolder: %q
+      CreateAlbums: "Off"
+      IncludePatterns: ["_ALL_FILES_"]
+      Routes: [
+        { Match: ["_RAW_EXTENSIONS_"], Account: "archive", Album: "Raw" }
+      ]
+    }
+  ]
+}`, testTokenEnvVar, filepath.Join(dir, "photos"))
+	if err := ioutil.WriteFile(filepath.Join(dir, "config", app.DefaultConfigFilename), []byte(cfgContent), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	filename := filepath.Join(dir, "manifest.json")
+	c := cmd.NewScanCmd(&flags.GlobalFlags{CfgDir: filepath.Join(dir, "config")})
+	c.SetArgs([]string{"--output", filename})
+	if err := c.Execute(); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	m, err := manifest.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if len(m.Entries) != 2 {
+		t.Fatalf("want: %d entries, got: %v", 2, m.Entries)
+	}
+
+	// routed files are written with the account and album of their route.
+	want := map[string]manifest.Entry{
+		"IMG_0001.jpg": {Account: "youremail@domain.com"},
+		"IMG_0002.raf": {Account: "archive@domain.com", Album: "Raw"},
+	}
+	for _, e := range m.Entries {
+		w := want[filepath.Base(e.Path)]
+		if e.Account != w.Account || e.Album != w.Album {
+			t.Errorf("%s: want: %s/%s, got: %s/%s", filepath.Base(e.Path), w.Account, w.Album, e.Account, e.Album)
+		}
+	}
+}
+
+func TestNewScanCmd_RecoverTracker(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	createTestEnvironment(t, dir, filepath.Join(dir, "photos"), "http://127.0.0.1:0")
+	// the CURRENT file of the tracking store, pointing to its manifest, is corrupted.
+	store := filepath.Join(dir, "config", "uploads.db")
+	if err := os.MkdirAll(store, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(store, "CURRENT"), []byte("garbage"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(dir, "manifest.json")
+
+	c := cmd.NewScanCmd(&flags.GlobalFlags{CfgDir: filepath.Join(dir, "config")})
+	c.SetArgs([]string{"--output", filename})
+	if err := c.Execute(); err == nil || !strings.Contains(err.Error(), "--recover-tracker") {
+		t.Fatalf("want: error suggesting --recover-tracker, got: %v", err)
+	}
+
+	c = cmd.NewScanCmd(&flags.GlobalFlags{CfgDir: filepath.Join(dir, "config"), RecoverTracker: true})
+	c.SetArgs([]string{"--output", filename})
+	if err := c.Execute(); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	moved, err := filepath.Glob(store + ".corrupted-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moved) != 1 {
+		t.Errorf("want: the corrupted store moved aside, got: %v", moved)
+	}
+}
+
+func TestNewUploadCmd_ResumeFrom(t *testing.T) {
+	dir, err := ioutil.TempDir("", "upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	api := photosapitest.NewServer()
+	defer api.Close()
+	createTestEnvironment(t, dir, filepath.Join(dir, "photos"), api.URL)
+	photos := []string{
+		"\xff\xd8\xff\xe0\x00\x10JFIF\x00photo-1",
+		"\xff\xd8\xff\xe0\x00\x10JFIF\x00photo-2",
+		"\xff\xd8\xff\xe0\x00\x10JFIF\x00photo-3",
+	}
+	for i, content := range photos {
+		if err := ioutil.WriteFile(filepath.Join(dir, "photos", fmt.Sprintf("IMG_000%d.jpg", i+1)), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Setenv(testTokenEnvVar, `{"access_token":"access-token","token_type":"Bearer","expiry":"2999-01-01T00:00:00Z"}`); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv(testTokenEnvVar)
+	globalFlags := &flags.GlobalFlags{CfgDir: filepath.Join(dir, "config")}
+
+	filename := filepath.Join(dir, "manifest.json")
+	c := cmd.NewScanCmd(globalFlags)
+	c.SetArgs([]string{"--output", filename})
+	if err := c.Execute(); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	// the run stops partway: the third file fails, and the process crashes while recording the second one.
+	api.Lock()
+	api.UploadFailure = photos[2]
+	api.Unlock()
+	c = cmd.NewUploadCmd(globalFlags)
+	c.SetArgs([]string{"--manifest", filename})
+	if err := c.Execute(); err == nil {
+		t.Fatalf("error was expected, but not produced")
+	}
+	runs, err := manifest.ListRuns(filepath.Join(dir, "config", manifest.RunsFolder))
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if len(runs) != 1 || runs[0].Completed != 2 {
+		t.Fatalf("want: a run with 2 files completed, got: %+v", runs)
+	}
+	id := runs[0].ID
+	state := filepath.Join(dir, "config", manifest.RunsFolder, id+".jsonl")
+	b, err := ioutil.ReadFile(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(string(b), "\n")
+	torn := lines[len(lines)-2]
+	if err := ioutil.WriteFile(state, []byte(strings.Join(lines[:len(lines)-2], "")+torn[:len(torn)/2]), 0600); err != nil {
+		t.Fatal(err)
+	}
+	// the manifest is not needed to resume the run.
+	if err := os.Remove(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	// the run is discoverable by its ID.
+	var out bytes.Buffer
+	c = cmd.NewRunsCmd(globalFlags)
+	c.SetOut(&out)
+	c.SetArgs([]string{"list"})
+	if err := c.Execute(); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if !strings.Contains(out.String(), id) || !strings.Contains(out.String(), "1/3") {
+		t.Errorf("want: run %s with 1 of 3 files completed, got: %s", id, out.String())
+	}
+
+	api.Lock()
+	api.UploadFailure = ""
+	api.Uploaded = nil
+	api.Unlock()
+	c = cmd.NewUploadCmd(globalFlags)
+	c.SetArgs([]string{"--resume-from", id})
+	if err := c.Execute(); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	api.Lock()
+	defer api.Unlock()
+	// the upload session of the failed file, kept to be resumed, is queried with an empty request first.
+	var uploaded []string
+	for _, content := range api.Uploaded {
+		if content != "" {
+			uploaded = append(uploaded, content)
+		}
+	}
+	if len(uploaded) != 1 || uploaded[0] != photos[2] {
+		t.Errorf("want: [%q], got: %q", photos[2], uploaded)
+	}
+	runs, err = manifest.ListRuns(filepath.Join(dir, "config", manifest.RunsFolder))
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if len(runs) != 1 || runs[0].ID != id || runs[0].Completed != 3 {
+		t.Errorf("want: run %s with 3 files completed, got: %+v", id, runs)
+	}
+}
+
+func TestNewUploadCmd_ResumeFromUnknownRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	createTestEnvironment(t, dir, filepath.Join(dir, "photos"), "http://127.0.0.1:0")
+	if err := os.Setenv(testTokenEnvVar, `{"access_token":"access-token","token_type":"Bearer","expiry":"2999-01-01T00:00:00Z"}`); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv(testTokenEnvVar)
+
+	c := cmd.NewUploadCmd(&flags.GlobalFlags{CfgDir: filepath.Join(dir, "config")})
+	c.SetArgs([]string{"--resume-from", "20230701-120000-a1b2c3"})
+	if err := c.Execute(); !errors.Is(err, manifest.ErrRunNotFound) {
+		t.Errorf("want: %v, got: %v", manifest.ErrRunNotFound, err)
+	}
+}
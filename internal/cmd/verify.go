@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/gphotosuploader/google-photos-api-client-go/v2/media_items"
+	"github.com/spf13/cobra"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/app"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/cmd/flags"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/config"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/library"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/ratelimit"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/verify"
+)
+
+// defaultVerifyRate is the default number of media items verified per second.
+const defaultVerifyRate = 5
+
+// VerifyCmd holds the required data for the verify cmd
+type VerifyCmd struct {
+	*flags.GlobalFlags
+
+	// command flags
+	Fix  bool
+	Rate int
+}
+
+func NewVerifyCmd(globalFlags *flags.GlobalFlags) *cobra.Command {
+	cmd := &VerifyCmd{GlobalFlags: globalFlags}
+
+	verifyCmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Verify that tracked files still exist in Google Photos",
+		Long: `Check, for every tracked file, that its media item still exists in Google Photos.
+It reports tracked files that don't exist locally anymore, and tracked files missing in Google Photos.
+Use --fix to stop tracking the missing ones, so they will be uploaded again on the next push.`,
+		Args: cobra.NoArgs,
+		RunE: cmd.Run,
+	}
+
+	verifyCmd.Flags().BoolVar(&cmd.Fix, "fix", false, "Stop tracking files missing in Google Photos, so they are uploaded again")
+	verifyCmd.Flags().IntVar(&cmd.Rate, "rate", defaultVerifyRate, "Maximum number of media items verified per second")
+
+	return verifyCmd
+}
+
+func (cmd *VerifyCmd) Run(cobraCmd *cobra.Command, args []string) error {
+	if cmd.Rate < 1 {
+		return fmt.Errorf("invalid rate: %d", cmd.Rate)
+	}
+
+	ctx := context.Background()
+	cli, err := app.Start(ctx, cmd.CfgDir, appOptions(cmd.GlobalFlags)...)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = cli.Stop()
+	}()
+
+	// all the accounts share the limiter, since quotas are per project.
+	limiter := ratelimit.NewLimiter(int64(cmd.Rate))
+
+	var total verify.Report
+	for _, account := range accounts(cli.Config) {
+		account := account
+		client, err := cli.ClientForAccount(ctx, account)
+		if err != nil {
+			return err
+		}
+		mediaItems, err := media_items.NewPhotosLibraryClientWithURL(client, library.NewEndpoints(cli.Config.PhotosAPIBaseURL).BaseURL)
+		if err != nil {
+			return err
+		}
+
+		cli.Logger.Infof("Verifying files uploaded to account '%s'.", account)
+		v := verify.Verifier{
+			MediaItems:  mediaItems,
+			FileTracker: cli.FileTracker,
+			Logger:      cli.Logger,
+			Limiter:     limiter,
+			Include: func(file string) bool {
+				return fileAccount(cli.Config, file) == account
+			},
+			Fix: cmd.Fix && !cmd.DryRun,
+		}
+		report, err := v.Run(ctx)
+		total.Checked += report.Checked
+		total.Orphaned = append(total.Orphaned, report.Orphaned...)
+		total.Missing = append(total.Missing, report.Missing...)
+		total.Unverifiable = append(total.Unverifiable, report.Unverifiable...)
+		total.Pruned = append(total.Pruned, report.Pruned...)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, f := range total.Orphaned {
+		cli.Logger.Warnf("Tracked file does not exist locally: %s", f)
+	}
+	for _, f := range total.Missing {
+		cli.Logger.Warnf("Tracked file is missing in Google Photos: %s", f)
+	}
+	if len(total.Unverifiable) > 0 {
+		cli.Logger.Infof("%d tracked files could not be verified, they were uploaded by a previous version.", len(total.Unverifiable))
+	}
+	cli.Logger.Donef("%d verified files: %d missing in Google Photos, %d not existing locally", total.Checked, len(total.Missing), len(total.Orphaned))
+
+	switch {
+	case len(total.Pruned) > 0:
+		cli.Logger.Donef("%d missing files are not tracked anymore, they will be uploaded again.", len(total.Pruned))
+	case len(total.Missing) > 0 && cmd.DryRun:
+		cli.Logger.Infof("Running in dry run mode. %d missing files would not be tracked anymore.", len(total.Missing))
+	case len(total.Missing) > 0:
+		cli.Logger.Infof("Run it with --fix to upload the missing files again on the next push.")
+	}
+	return nil
+}
+
+// accounts returns all the configured accounts, without duplicates.
+func accounts(cfg *config.Config) []string {
+	result := []string{cfg.Account}
+	seen := map[string]bool{cfg.Account: true}
+	for _, a := range cfg.Accounts {
+		if !seen[a.Account] {
+			result = append(result, a.Account)
+			seen[a.Account] = true
+		}
+	}
+	return result
+}
+
+// fileAccount returns the account where a tracked file was uploaded. It's the account of the job
+// with the longest SourceFolder containing the file, or the default Account if there is none.
+func fileAccount(cfg *config.Config, file string) string {
+	account, longest := cfg.Account, 0
+	for _, job := range cfg.Jobs {
+		folder := filepath.Clean(job.SourceFolder)
+		if !strings.HasPrefix(file, folder+string(filepath.Separator)) || len(folder) <= longest {
+			continue
+		}
+		if a, err := cfg.JobAccount(job); err == nil {
+			account, longest = a, len(folder)
+		}
+	}
+	return account
+}
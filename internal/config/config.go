@@ -1,16 +1,28 @@
 package config
 
 import (
-	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/hjson/hjson-go"
 	"github.com/mitchellh/go-homedir"
 	"github.com/spf13/afero"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/filter"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/ratelimit"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/transport"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/trash"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/units"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/upload"
 )
 
 // Create returns the configuration data after creating file with default settings.
@@ -22,6 +34,21 @@ func Create(fs afero.Fs, filename string) (*Config, error) {
 	return &cfg, nil
 }
 
+// Default returns the configuration with the default settings, as written by Create. Its credentials, account
+// and source folder are placeholders to be replaced.
+func Default() Config {
+	return defaultSettings()
+}
+
+// Write writes the configuration data to the file named by filename. It returns the first problem found,
+// without writing the file, if the configuration is not valid.
+func Write(fs afero.Fs, filename string, cfg Config) error {
+	if err := cfg.validate(fs); err != nil {
+		return err
+	}
+	return cfg.writeFile(fs, filename)
+}
+
 // FromFile returns the configuration data read from the specified file.
 // FromFile returns a ParseError{} if the configuration validation fails.
 func FromFile(fs afero.Fs, filename string) (*Config, error) {
@@ -36,6 +63,30 @@ func FromFile(fs afero.Fs, filename string) (*Config, error) {
 	return cfg, nil
 }
 
+// StdinName is the name of the configuration read from the standard input, e.g. using `--config -`.
+const StdinName = "-"
+
+// FromReader returns the configuration data read from r, e.g. the standard input, like FromFile does.
+// Relative paths of patterns files are relative to the current folder.
+func FromReader(fs afero.Fs, r io.Reader) (*Config, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	raw, migrations, err := parseRaw(b)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := fromRaw(fs, raw, migrations, StdinName)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.validate(fs); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
 // Exists checks the existence of the configuration file
 func Exists(fs afero.Fs, filename string) bool {
 	filename = normalizePath(filename)
@@ -48,36 +99,160 @@ func Exists(fs afero.Fs, filename string) bool {
 // SafePrint returns the configuration, removing sensible fields.
 func (c Config) SafePrint() string {
 	printableConfig := struct {
-		APIAppCredentials APIAppCredentials
-		Account string
-		SecretsBackendType string
-		Jobs []FolderUploadJob
+		ConfigVersion          int `json:",omitempty"`
+		APIAppCredentials      APIAppCredentials
+		Account                string
+		Accounts               []NamedAccount `json:",omitempty"`
+		SecretsBackendType     string
+		TokenStore             string            `json:",omitempty"`
+		TokenStoreEnvVar       string            `json:",omitempty"`
+		UploadWorkerCount      int               `json:",omitempty"`
+		ScanWorkerCount        int               `json:",omitempty"`
+		UploadRateLimit        string            `json:",omitempty"`
+		UploadChunkSize        string            `json:",omitempty"`
+		MaxRetries             int               `json:",omitempty"`
+		RetryBaseDelay         string            `json:",omitempty"`
+		PerFileTimeout         string            `json:",omitempty"`
+		FSRetries              int               `json:",omitempty"`
+		FSRetryDelay           string            `json:",omitempty"`
+		RetryableMessages      []string          `json:",omitempty"`
+		MaxUploadAttempts      int               `json:",omitempty"`
+		DailyRequestBudget     int               `json:",omitempty"`
+		UploadOrder            string            `json:",omitempty"`
+		MinFileAge             string            `json:",omitempty"`
+		StableSizeCheck        bool              `json:",omitempty"`
+		MaxPhotoSize           string            `json:",omitempty"`
+		MaxVideoSize           string            `json:",omitempty"`
+		MIMEDetection          string            `json:",omitempty"`
+		UnicodeNormalization   string            `json:",omitempty"`
+		OnUnsupported          string            `json:",omitempty"`
+		DedupStrategy          string            `json:",omitempty"`
+		DedupWithinRun         bool              `json:",omitempty"`
+		NearDuplicateThreshold int               `json:",omitempty"`
+		ShareUploadTokens      bool              `json:",omitempty"`
+		DedupLibrarySearch     bool              `json:",omitempty"`
+		TrackerBackend         string            `json:",omitempty"`
+		TrackerDBPath          string            `json:",omitempty"`
+		TempDir                string            `json:",omitempty"`
+		MinFreeSpace           string            `json:",omitempty"`
+		ControlDir             string            `json:",omitempty"`
+		NotifyWebhook          string            `json:",omitempty"`
+		NotifyOn               string            `json:",omitempty"`
+		NotifyTimeout          string            `json:",omitempty"`
+		OnUploadCommand        string            `json:",omitempty"`
+		OnUploadFatal          bool              `json:",omitempty"`
+		OnUploadTimeout        string            `json:",omitempty"`
+		PhotosAPIBaseURL       string            `json:",omitempty"`
+		ProxyURL               string            `json:",omitempty"`
+		UserAgent              string            `json:",omitempty"`
+		RequestHeaders         map[string]string `json:",omitempty"`
+		MaxConnections         int               `json:",omitempty"`
+		Jobs                   []FolderUploadJob
 	}{
+		ConfigVersion: c.ConfigVersion,
 		APIAppCredentials: APIAppCredentials{
-			ClientID:     c.APIAppCredentials.ClientID,
-			ClientSecret: "REMOVED",
+			ClientID:          c.APIAppCredentials.ClientID,
+			ClientSecret:      "REMOVED",
+			ServiceAccountKey: c.APIAppCredentials.ServiceAccountKey,
+			Subject:           c.APIAppCredentials.Subject,
 		},
-		Account: c.Account,
-		SecretsBackendType: c.SecretsBackendType,
-		Jobs: c.Jobs,
+		Account:                c.Account,
+		Accounts:               c.Accounts,
+		SecretsBackendType:     c.SecretsBackendType,
+		TokenStore:             c.TokenStore,
+		TokenStoreEnvVar:       c.TokenStoreEnvVar,
+		UploadWorkerCount:      c.UploadWorkerCount,
+		ScanWorkerCount:        c.ScanWorkerCount,
+		UploadRateLimit:        c.UploadRateLimit,
+		UploadChunkSize:        c.UploadChunkSize,
+		MaxRetries:             c.MaxRetries,
+		RetryBaseDelay:         c.RetryBaseDelay,
+		PerFileTimeout:         c.PerFileTimeout,
+		FSRetries:              c.FSRetries,
+		FSRetryDelay:           c.FSRetryDelay,
+		RetryableMessages:      c.RetryableMessages,
+		MaxUploadAttempts:      c.MaxUploadAttempts,
+		DailyRequestBudget:     c.DailyRequestBudget,
+		UploadOrder:            c.UploadOrder,
+		MinFileAge:             c.MinFileAge,
+		StableSizeCheck:        c.StableSizeCheck,
+		MaxPhotoSize:           c.MaxPhotoSize,
+		MaxVideoSize:           c.MaxVideoSize,
+		MIMEDetection:          c.MIMEDetection,
+		UnicodeNormalization:   c.UnicodeNormalization,
+		OnUnsupported:          c.OnUnsupported,
+		DedupStrategy:          c.DedupStrategy,
+		DedupWithinRun:         c.DedupWithinRun,
+		NearDuplicateThreshold: c.NearDuplicateThreshold,
+		ShareUploadTokens:      c.ShareUploadTokens,
+		DedupLibrarySearch:     c.DedupLibrarySearch,
+		TrackerBackend:         c.TrackerBackend,
+		TrackerDBPath:          c.TrackerDBPath,
+		TempDir:                c.TempDir,
+		MinFreeSpace:           c.MinFreeSpace,
+		ControlDir:             c.ControlDir,
+		NotifyWebhook:          c.NotifyWebhook,
+		NotifyOn:               c.NotifyOn,
+		NotifyTimeout:          c.NotifyTimeout,
+		OnUploadCommand:        c.OnUploadCommand,
+		OnUploadFatal:          c.OnUploadFatal,
+		OnUploadTimeout:        c.OnUploadTimeout,
+		PhotosAPIBaseURL:       c.PhotosAPIBaseURL,
+		ProxyURL:               redactURL(c.ProxyURL),
+		UserAgent:              c.UserAgent,
+		RequestHeaders:         redactHeaders(c.RequestHeaders),
+		MaxConnections:         c.MaxConnections,
+		Jobs:                   c.Jobs,
 	}
 	b, _ := json.Marshal(printableConfig)
 	return fmt.Sprint(string(b))
 }
 
-// validate validates the current configuration.
-func (c Config) validate(fs afero.Fs) error {
-	if err := c.validateSecretsBackendType(); err != nil {
-		return err
+// JobAccount returns the Google Photos account where the job uploads files.
+// The job references an account by its name in Accounts, or uses Account if it doesn't reference any.
+func (c Config) JobAccount(job FolderUploadJob) (string, error) {
+	if account, ok := c.namedAccount(job.Account); ok {
+		return account, nil
 	}
-	if err := c.validateAPIAppCredentials(); err != nil {
-		return err
+	return "", fmt.Errorf("option Account '%s' of job '%s' is invalid, it's not in Accounts", job.Account, job.SourceFolder)
+}
+
+// Library returns the folders of the library of the job, if it has been expanded from SourceFolders, or an
+// empty string otherwise. Jobs of the same library have the same one.
+func (job FolderUploadJob) Library() string {
+	return job.library
+}
+
+// RouteAccount returns the Google Photos account where the route of the job uploads files.
+// The route references an account by its name in Accounts, or uses the account of the job if it doesn't reference any.
+func (c Config) RouteAccount(job FolderUploadJob, route Route) (string, error) {
+	if route.Account == "" {
+		return c.JobAccount(job)
 	}
-	if err := c.validateAccount(); err != nil {
-		return err
+	if account, ok := c.namedAccount(route.Account); ok {
+		return account, nil
 	}
-	if err := c.validateJobs(fs); err != nil {
-		return err
+	return "", fmt.Errorf("option Account '%s' of route of job '%s' is invalid, it's not in Accounts", route.Account, job.SourceFolder)
+}
+
+// namedAccount returns the account referenced by its name in Accounts, or Account if the name is empty.
+func (c Config) namedAccount(name string) (string, bool) {
+	if name == "" || name == c.Account {
+		return c.Account, true
+	}
+	for _, a := range c.Accounts {
+		if a.Name == name || a.Account == name {
+			return a.Account, true
+		}
+	}
+	return "", false
+}
+
+// validate validates the current configuration.
+// It returns the first problem found, use Diagnose to get all of them.
+func (c Config) validate(fs afero.Fs) error {
+	if problems := c.Diagnose(fs); len(problems) > 0 {
+		return problems[0].Err
 	}
 	return nil
 }
@@ -96,18 +271,53 @@ func (c Config) writeFile(fs afero.Fs, filename string) error {
 
 // readFile loads the configuration data reading the file named by filename.
 func readFile(fs afero.Fs, filename string) (*Config, error) {
-	b, err := afero.ReadFile(fs, filename)
+	raw, migrations, err := readRaw(fs, filename)
 	if err != nil {
 		return nil, err
 	}
+	return fromRaw(fs, raw, migrations, filename)
+}
+
+// fromRaw returns the configuration data of the raw configuration, read from filename, with the changes
+// made to upgrade it. Relative paths of patterns files are relative to the folder of filename.
+func fromRaw(fs afero.Fs, raw map[string]interface{}, migrations []string, filename string) (*Config, error) {
+	// string values could reference environment variables, e.g. to keep secrets out of the file.
+	if _, err := expandEnv(raw, "", os.LookupEnv); err != nil {
+		return nil, err
+	}
 
 	config := Config{}
-	if err := unmarshalReader(bytes.NewReader(b), &config); err != nil {
+	if err := unmarshalRaw(raw, &config); err != nil {
 		return nil, err
 	}
+	config.Migrations = migrations
 
 	// convert all path to absolute paths.
-	if err := config.ensureSourceFolderAbsolutePaths(); err != nil {
+	if err := config.ensureJobsAbsolutePaths(); err != nil {
+		return nil, err
+	}
+	if err := config.ensurePatternsFilesAbsolutePaths(filepath.Dir(normalizePath(filename))); err != nil {
+		return nil, err
+	}
+	if err := config.readJobsPatternsFiles(fs); err != nil {
+		return nil, err
+	}
+	if err := config.expandJobsLibraries(); err != nil {
+		return nil, err
+	}
+	if err := config.expandJobsSourceFolders(fs); err != nil {
+		return nil, err
+	}
+	if err := config.ensureServiceAccountKeyAbsolutePath(); err != nil {
+		return nil, err
+	}
+	if err := config.ensureTrackerDBAbsolutePath(); err != nil {
+		return nil, err
+	}
+	if err := config.ensureTempDirAbsolutePath(); err != nil {
+		return nil, err
+	}
+	if err := config.ensureControlDirAbsolutePath(); err != nil {
 		return nil, err
 	}
 
@@ -115,7 +325,15 @@ func readFile(fs afero.Fs, filename string) (*Config, error) {
 }
 
 func (c Config) validateAPIAppCredentials() error {
-	if c.APIAppCredentials.ClientID == "" || c.APIAppCredentials.ClientSecret == "" {
+	creds := c.APIAppCredentials
+	switch {
+	case creds.UsesServiceAccount() && (creds.ClientID != "" || creds.ClientSecret != ""):
+		return errors.New("option APIAppCredentials are invalid, ServiceAccountKey could not be set with ClientID and ClientSecret")
+	case creds.UsesServiceAccount():
+		return nil
+	case creds.Subject != "":
+		return errors.New("option APIAppCredentials are invalid, Subject requires ServiceAccountKey")
+	case creds.ClientID == "" || creds.ClientSecret == "":
 		return errors.New("option APIAppCredentials are invalid")
 	}
 	return nil
@@ -128,22 +346,481 @@ func (c Config) validateAccount() error {
 	return nil
 }
 
-func (c Config) validateJobs(fs afero.Fs) error {
-	if len(c.Jobs) < 1 {
-		return errors.New("at least one Job must be configured")
+func (c Config) validateAccounts() error {
+	names := make(map[string]bool)
+	for _, a := range c.Accounts {
+		if a.Name == "" || a.Account == "" {
+			return fmt.Errorf("option Accounts is invalid, name and account could not be empty, '%s'", a.Name)
+		}
+		if names[a.Name] {
+			return fmt.Errorf("option Accounts is invalid, name '%s' is used more than once", a.Name)
+		}
+		names[a.Name] = true
+	}
+	return nil
+}
+
+func (c Config) validateUploadWorkerCount() error {
+	if c.UploadWorkerCount < 0 {
+		return fmt.Errorf("option UploadWorkerCount is invalid, '%d'", c.UploadWorkerCount)
 	}
+	return nil
+}
 
-	for _, job := range c.Jobs {
-		exist, err := afero.DirExists(fs, job.SourceFolder)
-		if err != nil {
-			return fmt.Errorf("option SourceFolder '%s' is invalid, err=%s", job.SourceFolder, err)
+func (c Config) validateScanWorkerCount() error {
+	if c.ScanWorkerCount < 0 {
+		return fmt.Errorf("option ScanWorkerCount is invalid, '%d'", c.ScanWorkerCount)
+	}
+	return nil
+}
+
+func (c Config) validateUploadRateLimit() error {
+	if _, err := ratelimit.Parse(c.UploadRateLimit); err != nil {
+		return fmt.Errorf("option UploadRateLimit is invalid, '%s'", c.UploadRateLimit)
+	}
+	return nil
+}
+
+func (c Config) validateMaxRetries() error {
+	if c.MaxRetries < -1 {
+		return fmt.Errorf("option MaxRetries is invalid, '%d'", c.MaxRetries)
+	}
+	return nil
+}
+
+func (c Config) validateMaxConnections() error {
+	if c.MaxConnections < 0 {
+		return fmt.Errorf("option MaxConnections is invalid, '%d'", c.MaxConnections)
+	}
+	return nil
+}
+
+func (c Config) validateRetryBaseDelay() error {
+	if c.RetryBaseDelay == "" {
+		return nil
+	}
+	if d, err := time.ParseDuration(c.RetryBaseDelay); err != nil || d <= 0 {
+		return fmt.Errorf("option RetryBaseDelay is invalid, '%s'", c.RetryBaseDelay)
+	}
+	return nil
+}
+
+func (c Config) validatePerFileTimeout() error {
+	if c.PerFileTimeout == "" {
+		return nil
+	}
+	if d, err := time.ParseDuration(c.PerFileTimeout); err != nil || d <= 0 {
+		return fmt.Errorf("option PerFileTimeout is invalid, '%s'", c.PerFileTimeout)
+	}
+	return nil
+}
+
+func (c Config) validateFSRetries() error {
+	if c.FSRetries < -1 {
+		return fmt.Errorf("option FSRetries is invalid, '%d'", c.FSRetries)
+	}
+	return nil
+}
+
+func (c Config) validateFSRetryDelay() error {
+	if c.FSRetryDelay == "" {
+		return nil
+	}
+	if d, err := time.ParseDuration(c.FSRetryDelay); err != nil || d <= 0 {
+		return fmt.Errorf("option FSRetryDelay is invalid, '%s'", c.FSRetryDelay)
+	}
+	return nil
+}
+
+func (c Config) validateRetryableMessages() error {
+	for _, m := range c.RetryableMessages {
+		if strings.TrimSpace(m) == "" {
+			return fmt.Errorf("option RetryableMessages is invalid, '%s' would match every error", m)
 		}
-		if !exist {
-			return fmt.Errorf("folder '%s' does not exist", job.SourceFolder)
+	}
+	return nil
+}
+
+func (c Config) validateMaxUploadAttempts() error {
+	if c.MaxUploadAttempts < 0 {
+		return fmt.Errorf("option MaxUploadAttempts is invalid, '%d'", c.MaxUploadAttempts)
+	}
+	return nil
+}
+
+func (c Config) validateDailyRequestBudget() error {
+	if c.DailyRequestBudget < 0 {
+		return fmt.Errorf("option DailyRequestBudget is invalid, '%d'", c.DailyRequestBudget)
+	}
+	return nil
+}
+
+func (c Config) validateUploadOrder() error {
+	switch c.UploadOrder {
+	case "", "none", "mtime-asc", "mtime-desc", "name":
+		return nil
+	}
+	return fmt.Errorf("option UploadOrder is invalid, '%s'", c.UploadOrder)
+}
+
+func (c Config) validateMinFileAge() error {
+	if c.MinFileAge == "" {
+		return nil
+	}
+	if d, err := time.ParseDuration(c.MinFileAge); err != nil || d < 0 {
+		return fmt.Errorf("option MinFileAge is invalid, '%s'", c.MinFileAge)
+	}
+	return nil
+}
+
+func (c Config) validateUploadChunkSize() error {
+	if _, err := units.ParseSize(c.UploadChunkSize); err != nil {
+		return fmt.Errorf("option UploadChunkSize is invalid, '%s'", c.UploadChunkSize)
+	}
+	return nil
+}
+
+func (c Config) validateMaxPhotoSize() error {
+	if _, err := units.ParseSize(c.MaxPhotoSize); err != nil {
+		return fmt.Errorf("option MaxPhotoSize is invalid, '%s'", c.MaxPhotoSize)
+	}
+	return nil
+}
+
+func (c Config) validateMaxVideoSize() error {
+	if _, err := units.ParseSize(c.MaxVideoSize); err != nil {
+		return fmt.Errorf("option MaxVideoSize is invalid, '%s'", c.MaxVideoSize)
+	}
+	return nil
+}
+
+func (c Config) validateMinFreeSpace() error {
+	if _, err := units.ParseSize(c.MinFreeSpace); err != nil {
+		return fmt.Errorf("option MinFreeSpace is invalid, '%s'", c.MinFreeSpace)
+	}
+	return nil
+}
+
+func (c Config) validateMIMEDetection() error {
+	switch c.MIMEDetection {
+	case "", "sniff", "extension", "auto":
+		return nil
+	}
+	return fmt.Errorf("option MIMEDetection is invalid, '%s', valid options are: sniff, extension, auto", c.MIMEDetection)
+}
+
+func (c Config) validateUnicodeNormalization() error {
+	switch c.UnicodeNormalization {
+	case "", "nfc", "nfd", "off":
+		return nil
+	}
+	return fmt.Errorf("option UnicodeNormalization is invalid, '%s', valid options are: nfc, nfd, off", c.UnicodeNormalization)
+}
+
+func (c Config) validateOnUnsupported() error {
+	switch c.OnUnsupported {
+	case "", "skip", "warn", "fail":
+		return nil
+	}
+	return fmt.Errorf("option OnUnsupported is invalid, '%s', valid options are: skip, warn, fail", c.OnUnsupported)
+}
+
+func (c Config) validateDedupStrategy() error {
+	switch c.DedupStrategy {
+	case "", "path", "hash":
+		return nil
+	}
+	return fmt.Errorf("option DedupStrategy is invalid, '%s'", c.DedupStrategy)
+}
+
+func (c Config) validateNearDuplicateThreshold() error {
+	if c.NearDuplicateThreshold < 0 || c.NearDuplicateThreshold > 64 {
+		return fmt.Errorf("option NearDuplicateThreshold is invalid, '%d', it should be between 0 and 64", c.NearDuplicateThreshold)
+	}
+	return nil
+}
+
+func (c Config) validateTrackerBackend() error {
+	switch c.TrackerBackend {
+	case "", "leveldb", "sqlite":
+		return nil
+	}
+	return fmt.Errorf("option TrackerBackend is invalid, '%s'", c.TrackerBackend)
+}
+
+func (c Config) validateNotifyWebhook() error {
+	if c.NotifyWebhook == "" {
+		return nil
+	}
+	u, err := url.Parse(c.NotifyWebhook)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("option NotifyWebhook is invalid, '%s'", c.NotifyWebhook)
+	}
+	return nil
+}
+
+func (c Config) validatePhotosAPIBaseURL() error {
+	if c.PhotosAPIBaseURL == "" {
+		return nil
+	}
+	u, err := url.Parse(c.PhotosAPIBaseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.RawQuery != "" {
+		return fmt.Errorf("option PhotosAPIBaseURL is invalid, '%s'", c.PhotosAPIBaseURL)
+	}
+	return nil
+}
+
+func (c Config) validateUserAgent() error {
+	if err := transport.ValidateUserAgent(c.UserAgent); err != nil {
+		return fmt.Errorf("option UserAgent is invalid, '%s'", c.UserAgent)
+	}
+	return nil
+}
+
+func (c Config) validateRequestHeaders() error {
+	names := make([]string, 0, len(c.RequestHeaders))
+	for name := range c.RequestHeaders {
+		names = append(names, name)
+	}
+	// the first invalid header is always the same one.
+	sort.Strings(names)
+	for _, name := range names {
+		if err := transport.ValidateHeader(name, c.RequestHeaders[name]); err != nil {
+			return fmt.Errorf("option RequestHeaders is invalid: %s", err)
 		}
-		if !isValidCreateAlbums(job.CreateAlbums) {
-			return fmt.Errorf("option CreateAlbums is invalid, '%s", job.CreateAlbums)
+	}
+	return nil
+}
+
+func (c Config) validateProxyURL() error {
+	if c.ProxyURL == "" {
+		return nil
+	}
+	if err := transport.ValidateProxyURL(c.ProxyURL); err != nil {
+		return fmt.Errorf("option ProxyURL is invalid, '%s'", c.ProxyURL)
+	}
+	return nil
+}
+
+func (c Config) validateNotifyOn() error {
+	switch c.NotifyOn {
+	case "", "always", "failure", "success":
+		return nil
+	}
+	return fmt.Errorf("option NotifyOn is invalid, '%s'", c.NotifyOn)
+}
+
+func (c Config) validateNotifyTimeout() error {
+	if c.NotifyTimeout == "" {
+		return nil
+	}
+	if d, err := time.ParseDuration(c.NotifyTimeout); err != nil || d <= 0 {
+		return fmt.Errorf("option NotifyTimeout is invalid, '%s'", c.NotifyTimeout)
+	}
+	return nil
+}
+
+func (c Config) validateOnUploadTimeout() error {
+	if c.OnUploadTimeout == "" {
+		return nil
+	}
+	if d, err := time.ParseDuration(c.OnUploadTimeout); err != nil || d <= 0 {
+		return fmt.Errorf("option OnUploadTimeout is invalid, '%s'", c.OnUploadTimeout)
+	}
+	return nil
+}
+
+func validateSourceFolder(fs afero.Fs, job FolderUploadJob) error {
+	exist, err := afero.DirExists(fs, job.SourceFolder)
+	if err != nil {
+		return fmt.Errorf("option SourceFolder '%s' is invalid, err=%s", job.SourceFolder, err)
+	}
+	if !exist {
+		return fmt.Errorf("folder '%s' does not exist", job.SourceFolder)
+	}
+	return nil
+}
+
+func validateJobWorkers(job FolderUploadJob) error {
+	if job.Workers < 0 {
+		return fmt.Errorf("option Workers is invalid, '%d'", job.Workers)
+	}
+	return nil
+}
+
+func validateMinDimensions(job FolderUploadJob) error {
+	if job.MinWidth < 0 {
+		return fmt.Errorf("option MinWidth is invalid, '%d'", job.MinWidth)
+	}
+	if job.MinHeight < 0 {
+		return fmt.Errorf("option MinHeight is invalid, '%d'", job.MinHeight)
+	}
+	return nil
+}
+
+func validateSharedAlbum(job FolderUploadJob) error {
+	if job.SharedAlbum == nil {
+		return nil
+	}
+	if (job.SharedAlbum.ShareToken == "") == (job.SharedAlbum.Title == "") {
+		return fmt.Errorf("option SharedAlbum is invalid, one of ShareToken or Title must be set")
+	}
+	return nil
+}
+
+func validateJobRateLimit(job FolderUploadJob) error {
+	if _, err := ratelimit.Parse(job.RateLimit); err != nil {
+		return fmt.Errorf("option RateLimit is invalid, '%s'", job.RateLimit)
+	}
+	return nil
+}
+
+// validateFavoritesFolder checks that FavoritesFolder is the name of a folder, not a path.
+func validateFavoritesFolder(job FolderUploadJob) error {
+	if job.FavoritesFolder == "." || job.FavoritesFolder == ".." || strings.ContainsAny(job.FavoritesFolder, `/\`) {
+		return fmt.Errorf("option FavoritesFolder is invalid, '%s'", job.FavoritesFolder)
+	}
+	return nil
+}
+
+func validateDateFromFilename(job FolderUploadJob) error {
+	if job.DateFromFilename == nil {
+		return nil
+	}
+	if _, err := upload.NewFilenameDate(job.DateFromFilename.Pattern, job.DateFromFilename.Layout); err != nil {
+		return fmt.Errorf("option DateFromFilename is invalid: %s", err)
+	}
+	return nil
+}
+
+// validateCaptureDateDescription checks that CaptureDateDescription has some element of the date.
+func validateCaptureDateDescription(job FolderUploadJob) error {
+	if job.CaptureDateDescription == "" {
+		return nil
+	}
+	if time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC).Format(job.CaptureDateDescription) == job.CaptureDateDescription {
+		return fmt.Errorf("option CaptureDateDescription is invalid, '%s'", job.CaptureDateDescription)
+	}
+	return nil
+}
+
+func validateMimeTypes(job FolderUploadJob) error {
+	if _, err := filter.NewMIMEFilter(job.AllowedMimeTypes, job.ExcludedMimeTypes); err != nil {
+		return fmt.Errorf("options AllowedMimeTypes and ExcludedMimeTypes are invalid: %s", err)
+	}
+	return nil
+}
+
+// validateDepth checks that MinDepth and MaxDepth are not negative, and MinDepth is not greater than MaxDepth.
+func validateDepth(job FolderUploadJob) error {
+	if job.MinDepth < 0 {
+		return fmt.Errorf("option MinDepth is invalid, '%d'", job.MinDepth)
+	}
+	if job.MaxDepth == nil {
+		return nil
+	}
+	if *job.MaxDepth < 0 {
+		return fmt.Errorf("option MaxDepth is invalid, '%d'", *job.MaxDepth)
+	}
+	if job.MinDepth > *job.MaxDepth {
+		return fmt.Errorf("option MinDepth, '%d', is greater than MaxDepth, '%d'", job.MinDepth, *job.MaxDepth)
+	}
+	return nil
+}
+
+func validateAlbumHierarchyDepth(job FolderUploadJob) error {
+	if job.AlbumHierarchyDepth < 0 {
+		return fmt.Errorf("option AlbumHierarchyDepth is invalid, '%d'", job.AlbumHierarchyDepth)
+	}
+	return nil
+}
+
+func validateAlbumNameTemplate(job FolderUploadJob) error {
+	if job.AlbumNameTemplate == "" {
+		return nil
+	}
+	if _, err := upload.NewAlbumNameTemplate(job.AlbumNameTemplate); err != nil {
+		return fmt.Errorf("option AlbumNameTemplate is invalid: %s", err)
+	}
+	return nil
+}
+
+func validateCreateAlbums(job FolderUploadJob) error {
+	if !IsValidCreateAlbums(job.CreateAlbums) {
+		return fmt.Errorf("option CreateAlbums is invalid, '%s'", job.CreateAlbums)
+	}
+	return nil
+}
+
+// validatePattern checks that the include or exclude pattern could be compiled.
+func validatePattern(pattern string) error {
+	if err := filter.ValidatePattern(pattern); err != nil {
+		return fmt.Errorf("pattern is invalid, '%s': %s", pattern, err)
+	}
+	return nil
+}
+
+// validateAlbumPattern checks that the pattern of the album could be compiled.
+func validateAlbumPattern(album AlbumMapping, pattern string) error {
+	if err := filter.ValidatePattern(pattern); err != nil {
+		return fmt.Errorf("pattern of album '%s' is invalid, '%s': %s", album.Name, pattern, err)
+	}
+	return nil
+}
+
+func validateRoute(route Route) error {
+	for _, pattern := range route.Match {
+		if err := filter.ValidatePattern(pattern); err != nil {
+			return fmt.Errorf("pattern of route is invalid, '%s': %s", pattern, err)
+		}
+	}
+	if _, err := filter.NewMIMEFilter(route.MimeTypes, nil); err != nil {
+		return fmt.Errorf("option MimeTypes of route is invalid: %s", err)
+	}
+	return nil
+}
+
+// validateAlbumCoverPhoto checks the CoverPhotoPath and CoverPhotoStrategy options of the album.
+func validateAlbumCoverPhoto(album AlbumMapping) error {
+	switch album.CoverPhotoStrategy {
+	case "":
+		return nil
+	case "first", "latest":
+	default:
+		return fmt.Errorf("option CoverPhotoStrategy of album '%s' is invalid, '%s'", album.Name, album.CoverPhotoStrategy)
+	}
+	if album.CoverPhotoPath != "" {
+		return fmt.Errorf("options CoverPhotoPath and CoverPhotoStrategy of album '%s' could not be used together", album.Name)
+	}
+	return nil
+}
+
+// validateAfterUpload checks the AfterUpload and MoveToDir options of the job.
+func validateAfterUpload(job FolderUploadJob) error {
+	switch job.AfterUpload {
+	case "", "keep", "delete":
+		return nil
+	case "trash":
+		if job.DeleteAfterUpload {
+			return errors.New("options DeleteAfterUpload and AfterUpload 'trash' could not be used at the same time")
 		}
+		if err := trash.Supported(); err != nil {
+			return fmt.Errorf("option AfterUpload 'trash' is invalid, %s", err)
+		}
+		return nil
+	case "move":
+	default:
+		return fmt.Errorf("option AfterUpload is invalid, '%s'", job.AfterUpload)
+	}
+
+	if job.DeleteAfterUpload {
+		return errors.New("options DeleteAfterUpload and AfterUpload 'move' could not be used at the same time")
+	}
+	if job.MoveToDir == "" {
+		return errors.New("option MoveToDir could not be empty when AfterUpload is 'move'")
+	}
+	if rel, err := filepath.Rel(job.SourceFolder, job.MoveToDir); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("option MoveToDir is invalid, '%s' is inside SourceFolder", job.MoveToDir)
 	}
 	return nil
 }
@@ -157,58 +834,266 @@ func (c Config) validateSecretsBackendType() error {
 	return fmt.Errorf("option SecretsBackendType is invalid, '%s'", c.SecretsBackendType)
 }
 
-func (c Config) ensureSourceFolderAbsolutePaths() error {
+func (c Config) validateTokenStore() error {
+	switch c.TokenStore {
+	case "", "keyring", "file", "env":
+		return nil
+	}
+	return fmt.Errorf("option TokenStore is invalid, '%s'", c.TokenStore)
+}
+
+func (c Config) ensureJobsAbsolutePaths() error {
 	for i := range c.Jobs {
 		item := &c.Jobs[i] // we do that way to modify original object while iterating.
+		for j, folder := range item.SourceFolders {
+			src, err := homedir.Expand(folder)
+			if err != nil {
+				return err
+			}
+			item.SourceFolders[j] = normalizePath(src)
+		}
+		if len(item.SourceFolders) > 0 && item.SourceFolder == "" {
+			continue
+		}
 		src, err := homedir.Expand(item.SourceFolder)
 		if err != nil {
 			return err
 		}
 		item.SourceFolder = normalizePath(src)
+
+		if item.MoveToDir != "" {
+			dst, err := homedir.Expand(item.MoveToDir)
+			if err != nil {
+				return err
+			}
+			item.MoveToDir = normalizePath(dst)
+		}
 	}
 	return nil
 }
 
-// isValidCreateAlbums checks if the value is a valid CreateAlbums option.
-func isValidCreateAlbums(value string) bool {
-	switch value {
-	case "Off", "folderPath", "folderName":
-		return true
-	default:
+// ensurePatternsFilesAbsolutePaths converts the patterns files of the jobs to absolute paths,
+// relative paths are relative to dir, the folder of the configuration file.
+func (c Config) ensurePatternsFilesAbsolutePaths(dir string) error {
+	for i := range c.Jobs {
+		item := &c.Jobs[i]
+		for _, path := range []*string{&item.IncludePatternsFile, &item.ExcludePatternsFile} {
+			if *path == "" {
+				continue
+			}
+			p, err := homedir.Expand(*path)
+			if err != nil {
+				return err
+			}
+			if !filepath.IsAbs(p) {
+				p = filepath.Join(dir, p)
+			}
+			*path = normalizePath(p)
+		}
 	}
-	return false
+	return nil
+}
+
+// readJobsPatternsFiles adds the patterns in the IncludePatternsFile and ExcludePatternsFile of the jobs
+// to their IncludePatterns and ExcludePatterns.
+func (c Config) readJobsPatternsFiles(fs afero.Fs) error {
+	for i := range c.Jobs {
+		item := &c.Jobs[i]
+		if item.IncludePatternsFile != "" {
+			patterns, err := readPatternsFile(fs, item.IncludePatternsFile)
+			if err != nil {
+				return fmt.Errorf("option IncludePatternsFile is invalid, '%s': %s", item.IncludePatternsFile, err)
+			}
+			item.IncludePatterns = append(item.IncludePatterns, patterns...)
+		}
+		if item.ExcludePatternsFile != "" {
+			patterns, err := readPatternsFile(fs, item.ExcludePatternsFile)
+			if err != nil {
+				return fmt.Errorf("option ExcludePatternsFile is invalid, '%s': %s", item.ExcludePatternsFile, err)
+			}
+			item.ExcludePatterns = append(item.ExcludePatterns, patterns...)
+		}
+	}
+	return nil
+}
+
+// readPatternsFile returns the patterns in the file, one per line. Blank lines and lines
+// starting with `#` are ignored.
+func readPatternsFile(fs afero.Fs, filename string) ([]string, error) {
+	b, err := afero.ReadFile(fs, filename)
+	if err != nil {
+		return nil, err
+	}
+	var patterns []string
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns, nil
+}
+
+// expandJobsLibraries replaces every job with SourceFolders by one job per folder, with the same Library. It
+// returns an error if SourceFolder is set too, or if a folder is inside another one, since its files would be
+// scanned twice.
+func (c *Config) expandJobsLibraries() error {
+	var jobs []FolderUploadJob
+	for _, job := range c.Jobs {
+		if len(job.SourceFolders) == 0 {
+			jobs = append(jobs, job)
+			continue
+		}
+		if job.SourceFolder != "" {
+			return fmt.Errorf("option SourceFolders is invalid, SourceFolder '%s' is set too", job.SourceFolder)
+		}
+		for i, folder := range job.SourceFolders {
+			for _, other := range job.SourceFolders[i+1:] {
+				if isInside(folder, other) || isInside(other, folder) {
+					return fmt.Errorf("option SourceFolders is invalid, '%s' and '%s' overlap", folder, other)
+				}
+			}
+		}
+		library := strings.Join(job.SourceFolders, string(filepath.ListSeparator))
+		for _, folder := range job.SourceFolders {
+			expanded := job
+			expanded.SourceFolder = folder
+			expanded.SourceFolders = nil
+			expanded.library = library
+			jobs = append(jobs, expanded)
+		}
+	}
+	c.Jobs = jobs
+	return nil
+}
+
+// isInside returns true if path is folder, or it's inside it.
+func isInside(path string, folder string) bool {
+	rel, err := filepath.Rel(folder, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// expandJobsSourceFolders replaces every job whose SourceFolder is a glob pattern, e.g. "/photos/*/incoming",
+// by one job per matched folder. Files matching the pattern are ignored. It returns an error if the pattern
+// doesn't match any folder, unless AllowEmptyGlob is set.
+func (c *Config) expandJobsSourceFolders(fs afero.Fs) error {
+	var jobs []FolderUploadJob
+	for _, job := range c.Jobs {
+		// existing folders are kept as they are, even if its name has glob meta characters, e.g. "[2020] Trip".
+		if exist, _ := afero.DirExists(fs, job.SourceFolder); exist || !isGlob(job.SourceFolder) {
+			jobs = append(jobs, job)
+			continue
+		}
+		folders, err := globFolders(fs, job.SourceFolder)
+		if err != nil {
+			return fmt.Errorf("option SourceFolder is invalid, '%s': %s", job.SourceFolder, err)
+		}
+		if len(folders) == 0 && !job.AllowEmptyGlob {
+			return fmt.Errorf("option SourceFolder is invalid, '%s' doesn't match any folder", job.SourceFolder)
+		}
+		for _, folder := range folders {
+			expanded := job
+			expanded.SourceFolder = folder
+			jobs = append(jobs, expanded)
+		}
+	}
+	c.Jobs = jobs
+	return nil
 }
 
-// unmarshalReader unmarshal HJSON data into the provided interface.
-func unmarshalReader(in io.Reader, c interface{}) error {
-	buf := new(bytes.Buffer)
-	if _, err := buf.ReadFrom(in); err != nil {
+// isGlob returns true if the path has any glob meta character.
+func isGlob(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// globFolders returns the sorted folders matching the glob pattern.
+func globFolders(fs afero.Fs, pattern string) ([]string, error) {
+	matches, err := afero.Glob(fs, pattern)
+	if err != nil {
+		return nil, err
+	}
+	var folders []string
+	for _, m := range matches {
+		if isDir, err := afero.IsDir(fs, m); err == nil && isDir {
+			folders = append(folders, m)
+		}
+	}
+	sort.Strings(folders)
+	return folders, nil
+}
+
+func (c *Config) ensureServiceAccountKeyAbsolutePath() error {
+	if !c.APIAppCredentials.UsesServiceAccount() {
+		return nil
+	}
+	key, err := homedir.Expand(c.APIAppCredentials.ServiceAccountKey)
+	if err != nil {
 		return err
 	}
+	c.APIAppCredentials.ServiceAccountKey = normalizePath(key)
+	return nil
+}
 
-	b, err := hjsonToJson(buf.Bytes())
+func (c *Config) ensureTrackerDBAbsolutePath() error {
+	if c.TrackerDBPath == "" {
+		return nil
+	}
+	path, err := homedir.Expand(c.TrackerDBPath)
 	if err != nil {
 		return err
 	}
+	c.TrackerDBPath = normalizePath(path)
+	return nil
+}
 
-	// unmarshal
-	return json.Unmarshal(b, c)
+func (c *Config) ensureTempDirAbsolutePath() error {
+	if c.TempDir == "" {
+		return nil
+	}
+	path, err := homedir.Expand(c.TempDir)
+	if err != nil {
+		return err
+	}
+	c.TempDir = normalizePath(path)
+	return nil
 }
 
-// hjsonToJson converts data from HJSON to JSON format.
-func hjsonToJson(in []byte) ([]byte, error) {
-	var raw map[string]interface{}
-	if err := hjson.Unmarshal(in, &raw); err != nil {
-		return nil, err
+func (c *Config) ensureControlDirAbsolutePath() error {
+	if c.ControlDir == "" {
+		return nil
+	}
+	path, err := homedir.Expand(c.ControlDir)
+	if err != nil {
+		return err
 	}
+	c.ControlDir = normalizePath(path)
+	return nil
+}
+
+// IsValidCreateAlbums checks if the value is a valid CreateAlbums option.
+func IsValidCreateAlbums(value string) bool {
+	switch value {
+	case "Off", "folderPath", "folderName", "exifDate", "mediaType":
+		return true
+	default:
+	}
+	return false
+}
 
-	// convert to JSON
-	return json.Marshal(raw)
+// unmarshalRaw unmarshal the raw HJSON data into the provided interface, converting it to JSON.
+func unmarshalRaw(raw map[string]interface{}, c interface{}) error {
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, c)
 }
 
 // defaultSettings() returns a *Config with the default settings of the application.
 func defaultSettings() Config {
 	return Config{
+		ConfigVersion:      CurrentVersion,
 		SecretsBackendType: "file",
 		APIAppCredentials: APIAppCredentials{
 			ClientID:     "YOUR_APP_CLIENT_ID",
@@ -217,14 +1102,35 @@ func defaultSettings() Config {
 		Account: "YOUR_GOOGLE_PHOTOS_ACCOUNT",
 		Jobs: []FolderUploadJob{
 			{
-				SourceFolder: "YOUR_FOLDER_PATH",
-				CreateAlbums: "folderName",
+				SourceFolder:      "YOUR_FOLDER_PATH",
+				CreateAlbums:      "folderName",
 				DeleteAfterUpload: false,
 			},
 		},
 	}
 }
 
+// redactURL returns the URL without its password, if it has any.
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return u.Redacted()
+}
+
+// redactHeaders returns the names of the headers, removing their values, since they could be secrets.
+func redactHeaders(headers map[string]string) map[string]string {
+	if len(headers) == 0 {
+		return nil
+	}
+	redacted := make(map[string]string, len(headers))
+	for name := range headers {
+		redacted[name] = "REMOVED"
+	}
+	return redacted
+}
+
 func normalizePath(path string) string {
 	if absPath, err := filepath.Abs(path); err == nil {
 		return absPath
@@ -0,0 +1,129 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/afero"
+)
+
+// Diagnostic is a problem found in the configuration.
+type Diagnostic struct {
+	// Field is the location of the problem, e.g. "Jobs[1].IncludePatterns[2]". Jobs and patterns are counted from 0.
+	Field string
+	Err   error
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s", d.Field, d.Err)
+}
+
+// Validate returns all the problems found in the configuration file. It returns an error if
+// the file could not be read, or it's not a valid HJSON document.
+func Validate(fs afero.Fs, filename string) ([]Diagnostic, error) {
+	cfg, err := readFile(fs, filename)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.Diagnose(fs), nil
+}
+
+// Diagnose returns all the problems found in the configuration, while FromFile only
+// returns the first one.
+func (c Config) Diagnose(fs afero.Fs) []Diagnostic {
+	var problems []Diagnostic
+	check := func(field string, err error) {
+		if err != nil {
+			problems = append(problems, Diagnostic{Field: field, Err: err})
+		}
+	}
+
+	check("SecretsBackendType", c.validateSecretsBackendType())
+	check("TokenStore", c.validateTokenStore())
+	check("APIAppCredentials", c.validateAPIAppCredentials())
+	check("Account", c.validateAccount())
+	check("Accounts", c.validateAccounts())
+	check("UploadWorkerCount", c.validateUploadWorkerCount())
+	check("ScanWorkerCount", c.validateScanWorkerCount())
+	check("UploadRateLimit", c.validateUploadRateLimit())
+	check("UploadChunkSize", c.validateUploadChunkSize())
+	check("MaxRetries", c.validateMaxRetries())
+	check("RetryBaseDelay", c.validateRetryBaseDelay())
+	check("PerFileTimeout", c.validatePerFileTimeout())
+	check("FSRetries", c.validateFSRetries())
+	check("FSRetryDelay", c.validateFSRetryDelay())
+	check("RetryableMessages", c.validateRetryableMessages())
+	check("MaxUploadAttempts", c.validateMaxUploadAttempts())
+	check("DailyRequestBudget", c.validateDailyRequestBudget())
+	check("UploadOrder", c.validateUploadOrder())
+	check("MinFileAge", c.validateMinFileAge())
+	check("MaxPhotoSize", c.validateMaxPhotoSize())
+	check("MaxVideoSize", c.validateMaxVideoSize())
+	check("MinFreeSpace", c.validateMinFreeSpace())
+	check("MIMEDetection", c.validateMIMEDetection())
+	check("UnicodeNormalization", c.validateUnicodeNormalization())
+	check("OnUnsupported", c.validateOnUnsupported())
+	check("DedupStrategy", c.validateDedupStrategy())
+	check("NearDuplicateThreshold", c.validateNearDuplicateThreshold())
+	check("TrackerBackend", c.validateTrackerBackend())
+	check("NotifyWebhook", c.validateNotifyWebhook())
+	check("NotifyOn", c.validateNotifyOn())
+	check("NotifyTimeout", c.validateNotifyTimeout())
+	check("OnUploadTimeout", c.validateOnUploadTimeout())
+	check("PhotosAPIBaseURL", c.validatePhotosAPIBaseURL())
+	check("ProxyURL", c.validateProxyURL())
+	check("UserAgent", c.validateUserAgent())
+	check("RequestHeaders", c.validateRequestHeaders())
+	check("MaxConnections", c.validateMaxConnections())
+
+	if len(c.Jobs) < 1 {
+		check("Jobs", errors.New("at least one Job must be configured"))
+	}
+	for i, job := range c.Jobs {
+		field := fmt.Sprintf("Jobs[%d]", i)
+		check(field+".SourceFolder", validateSourceFolder(fs, job))
+		check(field+".CreateAlbums", validateCreateAlbums(job))
+		check(field+".AfterUpload", validateAfterUpload(job))
+		check(field+".Workers", validateJobWorkers(job))
+		check(field+".RateLimit", validateJobRateLimit(job))
+		check(field+".FavoritesFolder", validateFavoritesFolder(job))
+		check(field+".DateFromFilename", validateDateFromFilename(job))
+		check(field+".CaptureDateDescription", validateCaptureDateDescription(job))
+		check(field+".AllowedMimeTypes", validateMimeTypes(job))
+		check(field+".MinWidth", validateMinDimensions(job))
+		check(field+".AlbumNameTemplate", validateAlbumNameTemplate(job))
+		check(field+".AlbumHierarchyDepth", validateAlbumHierarchyDepth(job))
+		check(field+".MaxDepth", validateDepth(job))
+		check(field+".SharedAlbum", validateSharedAlbum(job))
+		if _, err := c.JobAccount(job); err != nil {
+			check(field+".Account", err)
+		}
+		for j, pattern := range job.IncludePatterns {
+			check(fmt.Sprintf("%s.IncludePatterns[%d]", field, j), validatePattern(pattern))
+		}
+		for j, pattern := range job.ExcludePatterns {
+			check(fmt.Sprintf("%s.ExcludePatterns[%d]", field, j), validatePattern(pattern))
+		}
+		for j, album := range job.Albums {
+			albumField := fmt.Sprintf("%s.Albums[%d]", field, j)
+			if album.Name == "" {
+				check(albumField+".Name", errors.New("option Name of album could not be empty"))
+			}
+			for k, pattern := range album.IncludePatterns {
+				check(fmt.Sprintf("%s.IncludePatterns[%d]", albumField, k), validateAlbumPattern(album, pattern))
+			}
+			for k, pattern := range album.ExcludePatterns {
+				check(fmt.Sprintf("%s.ExcludePatterns[%d]", albumField, k), validateAlbumPattern(album, pattern))
+			}
+			check(albumField+".CoverPhotoStrategy", validateAlbumCoverPhoto(album))
+		}
+		for j, route := range job.Routes {
+			routeField := fmt.Sprintf("%s.Routes[%d]", field, j)
+			check(routeField, validateRoute(route))
+			if _, err := c.RouteAccount(job, route); err != nil {
+				check(routeField+".Account", err)
+			}
+		}
+	}
+	return problems
+}
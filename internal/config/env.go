@@ -0,0 +1,135 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// regexpPatternPrefix is the prefix of regular expression patterns, where `$` is an anchor so they are not expanded.
+const regexpPatternPrefix = "re:"
+
+// expandEnv replaces ${VAR}, $VAR and ${VAR:-default} in the string values of the raw configuration
+// by the value of the environment variable, using lookup. The default is used if the variable is not
+// set or it's empty. `$$` is a literal `$`. Regular expression patterns, prefixed by `re:`, are not
+// expanded. It returns an error, with the option location, if a variable without default is not set.
+func expandEnv(raw interface{}, location string, lookup func(string) (string, bool)) (interface{}, error) {
+	switch v := raw.(type) {
+	case string:
+		if strings.HasPrefix(v, regexpPatternPrefix) {
+			return v, nil
+		}
+		s, err := expandString(v, lookup)
+		if err != nil {
+			return nil, fmt.Errorf("option %s is invalid, %s", location, err)
+		}
+		return s, nil
+	case map[string]interface{}:
+		// keys are sorted, so the reported error doesn't depend on the map order.
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			field := k
+			if location != "" {
+				field = location + "." + k
+			}
+			expanded, err := expandEnv(v[k], field, lookup)
+			if err != nil {
+				return nil, err
+			}
+			v[k] = expanded
+		}
+		return v, nil
+	case []interface{}:
+		for i := range v {
+			expanded, err := expandEnv(v[i], fmt.Sprintf("%s[%d]", location, i), lookup)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = expanded
+		}
+		return v, nil
+	}
+	return raw, nil
+}
+
+// expandString replaces the environment variables in s, see expandEnv.
+func expandString(s string, lookup func(string) (string, bool)) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		switch next := s[i+1]; {
+		case next == '$':
+			b.WriteByte('$')
+			i++
+		case next == '{':
+			end := strings.IndexByte(s[i+2:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("'%s' is not closed", s[i:])
+			}
+			name, def, hasDefault := splitDefault(s[i+2 : i+2+end])
+			if !isValidName(name) {
+				return "", fmt.Errorf("'%s' is not a valid environment variable", name)
+			}
+			value, ok := lookup(name)
+			switch {
+			case hasDefault && value == "":
+				value = def
+			case !ok:
+				return "", fmt.Errorf("environment variable '%s' is not set", name)
+			}
+			b.WriteString(value)
+			i += end + 2
+		case isNameStart(next):
+			end := i + 2
+			for end < len(s) && isNameChar(s[end]) {
+				end++
+			}
+			name := s[i+1 : end]
+			value, ok := lookup(name)
+			if !ok {
+				return "", fmt.Errorf("environment variable '%s' is not set", name)
+			}
+			b.WriteString(value)
+			i = end - 1
+		default:
+			b.WriteByte('$')
+		}
+	}
+	return b.String(), nil
+}
+
+// splitDefault splits `VAR:-default` into the name and the default value.
+func splitDefault(expr string) (string, string, bool) {
+	if i := strings.Index(expr, ":-"); i >= 0 {
+		return expr[:i], expr[i+2:], true
+	}
+	return expr, "", false
+}
+
+func isValidName(name string) bool {
+	if name == "" || !isNameStart(name[0]) {
+		return false
+	}
+	for i := 1; i < len(name); i++ {
+		if !isNameChar(name[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || ('0' <= c && c <= '9')
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/hjson/hjson-go"
+	"github.com/spf13/afero"
+)
+
+// ListMerge is the way lists are merged when the configuration is read from several files.
+type ListMerge string
+
+const (
+	// ListsReplace replaces the lists of the previous files, e.g. Jobs, by the ones of the later file (default).
+	ListsReplace ListMerge = "replace"
+	// ListsAppend appends the lists of the later file to the ones of the previous files.
+	ListsAppend ListMerge = "append"
+)
+
+// FromFiles returns the configuration data merging the specified files, in order, and validating the result.
+// Later files take precedence over earlier ones:
+//   - objects, e.g. APIAppCredentials, are merged recursively, so a file only needs to set the options it overrides.
+//   - other values, like strings or numbers, are replaced.
+//   - lists, e.g. Jobs, are replaced or appended, depending on lists.
+//
+// Every file is upgraded to CurrentVersion before merging it. Relative paths of patterns files are relative
+// to the folder of the first file. FromFiles returns a ParseError{} if the configuration validation fails.
+func FromFiles(fs afero.Fs, filenames []string, lists ListMerge) (*Config, error) {
+	cfg, err := readFiles(fs, filenames, lists)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.validate(fs); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// ValidateFiles returns all the problems found in the configuration merging the files, like FromFiles does.
+// It returns an error if any of the files could not be read, or it's not a valid HJSON document.
+func ValidateFiles(fs afero.Fs, filenames []string, lists ListMerge) ([]Diagnostic, error) {
+	cfg, err := readFiles(fs, filenames, lists)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.Diagnose(fs), nil
+}
+
+// readFiles loads the configuration data merging the files named by filenames, in order.
+func readFiles(fs afero.Fs, filenames []string, lists ListMerge) (*Config, error) {
+	if len(filenames) == 0 {
+		return nil, fmt.Errorf("no configuration files")
+	}
+	switch lists {
+	case "", ListsReplace, ListsAppend:
+	default:
+		return nil, fmt.Errorf("lists merge '%s' is invalid, valid options are: replace, append", lists)
+	}
+
+	var merged map[string]interface{}
+	var migrations []string
+	for _, filename := range filenames {
+		raw, changes, err := readRaw(fs, filename)
+		if err != nil {
+			if len(filenames) > 1 {
+				return nil, fmt.Errorf("file '%s': %w", filename, err)
+			}
+			return nil, err
+		}
+		for _, change := range changes {
+			if len(filenames) > 1 {
+				change = fmt.Sprintf("%s: %s", filename, change)
+			}
+			migrations = append(migrations, change)
+		}
+		merged = mergeRaw(merged, raw, lists)
+	}
+	return fromRaw(fs, merged, migrations, filenames[0])
+}
+
+// readRaw returns the raw configuration data of the file, upgraded to CurrentVersion, and the changes made.
+func readRaw(fs afero.Fs, filename string) (map[string]interface{}, []string, error) {
+	b, err := afero.ReadFile(fs, filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	return parseRaw(b)
+}
+
+// parseRaw returns the raw configuration data of the HJSON document, upgraded to CurrentVersion, and the changes made.
+func parseRaw(b []byte) (map[string]interface{}, []string, error) {
+	var raw map[string]interface{}
+	if err := hjson.Unmarshal(b, &raw); err != nil {
+		return nil, nil, err
+	}
+
+	// older configurations are upgraded in memory, use Migrate to write them.
+	_, migrations, err := migrate(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	return raw, migrations, nil
+}
+
+// mergeRaw returns dst after merging src into it. Objects are merged recursively, lists are replaced or
+// appended, depending on lists, and other values are replaced.
+func mergeRaw(dst, src map[string]interface{}, lists ListMerge) map[string]interface{} {
+	if dst == nil {
+		dst = make(map[string]interface{}, len(src))
+	}
+	for key, value := range src {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if m, ok := dst[key].(map[string]interface{}); ok {
+				dst[key] = mergeRaw(m, v, lists)
+				continue
+			}
+		case []interface{}:
+			if l, ok := dst[key].([]interface{}); ok && lists == ListsAppend {
+				dst[key] = append(l, v...)
+				continue
+			}
+		}
+		dst[key] = value
+	}
+	return dst
+}
@@ -0,0 +1,167 @@
+package config
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/hjson/hjson-go"
+	"github.com/spf13/afero"
+)
+
+// CurrentVersion is the version of the configuration schema. Configurations without ConfigVersion are version 1.
+const CurrentVersion = 2
+
+// migration upgrades a raw configuration from version to version+1, returning the changes made.
+type migration struct {
+	version int
+	apply   func(raw map[string]interface{}) []string
+}
+
+// migrations are applied in order, from the version of the configuration to CurrentVersion.
+var migrations = []migration{
+	{version: 1, apply: migrateToVersion2},
+}
+
+// migrateToVersion2 replaces the MakeAlbums job setting by CreateAlbums, and DeleteAfterUpload by AfterUpload.
+func migrateToVersion2(raw map[string]interface{}) []string {
+	var changes []string
+	jobs, _ := raw["Jobs"].([]interface{})
+	for i, j := range jobs {
+		job, ok := j.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if makeAlbums, exist := job["MakeAlbums"]; exist {
+			delete(job, "MakeAlbums")
+			if _, set := job["CreateAlbums"]; set {
+				changes = append(changes, fmt.Sprintf("Jobs[%d].MakeAlbums has been removed, CreateAlbums is already set", i))
+			} else {
+				createAlbums := createAlbumsFromMakeAlbums(makeAlbums)
+				job["CreateAlbums"] = createAlbums
+				changes = append(changes, fmt.Sprintf("Jobs[%d].MakeAlbums has been replaced by CreateAlbums '%s'", i, createAlbums))
+			}
+		}
+
+		if del, _ := job["DeleteAfterUpload"].(bool); del {
+			if _, set := job["AfterUpload"]; !set {
+				delete(job, "DeleteAfterUpload")
+				job["AfterUpload"] = "delete"
+				changes = append(changes, fmt.Sprintf("Jobs[%d].DeleteAfterUpload has been replaced by AfterUpload 'delete'", i))
+			}
+		}
+	}
+	return changes
+}
+
+// createAlbumsFromMakeAlbums returns the CreateAlbums value of the deprecated MakeAlbums setting,
+// e.g. `MakeAlbums: { Enabled: true, Use: folderPath }`.
+func createAlbumsFromMakeAlbums(value interface{}) string {
+	m, _ := value.(map[string]interface{})
+	if enabled, _ := m["Enabled"].(bool); !enabled {
+		return "Off"
+	}
+	if use, _ := m["Use"].(string); use != "" {
+		return use
+	}
+	return "folderName"
+}
+
+// migrate upgrades the raw configuration to CurrentVersion, returning the changes made.
+func migrate(raw map[string]interface{}) (int, []string, error) {
+	version, err := rawVersion(raw)
+	if err != nil {
+		return 0, nil, err
+	}
+	if version > CurrentVersion {
+		return 0, nil, fmt.Errorf("option ConfigVersion is invalid, version %d is newer than the supported one, %d", version, CurrentVersion)
+	}
+
+	var changes []string
+	for _, m := range migrations {
+		if m.version < version {
+			continue
+		}
+		changes = append(changes, m.apply(raw)...)
+	}
+	if version < CurrentVersion {
+		raw["ConfigVersion"] = CurrentVersion
+		changes = append(changes, fmt.Sprintf("ConfigVersion has been set to %d", CurrentVersion))
+	}
+	return version, changes, nil
+}
+
+// rawVersion returns the ConfigVersion of the raw configuration, 1 if it's not set.
+func rawVersion(raw map[string]interface{}) (int, error) {
+	value, exist := raw["ConfigVersion"]
+	if !exist {
+		return 1, nil
+	}
+	v, ok := value.(float64)
+	if !ok || v < 1 || v != math.Trunc(v) {
+		return 0, fmt.Errorf("option ConfigVersion is invalid, '%v'", value)
+	}
+	return int(v), nil
+}
+
+// Migration is a configuration file upgraded to CurrentVersion.
+type Migration struct {
+	// From is the version of the configuration file.
+	From int
+	// Changes are the changes made, empty if the file is at CurrentVersion.
+	Changes []string
+
+	original []byte
+	raw      map[string]interface{}
+}
+
+// Migrate reads the configuration file and upgrades it to CurrentVersion, without writing it.
+// Environment variables are not expanded, so the references are kept when it's written.
+func Migrate(fs afero.Fs, filename string) (*Migration, error) {
+	b, err := afero.ReadFile(fs, filename)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]interface{}
+	if err := hjson.Unmarshal(b, &raw); err != nil {
+		return nil, err
+	}
+	from, changes, err := migrate(raw)
+	if err != nil {
+		return nil, err
+	}
+	return &Migration{From: from, Changes: changes, original: b, raw: raw}, nil
+}
+
+// Write writes the upgraded configuration to filename, keeping the original one in a backup file.
+// It returns the backup filename. Comments of the original file are not kept.
+func (m *Migration) Write(fs afero.Fs, filename string) (string, error) {
+	b, err := hjson.MarshalWithOptions(m.raw, hjson.DefaultOptions())
+	if err != nil {
+		return "", err
+	}
+
+	backup, err := backupFilename(fs, fmt.Sprintf("%s.v%d.bak", filename, m.From))
+	if err != nil {
+		return "", err
+	}
+	if err := afero.WriteFile(fs, backup, m.original, 0600); err != nil {
+		return "", err
+	}
+	return backup, afero.WriteFile(fs, filename, b, 0600)
+}
+
+// backupFilename returns filename, or filename with a numeric suffix if it already exists.
+func backupFilename(fs afero.Fs, filename string) (string, error) {
+	candidate := filename
+	for i := 1; ; i++ {
+		exist, err := afero.Exists(fs, candidate)
+		if err != nil {
+			return "", err
+		}
+		if !exist {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s.%d", filename, i)
+	}
+}
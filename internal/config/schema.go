@@ -3,53 +3,562 @@ package config
 // Config represents the content of configuration file.
 // It defines the schema for Marshal and Unmarshal the data of the configuration file.
 type Config struct {
+	// ConfigVersion is the version of the configuration schema (see CurrentVersion). Configurations
+	// without it are version 1, older versions are upgraded when they are read.
+	ConfigVersion int `json:"ConfigVersion,omitempty"`
+
+	// Migrations are the changes made upgrading the configuration to CurrentVersion when it was read.
+	Migrations []string `json:"-"`
+
 	// APIAppCredentials represents Google Photos API credentials for OAuth.
 	APIAppCredentials APIAppCredentials `json:"APIAppCredentials"`
 
 	// Account is the Google Photos account to work with.
 	Account string `json:"Account"`
 
+	// Accounts are other Google Photos accounts to work with. Jobs reference them by its name.
+	Accounts []NamedAccount `json:"Accounts,omitempty"`
+
 	// SecretsBackendType is the type of backend to store secrets.
 	SecretsBackendType string `json:"SecretsBackendType"`
 
+	// TokenStore is where the OAuth tokens are kept.
+	// Valid options are:
+	// keyring: Tokens are kept in the keyring backend set by SecretsBackendType (default).
+	// file: Tokens are kept in a file, encrypted using the passphrase in the GPHOTOS_CLI_TOKENSTORE_KEY environment variable.
+	// env: The token is read from the TokenStoreEnvVar environment variable. It's read only, refreshed tokens are not kept.
+	TokenStore string `json:"TokenStore,omitempty"`
+
+	// TokenStoreEnvVar is the environment variable with the JSON token when TokenStore is env (default "GPHOTOS_CLI_TOKEN").
+	TokenStoreEnvVar string `json:"TokenStoreEnvVar,omitempty"`
+
+	// UploadWorkerCount is the number of files to be uploaded concurrently (default 1).
+	// It could be overridden using the `--workers` flag.
+	UploadWorkerCount int `json:"UploadWorkerCount,omitempty"`
+
+	// ScanWorkerCount is the number of directories scanned concurrently (default 1).
+	// Files are uploaded as soon as they are found, in no particular order, when it's greater than 1.
+	// It's useful for large libraries with deep folder trees, or on network shares.
+	ScanWorkerCount int `json:"ScanWorkerCount,omitempty"`
+
+	// UploadRateLimit is the maximum upload rate of all the concurrent uploads, e.g. "2MB/s" or "500KiB/s".
+	// Empty or "0" means unlimited (default). It could be overridden using the `--rate-limit` flag.
+	UploadRateLimit string `json:"UploadRateLimit,omitempty"`
+
+	// UploadChunkSize is the size of the chunks the files are uploaded in, e.g. "16MiB" (default "8MiB").
+	// The progress of every file is kept after each chunk, so an interrupted upload resumes from the last one.
+	UploadChunkSize string `json:"UploadChunkSize,omitempty"`
+
+	// MaxRetries is the maximum number of retries when Google Photos returns a transient error (default 4).
+	// Set it to -1 to disable retries.
+	MaxRetries int `json:"MaxRetries,omitempty"`
+
+	// RetryBaseDelay is the delay before the first retry, e.g. "500ms" or "2s" (default "1s").
+	// It doubles on every attempt.
+	RetryBaseDelay string `json:"RetryBaseDelay,omitempty"`
+
+	// PerFileTimeout is the maximum duration of the upload of a file, e.g. "10m" (default none). The upload is
+	// cancelled once it's reached, e.g. because the connection hangs, and attempted again with a new timeout, up
+	// to MaxRetries times, without stopping the other uploads. Unlike the `--timeout` flag, it's not the duration
+	// of the run. It could be overridden using the `--per-file-timeout` flag.
+	PerFileTimeout string `json:"PerFileTimeout,omitempty"`
+
+	// FSRetries is the maximum number of retries when opening or reading a file to upload it fails with a transient
+	// error of the file system, e.g. EAGAIN or EIO on a network mount (default 3). Permanent errors, like a missing
+	// file, are not retried. Set it to -1 to disable retries.
+	FSRetries int `json:"FSRetries,omitempty"`
+
+	// FSRetryDelay is the delay before the first retry of a file system error, e.g. "100ms" (default "100ms").
+	// It doubles on every attempt.
+	FSRetryDelay string `json:"FSRetryDelay,omitempty"`
+
+	// RetryableMessages are substrings of the error responses of Google Photos that are retried, even if their
+	// status code is not, e.g. a 400 "The upload is too large or has failed" (default none). This is an advanced
+	// setting: retrying permanent errors only delays their failure.
+	RetryableMessages []string `json:"RetryableMessages,omitempty"`
+
+	// MaxUploadAttempts is the number of runs a failed file is attempted before giving up (default 5).
+	// Failed files are attempted first on the next run, backing off exponentially. Files that have
+	// failed MaxUploadAttempts times are not attempted again and are reported at the end of the run.
+	MaxUploadAttempts int `json:"MaxUploadAttempts,omitempty"`
+
+	// DailyRequestBudget is the maximum number of Google Photos API requests a day, to not exceed the daily
+	// quota of the API (default 0, unlimited). Requests are counted across runs, and days start at midnight
+	// Pacific Time, when the quota is reset. Once it's exhausted, the run stops uploading files, and they are
+	// uploaded on a later run. When watching, uploads are paused until the next day.
+	DailyRequestBudget int `json:"DailyRequestBudget,omitempty"`
+
+	// UploadOrder is the order in which files are uploaded.
+	// Valid options are:
+	// none: Files are uploaded as soon as they are found (default).
+	// mtime-asc: Oldest files, by modification time, are uploaded first.
+	// mtime-desc: Newest files, by modification time, are uploaded first.
+	// name: Files are uploaded sorted by its path.
+	// Any order, but none, waits for the whole folder to be scanned, keeping the list of files in memory.
+	UploadOrder string `json:"UploadOrder,omitempty"`
+
+	// MinFileAge is the minimum time since a file was last modified to be uploaded, e.g. "30s" or "5m".
+	// Newer files could still be being written, they are skipped and uploaded on a later run, or once
+	// they are old enough when watching. Empty or "0" means files are uploaded regardless of their age (default).
+	MinFileAge string `json:"MinFileAge,omitempty"`
+
+	// StableSizeCheck, when watching, checks twice, a second apart, that the size of a file has not changed
+	// before uploading it. Files still growing are uploaded once they are stable.
+	StableSizeCheck bool `json:"StableSizeCheck,omitempty"`
+
+	// MaxPhotoSize is the maximum size of a photo accepted by Google Photos, e.g. "200MB" (default).
+	// Larger photos are skipped before uploading them. Files are only uploaded if Google Photos supports its
+	// content type, detected as set by MIMEDetection.
+	MaxPhotoSize string `json:"MaxPhotoSize,omitempty"`
+
+	// MaxVideoSize is the maximum size of a video accepted by Google Photos, e.g. "10GB" (default).
+	// Larger videos are skipped before uploading them.
+	MaxVideoSize string `json:"MaxVideoSize,omitempty"`
+
+	// MIMEDetection is the way the content type of the files is detected, to skip the ones Google Photos
+	// doesn't support, and to add them to albums when CreateAlbums is mediaType.
+	// Valid options are:
+	// sniff: Reads the first bytes of every file (default).
+	// extension: Trusts the extension of the files, without reading them. It's faster on large libraries,
+	//            but files with a misleading extension are misclassified.
+	// auto: Trusts the extension of the files, and reads the ones whose extension is missing, unknown or
+	//       ambiguous, like .ts.
+	MIMEDetection string `json:"MIMEDetection,omitempty"`
+
+	// UnicodeNormalization is the Unicode form the paths of the files are normalized to before matching the include
+	// and exclude patterns, and before tracking them, so the same file is found in the same way on every system.
+	// Valid options are:
+	// nfc: Composes the accented characters, like most Linux and Windows tools do (default). Files created on
+	//      macOS, that decomposes them, match the patterns written on other systems.
+	// nfd: Decomposes the accented characters, like macOS does.
+	// off: Uses the paths as they are read.
+	// Files tracked before in another form are still found by their path.
+	UnicodeNormalization string `json:"UnicodeNormalization,omitempty"`
+
+	// OnUnsupported is what is done with the files whose content type is not supported by Google Photos, found
+	// before uploading them. Files accepted then, but rejected by Google Photos when they are uploaded, fail.
+	// Valid options are:
+	// skip: Files are omitted, they are only logged in debug mode.
+	// warn: Files are omitted, logging a warning for every one of them (default).
+	// fail: The run is aborted at the first one. The files enqueued already are uploaded, but no more files are.
+	OnUnsupported string `json:"OnUnsupported,omitempty"`
+
+	// DedupStrategy is the way to detect already uploaded files.
+	// Valid options are:
+	// path: Files are identified by its path (default).
+	// hash: Files are identified by the SHA-256 of its content too, so moved files are not uploaded again.
+	DedupStrategy string `json:"DedupStrategy,omitempty"`
+
+	// NearDuplicateThreshold, if it's set, skips the images visually similar to an already uploaded one, even if
+	// their content is different, e.g. recompressed copies of a photo. Images are similar when the Hamming distance
+	// between their perceptual hashes, of 64 bits, is up to the threshold; 5 to 10 is a good start, higher values
+	// skip images that are not copies. Perceptual hashes of JPEG, PNG and GIF images are kept in the tracking store
+	// when they are uploaded. Computing them decodes every image found, so it's disabled by default (0).
+	NearDuplicateThreshold int `json:"NearDuplicateThreshold,omitempty"`
+
+	// DedupWithinRun, if it's true, skips the files with the same content than another file enqueued in the same
+	// run, e.g. copies of a photo in different folders. Files are hashed when they are enqueued. Skipped files are
+	// not tracked, use it with DedupStrategy hash to skip them on the next runs too.
+	DedupWithinRun bool `json:"DedupWithinRun,omitempty"`
+
+	// ShareUploadTokens, if it's true, uploads once the bytes of the files with the same content uploaded in the
+	// same run, e.g. copies of a photo in different folders, but every file creates its own media item, so it's
+	// added to its own album. A file waits for the upload of the same content by another file, and uploads its own
+	// bytes if that fails. Files are hashed when they are uploaded. It has no effect on the files skipped by
+	// DedupWithinRun.
+	ShareUploadTokens bool `json:"ShareUploadTokens,omitempty"`
+
+	// DedupLibrarySearch, if it's true, searches the library for a media item with the same filename, created
+	// around the capture time of the file, before uploading it, e.g. uploaded by the mobile app. Matched files are
+	// tracked instead of uploaded. The API doesn't expose content hashes, so it's heuristic: renamed or edited
+	// files are not matched, and they are uploaded. It takes search requests, shared by files captured the same day.
+	DedupLibrarySearch bool `json:"DedupLibrarySearch,omitempty"`
+
+	// TrackerBackend is where the uploaded files are tracked.
+	// Valid options are:
+	// leveldb: Files are tracked in a LevelDB database in the application data folder (default).
+	// sqlite: Files are tracked in a SQLite database, at TrackerDBPath. It requires a build with the sqlite tag.
+	TrackerBackend string `json:"TrackerBackend,omitempty"`
+
+	// TrackerDBPath is the path of the SQLite database when TrackerBackend is sqlite
+	// (default "uploads.sqlite" in the application data folder).
+	TrackerDBPath string `json:"TrackerDBPath,omitempty"`
+
+	// TempDir is the folder where the intermediate files, like the photos converted by ConvertHEIC, are written
+	// (default is the folder for temporary files of the system). Every run uses a folder of its own in it, removed
+	// once the run has finished. It could be overridden using the `--temp-dir` flag.
+	TempDir string `json:"TempDir,omitempty"`
+
+	// MinFreeSpace is the free space to be kept in the volume of TempDir, e.g. "2GB". It's checked before writing
+	// the intermediate files, and the run is stopped once it's below it, instead of failing mid-write when the disk
+	// is full. The intermediate files of the run are removed. Empty or "0" means it's not checked (default).
+	// It's a size, like the other sizes of the configuration, not a rate: "2GB/s" is invalid. The chunks of the
+	// resumable uploads are read from the files as they are sent, never written to TempDir, so they are not checked.
+	MinFreeSpace string `json:"MinFreeSpace,omitempty"`
+
+	// ControlDir, if it's set, is the folder of the files controlling the runs: new uploads are paused while a file
+	// named "pause" exists in it, e.g. during work hours, and resumed once it's removed. The uploads in progress
+	// finish, and files keep being scanned and watched. Sending SIGUSR1 to the process toggles the pause too.
+	ControlDir string `json:"ControlDir,omitempty"`
+
+	// NotifyWebhook is the URL where a JSON summary of the run is posted once it has finished.
+	// The run doesn't fail if the webhook is unreachable.
+	NotifyWebhook string `json:"NotifyWebhook,omitempty"`
+
+	// NotifyOn is when NotifyWebhook is notified.
+	// Valid options are:
+	// always: Every run is notified (default).
+	// failure: Only runs with failed files are notified.
+	// success: Only runs without failed files are notified.
+	NotifyOn string `json:"NotifyOn,omitempty"`
+
+	// NotifyTimeout is the time to wait for the NotifyWebhook response, e.g. "5s" (default "10s").
+	NotifyTimeout string `json:"NotifyTimeout,omitempty"`
+
+	// OnUploadCommand is a command run after every uploaded file, e.g. to tag it. Its arguments are separated by
+	// spaces, and the path and the media item ID of the file are added after them. They are set in the
+	// GPHOTOS_UPLOAD_PATH and GPHOTOS_UPLOAD_MEDIA_ITEM_ID environment variables too, with the album in
+	// GPHOTOS_UPLOAD_ALBUM. It's run before moving or removing the file.
+	OnUploadCommand string `json:"OnUploadCommand,omitempty"`
+
+	// OnUploadFatal, if it's true, fails the upload when OnUploadCommand fails, so the file is neither moved
+	// nor removed. Otherwise, failures are only logged.
+	OnUploadFatal bool `json:"OnUploadFatal,omitempty"`
+
+	// OnUploadTimeout is the time OnUploadCommand has to finish before being killed, e.g. "30s" (default "1m").
+	OnUploadTimeout string `json:"OnUploadTimeout,omitempty"`
+
+	// PhotosAPIBaseURL, if it's set, is the base URL of the Google Photos Library API, instead of
+	// "https://photoslibrary.googleapis.com/", e.g. to use a proxy. Uploads and the other requests are sent to it.
+	PhotosAPIBaseURL string `json:"PhotosAPIBaseURL,omitempty"`
+
+	// ProxyURL, if it's set, is the URL of the proxy the requests to Google are sent through, e.g.
+	// "http://proxy.example.com:3128". It takes precedence over the HTTP_PROXY and HTTPS_PROXY environment variables,
+	// which are used otherwise. The hosts in the NO_PROXY environment variable are reached directly in both cases.
+	ProxyURL string `json:"ProxyURL,omitempty"`
+
+	// UserAgent, if it's set, is the User-Agent of the requests to Google, e.g. to identify them in a proxy
+	// (default is the name and the version of the tool, e.g. "gphotos-uploader-cli/v5.0.0").
+	UserAgent string `json:"UserAgent,omitempty"`
+
+	// RequestHeaders, if it's set, are the headers added to every request to Google, by their name, e.g.
+	// { "X-Request-Source": "nas" }. They don't replace the headers set by the requests, so Authorization, User-Agent,
+	// Content-Type and the like could not be set.
+	RequestHeaders map[string]string `json:"RequestHeaders,omitempty"`
+
+	// MaxConnections, if it's greater than 0, is the maximum number of connections to every Google host (default 16),
+	// whatever the number of workers, e.g. to avoid the rate limiting of too many connections. Requests wait for
+	// a connection once they are all in use.
+	MaxConnections int `json:"MaxConnections,omitempty"`
+
 	// Jobs are the source folders to work with.
 	Jobs []FolderUploadJob `json:"Jobs"`
 }
 
 // APIAppCredentials represents Google Photos API credentials for OAuth.
+// Either ClientID and ClientSecret, or ServiceAccountKey, should be set.
 type APIAppCredentials struct {
 	// ClientID is the app identifier generated on the Google API console.
 	ClientID string `json:"ClientID"`
 	// ClientSecret is the secret key generated on the Google API console.
 	ClientSecret string `json:"ClientSecret"`
+
+	// ServiceAccountKey is the path to the JSON key file of a service account.
+	// It authenticates without the interactive consent, e.g. on headless servers.
+	ServiceAccountKey string `json:"ServiceAccountKey,omitempty"`
+	// Subject is the user impersonated by the service account. It requires domain-wide delegation
+	// on a Google Workspace domain, the service account acts on its own behalf if it's empty.
+	Subject string `json:"Subject,omitempty"`
+}
+
+// UsesServiceAccount returns true if the credentials are a service account key.
+func (c APIAppCredentials) UsesServiceAccount() bool {
+	return c.ServiceAccountKey != ""
+}
+
+// NamedAccount represents a Google Photos account that jobs could reference by its name.
+type NamedAccount struct {
+	// Name is the name used by jobs to reference the account.
+	Name string `json:"Name"`
+
+	// Account is the Google Photos account. Every account has its own OAuth token.
+	Account string `json:"Account"`
 }
 
 // FolderUploadJob represents configuration for a folder to be uploaded
 type FolderUploadJob struct {
-	// DEPRECATED: Account is deprecated, use Config.Account instead.
-	Account string `json:"-"`
+	// Account is the name of the account, from Config.Accounts, where to upload files.
+	// Uses Config.Account if it's empty.
+	Account string `json:"Account,omitempty"`
 
 	// SourceFolder is the folder containing the objects to be uploaded.
+	// It could be a glob pattern, e.g. "/photos/*/incoming", to upload every matching folder as a job.
 	SourceFolder string `json:"SourceFolder"`
 
+	// SourceFolders, if it's set instead of SourceFolder, are several folders forming one library, e.g. the photos
+	// spread across several disks. Every folder is scanned with the settings of the job, like a job of its own, so
+	// the paths used for albums, patterns and favorites are relative to the folder of every file. Folders could be
+	// glob patterns too. With DedupStrategy hash, files with the same content in several folders are uploaded once,
+	// even in the same run, e.g. a photo copied to two disks. Folders could not be inside another one of them.
+	SourceFolders []string `json:"SourceFolders,omitempty"`
+
+	// AllowEmptyGlob if it is true, a SourceFolder glob pattern could match no folder.
+	// The configuration is invalid otherwise, to catch typos.
+	AllowEmptyGlob bool `json:"AllowEmptyGlob,omitempty"`
+
+	// library identifies the jobs expanded from the same SourceFolders, see Library.
+	library string
+
 	// CreateAlbums is the parameter to create albums on Google Photos.
 	// Valid options are:
 	// Off: Disable album creation (default).
 	// folderPath: Creates album with the name based on full folder path.
 	// folderName: Creates album with the name based on the folder name.
+	// exifDate: Creates album with the name based on the date the photo was taken (see AlbumDateFormat).
+	// mediaType: Adds photos to PhotoAlbum, and videos to VideoAlbum, by their content type.
 	CreateAlbums string `json:"CreateAlbums,omitempty"`
 
+	// AlbumPathSeparator is the separator of folder names when CreateAlbums is folderPath (default "_").
+	// e.g. using " - ", files in "Trips/2023-Italy" are added to the "Trips - 2023-Italy" album.
+	AlbumPathSeparator string `json:"AlbumPathSeparator,omitempty"`
+
+	// AlbumHierarchySeparator, if it's set, joins the names of all the folders of the files when CreateAlbums is
+	// folderName, instead of using the name of their folder only, e.g. using " › ", files in "2023/Italy/Rome" are
+	// added to the "2023 › Italy › Rome" album. It's the separator of the Hierarchy field of AlbumNameTemplate
+	// too (default " › "). Empty folder names are skipped.
+	AlbumHierarchySeparator string `json:"AlbumHierarchySeparator,omitempty"`
+
+	// AlbumHierarchyDepth, if it's greater than zero, is the maximum number of folders in the album names joined by
+	// AlbumHierarchySeparator. Files in deeper folders are added to the album of their ancestor, e.g. using 2, files
+	// in "2023/Italy/Rome" are added to the "2023 › Italy" album.
+	AlbumHierarchyDepth int `json:"AlbumHierarchyDepth,omitempty"`
+
+	// AlbumNameTemplate, if it's set, is the text/template of the album names of the files, overriding CreateAlbums,
+	// e.g. "{{.Year}}/{{.ParentDir}}". Its fields are:
+	// Year, Month, Day: The date the photo was taken, e.g. "2023", "07" and "15", derived like when CreateAlbums is exifDate.
+	// ParentDir: The name of the folder of the file.
+	// Dir: The path of the folder of the file, relative to SourceFolder, e.g. "Trips/2023-Italy".
+	// Hierarchy: The folders of the file joined by AlbumHierarchySeparator, up to AlbumHierarchyDepth, e.g. "Trips › 2023-Italy".
+	// RelPath: The path of the file, relative to SourceFolder, e.g. "Trips/2023-Italy/IMG_0001.jpg".
+	// Name, Ext: The name of the file without its extension, and its extension in lower case, e.g. "IMG_0001" and "jpg".
+	// Files whose album name is empty are not added to any album.
+	AlbumNameTemplate string `json:"AlbumNameTemplate,omitempty"`
+
+	// AlbumDateFormat is the Go layout of album names when CreateAlbums is exifDate (default "2006-01", one album per month).
+	// The date is read from the EXIF DateTimeOriginal of the photo, or from the file modification time if it's not available.
+	AlbumDateFormat string `json:"AlbumDateFormat,omitempty"`
+
+	// DateFromFilename, if it's set, derives the date the photo was taken from its name, e.g. "20230715_142233.jpg",
+	// for `--since`, `--until` and when CreateAlbums is exifDate, without reading the EXIF metadata. Files whose
+	// name doesn't match use the EXIF DateTimeOriginal, or the file modification time.
+	DateFromFilename *DateFromFilename `json:"DateFromFilename,omitempty"`
+
+	// CaptureDateDescription, if it's set, is the Go layout of the date the photo was taken, added to the description
+	// of its media item, e.g. "Taken on 2006-01-02 15:04". The date is derived like when CreateAlbums is exifDate.
+	// The Google Photos API doesn't allow to set the creation time of media items, Google Photos reads it from the
+	// EXIF metadata of the file, or uses the upload time. So files without EXIF metadata are still ordered by
+	// their upload time in the timeline, the date is only shown in their description.
+	CaptureDateDescription string `json:"CaptureDateDescription,omitempty"`
+
+	// PhotoAlbum is the album of the photos when CreateAlbums is mediaType (default "Photos").
+	PhotoAlbum string `json:"PhotoAlbum,omitempty"`
+
+	// VideoAlbum is the album of the videos when CreateAlbums is mediaType (default "Videos").
+	VideoAlbum string `json:"VideoAlbum,omitempty"`
+
+	// OtherAlbum is the album of the files that are neither photos nor videos when CreateAlbums is mediaType.
+	// They are not added to any album if it's not set.
+	OtherAlbum string `json:"OtherAlbum,omitempty"`
+
 	// DEPRECATED: MakeAlbums is deprecated, use Config.Jobs.CreateAlbums instead.
 	MakeAlbums MakeAlbums `json:"-"`
 
 	// DeleteAfterUpload if it is true, the app will remove files after upload them.
+	// It's the same than setting AfterUpload to delete.
 	DeleteAfterUpload bool `json:"DeleteAfterUpload"`
 
+	// AfterUpload is what to do with files after upload them.
+	// Valid options are:
+	// keep: Leave files in place (default).
+	// delete: Remove files.
+	// trash: Move files to the trash of the OS, so they could be restored. It's not supported on Windows.
+	// move: Move files to MoveToDir, keeping its path relative to SourceFolder.
+	AfterUpload string `json:"AfterUpload,omitempty"`
+
+	// MoveToDir is the folder where to move files after upload them, when AfterUpload is move.
+	// It could not be inside SourceFolder.
+	MoveToDir string `json:"MoveToDir,omitempty"`
+
+	// Workers, if it's greater than 0, is the number of files of the job to be uploaded concurrently, overriding
+	// UploadWorkerCount and `--workers`. The job has a pool of workers of its own, instead of the shared one.
+	Workers int `json:"Workers,omitempty"`
+
+	// RateLimit, if it's set, is the maximum upload rate of the files of the job, e.g. "2MB/s", overriding
+	// UploadRateLimit and `--rate-limit`. "0" means unlimited.
+	RateLimit string `json:"RateLimit,omitempty"`
+
+	// ConvertHEIC if it is true, HEIC and HEIF photos are converted to JPEG before upload them, keeping
+	// their EXIF metadata. It requires heif-convert, from libheif. Photos are uploaded as they are otherwise.
+	ConvertHEIC bool `json:"ConvertHEIC,omitempty"`
+
 	// IncludePatterns are the patterns to include files to work with.
 	IncludePatterns []string `json:"IncludePatterns"`
 
 	// ExcludePatterns are the patterns to exclude files.
 	ExcludePatterns []string `json:"ExcludePatterns"`
+
+	// AllowedMimeTypes, if it's set, are the content types of the files to work with, e.g. "image/jpeg" or "image/*".
+	// The content type is detected from the content of the files, whatever their extension or MIMEDetection are,
+	// once they are allowed by IncludePatterns and ExcludePatterns.
+	AllowedMimeTypes []string `json:"AllowedMimeTypes,omitempty"`
+
+	// ExcludedMimeTypes are the content types of the files to exclude, like AllowedMimeTypes.
+	ExcludedMimeTypes []string `json:"ExcludedMimeTypes,omitempty"`
+
+	// MinWidth and MinHeight, if they are set, skip the images smaller than them, in pixels, e.g. thumbnails or
+	// previews with the same extension as the photos. The dimensions are read from the header of the images, as
+	// they are displayed given their EXIF orientation. Only JPEG, PNG and GIF images are checked.
+	MinWidth  int `json:"MinWidth,omitempty"`
+	MinHeight int `json:"MinHeight,omitempty"`
+
+	// IncludePatternsFile, if it's set, is a file with more patterns to include files, one per line.
+	// Blank lines and lines starting with `#` are ignored. Its patterns are added after IncludePatterns.
+	// A relative path is relative to the folder of the configuration file.
+	IncludePatternsFile string `json:"IncludePatternsFile,omitempty"`
+
+	// ExcludePatternsFile, if it's set, is a file with more patterns to exclude files, like IncludePatternsFile.
+	// Its patterns are added after ExcludePatterns, so they are evaluated last.
+	ExcludePatternsFile string `json:"ExcludePatternsFile,omitempty"`
+
+	// IncludeAll if it is true, all the files not excluded by ExcludePatterns are uploaded, bypassing
+	// IncludePatterns, like `push --no-filter` does. Non-media files would be rejected by Google Photos, so files
+	// whose content type is not supported are skipped before uploading them, detected as set by MIMEDetection.
+	IncludeAll bool `json:"IncludeAll,omitempty"`
+
+	// FollowSymlinks if it is true, the files symbolic links point to are uploaded, and the directories they
+	// point to are scanned, skipping the links that loop back to the directories they are in.
+	// Symbolic links are skipped, and logged, otherwise (default).
+	FollowSymlinks bool `json:"FollowSymlinks,omitempty"`
+
+	// IncludeHidden if it is true, hidden files are uploaded and hidden directories are scanned, like
+	// `push --include-hidden` does. Hidden files are the ones whose name starts with a dot, and on Windows the
+	// ones with the hidden attribute too. They are skipped, with the `hidden` reason, otherwise (default).
+	IncludeHidden bool `json:"IncludeHidden,omitempty"`
+
+	// MinDepth and MaxDepth, if they are set, are the bounds of the depth of the files to work with, relative to
+	// SourceFolder: the files directly in it have depth 0, the ones in its subfolders depth 1, and so on. Folders
+	// whose files would be deeper than MaxDepth are not scanned, e.g. deeply nested caches. They are applied along
+	// with IncludePatterns and ExcludePatterns.
+	MinDepth int  `json:"MinDepth,omitempty"`
+	MaxDepth *int `json:"MaxDepth,omitempty"`
+
+	// ExifFilters, if it's set, skips the files whose EXIF metadata doesn't match it, after IncludePatterns
+	// and ExcludePatterns are applied. Files without EXIF metadata, like screenshots or videos, have no camera.
+	ExifFilters *ExifFilters `json:"ExifFilters,omitempty"`
+
+	// Albums, if it's set, routes files to albums by their own patterns, after IncludePatterns and
+	// ExcludePatterns are applied. A file is added to the first album whose filter allows it, overriding
+	// the album given by CreateAlbums, and files not allowed by any album are skipped.
+	Albums []AlbumMapping `json:"Albums,omitempty"`
+
+	// Routes, if it's set, uploads files to other accounts by their patterns or content types, so a folder could
+	// be split by media type, e.g. RAW files to an archive account. A file is uploaded to the account of the first
+	// route matching it, and files not matching any route are uploaded to Account.
+	Routes []Route `json:"Routes,omitempty"`
+
+	// DefaultAlbum, if it's set, is the album of the files not added to any album by CreateAlbums,
+	// AlbumNameTemplate, Albums or Routes, e.g. "Uploaded by gphotos-uploader-cli", so the uploads are kept
+	// apart from the other photos. It's created once, like the other albums, and SharedAlbum overrides it.
+	DefaultAlbum string `json:"DefaultAlbum,omitempty"`
+
+	// SharedAlbum, if it's set, is the album shared with the account where the files are added, instead of the
+	// albums given by CreateAlbums, AlbumNameTemplate, Albums or Routes, e.g. an album of the family. Shared albums
+	// are not searched among the albums of the account, even if one has the same title. The account joins the album
+	// if it has not joined it yet, and the run fails if the account is not allowed to add photos to it.
+	SharedAlbum *SharedAlbum `json:"SharedAlbum,omitempty"`
+
+	// DetectLivePhotos, if it's true, detects the Live Photos: a photo, e.g. ".heic" or ".jpg", and a video, e.g.
+	// ".mov", with the same name in the same folder. The video is added to the album of its photo, so both parts are
+	// kept together, since the Google Photos API doesn't allow to upload them as a Live Photo.
+	DetectLivePhotos bool `json:"DetectLivePhotos,omitempty"`
+
+	// FavoritesFolder, if it's set, is the name of the folders whose files should be marked as favorites, e.g. "_favorites".
+	// The Google Photos API doesn't allow to mark media items as favorites, so the files are only recorded as
+	// favorites in the run summary and in the tracking database, to be marked by other means.
+	FavoritesFolder string `json:"FavoritesFolder,omitempty"`
+}
+
+// ExifFilters are the EXIF metadata of the files to work with. Files must match all of the set ones.
+type ExifFilters struct {
+	// Make is the camera maker, e.g. "SONY", ignoring the case.
+	Make string `json:"Make,omitempty"`
+
+	// Model is the camera model, e.g. "ILCE-7M4", ignoring the case.
+	Model string `json:"Model,omitempty"`
+
+	// GPS, if it's true, only allows photos with GPS data and, if it's false, only the ones without it.
+	GPS *bool `json:"GPS,omitempty"`
+}
+
+// DateFromFilename is how the date the photo was taken is found in its name.
+type DateFromFilename struct {
+	// Layout is the Go layout of the date, e.g. "20060102_150405".
+	Layout string `json:"Layout"`
+
+	// Pattern, if it's set, is the regular expression matching the date in the file name, e.g. "^IMG_(\\d{8})_".
+	// The date is its capturing group, or the whole match without groups. Otherwise, the name starts with the date.
+	Pattern string `json:"Pattern,omitempty"`
+}
+
+// AlbumMapping represents an album where files matching its patterns are added.
+type AlbumMapping struct {
+	// Name is the name of the album.
+	Name string `json:"Name"`
+
+	// IncludePatterns are the patterns of files added to the album (default _IMAGE_EXTENSIONS_).
+	IncludePatterns []string `json:"IncludePatterns,omitempty"`
+
+	// ExcludePatterns are the patterns of files not added to the album.
+	ExcludePatterns []string `json:"ExcludePatterns,omitempty"`
+
+	// Description is the text shown at the top of the album. It's only set when the album is created,
+	// since the Library API only allows to change albums created by this tool.
+	Description string `json:"Description,omitempty"`
+
+	// CoverPhotoPath is the file, relative to SourceFolder, set as cover photo of the album once it's uploaded.
+	CoverPhotoPath string `json:"CoverPhotoPath,omitempty"`
+
+	// CoverPhotoStrategy chooses the cover photo among the files uploaded to the album, by modification time.
+	// Valid values are "first" and "latest". It could not be used with CoverPhotoPath.
+	CoverPhotoStrategy string `json:"CoverPhotoStrategy,omitempty"`
+}
+
+// Route represents the account where the files matching it are uploaded. A route matches a file if it matches
+// any of its patterns, when they are set, and any of its content types, when they are set. A route without
+// patterns and content types matches all the files, so it's the default route when it's the last one.
+type Route struct {
+	// Match are the patterns of the files of the route, e.g. "_RAW_EXTENSIONS_".
+	Match []string `json:"Match,omitempty"`
+
+	// MimeTypes are the content types of the files of the route, e.g. "image/jpeg" or "video/*", detected like
+	// for MIMEDetection.
+	MimeTypes []string `json:"MimeTypes,omitempty"`
+
+	// Account is the name of the account, from Config.Accounts, where the files are uploaded.
+	// Uses the Account of the job if it's empty.
+	Account string `json:"Account,omitempty"`
+
+	// Album, if it's set, is the album of the files, instead of the one given by CreateAlbums or Albums.
+	Album string `json:"Album,omitempty"`
+}
+
+// SharedAlbum identifies an album shared with the account, by its share token or by its title.
+type SharedAlbum struct {
+	// ShareToken is the share token of the album, given by the Library API to the users it's shared with.
+	ShareToken string `json:"ShareToken,omitempty"`
+
+	// Title is the title of the album, if ShareToken is not set. The account must have joined the album.
+	Title string `json:"Title,omitempty"`
 }
 
 // DEPRECATED: MakeAlbums is deprecated, use Config.Jobs.CreateAlbums instead
@@ -0,0 +1,76 @@
+// Package convert transcodes files before uploading them, e.g. HEIC photos to JPEG.
+package convert
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Converter transcodes an image to JPEG.
+// Implementations must keep the EXIF metadata, since Google Photos gets the date the photo was taken from it.
+type Converter interface {
+	ToJPEG(ctx context.Context, src string, dst string) error
+}
+
+// Command is a Converter running an external program.
+type Command struct {
+	// Name is the program to run.
+	Name string
+	// Args returns the arguments of the program to convert src into dst.
+	Args func(src string, dst string) []string
+}
+
+// HeifConvert converts images using heif-convert, from libheif, which keeps the EXIF metadata.
+var HeifConvert = Command{
+	Name: "heif-convert",
+	Args: func(src string, dst string) []string {
+		return []string{"-q", "92", src, dst}
+	},
+}
+
+// ToJPEG runs the program to convert src into dst.
+func (c Command) ToJPEG(ctx context.Context, src string, dst string) error {
+	out, err := exec.CommandContext(ctx, c.Name, c.Args(src, dst)...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s failed converting '%s': %s: %s", c.Name, src, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// IsHEIC returns true if the file is a HEIC or HEIF image, by its extension.
+func IsHEIC(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".heic", ".heif":
+		return true
+	}
+	return false
+}
+
+// ToTempJPEG converts the file to a JPEG, with the same name, in a temporary folder in tempDir, or in the default
+// folder for temporary files if it's empty. tempDir is created if it doesn't exist.
+// It returns the path of the JPEG and a function to remove it once it's not needed.
+func ToTempJPEG(ctx context.Context, c Converter, src string, tempDir string) (string, func(), error) {
+	if tempDir != "" {
+		if err := os.MkdirAll(tempDir, 0700); err != nil {
+			return "", nil, err
+		}
+	}
+	dir, err := ioutil.TempDir(tempDir, "gphotos-convert")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { _ = os.RemoveAll(dir) }
+
+	name := strings.TrimSuffix(filepath.Base(src), filepath.Ext(src)) + ".jpg"
+	dst := filepath.Join(dir, name)
+	if err := c.ToJPEG(ctx, src, dst); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return dst, cleanup, nil
+}
@@ -0,0 +1,81 @@
+package convert_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/convert"
+)
+
+func TestIsHEIC(t *testing.T) {
+	var testCases = []struct {
+		path string
+		want bool
+	}{
+		{"photos/IMG_0001.heic", true},
+		{"photos/IMG_0001.HEIC", true},
+		{"photos/IMG_0001.heif", true},
+		{"photos/IMG_0001.jpg", false},
+		{"photos/heic", false},
+	}
+
+	for _, tc := range testCases {
+		if got := convert.IsHEIC(tc.path); got != tc.want {
+			t.Errorf("want: %t, got: %t, path: %s", tc.want, got, tc.path)
+		}
+	}
+}
+
+func TestToTempJPEG(t *testing.T) {
+	dir, err := ioutil.TempDir("", "convert")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "IMG_0001.HEIC")
+	if err := ioutil.WriteFile(src, []byte("photo"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// cp stands for a real converter, it's enough to check the arguments.
+	c := convert.Command{
+		Name: "cp",
+		Args: func(src string, dst string) []string { return []string{src, dst} },
+	}
+	tempDir := filepath.Join(dir, "tmp")
+	dst, cleanup, err := convert.ToTempJPEG(context.Background(), c, src, tempDir)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if !strings.HasPrefix(dst, tempDir+string(filepath.Separator)) {
+		t.Errorf("want: file in %s, got: %s", tempDir, dst)
+	}
+	if filepath.Base(dst) != "IMG_0001.jpg" {
+		t.Errorf("want: %s, got: %s", "IMG_0001.jpg", filepath.Base(dst))
+	}
+	if b, err := ioutil.ReadFile(dst); err != nil || string(b) != "photo" {
+		t.Errorf("want: %s, got: %s, err: %v", "photo", b, err)
+	}
+
+	cleanup()
+	if _, err := os.Stat(dst); !os.IsNotExist(err) {
+		t.Errorf("converted file was not expected to exist: %s", dst)
+	}
+	if entries, err := ioutil.ReadDir(tempDir); err != nil || len(entries) != 0 {
+		t.Errorf("want: empty %s, got: %v, err: %v", tempDir, entries, err)
+	}
+}
+
+func TestCommand_ToJPEGFailed(t *testing.T) {
+	c := convert.Command{
+		Name: "false",
+		Args: func(src string, dst string) []string { return nil },
+	}
+	if _, _, err := convert.ToTempJPEG(context.Background(), c, "IMG_0001.heic", ""); err == nil {
+		t.Errorf("error was expected at this point")
+	}
+}
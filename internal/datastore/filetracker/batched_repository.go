@@ -0,0 +1,256 @@
+package filetracker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrRepositoryClosed is returned when writing to a BatchedRepository after closing it.
+var ErrRepositoryClosed = errors.New("tracking repository is closed")
+
+const (
+	// DefaultBatchSize is the default maximum number of writes committed at once.
+	DefaultBatchSize = 100
+
+	// DefaultBatchInterval is the default maximum time that a write waits to be committed.
+	DefaultBatchInterval = 200 * time.Millisecond
+)
+
+// Op is a write operation: it puts the Item under the Key or, if Delete is set, removes the Key.
+type Op struct {
+	Key    string
+	Item   TrackedFile
+	Delete bool
+}
+
+// BatchWriter is a Repository able to commit several operations at once.
+type BatchWriter interface {
+	WriteBatch(ops []Op) error
+}
+
+// BatchedRepository implements a Repository funneling all the writes through a single goroutine,
+// that commits them in batches of up to size operations, or every interval, whichever comes first.
+// Writes not committed yet are visible to reads, so it behaves like the wrapped repository.
+// It's safe for concurrent use. Close commits the pending writes before closing the wrapped repository.
+type BatchedRepository struct {
+	repo     Repository
+	size     int
+	interval time.Duration
+
+	// mu protects pending, the last not committed write of every key, and err.
+	mu      sync.RWMutex
+	pending map[string]pendingOp
+	seq     uint64
+	err     error
+
+	// sendMu keeps the enqueued operations in the same order than seq, and protects closed.
+	sendMu  sync.Mutex
+	closed  bool
+	ops     chan pendingOp
+	flushes chan chan error
+	done    chan struct{}
+	close   sync.Once
+}
+
+// pendingOp is a write operation, with its order to know if it has been overwritten.
+type pendingOp struct {
+	Op
+	seq uint64
+}
+
+// NewBatchedRepository returns a repository batching the writes to repo. Defaults are used if
+// size or interval are not positive.
+func NewBatchedRepository(repo Repository, size int, interval time.Duration) *BatchedRepository {
+	if size <= 0 {
+		size = DefaultBatchSize
+	}
+	if interval <= 0 {
+		interval = DefaultBatchInterval
+	}
+	r := &BatchedRepository{
+		repo:     repo,
+		size:     size,
+		interval: interval,
+		pending:  make(map[string]pendingOp),
+		ops:      make(chan pendingOp, size),
+		flushes:  make(chan chan error),
+		done:     make(chan struct{}),
+	}
+	go r.write()
+	return r
+}
+
+// Get returns the item specified by key, including the writes not committed yet.
+// It returns ErrItemNotFound if the repository does not contains the key.
+func (r *BatchedRepository) Get(key string) (TrackedFile, error) {
+	r.mu.RLock()
+	op, exist := r.pending[key]
+	r.mu.RUnlock()
+	if !exist {
+		return r.repo.Get(key)
+	}
+	if op.Delete {
+		return TrackedFile{}, ErrItemNotFound
+	}
+	return op.Item, nil
+}
+
+// Put enqueues the item to be stored under key. It returns the first error committing writes, if any,
+// without enqueuing it, or ErrRepositoryClosed after Close.
+func (r *BatchedRepository) Put(key string, item TrackedFile) error {
+	return r.enqueue(Op{Key: key, Item: item})
+}
+
+// Delete enqueues the removal of the item specified by key. It returns the first error committing writes,
+// if any, without enqueuing it, or ErrRepositoryClosed after Close.
+func (r *BatchedRepository) Delete(key string) error {
+	return r.enqueue(Op{Key: key, Delete: true})
+}
+
+// Iterate commits the pending writes, and then calls fn for every item.
+func (r *BatchedRepository) Iterate(fn func(key string, item TrackedFile) error) error {
+	if err := r.Flush(); err != nil {
+		return err
+	}
+	return r.repo.Iterate(fn)
+}
+
+// Flush commits the pending writes. It returns the first error committing writes, if any.
+func (r *BatchedRepository) Flush() error {
+	result := make(chan error)
+	select {
+	case r.flushes <- result:
+		return <-result
+	case <-r.done:
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		return r.err
+	}
+}
+
+// Close commits the pending writes, and closes the wrapped repository.
+// No operation could be done after that.
+func (r *BatchedRepository) Close() error {
+	var err error
+	r.close.Do(func() {
+		// no operation is enqueued after that, so the flush commits all of them.
+		r.sendMu.Lock()
+		r.closed = true
+		r.sendMu.Unlock()
+
+		err = r.Flush()
+		close(r.done)
+		if closeErr := r.repo.Close(); err == nil {
+			err = closeErr
+		}
+	})
+	return err
+}
+
+func (r *BatchedRepository) enqueue(op Op) error {
+	r.sendMu.Lock()
+	defer r.sendMu.Unlock()
+	if r.closed {
+		return ErrRepositoryClosed
+	}
+
+	r.mu.Lock()
+	if r.err != nil {
+		r.mu.Unlock()
+		return r.err
+	}
+	r.seq++
+	p := pendingOp{Op: op, seq: r.seq}
+	r.pending[op.Key] = p
+	r.mu.Unlock()
+
+	r.ops <- p
+	return nil
+}
+
+// write is the single writer, committing the enqueued operations.
+func (r *BatchedRepository) write() {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	batch := make([]pendingOp, 0, r.size)
+	commit := func() {
+		if len(batch) == 0 {
+			return
+		}
+		r.commit(batch)
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case op := <-r.ops:
+			batch = append(batch, op)
+			if len(batch) >= r.size {
+				commit()
+			}
+		case <-ticker.C:
+			commit()
+		case result := <-r.flushes:
+			// operations enqueued before the flush are in the channel.
+			for drained := false; !drained; {
+				select {
+				case op := <-r.ops:
+					batch = append(batch, op)
+				default:
+					drained = true
+				}
+			}
+			commit()
+			r.mu.RLock()
+			result <- r.err
+			r.mu.RUnlock()
+		case <-r.done:
+			return
+		}
+	}
+}
+
+// commit writes the batch to the wrapped repository. Committed operations are not pending anymore,
+// unless the key has been written again. Failed operations are kept pending, so they are still visible.
+func (r *BatchedRepository) commit(batch []pendingOp) {
+	ops := make([]Op, len(batch))
+	for i, p := range batch {
+		ops[i] = p.Op
+	}
+	err := writeOps(r.repo, ops)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		if r.err == nil {
+			r.err = err
+		}
+		return
+	}
+	for _, p := range batch {
+		if current, exist := r.pending[p.Key]; exist && current.seq == p.seq {
+			delete(r.pending, p.Key)
+		}
+	}
+}
+
+// writeOps writes the operations at once, if the repository supports it, or one by one.
+func writeOps(repo Repository, ops []Op) error {
+	if w, ok := repo.(BatchWriter); ok {
+		return w.WriteBatch(ops)
+	}
+	for _, op := range ops {
+		var err error
+		if op.Delete {
+			err = repo.Delete(op.Key)
+		} else {
+			err = repo.Put(op.Key, op.Item)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
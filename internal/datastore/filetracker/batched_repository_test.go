@@ -0,0 +1,205 @@
+package filetracker_test
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/datastore/filetracker"
+)
+
+func TestBatchedRepository_Conformance(t *testing.T) {
+	testRepositoryConformance(t, func(t *testing.T) (filetracker.Repository, func()) {
+		dir, err := ioutil.TempDir("", "filetracker")
+		if err != nil {
+			t.Fatal(err)
+		}
+		leveldb, err := filetracker.NewLevelDBRepository(filepath.Join(dir, "uploads.db"))
+		if err != nil {
+			os.RemoveAll(dir)
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+		repo := filetracker.NewBatchedRepository(leveldb, 10, time.Hour)
+		return repo, func() {
+			_ = repo.Close()
+			os.RemoveAll(dir)
+		}
+	})
+}
+
+func TestBatchedRepository_ConcurrentWrites(t *testing.T) {
+	const writers = 50
+	const filesPerWriter = 100
+
+	dir, err := ioutil.TempDir("", "filetracker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "uploads.db")
+
+	leveldb, err := filetracker.NewLevelDBRepository(filename)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	repo := filetracker.NewBatchedRepository(leveldb, 16, 5*time.Millisecond)
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		w := w
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < filesPerWriter; i++ {
+				key := fmt.Sprintf("/photos/%d/%d.jpg", w, i)
+				if err := repo.Put(key, filetracker.NewTrackedFile(key)); err != nil {
+					t.Errorf("error was not expected at this point: %s", err)
+				}
+				// written files are visible, even if they are not committed yet.
+				if item, err := repo.Get(key); err != nil || item.Hash() != key {
+					t.Errorf("want: %s, got: %s, err: %v", key, item.Hash(), err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := repo.Close(); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	// all the files should have been committed when the repository was closed.
+	reopened, err := filetracker.NewLevelDBRepository(filename)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	defer reopened.Close()
+
+	var got int
+	err = reopened.Iterate(func(key string, item filetracker.TrackedFile) error {
+		if item.Hash() != key {
+			t.Errorf("want: %s, got: %s", key, item.Hash())
+		}
+		got++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if got != writers*filesPerWriter {
+		t.Errorf("want: %d, got: %d", writers*filesPerWriter, got)
+	}
+}
+
+func TestBatchedRepository_BatchSize(t *testing.T) {
+	repo := &batchRecorder{memoryRepository: newMemoryRepository()}
+	batched := filetracker.NewBatchedRepository(repo, 10, time.Hour)
+
+	for i := 0; i < 25; i++ {
+		_ = batched.Put(fmt.Sprintf("%d.jpg", i), filetracker.NewTrackedFile("hash"))
+	}
+	if err := batched.Close(); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	want := []int{10, 10, 5}
+	if fmt.Sprint(want) != fmt.Sprint(repo.batches) {
+		t.Errorf("want: %v, got: %v", want, repo.batches)
+	}
+	if len(repo.items) != 25 {
+		t.Errorf("want: %d, got: %d", 25, len(repo.items))
+	}
+}
+
+func TestBatchedRepository_BatchInterval(t *testing.T) {
+	repo := &batchRecorder{memoryRepository: newMemoryRepository()}
+	batched := filetracker.NewBatchedRepository(repo, 100, 10*time.Millisecond)
+	defer batched.Close()
+
+	_ = batched.Put("a.jpg", filetracker.NewTrackedFile("hash"))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for repo.committed() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("want: committed write, got: not committed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestBatchedRepository_WriteAfterClose(t *testing.T) {
+	batched := filetracker.NewBatchedRepository(newMemoryRepository(), 1, time.Hour)
+	if err := batched.Close(); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	if err := batched.Put("a.jpg", filetracker.NewTrackedFile("hash")); !errors.Is(err, filetracker.ErrRepositoryClosed) {
+		t.Errorf("want: %v, got: %v", filetracker.ErrRepositoryClosed, err)
+	}
+	if err := batched.Delete("a.jpg"); !errors.Is(err, filetracker.ErrRepositoryClosed) {
+		t.Errorf("want: %v, got: %v", filetracker.ErrRepositoryClosed, err)
+	}
+}
+
+func TestBatchedRepository_WriteAfterCommitError(t *testing.T) {
+	writeErr := errors.New("disk full")
+	batched := filetracker.NewBatchedRepository(&failingBatchWriter{memoryRepository: newMemoryRepository(), err: writeErr}, 10, time.Hour)
+	defer batched.Close()
+
+	if err := batched.Put("a.jpg", filetracker.NewTrackedFile("hash")); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if err := batched.Flush(); !errors.Is(err, writeErr) {
+		t.Fatalf("want: %v, got: %v", writeErr, err)
+	}
+
+	if err := batched.Put("b.jpg", filetracker.NewTrackedFile("hash")); !errors.Is(err, writeErr) {
+		t.Errorf("want: %v, got: %v", writeErr, err)
+	}
+	if err := batched.Delete("a.jpg"); !errors.Is(err, writeErr) {
+		t.Errorf("want: %v, got: %v", writeErr, err)
+	}
+}
+
+// failingBatchWriter is a memory repository failing to write any batch.
+type failingBatchWriter struct {
+	*memoryRepository
+	err error
+}
+
+func (r *failingBatchWriter) WriteBatch([]filetracker.Op) error {
+	return r.err
+}
+
+// batchRecorder is a memory repository recording the size of the written batches.
+type batchRecorder struct {
+	*memoryRepository
+
+	mu      sync.Mutex
+	batches []int
+}
+
+func (r *batchRecorder) WriteBatch(ops []filetracker.Op) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.batches = append(r.batches, len(ops))
+	for _, op := range ops {
+		if op.Delete {
+			delete(r.items, op.Key)
+		} else {
+			r.items[op.Key] = op.Item
+		}
+	}
+	return nil
+}
+
+func (r *batchRecorder) committed() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.batches)
+}
@@ -1,12 +1,30 @@
 package filetracker
 
 import (
+	"encoding/json"
 	"strings"
+	"time"
 )
 
 // TrackedFile represents a tracked file in the repository.
 type TrackedFile struct {
 	value string
+
+	// MediaItemID is the Google Photos media item created when the file was uploaded.
+	// It's empty for files tracked by previous versions.
+	MediaItemID string
+
+	// UploadedAt is when the file was uploaded.
+	// It's zero for files tracked by previous versions.
+	UploadedAt time.Time
+
+	// Albums are the names of the albums where the media item has been added.
+	// It's empty for files tracked by previous versions, or uploaded without album.
+	Albums []string
+
+	// Favorite is true if the file should be marked as favorite in Google Photos, since it was found in the
+	// favorites folder. The Google Photos API doesn't allow to mark media items as favorites, so it's only recorded.
+	Favorite bool
 }
 
 // NewTrackedFile returns a TrackedFile with the specified values
@@ -29,3 +47,44 @@ func (f TrackedFile) Hash() string {
 	}
 	return parts[0]
 }
+
+// trackedFileJSON is the format of a TrackedFile with a media item in the repository.
+type trackedFileJSON struct {
+	Hash        string    `json:"hash"`
+	MediaItemID string    `json:"mediaItemId,omitempty"`
+	UploadedAt  time.Time `json:"uploadedAt,omitempty"`
+	Albums      []string  `json:"albums,omitempty"`
+	Favorite    bool      `json:"favorite,omitempty"`
+}
+
+// marshal returns the value to be stored in the repository.
+// Files without media item nor upload time keep the previous format, the hash, so
+// a repository could be read by previous versions.
+func (f TrackedFile) marshal() []byte {
+	if f.MediaItemID == "" && f.UploadedAt.IsZero() && len(f.Albums) == 0 && !f.Favorite {
+		return []byte(f.value)
+	}
+	b, _ := json.Marshal(trackedFileJSON{
+		Hash:        f.Hash(),
+		MediaItemID: f.MediaItemID,
+		UploadedAt:  f.UploadedAt,
+		Albums:      f.Albums,
+		Favorite:    f.Favorite,
+	})
+	return b
+}
+
+// unmarshalTrackedFile returns the TrackedFile stored in the repository as value.
+func unmarshalTrackedFile(value []byte) TrackedFile {
+	var item trackedFileJSON
+	if len(value) > 0 && value[0] == '{' && json.Unmarshal(value, &item) == nil {
+		return TrackedFile{
+			value:       item.Hash,
+			MediaItemID: item.MediaItemID,
+			UploadedAt:  item.UploadedAt,
+			Albums:      item.Albums,
+			Favorite:    item.Favorite,
+		}
+	}
+	return NewTrackedFile(string(value))
+}
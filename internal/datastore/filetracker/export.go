@@ -0,0 +1,126 @@
+package filetracker
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Entry is a tracked file in the export format. Entries are exported as newline-delimited JSON,
+// one object per line, e.g.:
+//
+//	{"path":"/photos/2020/IMG_0001.jpg","hash":"3842009590","mediaItemId":"AGj1epU...","uploadedAt":"2020-05-17T10:30:00Z"}
+//
+// The hash is the one set by the FileTracker Hasher, xxHash32 by default. The media item and the
+// upload time are empty for files tracked by previous versions, and the albums for files uploaded without album too.
+// Favorite is only set for files found in the favorites folder.
+type Entry struct {
+	Path        string     `json:"path"`
+	Hash        string     `json:"hash"`
+	MediaItemID string     `json:"mediaItemId,omitempty"`
+	UploadedAt  *time.Time `json:"uploadedAt,omitempty"`
+	Albums      []string   `json:"albums,omitempty"`
+	Favorite    bool       `json:"favorite,omitempty"`
+}
+
+// ImportStats are the number of entries added, updated and kept when importing.
+type ImportStats struct {
+	Added   int
+	Updated int
+	// Kept are the entries not imported, because the tracked one is as recent or newer.
+	Kept int
+}
+
+// Export writes all the tracked files to w, as newline-delimited JSON entries.
+// It returns the number of exported entries.
+func (ft FileTracker) Export(w io.Writer) (int, error) {
+	var n int
+	enc := json.NewEncoder(w)
+	err := ft.Iterate(func(file string, item TrackedFile) error {
+		e := Entry{
+			Path:        file,
+			Hash:        item.Hash(),
+			MediaItemID: item.MediaItemID,
+			Albums:      item.Albums,
+			Favorite:    item.Favorite,
+		}
+		if !item.UploadedAt.IsZero() {
+			uploadedAt := item.UploadedAt
+			e.UploadedAt = &uploadedAt
+		}
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+		n++
+		return nil
+	})
+	return n, err
+}
+
+// Import merges the newline-delimited JSON entries read from r into the tracked files.
+// If a file is already tracked, the most recently uploaded entry wins. Entries without upload
+// time are older than any other.
+// If ContentHasher is set, the content of imported files existing locally, unchanged, is tracked too.
+func (ft FileTracker) Import(r io.Reader) (ImportStats, error) {
+	var stats ImportStats
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; s.Scan(); line++ {
+		if len(s.Bytes()) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			return stats, fmt.Errorf("invalid entry at line %d: %s", line, err)
+		}
+		if e.Path == "" || e.Hash == "" {
+			return stats, fmt.Errorf("invalid entry at line %d: path and hash could not be empty", line)
+		}
+
+		item := NewTrackedFile(e.Hash)
+		item.MediaItemID = e.MediaItemID
+		item.Albums = e.Albums
+		item.Favorite = e.Favorite
+		if e.UploadedAt != nil {
+			item.UploadedAt = e.UploadedAt.UTC()
+		}
+
+		current, err := ft.repo.Get(e.Path)
+		exist := err == nil
+		if exist && !item.UploadedAt.After(current.UploadedAt) {
+			stats.Kept++
+			continue
+		}
+		if err := ft.repo.Put(e.Path, item); err != nil {
+			return stats, err
+		}
+		if exist {
+			stats.Updated++
+		} else {
+			stats.Added++
+		}
+
+		if err := ft.importContent(e); err != nil {
+			return stats, err
+		}
+	}
+	return stats, s.Err()
+}
+
+// importContent tracks the content of the imported file, if ContentHasher is set and the
+// file exists locally, unchanged.
+func (ft FileTracker) importContent(e Entry) error {
+	if ft.ContentHasher == nil {
+		return nil
+	}
+	if hash, err := ft.Hasher.Hash(e.Path); err != nil || hash != e.Hash {
+		return nil
+	}
+	contentHash, err := ft.ContentHasher.Hash(e.Path)
+	if err != nil {
+		return nil
+	}
+	return ft.repo.Put(contentKeyPrefix+contentHash, NewTrackedFile(contentHash))
+}
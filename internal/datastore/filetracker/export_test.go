@@ -0,0 +1,125 @@
+package filetracker_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/datastore/filetracker"
+)
+
+func TestFileTracker_ExportImport(t *testing.T) {
+	uploadedAt := time.Date(2020, 5, 17, 10, 30, 0, 0, time.UTC)
+	src := newMemoryRepository()
+	withMediaItem := filetracker.NewTrackedFile("hash-a")
+	withMediaItem.MediaItemID = "media-item-a"
+	withMediaItem.UploadedAt = uploadedAt
+	src.items["/photos/a.jpg"] = withMediaItem
+	src.items["/photos/b.jpg"] = filetracker.NewTrackedFile("1589711400|hash-b")
+	src.items["sha256:content-hash"] = filetracker.NewTrackedFile("content-hash")
+
+	var buf bytes.Buffer
+	n, err := filetracker.New(src).Export(&buf)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if n != 2 {
+		t.Errorf("want: %d, got: %d", 2, n)
+	}
+
+	wantLines := []string{
+		`{"path":"/photos/a.jpg","hash":"hash-a","mediaItemId":"media-item-a","uploadedAt":"2020-05-17T10:30:00Z"}`,
+		`{"path":"/photos/b.jpg","hash":"hash-b"}`,
+	}
+	if got := strings.TrimSpace(buf.String()); got != strings.Join(wantLines, "\n") {
+		t.Errorf("want: %s, got: %s", strings.Join(wantLines, "\n"), got)
+	}
+
+	dst := newMemoryRepository()
+	stats, err := filetracker.New(dst).Import(&buf)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if stats != (filetracker.ImportStats{Added: 2}) {
+		t.Errorf("want: %+v, got: %+v", filetracker.ImportStats{Added: 2}, stats)
+	}
+
+	for _, file := range []string{"/photos/a.jpg", "/photos/b.jpg"} {
+		want, got := src.items[file], dst.items[file]
+		if want.Hash() != got.Hash() || want.MediaItemID != got.MediaItemID || !want.UploadedAt.Equal(got.UploadedAt) {
+			t.Errorf("want: %+v, got: %+v, file: %s", want, got, file)
+		}
+	}
+}
+
+func TestFileTracker_ImportMerge(t *testing.T) {
+	older := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tracked := func(hash string, uploadedAt time.Time) filetracker.TrackedFile {
+		item := filetracker.NewTrackedFile(hash)
+		item.UploadedAt = uploadedAt
+		return item
+	}
+	repo := newMemoryRepository()
+	repo.items["newer-tracked.jpg"] = tracked("tracked", newer)
+	repo.items["older-tracked.jpg"] = tracked("tracked", older)
+	repo.items["legacy-tracked.jpg"] = filetracker.NewTrackedFile("tracked")
+	repo.items["same-time.jpg"] = tracked("tracked", older)
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range []filetracker.Entry{
+		{Path: "newer-tracked.jpg", Hash: "imported", UploadedAt: &older},
+		{Path: "older-tracked.jpg", Hash: "imported", UploadedAt: &newer},
+		{Path: "legacy-tracked.jpg", Hash: "imported", UploadedAt: &older},
+		{Path: "same-time.jpg", Hash: "imported", UploadedAt: &older},
+		{Path: "not-tracked.jpg", Hash: "imported"},
+	} {
+		if err := enc.Encode(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := filetracker.New(repo).Import(&buf)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	wantStats := filetracker.ImportStats{Added: 1, Updated: 2, Kept: 2}
+	if stats != wantStats {
+		t.Errorf("want: %+v, got: %+v", wantStats, stats)
+	}
+
+	want := map[string]string{
+		"newer-tracked.jpg":  "tracked",
+		"older-tracked.jpg":  "imported",
+		"legacy-tracked.jpg": "imported",
+		"same-time.jpg":      "tracked",
+		"not-tracked.jpg":    "imported",
+	}
+	for file, hash := range want {
+		if got := repo.items[file].Hash(); got != hash {
+			t.Errorf("want: %s, got: %s, file: %s", hash, got, file)
+		}
+	}
+}
+
+func TestFileTracker_ImportInvalidEntries(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input string
+	}{
+		{"Should fail if it's not JSON", "not-json\n"},
+		{"Should fail if path is empty", `{"hash":"hash"}` + "\n"},
+		{"Should fail if hash is empty", `{"path":"a.jpg"}` + "\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := filetracker.New(newMemoryRepository()).Import(strings.NewReader(tc.input))
+			assertExpectedError(t, true, err)
+		})
+	}
+}
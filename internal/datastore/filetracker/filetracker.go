@@ -1,21 +1,53 @@
 package filetracker
 
 import (
+	"errors"
 	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/pathnorm"
 )
 
 var (
 	// ErrItemNotFound is the expected error if the item is not found.
 	ErrItemNotFound = fmt.Errorf("item was not found")
+
+	// ErrCorrupted is returned when the tracking store could not be opened because it's corrupted, e.g. after an
+	// unclean shutdown. See MoveAside.
+	ErrCorrupted = fmt.Errorf("tracking store is corrupted")
+)
+
+const (
+	// contentKeyPrefix is the prefix of the keys tracking the content hashes of uploaded files.
+	contentKeyPrefix = "sha256:"
 )
 
 // FileTracker allows to track already uploaded files in a repository.
+// It's safe for concurrent use, as long as the Repository is.
 type FileTracker struct {
 	repo Repository
 
 	// Hasher allows to change the way that hashes are calculated. Uses xxHash32Hasher{} by default.
 	// Useful for testing.
 	Hasher Hasher
+
+	// ContentHasher, if it's set, is used to track the content of uploaded files, so a file
+	// with an already uploaded content is considered uploaded, even if it has been moved.
+	// It's the hasher given to NewWithContentDedup.
+	ContentHasher Hasher
+
+	// UnicodeForm is the Unicode form of the paths of the files once they are tracked, so a file is tracked by the
+	// same key whatever the system it was created on. Uses pathnorm.NFC by default. Files tracked in another form,
+	// e.g. before it was normalized, are still found by their path.
+	UnicodeForm pathnorm.Form
+
+	// PerceptualHasher, if it's set, is used to track the perceptual hashes of uploaded images, so the images
+	// visually similar to them are found by NearDuplicate, given NearDuplicateThreshold.
+	PerceptualHasher       PerceptualHasher
+	NearDuplicateThreshold int
+	// perceptual are the perceptual hashes of the uploaded images, shared by the copies of the FileTracker.
+	perceptual *perceptualIndex
 }
 
 // Hasher is a Hasher to get the value of the file.
@@ -29,32 +61,116 @@ type Repository interface {
 	Get(key string) (TrackedFile, error)
 	Put(key string, item TrackedFile) error
 	Delete(key string) error
+	// Iterate calls fn for every item in the repo. It stops at the first error returned by fn.
+	Iterate(fn func(key string, item TrackedFile) error) error
 	Close() error
 }
 
 // New returns a FileTracker using specified repo.
 func New(r Repository) *FileTracker {
 	return &FileTracker{
-		repo:   r,
-		Hasher: xxHash32Hasher{},
+		repo:       r,
+		Hasher:     xxHash32Hasher{},
+		perceptual: &perceptualIndex{},
 	}
 }
 
+// NewWithContentDedup returns a FileTracker using specified repo, that also tracks
+// the content of the uploaded files, hashed by contentHasher, e.g. their SHA-256. It allows
+// to skip moved files, at the cost of hashing every file that is not tracked by its path.
+func NewWithContentDedup(r Repository, contentHasher Hasher) *FileTracker {
+	ft := New(r)
+	ft.ContentHasher = contentHasher
+	return ft
+}
+
 // Put marks a file as already uploaded to prevent re-uploads.
-func (ft FileTracker) Put(file string) error {
+// The mediaItemID is the Google Photos media item created by the upload, if it's known.
+func (ft FileTracker) Put(file string, mediaItemID string) error {
 	hash, err := ft.Hasher.Hash(file)
 	if err != nil {
 		return err
 	}
 	item := NewTrackedFile(hash)
-	return ft.repo.Put(file, item)
+	item.MediaItemID = mediaItemID
+	item.UploadedAt = time.Now().UTC()
+	if err := ft.repo.Put(ft.key(file), item); err != nil {
+		return err
+	}
+	if err := ft.putPerceptualHash(file); err != nil {
+		return err
+	}
+
+	if ft.ContentHasher == nil {
+		return nil
+	}
+	contentHash, err := ft.ContentHasher.Hash(file)
+	if err != nil {
+		return err
+	}
+	return ft.repo.Put(contentKeyPrefix+contentHash, NewTrackedFile(contentHash))
 }
 
 // Exist checks if the file was already uploaded.
-// Exist compares the value of the file against the repository.
+// Exist compares the value of the file against the repository. If ContentHasher is set,
+// a file with the same content of an uploaded one is considered uploaded too.
 func (ft FileTracker) Exist(file string) bool {
-	// Get returns ErrItemNotFound if the repo does not contains the key.
-	item, err := ft.repo.Get(file)
+	if ft.existByPath(file) {
+		return true
+	}
+	return ft.ContentHasher != nil && ft.existByContent(file)
+}
+
+// TrackedAlbums returns the media item of the file, and the names of the albums where it has been added.
+// It returns false if the file is not tracked, it has changed since it was uploaded, or its media item
+// or its albums are unknown, since it was tracked by previous versions.
+func (ft FileTracker) TrackedAlbums(file string) (string, []string, bool) {
+	_, item, err := ft.get(file)
+	if err != nil || item.MediaItemID == "" || len(item.Albums) == 0 {
+		return "", nil, false
+	}
+	hash, err := ft.Hasher.Hash(file)
+	if err != nil || item.Hash() != hash {
+		return "", nil, false
+	}
+	return item.MediaItemID, item.Albums, true
+}
+
+// AddAlbum records that the media item of the tracked file has been added to the album.
+// It returns ErrItemNotFound if the file is not tracked.
+func (ft FileTracker) AddAlbum(file string, album string) error {
+	key, item, err := ft.get(file)
+	if err != nil {
+		return err
+	}
+	for _, a := range item.Albums {
+		if a == album {
+			return nil
+		}
+	}
+	item.Albums = append(item.Albums, album)
+	return ft.repo.Put(key, item)
+}
+
+// MarkFavorite records that the media item of the tracked file should be marked as favorite. The Google Photos
+// API doesn't allow to do it, so they are recorded to be marked by other means.
+// It returns ErrItemNotFound if the file is not tracked.
+func (ft FileTracker) MarkFavorite(file string) error {
+	key, item, err := ft.get(file)
+	if err != nil {
+		return err
+	}
+	if item.Favorite {
+		return nil
+	}
+	item.Favorite = true
+	return ft.repo.Put(key, item)
+}
+
+// existByPath checks if the file was already uploaded from the same path.
+func (ft FileTracker) existByPath(file string) bool {
+	// get returns ErrItemNotFound if the repo does not contains the key.
+	_, item, err := ft.get(file)
 	if err != nil {
 		return false
 	}
@@ -72,11 +188,101 @@ func (ft FileTracker) Exist(file string) bool {
 	return false
 }
 
+// existByContent checks if a file with the same content was already uploaded.
+func (ft FileTracker) existByContent(file string) bool {
+	contentHash, err := ft.ContentHasher.Hash(file)
+	if err != nil {
+		return false
+	}
+	_, err = ft.repo.Get(contentKeyPrefix + contentHash)
+	return err == nil
+}
+
 // Delete un-marks a file as already uploaded.
+// If ContentHasher is set and the file is still readable, its content is un-marked too. If PerceptualHasher is
+// set, its perceptual hash is un-marked too.
 func (ft FileTracker) Delete(file string) error {
+	if err := ft.deletePerceptualHash(file); err != nil {
+		return err
+	}
+	if ft.ContentHasher != nil {
+		if contentHash, err := ft.ContentHasher.Hash(file); err == nil {
+			if err := ft.repo.Delete(contentKeyPrefix + contentHash); err != nil {
+				return err
+			}
+		}
+	}
+	if key := ft.key(file); key != file {
+		if err := ft.repo.Delete(key); err != nil {
+			return err
+		}
+	}
 	return ft.repo.Delete(file)
 }
 
+// key returns the key tracking the file, its path in UnicodeForm.
+func (ft FileTracker) key(file string) string {
+	return ft.UnicodeForm.Path(file)
+}
+
+// get returns the tracked file and its key. Files are found by their key, or by their path if they were tracked
+// in another Unicode form. It returns ErrItemNotFound if the file is not tracked.
+func (ft FileTracker) get(file string) (string, TrackedFile, error) {
+	key := ft.key(file)
+	item, err := ft.repo.Get(key)
+	if errors.Is(err, ErrItemNotFound) && key != file {
+		key = file
+		item, err = ft.repo.Get(file)
+	}
+	return key, item, err
+}
+
+// Reset un-marks the tracked files whose path starts with prefix, returning them.
+// An empty prefix un-marks all the files, and all the tracked content and perceptual hashes.
+// If dryRun is set, files are only returned, the tracking is not changed.
+func (ft FileTracker) Reset(prefix string, dryRun bool) ([]string, error) {
+	var files, contents []string
+	err := ft.repo.Iterate(func(key string, item TrackedFile) error {
+		switch {
+		case strings.HasPrefix(key, contentKeyPrefix), strings.HasPrefix(key, perceptualKeyPrefix):
+			contents = append(contents, key)
+		case strings.HasPrefix(key, prefix), strings.HasPrefix(key, ft.key(prefix)):
+			files = append(files, key)
+		}
+		return nil
+	})
+	if err != nil || dryRun {
+		return files, err
+	}
+
+	for _, file := range files {
+		if err := ft.Delete(file); err != nil {
+			return nil, err
+		}
+	}
+	if prefix != "" {
+		return files, nil
+	}
+	for _, key := range contents {
+		if err := ft.repo.Delete(key); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// Iterate calls fn for every tracked file. It stops at the first error returned by fn.
+// Content hashes and perceptual hashes, tracked when ContentHasher and PerceptualHasher are set, are not files so
+// they are skipped.
+func (ft FileTracker) Iterate(fn func(file string, item TrackedFile) error) error {
+	return ft.repo.Iterate(func(key string, item TrackedFile) error {
+		if strings.HasPrefix(key, contentKeyPrefix) || strings.HasPrefix(key, perceptualKeyPrefix) {
+			return nil
+		}
+		return fn(key, item)
+	})
+}
+
 // Close closes the file tracker repository.
 // No operation could be done after that.
 func (ft FileTracker) Close() error {
@@ -2,9 +2,16 @@ package filetracker_test
 
 import (
 	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
 	"testing"
 
 	"github.com/gphotosuploader/gphotos-uploader-cli/internal/datastore/filetracker"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/pathnorm"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/upload"
 )
 
 const (
@@ -34,7 +41,7 @@ func TestFileTracker_Put(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			err := ft.Put(tc.input)
+			err := ft.Put(tc.input, "")
 			assertExpectedError(t, tc.isErrExpected, err)
 		})
 	}
@@ -66,6 +73,178 @@ func TestFileTracker_Exist(t *testing.T) {
 	}
 }
 
+func TestFileTracker_ExistMovedFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filetracker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	content, err := ioutil.ReadFile(ShouldSuccess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	movedFile := filepath.Join(dir, "moved", "image.jpg")
+	if err := os.MkdirAll(filepath.Dir(movedFile), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(movedFile, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name string
+		ft   *filetracker.FileTracker
+		want bool
+	}{
+		{"Should skip moved file when deduplicating by content", filetracker.NewWithContentDedup(newMemoryRepository(), upload.NewContentHashes()), true},
+		{"Should not skip moved file when deduplicating by path", filetracker.New(newMemoryRepository()), false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.ft.Put(ShouldSuccess, ""); err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			if !tc.ft.Exist(ShouldSuccess) {
+				t.Errorf("want: %t, got: %t", true, false)
+			}
+			if got := tc.ft.Exist(movedFile); got != tc.want {
+				t.Errorf("want: %t, got: %t", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestFileTracker_PutMediaItem(t *testing.T) {
+	repo := newMemoryRepository()
+	ft := filetracker.NewWithContentDedup(repo, upload.NewContentHashes())
+
+	if err := ft.Put(ShouldSuccess, "media-item-id"); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	item, err := repo.Get(ShouldSuccess)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if item.MediaItemID != "media-item-id" {
+		t.Errorf("want: %s, got: %s", "media-item-id", item.MediaItemID)
+	}
+	if item.UploadedAt.IsZero() {
+		t.Errorf("want: upload time, got: %s", item.UploadedAt)
+	}
+
+	var files []string
+	err = ft.Iterate(func(file string, item filetracker.TrackedFile) error {
+		files = append(files, file)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if len(files) != 1 || files[0] != ShouldSuccess {
+		t.Errorf("want: %v, got: %v", []string{ShouldSuccess}, files)
+	}
+}
+
+func TestFileTracker_AddAlbum(t *testing.T) {
+	repo := newMemoryRepository()
+	ft := filetracker.New(repo)
+	ft.Hasher = &mockedHasher{"test-file-hash"}
+
+	if _, _, ok := ft.TrackedAlbums(ShouldSuccess); ok {
+		t.Errorf("albums of an untracked file were not expected")
+	}
+	if err := ft.AddAlbum(ShouldSuccess, "Trips"); !errors.Is(err, filetracker.ErrItemNotFound) {
+		t.Errorf("want: %s, got: %v", filetracker.ErrItemNotFound, err)
+	}
+
+	if err := ft.Put(ShouldSuccess, "media-item-id"); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if _, _, ok := ft.TrackedAlbums(ShouldSuccess); ok {
+		t.Errorf("albums of a file uploaded without album were not expected")
+	}
+	for _, album := range []string{"Trips", "2020", "Trips"} {
+		if err := ft.AddAlbum(ShouldSuccess, album); err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+	}
+
+	mediaItemID, albums, ok := ft.TrackedAlbums(ShouldSuccess)
+	if want := []string{"Trips", "2020"}; !ok || mediaItemID != "media-item-id" || !reflect.DeepEqual(want, albums) {
+		t.Errorf("want: media-item-id %v, got: %s %v %t", want, mediaItemID, albums, ok)
+	}
+}
+
+func TestFileTracker_MarkFavorite(t *testing.T) {
+	repo := newMemoryRepository()
+	ft := filetracker.New(repo)
+	ft.Hasher = &mockedHasher{"test-file-hash"}
+
+	if err := ft.MarkFavorite(ShouldSuccess); !errors.Is(err, filetracker.ErrItemNotFound) {
+		t.Errorf("want: %s, got: %v", filetracker.ErrItemNotFound, err)
+	}
+
+	if err := ft.Put(ShouldSuccess, "media-item-id"); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if err := ft.MarkFavorite(ShouldSuccess); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	var favorites []string
+	err := ft.Iterate(func(file string, item filetracker.TrackedFile) error {
+		if item.Favorite {
+			favorites = append(favorites, file)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if want := []string{ShouldSuccess}; !reflect.DeepEqual(want, favorites) {
+		t.Errorf("want: %v, got: %v", want, favorites)
+	}
+}
+
+func TestFileTracker_Reset(t *testing.T) {
+	testCases := []struct {
+		name      string
+		prefix    string
+		dryRun    bool
+		want      []string
+		remaining int
+	}{
+		{"Should reset files with the prefix", "/photos/trip/", false, []string{"/photos/trip/a.jpg", "/photos/trip/b.jpg"}, 3},
+		{"Should match the prefix literally", "/photos/trip", false, []string{"/photos/trip/a.jpg", "/photos/trip/b.jpg", "/photos/trip2/c.jpg"}, 2},
+		{"Should not reset files in dry run mode", "/photos/trip/", true, []string{"/photos/trip/a.jpg", "/photos/trip/b.jpg"}, 5},
+		{"Should reset nothing if no file has the prefix", "/videos/", false, nil, 5},
+		{"Should reset all files, and contents, without prefix", "", false, []string{"/photos/other.jpg", "/photos/trip/a.jpg", "/photos/trip/b.jpg", "/photos/trip2/c.jpg"}, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := newMemoryRepository()
+			for _, key := range []string{"/photos/trip/a.jpg", "/photos/trip/b.jpg", "/photos/trip2/c.jpg", "/photos/other.jpg", "sha256:content"} {
+				repo.items[key] = filetracker.NewTrackedFile("hash")
+			}
+
+			got, err := filetracker.New(repo).Reset(tc.prefix, tc.dryRun)
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			if !reflect.DeepEqual(tc.want, got) {
+				t.Errorf("want: %v, got: %v", tc.want, got)
+			}
+			if len(repo.items) != tc.remaining {
+				t.Errorf("want: %d, got: %d", tc.remaining, len(repo.items))
+			}
+		})
+	}
+}
+
 func TestFileTracker_Delete(t *testing.T) {
 	testCases := []struct {
 		name          string
@@ -86,6 +265,74 @@ func TestFileTracker_Delete(t *testing.T) {
 	}
 }
 
+func TestFileTracker_UnicodeForm(t *testing.T) {
+	// nfc and nfd are the same file name, "Café/Crème.jpg", composed and decomposed.
+	const nfc, nfd = "Caf\u00e9/Cr\u00e8me.jpg", "Cafe\u0301/Cre\u0300me.jpg"
+	testCases := []struct {
+		name     string
+		form     pathnorm.Form
+		put      string
+		exist    string
+		wantKey  string
+		wantSame bool
+	}{
+		{"Should track NFD file by its NFC key", "", nfd, nfc, nfc, true},
+		{"Should track NFC file by its NFC key", pathnorm.NFC, nfc, nfd, nfc, true},
+		{"Should track NFC file by its NFD key", pathnorm.NFD, nfc, nfd, nfd, true},
+		{"Should track files as they are when it's off", pathnorm.Off, nfd, nfc, nfd, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := newMemoryRepository()
+			ft := filetracker.New(repo)
+			ft.Hasher = &mockedHasher{"test-file-hash"}
+			ft.UnicodeForm = tc.form
+
+			if err := ft.Put(tc.put, "media-item-1"); err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			if _, ok := repo.items[tc.wantKey]; !ok || len(repo.items) != 1 {
+				t.Errorf("want: key %q, got: %v", tc.wantKey, repo.items)
+			}
+			if got := ft.Exist(tc.exist); tc.wantSame != got {
+				t.Errorf("want: %t, got: %t", tc.wantSame, got)
+			}
+		})
+	}
+}
+
+func TestFileTracker_UnicodeFormTrackedInAnotherForm(t *testing.T) {
+	const nfc, nfd = "Caf\u00e9/Cr\u00e8me.jpg", "Cafe\u0301/Cre\u0300me.jpg"
+	// the file was tracked in NFD before the paths were normalized.
+	repo := newMemoryRepository()
+	item := filetracker.NewTrackedFile("test-file-hash")
+	item.MediaItemID = "media-item-1"
+	repo.items[nfd] = item
+	ft := filetracker.New(repo)
+	ft.Hasher = &mockedHasher{"test-file-hash"}
+
+	if !ft.Exist(nfd) {
+		t.Errorf("want: %t, got: %t", true, false)
+	}
+	if err := ft.AddAlbum(nfd, "album"); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if want, got := []string{"album"}, repo.items[nfd].Albums; !reflect.DeepEqual(want, got) {
+		t.Errorf("want: %v, got: %v", want, got)
+	}
+	if _, ok := repo.items[nfc]; ok {
+		t.Errorf("want: no key %q, got: %v", nfc, repo.items)
+	}
+
+	if err := ft.Delete(nfd); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if len(repo.items) != 0 {
+		t.Errorf("want: 0, got: %d", len(repo.items))
+	}
+}
+
 func TestFileTracker_Close(t *testing.T) {
 	testCases := []struct {
 		name          string
@@ -132,10 +379,59 @@ func (m mockedRepository) Delete(key string) error {
 	return nil
 }
 
+func (m mockedRepository) Iterate(fn func(key string, item filetracker.TrackedFile) error) error {
+	return nil
+}
+
 func (m mockedRepository) Close() error {
 	return nil
 }
 
+// memoryRepository implements a Repository in memory.
+type memoryRepository struct {
+	items map[string]filetracker.TrackedFile
+}
+
+func newMemoryRepository() *memoryRepository {
+	return &memoryRepository{items: make(map[string]filetracker.TrackedFile)}
+}
+
+func (m *memoryRepository) Get(key string) (filetracker.TrackedFile, error) {
+	item, ok := m.items[key]
+	if !ok {
+		return filetracker.TrackedFile{}, filetracker.ErrItemNotFound
+	}
+	return item, nil
+}
+
+func (m *memoryRepository) Put(key string, item filetracker.TrackedFile) error {
+	m.items[key] = item
+	return nil
+}
+
+func (m *memoryRepository) Delete(key string) error {
+	delete(m.items, key)
+	return nil
+}
+
+func (m *memoryRepository) Iterate(fn func(key string, item filetracker.TrackedFile) error) error {
+	keys := make([]string, 0, len(m.items))
+	for k := range m.items {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := fn(k, m.items[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *memoryRepository) Close() error {
+	return nil
+}
+
 type mockedHasher struct {
 	hash string
 }
@@ -1,8 +1,13 @@
 package filetracker
 
 import (
+	"fmt"
+
 	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/errors"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
 	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
 )
 
 // DB represents a LevelDB database.
@@ -10,17 +15,24 @@ type DB interface {
 	Get(key []byte, ro *opt.ReadOptions) ([]byte, error)
 	Put(key []byte, item []byte, wo *opt.WriteOptions) error
 	Delete(key []byte, wo *opt.WriteOptions) error
+	NewIterator(slice *util.Range, ro *opt.ReadOptions) iterator.Iterator
+	Write(batch *leveldb.Batch, wo *opt.WriteOptions) error
 	Close() error
 }
 
 // LevelDBRepository implements a Repository using LevelDB.
+// It's safe for concurrent use, because LevelDB is.
 type LevelDBRepository struct {
 	DB DB
 }
 
 // NewLevelDBRepository creates a repository using LevelDB package.
+// It returns ErrCorrupted if the database is corrupted.
 func NewLevelDBRepository(filename string) (*LevelDBRepository, error) {
 	ft, err := leveldb.OpenFile(filename, nil)
+	if errors.IsCorrupted(err) {
+		err = fmt.Errorf("%w: %s", ErrCorrupted, err)
+	}
 	return &LevelDBRepository{
 		DB: ft,
 	}, err
@@ -33,12 +45,12 @@ func (r LevelDBRepository) Get(key string) (TrackedFile, error) {
 	if err != nil {
 		return TrackedFile{}, ErrItemNotFound
 	}
-	return NewTrackedFile(string(val)), nil
+	return unmarshalTrackedFile(val), nil
 }
 
 // Put stores the item under key.
 func (r LevelDBRepository) Put(key string, item TrackedFile) error {
-	return r.DB.Put([]byte(key), []byte(item.value), nil)
+	return r.DB.Put([]byte(key), item.marshal(), nil)
 }
 
 // Delete removes the item specified by key.
@@ -46,6 +58,31 @@ func (r LevelDBRepository) Delete(key string) error {
 	return r.DB.Delete([]byte(key), nil)
 }
 
+// WriteBatch writes all the operations atomically.
+func (r LevelDBRepository) WriteBatch(ops []Op) error {
+	batch := new(leveldb.Batch)
+	for _, op := range ops {
+		if op.Delete {
+			batch.Delete([]byte(op.Key))
+		} else {
+			batch.Put([]byte(op.Key), op.Item.marshal())
+		}
+	}
+	return r.DB.Write(batch, nil)
+}
+
+// Iterate calls fn for every item, sorted by key. It stops at the first error returned by fn.
+func (r LevelDBRepository) Iterate(fn func(key string, item TrackedFile) error) error {
+	iter := r.DB.NewIterator(nil, nil)
+	defer iter.Release()
+	for iter.Next() {
+		if err := fn(string(iter.Key()), unmarshalTrackedFile(iter.Value())); err != nil {
+			return err
+		}
+	}
+	return iter.Error()
+}
+
 // Close closes the DB.
 func (r LevelDBRepository) Close() error {
 	return r.DB.Close()
@@ -1,9 +1,16 @@
 package filetracker_test
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
 	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
 
 	"github.com/gphotosuploader/gphotos-uploader-cli/internal/datastore/filetracker"
 )
@@ -57,6 +64,61 @@ func TestLevelDBRepository_Close(t *testing.T) {
 	}
 }
 
+func TestLevelDBRepository_Iterate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filetracker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	repo, err := filetracker.NewLevelDBRepository(filepath.Join(dir, "uploads.db"))
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	defer repo.Close()
+
+	uploadedAt := time.Date(2020, 5, 17, 10, 30, 0, 0, time.UTC)
+	withMediaItem := filetracker.NewTrackedFile("hash-a")
+	withMediaItem.MediaItemID = "media-item-a"
+	withMediaItem.UploadedAt = uploadedAt
+
+	items := map[string]filetracker.TrackedFile{
+		"a.jpg": withMediaItem,
+		"b.jpg": filetracker.NewTrackedFile("hash-b"),
+		"c.jpg": filetracker.NewTrackedFile("1589711400|hash-c"),
+	}
+	for k, v := range items {
+		if err := repo.Put(k, v); err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+	}
+
+	want := map[string]struct {
+		hash        string
+		mediaItemID string
+		uploadedAt  time.Time
+	}{
+		"a.jpg": {"hash-a", "media-item-a", uploadedAt},
+		"b.jpg": {"hash-b", "", time.Time{}},
+		"c.jpg": {"hash-c", "", time.Time{}},
+	}
+	var got int
+	err = repo.Iterate(func(key string, item filetracker.TrackedFile) error {
+		got++
+		w := want[key]
+		if item.Hash() != w.hash || item.MediaItemID != w.mediaItemID || !item.UploadedAt.Equal(w.uploadedAt) {
+			t.Errorf("want: %v, got: %s %s %s, key: %s", w, item.Hash(), item.MediaItemID, item.UploadedAt, key)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if got != len(want) {
+		t.Errorf("want: %d, got: %d", len(want), got)
+	}
+}
+
 type mockedDB struct{}
 
 func (m mockedDB) Get(key []byte, ro *opt.ReadOptions) ([]byte, error) {
@@ -75,6 +137,14 @@ func (m mockedDB) Delete(key []byte, wo *opt.WriteOptions) error {
 	return nil
 }
 
+func (m mockedDB) NewIterator(slice *util.Range, ro *opt.ReadOptions) iterator.Iterator {
+	return iterator.NewEmptyIterator(nil)
+}
+
+func (m mockedDB) Write(batch *leveldb.Batch, wo *opt.WriteOptions) error {
+	return nil
+}
+
 func (m mockedDB) Close() error {
 	return nil
 }
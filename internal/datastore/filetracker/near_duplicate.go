@@ -0,0 +1,124 @@
+package filetracker
+
+import (
+	"math/bits"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// perceptualKeyPrefix is the prefix of the keys tracking the perceptual hashes of uploaded images, by their path.
+const perceptualKeyPrefix = "phash:"
+
+// PerceptualHasher is a way to get the perceptual hash of an image, so visually similar images have hashes at a
+// short Hamming distance, even if their content is different. It fails if the file is not a supported image.
+type PerceptualHasher interface {
+	PerceptualHash(file string) (uint64, error)
+}
+
+// perceptualIndex keeps the perceptual hashes of the uploaded images in memory, so they are read from the
+// repository only once, the first time a near duplicate is looked up. It's safe for concurrent use.
+type perceptualIndex struct {
+	mu     sync.Mutex
+	loaded bool
+	// hashes are the perceptual hashes of the uploaded images, by their path.
+	hashes map[string]uint64
+}
+
+// NearDuplicate returns the uploaded image visually similar to the file, and true if there's one: the Hamming
+// distance between their perceptual hashes is up to NearDuplicateThreshold. The closest one is returned.
+// It returns false if PerceptualHasher is not set, or the file is not an image that could be hashed.
+func (ft FileTracker) NearDuplicate(file string) (string, bool) {
+	if ft.PerceptualHasher == nil || ft.perceptual == nil {
+		return "", false
+	}
+	hash, err := ft.PerceptualHasher.PerceptualHash(file)
+	if err != nil {
+		return "", false
+	}
+
+	ft.perceptual.mu.Lock()
+	defer ft.perceptual.mu.Unlock()
+	if err := ft.loadPerceptualHashes(); err != nil {
+		return "", false
+	}
+	original, closest := "", ft.NearDuplicateThreshold+1
+	for path, h := range ft.perceptual.hashes {
+		if d := bits.OnesCount64(hash ^ h); path != ft.key(file) && path != file && d < closest {
+			original, closest = path, d
+		}
+	}
+	if original == "" {
+		return "", false
+	}
+	// images removed from the tracking while perceptual hashes were not tracked could still have one.
+	if _, _, err := ft.get(original); err != nil {
+		return "", false
+	}
+	return original, true
+}
+
+// loadPerceptualHashes reads the perceptual hashes from the repository, if they have not been read yet.
+// It must be called holding the lock of the index.
+func (ft FileTracker) loadPerceptualHashes() error {
+	if ft.perceptual.loaded {
+		return nil
+	}
+	hashes := make(map[string]uint64)
+	err := ft.repo.Iterate(func(key string, item TrackedFile) error {
+		if !strings.HasPrefix(key, perceptualKeyPrefix) {
+			return nil
+		}
+		if h, err := strconv.ParseUint(item.Hash(), 16, 64); err == nil {
+			hashes[strings.TrimPrefix(key, perceptualKeyPrefix)] = h
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	ft.perceptual.hashes, ft.perceptual.loaded = hashes, true
+	return nil
+}
+
+// putPerceptualHash tracks the perceptual hash of the uploaded file, if PerceptualHasher is set. Files that are
+// not images that could be hashed are not tracked by it.
+func (ft FileTracker) putPerceptualHash(file string) error {
+	if ft.PerceptualHasher == nil {
+		return nil
+	}
+	hash, err := ft.PerceptualHasher.PerceptualHash(file)
+	if err != nil {
+		return nil
+	}
+	if err := ft.repo.Put(perceptualKeyPrefix+ft.key(file), NewTrackedFile(strconv.FormatUint(hash, 16))); err != nil {
+		return err
+	}
+	if ft.perceptual != nil {
+		ft.perceptual.mu.Lock()
+		defer ft.perceptual.mu.Unlock()
+		if ft.perceptual.loaded {
+			ft.perceptual.hashes[ft.key(file)] = hash
+		}
+	}
+	return nil
+}
+
+// deletePerceptualHash un-marks the perceptual hash of the file, if PerceptualHasher is set.
+func (ft FileTracker) deletePerceptualHash(file string) error {
+	if ft.PerceptualHasher == nil {
+		return nil
+	}
+	if ft.perceptual != nil {
+		ft.perceptual.mu.Lock()
+		delete(ft.perceptual.hashes, ft.key(file))
+		delete(ft.perceptual.hashes, file)
+		ft.perceptual.mu.Unlock()
+	}
+	if key := ft.key(file); key != file {
+		if err := ft.repo.Delete(perceptualKeyPrefix + key); err != nil {
+			return err
+		}
+	}
+	return ft.repo.Delete(perceptualKeyPrefix + file)
+}
@@ -0,0 +1,97 @@
+package filetracker_test
+
+import (
+	"testing"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/datastore/filetracker"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/imagehash"
+)
+
+// mockedPerceptualHasher returns the hash of every file, it fails for the files without hash.
+type mockedPerceptualHasher map[string]uint64
+
+func (m mockedPerceptualHasher) PerceptualHash(file string) (uint64, error) {
+	h, ok := m[file]
+	if !ok {
+		return 0, imagehash.ErrUnsupported
+	}
+	return h, nil
+}
+
+func TestFileTracker_NearDuplicate(t *testing.T) {
+	hasher := mockedPerceptualHasher{
+		"/photos/original.jpg": 0xf0f0,
+		"/photos/copy.jpg":     0xf0f7,
+		"/photos/other.jpg":    0x0f0f,
+	}
+	testCases := []struct {
+		name      string
+		file      string
+		threshold int
+		want      string
+	}{
+		{"Should find image within the threshold", "/photos/copy.jpg", 3, "/photos/original.jpg"},
+		{"Should not find image beyond the threshold", "/photos/copy.jpg", 2, ""},
+		{"Should not find different image", "/photos/other.jpg", 10, ""},
+		{"Should not find the image itself", "/photos/original.jpg", 10, ""},
+		{"Should not find file that is not an image", "/photos/video.mp4", 64, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ft := filetracker.New(newMemoryRepository())
+			ft.Hasher = &mockedHasher{"test-file-hash"}
+			ft.PerceptualHasher = hasher
+			ft.NearDuplicateThreshold = tc.threshold
+			if err := ft.Put("/photos/original.jpg", ""); err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+
+			got, ok := ft.NearDuplicate(tc.file)
+			if tc.want != got || ok != (tc.want != "") {
+				t.Errorf("want: %q, got: %q, %t", tc.want, got, ok)
+			}
+		})
+	}
+}
+
+func TestFileTracker_NearDuplicateTracked(t *testing.T) {
+	hasher := mockedPerceptualHasher{"/photos/original.jpg": 0xf0f0, "/photos/copy.jpg": 0xf0f1}
+	repo := newMemoryRepository()
+	ft := filetracker.New(repo)
+	ft.Hasher = &mockedHasher{"test-file-hash"}
+	ft.PerceptualHasher = hasher
+	ft.NearDuplicateThreshold = 5
+	if err := ft.Put("/photos/original.jpg", ""); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	// perceptual hashes are read from the repository, and they are not files.
+	other := filetracker.New(repo)
+	other.PerceptualHasher = hasher
+	other.NearDuplicateThreshold = 5
+	if got, ok := other.NearDuplicate("/photos/copy.jpg"); !ok || got != "/photos/original.jpg" {
+		t.Errorf("want: %q, got: %q", "/photos/original.jpg", got)
+	}
+	var files []string
+	if err := other.Iterate(func(file string, item filetracker.TrackedFile) error {
+		files = append(files, file)
+		return nil
+	}); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if len(files) != 1 {
+		t.Errorf("want: %d, got: %v", 1, files)
+	}
+
+	// deleted files are not near duplicates anymore.
+	if err := other.Delete("/photos/original.jpg"); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if got, ok := other.NearDuplicate("/photos/copy.jpg"); ok {
+		t.Errorf("want: no near duplicate, got: %q", got)
+	}
+	if len(repo.items) != 0 {
+		t.Errorf("want: empty repository, got: %v", repo.items)
+	}
+}
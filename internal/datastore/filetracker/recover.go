@@ -0,0 +1,18 @@
+package filetracker
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// MoveAside renames the tracking store at path, e.g. because it's corrupted, so a new empty one could be created
+// in its place. The store is kept next to it, with the time as suffix, so it could be inspected later.
+// It returns the new path of the store.
+func MoveAside(path string, now time.Time) (string, error) {
+	dst := fmt.Sprintf("%s.corrupted-%s", path, now.Format("20060102-150405"))
+	if err := os.Rename(path, dst); err != nil {
+		return "", err
+	}
+	return dst, nil
+}
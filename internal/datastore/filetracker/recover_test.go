@@ -0,0 +1,59 @@
+package filetracker_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/datastore/filetracker"
+)
+
+// writeCorruptedLevelDB writes a LevelDB database whose CURRENT file, pointing to its manifest, is corrupted.
+func writeCorruptedLevelDB(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(path, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(path, "CURRENT"), []byte("garbage"), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewLevelDBRepository_Corrupted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filetracker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "uploads.db")
+	writeCorruptedLevelDB(t, path)
+
+	_, err = filetracker.NewLevelDBRepository(path)
+	if !errors.Is(err, filetracker.ErrCorrupted) {
+		t.Fatalf("want: %v, got: %v", filetracker.ErrCorrupted, err)
+	}
+
+	moved, err := filetracker.MoveAside(path, time.Date(2023, 7, 15, 14, 22, 33, 0, time.Local))
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if want := path + ".corrupted-20230715-142233"; want != moved {
+		t.Errorf("want: %s, got: %s", want, moved)
+	}
+	if _, err := os.Stat(filepath.Join(moved, "CURRENT")); err != nil {
+		t.Errorf("error was not expected at this point: %s", err)
+	}
+
+	// a new empty database is created in its place.
+	repo, err := filetracker.NewLevelDBRepository(path)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	defer repo.Close()
+	if _, err := repo.Get("/photos/IMG_0001.jpg"); !errors.Is(err, filetracker.ErrItemNotFound) {
+		t.Errorf("want: %v, got: %v", filetracker.ErrItemNotFound, err)
+	}
+}
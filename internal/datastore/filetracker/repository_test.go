@@ -0,0 +1,233 @@
+package filetracker_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/datastore/filetracker"
+)
+
+// newRepositoryFn returns an empty repository, and a function to remove it.
+type newRepositoryFn func(t *testing.T) (filetracker.Repository, func())
+
+func TestLevelDBRepository_Conformance(t *testing.T) {
+	testRepositoryConformance(t, func(t *testing.T) (filetracker.Repository, func()) {
+		dir, err := ioutil.TempDir("", "filetracker")
+		if err != nil {
+			t.Fatal(err)
+		}
+		repo, err := filetracker.NewLevelDBRepository(filepath.Join(dir, "uploads.db"))
+		if err != nil {
+			os.RemoveAll(dir)
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+		return repo, func() {
+			_ = repo.Close()
+			os.RemoveAll(dir)
+		}
+	})
+}
+
+// testRepositoryConformance checks the behaviour that every Repository implementation should have.
+func testRepositoryConformance(t *testing.T, newRepository newRepositoryFn) {
+	uploadedAt := time.Date(2020, 5, 17, 10, 30, 0, 123000000, time.UTC)
+	withMediaItem := filetracker.NewTrackedFile("hash-a")
+	withMediaItem.MediaItemID = "media-item-a"
+	withMediaItem.UploadedAt = uploadedAt
+
+	t.Run("Should return ErrItemNotFound if the key is not tracked", func(t *testing.T) {
+		repo, cleanup := newRepository(t)
+		defer cleanup()
+
+		if _, err := repo.Get("non-existent"); !errors.Is(err, filetracker.ErrItemNotFound) {
+			t.Errorf("want: %s, got: %v", filetracker.ErrItemNotFound, err)
+		}
+	})
+
+	t.Run("Should get the put item", func(t *testing.T) {
+		repo, cleanup := newRepository(t)
+		defer cleanup()
+
+		mustPut(t, repo, "/photos/a.jpg", withMediaItem)
+		mustPut(t, repo, "/photos/b.jpg", filetracker.NewTrackedFile("1589711400|hash-b"))
+
+		assertTrackedFile(t, repo, "/photos/a.jpg", "hash-a", "media-item-a", uploadedAt)
+		assertTrackedFile(t, repo, "/photos/b.jpg", "hash-b", "", time.Time{})
+	})
+
+	t.Run("Should get the albums of the put item", func(t *testing.T) {
+		repo, cleanup := newRepository(t)
+		defer cleanup()
+
+		withAlbums := withMediaItem
+		withAlbums.Albums = []string{"Trips", "2020"}
+		mustPut(t, repo, "/photos/a.jpg", withAlbums)
+
+		got, err := repo.Get("/photos/a.jpg")
+		if err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+		if !reflect.DeepEqual(withAlbums.Albums, got.Albums) {
+			t.Errorf("want: %v, got: %v", withAlbums.Albums, got.Albums)
+		}
+	})
+
+	t.Run("Should get the favorite mark of the put item", func(t *testing.T) {
+		repo, cleanup := newRepository(t)
+		defer cleanup()
+
+		favorite := filetracker.NewTrackedFile("hash-a")
+		favorite.Favorite = true
+		mustPut(t, repo, "/photos/a.jpg", favorite)
+
+		got, err := repo.Get("/photos/a.jpg")
+		if err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+		if !got.Favorite || got.Hash() != "hash-a" {
+			t.Errorf("want: favorite hash-a, got: %+v", got)
+		}
+	})
+
+	t.Run("Should replace the item if the key is already tracked", func(t *testing.T) {
+		repo, cleanup := newRepository(t)
+		defer cleanup()
+
+		mustPut(t, repo, "/photos/a.jpg", filetracker.NewTrackedFile("old-hash"))
+		mustPut(t, repo, "/photos/a.jpg", withMediaItem)
+
+		assertTrackedFile(t, repo, "/photos/a.jpg", "hash-a", "media-item-a", uploadedAt)
+	})
+
+	t.Run("Should delete the item", func(t *testing.T) {
+		repo, cleanup := newRepository(t)
+		defer cleanup()
+
+		mustPut(t, repo, "/photos/a.jpg", withMediaItem)
+		if err := repo.Delete("/photos/a.jpg"); err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+		if _, err := repo.Get("/photos/a.jpg"); !errors.Is(err, filetracker.ErrItemNotFound) {
+			t.Errorf("want: %s, got: %v", filetracker.ErrItemNotFound, err)
+		}
+		if err := repo.Delete("/photos/a.jpg"); err != nil {
+			t.Errorf("error was not expected deleting an untracked key: %s", err)
+		}
+	})
+
+	t.Run("Should iterate all the items sorted by key", func(t *testing.T) {
+		repo, cleanup := newRepository(t)
+		defer cleanup()
+
+		for _, key := range []string{"/photos/c.jpg", "/photos/a.jpg", "sha256:content", "/photos/b.jpg"} {
+			mustPut(t, repo, key, withMediaItem)
+		}
+
+		var got []string
+		err := repo.Iterate(func(key string, item filetracker.TrackedFile) error {
+			if item.Hash() != "hash-a" || item.MediaItemID != "media-item-a" || !item.UploadedAt.Equal(uploadedAt) {
+				t.Errorf("want: %+v, got: %+v, key: %s", withMediaItem, item, key)
+			}
+			got = append(got, key)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+		want := []string{"/photos/a.jpg", "/photos/b.jpg", "/photos/c.jpg", "sha256:content"}
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("want: %v, got: %v", want, got)
+		}
+	})
+
+	t.Run("Should stop iterating at the first error", func(t *testing.T) {
+		repo, cleanup := newRepository(t)
+		defer cleanup()
+
+		mustPut(t, repo, "/photos/a.jpg", withMediaItem)
+		mustPut(t, repo, "/photos/b.jpg", withMediaItem)
+
+		var visited int
+		err := repo.Iterate(func(key string, item filetracker.TrackedFile) error {
+			visited++
+			return ErrTestError
+		})
+		if !errors.Is(err, ErrTestError) {
+			t.Errorf("want: %s, got: %v", ErrTestError, err)
+		}
+		if visited != 1 {
+			t.Errorf("want: %d, got: %d", 1, visited)
+		}
+	})
+
+	t.Run("Should write batches of operations", func(t *testing.T) {
+		repo, cleanup := newRepository(t)
+		defer cleanup()
+
+		w, ok := repo.(filetracker.BatchWriter)
+		if !ok {
+			t.Skip("repository does not write batches")
+		}
+		mustPut(t, repo, "/photos/a.jpg", filetracker.NewTrackedFile("old-hash"))
+		err := w.WriteBatch([]filetracker.Op{
+			{Key: "/photos/a.jpg", Delete: true},
+			{Key: "/photos/b.jpg", Item: withMediaItem},
+			{Key: "/photos/c.jpg", Item: filetracker.NewTrackedFile("hash-c")},
+			{Key: "/photos/c.jpg", Delete: true},
+		})
+		if err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+
+		for _, key := range []string{"/photos/a.jpg", "/photos/c.jpg"} {
+			if _, err := repo.Get(key); !errors.Is(err, filetracker.ErrItemNotFound) {
+				t.Errorf("want: %s, got: %v, key: %s", filetracker.ErrItemNotFound, err, key)
+			}
+		}
+		assertTrackedFile(t, repo, "/photos/b.jpg", "hash-a", "media-item-a", uploadedAt)
+	})
+
+	t.Run("Should allow to delete items while iterating", func(t *testing.T) {
+		repo, cleanup := newRepository(t)
+		defer cleanup()
+
+		mustPut(t, repo, "/photos/a.jpg", withMediaItem)
+		mustPut(t, repo, "/photos/b.jpg", withMediaItem)
+
+		err := repo.Iterate(func(key string, item filetracker.TrackedFile) error {
+			return repo.Delete(key)
+		})
+		if err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+		var remaining int
+		_ = repo.Iterate(func(key string, item filetracker.TrackedFile) error {
+			remaining++
+			return nil
+		})
+		if remaining != 0 {
+			t.Errorf("want: %d, got: %d", 0, remaining)
+		}
+	})
+}
+
+func mustPut(t *testing.T, repo filetracker.Repository, key string, item filetracker.TrackedFile) {
+	if err := repo.Put(key, item); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+}
+
+func assertTrackedFile(t *testing.T, repo filetracker.Repository, key string, hash string, mediaItemID string, uploadedAt time.Time) {
+	got, err := repo.Get(key)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if got.Hash() != hash || got.MediaItemID != mediaItemID || !got.UploadedAt.Equal(uploadedAt) {
+		t.Errorf("want: %s %s %s, got: %s %s %s, key: %s", hash, mediaItemID, uploadedAt, got.Hash(), got.MediaItemID, got.UploadedAt, key)
+	}
+}
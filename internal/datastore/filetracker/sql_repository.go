@@ -0,0 +1,191 @@
+package filetracker
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// sqlSchema creates the tracked files table. Files are indexed by path, the key, and by hash,
+// so files could be queried by its content when deduplicating by hash.
+const sqlSchema = `
+CREATE TABLE IF NOT EXISTS tracked_files (
+	path          TEXT PRIMARY KEY,
+	hash          TEXT NOT NULL,
+	media_item_id TEXT NOT NULL DEFAULT '',
+	uploaded_at   TEXT NOT NULL DEFAULT '',
+	albums        TEXT NOT NULL DEFAULT '',
+	favorite      INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS tracked_files_hash ON tracked_files (hash);
+`
+
+// sqlAddAlbums adds the albums column to the tracked files tables created by previous versions.
+const sqlAddAlbums = `ALTER TABLE tracked_files ADD COLUMN albums TEXT NOT NULL DEFAULT ''`
+
+// sqlAddFavorite adds the favorite column to the tracked files tables created by previous versions.
+const sqlAddFavorite = `ALTER TABLE tracked_files ADD COLUMN favorite INTEGER NOT NULL DEFAULT 0`
+
+// SQLRepository implements a Repository using a SQL database, like SQLite.
+// It's safe for concurrent use, because sql.DB is.
+type SQLRepository struct {
+	DB *sql.DB
+}
+
+// NewSQLRepository returns a repository using the database, creating the schema if it doesn't exist.
+func NewSQLRepository(db *sql.DB) (*SQLRepository, error) {
+	if _, err := db.Exec(sqlSchema); err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec("SELECT albums FROM tracked_files LIMIT 0"); err != nil {
+		if _, err := db.Exec(sqlAddAlbums); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := db.Exec("SELECT favorite FROM tracked_files LIMIT 0"); err != nil {
+		if _, err := db.Exec(sqlAddFavorite); err != nil {
+			return nil, err
+		}
+	}
+	return &SQLRepository{DB: db}, nil
+}
+
+// Get returns the item specified by key. It returns ErrItemNotFound if the
+// DB does not contains the key.
+func (r SQLRepository) Get(key string) (TrackedFile, error) {
+	row := r.DB.QueryRow("SELECT hash, media_item_id, uploaded_at, albums, favorite FROM tracked_files WHERE path = ?", key)
+	item, err := scanTrackedFile(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return TrackedFile{}, ErrItemNotFound
+	}
+	return item, err
+}
+
+// Put stores the item under key.
+func (r SQLRepository) Put(key string, item TrackedFile) error {
+	return putTrackedFile(r.DB, key, item)
+}
+
+// Delete removes the item specified by key.
+func (r SQLRepository) Delete(key string) error {
+	return deleteTrackedFile(r.DB, key)
+}
+
+// WriteBatch writes all the operations in a single transaction.
+func (r SQLRepository) WriteBatch(ops []Op) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	for _, op := range ops {
+		if op.Delete {
+			err = deleteTrackedFile(tx, op.Key)
+		} else {
+			err = putTrackedFile(tx, op.Key, op.Item)
+		}
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Iterate calls fn for every item, sorted by key. It stops at the first error returned by fn.
+func (r SQLRepository) Iterate(fn func(key string, item TrackedFile) error) error {
+	rows, err := r.DB.Query("SELECT path, hash, media_item_id, uploaded_at, albums, favorite FROM tracked_files ORDER BY path")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	// items are read before calling fn, so fn could change the repository.
+	var keys []string
+	var items []TrackedFile
+	for rows.Next() {
+		var key string
+		item, err := scanTrackedFile(rows, &key)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+
+	for i := range keys {
+		if err := fn(keys[i], items[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the DB.
+func (r SQLRepository) Close() error {
+	return r.DB.Close()
+}
+
+// execer executes statements, like sql.DB and sql.Tx.
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+func putTrackedFile(db execer, key string, item TrackedFile) error {
+	var uploadedAt string
+	if !item.UploadedAt.IsZero() {
+		uploadedAt = item.UploadedAt.UTC().Format(time.RFC3339Nano)
+	}
+	var albums string
+	if len(item.Albums) > 0 {
+		b, err := json.Marshal(item.Albums)
+		if err != nil {
+			return err
+		}
+		albums = string(b)
+	}
+	_, err := db.Exec("INSERT OR REPLACE INTO tracked_files (path, hash, media_item_id, uploaded_at, albums, favorite) VALUES (?, ?, ?, ?, ?, ?)",
+		key, item.Hash(), item.MediaItemID, uploadedAt, albums, item.Favorite)
+	return err
+}
+
+func deleteTrackedFile(db execer, key string) error {
+	_, err := db.Exec("DELETE FROM tracked_files WHERE path = ?", key)
+	return err
+}
+
+// rowScanner is a row of a query result, like sql.Row and sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanTrackedFile reads a tracked file from the row, after the given columns.
+func scanTrackedFile(row rowScanner, columns ...interface{}) (TrackedFile, error) {
+	var hash, mediaItemID, uploadedAt, albums string
+	var favorite bool
+	if err := row.Scan(append(columns, &hash, &mediaItemID, &uploadedAt, &albums, &favorite)...); err != nil {
+		return TrackedFile{}, err
+	}
+	item := NewTrackedFile(hash)
+	item.MediaItemID = mediaItemID
+	item.Favorite = favorite
+	if uploadedAt != "" {
+		t, err := time.Parse(time.RFC3339Nano, uploadedAt)
+		if err != nil {
+			return TrackedFile{}, err
+		}
+		item.UploadedAt = t
+	}
+	if albums != "" {
+		if err := json.Unmarshal([]byte(albums), &item.Albums); err != nil {
+			return TrackedFile{}, err
+		}
+	}
+	return item, nil
+}
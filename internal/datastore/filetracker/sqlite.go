@@ -0,0 +1,34 @@
+//go:build sqlite
+// +build sqlite
+
+package filetracker
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// SQLiteAvailable is true if the SQLite driver is linked, using the sqlite build tag.
+const SQLiteAvailable = true
+
+// NewSQLiteRepository returns a repository using the SQLite database at filename,
+// creating it if it doesn't exist. It returns ErrCorrupted if the database is corrupted.
+func NewSQLiteRepository(filename string) (*SQLRepository, error) {
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		return nil, err
+	}
+	repo, err := NewSQLRepository(db)
+	if err != nil {
+		_ = db.Close()
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && (sqliteErr.Code == sqlite3.ErrNotADB || sqliteErr.Code == sqlite3.ErrCorrupt) {
+			return nil, fmt.Errorf("%w: %s", ErrCorrupted, err)
+		}
+		return nil, err
+	}
+	return repo, nil
+}
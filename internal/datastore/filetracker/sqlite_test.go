@@ -0,0 +1,78 @@
+//go:build sqlite
+// +build sqlite
+
+package filetracker_test
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/datastore/filetracker"
+)
+
+func TestSQLiteRepository_Conformance(t *testing.T) {
+	testRepositoryConformance(t, func(t *testing.T) (filetracker.Repository, func()) {
+		dir, err := ioutil.TempDir("", "filetracker")
+		if err != nil {
+			t.Fatal(err)
+		}
+		repo, err := filetracker.NewSQLiteRepository(filepath.Join(dir, "uploads.sqlite"))
+		if err != nil {
+			os.RemoveAll(dir)
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+		return repo, func() {
+			_ = repo.Close()
+			os.RemoveAll(dir)
+		}
+	})
+}
+
+func TestNewSQLRepository_AddsAlbumsColumn(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filetracker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// tracked_files table created by previous versions, without albums.
+	db, err := sql.Open("sqlite3", filepath.Join(dir, "uploads.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	_, err = db.Exec(`CREATE TABLE tracked_files (
+	path          TEXT PRIMARY KEY,
+	hash          TEXT NOT NULL,
+	media_item_id TEXT NOT NULL DEFAULT '',
+	uploaded_at   TEXT NOT NULL DEFAULT ''
+);
+INSERT INTO tracked_files (path, hash, media_item_id) VALUES ('/photos/a.jpg', 'hash-a', 'media-item-a');`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := filetracker.NewSQLRepository(db)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	item, err := repo.Get("/photos/a.jpg")
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if item.MediaItemID != "media-item-a" || len(item.Albums) != 0 {
+		t.Errorf("want: media-item-a [], got: %s %v", item.MediaItemID, item.Albums)
+	}
+
+	item.Albums = []string{"Trips"}
+	if err := repo.Put("/photos/a.jpg", item); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if got, _ := repo.Get("/photos/a.jpg"); !reflect.DeepEqual(item.Albums, got.Albums) {
+		t.Errorf("want: %v, got: %v", item.Albums, got.Albums)
+	}
+}
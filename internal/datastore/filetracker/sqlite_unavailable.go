@@ -0,0 +1,19 @@
+//go:build !sqlite
+// +build !sqlite
+
+package filetracker
+
+import (
+	"errors"
+)
+
+// SQLiteAvailable is true if the SQLite driver is linked, using the sqlite build tag.
+const SQLiteAvailable = false
+
+// ErrSQLiteUnavailable is returned when the SQLite driver is not linked.
+var ErrSQLiteUnavailable = errors.New("sqlite backend is not available, it requires building with the sqlite tag")
+
+// NewSQLiteRepository returns ErrSQLiteUnavailable, since the SQLite driver is not linked.
+func NewSQLiteRepository(filename string) (*SQLRepository, error) {
+	return nil, ErrSQLiteUnavailable
+}
@@ -0,0 +1,17 @@
+//go:build !sqlite
+// +build !sqlite
+
+package filetracker_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/datastore/filetracker"
+)
+
+func TestNewSQLiteRepository_Unavailable(t *testing.T) {
+	if _, err := filetracker.NewSQLiteRepository("uploads.sqlite"); !errors.Is(err, filetracker.ErrSQLiteUnavailable) {
+		t.Errorf("want: %s, got: %v", filetracker.ErrSQLiteUnavailable, err)
+	}
+}
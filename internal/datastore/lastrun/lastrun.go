@@ -0,0 +1,84 @@
+// Package lastrun keeps when the last successful run of every folder started, so the next runs
+// only check the files changed since then.
+package lastrun
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+const folderKeyPrefix = "folder:"
+
+// Run is the last successful run of a folder.
+type Run struct {
+	// StartedAt is when the run started, files changed later could not have been seen.
+	StartedAt time.Time `json:"startedAt"`
+	// Fingerprint identifies the settings of the job, files skipped with other settings could be uploaded now.
+	Fingerprint string `json:"fingerprint"`
+}
+
+// Store keeps the last successful run of every folder in a LevelDB database.
+// It's safe for concurrent use.
+type Store struct {
+	db *leveldb.DB
+
+	// now returns the current time.
+	// Useful for testing.
+	now func() time.Time
+}
+
+// Open returns the store kept in the LevelDB database at filename.
+func Open(filename string) (*Store, error) {
+	db, err := leveldb.OpenFile(filename, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{db: db, now: time.Now}, nil
+}
+
+// Get returns the last successful run of the folder, and false if there is none.
+func (s *Store) Get(folder string) (Run, bool, error) {
+	var run Run
+	b, err := s.db.Get([]byte(folderKeyPrefix+folder), nil)
+	if err == leveldb.ErrNotFound {
+		return run, false, nil
+	}
+	if err != nil {
+		return run, false, err
+	}
+	if err := json.Unmarshal(b, &run); err != nil {
+		return run, false, err
+	}
+	return run, true, nil
+}
+
+// Set records the successful run of the folder.
+func (s *Store) Set(folder string, run Run) error {
+	b, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	return s.db.Put([]byte(folderKeyPrefix+folder), b, nil)
+}
+
+// ChangedSince returns the time since when the files of the folder should be checked, given its last
+// successful run with the same fingerprint. It's moved back by margin, so files whose modification time
+// is set by a clock behind this one are not missed. It returns false if all the files should be checked:
+// there is no run with the same fingerprint, or it's in the future, since the clock has gone backwards.
+func (s *Store) ChangedSince(folder string, fingerprint string, margin time.Duration) (time.Time, bool, error) {
+	run, ok, err := s.Get(folder)
+	if err != nil || !ok {
+		return time.Time{}, false, err
+	}
+	if run.Fingerprint != fingerprint || run.StartedAt.After(s.now()) {
+		return time.Time{}, false, nil
+	}
+	return run.StartedAt.Add(-margin), true, nil
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
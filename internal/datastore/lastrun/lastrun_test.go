@@ -0,0 +1,82 @@
+package lastrun
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestStore(t *testing.T) (*Store, func()) {
+	dir, err := ioutil.TempDir("", "lastrun")
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	s, err := Open(filepath.Join(dir, "lastrun.db"))
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	return s, func() {
+		_ = s.Close()
+		_ = os.RemoveAll(dir)
+	}
+}
+
+func TestStore_ChangedSince(t *testing.T) {
+	now := time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name        string
+		run         *Run
+		fingerprint string
+		want        time.Time
+		wantOk      bool
+	}{
+		{"Should check all files without previous run", nil, "abc", time.Time{}, false},
+		{"Should check files changed since previous run", &Run{StartedAt: now.Add(-24 * time.Hour), Fingerprint: "abc"}, "abc", now.Add(-25 * time.Hour), true},
+		{"Should check all files if settings have changed", &Run{StartedAt: now.Add(-24 * time.Hour), Fingerprint: "abc"}, "def", time.Time{}, false},
+		{"Should check all files if previous run is in the future", &Run{StartedAt: now.Add(time.Hour), Fingerprint: "abc"}, "abc", time.Time{}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, cleanup := newTestStore(t)
+			defer cleanup()
+			s.now = func() time.Time { return now }
+
+			if tc.run != nil {
+				if err := s.Set("/photos", *tc.run); err != nil {
+					t.Fatalf("error was not expected at this point: %s", err)
+				}
+			}
+			got, ok, err := s.ChangedSince("/photos", tc.fingerprint, time.Hour)
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			if ok != tc.wantOk || !got.Equal(tc.want) {
+				t.Errorf("want: %v %t, got: %v %t", tc.want, tc.wantOk, got, ok)
+			}
+		})
+	}
+}
+
+func TestStore_SetKeepsFolders(t *testing.T) {
+	s, cleanup := newTestStore(t)
+	defer cleanup()
+
+	started := time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC)
+	if err := s.Set("/photos", Run{StartedAt: started, Fingerprint: "abc"}); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	got, ok, err := s.Get("/photos")
+	if err != nil || !ok {
+		t.Fatalf("want: run, got: %v %t", err, ok)
+	}
+	if !got.StartedAt.Equal(started) || got.Fingerprint != "abc" {
+		t.Errorf("want: %v abc, got: %v %s", started, got.StartedAt, got.Fingerprint)
+	}
+	if _, ok, _ := s.Get("/videos"); ok {
+		t.Errorf("run of other folder was not expected")
+	}
+}
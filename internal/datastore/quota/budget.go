@@ -0,0 +1,177 @@
+// Package quota keeps the count of the Google Photos API requests made every day,
+// so the daily quota of the API is not exceeded, even across several runs.
+package quota
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+const (
+	dayKeyPrefix = "day:"
+	dayLayout    = "2006-01-02"
+)
+
+// ErrExhausted is returned when the requests of the day have been used.
+var ErrExhausted = errors.New("daily request budget exhausted")
+
+// quotaLocation is where the days start, since Google resets the quota at midnight Pacific Time.
+var quotaLocation = pacificTime()
+
+func pacificTime() *time.Location {
+	if loc, err := time.LoadLocation("America/Los_Angeles"); err == nil {
+		return loc
+	}
+	return time.FixedZone("PST", -8*60*60)
+}
+
+// Budget is the number of requests allowed every day. The requests already made are kept
+// by day in a LevelDB database, so they are counted across runs.
+// It's safe for concurrent use.
+type Budget struct {
+	db *leveldb.DB
+
+	// Limit is the maximum number of requests a day. Zero means unlimited.
+	Limit int
+
+	// wait, if it's true, makes Take wait until the next day when the budget is exhausted.
+	wait bool
+
+	// now returns the current time.
+	// Useful for testing.
+	now func() time.Time
+
+	// mu serializes the read-modify-write of the count, and protects wait.
+	mu sync.Mutex
+}
+
+// Open returns the budget kept in the LevelDB database at filename.
+// The budget is unlimited if limit is not positive, but requests are counted anyway.
+func Open(filename string, limit int) (*Budget, error) {
+	db, err := leveldb.OpenFile(filename, nil)
+	if err != nil {
+		return nil, err
+	}
+	if limit < 0 {
+		limit = 0
+	}
+	return &Budget{db: db, Limit: limit, now: time.Now}, nil
+}
+
+// SetWaitForRenewal sets if Take waits until the next day when the budget is exhausted,
+// instead of returning ErrExhausted.
+func (b *Budget) SetWaitForRenewal(wait bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.wait = wait
+}
+
+// Take counts a request. It returns ErrExhausted if the requests of the day have been used, unless
+// it should wait for the renewal, see SetWaitForRenewal. It waits until the next day, or until ctx is done, then.
+func (b *Budget) Take(ctx context.Context) error {
+	for {
+		ok, wait, err := b.take()
+		if err != nil || ok {
+			return err
+		}
+		if !wait {
+			return ErrExhausted
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(b.Renewal().Sub(b.now())):
+		}
+	}
+}
+
+// take counts a request, returning false if the budget is exhausted, and if it should wait for the renewal.
+func (b *Budget) take() (bool, bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := b.dayKey(b.now())
+	used, err := b.get(key)
+	if err != nil {
+		return false, false, err
+	}
+	if b.Limit > 0 && used >= b.Limit {
+		return false, b.wait, nil
+	}
+	if used == 0 {
+		// the counts of previous days are not needed anymore.
+		if err := b.deleteOtherDays(key); err != nil {
+			return false, false, err
+		}
+	}
+	return true, false, b.db.Put([]byte(key), []byte(strconv.Itoa(used+1)), nil)
+}
+
+// Used returns the number of requests made today.
+func (b *Budget) Used() (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.get(b.dayKey(b.now()))
+}
+
+// Remaining returns the number of requests allowed until the end of the day, or -1 if it's unlimited.
+func (b *Budget) Remaining() (int, error) {
+	if b.Limit <= 0 {
+		return -1, nil
+	}
+	used, err := b.Used()
+	if err != nil {
+		return 0, err
+	}
+	if used >= b.Limit {
+		return 0, nil
+	}
+	return b.Limit - used, nil
+}
+
+// Renewal returns when the budget is renewed, at the start of the next day in Pacific Time.
+func (b *Budget) Renewal() time.Time {
+	now := b.now().In(quotaLocation)
+	return time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, quotaLocation)
+}
+
+// Close closes the database.
+func (b *Budget) Close() error {
+	return b.db.Close()
+}
+
+func (b *Budget) dayKey(t time.Time) string {
+	return dayKeyPrefix + t.In(quotaLocation).Format(dayLayout)
+}
+
+func (b *Budget) get(key string) (int, error) {
+	value, err := b.db.Get([]byte(key), nil)
+	if err == leveldb.ErrNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(string(value))
+}
+
+func (b *Budget) deleteOtherDays(key string) error {
+	iter := b.db.NewIterator(util.BytesPrefix([]byte(dayKeyPrefix)), nil)
+	defer iter.Release()
+	batch := new(leveldb.Batch)
+	for iter.Next() {
+		if string(iter.Key()) != key {
+			batch.Delete(append([]byte(nil), iter.Key()...))
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	return b.db.Write(batch, nil)
+}
@@ -0,0 +1,142 @@
+package quota
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestBudget(t *testing.T, limit int) (*Budget, string, func()) {
+	dir, err := ioutil.TempDir("", "quota")
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	filename := filepath.Join(dir, "quota.db")
+	b, err := Open(filename, limit)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	return b, filename, func() {
+		_ = b.Close()
+		_ = os.RemoveAll(dir)
+	}
+}
+
+func TestBudget_Exhausted(t *testing.T) {
+	b, _, cleanup := newTestBudget(t, 3)
+	defer cleanup()
+
+	for i := 0; i < 3; i++ {
+		if err := b.Take(context.Background()); err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+	}
+	if err := b.Take(context.Background()); err != ErrExhausted {
+		t.Errorf("want: %s, got: %v", ErrExhausted, err)
+	}
+	if remaining, err := b.Remaining(); err != nil || remaining != 0 {
+		t.Errorf("want: 0, got: %d, err: %v", remaining, err)
+	}
+}
+
+func TestBudget_RenewedNextDay(t *testing.T) {
+	b, filename, cleanup := newTestBudget(t, 2)
+	defer cleanup()
+
+	day := time.Date(2026, 10, 14, 23, 30, 0, 0, quotaLocation)
+	b.now = func() time.Time { return day }
+	for i := 0; i < 2; i++ {
+		if err := b.Take(context.Background()); err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+	}
+
+	// the requests are counted across runs.
+	if err := b.Close(); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	b, err := Open(filename, 2)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	b.now = func() time.Time { return day }
+	if err := b.Take(context.Background()); err != ErrExhausted {
+		t.Errorf("want: %s, got: %v", ErrExhausted, err)
+	}
+	if want := time.Date(2026, 10, 15, 0, 0, 0, 0, quotaLocation); !b.Renewal().Equal(want) {
+		t.Errorf("want: %s, got: %s", want, b.Renewal())
+	}
+
+	b.now = func() time.Time { return day.Add(time.Hour) }
+	if err := b.Take(context.Background()); err != nil {
+		t.Errorf("error was not expected at this point: %s", err)
+	}
+	if used, err := b.Used(); err != nil || used != 1 {
+		t.Errorf("want: 1, got: %d, err: %v", used, err)
+	}
+
+	// the counts of the previous days are removed.
+	b.now = func() time.Time { return day }
+	if used, err := b.Used(); err != nil || used != 0 {
+		t.Errorf("want: 0, got: %d, err: %v", used, err)
+	}
+	_ = b.Close()
+}
+
+func TestBudget_WaitForRenewal(t *testing.T) {
+	b, _, cleanup := newTestBudget(t, 1)
+	defer cleanup()
+
+	// the clock is moved to just before midnight, so the wait is short.
+	midnight := time.Date(2026, 10, 15, 0, 0, 0, 0, quotaLocation)
+	offset := midnight.Add(-50 * time.Millisecond).Sub(time.Now())
+	b.now = func() time.Time { return time.Now().Add(offset) }
+	b.SetWaitForRenewal(true)
+
+	if err := b.Take(context.Background()); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := b.Take(ctx); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if now := b.now(); now.Before(midnight) {
+		t.Errorf("want: after %s, got: %s", midnight, now)
+	}
+}
+
+func TestBudget_WaitForRenewalCancelled(t *testing.T) {
+	b, _, cleanup := newTestBudget(t, 1)
+	defer cleanup()
+	b.SetWaitForRenewal(true)
+
+	if err := b.Take(context.Background()); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := b.Take(ctx); err != context.DeadlineExceeded {
+		t.Errorf("want: %s, got: %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestBudget_Unlimited(t *testing.T) {
+	b, _, cleanup := newTestBudget(t, 0)
+	defer cleanup()
+
+	for i := 0; i < 10; i++ {
+		if err := b.Take(context.Background()); err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+	}
+	if used, err := b.Used(); err != nil || used != 10 {
+		t.Errorf("want: 10, got: %d, err: %v", used, err)
+	}
+	if remaining, err := b.Remaining(); err != nil || remaining != -1 {
+		t.Errorf("want: -1, got: %d, err: %v", remaining, err)
+	}
+}
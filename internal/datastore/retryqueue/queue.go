@@ -0,0 +1,223 @@
+// Package retryqueue keeps the failed uploads to be retried on the next runs.
+package retryqueue
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+const (
+	// DefaultMaxAttempts is the default number of failed attempts before giving up uploading a file.
+	DefaultMaxAttempts = 5
+
+	// BaseBackoff is the time to wait before retrying a file that has failed once.
+	// It doubles on every failed attempt, up to MaxBackoff.
+	BaseBackoff = time.Minute
+
+	// MaxBackoff is the maximum time to wait before retrying a failed file.
+	MaxBackoff = 24 * time.Hour
+
+	pendingKeyPrefix = "pending:"
+	deadKeyPrefix    = "dead:"
+)
+
+// Entry is a failed upload.
+type Entry struct {
+	Path        string    `json:"path"`
+	Reason      string    `json:"reason"`
+	Attempts    int       `json:"attempts"`
+	LastAttempt time.Time `json:"lastAttempt"`
+}
+
+// NextAttempt returns when the file should be retried, backing off exponentially given the attempts.
+func (e Entry) NextAttempt() time.Time {
+	backoff := MaxBackoff
+	if e.Attempts < 32 {
+		if b := BaseBackoff << uint(e.Attempts-1); b > 0 && b < MaxBackoff {
+			backoff = b
+		}
+	}
+	return e.LastAttempt.Add(backoff)
+}
+
+// Queue keeps the pending uploads, failed less than MaxAttempts times, and the dead letters,
+// the uploads that have failed MaxAttempts times and are not retried anymore.
+// It's safe for concurrent use.
+type Queue struct {
+	db *leveldb.DB
+
+	// MaxAttempts is the number of failed attempts before an upload is a dead letter.
+	MaxAttempts int
+
+	// now returns the current time.
+	// Useful for testing.
+	now func() time.Time
+
+	// mu serializes the read-modify-write of entries.
+	mu sync.Mutex
+}
+
+// Open returns the queue kept in the LevelDB database at filename. DefaultMaxAttempts is used if
+// maxAttempts is not positive.
+func Open(filename string, maxAttempts int) (*Queue, error) {
+	db, err := leveldb.OpenFile(filename, nil)
+	if err != nil {
+		return nil, err
+	}
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+	return &Queue{db: db, MaxAttempts: maxAttempts, now: time.Now}, nil
+}
+
+// Failed records a failed attempt to upload the file. It returns the updated entry, and true if
+// the file has become a dead letter.
+func (q *Queue) Failed(path string, reason string) (Entry, bool, error) {
+	return q.fail(path, reason, false)
+}
+
+// Rejected records a failed attempt to upload the file that would fail again if it's retried, e.g. because
+// its content is not supported. The file becomes a dead letter, whatever its attempts.
+func (q *Queue) Rejected(path string, reason string) (Entry, error) {
+	e, _, err := q.fail(path, reason, true)
+	return e, err
+}
+
+// fail records a failed attempt to upload the file, that becomes a dead letter after MaxAttempts, or
+// right away if it's permanent.
+func (q *Queue) fail(path string, reason string, permanent bool) (Entry, bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	e, _, err := q.get(pendingKeyPrefix + path)
+	if err != nil {
+		return Entry{}, false, err
+	}
+	e.Path = path
+	e.Reason = reason
+	e.Attempts++
+	e.LastAttempt = q.now().UTC()
+
+	if e.Attempts < q.MaxAttempts && !permanent {
+		return e, false, q.put(pendingKeyPrefix+path, e)
+	}
+	batch := new(leveldb.Batch)
+	batch.Delete([]byte(pendingKeyPrefix + path))
+	b, err := json.Marshal(e)
+	if err != nil {
+		return Entry{}, false, err
+	}
+	batch.Put([]byte(deadKeyPrefix+path), b)
+	return e, true, q.db.Write(batch, nil)
+}
+
+// Remove forgets the file, e.g. once it has been uploaded.
+func (q *Queue) Remove(path string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	batch := new(leveldb.Batch)
+	batch.Delete([]byte(pendingKeyPrefix + path))
+	batch.Delete([]byte(deadKeyPrefix + path))
+	return q.db.Write(batch, nil)
+}
+
+// Get returns the pending entry of the file, and true if it's a dead letter.
+// The entry is empty if the file has not failed.
+func (q *Queue) Get(path string) (Entry, bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	e, exist, err := q.get(deadKeyPrefix + path)
+	if err != nil || exist {
+		return e, exist, err
+	}
+	e, _, err = q.get(pendingKeyPrefix + path)
+	return e, false, err
+}
+
+// Due returns true if the file should be attempted now: it has not failed, or its backoff has
+// passed. Dead letters are never due.
+func (q *Queue) Due(path string) (bool, error) {
+	e, dead, err := q.Get(path)
+	if err != nil || dead {
+		return false, err
+	}
+	return e.Attempts == 0 || !q.now().Before(e.NextAttempt()), nil
+}
+
+// Pending returns the pending entries, the ones with less failures first.
+func (q *Queue) Pending() ([]Entry, error) {
+	entries, err := q.list(pendingKeyPrefix)
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Attempts < entries[j].Attempts
+	})
+	return entries, err
+}
+
+// DeadLetters returns the uploads that have failed MaxAttempts times, sorted by path.
+func (q *Queue) DeadLetters() ([]Entry, error) {
+	return q.list(deadKeyPrefix)
+}
+
+// ClearDeadLetters removes the dead letters, so they are attempted again.
+func (q *Queue) ClearDeadLetters() (int, error) {
+	entries, err := q.DeadLetters()
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range entries {
+		if err := q.Remove(e.Path); err != nil {
+			return 0, err
+		}
+	}
+	return len(entries), nil
+}
+
+// Close closes the queue.
+// No operation could be done after that.
+func (q *Queue) Close() error {
+	return q.db.Close()
+}
+
+func (q *Queue) get(key string) (Entry, bool, error) {
+	var e Entry
+	b, err := q.db.Get([]byte(key), nil)
+	if err == leveldb.ErrNotFound {
+		return e, false, nil
+	}
+	if err != nil {
+		return e, false, err
+	}
+	return e, true, json.Unmarshal(b, &e)
+}
+
+func (q *Queue) put(key string, e Entry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return q.db.Put([]byte(key), b, nil)
+}
+
+func (q *Queue) list(prefix string) ([]Entry, error) {
+	iter := q.db.NewIterator(util.BytesPrefix([]byte(prefix)), nil)
+	defer iter.Release()
+
+	var entries []Entry
+	for iter.Next() {
+		var e Entry
+		if err := json.Unmarshal(iter.Value(), &e); err != nil {
+			return nil, err
+		}
+		e.Path = strings.TrimPrefix(string(iter.Key()), prefix)
+		entries = append(entries, e)
+	}
+	return entries, iter.Error()
+}
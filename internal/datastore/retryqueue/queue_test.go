@@ -0,0 +1,238 @@
+package retryqueue
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestQueue(t *testing.T, maxAttempts int) (*Queue, func()) {
+	dir, err := ioutil.TempDir("", "retryqueue")
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	q, err := Open(filepath.Join(dir, "retries.db"), maxAttempts)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	return q, func() {
+		_ = q.Close()
+		_ = os.RemoveAll(dir)
+	}
+}
+
+func TestQueue_FailedThenSucceeded(t *testing.T) {
+	q, cleanup := newTestQueue(t, 3)
+	defer cleanup()
+
+	for i := 1; i <= 2; i++ {
+		e, dead, err := q.Failed("/foo/bar.jpg", "503 Service Unavailable")
+		if err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+		if dead {
+			t.Errorf("want: not a dead letter, got: dead letter after %d attempts", i)
+		}
+		if e.Attempts != i {
+			t.Errorf("want: %d, got: %d", i, e.Attempts)
+		}
+	}
+
+	pending, err := q.Pending()
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if len(pending) != 1 || pending[0].Path != "/foo/bar.jpg" || pending[0].Attempts != 2 || pending[0].Reason != "503 Service Unavailable" {
+		t.Errorf("want: one pending entry with 2 attempts, got: %v", pending)
+	}
+
+	if err := q.Remove("/foo/bar.jpg"); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	pending, err = q.Pending()
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("want: no pending entries, got: %v", pending)
+	}
+	due, err := q.Due("/foo/bar.jpg")
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if !due {
+		t.Errorf("want: %t, got: %t", true, due)
+	}
+}
+
+func TestQueue_DeadLetters(t *testing.T) {
+	q, cleanup := newTestQueue(t, 3)
+	defer cleanup()
+
+	var dead bool
+	var err error
+	for i := 0; i < 3; i++ {
+		_, dead, err = q.Failed("/foo/bar.jpg", "400 Bad Request")
+		if err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+	}
+	if !dead {
+		t.Errorf("want: dead letter, got: pending")
+	}
+
+	letters, err := q.DeadLetters()
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if len(letters) != 1 || letters[0].Path != "/foo/bar.jpg" || letters[0].Attempts != 3 {
+		t.Errorf("want: one dead letter with 3 attempts, got: %v", letters)
+	}
+	pending, err := q.Pending()
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("want: no pending entries, got: %v", pending)
+	}
+
+	// dead letters are never due, even after the maximum backoff.
+	q.now = func() time.Time { return time.Now().Add(2 * MaxBackoff) }
+	due, err := q.Due("/foo/bar.jpg")
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if due {
+		t.Errorf("want: %t, got: %t", false, due)
+	}
+
+	n, err := q.ClearDeadLetters()
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if n != 1 {
+		t.Errorf("want: %d, got: %d", 1, n)
+	}
+	if due, _ := q.Due("/foo/bar.jpg"); !due {
+		t.Errorf("want: %t, got: %t", true, due)
+	}
+}
+
+func TestQueue_Rejected(t *testing.T) {
+	q, cleanup := newTestQueue(t, 3)
+	defer cleanup()
+
+	if _, _, err := q.Failed("/foo/bar.jpg", "503 Service Unavailable"); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	e, err := q.Rejected("/foo/bar.jpg", "400 Bad Request")
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if e.Attempts != 2 || e.Reason != "400 Bad Request" {
+		t.Errorf("want: 2 attempts, reason 400 Bad Request, got: %v", e)
+	}
+
+	letters, err := q.DeadLetters()
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if len(letters) != 1 || letters[0].Path != "/foo/bar.jpg" {
+		t.Errorf("want: one dead letter, got: %v", letters)
+	}
+	if due, _ := q.Due("/foo/bar.jpg"); due {
+		t.Errorf("want: %t, got: %t", false, due)
+	}
+}
+
+func TestQueue_Due(t *testing.T) {
+	q, cleanup := newTestQueue(t, 5)
+	defer cleanup()
+
+	start := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	q.now = func() time.Time { return start }
+	for i := 0; i < 2; i++ {
+		if _, _, err := q.Failed("/foo/bar.jpg", "timeout"); err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+	}
+
+	var testData = []struct {
+		name  string
+		after time.Duration
+		want  bool
+	}{
+		{"Should not be due during the backoff", time.Minute, false},
+		{"Should be due once the backoff has passed", 2 * time.Minute, true},
+	}
+	for _, tt := range testData {
+		t.Run(tt.name, func(t *testing.T) {
+			q.now = func() time.Time { return start.Add(tt.after) }
+			got, err := q.Due("/foo/bar.jpg")
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			if got != tt.want {
+				t.Errorf("want: %t, got: %t", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestEntry_NextAttempt(t *testing.T) {
+	last := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	var testData = []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{4, 8 * time.Minute},
+		{20, MaxBackoff},
+		{100, MaxBackoff},
+	}
+	for _, tt := range testData {
+		got := Entry{Attempts: tt.attempts, LastAttempt: last}.NextAttempt()
+		if !got.Equal(last.Add(tt.want)) {
+			t.Errorf("attempts %d, want: %v, got: %v", tt.attempts, last.Add(tt.want), got)
+		}
+	}
+}
+
+func TestQueue_PersistedAcrossRuns(t *testing.T) {
+	dir, err := ioutil.TempDir("", "retryqueue")
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "retries.db")
+
+	q, err := Open(filename, 0)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if q.MaxAttempts != DefaultMaxAttempts {
+		t.Errorf("want: %d, got: %d", DefaultMaxAttempts, q.MaxAttempts)
+	}
+	if _, _, err := q.Failed("/foo/bar.jpg", "timeout"); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	q, err = Open(filename, 0)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	defer q.Close()
+	e, dead, err := q.Get("/foo/bar.jpg")
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if dead || e.Attempts != 1 || e.Reason != "timeout" {
+		t.Errorf("want: pending entry with 1 attempt, got: %v, dead: %t", e, dead)
+	}
+}
@@ -0,0 +1,52 @@
+package tokenmanager
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+
+	"golang.org/x/oauth2"
+)
+
+// DefaultTokenEnvVar is the default environment variable to read the token from.
+const DefaultTokenEnvVar = "GPHOTOS_CLI_TOKEN"
+
+// ErrReadOnlyRepository is returned when storing a token into a read only repository.
+var ErrReadOnlyRepository = errors.New("token repository is read only")
+
+// EnvRepository reads the token, encoded as JSON, from an environment variable.
+// It's read only, so refreshed tokens are not kept.
+type EnvRepository struct {
+	variable string
+}
+
+// NewEnvRepository returns a repository reading the token from the environment variable.
+func NewEnvRepository(variable string) *EnvRepository {
+	if variable == "" {
+		variable = DefaultTokenEnvVar
+	}
+	return &EnvRepository{variable: variable}
+}
+
+// Set returns ErrReadOnlyRepository, since the environment could not be changed.
+func (r *EnvRepository) Set(key string, token *oauth2.Token) error {
+	return ErrReadOnlyRepository
+}
+
+// Get returns the token in the environment variable. The same token is returned for any key.
+func (r *EnvRepository) Get(key string) (*oauth2.Token, error) {
+	value := os.Getenv(r.variable)
+	if value == "" {
+		return nil, ErrTokenNotFound
+	}
+	var token oauth2.Token
+	if err := json.Unmarshal([]byte(value), &token); err != nil {
+		return nil, ErrInvalidToken
+	}
+	return &token, nil
+}
+
+// Close closes the repository.
+func (r *EnvRepository) Close() error {
+	return nil
+}
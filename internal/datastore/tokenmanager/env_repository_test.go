@@ -0,0 +1,51 @@
+package tokenmanager
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func TestEnvRepository_Get(t *testing.T) {
+	const variable = "GPHOTOS_CLI_TEST_TOKEN"
+	defer os.Unsetenv(variable)
+
+	repo := NewEnvRepository(variable)
+
+	t.Run("ReturnErrNotFoundWhenVariableIsNotSet", func(t *testing.T) {
+		_ = os.Unsetenv(variable)
+		_, err := repo.Get("user@domain.com")
+		if err != ErrTokenNotFound {
+			t.Errorf("want: %s, got: %v", ErrTokenNotFound, err)
+		}
+	})
+
+	t.Run("ReturnErrInvalidTokenWhenVariableIsNotJSON", func(t *testing.T) {
+		_ = os.Setenv(variable, "my-access-token")
+		_, err := repo.Get("user@domain.com")
+		if err != ErrInvalidToken {
+			t.Errorf("want: %s, got: %v", ErrInvalidToken, err)
+		}
+	})
+
+	t.Run("ShouldSuccess", func(t *testing.T) {
+		want := getDefaultToken()
+		b, _ := json.Marshal(want)
+		_ = os.Setenv(variable, string(b))
+
+		got, err := repo.Get("user@domain.com")
+		if err != nil {
+			t.Fatalf("error was not expected: err=%s", err)
+		}
+		if !sameTokens(want, got) {
+			t.Errorf("want: %v, got: %v", want, got)
+		}
+	})
+}
+
+func TestEnvRepository_Set(t *testing.T) {
+	repo := NewEnvRepository("")
+	if err := repo.Set("user@domain.com", getDefaultToken()); err != ErrReadOnlyRepository {
+		t.Errorf("want: %s, got: %v", ErrReadOnlyRepository, err)
+	}
+}
@@ -0,0 +1,184 @@
+package tokenmanager
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// scrypt parameters to derive the encryption key from the passphrase.
+	scryptN       = 32768
+	scryptR       = 8
+	scryptP       = 1
+	keyLength     = 32
+	saltLength    = 16
+	tokenFileMode = 0600
+)
+
+// ErrEmptyPassphrase is returned when the file repository has not a passphrase.
+var ErrEmptyPassphrase = errors.New("passphrase to encrypt tokens could not be empty")
+
+// FileRepository keeps tokens in a file, encrypted at rest using a passphrase.
+type FileRepository struct {
+	path       string
+	passphrase string
+
+	mu sync.Mutex
+}
+
+// encryptedFile is the content of the file. Data is the JSON of the tokens by key, encrypted with AES-GCM.
+type encryptedFile struct {
+	Salt  []byte `json:"salt"`
+	Nonce []byte `json:"nonce"`
+	Data  []byte `json:"data"`
+}
+
+// NewFileRepository returns a repository keeping tokens in the file at path, encrypted with the passphrase.
+func NewFileRepository(path string, passphrase string) (*FileRepository, error) {
+	if passphrase == "" {
+		return nil, ErrEmptyPassphrase
+	}
+	return &FileRepository{path: path, passphrase: passphrase}, nil
+}
+
+// Set stores the token using key.
+func (r *FileRepository) Set(key string, token *oauth2.Token) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tokens, err := r.read()
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if tokens == nil {
+		tokens = make(map[string]*oauth2.Token)
+	}
+	tokens[key] = token
+	return r.write(tokens)
+}
+
+// Get returns the token stored using key.
+func (r *FileRepository) Get(key string) (*oauth2.Token, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tokens, err := r.read()
+	if os.IsNotExist(err) {
+		return nil, ErrTokenNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	token, ok := tokens[key]
+	if !ok {
+		return nil, ErrTokenNotFound
+	}
+	return token, nil
+}
+
+// Close closes the repository.
+func (r *FileRepository) Close() error {
+	return nil
+}
+
+// read returns the tokens after decrypting the file.
+func (r *FileRepository) read() (map[string]*oauth2.Token, error) {
+	b, err := ioutil.ReadFile(r.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var content encryptedFile
+	if err := json.Unmarshal(b, &content); err != nil {
+		return nil, fmt.Errorf("invalid token file '%s': %s", r.path, err)
+	}
+	aead, err := r.cipher(content.Salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(content.Nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("invalid token file '%s': invalid nonce", r.path)
+	}
+	data, err := aead.Open(nil, content.Nonce, content.Data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt token file '%s', the passphrase could be wrong", r.path)
+	}
+
+	tokens := make(map[string]*oauth2.Token)
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, ErrInvalidToken
+	}
+	return tokens, nil
+}
+
+// write encrypts the tokens, with a new salt and nonce, and replaces the file.
+func (r *FileRepository) write(tokens map[string]*oauth2.Token) error {
+	data, err := json.Marshal(tokens)
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	content := encryptedFile{Salt: make([]byte, saltLength)}
+	if _, err := io.ReadFull(rand.Reader, content.Salt); err != nil {
+		return err
+	}
+	aead, err := r.cipher(content.Salt)
+	if err != nil {
+		return err
+	}
+	content.Nonce = make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, content.Nonce); err != nil {
+		return err
+	}
+	content.Data = aead.Seal(nil, content.Nonce, data, nil)
+
+	b, err := json.Marshal(content)
+	if err != nil {
+		return err
+	}
+
+	// the file is replaced once it has been written, so it's never left half written.
+	tmp, err := ioutil.TempFile(filepath.Dir(r.path), filepath.Base(r.path))
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), tokenFileMode); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), r.path)
+}
+
+// cipher returns the AES-GCM cipher using a key derived from the passphrase and salt.
+func (r *FileRepository) cipher(salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(r.passphrase), salt, scryptN, scryptR, scryptP, keyLength)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
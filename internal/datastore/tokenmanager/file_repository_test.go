@@ -0,0 +1,111 @@
+package tokenmanager
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func TestNewFileRepository(t *testing.T) {
+	if _, err := NewFileRepository("tokens.enc", ""); err != ErrEmptyPassphrase {
+		t.Errorf("want: %s, got: %v", ErrEmptyPassphrase, err)
+	}
+}
+
+func TestFileRepository_Get(t *testing.T) {
+	dir := tempDir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatalf("error was not expected at this stage: err=%s", err)
+	}
+	defer func() {
+		err := os.RemoveAll(dir)
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+	path := filepath.Join(dir, "tokens.enc")
+
+	repo, err := NewFileRepository(path, "no more secrets")
+	if err != nil {
+		t.Fatalf("error was not expected at this stage: err=%s", err)
+	}
+
+	t.Run("ReturnErrNotFoundWhenFileDoesNotExist", func(t *testing.T) {
+		_, err := repo.Get("user@domain.com")
+		if err != ErrTokenNotFound {
+			t.Errorf("want: %s, got: %v", ErrTokenNotFound, err)
+		}
+	})
+
+	want := getDefaultToken()
+	if err := repo.Set("user@domain.com", want); err != nil {
+		t.Fatalf("error was not expected: err=%s", err)
+	}
+	other := &oauth2.Token{AccessToken: "other-access-token", RefreshToken: "other-refresh-token"}
+	if err := repo.Set("other@domain.com", other); err != nil {
+		t.Fatalf("error was not expected: err=%s", err)
+	}
+
+	t.Run("ShouldSuccess", func(t *testing.T) {
+		got, err := repo.Get("user@domain.com")
+		if err != nil {
+			t.Fatalf("error was not expected: err=%s", err)
+		}
+		if !sameTokens(want, got) {
+			t.Errorf("want: %v, got: %v", want, got)
+		}
+	})
+
+	t.Run("ShouldSuccessFromAnotherRepository", func(t *testing.T) {
+		repo, err := NewFileRepository(path, "no more secrets")
+		if err != nil {
+			t.Fatalf("error was not expected at this stage: err=%s", err)
+		}
+		got, err := repo.Get("other@domain.com")
+		if err != nil {
+			t.Fatalf("error was not expected: err=%s", err)
+		}
+		if !sameTokens(other, got) {
+			t.Errorf("want: %v, got: %v", other, got)
+		}
+	})
+
+	t.Run("ReturnErrNotFoundWhenTokenDoesNotExists", func(t *testing.T) {
+		_, err := repo.Get("non-existent")
+		if err != ErrTokenNotFound {
+			t.Errorf("want: %s, got: %v", ErrTokenNotFound, err)
+		}
+	})
+
+	t.Run("ShouldFailWithWrongPassphrase", func(t *testing.T) {
+		repo, err := NewFileRepository(path, "wrong passphrase")
+		if err != nil {
+			t.Fatalf("error was not expected at this stage: err=%s", err)
+		}
+		if _, err := repo.Get("user@domain.com"); err == nil {
+			t.Errorf("error was expected, but not produced")
+		}
+	})
+
+	t.Run("ShouldBeEncryptedAtRest", func(t *testing.T) {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatalf("error was not expected: err=%s", err)
+		}
+		if bytes.Contains(b, []byte(want.AccessToken)) || bytes.Contains(b, []byte(want.RefreshToken)) {
+			t.Errorf("token should not be in plain text: %s", b)
+		}
+	})
+}
+
+// sameTokens returns true if both tokens have the same values.
+func sameTokens(want, got *oauth2.Token) bool {
+	return want.AccessToken == got.AccessToken &&
+		want.TokenType == got.TokenType &&
+		want.RefreshToken == got.RefreshToken &&
+		want.Expiry.Equal(got.Expiry)
+}
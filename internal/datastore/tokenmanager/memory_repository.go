@@ -0,0 +1,35 @@
+package tokenmanager
+
+import (
+	"golang.org/x/oauth2"
+)
+
+// MemoryRepository keeps the token in memory, e.g. the one read from the standard input, so it's never written
+// to disk. It's read only, so refreshed tokens are not kept.
+type MemoryRepository struct {
+	token *oauth2.Token
+}
+
+// NewMemoryRepository returns a repository with the token.
+func NewMemoryRepository(token *oauth2.Token) *MemoryRepository {
+	return &MemoryRepository{token: token}
+}
+
+// Set returns ErrReadOnlyRepository, the token could not be changed.
+func (r *MemoryRepository) Set(key string, token *oauth2.Token) error {
+	return ErrReadOnlyRepository
+}
+
+// Get returns the token of the repository. The same token is returned for any key.
+func (r *MemoryRepository) Get(key string) (*oauth2.Token, error) {
+	if r.token == nil {
+		return nil, ErrTokenNotFound
+	}
+	token := *r.token
+	return &token, nil
+}
+
+// Close closes the repository.
+func (r *MemoryRepository) Close() error {
+	return nil
+}
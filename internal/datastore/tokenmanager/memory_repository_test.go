@@ -0,0 +1,35 @@
+package tokenmanager
+
+import (
+	"testing"
+)
+
+func TestMemoryRepository_Get(t *testing.T) {
+	t.Run("ReturnErrNotFoundWithoutToken", func(t *testing.T) {
+		repo := NewMemoryRepository(nil)
+		_, err := repo.Get("user@domain.com")
+		if err != ErrTokenNotFound {
+			t.Errorf("want: %s, got: %v", ErrTokenNotFound, err)
+		}
+	})
+
+	t.Run("ShouldSuccess", func(t *testing.T) {
+		want := getDefaultToken()
+		repo := NewMemoryRepository(want)
+
+		got, err := repo.Get("user@domain.com")
+		if err != nil {
+			t.Fatalf("error was not expected: err=%s", err)
+		}
+		if !sameTokens(want, got) {
+			t.Errorf("want: %v, got: %v", want, got)
+		}
+	})
+}
+
+func TestMemoryRepository_Set(t *testing.T) {
+	repo := NewMemoryRepository(getDefaultToken())
+	if err := repo.Set("user@domain.com", getDefaultToken()); err != ErrReadOnlyRepository {
+		t.Errorf("want: %s, got: %v", ErrReadOnlyRepository, err)
+	}
+}
@@ -0,0 +1,250 @@
+// Package exif reads the capture date, the camera, the orientation and the presence of GPS data of photos from
+// its EXIF metadata.
+package exif
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// dateTimeLayout is the layout of EXIF dates.
+	dateTimeLayout = "2006:01:02 15:04:05"
+
+	tagMake             = 0x010F
+	tagModel            = 0x0110
+	tagOrientation      = 0x0112
+	tagExifIFDPointer   = 0x8769
+	tagGPSIFDPointer    = 0x8825
+	tagDateTimeOriginal = 0x9003
+	typeASCII           = 2
+	typeShort           = 3
+)
+
+var (
+	// ErrNotFound is returned when the file has no EXIF metadata, or it doesn't contain the capture date.
+	ErrNotFound = errors.New("exif capture date was not found")
+
+	// ErrCorrupt is returned when the EXIF metadata could not be parsed.
+	ErrCorrupt = errors.New("exif metadata is corrupt")
+)
+
+// Metadata are the EXIF tags of a photo. Missing tags are empty.
+type Metadata struct {
+	Make  string
+	Model string
+	// HasGPS is true if the photo has GPS data.
+	HasGPS bool
+	// DateTimeOriginal is when the photo was taken, in local time.
+	DateTimeOriginal time.Time
+	// Orientation is how the image should be rotated or flipped to be displayed, from 1 to 8, see Rotated.
+	Orientation int
+}
+
+// Rotated returns true if the image is displayed rotated by 90 degrees, so its width and height are swapped.
+func (md Metadata) Rotated() bool {
+	return md.Orientation >= 5 && md.Orientation <= 8
+}
+
+// Reader reads the EXIF metadata of JPEG files.
+type Reader struct{}
+
+// CaptureTime returns the EXIF DateTimeOriginal of the file, in local time.
+func (Reader) CaptureTime(path string) (time.Time, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+	return DateTimeOriginal(f)
+}
+
+// Metadata returns the EXIF metadata of the file.
+func (Reader) Metadata(path string) (Metadata, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Metadata{}, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// DateTimeOriginal returns the EXIF DateTimeOriginal of a JPEG stream, in local time.
+// It returns ErrNotFound if there is no such date, and ErrCorrupt if the metadata could not be parsed.
+func DateTimeOriginal(r io.Reader) (time.Time, error) {
+	md, err := Read(r)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if md.DateTimeOriginal.IsZero() {
+		return time.Time{}, ErrNotFound
+	}
+	return md.DateTimeOriginal, nil
+}
+
+// Read returns the EXIF metadata of a JPEG stream.
+// It returns ErrNotFound if there is no EXIF metadata, and ErrCorrupt if it could not be parsed.
+func Read(r io.Reader) (Metadata, error) {
+	tiff, err := findExifSegment(bufio.NewReader(r))
+	if err != nil {
+		return Metadata{}, err
+	}
+	return readMetadata(tiff)
+}
+
+// findExifSegment returns the TIFF data of the APP1 Exif segment of a JPEG stream.
+func findExifSegment(r *bufio.Reader) ([]byte, error) {
+	var soi [2]byte
+	if _, err := io.ReadFull(r, soi[:]); err != nil || soi != [2]byte{0xFF, 0xD8} {
+		return nil, ErrNotFound // not a JPEG file.
+	}
+
+	for {
+		var marker [2]byte
+		if _, err := io.ReadFull(r, marker[:]); err != nil {
+			return nil, ErrNotFound
+		}
+		if marker[0] != 0xFF {
+			return nil, ErrCorrupt
+		}
+		// metadata segments are before the image data (SOS) or the end of the image (EOI).
+		if marker[1] == 0xDA || marker[1] == 0xD9 {
+			return nil, ErrNotFound
+		}
+
+		var length uint16
+		if err := binary.Read(r, binary.BigEndian, &length); err != nil || length < 2 {
+			return nil, ErrCorrupt
+		}
+		size := int64(length) - 2
+
+		if marker[1] != 0xE1 {
+			if _, err := io.CopyN(ioutil.Discard, r, size); err != nil {
+				return nil, ErrCorrupt
+			}
+			continue
+		}
+
+		data := make([]byte, size)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, ErrCorrupt
+		}
+		if bytes.HasPrefix(data, []byte("Exif\x00\x00")) {
+			return data[6:], nil
+		}
+	}
+}
+
+// readMetadata returns the metadata of the TIFF data.
+func readMetadata(tiff []byte) (Metadata, error) {
+	var md Metadata
+	if len(tiff) < 8 {
+		return md, ErrCorrupt
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return md, ErrCorrupt
+	}
+	if order.Uint16(tiff[2:]) != 42 {
+		return md, ErrCorrupt
+	}
+	ifd0 := order.Uint32(tiff[4:])
+
+	var err error
+	if md.Make, err = readASCIITag(tiff, order, ifd0, tagMake); err != nil {
+		return md, err
+	}
+	if md.Model, err = readASCIITag(tiff, order, ifd0, tagModel); err != nil {
+		return md, err
+	}
+	if md.Orientation, err = readShortTag(tiff, order, ifd0, tagOrientation); err != nil {
+		return md, err
+	}
+	if _, md.HasGPS, err = findTag(tiff, order, ifd0, tagGPSIFDPointer); err != nil {
+		return md, err
+	}
+
+	exifIFD, found, err := findTag(tiff, order, ifd0, tagExifIFDPointer)
+	if err != nil || !found {
+		return md, err
+	}
+	value, err := readASCIITag(tiff, order, order.Uint32(exifIFD[8:]), tagDateTimeOriginal)
+	if err != nil || value == "" {
+		return md, err
+	}
+	md.DateTimeOriginal, err = time.ParseInLocation(dateTimeLayout, value, time.Local)
+	if err != nil {
+		return md, fmt.Errorf("%w: invalid date '%s'", ErrCorrupt, value)
+	}
+	return md, nil
+}
+
+// readASCIITag returns the value of the ASCII tag in the IFD at the given offset, or empty if it's not found.
+func readASCIITag(tiff []byte, order binary.ByteOrder, offset uint32, tag uint16) (string, error) {
+	entry, found, err := findTag(tiff, order, offset, tag)
+	if err != nil || !found {
+		return "", err
+	}
+	if order.Uint16(entry[2:]) != typeASCII {
+		return "", ErrCorrupt
+	}
+
+	count := order.Uint32(entry[4:])
+	var value []byte
+	if count <= 4 {
+		value = entry[8 : 8+count]
+	} else {
+		offset := order.Uint32(entry[8:])
+		if uint64(offset)+uint64(count) > uint64(len(tiff)) {
+			return "", ErrCorrupt
+		}
+		value = tiff[offset : offset+count]
+	}
+	return strings.TrimRight(string(value), "\x00 "), nil
+}
+
+// readShortTag returns the value of the SHORT tag in the IFD at the given offset, or 0 if it's not found.
+func readShortTag(tiff []byte, order binary.ByteOrder, offset uint32, tag uint16) (int, error) {
+	entry, found, err := findTag(tiff, order, offset, tag)
+	if err != nil || !found {
+		return 0, err
+	}
+	if order.Uint16(entry[2:]) != typeShort || order.Uint32(entry[4:]) != 1 {
+		return 0, ErrCorrupt
+	}
+	return int(order.Uint16(entry[8:])), nil
+}
+
+// findTag returns the 12 bytes entry of the tag in the IFD at the given offset.
+func findTag(tiff []byte, order binary.ByteOrder, offset uint32, tag uint16) ([]byte, bool, error) {
+	if uint64(offset)+2 > uint64(len(tiff)) {
+		return nil, false, ErrCorrupt
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	entries := uint64(offset) + 2
+	if entries+uint64(count)*12 > uint64(len(tiff)) {
+		return nil, false, ErrCorrupt
+	}
+
+	for i := 0; i < count; i++ {
+		entry := tiff[entries+uint64(i)*12 : entries+uint64(i+1)*12]
+		if order.Uint16(entry) == tag {
+			return entry, true, nil
+		}
+	}
+	return nil, false, nil
+}
@@ -0,0 +1,220 @@
+package exif_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/exif"
+)
+
+// newTIFF returns the TIFF data with an Exif IFD containing DateTimeOriginal.
+func newTIFF(order binary.ByteOrder, date string) []byte {
+	value := append([]byte(date), 0)
+
+	buf := new(bytes.Buffer)
+	if order == binary.LittleEndian {
+		buf.WriteString("II")
+	} else {
+		buf.WriteString("MM")
+	}
+	_ = binary.Write(buf, order, uint16(42))
+	_ = binary.Write(buf, order, uint32(8)) // IFD0 offset
+
+	// IFD0: one entry pointing to the Exif IFD, at 8+18.
+	_ = binary.Write(buf, order, uint16(1))
+	_ = binary.Write(buf, order, []uint16{0x8769, 4})
+	_ = binary.Write(buf, order, []uint32{1, 26})
+	_ = binary.Write(buf, order, uint32(0))
+
+	// Exif IFD: one entry with DateTimeOriginal value, at 26+18.
+	_ = binary.Write(buf, order, uint16(1))
+	_ = binary.Write(buf, order, []uint16{0x9003, 2})
+	_ = binary.Write(buf, order, []uint32{uint32(len(value)), 44})
+	_ = binary.Write(buf, order, uint32(0))
+
+	buf.Write(value)
+	return buf.Bytes()
+}
+
+// newJPEG returns a JPEG stream with an APP0 segment and an APP1 segment with the given data, if any.
+func newJPEG(app1 []byte) []byte {
+	buf := new(bytes.Buffer)
+	buf.Write([]byte{0xFF, 0xD8})
+
+	app0 := []byte("JFIF\x00\x01\x01")
+	buf.Write([]byte{0xFF, 0xE0})
+	_ = binary.Write(buf, binary.BigEndian, uint16(len(app0)+2))
+	buf.Write(app0)
+
+	if app1 != nil {
+		buf.Write([]byte{0xFF, 0xE1})
+		_ = binary.Write(buf, binary.BigEndian, uint16(len(app1)+2))
+		buf.Write(app1)
+	}
+
+	buf.Write([]byte{0xFF, 0xD9})
+	return buf.Bytes()
+}
+
+func exifSegment(tiff []byte) []byte {
+	return append([]byte("Exif\x00\x00"), tiff...)
+}
+
+func TestDateTimeOriginal(t *testing.T) {
+	want := time.Date(2023, 7, 14, 18, 30, 5, 0, time.Local)
+	validTIFF := newTIFF(binary.LittleEndian, "2023:07:14 18:30:05")
+
+	testCases := []struct {
+		name    string
+		input   []byte
+		want    time.Time
+		wantErr error
+	}{
+		{"Should return date with little endian EXIF", newJPEG(exifSegment(validTIFF)), want, nil},
+		{"Should return date with big endian EXIF", newJPEG(exifSegment(newTIFF(binary.BigEndian, "2023:07:14 18:30:05"))), want, nil},
+		{"Should fail if there is no EXIF", newJPEG(nil), time.Time{}, exif.ErrNotFound},
+		{"Should fail if it's not a JPEG", []byte("\x89PNG\r\n\x1a\n"), time.Time{}, exif.ErrNotFound},
+		{"Should fail if EXIF is truncated", newJPEG(exifSegment(validTIFF[:30])), time.Time{}, exif.ErrCorrupt},
+		{"Should fail if EXIF has an invalid header", newJPEG(exifSegment([]byte("XX*\x00\x08\x00\x00\x00"))), time.Time{}, exif.ErrCorrupt},
+		{"Should fail if EXIF date is invalid", newJPEG(exifSegment(newTIFF(binary.LittleEndian, "0000:00:00 00:00:00"))), time.Time{}, exif.ErrCorrupt},
+		{"Should fail if segment is truncated", newJPEG(exifSegment(validTIFF))[:40], time.Time{}, exif.ErrCorrupt},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := exif.DateTimeOriginal(bytes.NewReader(tc.input))
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("want: %v, got: %v", tc.wantErr, err)
+			}
+			if !got.Equal(tc.want) {
+				t.Errorf("want: %s, got: %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestReader_CaptureTime(t *testing.T) {
+	dir, err := ioutil.TempDir("", "exif")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "photo.jpg")
+	if err := ioutil.WriteFile(file, newJPEG(exifSegment(newTIFF(binary.LittleEndian, "2023:07:14 18:30:05"))), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := exif.Reader{}.CaptureTime(file)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if want := time.Date(2023, 7, 14, 18, 30, 5, 0, time.Local); !got.Equal(want) {
+		t.Errorf("want: %s, got: %s", want, got)
+	}
+
+	if _, err := (exif.Reader{}).CaptureTime(filepath.Join(dir, "non-existent.jpg")); err == nil {
+		t.Errorf("error was expected, but not produced")
+	}
+}
+
+// newCameraTIFF returns the TIFF data with Make and Model tags, and a GPS IFD if hasGPS is true.
+func newCameraTIFF(order binary.ByteOrder, maker string, model string, hasGPS bool) []byte {
+	values := [][]byte{append([]byte(maker), 0), append([]byte(model), 0)}
+	entries := uint16(2)
+	if hasGPS {
+		entries++
+	}
+
+	buf := new(bytes.Buffer)
+	if order == binary.LittleEndian {
+		buf.WriteString("II")
+	} else {
+		buf.WriteString("MM")
+	}
+	_ = binary.Write(buf, order, uint16(42))
+	_ = binary.Write(buf, order, uint32(8)) // IFD0 offset
+
+	// IFD0, values are after it, and the GPS IFD is empty.
+	valuesOffset := uint32(8 + 2 + int(entries)*12 + 4)
+	_ = binary.Write(buf, order, entries)
+	_ = binary.Write(buf, order, []uint16{0x010F, 2})
+	_ = binary.Write(buf, order, []uint32{uint32(len(values[0])), valuesOffset})
+	_ = binary.Write(buf, order, []uint16{0x0110, 2})
+	_ = binary.Write(buf, order, []uint32{uint32(len(values[1])), valuesOffset + uint32(len(values[0]))})
+	gpsOffset := valuesOffset + uint32(len(values[0])+len(values[1]))
+	if hasGPS {
+		_ = binary.Write(buf, order, []uint16{0x8825, 4})
+		_ = binary.Write(buf, order, []uint32{1, gpsOffset})
+	}
+	_ = binary.Write(buf, order, uint32(0))
+
+	buf.Write(values[0])
+	buf.Write(values[1])
+	if hasGPS {
+		_ = binary.Write(buf, order, uint16(0))
+		_ = binary.Write(buf, order, uint32(0))
+	}
+	return buf.Bytes()
+}
+
+// newOrientationTIFF returns the TIFF data with only the Orientation tag.
+func newOrientationTIFF(order binary.ByteOrder, orientation uint16) []byte {
+	buf := new(bytes.Buffer)
+	if order == binary.LittleEndian {
+		buf.WriteString("II")
+	} else {
+		buf.WriteString("MM")
+	}
+	_ = binary.Write(buf, order, uint16(42))
+	_ = binary.Write(buf, order, uint32(8)) // IFD0 offset
+
+	// IFD0, the value fits in the entry.
+	_ = binary.Write(buf, order, uint16(1))
+	_ = binary.Write(buf, order, []uint16{0x0112, 3})
+	_ = binary.Write(buf, order, uint32(1))
+	_ = binary.Write(buf, order, []uint16{orientation, 0})
+	_ = binary.Write(buf, order, uint32(0))
+	return buf.Bytes()
+}
+
+func TestRead(t *testing.T) {
+	testCases := []struct {
+		name    string
+		input   []byte
+		want    exif.Metadata
+		wantErr error
+	}{
+		{"Should return camera with GPS", newJPEG(exifSegment(newCameraTIFF(binary.LittleEndian, "SONY", "ILCE-7M4", true))), exif.Metadata{Make: "SONY", Model: "ILCE-7M4", HasGPS: true}, nil},
+		{"Should return camera without GPS", newJPEG(exifSegment(newCameraTIFF(binary.BigEndian, "Canon", "Canon EOS R5", false))), exif.Metadata{Make: "Canon", Model: "Canon EOS R5"}, nil},
+		{"Should return date without camera", newJPEG(exifSegment(newTIFF(binary.LittleEndian, "2023:07:14 18:30:05"))), exif.Metadata{DateTimeOriginal: time.Date(2023, 7, 14, 18, 30, 5, 0, time.Local)}, nil},
+		{"Should return orientation", newJPEG(exifSegment(newOrientationTIFF(binary.BigEndian, 6))), exif.Metadata{Orientation: 6}, nil},
+		{"Should fail if there is no EXIF", newJPEG(nil), exif.Metadata{}, exif.ErrNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := exif.Read(bytes.NewReader(tc.input))
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("want: %v, got: %v", tc.wantErr, err)
+			}
+			if got.Make != tc.want.Make || got.Model != tc.want.Model || got.HasGPS != tc.want.HasGPS || got.Orientation != tc.want.Orientation || !got.DateTimeOriginal.Equal(tc.want.DateTimeOriginal) {
+				t.Errorf("want: %+v, got: %+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestMetadata_Rotated(t *testing.T) {
+	for orientation, want := range map[int]bool{0: false, 1: false, 3: false, 5: true, 6: true, 8: true} {
+		if got := (exif.Metadata{Orientation: orientation}).Rotated(); want != got {
+			t.Errorf("orientation %d, want: %v, got: %v", orientation, want, got)
+		}
+	}
+}
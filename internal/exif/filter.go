@@ -0,0 +1,29 @@
+package exif
+
+import "strings"
+
+// Filter allows the photos whose EXIF metadata matches all of its criteria.
+// Photos without EXIF metadata, like screenshots or videos, only match a Filter without Make
+// and Model, not requiring GPS data.
+type Filter struct {
+	// Make, if it's set, is the camera maker, e.g. "SONY". It's compared ignoring the case.
+	Make string
+	// Model, if it's set, is the camera model, e.g. "ILCE-7M4". It's compared ignoring the case.
+	Model string
+	// GPS, if it's set, requires the photos to have GPS data, if it's true, or not to have it, if it's false.
+	GPS *bool
+}
+
+// IsAllowed returns true if the metadata matches all the criteria of the filter.
+func (f Filter) IsAllowed(md Metadata) bool {
+	if f.Make != "" && !strings.EqualFold(strings.TrimSpace(md.Make), strings.TrimSpace(f.Make)) {
+		return false
+	}
+	if f.Model != "" && !strings.EqualFold(strings.TrimSpace(md.Model), strings.TrimSpace(f.Model)) {
+		return false
+	}
+	if f.GPS != nil && md.HasGPS != *f.GPS {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,44 @@
+package exif_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/exif"
+)
+
+func TestFilter_IsAllowed(t *testing.T) {
+	withGPS, withoutGPS := true, false
+	sony := newJPEG(exifSegment(newCameraTIFF(binary.LittleEndian, "SONY", "ILCE-7M4", true)))
+	canon := newJPEG(exifSegment(newCameraTIFF(binary.BigEndian, "Canon", "Canon EOS R5", false)))
+	screenshot := newJPEG(nil)
+
+	testCases := []struct {
+		name   string
+		filter exif.Filter
+		input  []byte
+		want   bool
+	}{
+		{"Should allow matching model", exif.Filter{Model: "ILCE-7M4"}, sony, true},
+		{"Should allow matching model ignoring case", exif.Filter{Model: "ilce-7m4"}, sony, true},
+		{"Should not allow other model", exif.Filter{Model: "ILCE-7M4"}, canon, false},
+		{"Should allow matching make and model", exif.Filter{Make: "Canon", Model: "Canon EOS R5"}, canon, true},
+		{"Should not allow other make", exif.Filter{Make: "SONY", Model: "Canon EOS R5"}, canon, false},
+		{"Should allow photo with GPS", exif.Filter{GPS: &withGPS}, sony, true},
+		{"Should not allow photo without GPS", exif.Filter{GPS: &withGPS}, canon, false},
+		{"Should allow photo without GPS", exif.Filter{GPS: &withoutGPS}, canon, true},
+		{"Should not allow screenshot without camera", exif.Filter{Model: "ILCE-7M4"}, screenshot, false},
+		{"Should allow screenshot without criteria", exif.Filter{}, screenshot, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// files without EXIF metadata have empty metadata.
+			md, _ := exif.Read(bytes.NewReader(tc.input))
+			if got := tc.filter.IsAllowed(md); got != tc.want {
+				t.Errorf("want: %t, got: %t", tc.want, got)
+			}
+		})
+	}
+}
@@ -2,30 +2,167 @@ package filter
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/pathnorm"
 )
 
 // Filter is a file filter based on allowed and excluded patterns.
+//
+// Patterns are matched against the whole path relative to the scanned folder,
+// not only against the file name. `**` matches zero or more directories, so
+// `foo/**/*.jpg` matches any JPG under the top-level `foo` folder, while
+// `**/foo/*.jpg` matches JPGs directly inside any `foo` folder. A pattern with
+// no slash only matches items in the top-level folder, e.g. `*.jpg` doesn't
+// match `foo/bar.jpg`; use `**/*.jpg` instead.
+//
+// Paths are matched using `/` as separator on every OS, so patterns are written
+// in the same way on all of them, e.g. `foo/*.jpg` matches `foo\bar.jpg` on Windows.
+//
+// Paths and patterns are matched in the same Unicode form, NFC by default, so names created on macOS, where
+// accented characters are decomposed, match the patterns written on other systems.
 type Filter struct {
 	allowedList  []string
 	excludedList []string
+	// allowed and excluded are allowedList and excludedList compiled, see compilePatterns.
+	allowed  compiledPatterns
+	excluded compiledPatterns
+
+	caseInsensitive bool
+	unicodeForm     pathnorm.Form
+	includeAll      bool
+	minSize         int64
+	maxSize         int64
+	// minDepth and maxDepth are the bounds of the depth of the allowed files, maxDepth is -1 if it's unbounded.
+	minDepth int
+	maxDepth int
+
+	logger log.Logger
+}
+
+// FilterResult represents the outcome of a Filter for a given item, see Explain.
+type FilterResult struct {
+	// Allowed is true if the item is allowed by the Filter.
+	Allowed bool
+
+	// Excluded is true if the item has been excluded by one of the exclude patterns.
+	Excluded bool
+
+	// Pattern is the pattern that decided the outcome:
+	//   - the exclude pattern, if the item is excluded.
+	//   - the include pattern, if the item is allowed.
+	//   - empty, if no include pattern matches the item, or if include patterns are bypassed (see IncludeAll).
+	Pattern string
+
+	// Index is the position of Pattern in the include or exclude list, once the
+	// tagged patterns have been resolved. It's -1 if Pattern is empty.
+	Index int
+
+	// OutOfDepth is true if the item is not allowed because its depth is out of MinDepth and MaxDepth.
+	OutOfDepth bool
+}
+
+// String returns a human readable description of the result.
+func (r FilterResult) String() string {
+	switch {
+	case r.Allowed && r.Pattern == "":
+		return "allowed, include patterns are bypassed"
+	case r.Allowed:
+		return fmt.Sprintf("allowed by include pattern '%s' (#%d)", r.Pattern, r.Index)
+	case r.Excluded:
+		return fmt.Sprintf("excluded by exclude pattern '%s' (#%d)", r.Pattern, r.Index)
+	case r.OutOfDepth:
+		return "not allowed, its depth is out of bounds"
+	default:
+		return "not allowed, no include pattern matches"
+	}
+}
+
+// FilterOptions represents the options to be used when compiling a Filter.
+type FilterOptions struct {
+	// CaseInsensitive makes patterns to match regardless of the case of the path.
+	CaseInsensitive bool
+
+	// UnicodeForm is the Unicode form the paths and the patterns are normalized to before matching them. Uses
+	// pathnorm.NFC by default, pathnorm.Off matches them as they are.
+	UnicodeForm pathnorm.Form
+
+	// IncludeAll makes every item not excluded to be allowed, bypassing the include patterns.
+	IncludeAll bool
+
+	// MinSize is the minimum size, in bytes, of the allowed files. Zero means unbounded.
+	MinSize int64
+
+	// MaxSize is the maximum size, in bytes, of the allowed files. Zero means unbounded.
+	MaxSize int64
+
+	// MinDepth is the minimum depth of the allowed files, see Depth. Zero means unbounded.
+	MinDepth int
+
+	// MaxDepth, if it's set, is the maximum depth of the allowed files, see Depth. Directories whose files would be
+	// deeper are not scanned. Zero only allows the files directly in the scanned folder.
+	MaxDepth *int
+
+	// Logger, if it's set, logs every decision at debug level, with the pattern that decided it.
+	Logger log.Logger
 }
 
 // Compile returns an initialized Filter struct. If allowedList is empty, _IMAGE_EXTENSIONS_ tagged pattern is used instead.
+// Use _MEDIA_EXTENSIONS_ tagged pattern in allowedList to include both images and videos.
+// Patterns prefixed by `re:` are regular expressions matched against the whole path, e.g. `re:IMG_\d{8}_.*\.jpg$`.
 // It validates the patterns in allowedList and excludedList, returning error if they are not valid.
 func Compile(allowedList []string, excludedList []string) (*Filter, error) {
+	return CompileWithOptions(allowedList, excludedList, FilterOptions{})
+}
+
+// CompileWithOptions is like Compile but allows to set the options used to build the Filter.
+// When CaseInsensitive is set, both allowedList and excludedList are compiled in a case-folding mode.
+func CompileWithOptions(allowedList []string, excludedList []string, opts FilterOptions) (*Filter, error) {
 	f := Filter{
-		allowedList:  translatePatternList(allowedList),
-		excludedList: translatePatternList(excludedList),
+		allowedList:     opts.UnicodeForm.Paths(translatePatternList(allowedList)),
+		excludedList:    opts.UnicodeForm.Paths(translatePatternList(excludedList)),
+		caseInsensitive: opts.CaseInsensitive,
+		unicodeForm:     opts.UnicodeForm,
+		includeAll:      opts.IncludeAll,
+		minSize:         opts.MinSize,
+		maxSize:         opts.MaxSize,
+		minDepth:        opts.MinDepth,
+		maxDepth:        -1,
+		logger:          opts.Logger,
+	}
+	if opts.MaxDepth != nil {
+		if *opts.MaxDepth < 0 {
+			return nil, fmt.Errorf("depth bounds could not be negative: min=%d, max=%d", opts.MinDepth, *opts.MaxDepth)
+		}
+		f.maxDepth = *opts.MaxDepth
 	}
 
 	if len(f.allowedList) == 0 {
 		f.allowedList = patternDictionary["_IMAGE_EXTENSIONS_"]
 	}
 
+	if f.caseInsensitive {
+		f.allowedList = toLowerList(f.allowedList)
+		f.excludedList = toLowerList(f.excludedList)
+	}
+
 	if err := f.validate(); err != nil {
 		return nil, err
 	}
 
+	var err error
+	if f.allowed, err = compilePatterns(f.allowedList, false); err != nil {
+		return nil, err
+	}
+	if f.excluded, err = compilePatterns(f.excludedList, true); err != nil {
+		return nil, err
+	}
+
 	return &f, nil
 }
 
@@ -41,24 +178,195 @@ func MustCompile(allowedList []string, excludedList []string) *Filter {
 
 // IsAllowed returns if an item is allowed.
 // That means:
-//   - item is in the include pattern
+//   - item depth is between MinDepth and MaxDepth (both included)
+//   - item is in the include pattern, unless IncludeAll is set
 //   - item is not in the exclude pattern
 func (f Filter) IsAllowed(fp string) bool {
-	// patterns has been validated before (see Compile), so no need to check error.
-	matched, _ := match(f.allowedList, fp)
-	return matched && !f.IsExcluded(fp)
+	allowed := f.isAllowed(fp)
+	if f.debugging() {
+		f.logger.Debugf("Filter: '%s' is %s.", fp, f.Explain(fp))
+	}
+	return allowed
+}
+
+func (f Filter) isAllowed(fp string) bool {
+	if !f.isAllowedDepth(Depth(fp)) {
+		return false
+	}
+	if f.includeAll {
+		return !f.IsExcluded(fp)
+	}
+	return f.allowed.firstIndex(f.normalize(fp)) >= 0 && !f.IsExcluded(fp)
+}
+
+// IsAllowedDir returns if a directory should be scanned.
+// It returns false only when the directory matches the exclude patterns, or its files
+// would be deeper than MaxDepth, allowing to prune the whole subtree. Include patterns
+// and MinDepth are never applied to directories, because a directory that doesn't match
+// them could contain allowed files.
+func (f Filter) IsAllowedDir(dir string) bool {
+	// the files of the directory are one level deeper than it.
+	if f.maxDepth >= 0 && Depth(dir)+1 > f.maxDepth {
+		if f.debugging() {
+			f.logger.Debugf("Filter: directory '%s' is deeper than MaxDepth %d, it's not scanned.", dir, f.maxDepth)
+		}
+		return false
+	}
+	i := f.excluded.lastIndex(f.normalize(dir))
+	if i < 0 {
+		return true
+	}
+	if _, negated := isNegated(f.excludedList[i]); negated {
+		return true
+	}
+	if f.debugging() {
+		f.logger.Debugf("Filter: directory '%s' is excluded by exclude pattern '%s' (#%d), it's not scanned.", dir, f.excludedList[i], i)
+	}
+	return false
+}
+
+// Explain returns the FilterResult for an item, reporting which pattern decided
+// if the item is allowed or not. It's useful to debug why an item is skipped.
+func (f Filter) Explain(fp string) FilterResult {
+	if !f.isAllowedDepth(Depth(fp)) {
+		return FilterResult{OutOfDepth: true, Index: -1}
+	}
+	p := f.normalize(fp)
+
+	i := f.allowed.firstIndex(p)
+	if i < 0 && !f.includeAll {
+		return FilterResult{Index: -1}
+	}
+
+	if j := f.excluded.lastIndex(p); j >= 0 {
+		if _, negated := isNegated(f.excludedList[j]); !negated {
+			return FilterResult{Excluded: true, Pattern: f.excludedList[j], Index: j}
+		}
+	}
+
+	if f.includeAll {
+		return FilterResult{Allowed: true, Index: -1}
+	}
+	return FilterResult{Allowed: true, Pattern: f.allowedList[i], Index: i}
+}
+
+// Patterns returns the include and exclude patterns, once the tagged patterns have been resolved, so the Index of
+// a FilterResult is the position of its Pattern in one of them.
+func (f Filter) Patterns() (allowed []string, excluded []string) {
+	return append([]string(nil), f.allowedList...), append([]string(nil), f.excludedList...)
+}
+
+// IsAllowedFile returns if a file is allowed, taking into account its size.
+// That means:
+//   - file is allowed by the patterns (see IsAllowed)
+//   - file size is between MinSize and MaxSize (both included)
+func (f Filter) IsAllowedFile(fp string, info os.FileInfo) bool {
+	if !f.IsAllowed(fp) {
+		return false
+	}
+	if !f.isAllowedSize(info.Size()) {
+		if f.debugging() {
+			f.logger.Debugf("Filter: '%s' is not allowed, its size of %d bytes is out of bounds.", fp, info.Size())
+		}
+		return false
+	}
+	return true
 }
 
 // IsExcluded return if an item should be excluded.
 // It's useful for skipping directories that match with an exclusion.
+//
+// The excluded list is evaluated in order, and the last matching pattern decides.
+// A pattern starting with `!` re-includes the items matched by a previous pattern,
+// in the same way as `.gitignore` does. So, a negated pattern only takes effect
+// when it comes after the pattern it's overriding:
+//   - {"folder1/**", "!folder1/keepers/**"} excludes folder1 except folder1/keepers.
+//   - {"!folder1/keepers/**", "folder1/**"} excludes the whole folder1.
+//
+// Note that a file could not be re-included if one of its parent directories
+// was excluded, because the directory is not scanned at all.
 func (f Filter) IsExcluded(fp string) bool {
-	// patterns has been validated before (see Compile), so no need to check error.
-	matched, _ := match(f.excludedList, fp)
-	return matched
+	i := f.excluded.lastIndex(f.normalize(fp))
+	if i < 0 {
+		return false
+	}
+	_, negated := isNegated(f.excludedList[i])
+	return !negated
+}
+
+// MatchExcluded returns if an item is excluded, like IsExcluded does, and if any of the exclude patterns
+// matches it. Items not matched by any pattern are not decided by the filter, which is useful to compose
+// filters, e.g. the ones of nested ignore files, where the last filter matching an item decides.
+func (f Filter) MatchExcluded(fp string) (excluded bool, matched bool) {
+	i := f.excluded.lastIndex(f.normalize(fp))
+	if i < 0 {
+		return false, false
+	}
+	_, negated := isNegated(f.excludedList[i])
+	return !negated, true
+}
+
+// debugging returns if the decisions are logged.
+func (f Filter) debugging() bool {
+	return f.logger != nil && f.logger.GetLevel() >= logrus.DebugLevel
+}
+
+// isAllowedSize returns if the size is between the configured bounds.
+func (f Filter) isAllowedSize(size int64) bool {
+	if f.minSize > 0 && size < f.minSize {
+		return false
+	}
+	if f.maxSize > 0 && size > f.maxSize {
+		return false
+	}
+	return true
+}
+
+// Depth returns the depth of an item, given its path relative to the scanned folder. The items directly in
+// the scanned folder have depth zero, e.g. `foo.jpg` or `foo`, the ones in their subfolders depth one, e.g.
+// `foo/bar.jpg`, and so on. The scanned folder itself, `.`, has depth -1.
+func Depth(fp string) int {
+	p := strings.Trim(filepath.ToSlash(filepath.Clean(fp)), "/")
+	if p == "" || p == "." {
+		return -1
+	}
+	return strings.Count(p, "/")
+}
+
+// isAllowedDepth returns if the depth is between the configured bounds.
+func (f Filter) isAllowedDepth(depth int) bool {
+	if depth < f.minDepth {
+		return false
+	}
+	if f.maxDepth >= 0 && depth > f.maxDepth {
+		return false
+	}
+	return true
+}
+
+// normalize returns the path to be matched against the patterns, once the Filter options are applied.
+func (f Filter) normalize(fp string) string {
+	fp = f.unicodeForm.Path(fp)
+	if f.caseInsensitive {
+		return strings.ToLower(fp)
+	}
+	return fp
 }
 
 // validate returns error if allowedList or excludedList are not valid.
 func (f Filter) validate() error {
+	if f.minSize < 0 || f.maxSize < 0 {
+		return fmt.Errorf("size bounds could not be negative: min=%d, max=%d", f.minSize, f.maxSize)
+	}
+	if f.maxSize > 0 && f.minSize > f.maxSize {
+		return fmt.Errorf("minimum size is greater than maximum size: min=%d, max=%d", f.minSize, f.maxSize)
+	}
+	if f.minDepth < 0 {
+		return fmt.Errorf("depth bounds could not be negative: min=%d, max=%d", f.minDepth, f.maxDepth)
+	}
+	if f.maxDepth >= 0 && f.minDepth > f.maxDepth {
+		return fmt.Errorf("minimum depth is greater than maximum depth: min=%d, max=%d", f.minDepth, f.maxDepth)
+	}
 	if err := validatePatternList(f.allowedList); err != nil {
 		return fmt.Errorf("include patterns are invalid: %w", err)
 	}
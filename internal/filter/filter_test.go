@@ -1,9 +1,17 @@
 package filter_test
 
 import (
+	"bytes"
+	"os"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
 
 	"github.com/gphotosuploader/gphotos-uploader-cli/internal/filter"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/pathnorm"
 )
 
 func TestCompile(t *testing.T) {
@@ -65,6 +73,10 @@ func TestFilter_AllowDefaultFiles(t *testing.T) {
 		file string
 		out  bool
 	}{
+		{"testdata/IMG_0001.heic", true},
+		{"testdata/IMG_0001.HEIC", true},
+		{"testdata/IMG_0001.heif", true},
+		{"testdata/IMG_0001.HEIF", true},
 		{"testdata/SampleAudio.mp3", false},
 		{"testdata/SampleJPGImage.jpg", true},
 		{"testdata/SamplePNGImage.png", true},
@@ -405,3 +417,711 @@ func TestFilter_ExcludingAFolder(t *testing.T) {
 	})
 
 }
+
+func TestCompileWithOptions_CaseInsensitive(t *testing.T) {
+	var testCases = []struct {
+		name         string
+		allowedList  []string
+		excludedList []string
+		file         string
+		out          bool
+	}{
+		{"lower case extension on default patterns", []string{""}, []string{""}, "testdata/DSC1234.jpg", true},
+		{"upper case extension on default patterns", []string{""}, []string{""}, "testdata/DSC1234.JPG", true},
+		{"mixed case extension on default patterns", []string{""}, []string{""}, "testdata/photo.Jpeg", true},
+		{"mixed case extension on tagged pattern", []string{"_IMAGE_EXTENSIONS_"}, []string{""}, "testdata/photo.pNg", true},
+		{"upper case pattern matches lower case file", []string{"**/*.PNG"}, []string{""}, "testdata/photo.png", true},
+		{"lower case pattern matches mixed case file", []string{"**/*.png"}, []string{""}, "testdata/photo.PnG", true},
+		{"mixed case extension is excluded", []string{"_ALL_FILES_"}, []string{"**/*.mp4"}, "testdata/SampleVideo.Mp4", false},
+		{"mixed case folder is excluded", []string{"_ALL_FILES_"}, []string{"**/folder1/*"}, "testdata/Folder1/photo.jpg", false},
+		{"non image file is not allowed", []string{""}, []string{""}, "testdata/SampleText.TXT", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := filter.CompileWithOptions(tc.allowedList, tc.excludedList, filter.FilterOptions{CaseInsensitive: true})
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %v", err)
+			}
+			if got := f.IsAllowed(tc.file); tc.out != got {
+				t.Errorf("IsAllowed result was not expected: file=%s, want %t, got %t", tc.file, tc.out, got)
+			}
+			// IsExcluded should be consistent with IsAllowed for files matching the allowed list.
+			if got := f.IsExcluded(tc.file); tc.out && got {
+				t.Errorf("IsExcluded result was not expected: file=%s, want %t, got %t", tc.file, false, got)
+			}
+		})
+	}
+}
+
+func TestCompileWithOptions_CaseSensitive(t *testing.T) {
+	f, err := filter.CompileWithOptions([]string{"**/*.png"}, []string{"**/*.mp4"}, filter.FilterOptions{})
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %v", err)
+	}
+	if f.IsAllowed("testdata/photo.PNG") {
+		t.Errorf("Filter result was not expected: file=%s, want %t, got %t", "testdata/photo.PNG", false, true)
+	}
+	if f.IsExcluded("testdata/SampleVideo.MP4") {
+		t.Errorf("Filter result was not expected: file=%s, want %t, got %t", "testdata/SampleVideo.MP4", false, true)
+	}
+}
+
+func TestCompileWithOptions_UnicodeForm(t *testing.T) {
+	// nfc and nfd are the same folder name, "Café", composed and decomposed.
+	const nfc, nfd = "Caf\u00e9", "Cafe\u0301"
+	var testCases = []struct {
+		name        string
+		form        pathnorm.Form
+		pattern     string
+		file        string
+		wantAllowed bool
+	}{
+		{"NFD file matches NFC pattern by default", "", nfc + "/**", nfd + "/IMG_0001.jpg", true},
+		{"NFC file matches NFD pattern by default", "", nfd + "/**", nfc + "/IMG_0001.jpg", true},
+		{"NFD file matches NFC pattern in NFD", pathnorm.NFD, nfc + "/**", nfd + "/IMG_0001.jpg", true},
+		{"NFD file doesn't match NFC pattern when it's off", pathnorm.Off, nfc + "/**", nfd + "/IMG_0001.jpg", false},
+		{"NFD file matches NFD pattern when it's off", pathnorm.Off, nfd + "/**", nfd + "/IMG_0001.jpg", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := filter.CompileWithOptions([]string{tc.pattern}, []string{"**/" + nfc + "/private/**"}, filter.FilterOptions{UnicodeForm: tc.form})
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %v", err)
+			}
+			if got := f.IsAllowed(tc.file); tc.wantAllowed != got {
+				t.Errorf("IsAllowed result was not expected: file=%q, want %t, got %t", tc.file, tc.wantAllowed, got)
+			}
+		})
+	}
+
+	// both forms of the same name get the same decision, by the same pattern.
+	f, err := filter.CompileWithOptions([]string{"_ALL_FILES_"}, []string{"**/" + nfc + "/private/**"}, filter.FilterOptions{})
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %v", err)
+	}
+	for _, name := range []string{nfc, nfd} {
+		if want, got := "excluded by exclude pattern '**/"+nfc+"/private/**' (#0)", f.Explain("2020/"+name+"/private/IMG_0001.jpg").String(); want != got {
+			t.Errorf("want: %v, got: %v", want, got)
+		}
+		if f.IsAllowedDir("2020/" + name + "/private/2021") {
+			t.Errorf("IsAllowedDir result was not expected: dir=%q, want %t, got %t", name, false, true)
+		}
+	}
+}
+
+func TestCompileWithOptions_IncludeAll(t *testing.T) {
+	var testCases = []struct {
+		name string
+		file string
+		want filter.FilterResult
+	}{
+		{"file matching the include patterns is allowed", "testdata/SamplePNGImage.png", filter.FilterResult{Allowed: true, Index: -1}},
+		{"file not matching the include patterns is allowed", "testdata/SampleText.txt", filter.FilterResult{Allowed: true, Index: -1}},
+		{"excluded file is not allowed", "testdata/ScreenShotPNG.png", filter.FilterResult{Excluded: true, Pattern: "**/ScreenShot*", Index: 0}},
+	}
+
+	f, err := filter.CompileWithOptions([]string{"**/*.png"}, []string{"**/ScreenShot*"}, filter.FilterOptions{IncludeAll: true})
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %v", err)
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := f.Explain(tc.file)
+			if tc.want != got {
+				t.Errorf("Explain result was not expected: file=%s, want %+v, got %+v", tc.file, tc.want, got)
+			}
+			if got.Allowed != f.IsAllowed(tc.file) {
+				t.Errorf("Explain is not consistent with IsAllowed: file=%s, want %t, got %t", tc.file, f.IsAllowed(tc.file), got.Allowed)
+			}
+		})
+	}
+}
+
+func TestFilter_IsAllowedFile(t *testing.T) {
+	var testCases = []struct {
+		name    string
+		file    string
+		size    int64
+		minSize int64
+		maxSize int64
+		out     bool
+	}{
+		{"unbounded size", "testdata/SampleJPGImage.jpg", 0, 0, 0, true},
+		{"size below MinSize", "testdata/SampleJPGImage.jpg", 0, 1, 0, false},
+		{"size exactly MinSize", "testdata/SampleJPGImage.jpg", 1, 1, 0, true},
+		{"size exactly MaxSize", "testdata/SampleJPGImage.jpg", 200, 0, 200, true},
+		{"size above MaxSize", "testdata/SampleJPGImage.jpg", 201, 0, 200, false},
+		{"size between bounds", "testdata/SampleJPGImage.jpg", 100, 1, 200, true},
+		{"pattern matches but fails size check", "testdata/SamplePNGImage.png", 300, 1, 200, false},
+		{"size is ok but pattern does not match", "testdata/SampleText.txt", 100, 1, 200, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := filter.CompileWithOptions([]string{""}, []string{""}, filter.FilterOptions{MinSize: tc.minSize, MaxSize: tc.maxSize})
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %v", err)
+			}
+			got := f.IsAllowedFile(tc.file, mockedFileInfo{size: tc.size})
+			if tc.out != got {
+				t.Errorf("Filter result was not expected: file=%s, size=%d, want %t, got %t", tc.file, tc.size, tc.out, got)
+			}
+		})
+	}
+}
+
+func TestCompileWithOptions_InvalidSize(t *testing.T) {
+	testCases := []struct {
+		name    string
+		minSize int64
+		maxSize int64
+	}{
+		{name: "negative MinSize", minSize: -1, maxSize: 0},
+		{name: "negative MaxSize", minSize: 0, maxSize: -1},
+		{name: "MinSize greater than MaxSize", minSize: 200, maxSize: 100},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := filter.CompileWithOptions([]string{""}, []string{""}, filter.FilterOptions{MinSize: tc.minSize, MaxSize: tc.maxSize})
+			if err == nil {
+				t.Errorf("error was expected, but not produced")
+			}
+		})
+	}
+}
+
+func TestFilter_Depth(t *testing.T) {
+	maxDepth := func(d int) *int { return &d }
+	var testCases = []struct {
+		name     string
+		minDepth int
+		maxDepth *int
+		path     string
+		isDir    bool
+		out      bool
+	}{
+		{"unbounded depth", 0, nil, "a/b/c/d/foo.jpg", false, true},
+		{"file in the root with MaxDepth 0", 0, maxDepth(0), "foo.jpg", false, true},
+		{"file in a folder with MaxDepth 0", 0, maxDepth(0), "a/foo.jpg", false, false},
+		{"file exactly MaxDepth", 0, maxDepth(1), "a/foo.jpg", false, true},
+		{"file deeper than MaxDepth", 0, maxDepth(1), "a/b/foo.jpg", false, false},
+		{"file shallower than MinDepth", 1, nil, "foo.jpg", false, false},
+		{"file exactly MinDepth", 1, nil, "a/foo.jpg", false, true},
+		{"file between bounds", 1, maxDepth(2), "a/b/foo.jpg", false, true},
+		{"root is scanned with MaxDepth 0", 0, maxDepth(0), ".", true, true},
+		{"folder is pruned with MaxDepth 0", 0, maxDepth(0), "a", true, false},
+		{"folder whose files are MaxDepth is scanned", 0, maxDepth(1), "a", true, true},
+		{"folder whose files are deeper than MaxDepth is pruned", 0, maxDepth(1), "a/b", true, false},
+		{"folder shallower than MinDepth is scanned", 2, nil, "a", true, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := filter.CompileWithOptions([]string{"**"}, []string{""}, filter.FilterOptions{MinDepth: tc.minDepth, MaxDepth: tc.maxDepth})
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %v", err)
+			}
+			got := f.IsAllowed(tc.path)
+			if tc.isDir {
+				got = f.IsAllowedDir(tc.path)
+			}
+			if tc.out != got {
+				t.Errorf("Filter result was not expected: path=%s, want %t, got %t", tc.path, tc.out, got)
+			}
+			if !tc.isDir && f.Explain(tc.path).Allowed != got {
+				t.Errorf("want: %t, got: %t", got, f.Explain(tc.path).Allowed)
+			}
+		})
+	}
+}
+
+func TestCompileWithOptions_InvalidDepth(t *testing.T) {
+	negative, one := -1, 1
+	testCases := []struct {
+		name     string
+		minDepth int
+		maxDepth *int
+	}{
+		{name: "negative MinDepth", minDepth: -1},
+		{name: "negative MaxDepth", maxDepth: &negative},
+		{name: "MinDepth greater than MaxDepth", minDepth: 2, maxDepth: &one},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := filter.CompileWithOptions([]string{""}, []string{""}, filter.FilterOptions{MinDepth: tc.minDepth, MaxDepth: tc.maxDepth})
+			if err == nil {
+				t.Errorf("error was expected, but not produced")
+			}
+		})
+	}
+}
+
+type mockedFileInfo struct {
+	size int64
+}
+
+func (m mockedFileInfo) Name() string       { return "" }
+func (m mockedFileInfo) Size() int64        { return m.size }
+func (m mockedFileInfo) Mode() os.FileMode  { return 0644 }
+func (m mockedFileInfo) ModTime() time.Time { return time.Time{} }
+func (m mockedFileInfo) IsDir() bool        { return false }
+func (m mockedFileInfo) Sys() interface{}   { return nil }
+
+func TestFilter_ExcludingWithNegation(t *testing.T) {
+	var testCases = []struct {
+		name         string
+		excludedList []string
+		file         string
+		out          bool
+	}{
+		{"excluded folder", []string{"folder1/**", "!folder1/keepers/**"}, "folder1/SampleJPGImage.jpg", true},
+		{"negation after exclusion re-includes", []string{"folder1/**", "!folder1/keepers/**"}, "folder1/keepers/SampleJPGImage.jpg", false},
+		{"negation before exclusion is overridden", []string{"!folder1/keepers/**", "folder1/**"}, "folder1/keepers/SampleJPGImage.jpg", true},
+		{"negation without previous exclusion", []string{"!folder1/keepers/**"}, "folder1/keepers/SampleJPGImage.jpg", false},
+		{"not matching item", []string{"folder1/**", "!folder1/keepers/**"}, "folder2/SampleJPGImage.jpg", false},
+		{"re-exclusion after negation", []string{"folder1/**", "!folder1/keepers/**", "**/*.png"}, "folder1/keepers/SamplePNGImage.png", true},
+		{"negated tagged pattern", []string{"folder1/**", "!_IMAGE_EXTENSIONS_"}, "folder1/SampleJPGImage.jpg", false},
+		{"negated tagged pattern does not match", []string{"folder1/**", "!_IMAGE_EXTENSIONS_"}, "folder1/SampleText.txt", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := filter.Compile([]string{"_ALL_FILES_"}, tc.excludedList)
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %v", err)
+			}
+			if got := f.IsExcluded(tc.file); tc.out != got {
+				t.Errorf("Filter result was not expected: file=%s, want %t, got %t", tc.file, tc.out, got)
+			}
+			if got := f.IsAllowed(tc.file); tc.out == got {
+				t.Errorf("IsAllowed result was not expected: file=%s, want %t, got %t", tc.file, !tc.out, got)
+			}
+		})
+	}
+}
+
+func TestFilter_MatchExcluded(t *testing.T) {
+	var testCases = []struct {
+		name         string
+		file         string
+		wantExcluded bool
+		wantMatched  bool
+	}{
+		{"excluded", "folder1/SampleJPGImage.jpg", true, true},
+		{"re-included by negated pattern", "folder1/keepers/SampleJPGImage.jpg", false, true},
+		{"not matching any pattern", "folder2/SampleJPGImage.jpg", false, false},
+	}
+
+	f, err := filter.Compile([]string{"_ALL_FILES_"}, []string{"folder1/**", "!folder1/keepers/**"})
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %v", err)
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			excluded, matched := f.MatchExcluded(tc.file)
+			if tc.wantExcluded != excluded || tc.wantMatched != matched {
+				t.Errorf("MatchExcluded result was not expected: file=%s, want (%t, %t), got (%t, %t)", tc.file, tc.wantExcluded, tc.wantMatched, excluded, matched)
+			}
+			if excluded != f.IsExcluded(tc.file) {
+				t.Errorf("MatchExcluded is not consistent with IsExcluded: file=%s, want %t, got %t", tc.file, f.IsExcluded(tc.file), excluded)
+			}
+		})
+	}
+}
+
+func TestFilter_Explain(t *testing.T) {
+	var testCases = []struct {
+		name string
+		file string
+		want filter.FilterResult
+	}{
+		{"allowed by first include pattern", "testdata/SamplePNGImage.png", filter.FilterResult{Allowed: true, Pattern: "**/*.png", Index: 0}},
+		{"allowed by second include pattern", "testdata/SampleJPGImage.jpg", filter.FilterResult{Allowed: true, Pattern: "**/*.jpg", Index: 1}},
+		{"not matching any include pattern", "testdata/SampleText.txt", filter.FilterResult{Index: -1}},
+		{"excluded by first exclude pattern", "testdata/ScreenShotPNG.png", filter.FilterResult{Excluded: true, Pattern: "**/ScreenShot*", Index: 0}},
+		{"excluded by last matching exclude pattern", "folder1/SampleJPGImage.jpg", filter.FilterResult{Excluded: true, Pattern: "folder1/**", Index: 1}},
+		{"re-included by negated exclude pattern", "folder1/keepers/SampleJPGImage.jpg", filter.FilterResult{Allowed: true, Pattern: "**/*.jpg", Index: 1}},
+	}
+
+	f, err := filter.Compile([]string{"**/*.png", "**/*.jpg"}, []string{"**/ScreenShot*", "folder1/**", "!folder1/keepers/**"})
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %v", err)
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := f.Explain(tc.file)
+			if tc.want != got {
+				t.Errorf("Explain result was not expected: file=%s, want %+v, got %+v", tc.file, tc.want, got)
+			}
+			if got.Allowed != f.IsAllowed(tc.file) {
+				t.Errorf("Explain is not consistent with IsAllowed: file=%s, want %t, got %t", tc.file, f.IsAllowed(tc.file), got.Allowed)
+			}
+		})
+	}
+}
+
+func TestFilter_Patterns(t *testing.T) {
+	f, err := filter.Compile([]string{"_RAW_EXTENSIONS_", "**/*.jpg"}, []string{"folder1/**"})
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %v", err)
+	}
+	allowed, excluded := f.Patterns()
+	if len(allowed) < 3 || len(excluded) != 1 {
+		t.Fatalf("want: the tagged patterns resolved, got: %v, %v", allowed, excluded)
+	}
+	// the indexes of the results are the positions in the patterns.
+	got := f.Explain("SampleJPGImage.jpg")
+	if allowed[got.Index] != "**/*.jpg" || got.Pattern != "**/*.jpg" {
+		t.Errorf("want: %s, got: %s (#%d)", "**/*.jpg", allowed[got.Index], got.Index)
+	}
+	got = f.Explain("folder1/SampleJPGImage.jpg")
+	if excluded[got.Index] != "folder1/**" {
+		t.Errorf("want: %s, got: %s (#%d)", "folder1/**", excluded[got.Index], got.Index)
+	}
+}
+
+func TestFilter_AllowRAWFiles(t *testing.T) {
+	var testCases = []struct {
+		file string
+		out  bool
+	}{
+		{"testdata/SampleRAWImage.arw", true},
+		{"testdata/SampleRAWImage.cr2", true},
+		{"testdata/SampleRAWImage.cr3", true},
+		{"testdata/SampleRAWImage.dng", true},
+		{"testdata/SampleRAWImage.nef", true},
+		{"testdata/SampleRAWImage.orf", true},
+		{"testdata/SampleRAWImage.raf", true},
+		{"testdata/SampleRAWImage.rw2", true},
+		{"testdata/SampleRAWImage.NEF", true},
+		{"testdata/SampleJPGImage.jpg", false},
+		{"testdata/SamplePNGImage.png", false},
+		{"testdata/SampleVideo.mp4", false},
+		{"testdata/SampleText.txt", false},
+	}
+
+	f, err := filter.Compile([]string{"_RAW_EXTENSIONS_"}, []string{""})
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %v", err)
+	}
+	for _, tc := range testCases {
+		got := f.IsAllowed(tc.file)
+		if tc.out != got {
+			t.Errorf("Filter result was not expected: file=%s, want %t, got %t", tc.file, tc.out, got)
+		}
+	}
+}
+
+func TestFilter_AllowPhotoFiles(t *testing.T) {
+	var testCases = []struct {
+		file string
+		out  bool
+	}{
+		{"testdata/SampleRAWImage.cr2", true},
+		{"testdata/SampleRAWImage.DNG", true},
+		{"testdata/SampleJPGImage.jpg", true},
+		{"testdata/SamplePNGImage.PNG", true},
+		{"testdata/SampleSVGImage.svg", false},
+		{"testdata/SampleVideo.mp4", false},
+		{"testdata/SampleText.txt", false},
+	}
+
+	f, err := filter.Compile([]string{"_PHOTO_EXTENSIONS_"}, []string{""})
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %v", err)
+	}
+	for _, tc := range testCases {
+		got := f.IsAllowed(tc.file)
+		if tc.out != got {
+			t.Errorf("Filter result was not expected: file=%s, want %t, got %t", tc.file, tc.out, got)
+		}
+	}
+}
+
+func TestFilter_AllowVideoFiles(t *testing.T) {
+	var testCases = []struct {
+		file string
+		out  bool
+	}{
+		{"testdata/SampleVideo.mp4", true},
+		{"testdata/SampleVideo.mov", true},
+		{"testdata/SampleVideo.MOV", true},
+		{"testdata/SampleVideo.m4v", true},
+		{"testdata/SampleVideo.mkv", true},
+		{"testdata/SampleVideo.avi", true},
+		{"testdata/SampleVideo.mpg", true},
+		{"testdata/SampleVideo.3gp", true},
+		{"testdata/SampleVideo.webm", true},
+		{"testdata/SampleJPGImage.jpg", false},
+		{"testdata/SampleAudio.mp3", false},
+	}
+
+	f, err := filter.Compile([]string{"_VIDEO_EXTENSIONS_"}, []string{""})
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %v", err)
+	}
+	for _, tc := range testCases {
+		got := f.IsAllowed(tc.file)
+		if tc.out != got {
+			t.Errorf("Filter result was not expected: file=%s, want %t, got %t", tc.file, tc.out, got)
+		}
+	}
+}
+
+func TestFilter_AllowMediaFiles(t *testing.T) {
+	var testCases = []struct {
+		file string
+		out  bool
+	}{
+		{"testdata/SampleVideo.mp4", true},
+		{"testdata/SampleVideo.MOV", true},
+		{"testdata/SampleVideo.Mov", true},
+		{"testdata/SampleJPGImage.jpg", true},
+		{"testdata/SamplePNGImage.Png", true},
+		{"testdata/SampleRAWImage.cr2", false},
+		{"testdata/SampleAudio.mp3", false},
+		{"testdata/SampleText.txt", false},
+	}
+
+	f, err := filter.CompileWithOptions([]string{"_MEDIA_EXTENSIONS_"}, []string{""}, filter.FilterOptions{CaseInsensitive: true})
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %v", err)
+	}
+	for _, tc := range testCases {
+		got := f.IsAllowed(tc.file)
+		if tc.out != got {
+			t.Errorf("Filter result was not expected: file=%s, want %t, got %t", tc.file, tc.out, got)
+		}
+	}
+}
+
+func TestFilter_RegexpPatterns(t *testing.T) {
+	var testCases = []struct {
+		name         string
+		allowedList  []string
+		excludedList []string
+		file         string
+		out          bool
+	}{
+		{"regexp in allowed list", []string{`re:IMG_\d{8}_.*\.jpg$`}, []string{""}, "testdata/IMG_20200101_beach.jpg", true},
+		{"regexp in allowed list does not match", []string{`re:IMG_\d{8}_.*\.jpg$`}, []string{""}, "testdata/IMG_2020_beach.jpg", false},
+		{"glob and regexp in allowed list", []string{"**/*.png", `re:IMG_\d{8}_.*\.jpg$`}, []string{""}, "testdata/SamplePNGImage.png", true},
+		{"regexp in excluded list", []string{"_ALL_FILES_"}, []string{`re:IMG_\d{8}_.*\.jpg$`}, "testdata/IMG_20200101_beach.jpg", false},
+		{"glob and regexp in excluded list", []string{"_ALL_FILES_"}, []string{"**/*.png", `re:^folder1/`}, "folder1/SampleJPGImage.jpg", false},
+		{"glob and regexp in excluded list does not match", []string{"_ALL_FILES_"}, []string{"**/*.png", `re:^folder1/`}, "folder2/SampleJPGImage.jpg", true},
+		{"negated regexp in excluded list", []string{"_ALL_FILES_"}, []string{"folder1/**", `!re:^folder1/keepers/`}, "folder1/keepers/SampleJPGImage.jpg", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := filter.Compile(tc.allowedList, tc.excludedList)
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %v", err)
+			}
+			if got := f.IsAllowed(tc.file); tc.out != got {
+				t.Errorf("Filter result was not expected: file=%s, want %t, got %t", tc.file, tc.out, got)
+			}
+		})
+	}
+
+	t.Run("case-insensitive regexp keeps its meaning", func(t *testing.T) {
+		f, err := filter.CompileWithOptions([]string{`re:IMG_\D+\.jpg$`}, []string{""}, filter.FilterOptions{CaseInsensitive: true})
+		if err != nil {
+			t.Fatalf("error was not expected at this point: %v", err)
+		}
+		if !f.IsAllowed("testdata/img_beach.JPG") {
+			t.Errorf("Filter result was not expected: file=%s, want %t, got %t", "testdata/img_beach.JPG", true, false)
+		}
+		if f.IsAllowed("testdata/IMG_1234.jpg") {
+			t.Errorf("Filter result was not expected: file=%s, want %t, got %t", "testdata/IMG_1234.jpg", false, true)
+		}
+	})
+}
+
+func TestCompile_InvalidRegexp(t *testing.T) {
+	testCases := []struct {
+		name         string
+		allowedList  []string
+		excludedList []string
+	}{
+		{name: "invalid regexp in allowed list", allowedList: []string{"**/*.png", "re:IMG_(\\d"}, excludedList: []string{""}},
+		{name: "invalid regexp in excluded list", allowedList: []string{""}, excludedList: []string{"re:IMG_(\\d"}},
+		{name: "invalid negated regexp in excluded list", allowedList: []string{""}, excludedList: []string{"!re:IMG_(\\d"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := filter.Compile(tc.allowedList, tc.excludedList)
+			if err == nil {
+				t.Fatalf("error was expected, but not produced")
+			}
+			if !strings.Contains(err.Error(), "IMG_(\\d") {
+				t.Errorf("error should name the invalid pattern, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestFilter_MatchRelativePaths(t *testing.T) {
+	var testCases = []struct {
+		name        string
+		allowedList []string
+		file        string
+		out         bool
+	}{
+		{"recursive wildcard under folder matches direct child", []string{"foo/**/*.jpg"}, "foo/SampleJPGImage.jpg", true},
+		{"recursive wildcard under folder matches nested child", []string{"foo/**/*.jpg"}, "foo/bar/baz/SampleJPGImage.jpg", true},
+		{"recursive wildcard under folder does not match other folder", []string{"foo/**/*.jpg"}, "bar/foo/SampleJPGImage.jpg", false},
+		{"folder anywhere matches direct child", []string{"**/foo/*.jpg"}, "bar/foo/SampleJPGImage.jpg", true},
+		{"folder anywhere matches top-level folder", []string{"**/foo/*.jpg"}, "foo/SampleJPGImage.jpg", true},
+		{"folder anywhere does not match nested child", []string{"**/foo/*.jpg"}, "foo/bar/SampleJPGImage.jpg", false},
+		{"pattern with no slash matches top-level item", []string{"*.jpg"}, "SampleJPGImage.jpg", true},
+		{"pattern with no slash does not match nested item", []string{"*.jpg"}, "foo/SampleJPGImage.jpg", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := filter.Compile(tc.allowedList, []string{""})
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %v", err)
+			}
+			if got := f.IsAllowed(tc.file); tc.out != got {
+				t.Errorf("Filter result was not expected: file=%s, want %t, got %t", tc.file, tc.out, got)
+			}
+		})
+	}
+
+	t.Run("excluding a folder prefix", func(t *testing.T) {
+		f, err := filter.Compile([]string{"2023/**/*.jpg"}, []string{"2023/private/**"})
+		if err != nil {
+			t.Fatalf("error was not expected at this point: %v", err)
+		}
+		for file, want := range map[string]bool{
+			"2023/SampleJPGImage.jpg":              true,
+			"2023/trips/SampleJPGImage.jpg":        true,
+			"2023/private/SampleJPGImage.jpg":      false,
+			"2023/private/deep/SampleJPGImage.jpg": false,
+			"2022/SampleJPGImage.jpg":              false,
+		} {
+			if got := f.IsAllowed(file); want != got {
+				t.Errorf("Filter result was not expected: file=%s, want %t, got %t", file, want, got)
+			}
+		}
+	})
+}
+
+func TestFilter_IsAllowedDir(t *testing.T) {
+	var testCases = []struct {
+		dir string
+		out bool
+	}{
+		{"folder1", false},
+		{"folder2", true},
+		{"folder2/folder1", false},
+		{"photos", true},
+	}
+
+	// include patterns doesn't match any directory, but they should not be pruned.
+	f, err := filter.Compile([]string{"**/*.jpg"}, []string{"**/folder1"})
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %v", err)
+	}
+	for _, tc := range testCases {
+		got := f.IsAllowedDir(tc.dir)
+		if tc.out != got {
+			t.Errorf("Filter result was not expected: dir=%s, want %t, got %t", tc.dir, tc.out, got)
+		}
+	}
+}
+
+func TestFilter_WindowsPathSeparators(t *testing.T) {
+	f, err := filter.Compile([]string{"foo/*.jpg", "**/trips/**"}, []string{"foo/private*.jpg", "**/trips/private/**"})
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %v", err)
+	}
+
+	var testCases = []struct {
+		slash     string
+		backslash string
+		out       bool
+	}{
+		{"foo/bar.jpg", `foo\bar.jpg`, true},
+		{"foo/private.jpg", `foo\private.jpg`, false},
+		{"foo/baz/bar.jpg", `foo\baz\bar.jpg`, false},
+		{"C:/photos/trips/bar.jpg", `C:\photos\trips\bar.jpg`, true},
+		{"C:/photos/trips/private/bar.jpg", `C:\photos\trips\private\bar.jpg`, false},
+		{"//server/share/trips/bar.jpg", `\\server\share\trips\bar.jpg`, true},
+		{"//server/share/trips/private/bar.jpg", `\\?\UNC\server\share\trips\private\bar.jpg`, false},
+		{"C:/trips/bar.jpg", `\\?\C:\trips\bar.jpg`, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.slash, func(t *testing.T) {
+			if got := f.IsAllowed(tc.slash); tc.out != got {
+				t.Errorf("Filter result was not expected: file=%s, want %t, got %t", tc.slash, tc.out, got)
+			}
+			if got := f.IsAllowed(tc.backslash); tc.out != got {
+				t.Errorf("Filter result was not expected: file=%s, want %t, got %t", tc.backslash, tc.out, got)
+			}
+			if want, got := f.IsExcluded(tc.slash), f.IsExcluded(tc.backslash); want != got {
+				t.Errorf("Filter exclusion was not expected: file=%s, want %t, got %t", tc.backslash, want, got)
+			}
+		})
+	}
+}
+
+func TestFilter_LogsDecisions(t *testing.T) {
+	testCases := []struct {
+		name         string
+		debugModules []string
+		want         []string
+	}{
+		{"Should log decisions if the module is enabled", []string{log.ModuleFilter}, []string{
+			`'foo.jpg' is allowed by include pattern '**/*.jpg' (#0)`,
+			`'foo.png' is not allowed, no include pattern matches`,
+			`'tmp/foo.jpg' is excluded by exclude pattern 'tmp/**' (#0)`,
+			`directory 'tmp' is excluded by exclude pattern 'tmp' (#1)`,
+		}},
+		{"Should not log decisions if other module is enabled", []string{log.ModuleScan}, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			logger := log.NewLeveledLogger(log.NewJSONLogger(&out), logrus.InfoLevel, tc.debugModules)
+			f, err := filter.CompileWithOptions([]string{"**/*.jpg"}, []string{"tmp/**", "tmp"}, filter.FilterOptions{Logger: log.WithModule(logger, log.ModuleFilter)})
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+
+			f.IsAllowed("foo.jpg")
+			f.IsAllowed("foo.png")
+			f.IsAllowed("tmp/foo.jpg")
+			f.IsAllowedDir("tmp")
+			f.IsAllowedDir("photos")
+
+			lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+			if len(tc.want) == 0 {
+				if out.Len() > 0 {
+					t.Errorf("want: no output, got: %s", out.String())
+				}
+				return
+			}
+			if len(lines) != len(tc.want) {
+				t.Fatalf("want: %d lines, got: %d, output: %s", len(tc.want), len(lines), out.String())
+			}
+			for i, want := range tc.want {
+				if !strings.Contains(lines[i], want) || !strings.Contains(lines[i], `"module":"filter"`) {
+					t.Errorf("want: %s, got: %s", want, lines[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package filter
+
+import (
+	"fmt"
+)
+
+// FilterSet holds named filter fragments that could be combined into a single Filter.
+// It allows to define shared rules once (e.g. "never upload screenshots") and reuse them.
+type FilterSet struct {
+	fragments map[string]fragment
+}
+
+// fragment represents the patterns of a named filter in the FilterSet.
+type fragment struct {
+	allowedList  []string
+	excludedList []string
+}
+
+// NewFilterSet returns an empty FilterSet.
+func NewFilterSet() *FilterSet {
+	return &FilterSet{
+		fragments: make(map[string]fragment),
+	}
+}
+
+// Add stores the patterns of a filter under the specified name, replacing any previous one.
+// Patterns are not validated until they are combined (see Combine).
+func (s *FilterSet) Add(name string, allowedList []string, excludedList []string) {
+	s.fragments[name] = fragment{
+		allowedList:  allowedList,
+		excludedList: excludedList,
+	}
+}
+
+// Combine returns a Filter whose allowed list is the union of the allowed lists of the named
+// filters, and whose excluded list is the union of the excluded lists of the named filters.
+// Patterns are kept in the order of names, which matters for negated exclude patterns.
+// It returns error if any name is unknown or the combined patterns are not valid.
+func (s FilterSet) Combine(names ...string) (*Filter, error) {
+	var allowedList, excludedList []string
+	for _, name := range names {
+		f, exist := s.fragments[name]
+		if !exist {
+			return nil, fmt.Errorf("filter '%s' was not found", name)
+		}
+		allowedList = append(allowedList, f.allowedList...)
+		excludedList = append(excludedList, f.excludedList...)
+	}
+	return Compile(allowedList, excludedList)
+}
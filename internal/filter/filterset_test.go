@@ -0,0 +1,61 @@
+package filter_test
+
+import (
+	"testing"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/filter"
+)
+
+func TestFilterSet_Combine(t *testing.T) {
+	var testCases = []struct {
+		file string
+		out  bool
+	}{
+		{"testdata/SampleJPGImage.jpg", true},
+		{"testdata/SampleVideo.mp4", true},
+		{"testdata/SampleText.txt", false},
+		{"testdata/ScreenShotJPG.jpg", false},
+		{"testdata/private/SampleJPGImage.jpg", false},
+	}
+
+	fs := filter.NewFilterSet()
+	fs.Add("images", []string{"_IMAGE_EXTENSIONS_"}, []string{""})
+	fs.Add("videos", []string{"_ALL_VIDEO_FILES_"}, []string{""})
+	fs.Add("no-screenshots", []string{}, []string{"**/ScreenShot*"})
+	fs.Add("no-private", []string{}, []string{"**/private/**"})
+
+	f, err := fs.Combine("images", "videos", "no-screenshots", "no-private")
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %v", err)
+	}
+	for _, tc := range testCases {
+		got := f.IsAllowed(tc.file)
+		if tc.out != got {
+			t.Errorf("Filter result was not expected: file=%s, want %t, got %t", tc.file, tc.out, got)
+		}
+	}
+}
+
+func TestFilterSet_CombineErrors(t *testing.T) {
+	testCases := []struct {
+		name  string
+		names []string
+	}{
+		{name: "invalid allowed fragment", names: []string{"valid", "invalid-allowed"}},
+		{name: "invalid excluded fragment", names: []string{"valid", "invalid-excluded"}},
+		{name: "unknown fragment", names: []string{"valid", "non-existent"}},
+	}
+
+	fs := filter.NewFilterSet()
+	fs.Add("valid", []string{"**/*.jpg"}, []string{"**/ScreenShot*"})
+	fs.Add("invalid-allowed", []string{"[]a]"}, []string{""})
+	fs.Add("invalid-excluded", []string{""}, []string{"[]a]"})
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := fs.Combine(tc.names...); err == nil {
+				t.Errorf("error was expected, but not produced")
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package filter
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v2"
+)
+
+// extensionPatternPrefix is the prefix of the patterns matching the files by their extension in any folder,
+// e.g. `**/*.jpg`, like the ones of the tagged patterns.
+const extensionPatternPrefix = "**/*."
+
+// globMetaChars are the characters with a special meaning in glob patterns, see doublestar.Match.
+const globMetaChars = `*?[]{}\/`
+
+// compiledPatterns is a pattern list compiled once, when the Filter is compiled, so the patterns are not
+// interpreted every time a path is matched. Paths are matched by every pattern like pathMatch does.
+//
+// Extension-only patterns, like `**/*.jpg`, which are the most common ones, are matched by looking up the
+// extensions of the file name in a set, instead of matching every one of them. Regular expressions are
+// compiled, and any other pattern is matched as a glob.
+type compiledPatterns struct {
+	// extensions are the positions of the extension-only patterns in the list, by their extension, e.g. "jpg".
+	extensions map[string]patternIndexes
+	// others are the rest of the patterns, in the order of the list.
+	others []compiledPattern
+}
+
+// patternIndexes are the positions of the first and the last patterns of the list with the same extension.
+type patternIndexes struct {
+	first int
+	last  int
+}
+
+// compiledPattern is a pattern of the list, see compilePatterns.
+type compiledPattern struct {
+	index int
+	// re is the regular expression, if the pattern is a regular expression, or glob the pattern otherwise.
+	re   *regexp.Regexp
+	glob string
+}
+
+// compilePatterns returns the patterns of the list compiled. Empty patterns are ignored. The negation prefix is
+// removed from the patterns if negatable is set, as the excluded list is matched in order, see Filter.IsExcluded.
+func compilePatterns(patternList []string, negatable bool) (compiledPatterns, error) {
+	c := compiledPatterns{extensions: make(map[string]patternIndexes)}
+	for i, pat := range patternList {
+		if pat == "" {
+			continue
+		}
+		p := pat
+		if negatable {
+			p, _ = isNegated(pat)
+		}
+		if ext, ok := extensionOnly(p); ok {
+			indexes, exist := c.extensions[ext]
+			if !exist {
+				indexes.first = i
+			}
+			indexes.last = i
+			c.extensions[ext] = indexes
+			continue
+		}
+		cp := compiledPattern{index: i, glob: p}
+		if expr, ok := isRegexp(p); ok {
+			re, err := compileRegexp(expr)
+			if err != nil {
+				return compiledPatterns{}, err
+			}
+			cp.re = re
+		}
+		c.others = append(c.others, cp)
+	}
+	return c, nil
+}
+
+// extensionOnly returns the extension of the pattern and true if it only matches files by their extension in
+// any folder, e.g. `**/*.jpg` or `**/*.tar.gz`.
+func extensionOnly(pattern string) (string, bool) {
+	if !strings.HasPrefix(pattern, extensionPatternPrefix) {
+		return "", false
+	}
+	ext := strings.TrimPrefix(pattern, extensionPatternPrefix)
+	if ext == "" || strings.ContainsAny(ext, globMetaChars) {
+		return "", false
+	}
+	return ext, true
+}
+
+// firstIndex returns the index of the first pattern matching str, or -1 if none of them matches.
+func (c compiledPatterns) firstIndex(str string) int {
+	str = toSlash(str)
+	first := -1
+	c.forEachExtension(str, func(indexes patternIndexes) {
+		if first < 0 || indexes.first < first {
+			first = indexes.first
+		}
+	})
+	for _, p := range c.others {
+		if first >= 0 && p.index > first {
+			break
+		}
+		if p.match(str) {
+			return p.index
+		}
+	}
+	return first
+}
+
+// lastIndex returns the index of the last pattern matching str, or -1 if none of them matches.
+func (c compiledPatterns) lastIndex(str string) int {
+	str = toSlash(str)
+	last := -1
+	c.forEachExtension(str, func(indexes patternIndexes) {
+		if indexes.last > last {
+			last = indexes.last
+		}
+	})
+	for i := len(c.others) - 1; i >= 0; i-- {
+		p := c.others[i]
+		if p.index < last {
+			break
+		}
+		if p.match(str) {
+			return p.index
+		}
+	}
+	return last
+}
+
+// forEachExtension calls fn with the extension-only patterns matching the name of the file of str. Every
+// suffix of the name after a dot is an extension, e.g. "tar.gz" and "gz" for "backup.tar.gz".
+func (c compiledPatterns) forEachExtension(str string, fn func(indexes patternIndexes)) {
+	if len(c.extensions) == 0 {
+		return
+	}
+	name := str[strings.LastIndex(str, "/")+1:]
+	for i := 0; i < len(name); i++ {
+		if name[i] != '.' {
+			continue
+		}
+		if indexes, ok := c.extensions[name[i+1:]]; ok {
+			fn(indexes)
+		}
+	}
+}
+
+// match returns true if str, using `/` as separator, matches the pattern.
+func (p compiledPattern) match(str string) bool {
+	if p.re != nil {
+		return p.re.MatchString(str)
+	}
+	// patterns has been validated before (see Compile), so no need to check error.
+	matched, _ := doublestar.Match(p.glob, str)
+	return matched
+}
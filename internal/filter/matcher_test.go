@@ -0,0 +1,126 @@
+package filter
+
+import (
+	"fmt"
+	"testing"
+)
+
+// matcherTestPaths are paths with every kind of name matched by the patterns of matcherTestLists.
+var matcherTestPaths = []string{
+	"IMG_0001.jpg", "IMG_0001.JPG", "foo/IMG_0001.jpg", "foo/bar/IMG_0001.jpeg", "foo\\bar\\IMG_0001.png",
+	"C:\\photos\\IMG_0001.heic", "\\\\?\\C:\\photos\\IMG_0001.gif", "\\\\server\\share\\movie.mp4",
+	"backup.tar.gz", "foo/backup.tar.gz", "foo/.jpg", "foo/jpg", "foo.jpg/bar", "foo.jpg/bar.txt",
+	"IMG_20200101_000000.jpg", "foo/IMG_20200101_000000.JPG", "@eaDir/IMG_0001.jpg", "foo/@eaDir/thumb.jpg",
+	"!IMG_0001.jpg", "foo/.DS_Store", "foo/README", "", "foo/", "a.b.c.d",
+}
+
+// matcherTestLists are pattern lists with extension-only patterns, globs, regular expressions and negations.
+var matcherTestLists = [][]string{
+	nil,
+	{""},
+	patternDictionary["_PHOTO_EXTENSIONS_"],
+	patternDictionary["_MEDIA_EXTENSIONS_"],
+	patternDictionary["_ALL_FILES_"],
+	{"**/*.jpg", "**/*.tar.gz", "**/*.gz", "**/*.jpg"},
+	{"**/@eaDir/**", "**/*.jpg", "!foo/**", "**/*.tar.gz", "!**/*.gz"},
+	{"!**/*.jpg", "**/IMG_*", "re:IMG_\\d{8}_\\d{6}\\.jpg$", "", "**/*.JPG", "re:(?i)\\.jpe?g$"},
+	{"*.jpg", "foo/*.jpg", "**/*.[jp][pn]g", "**/*.{mp4,gz}", "**/*.", "**/*.*", "**/*.c.d"},
+	{"**/.DS_Store", "!**/*.jpg", "foo/**", "!foo/backup.*", "**/*.tar.gz"},
+	toLowerList(concat(patternDictionary["_MEDIA_EXTENSIONS_"], []string{"re:IMG_\\d{8}", "!**/*.GIF"})),
+}
+
+func Test_CompiledPatterns(t *testing.T) {
+	for _, list := range matcherTestLists {
+		t.Run(fmt.Sprintf("%q", list), func(t *testing.T) {
+			allowed, err := compilePatterns(list, false)
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			excluded, err := compilePatterns(list, true)
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			for _, path := range matcherTestPaths {
+				first, last := patternIndexesOf(t, list, path)
+				if got := allowed.firstIndex(path); first != got {
+					t.Errorf("first index of %q, want: %d, got: %d", path, first, got)
+				}
+				if got := excluded.lastIndex(path); last != got {
+					t.Errorf("last index of %q, want: %d, got: %d", path, last, got)
+				}
+			}
+		})
+	}
+}
+
+// patternIndexesOf returns the indexes of the first and the last patterns of the list matching path, by matching
+// it with every pattern, or -1 if none of them matches. The negation prefix is removed for the last one, like it's
+// removed from the excluded list.
+func patternIndexesOf(t *testing.T, patternList []string, path string) (int, int) {
+	first, last := -1, -1
+	for i, pat := range patternList {
+		if pat == "" {
+			continue
+		}
+		matched, err := pathMatch(pat, path)
+		if err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+		if matched && first < 0 {
+			first = i
+		}
+		p, _ := isNegated(pat)
+		if matched, err = pathMatch(p, path); err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+		if matched {
+			last = i
+		}
+	}
+	return first, last
+}
+
+func Test_CompilePatterns_InvalidRegexp(t *testing.T) {
+	if _, err := compilePatterns([]string{"**/*.jpg", "!re:IMG_(\\d"}, true); err == nil {
+		t.Errorf("error was expected, but not produced")
+	}
+}
+
+// benchmarkPaths returns n paths of files nested in folders, with the extensions of the media files and others.
+func benchmarkPaths(n int) []string {
+	extensions := []string{"jpg", "JPG", "heic", "mp4", "MOV", "cr2", "txt", "json", "tar.gz", ""}
+	paths := make([]string, n)
+	for i := range paths {
+		paths[i] = fmt.Sprintf("%d/folder-%d/sub folder-%d/IMG_%08d.%s", 2000+i%20, i%50, i%7, i, extensions[i%len(extensions)])
+	}
+	return paths
+}
+
+// benchmarkLists are the allowed and excluded lists of a typical configuration.
+var benchmarkLists = struct {
+	allowed  []string
+	excluded []string
+}{
+	allowed:  translatePatternList([]string{"_MEDIA_EXTENSIONS_", "_RAW_EXTENSIONS_"}),
+	excluded: []string{"**/@eaDir/**", "**/.thumbnails/**", "re:\\.tmp$", "**/Thumbs.db", "2005/**", "!2005/folder-1/**"},
+}
+
+func BenchmarkCompiledPatterns(b *testing.B) {
+	paths := benchmarkPaths(10000)
+	allowed, err := compilePatterns(benchmarkLists.allowed, false)
+	if err != nil {
+		b.Fatal(err)
+	}
+	excluded, err := compilePatterns(benchmarkLists.excluded, true)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for _, path := range paths {
+			if allowed.firstIndex(path) >= 0 {
+				_ = excluded.lastIndex(path)
+			}
+		}
+	}
+}
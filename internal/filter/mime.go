@@ -0,0 +1,86 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MIMEFilter is a file filter based on allowed and excluded content types, e.g. "image/jpeg".
+// A type could be a wildcard of all the subtypes, e.g. "image/*". Types are matched regardless of their case,
+// and the parameters of the content types, like "; charset=utf-8", are ignored.
+//
+// It's applied once a file is allowed by a Filter, so it's only allowed if both allow it.
+type MIMEFilter struct {
+	allowedList  []string
+	excludedList []string
+}
+
+// NewMIMEFilter returns the MIMEFilter allowing the content types of allowedList, or all of them if it's empty,
+// except the ones of excludedList. It returns error if any of the types are not valid.
+func NewMIMEFilter(allowedList []string, excludedList []string) (*MIMEFilter, error) {
+	f := MIMEFilter{
+		allowedList:  normalizeMIMETypes(allowedList),
+		excludedList: normalizeMIMETypes(excludedList),
+	}
+	if err := validateMIMETypes(f.allowedList); err != nil {
+		return nil, fmt.Errorf("allowed MIME types are invalid: %w", err)
+	}
+	if err := validateMIMETypes(f.excludedList); err != nil {
+		return nil, fmt.Errorf("excluded MIME types are invalid: %w", err)
+	}
+	return &f, nil
+}
+
+// IsAllowed returns if a content type is allowed.
+// That means:
+//   - content type is in the allowed list, or it's empty
+//   - content type is not in the excluded list
+func (f MIMEFilter) IsAllowed(mimeType string) bool {
+	t := normalizeMIMEType(mimeType)
+	if len(f.allowedList) > 0 && !matchMIMEType(f.allowedList, t) {
+		return false
+	}
+	return !matchMIMEType(f.excludedList, t)
+}
+
+// normalizeMIMEType returns the content type without its parameters, in lower case.
+func normalizeMIMEType(mimeType string) string {
+	if i := strings.IndexByte(mimeType, ';'); i >= 0 {
+		mimeType = mimeType[:i]
+	}
+	return strings.ToLower(strings.TrimSpace(mimeType))
+}
+
+// normalizeMIMETypes returns the normalized content types of the list, see normalizeMIMEType.
+func normalizeMIMETypes(list []string) []string {
+	r := make([]string, len(list))
+	for i, t := range list {
+		r[i] = normalizeMIMEType(t)
+	}
+	return r
+}
+
+// matchMIMEType returns if any of the types of the list matches the content type.
+func matchMIMEType(list []string, mimeType string) bool {
+	for _, t := range list {
+		if t == mimeType {
+			return true
+		}
+		if prefix := strings.TrimSuffix(t, "*"); prefix != t && strings.HasPrefix(mimeType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateMIMETypes returns error if any of the types is not a "type/subtype" pair, or a "type/*" wildcard.
+func validateMIMETypes(list []string) error {
+	for _, t := range list {
+		parts := strings.Split(t, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" || strings.Contains(parts[0], "*") ||
+			(strings.Contains(parts[1], "*") && parts[1] != "*") {
+			return fmt.Errorf("invalid MIME type '%s'", t)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package filter_test
+
+import (
+	"testing"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/filter"
+)
+
+func TestNewMIMEFilter(t *testing.T) {
+	var testCases = []struct {
+		name         string
+		allowedList  []string
+		excludedList []string
+		errExpected  bool
+	}{
+		{name: "empty lists", errExpected: false},
+		{name: "valid types", allowedList: []string{"image/jpeg", "video/*"}, excludedList: []string{"image/gif"}, errExpected: false},
+		{name: "type without subtype", allowedList: []string{"jpeg"}, errExpected: true},
+		{name: "wildcard type", allowedList: []string{"*/jpeg"}, errExpected: true},
+		{name: "partial wildcard subtype", excludedList: []string{"image/x-*"}, errExpected: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := filter.NewMIMEFilter(tc.allowedList, tc.excludedList)
+			if tc.errExpected && err == nil {
+				t.Errorf("error was expected, but not produced")
+			}
+			if !tc.errExpected && err != nil {
+				t.Errorf("error was not expected at this point: %s", err)
+			}
+		})
+	}
+}
+
+func TestMIMEFilter_IsAllowed(t *testing.T) {
+	var testCases = []struct {
+		name         string
+		allowedList  []string
+		excludedList []string
+		in           string
+		out          bool
+	}{
+		{"allowed by type", []string{"image/jpeg", "image/png"}, nil, "image/png", true},
+		{"not in the allowed types", []string{"image/jpeg", "image/png"}, nil, "image/gif", false},
+		{"allowed by wildcard", []string{"video/*"}, nil, "video/mp4", true},
+		{"allowed regardless of case", []string{"Image/JPEG"}, nil, "image/jpeg", true},
+		{"allowed ignoring parameters", []string{"text/plain"}, nil, "text/plain; charset=utf-8", true},
+		{"all allowed without allowed types", nil, nil, "application/octet-stream", true},
+		{"excluded by type", nil, []string{"image/gif"}, "image/gif", false},
+		{"excluded by wildcard even if allowed", []string{"image/jpeg"}, []string{"image/*"}, "image/jpeg", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := filter.NewMIMEFilter(tc.allowedList, tc.excludedList)
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %v", err)
+			}
+			if got := f.IsAllowed(tc.in); tc.out != got {
+				t.Errorf("IsAllowed result was not expected: type=%s, want %t, got %t", tc.in, tc.out, got)
+			}
+		})
+	}
+}
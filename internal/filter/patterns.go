@@ -1,32 +1,84 @@
 package filter
 
-import "github.com/bmatcuk/doublestar/v2"
+import (
+	"fmt"
+	"regexp"
+	"strings"
 
-var patternDictionary = map[string][]string{
-	// _ALL_FILES match with all file extensions
-	"_ALL_FILES_": {"**"},
+	"github.com/bmatcuk/doublestar/v2"
+)
 
-	// _IMAGE_EXTENSIONS_ match with the supported photos file type extensions
+const (
+	// negationPrefix is the prefix used to re-include items in the excluded list.
+	negationPrefix = "!"
+
+	// regexpPrefix is the prefix used to define a regular expression instead of a glob pattern.
+	regexpPrefix = "re:"
+
+	// extendedLengthPrefix and extendedLengthUNCPrefix are the prefixes of the Windows extended-length paths,
+	// e.g. `\\?\C:\photos` or `\\?\UNC\server\share\photos`.
+	extendedLengthPrefix    = `\\?\`
+	extendedLengthUNCPrefix = `\\?\UNC\`
+)
+
+var (
+	// imageExtensions match with the supported photos file type extensions
 	// Source: https://support.google.com/photos/answer/6193313
-	"_IMAGE_EXTENSIONS_": {
-		"**/*.jpg", "**/*.jpeg", "**/*.png", "**/*.webp", "**/*.gif",
-		"**/*.JPG", "**/*.JPEG", "**/*.PNG", "**/*.WEBP", "**/*.GIF",
-	},
+	imageExtensions = []string{
+		"**/*.jpg", "**/*.jpeg", "**/*.png", "**/*.webp", "**/*.gif", "**/*.heic", "**/*.heif",
+		"**/*.JPG", "**/*.JPEG", "**/*.PNG", "**/*.WEBP", "**/*.GIF", "**/*.HEIC", "**/*.HEIF",
+	}
 
-	// _RAW_EXTENSIONS_ match with the RAW file type extensions
+	// rawExtensions match with the RAW file type extensions
 	// Source: https://support.google.com/photos/answer/6193313
 	// Source: https://en.wikipedia.org/wiki/Raw_image_format#Raw_filename_extensions_and_respective_camera_manufacturers
-	"_RAW_EXTENSIONS_": {
+	rawExtensions = []string{
 		"**/*.arw", "**/*.srf", "**/*.sr2", "**/*.crw", "**/*.cr2", "**/*.cr3", "**/*.dng", "**/*.nef", "**/*.nrw", "**/*.orf", "**/*.raf", "**/*.raw", "**/*.rw2",
 		"**/*.ARW", "**/*.SRF", "**/*.SR2", "**/*.CRW", "**/*.CR2", "**/*.CR3", "**/*.DNG", "**/*.NEF", "**/*.NRW", "**/*.ORF", "**/*.RAF", "**/*.RAW", "**/*.RW2",
-	},
+	}
 
-	// _ALL_VIDEO_FILES match with all video file extensions supported by Google Photos
+	// allVideoFiles match with all video file extensions supported by Google Photos
 	// Source: https://support.google.com/photos/answer/6193313.
-	"_ALL_VIDEO_FILES_": {
+	allVideoFiles = []string{
 		"**/*.mpg", "**/*.mod", "**/*.mmv", "**/*.tod", "**/*.wmv", "**/*.asf", "**/*.avi", "**/*.divx", "**/*.mov", "**/*.m4v", "**/*.3gp", "**/*.3g2", "**/*.mp4", "**/*.m2t", "**/*.m2ts", "**/*.mts", "**/*.mkv",
 		"**/*.MPG", "**/*.MOD", "**/*.MMV", "**/*.TOD", "**/*.WMV", "**/*.ASF", "**/*.AVI", "**/*.DIVX", "**/*.MOV", "**/*.M4V", "**/*.3GP", "**/*.3G2", "**/*.MP4", "**/*.M2T", "**/*.M2TS", "**/*.MTS", "**/*.MKV",
-	},
+	}
+
+	// videoExtensions match with the video file type extensions. It includes
+	// all the ones supported by Google Photos and other common ones, like WebM.
+	videoExtensions = concat(allVideoFiles, []string{"**/*.webm", "**/*.WEBM"})
+)
+
+var patternDictionary = map[string][]string{
+	// _ALL_FILES match with all file extensions
+	"_ALL_FILES_": {"**"},
+
+	// _IMAGE_EXTENSIONS_ match with the supported photos file type extensions
+	"_IMAGE_EXTENSIONS_": imageExtensions,
+
+	// _RAW_EXTENSIONS_ match with the RAW file type extensions
+	"_RAW_EXTENSIONS_": rawExtensions,
+
+	// _PHOTO_EXTENSIONS_ match with both, image and RAW, file type extensions
+	"_PHOTO_EXTENSIONS_": concat(imageExtensions, rawExtensions),
+
+	// _ALL_VIDEO_FILES match with all video file extensions supported by Google Photos
+	"_ALL_VIDEO_FILES_": allVideoFiles,
+
+	// _VIDEO_EXTENSIONS_ match with the video file type extensions
+	"_VIDEO_EXTENSIONS_": videoExtensions,
+
+	// _MEDIA_EXTENSIONS_ match with both, image and video, file type extensions
+	"_MEDIA_EXTENSIONS_": concat(imageExtensions, videoExtensions),
+}
+
+// concat returns a new array with the elements of all the given arrays.
+func concat(lists ...[]string) []string {
+	var r []string
+	for _, l := range lists {
+		r = append(r, l...)
+	}
+	return r
 }
 
 // translatePatternList returns an array of patterns once tagged patterns has been
@@ -40,14 +92,56 @@ func translatePatternList(patternList []string) []string {
 }
 
 // translatePattern returns an array of patterns once a tagged pattern has been
-// resolved using patternDictionary
+// resolved using patternDictionary. Negated tagged patterns (e.g. `!_ALL_VIDEO_FILES_`)
+// are resolved to the negation of every pattern in the dictionary entry.
 func translatePattern(pattern string) []string {
 	if val, exist := patternDictionary[pattern]; exist {
 		return val
 	}
+	if p, negated := isNegated(pattern); negated {
+		if val, exist := patternDictionary[p]; exist {
+			r := make([]string, len(val))
+			for i, v := range val {
+				r[i] = negationPrefix + v
+			}
+			return r
+		}
+	}
 	return []string{pattern}
 }
 
+// isNegated returns the pattern without the negation prefix and true if the pattern was negated.
+func isNegated(pattern string) (string, bool) {
+	if strings.HasPrefix(pattern, negationPrefix) {
+		return strings.TrimPrefix(pattern, negationPrefix), true
+	}
+	return pattern, false
+}
+
+// toLowerList returns a copy of the patternList with all the patterns in lower case.
+// Regular expressions are not modified, but flagged as case-insensitive instead.
+func toLowerList(patternList []string) []string {
+	r := make([]string, len(patternList))
+	for i, p := range patternList {
+		r[i] = toLower(p)
+	}
+	return r
+}
+
+// toLower returns the pattern in lower case, or the case-insensitive version of the regular expression.
+func toLower(pattern string) string {
+	p, negated := isNegated(pattern)
+	if expr, ok := isRegexp(p); ok {
+		p = regexpPrefix + "(?i)" + expr
+	} else {
+		p = strings.ToLower(p)
+	}
+	if negated {
+		return negationPrefix + p
+	}
+	return p
+}
+
 // deleteEmpty removes empty string from an array.
 func deleteEmpty(s []string) []string {
 	var r []string
@@ -70,24 +164,62 @@ func validatePatternList(patternList []string) error {
 	return nil
 }
 
+// ValidatePattern returns error if the include or exclude pattern is not valid.
+func ValidatePattern(pattern string) error {
+	return validatePatternList(translatePattern(pattern))
+}
+
+// isRegexp returns the regular expression without the regexp prefix and true if the pattern is a regular expression.
+func isRegexp(pattern string) (string, bool) {
+	if strings.HasPrefix(pattern, regexpPrefix) {
+		return strings.TrimPrefix(pattern, regexpPrefix), true
+	}
+	return pattern, false
+}
+
 // validatePattern tries to use pattern and returns error if it is not valid.
 func validatePattern(pattern string) error {
-	_, err := doublestar.PathMatch(pattern, "x")
+	pattern, _ = isNegated(pattern)
+	_, err := pathMatch(pattern, "x")
 	return err
 }
 
-// match returns true if str matches one of the patterns. Empty patterns are ignored.
-func match(patternList []string, str string) (bool, error) {
-	for _, pat := range deleteEmpty(patternList) {
-		matched, err := doublestar.PathMatch(pat, str)
+// pathMatch returns true if str matches the pattern.
+// Patterns prefixed by `re:` are regular expressions (see regexp package) matched
+// against the whole str, any other pattern is a glob (see doublestar package).
+// str is matched with `/` as separator on every OS, see toSlash.
+func pathMatch(pattern string, str string) (bool, error) {
+	str = toSlash(str)
+	if expr, ok := isRegexp(pattern); ok {
+		re, err := compileRegexp(expr)
 		if err != nil {
 			return false, err
 		}
+		return re.MatchString(str), nil
+	}
+	return doublestar.Match(pattern, str)
+}
 
-		if matched {
-			return true, nil
-		}
+// toSlash returns the path using `/` as separator, whatever the OS is, so the same patterns
+// match on every platform: `foo\bar.jpg` is matched as `foo/bar.jpg`.
+// Drive letters are kept as the first element, `C:\photos` is `C:/photos`, UNC paths keep their two
+// leading slashes, `\\server\share` is `//server/share`, and extended-length prefixes are removed.
+func toSlash(path string) string {
+	switch {
+	case strings.HasPrefix(path, extendedLengthUNCPrefix):
+		path = `\\` + strings.TrimPrefix(path, extendedLengthUNCPrefix)
+	case strings.HasPrefix(path, extendedLengthPrefix):
+		path = strings.TrimPrefix(path, extendedLengthPrefix)
 	}
+	return strings.ReplaceAll(path, `\`, "/")
+}
 
-	return false, nil
+// compileRegexp returns the compiled regular expression. Filters compile their patterns once, see
+// compilePatterns, instead of every time a path is matched.
+func compileRegexp(expr string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression '%s': %w", expr, err)
+	}
+	return re, nil
 }
@@ -55,7 +55,7 @@ func Test_ValidatePatterns(t *testing.T) {
 	}
 }
 
-func Test_Match(t *testing.T) {
+func Test_CompiledPatterns_FirstIndex(t *testing.T) {
 	testCases := []struct {
 		name        string
 		patterns    []string
@@ -65,15 +65,62 @@ func Test_Match(t *testing.T) {
 	}{
 		{name: "input match pattern", patterns: []string{"*"}, input: "foo", shouldMatch: true, errExpected: false},
 		{name: "input doesn't match pattern", patterns: []string{"*"}, input: "foo/bar.jpg", shouldMatch: false, errExpected: false},
+		{name: "input with backslashes match pattern", patterns: []string{"foo/*.jpg"}, input: `foo\bar.jpg`, shouldMatch: true, errExpected: false},
 		{name: "invalid pattern returns error", patterns: []string{"[]a]"}, input: "]", shouldMatch: false, errExpected: true},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			got, err := match(tc.patterns, tc.input)
+			got, err := compiledMatch(tc.patterns, false, tc.input)
 			if tc.shouldMatch != got || (err != nil && !tc.errExpected) {
 				t.Errorf("want: %v, got: %v, err: %v", tc.shouldMatch, got, err)
 			}
 		})
 	}
 }
+
+func Test_CompiledPatterns_LastIndex(t *testing.T) {
+	testCases := []struct {
+		name        string
+		patterns    []string
+		input       string
+		shouldMatch bool
+		errExpected bool
+	}{
+		{name: "input match pattern", patterns: []string{"foo/*"}, input: "foo/bar.jpg", shouldMatch: true, errExpected: false},
+		{name: "input match negated pattern", patterns: []string{"foo/*", "!foo/bar.jpg"}, input: "foo/bar.jpg", shouldMatch: false, errExpected: false},
+		{name: "last matching pattern wins", patterns: []string{"!foo/bar.jpg", "foo/*"}, input: "foo/bar.jpg", shouldMatch: true, errExpected: false},
+		{name: "input doesn't match pattern", patterns: []string{"foo/*"}, input: "bar/foo.jpg", shouldMatch: false, errExpected: false},
+		{name: "invalid negated pattern returns error", patterns: []string{"![]a]"}, input: "]", shouldMatch: false, errExpected: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := compiledMatch(tc.patterns, true, tc.input)
+			if tc.shouldMatch != got || (err != nil && !tc.errExpected) || (err == nil && tc.errExpected) {
+				t.Errorf("want: %v, got: %v, err: %v", tc.shouldMatch, got, err)
+			}
+		})
+	}
+}
+
+// compiledMatch returns true if str matches the patterns once they are validated and compiled, like the allowed
+// list is matched, or like the excluded list, the verdict of its last matching pattern, if negatable is set.
+func compiledMatch(patternList []string, negatable bool, str string) (bool, error) {
+	if err := validatePatternList(patternList); err != nil {
+		return false, err
+	}
+	c, err := compilePatterns(patternList, negatable)
+	if err != nil {
+		return false, err
+	}
+	if !negatable {
+		return c.firstIndex(str) >= 0, nil
+	}
+	i := c.lastIndex(str)
+	if i < 0 {
+		return false, nil
+	}
+	_, negated := isNegated(patternList[i])
+	return !negated, nil
+}
@@ -0,0 +1,59 @@
+// Package gitchanges lists the files changed in a git repository since a commit, e.g. to upload only them.
+package gitchanges
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNotRepository is returned when the folder is not in a git repository.
+var ErrNotRepository = errors.New("not a git repository")
+
+// Files returns the files of folder added or modified since ref, e.g. a commit, a branch or a tag, as listed by
+// `git diff --name-only <ref>` in the repository of folder. Changes not committed yet are listed too, but not
+// the untracked files, nor the deleted ones. Paths are relative to folder, using the separator of the OS, and
+// files out of folder are not listed. It returns ErrNotRepository if folder is not in a git repository.
+func Files(ctx context.Context, folder string, ref string) ([]string, error) {
+	if ref == "" || strings.HasPrefix(ref, "-") {
+		return nil, fmt.Errorf("invalid git ref '%s'", ref)
+	}
+	if _, err := run(ctx, folder, "rev-parse", "--is-inside-work-tree"); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("folder '%s' is %w", folder, ErrNotRepository)
+		}
+		return nil, err
+	}
+	// --relative lists the paths relative to folder, only the ones in folder.
+	out, err := run(ctx, folder, "diff", "--name-only", "--relative", "--diff-filter=ACMR", "-z", ref, "--")
+	if err != nil {
+		return nil, fmt.Errorf("unable to list the files changed since '%s': %w", ref, err)
+	}
+	var files []string
+	for _, name := range strings.Split(string(out), "\x00") {
+		if name != "" {
+			files = append(files, filepath.FromSlash(name))
+		}
+	}
+	return files, nil
+}
+
+// run returns the output of the git command run in folder. The error includes the messages of git, if any.
+func run(ctx context.Context, folder string, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	c := exec.CommandContext(ctx, "git", append([]string{"-C", folder}, args...)...)
+	c.Stderr = &stderr
+	out, err := c.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,122 @@
+package gitchanges_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/gitchanges"
+)
+
+// git runs the git command in dir, failing the test if it fails.
+func git(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	c := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@domain.com", "-c", "commit.gpgsign=false"}, args...)...)
+	if out, err := c.CombinedOutput(); err != nil {
+		t.Fatalf("git %v has failed: %s, %s", args, err, out)
+	}
+}
+
+// writeFiles writes the files in dir, creating their folders.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFiles(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir, err := ioutil.TempDir("", "gitchanges")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	git(t, dir, "init", "-q")
+	writeFiles(t, dir, map[string]string{
+		"README.md":             "readme",
+		"photos/IMG_0001.jpg":   "photo 1",
+		"photos/IMG_0002.jpg":   "photo 2",
+		"photos/2023/IMG_3.jpg": "photo 3",
+	})
+	git(t, dir, "add", ".")
+	git(t, dir, "commit", "-q", "-m", "first")
+	git(t, dir, "tag", "v1")
+
+	// added, modified, renamed and deleted files, committed or not.
+	writeFiles(t, dir, map[string]string{
+		"README.md":             "new readme",
+		"photos/IMG_0001.jpg":   "edited photo 1",
+		"photos/2023/IMG 4.jpg": "photo 4",
+		"photos/untracked.jpg":  "untracked",
+	})
+	git(t, dir, "add", "README.md", "photos/IMG_0001.jpg", "photos/2023/IMG 4.jpg")
+	git(t, dir, "mv", "photos/2023/IMG_3.jpg", "photos/2023/IMG_0003.jpg")
+	git(t, dir, "rm", "-q", "photos/IMG_0002.jpg")
+	git(t, dir, "commit", "-q", "-m", "second")
+	writeFiles(t, dir, map[string]string{"photos/2023/IMG 4.jpg": "edited photo 4, not committed"})
+
+	testCases := []struct {
+		name   string
+		folder string
+		ref    string
+		want   []string
+	}{
+		{"Should list the files changed in the repository", dir, "v1", []string{"README.md", filepath.Join("photos", "2023", "IMG 4.jpg"), filepath.Join("photos", "2023", "IMG_0003.jpg"), filepath.Join("photos", "IMG_0001.jpg")}},
+		{"Should list the files changed in the folder only", filepath.Join(dir, "photos"), "v1", []string{filepath.Join("2023", "IMG 4.jpg"), filepath.Join("2023", "IMG_0003.jpg"), "IMG_0001.jpg"}},
+		{"Should list the files not committed", filepath.Join(dir, "photos", "2023"), "HEAD", []string{"IMG 4.jpg"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := gitchanges.Files(context.Background(), tc.folder, tc.ref)
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(tc.want, got) {
+				t.Errorf("want: %q, got: %q", tc.want, got)
+			}
+		})
+	}
+
+	t.Run("Should fail if the ref does not exist", func(t *testing.T) {
+		if _, err := gitchanges.Files(context.Background(), dir, "v2"); err == nil {
+			t.Errorf("error was expected, but not produced")
+		}
+	})
+	t.Run("Should fail if the ref is an option", func(t *testing.T) {
+		if _, err := gitchanges.Files(context.Background(), dir, "--output=changes"); err == nil {
+			t.Errorf("error was expected, but not produced")
+		}
+	})
+}
+
+func TestFiles_NotRepository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir, err := ioutil.TempDir("", "gitchanges")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := gitchanges.Files(context.Background(), dir, "HEAD"); !errors.Is(err, gitchanges.ErrNotRepository) {
+		t.Errorf("want: %v, got: %v", gitchanges.ErrNotRepository, err)
+	}
+}
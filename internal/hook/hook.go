@@ -0,0 +1,76 @@
+// Package hook runs user commands after the files are uploaded, e.g. to tag the files or update other databases.
+package hook
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout is the default time a command has to finish, before being killed.
+const DefaultTimeout = time.Minute
+
+// Environment variables set when running the command.
+const (
+	EnvPath        = "GPHOTOS_UPLOAD_PATH"
+	EnvMediaItemID = "GPHOTOS_UPLOAD_MEDIA_ITEM_ID"
+	EnvAlbum       = "GPHOTOS_UPLOAD_ALBUM"
+)
+
+// Upload is an uploaded file.
+type Upload struct {
+	Path        string
+	MediaItemID string
+	AlbumName   string
+}
+
+// Command is an external program run after every upload.
+type Command struct {
+	// Name is the program to run.
+	Name string
+	// Args are the arguments of the program, the path and the media item ID of the upload are added after them.
+	Args []string
+	// Timeout is the time the program has to finish, before being killed.
+	Timeout time.Duration
+}
+
+// NewCommand returns the Command running the command line, whose arguments are separated by spaces.
+// A zero timeout uses DefaultTimeout.
+func NewCommand(commandLine string, timeout time.Duration) Command {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	fields := strings.Fields(commandLine)
+	c := Command{Timeout: timeout}
+	if len(fields) > 0 {
+		c.Name, c.Args = fields[0], fields[1:]
+	}
+	return c
+}
+
+// Run runs the program for the upload. The path and the media item ID are passed as the last arguments,
+// and as the GPHOTOS_UPLOAD_PATH and GPHOTOS_UPLOAD_MEDIA_ITEM_ID environment variables, with the album
+// in GPHOTOS_UPLOAD_ALBUM. It returns an error if the program fails, or it doesn't finish in time.
+func (c Command) Run(ctx context.Context, u Upload) error {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	args := append(append([]string{}, c.Args...), u.Path, u.MediaItemID)
+	cmd := exec.CommandContext(ctx, c.Name, args...)
+	cmd.Env = append(os.Environ(),
+		EnvPath+"="+u.Path,
+		EnvMediaItemID+"="+u.MediaItemID,
+		EnvAlbum+"="+u.AlbumName,
+	)
+	out, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("%s has not finished in %s processing '%s'", c.Name, c.Timeout, u.Path)
+	}
+	if err != nil {
+		return fmt.Errorf("%s failed processing '%s': %s: %s", c.Name, u.Path, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package hook_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/hook"
+)
+
+// writeStub writes a shell script with the body, returning its path.
+func writeStub(t *testing.T, dir string, body string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("shell scripts are not supported on windows")
+	}
+	path := filepath.Join(dir, "stub.sh")
+	if err := ioutil.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCommand_Run(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hook")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	invocations := filepath.Join(dir, "invocations")
+	stub := writeStub(t, dir, `for arg in "$@"; do echo "arg=$arg" >> "`+invocations+`"; done
+echo "path=$GPHOTOS_UPLOAD_PATH" >> "`+invocations+`"
+echo "id=$GPHOTOS_UPLOAD_MEDIA_ITEM_ID" >> "`+invocations+`"
+echo "album=$GPHOTOS_UPLOAD_ALBUM" >> "`+invocations+`"`)
+
+	c := hook.NewCommand(stub+" --tag uploaded", 0)
+	u := hook.Upload{Path: "/photos/IMG 0001.jpg", MediaItemID: "media-1", AlbumName: "Trips"}
+	if err := c.Run(context.Background(), u); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	b, err := ioutil.ReadFile(invocations)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	want := []string{
+		"arg=--tag",
+		"arg=uploaded",
+		"arg=/photos/IMG 0001.jpg",
+		"arg=media-1",
+		"path=/photos/IMG 0001.jpg",
+		"id=media-1",
+		"album=Trips",
+	}
+	if got := strings.Split(strings.TrimSpace(string(b)), "\n"); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("want: %v, got: %v", want, got)
+	}
+}
+
+func TestCommand_RunFailed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hook")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	stub := writeStub(t, dir, "echo 'unable to tag' >&2\nexit 3")
+
+	err = hook.NewCommand(stub, 0).Run(context.Background(), hook.Upload{Path: "/photos/IMG_0001.jpg"})
+	if err == nil {
+		t.Fatalf("error was expected, but not produced")
+	}
+	if !strings.Contains(err.Error(), "unable to tag") {
+		t.Errorf("want: command output in error, got: %s", err)
+	}
+}
+
+func TestCommand_RunTimeout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hook")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	stub := writeStub(t, dir, "exec sleep 5")
+
+	start := time.Now()
+	err = hook.NewCommand(stub, 100*time.Millisecond).Run(context.Background(), hook.Upload{Path: "/photos/IMG_0001.jpg"})
+	if err == nil {
+		t.Fatalf("error was expected, but not produced")
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("want: command killed after timeout, got: %s", elapsed)
+	}
+}
+
+func TestNewCommand(t *testing.T) {
+	c := hook.NewCommand("  exiftool   -keywords+=uploaded ", 0)
+	if c.Name != "exiftool" || len(c.Args) != 1 || c.Args[0] != "-keywords+=uploaded" {
+		t.Errorf("want: exiftool [-keywords+=uploaded], got: %s %v", c.Name, c.Args)
+	}
+	if c.Timeout != hook.DefaultTimeout {
+		t.Errorf("want: %s, got: %s", hook.DefaultTimeout, c.Timeout)
+	}
+}
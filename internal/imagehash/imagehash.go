@@ -0,0 +1,111 @@
+// Package imagehash computes perceptual hashes of images, which are similar for visually similar images, even if
+// their content is different, e.g. a photo and its recompressed copy.
+package imagehash
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	// image formats decoded by DHash.
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math/bits"
+	"os"
+)
+
+// ErrUnsupported is returned when the file is not an image that could be decoded, e.g. a video.
+var ErrUnsupported = errors.New("unsupported image format")
+
+const (
+	// hashWidth and hashHeight are the size of the image every hash is computed from. Every row has one more
+	// pixel than bits, since every bit compares two adjacent pixels.
+	hashWidth  = 9
+	hashHeight = 8
+)
+
+// DHash computes the difference hash of JPEG, PNG and GIF images: the image is scaled down to 9x8 pixels in
+// grayscale, and every bit of the 64 bits hash is set if a pixel is brighter than the next one of its row.
+// It's robust to recompression and resizing, but not to crops or rotations.
+type DHash struct{}
+
+// PerceptualHash returns the difference hash of the image file. It returns ErrUnsupported if the file is not an
+// image of a supported format.
+func (DHash) PerceptualHash(file string) (uint64, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	img, _, err := image.Decode(f)
+	if errors.Is(err, image.ErrFormat) {
+		return 0, fmt.Errorf("%w: %s", ErrUnsupported, file)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return Difference(img), nil
+}
+
+// Difference returns the difference hash of the image, see DHash.
+func Difference(img image.Image) uint64 {
+	var cells [hashHeight][hashWidth]float64
+	var counts [hashHeight][hashWidth]int
+	b := img.Bounds()
+	if b.Empty() {
+		return 0
+	}
+	// every pixel is added to the cell of the scaled down image where it is, so the cells are its average.
+	luminance := grayReader(img)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		cy := (y - b.Min.Y) * hashHeight / b.Dy()
+		for x := b.Min.X; x < b.Max.X; x++ {
+			cx := (x - b.Min.X) * hashWidth / b.Dx()
+			cells[cy][cx] += luminance(x, y)
+			counts[cy][cx]++
+		}
+	}
+
+	var hash uint64
+	for y := 0; y < hashHeight; y++ {
+		for x := 0; x < hashWidth-1; x++ {
+			hash <<= 1
+			if average(cells[y][x], counts[y][x]) > average(cells[y][x+1], counts[y][x+1]) {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// Distance returns the Hamming distance between the hashes, the number of different bits. Visually similar
+// images have hashes at a short distance.
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// grayReader returns the function reading the luminance of the pixels of the image. The luma of YCbCr images,
+// like the JPEG ones, is read without converting their color.
+func grayReader(img image.Image) func(x, y int) float64 {
+	if ycc, ok := img.(*image.YCbCr); ok {
+		return func(x, y int) float64 {
+			return float64(ycc.Y[ycc.YOffset(x, y)])
+		}
+	}
+	return func(x, y int) float64 {
+		return float64(color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y)
+	}
+}
+
+// average returns the average of the sum of n values, or 0 if there are none, e.g. if the image is smaller than
+// the hash.
+func average(sum float64, n int) float64 {
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
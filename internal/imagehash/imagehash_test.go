@@ -0,0 +1,103 @@
+package imagehash_test
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/imagehash"
+)
+
+// testImage returns an image with shapes of different brightness, inverted if invert is set.
+func testImage(invert bool) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 160, 120))
+	for y := 0; y < 120; y++ {
+		for x := 0; x < 160; x++ {
+			v := uint8((x*255/160 + y*255/120) / 2)
+			if (x/40+y/30)%2 == 0 {
+				v = 255 - v/2
+			}
+			if invert {
+				v = 255 - v
+			}
+			img.Set(x, y, color.RGBA{R: v, G: v / 2, B: 255 - v, A: 255})
+		}
+	}
+	return img
+}
+
+func writeJPEG(t *testing.T, path string, img image.Image, quality int) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := jpeg.Encode(f, img, &jpeg.Options{Quality: quality}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDHash_PerceptualHash(t *testing.T) {
+	dir := t.TempDir()
+	original := filepath.Join(dir, "original.png")
+	f, err := os.Create(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, testImage(false)); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	recompressed := filepath.Join(dir, "recompressed.jpg")
+	writeJPEG(t, recompressed, testImage(false), 40)
+	other := filepath.Join(dir, "other.jpg")
+	writeJPEG(t, other, testImage(true), 90)
+
+	hash := func(path string) uint64 {
+		h, err := imagehash.DHash{}.PerceptualHash(path)
+		if err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+		return h
+	}
+	a, b, c := hash(original), hash(recompressed), hash(other)
+	if d := imagehash.Distance(a, b); d > 5 {
+		t.Errorf("want: distance up to %d between similar images, got: %d", 5, d)
+	}
+	if d := imagehash.Distance(a, c); d < 20 {
+		t.Errorf("want: distance of %d at least between different images, got: %d", 20, d)
+	}
+}
+
+func TestDHash_PerceptualHashUnsupported(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "video.mp4")
+	if err := ioutil.WriteFile(path, []byte("\x00\x00\x00\x18ftypmp42"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (imagehash.DHash{}).PerceptualHash(path); !errors.Is(err, imagehash.ErrUnsupported) {
+		t.Errorf("want: %v, got: %v", imagehash.ErrUnsupported, err)
+	}
+}
+
+func TestDistance(t *testing.T) {
+	testCases := []struct {
+		a, b uint64
+		want int
+	}{
+		{0, 0, 0},
+		{0xff, 0x0f, 4},
+		{0, ^uint64(0), 64},
+	}
+	for _, tc := range testCases {
+		if got := imagehash.Distance(tc.a, tc.b); got != tc.want {
+			t.Errorf("want: %d, got: %d", tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+// Package library calls the Google Photos Library API methods not provided by the client library.
+//
+// The Library API only allows to change albums created by this tool. Their title and cover photo
+// can be updated, but there isn't any description field: the closest is a text enrichment, shown at
+// the top of the album. Other album properties, like the sharing options, could not be set.
+package library
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/gphotosuploader/google-photos-api-client-go/v2/albums"
+	"google.golang.org/api/googleapi"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/ratelimit"
+)
+
+// DefaultEndpoint is the Google Photos Library API endpoint of albums.
+const DefaultEndpoint = "https://photoslibrary.googleapis.com/v1/albums"
+
+// HttpClient represents a HTTP client.
+type HttpClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// AlbumsService updates the albums created by this tool.
+type AlbumsService struct {
+	client HttpClient
+
+	// Endpoint is the URL of the albums. Uses DefaultEndpoint by default.
+	// Useful for testing.
+	Endpoint string
+
+	// Limiter limits the requests listing the albums, one per page, to respect the API quotas. Nil means unlimited.
+	Limiter *ratelimit.Limiter
+}
+
+// albumsPageSize is the maximum number of albums returned by every list request.
+const albumsPageSize = 50
+
+// NewAlbumsService returns an AlbumsService using the authenticated client.
+func NewAlbumsService(client HttpClient) *AlbumsService {
+	return &AlbumsService{client: client, Endpoint: DefaultEndpoint}
+}
+
+// SetCoverPhoto sets the media item as the cover photo of the album. The media item must be in the album.
+func (s *AlbumsService) SetCoverPhoto(ctx context.Context, albumID string, mediaItemID string) error {
+	body := map[string]string{"coverPhotoMediaItemId": mediaItemID}
+	u := fmt.Sprintf("%s/%s?updateMask=coverPhotoMediaItemId", s.Endpoint, url.PathEscape(albumID))
+	return s.do(ctx, "PATCH", u, body)
+}
+
+// AddDescription adds the text at the top of the album, as a text enrichment.
+// It's added every time it's called, so it should be called once, when the album is created.
+func (s *AlbumsService) AddDescription(ctx context.Context, albumID string, text string) error {
+	body := map[string]interface{}{
+		"newEnrichmentItem": map[string]interface{}{
+			"textEnrichment": map[string]string{"text": text},
+		},
+		"albumPosition": map[string]string{"position": "FIRST_IN_ALBUM"},
+	}
+	u := fmt.Sprintf("%s/%s:addEnrichment", s.Endpoint, url.PathEscape(albumID))
+	return s.do(ctx, "POST", u, body)
+}
+
+// List returns all the albums created by this tool, the only ones where media items could be added, in the order
+// returned by the API. Albums are paginated, so it could take several requests.
+func (s *AlbumsService) List(ctx context.Context) ([]albums.Album, error) {
+	var result []albums.Album
+	pageToken := ""
+	for {
+		if err := s.Limiter.WaitContext(ctx, 1); err != nil {
+			return nil, err
+		}
+		res, err := s.list(ctx, pageToken)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range res.Albums {
+			result = append(result, albums.Album{
+				ID:                    a.ID,
+				Title:                 a.Title,
+				ProductURL:            a.ProductURL,
+				IsWriteable:           a.IsWriteable,
+				MediaItemsCount:       a.MediaItemsCount,
+				CoverPhotoBaseURL:     a.CoverPhotoBaseURL,
+				CoverPhotoMediaItemID: a.CoverPhotoMediaItemID,
+			})
+		}
+		if res.NextPageToken == "" {
+			return result, nil
+		}
+		pageToken = res.NextPageToken
+	}
+}
+
+// list sends the request of the page of albums, returning a *googleapi.Error if it is not successful.
+func (s *AlbumsService) list(ctx context.Context, pageToken string) (listAlbumsResponse, error) {
+	var res listAlbumsResponse
+	params := url.Values{}
+	params.Set("pageSize", fmt.Sprint(albumsPageSize))
+	params.Set("excludeNonAppCreatedData", "true")
+	if pageToken != "" {
+		params.Set("pageToken", pageToken)
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", s.Endpoint+"?"+params.Encode(), nil)
+	if err != nil {
+		return res, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return res, err
+	}
+	defer resp.Body.Close()
+	if err := googleapi.CheckResponse(resp); err != nil {
+		return res, err
+	}
+	err = json.NewDecoder(resp.Body).Decode(&res)
+	return res, err
+}
+
+type listAlbumsResponse struct {
+	Albums []struct {
+		ID                    string `json:"id"`
+		Title                 string `json:"title"`
+		ProductURL            string `json:"productUrl"`
+		IsWriteable           bool   `json:"isWriteable"`
+		MediaItemsCount       string `json:"mediaItemsCount"`
+		CoverPhotoBaseURL     string `json:"coverPhotoBaseUrl"`
+		CoverPhotoMediaItemID string `json:"coverPhotoMediaItemId"`
+	} `json:"albums"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// do sends the request with the JSON body, returning a *googleapi.Error if it is not successful.
+func (s *AlbumsService) do(ctx context.Context, method string, url string, body interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	return googleapi.CheckResponse(res)
+}
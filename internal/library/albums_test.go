@@ -0,0 +1,144 @@
+package library_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/library"
+)
+
+func TestAlbumsService_SetCoverPhoto(t *testing.T) {
+	var gotMethod, gotPath, gotMask string
+	var gotBody map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod, gotPath, gotMask = r.Method, r.URL.Path, r.URL.Query().Get("updateMask")
+		if err := json.NewDecoder(r.Body).Decode(&gotBody); err != nil {
+			t.Errorf("error was not expected at this point: %s", err)
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	s := library.NewAlbumsService(srv.Client())
+	s.Endpoint = srv.URL + "/v1/albums"
+	if err := s.SetCoverPhoto(context.Background(), "album-id", "media-item-id"); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	if gotMethod != "PATCH" || gotPath != "/v1/albums/album-id" || gotMask != "coverPhotoMediaItemId" {
+		t.Errorf("want: PATCH /v1/albums/album-id?updateMask=coverPhotoMediaItemId, got: %s %s?updateMask=%s", gotMethod, gotPath, gotMask)
+	}
+	if want := "media-item-id"; gotBody["coverPhotoMediaItemId"] != want {
+		t.Errorf("want: %s, got: %s", want, gotBody["coverPhotoMediaItemId"])
+	}
+}
+
+func TestAlbumsService_AddDescription(t *testing.T) {
+	var gotPath string
+	var gotBody struct {
+		NewEnrichmentItem struct {
+			TextEnrichment struct {
+				Text string `json:"text"`
+			} `json:"textEnrichment"`
+		} `json:"newEnrichmentItem"`
+		AlbumPosition struct {
+			Position string `json:"position"`
+		} `json:"albumPosition"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		if err := json.NewDecoder(r.Body).Decode(&gotBody); err != nil {
+			t.Errorf("error was not expected at this point: %s", err)
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	s := library.NewAlbumsService(srv.Client())
+	s.Endpoint = srv.URL + "/v1/albums"
+	if err := s.AddDescription(context.Background(), "album-id", "Summer holidays"); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	if want := "/v1/albums/album-id:addEnrichment"; gotPath != want {
+		t.Errorf("want: %s, got: %s", want, gotPath)
+	}
+	if want := "Summer holidays"; gotBody.NewEnrichmentItem.TextEnrichment.Text != want {
+		t.Errorf("want: %s, got: %s", want, gotBody.NewEnrichmentItem.TextEnrichment.Text)
+	}
+	if want := "FIRST_IN_ALBUM"; gotBody.AlbumPosition.Position != want {
+		t.Errorf("want: %s, got: %s", want, gotBody.AlbumPosition.Position)
+	}
+}
+
+func TestAlbumsService_SetCoverPhotoNotCreatedByApp(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error": {"code": 400, "message": "Request must be made to an album created by the app."}}`))
+	}))
+	defer srv.Close()
+
+	s := library.NewAlbumsService(srv.Client())
+	s.Endpoint = srv.URL + "/v1/albums"
+	if err := s.SetCoverPhoto(context.Background(), "album-id", "media-item-id"); err == nil {
+		t.Errorf("error was expected, but not produced")
+	}
+}
+
+func TestAlbumsService_List(t *testing.T) {
+	var pageTokens []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pageTokens = append(pageTokens, r.URL.Query().Get("pageToken"))
+		if r.URL.Query().Get("excludeNonAppCreatedData") != "true" {
+			t.Errorf("want: only albums created by the app, got: %s", r.URL.RawQuery)
+		}
+		switch r.URL.Query().Get("pageToken") {
+		case "":
+			_, _ = w.Write([]byte(`{"albums":[{"id":"album-1","title":"Trips"},{"id":"album-2","title":"Family"}],"nextPageToken":"page-2"}`))
+		case "page-2":
+			_, _ = w.Write([]byte(`{"albums":[{"id":"album-3","title":"Birthdays"}],"nextPageToken":"page-3"}`))
+		default:
+			_, _ = w.Write([]byte(`{"albums":[{"id":"album-4","title":"Pets"}]}`))
+		}
+	}))
+	defer srv.Close()
+
+	s := library.NewAlbumsService(srv.Client())
+	s.Endpoint = srv.URL + "/v1/albums"
+	got, err := s.List(context.Background())
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	var titles []string
+	for _, a := range got {
+		titles = append(titles, a.ID+":"+a.Title)
+	}
+	if want := []string{"album-1:Trips", "album-2:Family", "album-3:Birthdays", "album-4:Pets"}; !reflect.DeepEqual(want, titles) {
+		t.Errorf("want: %v, got: %v", want, titles)
+	}
+	if want := []string{"", "page-2", "page-3"}; !reflect.DeepEqual(want, pageTokens) {
+		t.Errorf("want: %v, got: %v", want, pageTokens)
+	}
+}
+
+func TestAlbumsService_ListFailed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("pageToken") == "" {
+			_, _ = w.Write([]byte(`{"albums":[{"id":"album-1","title":"Trips"}],"nextPageToken":"page-2"}`))
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	s := library.NewAlbumsService(srv.Client())
+	s.Endpoint = srv.URL + "/v1/albums"
+	if _, err := s.List(context.Background()); err == nil {
+		t.Fatalf("error was expected, but not produced")
+	}
+}
@@ -0,0 +1,51 @@
+package library
+
+import (
+	"strings"
+)
+
+// DefaultBaseURL is the base URL of the Google Photos Library API.
+const DefaultBaseURL = "https://photoslibrary.googleapis.com/"
+
+// Endpoints are the URLs of the Google Photos Library API, derived from its base URL. A custom base URL
+// allows to use a proxy, or a local server for testing.
+type Endpoints struct {
+	// BaseURL is the base URL of the API, ending with a slash.
+	BaseURL string
+}
+
+// NewEndpoints returns the endpoints of the API at baseURL, or at DefaultBaseURL if it's empty.
+func NewEndpoints(baseURL string) Endpoints {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+	return Endpoints{BaseURL: baseURL}
+}
+
+// Uploads returns the URL where the content of the files is uploaded.
+func (e Endpoints) Uploads() string {
+	return e.BaseURL + "v1/uploads"
+}
+
+// Albums returns the URL of the albums.
+func (e Endpoints) Albums() string {
+	return e.BaseURL + "v1/albums"
+}
+
+// SharedAlbums returns the URL of the albums shared with the account.
+func (e Endpoints) SharedAlbums() string {
+	return e.BaseURL + "v1/sharedAlbums"
+}
+
+// MediaItemsSearch returns the URL to search the media items of the library.
+func (e Endpoints) MediaItemsSearch() string {
+	return e.BaseURL + "v1/mediaItems:search"
+}
+
+// MediaItemsBatchCreate returns the URL to create the media items of the uploaded files.
+func (e Endpoints) MediaItemsBatchCreate() string {
+	return e.BaseURL + "v1/mediaItems:batchCreate"
+}
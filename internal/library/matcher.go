@@ -0,0 +1,101 @@
+package library
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Searcher represents a way to search the media items of the library by their creation date.
+type Searcher interface {
+	ByDate(ctx context.Context, from time.Time, to time.Time) ([]MediaItem, error)
+}
+
+// CaptureTimeReader represents a way to read when a photo was taken, e.g. exif.Reader.
+type CaptureTimeReader interface {
+	CaptureTime(path string) (time.Time, error)
+}
+
+// Matcher finds the media item of the library matching a file, e.g. uploaded by the mobile app, so the file is
+// not uploaded again. The API doesn't expose the content hashes, so matches are heuristic: a media item matches
+// if it has the same filename, compared case-insensitively, and it was created the day the file was captured, or
+// the day before or after it, since creation times are in UTC.
+// Files renamed, edited or without a capture time are not matched, so they are uploaded.
+// Search results are kept in memory for every day, since files captured the same day share them. It's safe for
+// concurrent use.
+type Matcher struct {
+	Search      Searcher
+	CaptureTime CaptureTimeReader
+
+	mu sync.Mutex
+	// days are the media items created around a day, by the day.
+	days map[string][]MediaItem
+}
+
+// NewMatcher returns a Matcher searching the media items using search, around the capture time of the files.
+func NewMatcher(search Searcher, captureTime CaptureTimeReader) *Matcher {
+	return &Matcher{
+		Search:      search,
+		CaptureTime: captureTime,
+		days:        make(map[string][]MediaItem),
+	}
+}
+
+// Match returns the ID of the media item matching the file, and true if it has been found.
+// Files without a capture time use their modification time instead.
+func (m *Matcher) Match(ctx context.Context, path string) (string, bool, error) {
+	return m.match(ctx, path, true)
+}
+
+// Recheck is like Match, but it searches the media items again instead of using the ones kept in memory, e.g. to
+// find the media item of a file that could have been created after the previous search.
+func (m *Matcher) Recheck(ctx context.Context, path string) (string, bool, error) {
+	return m.match(ctx, path, false)
+}
+
+func (m *Matcher) match(ctx context.Context, path string, cached bool) (string, bool, error) {
+	captured, err := m.CaptureTime.CaptureTime(path)
+	if err != nil || captured.IsZero() {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return "", false, err
+		}
+		captured = fi.ModTime()
+	}
+
+	items, err := m.around(ctx, captured, cached)
+	if err != nil {
+		return "", false, err
+	}
+	name := filepath.Base(path)
+	for _, item := range items {
+		if strings.EqualFold(item.Filename, name) {
+			return item.ID, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// around returns the media items created the day of t, and the day before or after it. If cached is false,
+// they are searched even if they are kept in memory.
+func (m *Matcher) around(ctx context.Context, t time.Time, cached bool) ([]MediaItem, error) {
+	day := t.Format("2006-01-02")
+	m.mu.Lock()
+	items, ok := m.days[day]
+	m.mu.Unlock()
+	if ok && cached {
+		return items, nil
+	}
+
+	items, err := m.Search.ByDate(ctx, t.AddDate(0, 0, -1), t.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.days[day] = items
+	return items, nil
+}
@@ -0,0 +1,154 @@
+package library_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/library"
+)
+
+// searcher mocks the search of media items by date.
+type searcher struct {
+	items    []library.MediaItem
+	searches int
+}
+
+func (s *searcher) ByDate(ctx context.Context, from time.Time, to time.Time) ([]library.MediaItem, error) {
+	s.searches++
+	var items []library.MediaItem
+	for _, item := range s.items {
+		if !item.CreationTime.Before(from.Truncate(24*time.Hour)) && item.CreationTime.Before(to.Truncate(24*time.Hour).AddDate(0, 0, 1)) {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+// captureTimes mocks the capture times of the files.
+type captureTimes map[string]time.Time
+
+func (c captureTimes) CaptureTime(path string) (time.Time, error) {
+	t, ok := c[filepath.Base(path)]
+	if !ok {
+		return time.Time{}, errors.New("no capture time")
+	}
+	return t, nil
+}
+
+func TestMatcher_Match(t *testing.T) {
+	dir, err := ioutil.TempDir("", "matcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	captured := time.Date(2021, 6, 1, 23, 30, 0, 0, time.UTC)
+	search := &searcher{items: []library.MediaItem{
+		{ID: "media-1", Filename: "IMG_0001.JPG", CreationTime: captured.Add(time.Hour)},
+		{ID: "media-2", Filename: "IMG_0002.jpg", CreationTime: captured.AddDate(0, 0, -10)},
+	}}
+	times := captureTimes{
+		"IMG_0001.jpg": captured,
+		"IMG_0002.jpg": captured,
+		"IMG_0003.jpg": captured,
+	}
+
+	testCases := []struct {
+		name      string
+		file      string
+		wantID    string
+		wantFound bool
+	}{
+		{"Should match same filename around the capture time", "IMG_0001.jpg", "media-1", true},
+		{"Should not match same filename created other day", "IMG_0002.jpg", "", false},
+		{"Should not match other filename", "IMG_0003.jpg", "", false},
+	}
+
+	m := library.NewMatcher(search, times)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(dir, tc.file)
+			if err := ioutil.WriteFile(path, []byte("photo"), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			id, found, err := m.Match(context.Background(), path)
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			if id != tc.wantID || found != tc.wantFound {
+				t.Errorf("want: %s %t, got: %s %t", tc.wantID, tc.wantFound, id, found)
+			}
+		})
+	}
+
+	// files captured the same day share the search results.
+	if search.searches != 1 {
+		t.Errorf("want: %d searches, got: %d", 1, search.searches)
+	}
+}
+
+func TestMatcher_MatchWithoutCaptureTime(t *testing.T) {
+	dir, err := ioutil.TempDir("", "matcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "VID_0001.mp4")
+	if err := ioutil.WriteFile(path, []byte("video"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	search := &searcher{items: []library.MediaItem{{ID: "media-1", Filename: "VID_0001.mp4", CreationTime: modTime}}}
+	id, found, err := library.NewMatcher(search, captureTimes{}).Match(context.Background(), path)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if id != "media-1" || !found {
+		t.Errorf("want: media-1 true, got: %s %t", id, found)
+	}
+}
+
+func TestMatcher_Recheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "matcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "IMG_0001.jpg")
+	if err := ioutil.WriteFile(path, []byte("photo"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	captured := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	search := &searcher{}
+	m := library.NewMatcher(search, captureTimes{"IMG_0001.jpg": captured})
+	if _, found, err := m.Match(context.Background(), path); err != nil || found {
+		t.Fatalf("want: not found, got: %t %v", found, err)
+	}
+
+	// the media item is created after the first search, so it's only found if the search is done again.
+	search.items = []library.MediaItem{{ID: "media-1", Filename: "IMG_0001.jpg", CreationTime: captured}}
+	if _, found, err := m.Match(context.Background(), path); err != nil || found {
+		t.Fatalf("want: not found, got: %t %v", found, err)
+	}
+	id, found, err := m.Recheck(context.Background(), path)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if id != "media-1" || !found {
+		t.Errorf("want: media-1 true, got: %s %t", id, found)
+	}
+	if search.searches != 2 {
+		t.Errorf("want: %d searches, got: %d", 2, search.searches)
+	}
+}
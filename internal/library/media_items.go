@@ -0,0 +1,155 @@
+package library
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+
+	"github.com/gphotosuploader/google-photos-api-client-go/v2/media_items"
+	"google.golang.org/api/googleapi"
+)
+
+// MediaItemsService creates and gets media items using a media items repository, like the one of the client
+// library does, but the repository could use any base URL (see media_items.NewPhotosLibraryClientWithURL).
+type MediaItemsService struct {
+	Repo media_items.Repository
+
+	// Client and BatchCreateEndpoint create the media items with a description, since the repository
+	// doesn't send it. Media items with a description can't be created if Client is not set.
+	Client              HttpClient
+	BatchCreateEndpoint string
+}
+
+// Create creates a media item, added to the end of the library.
+func (s MediaItemsService) Create(ctx context.Context, mediaItem media_items.SimpleMediaItem) (media_items.MediaItem, error) {
+	return s.CreateToAlbum(ctx, "", mediaItem)
+}
+
+// CreateMany creates one or more media items, added to the end of the library.
+func (s MediaItemsService) CreateMany(ctx context.Context, mediaItems []media_items.SimpleMediaItem) ([]media_items.MediaItem, error) {
+	return s.Repo.CreateMany(ctx, mediaItems)
+}
+
+// CreateToAlbum creates a media item, added to the album if its ID is not empty.
+func (s MediaItemsService) CreateToAlbum(ctx context.Context, albumId string, mediaItem media_items.SimpleMediaItem) (media_items.MediaItem, error) {
+	result, err := s.CreateManyToAlbum(ctx, albumId, []media_items.SimpleMediaItem{mediaItem})
+	if err != nil {
+		return media_items.NullMediaItem, err
+	}
+	return result[0], nil
+}
+
+// maxDescriptionLength is the maximum number of characters of a media item description.
+const maxDescriptionLength = 999
+
+// CreateToAlbumWithDescription creates a media item with the description, added to the album if its ID is not empty.
+// Descriptions longer than the API allows are truncated. The file name is sent without its folder. Empty descriptions are omitted, like CreateToAlbum does.
+func (s MediaItemsService) CreateToAlbumWithDescription(ctx context.Context, albumId string, mediaItem media_items.SimpleMediaItem, description string) (media_items.MediaItem, error) {
+	if description == "" {
+		return s.CreateToAlbum(ctx, albumId, mediaItem)
+	}
+	if s.Client == nil {
+		return media_items.NullMediaItem, errors.New("media items with a description can't be created without a client")
+	}
+	if r := []rune(description); len(r) > maxDescriptionLength {
+		description = string(r[:maxDescriptionLength])
+	}
+
+	b, err := json.Marshal(batchCreateRequest{
+		AlbumID: albumId,
+		NewMediaItems: []newMediaItem{{
+			Description:     description,
+			SimpleMediaItem: simpleMediaItem{UploadToken: mediaItem.UploadToken, FileName: filepath.Base(mediaItem.FileName)},
+		}},
+	})
+	if err != nil {
+		return media_items.NullMediaItem, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", s.BatchCreateEndpoint, bytes.NewReader(b))
+	if err != nil {
+		return media_items.NullMediaItem, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return media_items.NullMediaItem, err
+	}
+	defer resp.Body.Close()
+	if err := googleapi.CheckResponse(resp); err != nil {
+		return media_items.NullMediaItem, err
+	}
+	var res batchCreateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return media_items.NullMediaItem, err
+	}
+	if len(res.NewMediaItemResults) == 0 {
+		return media_items.NullMediaItem, errors.New("media item was not created")
+	}
+	result := res.NewMediaItemResults[0]
+	if result.MediaItem.ID == "" {
+		return media_items.NullMediaItem, fmt.Errorf("media item was not created: %s", result.Status.Message)
+	}
+	return media_items.MediaItem{
+		ID:          result.MediaItem.ID,
+		Description: result.MediaItem.Description,
+		ProductURL:  result.MediaItem.ProductURL,
+		BaseURL:     result.MediaItem.BaseURL,
+		MimeType:    result.MediaItem.MimeType,
+		Filename:    result.MediaItem.Filename,
+	}, nil
+}
+
+// CreateManyToAlbum creates one or more media items, added to the album if its ID is not empty.
+func (s MediaItemsService) CreateManyToAlbum(ctx context.Context, albumId string, mediaItems []media_items.SimpleMediaItem) ([]media_items.MediaItem, error) {
+	return s.Repo.CreateManyToAlbum(ctx, albumId, mediaItems)
+}
+
+// Get returns the media item. It returns media_items.ErrMediaItemNotFound if it could not be got.
+func (s MediaItemsService) Get(ctx context.Context, mediaItemId string) (*media_items.MediaItem, error) {
+	mediaItem, err := s.Repo.Get(ctx, mediaItemId)
+	if err != nil {
+		return &media_items.NullMediaItem, media_items.ErrMediaItemNotFound
+	}
+	return mediaItem, nil
+}
+
+// ListByAlbum returns all the media items in the album.
+func (s MediaItemsService) ListByAlbum(ctx context.Context, albumId string) ([]media_items.MediaItem, error) {
+	return s.Repo.ListByAlbum(ctx, albumId)
+}
+
+type batchCreateRequest struct {
+	AlbumID       string         `json:"albumId,omitempty"`
+	NewMediaItems []newMediaItem `json:"newMediaItems"`
+}
+
+type newMediaItem struct {
+	Description     string          `json:"description,omitempty"`
+	SimpleMediaItem simpleMediaItem `json:"simpleMediaItem"`
+}
+
+type simpleMediaItem struct {
+	UploadToken string `json:"uploadToken"`
+	FileName    string `json:"fileName,omitempty"`
+}
+
+type batchCreateResponse struct {
+	NewMediaItemResults []struct {
+		Status struct {
+			Message string `json:"message"`
+		} `json:"status"`
+		MediaItem struct {
+			ID          string `json:"id"`
+			Description string `json:"description"`
+			ProductURL  string `json:"productUrl"`
+			BaseURL     string `json:"baseUrl"`
+			MimeType    string `json:"mimeType"`
+			Filename    string `json:"filename"`
+		} `json:"mediaItem"`
+	} `json:"newMediaItemResults"`
+}
@@ -0,0 +1,61 @@
+package library_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gphotosuploader/google-photos-api-client-go/v2/media_items"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/library"
+)
+
+func TestMediaItemsService_CreateToAlbumWithDescription(t *testing.T) {
+	var body map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("error was not expected at this point: %s", err)
+		}
+		_, _ = w.Write([]byte(`{"newMediaItemResults":[{"uploadToken":"token-1","status":{"message":"Success"},"mediaItem":{"id":"media-1","description":"At the beach","filename":"IMG_0001.jpg"}}]}`))
+	}))
+	defer srv.Close()
+
+	s := library.MediaItemsService{Client: srv.Client(), BatchCreateEndpoint: srv.URL + "/v1/mediaItems:batchCreate"}
+	got, err := s.CreateToAlbumWithDescription(context.Background(), "album-1", media_items.SimpleMediaItem{
+		UploadToken: "token-1",
+		FileName:    "/photos/IMG_0001.jpg",
+	}, "At the beach")
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if got.ID != "media-1" {
+		t.Errorf("want: %v, got: %v", "media-1", got.ID)
+	}
+
+	want := map[string]interface{}{
+		"albumId": "album-1",
+		"newMediaItems": []interface{}{map[string]interface{}{
+			"description":     "At the beach",
+			"simpleMediaItem": map[string]interface{}{"uploadToken": "token-1", "fileName": "IMG_0001.jpg"},
+		}},
+	}
+	if !reflect.DeepEqual(want, body) {
+		t.Errorf("want: %v, got: %v", want, body)
+	}
+}
+
+func TestMediaItemsService_CreateToAlbumWithDescription_Failed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"newMediaItemResults":[{"uploadToken":"token-1","status":{"code":3,"message":"Invalid upload token"}}]}`))
+	}))
+	defer srv.Close()
+
+	s := library.MediaItemsService{Client: srv.Client(), BatchCreateEndpoint: srv.URL + "/v1/mediaItems:batchCreate"}
+	_, err := s.CreateToAlbumWithDescription(context.Background(), "", media_items.SimpleMediaItem{UploadToken: "token-1"}, "At the beach")
+	if err == nil {
+		t.Fatalf("error was expected, but not produced")
+	}
+}
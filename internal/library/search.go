@@ -0,0 +1,162 @@
+package library
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"google.golang.org/api/googleapi"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/ratelimit"
+)
+
+// MediaItem is a media item found in the library.
+type MediaItem struct {
+	ID       string
+	Filename string
+	// CreationTime is when the media item was created, usually its capture time.
+	CreationTime time.Time
+}
+
+// SearchService searches the media items of the library, including the ones uploaded by other tools
+// or the mobile app.
+type SearchService struct {
+	client HttpClient
+
+	// Endpoint is the URL to search the media items.
+	// Useful for testing.
+	Endpoint string
+
+	// Limiter limits the search requests, one per page, to respect the API quotas. Nil means unlimited.
+	Limiter *ratelimit.Limiter
+}
+
+// NewSearchService returns a SearchService using the authenticated client.
+func NewSearchService(client HttpClient) *SearchService {
+	return &SearchService{client: client, Endpoint: NewEndpoints("").MediaItemsSearch()}
+}
+
+// searchPageSize is the maximum number of media items returned by every search request.
+const searchPageSize = 100
+
+// ByDate returns the media items created between the days of from and to, both included.
+// Search results are paginated, so it could take several requests.
+func (s *SearchService) ByDate(ctx context.Context, from time.Time, to time.Time) ([]MediaItem, error) {
+	var items []MediaItem
+	pageToken := ""
+	for {
+		res, err := s.search(ctx, searchRequest{
+			PageSize:  searchPageSize,
+			PageToken: pageToken,
+			Filters: &searchFilters{DateFilter: dateFilter{Ranges: []dateRange{
+				{StartDate: newDate(from), EndDate: newDate(to)},
+			}}},
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range res.MediaItems {
+			creationTime, _ := time.Parse(time.RFC3339, m.MediaMetadata.CreationTime)
+			items = append(items, MediaItem{ID: m.ID, Filename: m.Filename, CreationTime: creationTime})
+		}
+		if res.NextPageToken == "" {
+			return items, nil
+		}
+		pageToken = res.NextPageToken
+	}
+}
+
+// IsAlbumEmpty returns true if the album has no media items. Pages of the search results could be empty while
+// there are more pages, so it could take several requests.
+func (s *SearchService) IsAlbumEmpty(ctx context.Context, albumID string) (bool, error) {
+	pageToken := ""
+	for {
+		res, err := s.search(ctx, searchRequest{
+			AlbumID:   albumID,
+			PageSize:  searchPageSize,
+			PageToken: pageToken,
+		})
+		if err != nil {
+			return false, err
+		}
+		if len(res.MediaItems) > 0 {
+			return false, nil
+		}
+		if res.NextPageToken == "" {
+			return true, nil
+		}
+		pageToken = res.NextPageToken
+	}
+}
+
+// search sends the search request, returning a *googleapi.Error if it is not successful.
+func (s *SearchService) search(ctx context.Context, body searchRequest) (searchResponse, error) {
+	var res searchResponse
+	if err := s.Limiter.WaitContext(ctx, 1); err != nil {
+		return res, err
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return res, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", s.Endpoint, bytes.NewReader(b))
+	if err != nil {
+		return res, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return res, err
+	}
+	defer resp.Body.Close()
+	if err := googleapi.CheckResponse(resp); err != nil {
+		return res, err
+	}
+	err = json.NewDecoder(resp.Body).Decode(&res)
+	return res, err
+}
+
+// searchRequest searches either the media items of an album, or the ones matching the filters.
+type searchRequest struct {
+	AlbumID   string         `json:"albumId,omitempty"`
+	PageSize  int            `json:"pageSize"`
+	PageToken string         `json:"pageToken,omitempty"`
+	Filters   *searchFilters `json:"filters,omitempty"`
+}
+
+type searchFilters struct {
+	DateFilter dateFilter `json:"dateFilter"`
+}
+
+type dateFilter struct {
+	Ranges []dateRange `json:"ranges"`
+}
+
+type dateRange struct {
+	StartDate date `json:"startDate"`
+	EndDate   date `json:"endDate"`
+}
+
+type date struct {
+	Year  int `json:"year"`
+	Month int `json:"month"`
+	Day   int `json:"day"`
+}
+
+func newDate(t time.Time) date {
+	return date{Year: t.Year(), Month: int(t.Month()), Day: t.Day()}
+}
+
+type searchResponse struct {
+	MediaItems []struct {
+		ID            string `json:"id"`
+		Filename      string `json:"filename"`
+		MediaMetadata struct {
+			CreationTime string `json:"creationTime"`
+		} `json:"mediaMetadata"`
+	} `json:"mediaItems"`
+	NextPageToken string `json:"nextPageToken"`
+}
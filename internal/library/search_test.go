@@ -0,0 +1,107 @@
+package library_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/library"
+)
+
+func TestSearchService_ByDate(t *testing.T) {
+	var requests []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("error was not expected at this point: %s", err)
+		}
+		requests = append(requests, body)
+		if body["pageToken"] == nil {
+			_, _ = w.Write([]byte(`{"mediaItems":[{"id":"media-1","filename":"IMG_0001.jpg","mediaMetadata":{"creationTime":"2021-06-01T10:00:00Z"}}],"nextPageToken":"page-2"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"mediaItems":[{"id":"media-2","filename":"IMG_0002.jpg","mediaMetadata":{"creationTime":"2021-06-02T10:00:00Z"}}]}`))
+	}))
+	defer srv.Close()
+
+	s := library.NewSearchService(srv.Client())
+	s.Endpoint = srv.URL + "/v1/mediaItems:search"
+	from := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	got, err := s.ByDate(context.Background(), from, from.AddDate(0, 0, 2))
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	if len(got) != 2 || got[0].ID != "media-1" || got[1].Filename != "IMG_0002.jpg" {
+		t.Errorf("want: media-1 and media-2, got: %v", got)
+	}
+	if want := time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC); !got[0].CreationTime.Equal(want) {
+		t.Errorf("want: %s, got: %s", want, got[0].CreationTime)
+	}
+	if len(requests) != 2 {
+		t.Fatalf("want: %d requests, got: %d", 2, len(requests))
+	}
+	b, _ := json.Marshal(requests[0]["filters"])
+	if want := `{"dateFilter":{"ranges":[{"endDate":{"day":3,"month":6,"year":2021},"startDate":{"day":1,"month":6,"year":2021}}]}}`; string(b) != want {
+		t.Errorf("want: %s, got: %s", want, b)
+	}
+}
+
+func TestSearchService_ByDateFailed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	s := library.NewSearchService(srv.Client())
+	s.Endpoint = srv.URL + "/v1/mediaItems:search"
+	if _, err := s.ByDate(context.Background(), time.Now(), time.Now()); err == nil {
+		t.Errorf("error was expected, but not produced")
+	}
+}
+
+func TestSearchService_IsAlbumEmpty(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("error was not expected at this point: %s", err)
+		}
+		if body["filters"] != nil {
+			t.Errorf("want: no filters, got: %v", body["filters"])
+		}
+		switch {
+		case body["albumId"] == "album-empty":
+			_, _ = w.Write([]byte(`{}`))
+		case body["albumId"] == "album-paginated" && body["pageToken"] == nil:
+			_, _ = w.Write([]byte(`{"nextPageToken":"page-2"}`))
+		default:
+			_, _ = w.Write([]byte(`{"mediaItems":[{"id":"media-1","filename":"IMG_0001.jpg"}]}`))
+		}
+	}))
+	defer srv.Close()
+
+	s := library.NewSearchService(srv.Client())
+	s.Endpoint = srv.URL + "/v1/mediaItems:search"
+	testCases := []struct {
+		albumID string
+		want    bool
+	}{
+		{"album-empty", true},
+		{"album-paginated", false},
+		{"album-full", false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.albumID, func(t *testing.T) {
+			got, err := s.IsAlbumEmpty(context.Background(), tc.albumID)
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			if tc.want != got {
+				t.Errorf("want: %v, got: %v", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package library
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"google.golang.org/api/googleapi"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/ratelimit"
+)
+
+// DefaultSharedAlbumsEndpoint is the Google Photos Library API endpoint of shared albums.
+const DefaultSharedAlbumsEndpoint = "https://photoslibrary.googleapis.com/v1/sharedAlbums"
+
+// SharedAlbum is an album shared with the account. Unlike the albums of the account, it could be owned by another
+// user, and media items could only be added to it if IsWriteable is set.
+type SharedAlbum struct {
+	ID          string
+	Title       string
+	ProductURL  string
+	IsWriteable bool
+	// ShareToken identifies the album to the users it's shared with.
+	ShareToken string
+	// IsJoined is true if the account has joined the album, IsOwned if the account owns it.
+	IsJoined bool
+	IsOwned  bool
+}
+
+// SharedAlbumsService gets and joins the albums shared with the account.
+type SharedAlbumsService struct {
+	client HttpClient
+
+	// Endpoint is the URL of the shared albums. Uses DefaultSharedAlbumsEndpoint by default.
+	// Useful for testing.
+	Endpoint string
+
+	// Limiter limits the requests listing the shared albums, one per page, to respect the API quotas. Nil means
+	// unlimited.
+	Limiter *ratelimit.Limiter
+}
+
+// NewSharedAlbumsService returns a SharedAlbumsService using the authenticated client.
+func NewSharedAlbumsService(client HttpClient) *SharedAlbumsService {
+	return &SharedAlbumsService{client: client, Endpoint: DefaultSharedAlbumsEndpoint}
+}
+
+// Get returns the shared album by its share token, even if the account has not joined it.
+func (s *SharedAlbumsService) Get(ctx context.Context, shareToken string) (SharedAlbum, error) {
+	var res sharedAlbumResponse
+	err := s.do(ctx, "GET", fmt.Sprintf("%s/%s", s.Endpoint, url.PathEscape(shareToken)), nil, &res)
+	return res.sharedAlbum(), err
+}
+
+// Join joins the account to the shared album by its share token, returning it.
+func (s *SharedAlbumsService) Join(ctx context.Context, shareToken string) (SharedAlbum, error) {
+	var res struct {
+		Album sharedAlbumResponse `json:"album"`
+	}
+	err := s.do(ctx, "POST", s.Endpoint+":join", map[string]string{"shareToken": shareToken}, &res)
+	return res.Album.sharedAlbum(), err
+}
+
+// List returns all the albums shared with the account, the ones it has joined or owns, in the order returned by the
+// API. Albums are paginated, so it could take several requests.
+func (s *SharedAlbumsService) List(ctx context.Context) ([]SharedAlbum, error) {
+	var result []SharedAlbum
+	pageToken := ""
+	for {
+		if err := s.Limiter.WaitContext(ctx, 1); err != nil {
+			return nil, err
+		}
+		params := url.Values{}
+		params.Set("pageSize", fmt.Sprint(albumsPageSize))
+		if pageToken != "" {
+			params.Set("pageToken", pageToken)
+		}
+		var res struct {
+			SharedAlbums  []sharedAlbumResponse `json:"sharedAlbums"`
+			NextPageToken string                `json:"nextPageToken"`
+		}
+		if err := s.do(ctx, "GET", s.Endpoint+"?"+params.Encode(), nil, &res); err != nil {
+			return nil, err
+		}
+		for _, a := range res.SharedAlbums {
+			result = append(result, a.sharedAlbum())
+		}
+		if res.NextPageToken == "" {
+			return result, nil
+		}
+		pageToken = res.NextPageToken
+	}
+}
+
+type sharedAlbumResponse struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	ProductURL  string `json:"productUrl"`
+	IsWriteable bool   `json:"isWriteable"`
+	ShareInfo   struct {
+		ShareToken string `json:"shareToken"`
+		IsJoined   bool   `json:"isJoined"`
+		IsOwned    bool   `json:"isOwned"`
+	} `json:"shareInfo"`
+}
+
+func (a sharedAlbumResponse) sharedAlbum() SharedAlbum {
+	return SharedAlbum{
+		ID:          a.ID,
+		Title:       a.Title,
+		ProductURL:  a.ProductURL,
+		IsWriteable: a.IsWriteable,
+		ShareToken:  a.ShareInfo.ShareToken,
+		IsJoined:    a.ShareInfo.IsJoined,
+		IsOwned:     a.ShareInfo.IsOwned,
+	}
+}
+
+// do sends the request, with the JSON body if it's not nil, and decodes the response into res. It returns a
+// *googleapi.Error if it is not successful.
+func (s *SharedAlbumsService) do(ctx context.Context, method string, url string, body interface{}, res interface{}) error {
+	var b []byte
+	if body != nil {
+		var err error
+		if b, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := googleapi.CheckResponse(resp); err != nil {
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(res)
+}
@@ -0,0 +1,116 @@
+package library_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"google.golang.org/api/googleapi"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/library"
+)
+
+func TestSharedAlbumsService_Get(t *testing.T) {
+	var gotMethod, gotPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod, gotPath = r.Method, r.URL.Path
+		_, _ = w.Write([]byte(`{"id":"album-1","title":"Family","isWriteable":true,"shareInfo":{"shareToken":"token-1","isJoined":true}}`))
+	}))
+	defer srv.Close()
+
+	s := library.NewSharedAlbumsService(srv.Client())
+	s.Endpoint = srv.URL + "/v1/sharedAlbums"
+	got, err := s.Get(context.Background(), "token-1")
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	if gotMethod != "GET" || gotPath != "/v1/sharedAlbums/token-1" {
+		t.Errorf("want: GET /v1/sharedAlbums/token-1, got: %s %s", gotMethod, gotPath)
+	}
+	want := library.SharedAlbum{ID: "album-1", Title: "Family", IsWriteable: true, ShareToken: "token-1", IsJoined: true}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want: %v, got: %v", want, got)
+	}
+}
+
+func TestSharedAlbumsService_GetNotFound(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	s := library.NewSharedAlbumsService(srv.Client())
+	s.Endpoint = srv.URL + "/v1/sharedAlbums"
+	_, err := s.Get(context.Background(), "token-1")
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != http.StatusNotFound {
+		t.Errorf("want: %d, got: %v", http.StatusNotFound, err)
+	}
+}
+
+func TestSharedAlbumsService_Join(t *testing.T) {
+	var gotMethod, gotPath string
+	var gotBody map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod, gotPath = r.Method, r.URL.Path
+		if err := json.NewDecoder(r.Body).Decode(&gotBody); err != nil {
+			t.Errorf("error was not expected at this point: %s", err)
+		}
+		_, _ = w.Write([]byte(`{"album":{"id":"album-1","title":"Family","isWriteable":true,"shareInfo":{"shareToken":"token-1","isJoined":true}}}`))
+	}))
+	defer srv.Close()
+
+	s := library.NewSharedAlbumsService(srv.Client())
+	s.Endpoint = srv.URL + "/v1/sharedAlbums"
+	got, err := s.Join(context.Background(), "token-1")
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	if gotMethod != "POST" || gotPath != "/v1/sharedAlbums:join" {
+		t.Errorf("want: POST /v1/sharedAlbums:join, got: %s %s", gotMethod, gotPath)
+	}
+	if want := "token-1"; gotBody["shareToken"] != want {
+		t.Errorf("want: %s, got: %s", want, gotBody["shareToken"])
+	}
+	if want := "album-1"; got.ID != want || !got.IsJoined {
+		t.Errorf("want: %s joined, got: %v", want, got)
+	}
+}
+
+func TestSharedAlbumsService_List(t *testing.T) {
+	var pageTokens []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pageTokens = append(pageTokens, r.URL.Query().Get("pageToken"))
+		switch r.URL.Query().Get("pageToken") {
+		case "":
+			_, _ = w.Write([]byte(`{"sharedAlbums":[{"id":"album-1","title":"Family","isWriteable":true}],"nextPageToken":"page-2"}`))
+		default:
+			_, _ = w.Write([]byte(`{"sharedAlbums":[{"id":"album-2","title":"Friends"}]}`))
+		}
+	}))
+	defer srv.Close()
+
+	s := library.NewSharedAlbumsService(srv.Client())
+	s.Endpoint = srv.URL + "/v1/sharedAlbums"
+	got, err := s.List(context.Background())
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	var titles []string
+	for _, a := range got {
+		titles = append(titles, a.ID+":"+a.Title)
+	}
+	if want := []string{"album-1:Family", "album-2:Friends"}; !reflect.DeepEqual(want, titles) {
+		t.Errorf("want: %v, got: %v", want, titles)
+	}
+	if want := []string{"", "page-2"}; !reflect.DeepEqual(want, pageTokens) {
+		t.Errorf("want: %v, got: %v", want, pageTokens)
+	}
+}
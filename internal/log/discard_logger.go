@@ -95,3 +95,6 @@ func (d *DiscardLogger) Write(message []byte) (int, error) {
 
 // WriteString implements logger interface
 func (d *DiscardLogger) WriteString(message string) {}
+
+// WithFields implements logger interface
+func (d *DiscardLogger) WithFields(fields Fields) Logger { return d }
@@ -0,0 +1,51 @@
+package log
+
+// Events are the values of the `event` field, identifying the structured log entries.
+const (
+	EventScanStart    = "scan_start"
+	EventFileUploaded = "file_uploaded"
+	EventFileAttached = "file_attached"
+	EventFileSkipped  = "file_skipped"
+	EventError        = "error"
+	EventRunSummary   = "run_summary"
+)
+
+// Reasons are the values of the `reason` field, a stable code of why a file was skipped or failed.
+const (
+	ReasonExcluded        = "excluded"
+	ReasonSymlink         = "symlink"
+	ReasonSymlinkLoop     = "symlink_loop"
+	ReasonHidden          = "hidden"
+	ReasonUnreadable      = "unreadable"
+	ReasonNoAlbum         = "no_album"
+	ReasonOtherAlbum      = "other_album"
+	ReasonExifMismatch    = "exif_mismatch"
+	ReasonMIMEType        = "mime_type"
+	ReasonTooSmall        = "too_small"
+	ReasonOutOfDateRange  = "out_of_date_range"
+	ReasonAlreadyUploaded = "already_uploaded"
+	ReasonCompletedInRun  = "completed_in_run"
+	ReasonDuplicate       = "duplicate"
+	ReasonNearDuplicate   = "near_duplicate"
+	ReasonInLibrary       = "in_library"
+	ReasonTooRecent       = "too_recent"
+	ReasonUnchanged       = "unchanged"
+	ReasonTooLarge        = "too_large"
+	ReasonUnsupportedType = "unsupported_type"
+	ReasonAlbumFailed     = "album_failed"
+	ReasonAttachFailed    = "attach_failed"
+	ReasonPartialBatch    = "partial_batch"
+	ReasonScanFailed      = "scan_failed"
+	ReasonAuthExpired     = "auth_expired"
+	ReasonFileNotFound    = "file_not_found"
+	ReasonUploadFailed    = "upload_failed"
+	ReasonQuotaExceeded   = "quota_exceeded"
+	ReasonStorageFull     = "storage_full"
+	ReasonLowFreeSpace    = "low_free_space"
+	ReasonUnauthorized    = "unauthorized"
+	ReasonUnsupported     = "unsupported_media"
+	ReasonNetwork         = "network_error"
+	ReasonTimeout         = "timeout"
+	ReasonRetryBackoff    = "retry_backoff"
+	ReasonDeadLetter      = "dead_letter"
+)
@@ -157,3 +157,8 @@ func (f *fileLogger) Write(message []byte) (int, error) {
 func (f *fileLogger) WriteString(message string) {
 	_, _ = f.logger.Out.Write([]byte(message))
 }
+
+// WithFields returns the same logger, fields are not printed.
+func (f *fileLogger) WithFields(fields Fields) Logger {
+	return f
+}
@@ -0,0 +1,180 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// jsonOutput is the output shared by a JSON logger and the loggers created using WithFields.
+type jsonOutput struct {
+	mu    sync.Mutex
+	out   io.Writer
+	level logrus.Level
+}
+
+// jsonLogger writes one JSON object per log entry, with the `time`, `level` and `msg` fields,
+// and the fields added using WithFields.
+type jsonLogger struct {
+	output *jsonOutput
+	fields Fields
+}
+
+// NewJSONLogger returns a logger writing JSON lines to out.
+func NewJSONLogger(out io.Writer) Logger {
+	return &jsonLogger{
+		output: &jsonOutput{out: out, level: logrus.InfoLevel},
+	}
+}
+
+func (j *jsonLogger) write(level logrus.Level, message string) {
+	j.output.mu.Lock()
+	defer j.output.mu.Unlock()
+
+	if j.output.level < level {
+		return
+	}
+
+	entry := make(map[string]interface{}, len(j.fields)+3)
+	for k, v := range j.fields {
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		entry[k] = v
+	}
+	entry["time"] = time.Now().Format(time.RFC3339Nano)
+	entry["level"] = level.String()
+	entry["msg"] = strings.TrimRight(message, "\r\n")
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	_, _ = j.output.out.Write(append(b, '\n'))
+}
+
+func (j *jsonLogger) Debug(args ...interface{}) {
+	j.write(logrus.DebugLevel, fmt.Sprint(args...))
+}
+
+func (j *jsonLogger) Debugf(format string, args ...interface{}) {
+	j.write(logrus.DebugLevel, fmt.Sprintf(format, args...))
+}
+
+func (j *jsonLogger) Info(args ...interface{}) {
+	j.write(logrus.InfoLevel, fmt.Sprint(args...))
+}
+
+func (j *jsonLogger) Infof(format string, args ...interface{}) {
+	j.write(logrus.InfoLevel, fmt.Sprintf(format, args...))
+}
+
+func (j *jsonLogger) Warn(args ...interface{}) {
+	j.write(logrus.WarnLevel, fmt.Sprint(args...))
+}
+
+func (j *jsonLogger) Warnf(format string, args ...interface{}) {
+	j.write(logrus.WarnLevel, fmt.Sprintf(format, args...))
+}
+
+func (j *jsonLogger) Error(args ...interface{}) {
+	j.write(logrus.ErrorLevel, fmt.Sprint(args...))
+}
+
+func (j *jsonLogger) Errorf(format string, args ...interface{}) {
+	j.write(logrus.ErrorLevel, fmt.Sprintf(format, args...))
+}
+
+func (j *jsonLogger) Fatal(args ...interface{}) {
+	j.write(logrus.FatalLevel, fmt.Sprint(args...))
+	os.Exit(1)
+}
+
+func (j *jsonLogger) Fatalf(format string, args ...interface{}) {
+	j.write(logrus.FatalLevel, fmt.Sprintf(format, args...))
+	os.Exit(1)
+}
+
+func (j *jsonLogger) Panic(args ...interface{}) {
+	j.write(logrus.PanicLevel, fmt.Sprint(args...))
+	panic(fmt.Sprint(args...))
+}
+
+func (j *jsonLogger) Panicf(format string, args ...interface{}) {
+	j.write(logrus.PanicLevel, fmt.Sprintf(format, args...))
+	panic(fmt.Sprintf(format, args...))
+}
+
+// Done logs at info level.
+func (j *jsonLogger) Done(args ...interface{}) {
+	j.write(logrus.InfoLevel, fmt.Sprint(args...))
+}
+
+// Donef logs at info level.
+func (j *jsonLogger) Donef(format string, args ...interface{}) {
+	j.write(logrus.InfoLevel, fmt.Sprintf(format, args...))
+}
+
+// Fail logs at error level.
+func (j *jsonLogger) Fail(args ...interface{}) {
+	j.write(logrus.ErrorLevel, fmt.Sprint(args...))
+}
+
+// Failf logs at error level.
+func (j *jsonLogger) Failf(format string, args ...interface{}) {
+	j.write(logrus.ErrorLevel, fmt.Sprintf(format, args...))
+}
+
+func (j *jsonLogger) Print(level logrus.Level, args ...interface{}) {
+	j.write(level, fmt.Sprint(args...))
+}
+
+func (j *jsonLogger) Printf(level logrus.Level, format string, args ...interface{}) {
+	j.write(level, fmt.Sprintf(format, args...))
+}
+
+// Write logs the message at info level, so the output is always JSON lines.
+func (j *jsonLogger) Write(message []byte) (int, error) {
+	j.WriteString(string(message))
+	return len(message), nil
+}
+
+// WriteString logs the message at info level, so the output is always JSON lines.
+func (j *jsonLogger) WriteString(message string) {
+	if strings.TrimSpace(message) == "" {
+		return
+	}
+	j.write(logrus.InfoLevel, message)
+}
+
+func (j *jsonLogger) SetLevel(level logrus.Level) {
+	j.output.mu.Lock()
+	defer j.output.mu.Unlock()
+
+	j.output.level = level
+}
+
+func (j *jsonLogger) GetLevel() logrus.Level {
+	j.output.mu.Lock()
+	defer j.output.mu.Unlock()
+
+	return j.output.level
+}
+
+// WithFields returns a logger adding the fields to the ones of this logger.
+func (j *jsonLogger) WithFields(fields Fields) Logger {
+	merged := make(Fields, len(j.fields)+len(fields))
+	for k, v := range j.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return &jsonLogger{output: j.output, fields: merged}
+}
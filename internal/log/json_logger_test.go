@@ -0,0 +1,53 @@
+package log_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+)
+
+func TestJSONLogger_WithFields(t *testing.T) {
+	var out bytes.Buffer
+	logger := log.NewJSONLogger(&out)
+
+	logger.WithFields(log.Fields{"event": log.EventError, "path": "foo.jpg"}).
+		WithFields(log.Fields{"reason": log.ReasonUploadFailed}).
+		Failf("Error processing %s", "foo.jpg")
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("output is not a JSON line: %s, err: %s", out.String(), err)
+	}
+	want := map[string]interface{}{
+		"level":  "error",
+		"msg":    "Error processing foo.jpg",
+		"event":  "error",
+		"path":   "foo.jpg",
+		"reason": "upload_failed",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("want: %v, got: %v, field: %s", v, got[k], k)
+		}
+	}
+}
+
+func TestJSONLogger_SetLevel(t *testing.T) {
+	var out bytes.Buffer
+	logger := log.NewJSONLogger(&out)
+
+	logger.Debug("hidden")
+	logger.SetLevel(logrus.DebugLevel)
+	logger.WithFields(log.Fields{"event": log.EventFileSkipped}).Debug("shown")
+	logger.Info("shown")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Errorf("want: %d lines, got: %d, output: %s", 2, len(lines), out.String())
+	}
+}
@@ -0,0 +1,255 @@
+package log
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Modules are the values of the `module` field, the subsystems whose debug entries could be enabled on their own.
+const (
+	ModuleFilter = "filter"
+	ModuleScan   = "scan"
+	ModuleUpload = "upload"
+)
+
+// Modules are all the modules, see WithModule.
+var Modules = []string{ModuleFilter, ModuleScan, ModuleUpload}
+
+// WithModule returns a logger adding the module to every log entry, so its debug entries are written if the
+// module is enabled, even if the level of the logger is not debug. See NewLeveledLogger.
+func WithModule(logger Logger, module string) Logger {
+	return logger.WithFields(Fields{"module": module})
+}
+
+// ParseLevel returns the level of the name: error, warn, info or debug.
+func ParseLevel(name string) (logrus.Level, error) {
+	switch strings.ToLower(name) {
+	case "error":
+		return logrus.ErrorLevel, nil
+	case "warn":
+		return logrus.WarnLevel, nil
+	case "info":
+		return logrus.InfoLevel, nil
+	case "debug":
+		return logrus.DebugLevel, nil
+	}
+	return logrus.InfoLevel, fmt.Errorf("unknown log level '%s'", name)
+}
+
+// ValidateModules returns error if any of the modules is not one of Modules.
+func ValidateModules(modules []string) error {
+	for _, m := range modules {
+		if !isModule(m) {
+			return fmt.Errorf("unknown module '%s', valid ones are %s", m, strings.Join(Modules, ", "))
+		}
+	}
+	return nil
+}
+
+// leveledLevels are the levels shared by a leveled logger and the loggers created using WithFields.
+type leveledLevels struct {
+	mu    sync.Mutex
+	level logrus.Level
+	// debugModules are the modules whose debug entries are written whatever the level is.
+	debugModules map[string]bool
+}
+
+// leveledLogger writes the entries of its level, and the debug entries of the enabled modules, to another logger.
+type leveledLogger struct {
+	logger Logger
+	levels *leveledLevels
+	// module is the module of the entries, empty if they don't belong to any.
+	module string
+}
+
+// NewLeveledLogger returns a logger writing to logger the entries of the level, or a more severe one, and the debug
+// entries of debugModules. Levels are enforced by the returned logger, the level of logger is changed to write them.
+// If logger is a leveled logger already, it's replaced.
+func NewLeveledLogger(logger Logger, level logrus.Level, debugModules []string) Logger {
+	if l, ok := logger.(*leveledLogger); ok {
+		logger = l.logger
+	}
+	l := &leveledLogger{
+		logger: logger,
+		levels: &leveledLevels{level: level, debugModules: make(map[string]bool)},
+	}
+	for _, m := range debugModules {
+		l.levels.debugModules[m] = true
+	}
+	l.SetLevel(level)
+	return l
+}
+
+// enabled returns if the entries of the level are written.
+func (l *leveledLogger) enabled(level logrus.Level) bool {
+	return level <= l.GetLevel()
+}
+
+func (l *leveledLogger) Debug(args ...interface{}) {
+	if l.enabled(logrus.DebugLevel) {
+		l.logger.Debug(args...)
+	}
+}
+
+func (l *leveledLogger) Debugf(format string, args ...interface{}) {
+	if l.enabled(logrus.DebugLevel) {
+		l.logger.Debugf(format, args...)
+	}
+}
+
+func (l *leveledLogger) Info(args ...interface{}) {
+	if l.enabled(logrus.InfoLevel) {
+		l.logger.Info(args...)
+	}
+}
+
+func (l *leveledLogger) Infof(format string, args ...interface{}) {
+	if l.enabled(logrus.InfoLevel) {
+		l.logger.Infof(format, args...)
+	}
+}
+
+func (l *leveledLogger) Warn(args ...interface{}) {
+	if l.enabled(logrus.WarnLevel) {
+		l.logger.Warn(args...)
+	}
+}
+
+func (l *leveledLogger) Warnf(format string, args ...interface{}) {
+	if l.enabled(logrus.WarnLevel) {
+		l.logger.Warnf(format, args...)
+	}
+}
+
+func (l *leveledLogger) Error(args ...interface{}) {
+	if l.enabled(logrus.ErrorLevel) {
+		l.logger.Error(args...)
+	}
+}
+
+func (l *leveledLogger) Errorf(format string, args ...interface{}) {
+	if l.enabled(logrus.ErrorLevel) {
+		l.logger.Errorf(format, args...)
+	}
+}
+
+// Fatal is always written, since it exits.
+func (l *leveledLogger) Fatal(args ...interface{}) {
+	l.logger.Fatal(args...)
+}
+
+// Fatalf is always written, since it exits.
+func (l *leveledLogger) Fatalf(format string, args ...interface{}) {
+	l.logger.Fatalf(format, args...)
+}
+
+// Panic is always written, since it panics.
+func (l *leveledLogger) Panic(args ...interface{}) {
+	l.logger.Panic(args...)
+}
+
+// Panicf is always written, since it panics.
+func (l *leveledLogger) Panicf(format string, args ...interface{}) {
+	l.logger.Panicf(format, args...)
+}
+
+// Done logs at info level.
+func (l *leveledLogger) Done(args ...interface{}) {
+	if l.enabled(logrus.InfoLevel) {
+		l.logger.Done(args...)
+	}
+}
+
+// Donef logs at info level.
+func (l *leveledLogger) Donef(format string, args ...interface{}) {
+	if l.enabled(logrus.InfoLevel) {
+		l.logger.Donef(format, args...)
+	}
+}
+
+// Fail logs at error level.
+func (l *leveledLogger) Fail(args ...interface{}) {
+	if l.enabled(logrus.ErrorLevel) {
+		l.logger.Fail(args...)
+	}
+}
+
+// Failf logs at error level.
+func (l *leveledLogger) Failf(format string, args ...interface{}) {
+	if l.enabled(logrus.ErrorLevel) {
+		l.logger.Failf(format, args...)
+	}
+}
+
+func (l *leveledLogger) Print(level logrus.Level, args ...interface{}) {
+	if l.enabled(level) {
+		l.logger.Print(level, args...)
+	}
+}
+
+func (l *leveledLogger) Printf(level logrus.Level, format string, args ...interface{}) {
+	if l.enabled(level) {
+		l.logger.Printf(level, format, args...)
+	}
+}
+
+// Write writes the message at info level.
+func (l *leveledLogger) Write(message []byte) (int, error) {
+	if !l.enabled(logrus.InfoLevel) {
+		return len(message), nil
+	}
+	return l.logger.Write(message)
+}
+
+// WriteString writes the message at info level.
+func (l *leveledLogger) WriteString(message string) {
+	if l.enabled(logrus.InfoLevel) {
+		l.logger.WriteString(message)
+	}
+}
+
+// SetLevel changes the level of the logger, and of the loggers created using WithFields.
+func (l *leveledLogger) SetLevel(level logrus.Level) {
+	l.levels.mu.Lock()
+	defer l.levels.mu.Unlock()
+
+	l.levels.level = level
+	// the entries of the enabled modules are filtered by this logger.
+	if len(l.levels.debugModules) > 0 && level < logrus.DebugLevel {
+		level = logrus.DebugLevel
+	}
+	l.logger.SetLevel(level)
+}
+
+// GetLevel returns the level of the logger, which is debug if its module is enabled.
+func (l *leveledLogger) GetLevel() logrus.Level {
+	l.levels.mu.Lock()
+	defer l.levels.mu.Unlock()
+
+	if l.levels.debugModules[l.module] && l.levels.level < logrus.DebugLevel {
+		return logrus.DebugLevel
+	}
+	return l.levels.level
+}
+
+// WithFields returns a logger adding the fields to every log entry. The `module` field sets the module of
+// the entries.
+func (l *leveledLogger) WithFields(fields Fields) Logger {
+	module := l.module
+	if m, ok := fields["module"].(string); ok {
+		module = m
+	}
+	return &leveledLogger{logger: l.logger.WithFields(fields), levels: l.levels, module: module}
+}
+
+func isModule(name string) bool {
+	for _, m := range Modules {
+		if m == name {
+			return true
+		}
+	}
+	return false
+}
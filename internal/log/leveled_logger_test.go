@@ -0,0 +1,108 @@
+package log_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+)
+
+func TestLeveledLogger_DebugModules(t *testing.T) {
+	testCases := []struct {
+		name         string
+		level        logrus.Level
+		debugModules []string
+		want         []string
+	}{
+		{"Should log debug entries of enabled modules only", logrus.InfoLevel, []string{log.ModuleFilter}, []string{"filter debug", "info", "warn"}},
+		{"Should log debug entries of all modules at debug level", logrus.DebugLevel, nil, []string{"filter debug", "scan debug", "debug", "info", "warn"}},
+		{"Should not log debug entries without enabled modules", logrus.InfoLevel, nil, []string{"info", "warn"}},
+		{"Should enforce level with enabled modules", logrus.WarnLevel, []string{log.ModuleFilter, log.ModuleScan}, []string{"filter debug", "scan debug", "warn"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			logger := log.NewLeveledLogger(log.NewJSONLogger(&out), tc.level, tc.debugModules)
+
+			log.WithModule(logger, log.ModuleFilter).Debug("filter debug")
+			log.WithModule(logger, log.ModuleScan).Debugf("scan %s", "debug")
+			logger.Debug("debug")
+			logger.Info("info")
+			logger.WithFields(log.Fields{"event": log.EventError}).Warn("warn")
+
+			var got []string
+			for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+				if line == "" {
+					continue
+				}
+				var entry map[string]interface{}
+				if err := json.Unmarshal([]byte(line), &entry); err != nil {
+					t.Fatalf("output is not a JSON line: %s, err: %s", line, err)
+				}
+				got = append(got, entry["msg"].(string))
+			}
+			if strings.Join(tc.want, ",") != strings.Join(got, ",") {
+				t.Errorf("want: %v, got: %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestLeveledLogger_GetLevel(t *testing.T) {
+	logger := log.NewLeveledLogger(log.NewJSONLogger(&bytes.Buffer{}), logrus.InfoLevel, []string{log.ModuleFilter})
+
+	if got := log.WithModule(logger, log.ModuleFilter).GetLevel(); got != logrus.DebugLevel {
+		t.Errorf("want: %v, got: %v", logrus.DebugLevel, got)
+	}
+	if got := log.WithModule(logger, log.ModuleScan).GetLevel(); got != logrus.InfoLevel {
+		t.Errorf("want: %v, got: %v", logrus.InfoLevel, got)
+	}
+	logger.SetLevel(logrus.ErrorLevel)
+	if got := log.WithModule(logger, log.ModuleScan).GetLevel(); got != logrus.ErrorLevel {
+		t.Errorf("want: %v, got: %v", logrus.ErrorLevel, got)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	testCases := []struct {
+		name          string
+		input         string
+		want          logrus.Level
+		isErrExpected bool
+	}{
+		{"Should parse error", "error", logrus.ErrorLevel, false},
+		{"Should parse warn", "warn", logrus.WarnLevel, false},
+		{"Should parse info", "info", logrus.InfoLevel, false},
+		{"Should parse debug regardless of case", "DEBUG", logrus.DebugLevel, false},
+		{"Should fail with unknown level", "trace", logrus.InfoLevel, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := log.ParseLevel(tc.input)
+			if tc.isErrExpected && err == nil {
+				t.Fatalf("error was expected, but not produced")
+			}
+			if !tc.isErrExpected && err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			if tc.want != got {
+				t.Errorf("want: %v, got: %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestValidateModules(t *testing.T) {
+	if err := log.ValidateModules([]string{log.ModuleFilter, log.ModuleScan}); err != nil {
+		t.Errorf("error was not expected at this point: %s", err)
+	}
+	if err := log.ValidateModules([]string{"albums"}); err == nil {
+		t.Errorf("error was expected, but not produced")
+	}
+}
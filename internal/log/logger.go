@@ -52,4 +52,11 @@ type Logger interface {
 
 	SetLevel(level logrus.Level)
 	GetLevel() logrus.Level
+
+	// WithFields returns a logger adding the fields to every log entry.
+	// Fields are only printed by structured loggers, like the JSON logger.
+	WithFields(fields Fields) Logger
 }
+
+// Fields are the structured data of a log entry.
+type Fields map[string]interface{}
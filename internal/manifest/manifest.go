@@ -0,0 +1,157 @@
+// Package manifest reads and writes the manifests of the files selected by a scan, so they could be reviewed
+// before uploading exactly them.
+package manifest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/upload"
+)
+
+// Version is the version of the manifests written by this version.
+const Version = 1
+
+// fileMode is the mode of the written manifests, they are only readable by the owner.
+const fileMode = 0600
+
+// ErrChanged is returned when a file has changed since it was scanned.
+var ErrChanged = errors.New("file has changed since it was scanned")
+
+// Manifest is the list of files selected by a scan.
+type Manifest struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"createdAt"`
+	Entries   []Entry   `json:"entries"`
+}
+
+// Entry is a file selected by a scan, with the decisions taken about it.
+type Entry struct {
+	// Path is the absolute path of the file.
+	Path string `json:"path"`
+	// Size is the size of the file, in bytes, when it was scanned.
+	Size int64 `json:"size"`
+	// Hash is the hex encoded SHA-256 of the file content when it was scanned.
+	Hash string `json:"hash"`
+	// Account is the Google Photos account where the file is uploaded.
+	Account string `json:"account"`
+	// Album is the album where the file is added, if any.
+	Album string `json:"album,omitempty"`
+	// MediaItemID, if it's set, is the media item of the file, that has been uploaded already. It's only
+	// added to the album.
+	MediaItemID string `json:"mediaItemId,omitempty"`
+	// Favorite is true if the file should be marked as favorite.
+	Favorite bool `json:"favorite,omitempty"`
+}
+
+// NewEntry returns the entry of the scanned item, reading the size and the hash of its file. The hash is kept by
+// hashes, if it's set, for the run.
+func NewEntry(item upload.FileItem, account string, hashes *upload.ContentHashes) (Entry, error) {
+	fi, err := os.Stat(item.Path)
+	if err != nil {
+		return Entry{}, err
+	}
+	hash, err := hashes.Hash(item.Path)
+	if err != nil {
+		return Entry{}, err
+	}
+	return Entry{
+		Path:        item.Path,
+		Size:        fi.Size(),
+		Hash:        hash,
+		Account:     account,
+		Album:       item.AlbumName,
+		MediaItemID: item.MediaItemID,
+		Favorite:    item.Favorite,
+	}, nil
+}
+
+// Check returns ErrChanged if the file has changed since it was scanned, comparing its size first,
+// and then its hash. The hash is kept by hashes, if it's set, so it's not read again to upload it.
+func (e Entry) Check(hashes *upload.ContentHashes) error {
+	fi, err := os.Stat(e.Path)
+	if err != nil {
+		return err
+	}
+	if fi.Size() != e.Size {
+		return ErrChanged
+	}
+	hash, err := hashes.Hash(e.Path)
+	if err != nil {
+		return err
+	}
+	if hash != e.Hash {
+		return ErrChanged
+	}
+	return nil
+}
+
+// FileItem returns the item to be uploaded.
+func (e Entry) FileItem() upload.FileItem {
+	return upload.FileItem{
+		Path:        e.Path,
+		AlbumName:   e.Album,
+		MediaItemID: e.MediaItemID,
+		Favorite:    e.Favorite,
+	}
+}
+
+// Write writes the manifest as indented JSON, so it could be reviewed.
+func Write(w io.Writer, m Manifest) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// WriteFile writes the manifest to the file, replacing it if it exists.
+func WriteFile(filename string, m Manifest) error {
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, fileMode)
+	if err != nil {
+		return err
+	}
+	if err := Write(f, m); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Read reads the manifest, checking that its version is supported and all its entries are valid.
+func Read(r io.Reader) (Manifest, error) {
+	var m Manifest
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return m, err
+	}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return m, fmt.Errorf("invalid manifest: %w", err)
+	}
+	if m.Version != Version {
+		return m, fmt.Errorf("unsupported manifest version %d, want %d", m.Version, Version)
+	}
+	for i, e := range m.Entries {
+		if !filepath.IsAbs(e.Path) || e.Hash == "" || e.Account == "" {
+			return m, fmt.Errorf("invalid manifest entry %d: it must have an absolute path, a hash and an account", i+1)
+		}
+	}
+	return m, nil
+}
+
+// ReadFile reads the manifest from the file.
+func ReadFile(filename string) (Manifest, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return Manifest{}, err
+	}
+	defer f.Close()
+	return Read(f)
+}
@@ -0,0 +1,123 @@
+package manifest_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/manifest"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/upload"
+)
+
+func TestWriteFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "manifest")
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	photo := filepath.Join(dir, "IMG_0001.jpg")
+	if err := ioutil.WriteFile(photo, []byte("photo"), 0600); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	entry, err := manifest.NewEntry(upload.FileItem{Path: photo, AlbumName: "Trip", Favorite: true}, "me@domain.com", nil)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	want := manifest.Manifest{
+		Version:   manifest.Version,
+		CreatedAt: time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC),
+		Entries: []manifest.Entry{
+			entry,
+			{Path: filepath.Join(dir, "IMG_0002.jpg"), Size: 10, Hash: "abc", Account: "me@domain.com", MediaItemID: "media-2"},
+		},
+	}
+
+	filename := filepath.Join(dir, "manifest.json")
+	if err := manifest.WriteFile(filename, want); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	got, err := manifest.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want: %v, got: %v", want, got)
+	}
+	// SHA-256 of "photo".
+	if want := "55c64d0fcd6f9d5f7c828093857e3fdfda68478bb4e9bd24d481ef391c7804e8"; want != entry.Hash {
+		t.Errorf("want: %v, got: %v", want, entry.Hash)
+	}
+	if want := int64(5); want != entry.Size {
+		t.Errorf("want: %v, got: %v", want, entry.Size)
+	}
+	if want := (upload.FileItem{Path: photo, AlbumName: "Trip", Favorite: true}); want != entry.FileItem() {
+		t.Errorf("want: %v, got: %v", want, entry.FileItem())
+	}
+}
+
+func TestRead(t *testing.T) {
+	testCases := []struct {
+		name   string
+		input  string
+		errExp bool
+	}{
+		{"Should read a manifest", `{"version":1,"entries":[{"path":"/photos/IMG_0001.jpg","size":5,"hash":"abc","account":"me@domain.com"}]}`, false},
+		{"Should read a manifest without entries", `{"version":1,"entries":[]}`, false},
+		{"Should fail if the version is not supported", `{"version":2,"entries":[]}`, true},
+		{"Should fail if the path is relative", `{"version":1,"entries":[{"path":"IMG_0001.jpg","size":5,"hash":"abc","account":"me@domain.com"}]}`, true},
+		{"Should fail if the hash is missing", `{"version":1,"entries":[{"path":"/photos/IMG_0001.jpg","size":5,"account":"me@domain.com"}]}`, true},
+		{"Should fail if JSON is invalid", `{"version":1,`, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := manifest.Read(strings.NewReader(tc.input))
+			if tc.errExp && err == nil {
+				t.Fatalf("error was expected, but not produced")
+			}
+			if !tc.errExp && err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+		})
+	}
+}
+
+func TestEntry_Check(t *testing.T) {
+	dir, err := ioutil.TempDir("", "manifest")
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	photo := filepath.Join(dir, "IMG_0001.jpg")
+	if err := ioutil.WriteFile(photo, []byte("photo"), 0600); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	entry, err := manifest.NewEntry(upload.FileItem{Path: photo}, "me@domain.com", nil)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if err := entry.Check(nil); err != nil {
+		t.Errorf("error was not expected at this point: %s", err)
+	}
+
+	// the content changes, keeping its size.
+	if err := ioutil.WriteFile(photo, []byte("other"), 0600); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if err := entry.Check(nil); !errors.Is(err, manifest.ErrChanged) {
+		t.Errorf("want: %v, got: %v", manifest.ErrChanged, err)
+	}
+
+	if err := os.Remove(photo); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if err := entry.Check(nil); !os.IsNotExist(err) {
+		t.Errorf("want: not exist error, got: %v", err)
+	}
+}
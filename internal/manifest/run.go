@@ -0,0 +1,246 @@
+package manifest
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RunsFolder is the folder, in the application data folder, where the state files of the runs are kept.
+const RunsFolder = "runs"
+
+// runFileExt is the extension of the state files of the runs.
+const runFileExt = ".jsonl"
+
+// runIDPattern is the format of the run IDs, see newRunID.
+var runIDPattern = regexp.MustCompile(`^\d{8}-\d{6}-[0-9a-f]{6}$`)
+
+// ErrRunNotFound is returned when there is no state file for the run ID.
+var ErrRunNotFound = errors.New("run not found")
+
+// Run is the state of the upload of a manifest, recording the entries completed so far, so the upload could be
+// resumed if it's interrupted.
+//
+// The state is kept in a file of newline-delimited JSON: the first line has the ID, the start time and the
+// entries of the manifest, and the next ones are the entries completed, appended as soon as they are. So, if
+// the process crashes, at most the last line is lost, and the entries completed before are kept.
+// It's safe for concurrent use.
+type Run struct {
+	// ID identifies the run, it's the name of its state file.
+	ID string
+	// StartedAt is when the run started.
+	StartedAt time.Time
+	// Manifest is the file of the manifest, as given when the run started.
+	Manifest string
+	// Entries are the entries of the manifest, so the run could be resumed even if it's changed or removed.
+	Entries []Entry
+
+	mu        sync.Mutex
+	completed map[string]bool
+	f         *os.File
+	// size is the size of the state file up to its last line fully written.
+	size int64
+}
+
+// runHeader is the first line of the state file of a run.
+type runHeader struct {
+	ID        string    `json:"id"`
+	StartedAt time.Time `json:"startedAt"`
+	Manifest  string    `json:"manifest"`
+	Entries   []Entry   `json:"entries"`
+}
+
+// runRecord is a line of the state file of a run after the first one, an entry completed.
+type runRecord struct {
+	Path    string `json:"path"`
+	Account string `json:"account"`
+}
+
+// RunInfo is the summary of a run, see ListRuns.
+type RunInfo struct {
+	ID        string
+	StartedAt time.Time
+	Manifest  string
+	Entries   int
+	Completed int
+}
+
+// StartRun creates the state file of a new run of the manifest in folder, returning the run. Its ID is new,
+// made of its start time and a random suffix.
+func StartRun(folder string, filename string, m Manifest) (*Run, error) {
+	if err := os.MkdirAll(folder, 0700); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	id, err := newRunID(now)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(runFilename(folder, id), os.O_CREATE|os.O_EXCL|os.O_WRONLY, fileMode)
+	if err != nil {
+		return nil, err
+	}
+	r := &Run{ID: id, StartedAt: now, Manifest: filename, Entries: m.Entries, completed: make(map[string]bool), f: f}
+	if err := r.append(runHeader{ID: id, StartedAt: now, Manifest: filename, Entries: m.Entries}); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// ResumeRun opens the state file of the run in folder, in order to continue it. Entries completed before are
+// recorded as such, and the next ones are appended to the same file. It returns ErrRunNotFound if there is no
+// run with this ID.
+func ResumeRun(folder string, id string) (*Run, error) {
+	r, err := readRun(folder, id)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(runFilename(folder, id), os.O_WRONLY|os.O_APPEND, fileMode)
+	if err != nil {
+		return nil, err
+	}
+	// the line not fully written when the process crashed, if any, is removed before appending the next ones.
+	if err := f.Truncate(r.size); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	r.f = f
+	return r, nil
+}
+
+// ListRuns returns the runs whose state files are in folder, the most recent first.
+func ListRuns(folder string) ([]RunInfo, error) {
+	files, err := ioutil.ReadDir(folder)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var runs []RunInfo
+	for _, fi := range files {
+		id := strings.TrimSuffix(fi.Name(), runFileExt)
+		if fi.IsDir() || !strings.HasSuffix(fi.Name(), runFileExt) || !runIDPattern.MatchString(id) {
+			continue
+		}
+		r, err := readRun(folder, id)
+		if err != nil {
+			return nil, fmt.Errorf("invalid state of run '%s': %w", id, err)
+		}
+		runs = append(runs, RunInfo{ID: r.ID, StartedAt: r.StartedAt, Manifest: r.Manifest, Entries: len(r.Entries), Completed: len(r.completed)})
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].StartedAt.After(runs[j].StartedAt)
+	})
+	return runs, nil
+}
+
+// Completed returns true if the entry has been completed by the run.
+func (r *Run) Completed(e Entry) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.completed[entryKey(e.Path, e.Account)]
+}
+
+// Complete records the entry as completed by the run, appending it to its state file.
+func (r *Run) Complete(e Entry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := entryKey(e.Path, e.Account)
+	if r.completed[key] {
+		return nil
+	}
+	if err := r.append(runRecord{Path: e.Path, Account: e.Account}); err != nil {
+		return err
+	}
+	r.completed[key] = true
+	return nil
+}
+
+// Close closes the state file of the run.
+func (r *Run) Close() error {
+	return r.f.Close()
+}
+
+// append writes v as a line of the state file, synced so it's kept if the process crashes right after.
+func (r *Run) append(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := r.f.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	return r.f.Sync()
+}
+
+// readRun reads the state file of the run in folder. A last line not fully written, because the process crashed
+// while writing it, is ignored.
+func readRun(folder string, id string) (*Run, error) {
+	if !runIDPattern.MatchString(id) {
+		return nil, fmt.Errorf("invalid run ID '%s'", id)
+	}
+	f, err := os.Open(runFilename(folder, id))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: '%s'", ErrRunNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		return nil, fmt.Errorf("invalid run state: %w", err)
+	}
+	var h runHeader
+	if err := json.Unmarshal(line, &h); err != nil {
+		return nil, fmt.Errorf("invalid run state: %w", err)
+	}
+	r := &Run{ID: h.ID, StartedAt: h.StartedAt, Manifest: h.Manifest, Entries: h.Entries, completed: make(map[string]bool), size: int64(len(line))}
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			// the last line is only kept if it has been fully written.
+			break
+		}
+		var rec runRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return nil, fmt.Errorf("invalid run state: %w", err)
+		}
+		r.completed[entryKey(rec.Path, rec.Account)] = true
+		r.size += int64(len(line))
+	}
+	return r, nil
+}
+
+// newRunID returns a new run ID, like "20230701-120000-a1b2c3".
+func newRunID(now time.Time) (string, error) {
+	b := make([]byte, 3)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return now.Format("20060102-150405") + "-" + hex.EncodeToString(b), nil
+}
+
+func runFilename(folder string, id string) string {
+	return filepath.Join(folder, id+runFileExt)
+}
+
+// entryKey identifies an entry of a run, the same file could be uploaded to several accounts.
+func entryKey(path string, account string) string {
+	return account + "\x00" + path
+}
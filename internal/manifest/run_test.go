@@ -0,0 +1,109 @@
+package manifest_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/manifest"
+)
+
+func TestResumeRun(t *testing.T) {
+	dir := t.TempDir()
+	m := manifest.Manifest{Version: manifest.Version, Entries: []manifest.Entry{
+		{Path: "/photos/IMG_0001.jpg", Size: 1, Hash: "a", Account: "me@domain.com"},
+		{Path: "/photos/IMG_0002.jpg", Size: 2, Hash: "b", Account: "me@domain.com"},
+		{Path: "/photos/IMG_0002.jpg", Size: 2, Hash: "b", Account: "other@domain.com"},
+	}}
+	run, err := manifest.StartRun(dir, "/manifest.json", m)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if err := run.Complete(m.Entries[0]); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if err := run.Close(); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	// the process crashes while recording the second entry.
+	f, err := os.OpenFile(filepath.Join(dir, run.ID+".jsonl"), os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`{"path":"/photos/IMG_0002.jpg","acc`); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	resumed, err := manifest.ResumeRun(dir, run.ID)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if want := "/manifest.json"; want != resumed.Manifest {
+		t.Errorf("want: %v, got: %v", want, resumed.Manifest)
+	}
+	if len(resumed.Entries) != len(m.Entries) {
+		t.Fatalf("want: %d entries, got: %v", len(m.Entries), resumed.Entries)
+	}
+	for i, want := range []bool{true, false, false} {
+		if got := resumed.Completed(m.Entries[i]); want != got {
+			t.Errorf("entry %d completed, want: %v, got: %v", i, want, got)
+		}
+	}
+	if err := resumed.Complete(m.Entries[1]); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if err := resumed.Close(); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	runs, err := manifest.ListRuns(dir)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if len(runs) != 1 || runs[0].ID != run.ID || runs[0].Entries != 3 || runs[0].Completed != 2 {
+		t.Errorf("want: run %s with 2 of 3 entries completed, got: %+v", run.ID, runs)
+	}
+}
+
+func TestResumeRun_NotFound(t *testing.T) {
+	_, err := manifest.ResumeRun(t.TempDir(), "20230701-120000-a1b2c3")
+	if !errors.Is(err, manifest.ErrRunNotFound) {
+		t.Errorf("want: %v, got: %v", manifest.ErrRunNotFound, err)
+	}
+	if _, err := manifest.ResumeRun(t.TempDir(), "../config"); err == nil {
+		t.Errorf("error was expected, but not produced")
+	}
+}
+
+func TestListRuns(t *testing.T) {
+	dir := t.TempDir()
+	if runs, err := manifest.ListRuns(filepath.Join(dir, "runs")); err != nil || len(runs) != 0 {
+		t.Fatalf("want: no runs, got: %v, %v", runs, err)
+	}
+	ids := make(map[string]bool)
+	for i := 0; i < 2; i++ {
+		run, err := manifest.StartRun(dir, "/manifest.json", manifest.Manifest{Version: manifest.Version})
+		if err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+		_ = run.Close()
+		ids[run.ID] = true
+	}
+	runs, err := manifest.ListRuns(dir)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if len(runs) != 2 {
+		t.Fatalf("want: 2 runs, got: %+v", runs)
+	}
+	for _, r := range runs {
+		if !ids[r.ID] {
+			t.Errorf("want: runs %v, got: %+v", ids, runs)
+		}
+	}
+	if runs[0].StartedAt.Before(runs[1].StartedAt) {
+		t.Errorf("want: the most recent run first, got: %+v", runs)
+	}
+}
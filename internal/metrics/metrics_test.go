@@ -0,0 +1,73 @@
+package metrics_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/metrics"
+)
+
+func TestRegistry_WriteTo(t *testing.T) {
+	r := metrics.NewRegistry()
+	uploaded := r.NewCounter("files_uploaded_total", "Number of files uploaded.")
+	skipped := r.NewCounterVec("files_skipped_total", "Number of files skipped.", "reason")
+	duration := r.NewHistogram("upload_duration_seconds", "Upload duration.", []float64{1, 5})
+
+	uploaded.Inc()
+	uploaded.Add(2)
+	skipped.Inc("excluded")
+	skipped.Inc("already_uploaded")
+	skipped.Inc("excluded")
+	duration.Observe(0.5)
+	duration.Observe(3)
+	duration.Observe(10)
+
+	want := `# HELP files_skipped_total Number of files skipped.
+# TYPE files_skipped_total counter
+files_skipped_total{reason="already_uploaded"} 1
+files_skipped_total{reason="excluded"} 2
+# HELP files_uploaded_total Number of files uploaded.
+# TYPE files_uploaded_total counter
+files_uploaded_total 3
+# HELP upload_duration_seconds Upload duration.
+# TYPE upload_duration_seconds histogram
+upload_duration_seconds_bucket{le="1"} 1
+upload_duration_seconds_bucket{le="5"} 2
+upload_duration_seconds_bucket{le="+Inf"} 3
+upload_duration_seconds_sum 13.5
+upload_duration_seconds_count 3
+`
+	var got bytes.Buffer
+	r.Write(&got)
+	if got.String() != want {
+		t.Errorf("want: %s, got: %s", want, got.String())
+	}
+}
+
+func TestServe(t *testing.T) {
+	srv, err := metrics.Serve("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	defer func() {
+		if err := srv.Stop(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	res, err := http.Get("http://" + srv.Addr() + "/metrics")
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	defer res.Body.Close()
+	b, _ := ioutil.ReadAll(res.Body)
+
+	for _, name := range []string{"gphotos_files_uploaded_total", "gphotos_files_skipped_total", "gphotos_bytes_uploaded_total", "gphotos_upload_errors_total", "gphotos_upload_duration_seconds"} {
+		if !strings.Contains(string(b), "# TYPE "+name+" ") {
+			t.Errorf("want: metric %s, got: %s", name, b)
+		}
+	}
+}
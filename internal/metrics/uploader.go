@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Default is the package-scoped registry with the uploader metrics.
+var Default = NewRegistry()
+
+// UploadDurationBuckets are the upper bounds, in seconds, of the upload duration buckets.
+var UploadDurationBuckets = []float64{0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600}
+
+// Uploader metrics.
+var (
+	FilesUploaded  = Default.NewCounter("gphotos_files_uploaded_total", "Number of files uploaded.")
+	FilesSkipped   = Default.NewCounterVec("gphotos_files_skipped_total", "Number of files skipped, by reason.", "reason")
+	BytesUploaded  = Default.NewCounter("gphotos_bytes_uploaded_total", "Number of bytes of the uploaded files.")
+	UploadErrors   = Default.NewCounterVec("gphotos_upload_errors_total", "Number of failed uploads, by HTTP status code.", "status")
+	UploadDuration = Default.NewHistogram("gphotos_upload_duration_seconds", "Time to upload a file, in seconds.", UploadDurationBuckets)
+)
+
+// Server serves the Default registry metrics over HTTP.
+type Server struct {
+	server   *http.Server
+	listener net.Listener
+}
+
+// Serve starts serving the metrics at addr, e.g. ":9091", on the /metrics path.
+// It returns once the address is listening, so it's ready to be scraped.
+func Serve(addr string) (*Server, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Default.Handler())
+	s := &Server{
+		server:   &http.Server{Handler: mux},
+		listener: l,
+	}
+	go func() {
+		_ = s.server.Serve(l)
+	}()
+	return s, nil
+}
+
+// Addr returns the address where the metrics are served.
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Stop stops the server, waiting up to 5 seconds for the scrapes in progress.
+func (s *Server) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return s.server.Shutdown(ctx)
+}
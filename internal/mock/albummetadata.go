@@ -0,0 +1,19 @@
+package mock
+
+import "context"
+
+// AlbumMetadataService mocks the service to update the albums created by this tool.
+type AlbumMetadataService struct {
+	SetCoverPhotoFn  func(ctx context.Context, albumID string, mediaItemID string) error
+	AddDescriptionFn func(ctx context.Context, albumID string, text string) error
+}
+
+// SetCoverPhoto invokes the mock implementation.
+func (s *AlbumMetadataService) SetCoverPhoto(ctx context.Context, albumID string, mediaItemID string) error {
+	return s.SetCoverPhotoFn(ctx, albumID, mediaItemID)
+}
+
+// AddDescription invokes the mock implementation.
+func (s *AlbumMetadataService) AddDescription(ctx context.Context, albumID string, text string) error {
+	return s.AddDescriptionFn(ctx, albumID, text)
+}
@@ -0,0 +1,55 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/gphotosuploader/google-photos-api-client-go/v2/albums"
+)
+
+// AlbumsService mocks the service to manage albums.
+type AlbumsService struct {
+	CreateFn     func(ctx context.Context, title string) (*albums.Album, error)
+	GetByTitleFn func(ctx context.Context, title string) (*albums.Album, error)
+}
+
+// Create invokes the mock implementation.
+func (s *AlbumsService) Create(ctx context.Context, title string) (*albums.Album, error) {
+	return s.CreateFn(ctx, title)
+}
+
+// GetByTitle invokes the mock implementation.
+func (s *AlbumsService) GetByTitle(ctx context.Context, title string) (*albums.Album, error) {
+	return s.GetByTitleFn(ctx, title)
+}
+
+// AlbumLister mocks the service to list all the albums.
+type AlbumLister struct {
+	ListFn func(ctx context.Context) ([]albums.Album, error)
+}
+
+// List invokes the mock implementation.
+func (s *AlbumLister) List(ctx context.Context) ([]albums.Album, error) {
+	return s.ListFn(ctx)
+}
+
+// AlbumItemsService mocks the service to add media items already uploaded to albums.
+type AlbumItemsService struct {
+	AddMediaItemsFn func(ctx context.Context, albumId string, mediaItemIds []string) error
+}
+
+// AddMediaItems invokes the mock implementation.
+func (s *AlbumItemsService) AddMediaItems(ctx context.Context, albumId string, mediaItemIds []string) error {
+	return s.AddMediaItemsFn(ctx, albumId, mediaItemIds)
+}
+
+// AlbumItemsResultsService mocks the service to add media items already uploaded to albums, reporting the
+// result of every media item.
+type AlbumItemsResultsService struct {
+	AlbumItemsService
+	AddMediaItemsResultsFn func(ctx context.Context, albumId string, mediaItemIds []string) ([]error, error)
+}
+
+// AddMediaItemsResults invokes the mock implementation.
+func (s *AlbumItemsResultsService) AddMediaItemsResults(ctx context.Context, albumId string, mediaItemIds []string) ([]error, error) {
+	return s.AddMediaItemsResultsFn(ctx, albumId, mediaItemIds)
+}
@@ -0,0 +1,13 @@
+package mock
+
+import "context"
+
+// Converter mocks a converter of images to JPEG.
+type Converter struct {
+	ToJPEGFn func(ctx context.Context, src string, dst string) error
+}
+
+// ToJPEG invokes the mock implementation.
+func (c *Converter) ToJPEG(ctx context.Context, src string, dst string) error {
+	return c.ToJPEGFn(ctx, src, dst)
+}
@@ -2,14 +2,14 @@ package mock
 
 // FileTracker mocks the service to track already uploaded files.
 type FileTracker struct {
-	PutFn    func(path string) error
+	PutFn    func(path string, mediaItemID string) error
 	ExistFn  func(path string) bool
 	DeleteFn func(path string) error
 }
 
 // Put invokes the mock implementation.
-func (t *FileTracker) Put(path string) error {
-	return t.PutFn(path)
+func (t *FileTracker) Put(path string, mediaItemID string) error {
+	return t.PutFn(path, mediaItemID)
 }
 
 // Exist invokes the mock implementation.
@@ -21,3 +21,31 @@ func (t *FileTracker) Exist(path string) bool {
 func (t *FileTracker) Delete(path string) error {
 	return t.DeleteFn(path)
 }
+
+// AlbumTracker mocks the service to track already uploaded files, and the albums where they have been added.
+type AlbumTracker struct {
+	FileTracker
+	TrackedAlbumsFn func(path string) (string, []string, bool)
+	AddAlbumFn      func(path string, album string) error
+}
+
+// TrackedAlbums invokes the mock implementation.
+func (t *AlbumTracker) TrackedAlbums(path string) (string, []string, bool) {
+	return t.TrackedAlbumsFn(path)
+}
+
+// AddAlbum invokes the mock implementation.
+func (t *AlbumTracker) AddAlbum(path string, album string) error {
+	return t.AddAlbumFn(path, album)
+}
+
+// FavoriteTracker mocks the service to track already uploaded files, and the ones that should be marked as favorites.
+type FavoriteTracker struct {
+	FileTracker
+	MarkFavoriteFn func(path string) error
+}
+
+// MarkFavorite invokes the mock implementation.
+func (t *FavoriteTracker) MarkFavorite(path string) error {
+	return t.MarkFavoriteFn(path)
+}
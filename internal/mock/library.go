@@ -0,0 +1,15 @@
+package mock
+
+import (
+	"context"
+)
+
+// LibraryMatcher mocks the search of media items matching files in the library.
+type LibraryMatcher struct {
+	MatchFn func(ctx context.Context, path string) (string, bool, error)
+}
+
+// Match invokes the mock implementation.
+func (m *LibraryMatcher) Match(ctx context.Context, path string) (string, bool, error) {
+	return m.MatchFn(ctx, path)
+}
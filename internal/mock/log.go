@@ -1,6 +1,10 @@
 package mock
 
-import "github.com/sirupsen/logrus"
+import (
+	"github.com/sirupsen/logrus"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+)
 
 type Logger struct {
 	DebugInvoked  bool
@@ -27,6 +31,8 @@ type Logger struct {
 	FailInvoked  bool
 	FailfInvoked bool
 
+	WithFieldsInvoked bool
+
 	PrintInvoked  bool
 	PrintfInvoked bool
 
@@ -150,3 +156,9 @@ func (l *Logger) Write(message []byte) (int, error) {
 func (l *Logger) WriteString(message string) {
 	l.WriteStringInvoked = true
 }
+
+// WithFields marks the function as invoked.
+func (l *Logger) WithFields(fields log.Fields) log.Logger {
+	l.WithFieldsInvoked = true
+	return l
+}
@@ -0,0 +1,33 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/gphotosuploader/google-photos-api-client-go/v2/media_items"
+)
+
+// MediaItemsService mocks the service to get media items.
+type MediaItemsService struct {
+	GetFn func(ctx context.Context, mediaItemID string) (*media_items.MediaItem, error)
+}
+
+// Get invokes the mock implementation.
+func (s *MediaItemsService) Get(ctx context.Context, mediaItemID string) (*media_items.MediaItem, error) {
+	return s.GetFn(ctx, mediaItemID)
+}
+
+// MediaItemsCreator mocks the service to create media items.
+type MediaItemsCreator struct {
+	CreateToAlbumFn                func(ctx context.Context, albumId string, mediaItem media_items.SimpleMediaItem) (media_items.MediaItem, error)
+	CreateToAlbumWithDescriptionFn func(ctx context.Context, albumId string, mediaItem media_items.SimpleMediaItem, description string) (media_items.MediaItem, error)
+}
+
+// CreateToAlbum invokes the mock implementation.
+func (s *MediaItemsCreator) CreateToAlbum(ctx context.Context, albumId string, mediaItem media_items.SimpleMediaItem) (media_items.MediaItem, error) {
+	return s.CreateToAlbumFn(ctx, albumId, mediaItem)
+}
+
+// CreateToAlbumWithDescription invokes the mock implementation.
+func (s *MediaItemsCreator) CreateToAlbumWithDescription(ctx context.Context, albumId string, mediaItem media_items.SimpleMediaItem, description string) (media_items.MediaItem, error) {
+	return s.CreateToAlbumWithDescriptionFn(ctx, albumId, mediaItem, description)
+}
@@ -0,0 +1,29 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/library"
+)
+
+// SharedAlbumsService mocks the service to get and join the albums shared with the account.
+type SharedAlbumsService struct {
+	GetFn  func(ctx context.Context, shareToken string) (library.SharedAlbum, error)
+	JoinFn func(ctx context.Context, shareToken string) (library.SharedAlbum, error)
+	ListFn func(ctx context.Context) ([]library.SharedAlbum, error)
+}
+
+// Get invokes the mock implementation.
+func (s *SharedAlbumsService) Get(ctx context.Context, shareToken string) (library.SharedAlbum, error) {
+	return s.GetFn(ctx, shareToken)
+}
+
+// Join invokes the mock implementation.
+func (s *SharedAlbumsService) Join(ctx context.Context, shareToken string) (library.SharedAlbum, error) {
+	return s.JoinFn(ctx, shareToken)
+}
+
+// List invokes the mock implementation.
+func (s *SharedAlbumsService) List(ctx context.Context) ([]library.SharedAlbum, error) {
+	return s.ListFn(ctx)
+}
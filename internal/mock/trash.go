@@ -0,0 +1,11 @@
+package mock
+
+// Trasher mocks the service to move files to the trash.
+type Trasher struct {
+	TrashFn func(path string) error
+}
+
+// Trash invokes the mock implementation.
+func (t *Trasher) Trash(path string) error {
+	return t.TrashFn(path)
+}
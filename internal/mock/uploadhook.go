@@ -0,0 +1,17 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/hook"
+)
+
+// UploadHook mocks the user logic run after every upload.
+type UploadHook struct {
+	RunFn func(ctx context.Context, u hook.Upload) error
+}
+
+// Run invokes the mock implementation.
+func (h *UploadHook) Run(ctx context.Context, u hook.Upload) error {
+	return h.RunFn(ctx, u)
+}
@@ -0,0 +1,33 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/gphotosuploader/google-photos-api-client-go/v2/media_items"
+)
+
+// UploadsService mocks the service to upload files to Google Photos.
+type UploadsService struct {
+	UploadFileToAlbumFn                func(ctx context.Context, albumId string, filePath string) (media_items.MediaItem, error)
+	UploadFileToAlbumWithDescriptionFn func(ctx context.Context, albumId string, filePath string, description string) (media_items.MediaItem, error)
+}
+
+// UploadFileToAlbum invokes the mock implementation.
+func (s *UploadsService) UploadFileToAlbum(ctx context.Context, albumId string, filePath string) (media_items.MediaItem, error) {
+	return s.UploadFileToAlbumFn(ctx, albumId, filePath)
+}
+
+// UploadFileToAlbumWithDescription invokes the mock implementation.
+func (s *UploadsService) UploadFileToAlbumWithDescription(ctx context.Context, albumId string, filePath string, description string) (media_items.MediaItem, error) {
+	return s.UploadFileToAlbumWithDescriptionFn(ctx, albumId, filePath, description)
+}
+
+// MediaUploader mocks the service to upload the bytes of files.
+type MediaUploader struct {
+	UploadFileFn func(ctx context.Context, filePath string) (string, error)
+}
+
+// UploadFile invokes the mock implementation.
+func (u *MediaUploader) UploadFile(ctx context.Context, filePath string) (string, error) {
+	return u.UploadFileFn(ctx, filePath)
+}
@@ -0,0 +1,198 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ReportVersion is the version of the structure of Report. Fields could be added to it, but it's increased if
+// any of them is renamed, removed, or its meaning changes.
+const ReportVersion = 1
+
+// Formats of the reports written by WriteReport.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+)
+
+// Report is the summary of a run, as written by WriteReport for scripting. All its fields are always present,
+// empty lists and maps included, so they could be read without checking if they exist.
+type Report struct {
+	Version            int                           `json:"version" yaml:"version"`
+	Status             string                        `json:"status" yaml:"status"`
+	Scanned            int                           `json:"scanned" yaml:"scanned"`
+	Uploaded           int                           `json:"uploaded" yaml:"uploaded"`
+	Skipped            int                           `json:"skipped" yaml:"skipped"`
+	Failed             int                           `json:"failed" yaml:"failed"`
+	Bytes              int64                         `json:"bytes" yaml:"bytes"`
+	DurationSeconds    float64                       `json:"duration_seconds" yaml:"duration_seconds"`
+	Albums             map[string]int                `json:"albums" yaml:"albums"`
+	FailureReasons     map[string]int                `json:"failure_reasons" yaml:"failure_reasons"`
+	Errors             []string                      `json:"errors" yaml:"errors"`
+	PartialBatchErrors []string                      `json:"partial_batch_errors" yaml:"partial_batch_errors"`
+	DeadLetters        []string                      `json:"dead_letters" yaml:"dead_letters"`
+	Favorites          []string                      `json:"favorites" yaml:"favorites"`
+	PermissionErrors   int                           `json:"permission_errors" yaml:"permission_errors"`
+	LimitReached       bool                          `json:"limit_reached" yaml:"limit_reached"`
+	MaxDurationReached bool                          `json:"max_duration_reached" yaml:"max_duration_reached"`
+	SkippedByCategory  map[SkipCategory]SkippedFiles `json:"skipped_by_category" yaml:"skipped_by_category"`
+}
+
+// NewReport returns the report of the summary. The Status is set from the number of failures, like Notify does.
+func NewReport(s Summary) Report {
+	r := Report{
+		Version:            ReportVersion,
+		Status:             OnSuccess,
+		Scanned:            s.Scanned,
+		Uploaded:           s.Uploaded,
+		Skipped:            s.Skipped,
+		Failed:             s.Failed,
+		Bytes:              s.Bytes,
+		DurationSeconds:    s.DurationSeconds,
+		Albums:             copyCounts(s.Albums),
+		FailureReasons:     copyCounts(s.FailureReasons),
+		Errors:             append([]string{}, s.Errors...),
+		PartialBatchErrors: append([]string{}, s.PartialBatchErrors...),
+		DeadLetters:        append([]string{}, s.DeadLetters...),
+		Favorites:          append([]string{}, s.Favorites...),
+		PermissionErrors:   s.PermissionErrors,
+		LimitReached:       s.LimitReached,
+		MaxDurationReached: s.MaxDurationReached,
+		SkippedByCategory:  make(map[SkipCategory]SkippedFiles, len(s.SkippedByCategory)),
+	}
+	for c, files := range s.SkippedByCategory {
+		r.SkippedByCategory[c] = SkippedFiles{Count: files.Count, Samples: append([]string(nil), files.Samples...)}
+	}
+	if s.Failure() {
+		r.Status = OnFailure
+	}
+	return r
+}
+
+// IsValidFormat returns true if the format is one of the formats of WriteReport.
+func IsValidFormat(format string) bool {
+	switch format {
+	case FormatText, FormatJSON, FormatYAML:
+		return true
+	}
+	return false
+}
+
+// WriteReport writes the report of the summary to w, in the format: FormatText, FormatJSON or FormatYAML.
+func WriteReport(w io.Writer, s Summary, format string) error {
+	r := NewReport(s)
+	switch format {
+	case FormatText:
+		return writeText(w, r)
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	case FormatYAML:
+		b, err := yaml.Marshal(r)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
+	}
+	return fmt.Errorf("invalid summary format '%s'", format)
+}
+
+// writeText writes the report for humans, with the albums and the failure reasons sorted by their name.
+func writeText(w io.Writer, r Report) error {
+	duration := time.Duration(r.DurationSeconds * float64(time.Second)).Round(time.Second)
+	lines := []string{
+		fmt.Sprintf("Status: %s", r.Status),
+		fmt.Sprintf("Scanned: %d", r.Scanned),
+		fmt.Sprintf("Uploaded: %d (%d bytes)", r.Uploaded, r.Bytes),
+		fmt.Sprintf("Skipped: %d", r.Skipped),
+		fmt.Sprintf("Failed: %d", r.Failed),
+		fmt.Sprintf("Duration: %s", duration),
+	}
+	if r.PermissionErrors > 0 {
+		lines = append(lines, fmt.Sprintf("Unreadable: %d", r.PermissionErrors))
+	}
+	if r.LimitReached {
+		lines = append(lines, "Limit reached: files not uploaded will be uploaded on the next run")
+	}
+	if r.MaxDurationReached {
+		lines = append(lines, "Maximum duration reached: files not uploaded will be uploaded on the next run")
+	}
+	lines = append(lines, countLines("Albums", r.Albums)...)
+	lines = append(lines, countLines("Failure reasons", r.FailureReasons)...)
+	lines = append(lines, skippedLines("Skipped by category", r.SkippedByCategory)...)
+	lines = append(lines, listLines("Errors", r.Errors)...)
+	lines = append(lines, listLines("Partial batch errors", r.PartialBatchErrors)...)
+	lines = append(lines, listLines("Dead letters", r.DeadLetters)...)
+	lines = append(lines, listLines("Favorites", r.Favorites)...)
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// countLines returns the title, and the counts sorted by their name, or nothing if there are no counts.
+func countLines(title string, counts map[string]int) []string {
+	if len(counts) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	lines := []string{title + ":"}
+	for _, name := range names {
+		lines = append(lines, fmt.Sprintf("  %s: %d", name, counts[name]))
+	}
+	return lines
+}
+
+// skippedLines returns the title, and the count and the samples of every category, in the order of
+// SkipCategories, or nothing if no file has been skipped.
+func skippedLines(title string, skipped map[SkipCategory]SkippedFiles) []string {
+	if len(skipped) == 0 {
+		return nil
+	}
+	lines := []string{title + ":"}
+	for _, c := range SkipCategories {
+		files, ok := skipped[c]
+		if !ok {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("  %s: %d", c, files.Count))
+		for _, path := range files.Samples {
+			lines = append(lines, "    - "+path)
+		}
+	}
+	return lines
+}
+
+// listLines returns the title, and the items of the list, or nothing if it's empty.
+func listLines(title string, items []string) []string {
+	if len(items) == 0 {
+		return nil
+	}
+	lines := []string{title + ":"}
+	for _, item := range items {
+		lines = append(lines, "  - "+item)
+	}
+	return lines
+}
+
+func copyCounts(counts map[string]int) map[string]int {
+	c := make(map[string]int, len(counts))
+	for name, n := range counts {
+		c[name] = n
+	}
+	return c
+}
@@ -0,0 +1,144 @@
+package notify_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/notify"
+)
+
+// testSummary returns a summary with all the breakdowns set.
+func testSummary() notify.Summary {
+	return notify.Summary{
+		Scanned:            10,
+		Uploaded:           5,
+		Skipped:            3,
+		Failed:             2,
+		Bytes:              2048,
+		DurationSeconds:    90,
+		Albums:             map[string]int{"Trips": 3, "Family": 2},
+		FailureReasons:     map[string]int{"network_error": 1, "partial_batch": 1},
+		Errors:             []string{"IMG_0001.jpg: network failure"},
+		PartialBatchErrors: []string{"IMG_0002.jpg: invalid media item"},
+		PermissionErrors:   1,
+		SkippedByCategory: map[notify.SkipCategory]notify.SkippedFiles{
+			notify.SkipAlreadyUploaded: {Count: 2, Samples: []string{"IMG_0003.jpg", "IMG_0004.jpg"}},
+			notify.SkipUnreadable:      {Count: 1},
+		},
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	testCases := []struct {
+		name    string
+		summary notify.Summary
+	}{
+		{"Should write the breakdowns", testSummary()},
+		{"Should write an empty summary", notify.Summary{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			want := notify.NewReport(tc.summary)
+
+			var b bytes.Buffer
+			if err := notify.WriteReport(&b, tc.summary, notify.FormatJSON); err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			var fromJSON notify.Report
+			if err := json.Unmarshal(b.Bytes(), &fromJSON); err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			if !reflect.DeepEqual(want, fromJSON) {
+				t.Errorf("want: %+v, got: %+v", want, fromJSON)
+			}
+
+			b.Reset()
+			if err := notify.WriteReport(&b, tc.summary, notify.FormatYAML); err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			var fromYAML notify.Report
+			if err := yaml.Unmarshal(b.Bytes(), &fromYAML); err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			if !reflect.DeepEqual(want, fromYAML) {
+				t.Errorf("want: %+v, got: %+v", want, fromYAML)
+			}
+		})
+	}
+}
+
+func TestWriteReport_JSONRoundTrip(t *testing.T) {
+	var b bytes.Buffer
+	if err := notify.WriteReport(&b, notify.Summary{}, notify.FormatJSON); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(b.Bytes(), &got); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	// all the fields are present, even if they are empty, so scripts don't have to check them.
+	for _, field := range []string{"version", "status", "scanned", "uploaded", "skipped", "failed", "bytes", "duration_seconds", "albums", "failure_reasons", "errors", "partial_batch_errors", "dead_letters", "favorites", "permission_errors", "limit_reached", "max_duration_reached"} {
+		if _, ok := got[field]; !ok {
+			t.Errorf("field was expected: %s", field)
+		}
+	}
+	if got["version"] != float64(notify.ReportVersion) || got["status"] != notify.OnSuccess {
+		t.Errorf("want: version %d and status %s, got: %v", notify.ReportVersion, notify.OnSuccess, got)
+	}
+
+	again, err := json.MarshalIndent(notify.NewReport(notify.Summary{}), "", "  ")
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if strings.TrimSpace(b.String()) != string(again) {
+		t.Errorf("want: %s, got: %s", again, b.String())
+	}
+}
+
+func TestWriteReport_Text(t *testing.T) {
+	var b bytes.Buffer
+	if err := notify.WriteReport(&b, testSummary(), notify.FormatText); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	want := `Status: failure
+Scanned: 10
+Uploaded: 5 (2048 bytes)
+Skipped: 3
+Failed: 2
+Duration: 1m30s
+Unreadable: 1
+Albums:
+  Family: 2
+  Trips: 3
+Failure reasons:
+  network_error: 1
+  partial_batch: 1
+Skipped by category:
+  already_uploaded: 2
+    - IMG_0003.jpg
+    - IMG_0004.jpg
+  unreadable: 1
+Errors:
+  - IMG_0001.jpg: network failure
+Partial batch errors:
+  - IMG_0002.jpg: invalid media item
+`
+	if got := b.String(); got != want {
+		t.Errorf("want: %q, got: %q", want, got)
+	}
+}
+
+func TestWriteReport_InvalidFormat(t *testing.T) {
+	if notify.IsValidFormat("xml") {
+		t.Errorf("format was not expected to be valid: %s", "xml")
+	}
+	if err := notify.WriteReport(&bytes.Buffer{}, notify.Summary{}, "xml"); err == nil {
+		t.Errorf("error was expected, but not produced")
+	}
+}
@@ -0,0 +1,95 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+)
+
+// SkipCategory groups the reasons why files are skipped, in order to summarize them. Their values are stable,
+// since they are keys of the JSON summary.
+type SkipCategory string
+
+const (
+	// SkipAlreadyUploaded are the files uploaded already: tracked, found in the library or similar to an uploaded one.
+	SkipAlreadyUploaded SkipCategory = "already_uploaded"
+	// SkipDuplicate are the files with the same content as another file of the run.
+	SkipDuplicate SkipCategory = "duplicate"
+	// SkipFiltered are the files excluded by the options of the job or the flags of the run.
+	SkipFiltered SkipCategory = "filtered"
+	// SkipUnsupported are the files that would be rejected by Google Photos, because of their type or size.
+	SkipUnsupported SkipCategory = "unsupported"
+	// SkipTooRecent are the files modified too recently, they are uploaded on a next run.
+	SkipTooRecent SkipCategory = "too_recent"
+	// SkipUnchanged are the files not changed since the last run or the git ref.
+	SkipUnchanged SkipCategory = "unchanged"
+	// SkipUnreadable are the files and directories that could not be read.
+	SkipUnreadable SkipCategory = "unreadable"
+	// SkipInterrupted are the files whose upload has been interrupted, they are uploaded on a next run.
+	SkipInterrupted SkipCategory = "interrupted"
+	// SkipOther are the files skipped for any other reason.
+	SkipOther SkipCategory = "other"
+)
+
+// SkipCategories are all the categories, in the order they are summarized.
+var SkipCategories = []SkipCategory{
+	SkipAlreadyUploaded,
+	SkipDuplicate,
+	SkipFiltered,
+	SkipUnsupported,
+	SkipTooRecent,
+	SkipUnchanged,
+	SkipUnreadable,
+	SkipInterrupted,
+	SkipOther,
+}
+
+// DefaultSkippedSamples is the default maximum number of paths kept for every category of skipped files.
+const DefaultSkippedSamples = 5
+
+// SkippedFiles are the files skipped for the reasons of a category.
+type SkippedFiles struct {
+	Count int `json:"count" yaml:"count"`
+	// Samples are the first paths skipped, up to the maximum number of samples of the run.
+	Samples []string `json:"samples,omitempty" yaml:"samples,omitempty"`
+}
+
+// SkipCategoryOf returns the category of the reason, one of the log.Reason* codes, or SkipOther if it has none.
+func SkipCategoryOf(reason string) SkipCategory {
+	switch reason {
+	case log.ReasonAlreadyUploaded, log.ReasonInLibrary, log.ReasonNearDuplicate, log.ReasonCompletedInRun:
+		return SkipAlreadyUploaded
+	case log.ReasonDuplicate:
+		return SkipDuplicate
+	case log.ReasonExcluded, log.ReasonSymlink, log.ReasonSymlinkLoop, log.ReasonHidden, log.ReasonNoAlbum,
+		log.ReasonOtherAlbum, log.ReasonExifMismatch, log.ReasonMIMEType, log.ReasonTooSmall, log.ReasonOutOfDateRange:
+		return SkipFiltered
+	case log.ReasonUnsupportedType, log.ReasonTooLarge:
+		return SkipUnsupported
+	case log.ReasonTooRecent:
+		return SkipTooRecent
+	case log.ReasonUnchanged:
+		return SkipUnchanged
+	case log.ReasonUnreadable:
+		return SkipUnreadable
+	}
+	return SkipOther
+}
+
+// SkippedSummary returns the total of skipped files, and their count by category, like
+// "4200 skipped: 3000 already_uploaded, 900 filtered, 300 unsupported". Categories without files are omitted.
+func SkippedSummary(skipped map[SkipCategory]SkippedFiles) string {
+	total := 0
+	var counts []string
+	for _, c := range SkipCategories {
+		if n := skipped[c].Count; n > 0 {
+			total += n
+			counts = append(counts, fmt.Sprintf("%d %s", n, c))
+		}
+	}
+	if total == 0 {
+		return "0 skipped"
+	}
+	return fmt.Sprintf("%d skipped: %s", total, strings.Join(counts, ", "))
+}
@@ -0,0 +1,47 @@
+package notify_test
+
+import (
+	"testing"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/notify"
+)
+
+func TestSkipCategoryOf(t *testing.T) {
+	testCases := []struct {
+		reason string
+		want   notify.SkipCategory
+	}{
+		{log.ReasonAlreadyUploaded, notify.SkipAlreadyUploaded},
+		{log.ReasonInLibrary, notify.SkipAlreadyUploaded},
+		{log.ReasonNearDuplicate, notify.SkipAlreadyUploaded},
+		{log.ReasonDuplicate, notify.SkipDuplicate},
+		{log.ReasonExcluded, notify.SkipFiltered},
+		{log.ReasonOutOfDateRange, notify.SkipFiltered},
+		{log.ReasonUnsupportedType, notify.SkipUnsupported},
+		{log.ReasonTooLarge, notify.SkipUnsupported},
+		{log.ReasonTooRecent, notify.SkipTooRecent},
+		{log.ReasonUnchanged, notify.SkipUnchanged},
+		{log.ReasonUnreadable, notify.SkipUnreadable},
+		{"unknown", notify.SkipOther},
+	}
+	for _, tc := range testCases {
+		if got := notify.SkipCategoryOf(tc.reason); tc.want != got {
+			t.Errorf("reason %s, want: %v, got: %v", tc.reason, tc.want, got)
+		}
+	}
+}
+
+func TestSkippedSummary(t *testing.T) {
+	skipped := map[notify.SkipCategory]notify.SkippedFiles{
+		notify.SkipUnsupported:     {Count: 300},
+		notify.SkipAlreadyUploaded: {Count: 3000},
+		notify.SkipFiltered:        {Count: 900},
+	}
+	if want, got := "4200 skipped: 3000 already_uploaded, 900 filtered, 300 unsupported", notify.SkippedSummary(skipped); want != got {
+		t.Errorf("want: %v, got: %v", want, got)
+	}
+	if want, got := "0 skipped", notify.SkippedSummary(nil); want != got {
+		t.Errorf("want: %v, got: %v", want, got)
+	}
+}
@@ -0,0 +1,155 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/runstats"
+)
+
+const (
+	// DefaultTimeout is the default time to wait for the webhook response.
+	DefaultTimeout = 10 * time.Second
+
+	// MaxErrors is the maximum number of error messages included in the summary.
+	MaxErrors = 10
+)
+
+// When to notify the webhook.
+const (
+	OnAlways  = "always"
+	OnFailure = "failure"
+	OnSuccess = "success"
+)
+
+// Summary is the result of a run.
+type Summary struct {
+	// Status is "success" if no file has failed, "failure" otherwise.
+	Status          string   `json:"status"`
+	Scanned         int      `json:"scanned"`
+	Uploaded        int      `json:"uploaded"`
+	Skipped         int      `json:"skipped"`
+	Failed          int      `json:"failed"`
+	Bytes           int64    `json:"bytes"`
+	DurationSeconds float64  `json:"duration_seconds"`
+	Errors          []string `json:"errors"`
+	// PartialBatchErrors are the files that could not be added to their album, while other files of the same
+	// batch have been added. They are failures too, but not in Errors.
+	PartialBatchErrors []string `json:"partial_batch_errors,omitempty"`
+	// DeadLetters are the files that have failed too many times, and are not attempted anymore.
+	DeadLetters []string `json:"dead_letters,omitempty"`
+	// FailureReasons are the number of failures by their reason, e.g. "quota_exceeded" or "network_error".
+	FailureReasons map[string]int `json:"failure_reasons,omitempty"`
+	// LimitReached is true if files have not been uploaded because the maximum number of files of the run was reached.
+	LimitReached bool `json:"limit_reached,omitempty"`
+	// MaxDurationReached is true if files have not been uploaded because the maximum duration of the run was reached.
+	// The run has stopped cleanly, the next one uploads them.
+	MaxDurationReached bool `json:"max_duration_reached,omitempty"`
+	// Favorites are the uploaded files that should be marked as favorites. The Google Photos API doesn't allow
+	// to mark them, so they should be marked by other means.
+	Favorites []string `json:"favorites,omitempty"`
+	// PermissionErrors are the files and directories skipped because they could not be read, usually because
+	// of their permissions. They are counted as skipped too.
+	PermissionErrors int `json:"permission_errors,omitempty"`
+	// Albums are the number of files uploaded to every album.
+	Albums map[string]int `json:"albums,omitempty"`
+	// SkippedByCategory are the skipped files grouped by the category of their reason, with a sample of their paths.
+	SkippedByCategory map[SkipCategory]SkippedFiles `json:"skipped_by_category,omitempty"`
+}
+
+// NewSummary returns the summary with the totals of the run, without errors.
+func NewSummary(stats runstats.Snapshot) Summary {
+	return Summary{
+		Scanned:         stats.Scanned,
+		Uploaded:        stats.Uploaded,
+		Skipped:         stats.Skipped,
+		Failed:          stats.Failed,
+		Bytes:           stats.BytesUploaded,
+		DurationSeconds: stats.Duration.Seconds(),
+	}
+}
+
+// AddError counts a failure, keeping its message if there are less than MaxErrors.
+func (s *Summary) AddError(message string) {
+	s.Failed++
+	if len(s.Errors) < MaxErrors {
+		s.Errors = append(s.Errors, message)
+	}
+}
+
+// Failure returns true if any file has failed.
+func (s Summary) Failure() bool {
+	return s.Failed > 0
+}
+
+// Webhook posts the run summary, as JSON, to an URL.
+type Webhook struct {
+	URL string
+	// On is when to notify: OnAlways (default), OnFailure or OnSuccess.
+	On string
+
+	client *http.Client
+}
+
+// NewWebhook returns a webhook posting to the URL. It waits up to timeout for the response,
+// or DefaultTimeout if it's not positive.
+func NewWebhook(url string, on string, timeout time.Duration) *Webhook {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Webhook{
+		URL:    url,
+		On:     on,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// ShouldNotify returns true if the summary should be posted, given the On setting.
+func (w *Webhook) ShouldNotify(s Summary) bool {
+	switch w.On {
+	case OnFailure:
+		return s.Failure()
+	case OnSuccess:
+		return !s.Failure()
+	}
+	return true
+}
+
+// Notify posts the summary, if it should be notified. The Status is set from the number of failures.
+func (w *Webhook) Notify(ctx context.Context, s Summary) error {
+	if !w.ShouldNotify(s) {
+		return nil
+	}
+
+	s.Status = OnSuccess
+	if s.Failure() {
+		s.Status = OnFailure
+	}
+	if s.Errors == nil {
+		s.Errors = []string{}
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("unexpected response: %s", res.Status)
+	}
+	return nil
+}
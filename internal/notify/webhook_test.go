@@ -0,0 +1,163 @@
+package notify_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/notify"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/runstats"
+)
+
+func TestWebhook_Notify(t *testing.T) {
+	var got map[string]interface{}
+	var contentType string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("error was not expected at this point: %s", err)
+		}
+	}))
+	defer srv.Close()
+
+	s := notify.Summary{
+		Scanned:         10,
+		Uploaded:        6,
+		Skipped:         3,
+		Bytes:           2048,
+		DurationSeconds: 1.5,
+	}
+	s.AddError("photo.jpg: unexpected response: 500 Internal Server Error")
+
+	w := notify.NewWebhook(srv.URL, notify.OnAlways, time.Second)
+	if err := w.Notify(context.Background(), s); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	if contentType != "application/json" {
+		t.Errorf("want: %s, got: %s", "application/json", contentType)
+	}
+	want := map[string]interface{}{
+		"status":           "failure",
+		"scanned":          float64(10),
+		"uploaded":         float64(6),
+		"skipped":          float64(3),
+		"failed":           float64(1),
+		"bytes":            float64(2048),
+		"duration_seconds": 1.5,
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("want: %v, got: %v, field: %s", v, got[k], k)
+		}
+	}
+	errs, ok := got["errors"].([]interface{})
+	if !ok || len(errs) != 1 || errs[0] != s.Errors[0] {
+		t.Errorf("want: %v, got: %v", s.Errors, got["errors"])
+	}
+}
+
+func TestWebhook_NotifyOn(t *testing.T) {
+	testCases := []struct {
+		on     string
+		failed bool
+		want   bool
+	}{
+		{on: "", failed: false, want: true},
+		{on: "", failed: true, want: true},
+		{on: notify.OnAlways, failed: false, want: true},
+		{on: notify.OnAlways, failed: true, want: true},
+		{on: notify.OnFailure, failed: false, want: false},
+		{on: notify.OnFailure, failed: true, want: true},
+		{on: notify.OnSuccess, failed: false, want: true},
+		{on: notify.OnSuccess, failed: true, want: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("On[%s]Failed[%t]", tc.on, tc.failed), func(t *testing.T) {
+			var notified bool
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				notified = true
+			}))
+			defer srv.Close()
+
+			var s notify.Summary
+			if tc.failed {
+				s.AddError("error")
+			}
+			w := notify.NewWebhook(srv.URL, tc.on, time.Second)
+			if err := w.Notify(context.Background(), s); err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			if notified != tc.want {
+				t.Errorf("want: %t, got: %t", tc.want, notified)
+			}
+		})
+	}
+}
+
+func TestWebhook_NotifyErrors(t *testing.T) {
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer slow.Close()
+
+	unreachable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	unreachable.Close()
+
+	testCases := []struct {
+		name string
+		url  string
+	}{
+		{name: "Should fail if status is not 2xx", url: unavailable.URL},
+		{name: "Should fail if it times out", url: slow.URL},
+		{name: "Should fail if it's unreachable", url: unreachable.URL},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := notify.NewWebhook(tc.url, notify.OnAlways, 50*time.Millisecond)
+			if err := w.Notify(context.Background(), notify.Summary{}); err == nil {
+				t.Error("error was expected at this point")
+			}
+		})
+	}
+}
+
+func TestNewSummary(t *testing.T) {
+	s := notify.NewSummary(runstats.Snapshot{
+		Scanned:       10,
+		Uploaded:      6,
+		Skipped:       3,
+		Failed:        1,
+		BytesUploaded: 2048,
+		Duration:      1500 * time.Millisecond,
+	})
+
+	want := notify.Summary{Scanned: 10, Uploaded: 6, Skipped: 3, Failed: 1, Bytes: 2048, DurationSeconds: 1.5}
+	if s.Scanned != want.Scanned || s.Uploaded != want.Uploaded || s.Skipped != want.Skipped || s.Failed != want.Failed || s.Bytes != want.Bytes || s.DurationSeconds != want.DurationSeconds {
+		t.Errorf("want: %+v, got: %+v", want, s)
+	}
+}
+
+func TestSummary_AddError(t *testing.T) {
+	var s notify.Summary
+	for i := 0; i < notify.MaxErrors+5; i++ {
+		s.AddError(fmt.Sprintf("error %d", i))
+	}
+	if s.Failed != notify.MaxErrors+5 {
+		t.Errorf("want: %d, got: %d", notify.MaxErrors+5, s.Failed)
+	}
+	if len(s.Errors) != notify.MaxErrors {
+		t.Errorf("want: %d, got: %d", notify.MaxErrors, len(s.Errors))
+	}
+}
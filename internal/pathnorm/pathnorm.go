@@ -0,0 +1,44 @@
+// Package pathnorm normalizes the Unicode form of paths, so the same file name is matched and tracked in the same
+// way whatever the system it was created on: macOS decomposes the accented characters of the names (NFD), while
+// Linux and Windows tools, and the configuration files written with them, usually compose them (NFC).
+package pathnorm
+
+import (
+	"golang.org/x/text/unicode/norm"
+)
+
+// Form is the Unicode normalization form of the paths.
+type Form string
+
+const (
+	// NFC composes the characters of the paths, e.g. "é" is a single code point (default).
+	NFC Form = "nfc"
+	// NFD decomposes the characters of the paths, e.g. "é" is "e" followed by the combining acute accent.
+	NFD Form = "nfd"
+	// Off leaves the paths as they are read from the file system.
+	Off Form = "off"
+)
+
+// Path returns the path in the form. The zero value of Form is NFC.
+func (f Form) Path(path string) string {
+	switch f {
+	case Off:
+		return path
+	case NFD:
+		return norm.NFD.String(path)
+	default:
+		return norm.NFC.String(path)
+	}
+}
+
+// Paths returns the paths in the form.
+func (f Form) Paths(paths []string) []string {
+	if paths == nil {
+		return nil
+	}
+	res := make([]string, len(paths))
+	for i, p := range paths {
+		res[i] = f.Path(p)
+	}
+	return res
+}
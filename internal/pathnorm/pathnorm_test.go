@@ -0,0 +1,44 @@
+package pathnorm_test
+
+import (
+	"testing"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/pathnorm"
+)
+
+const (
+	// nfc and nfd are the same name, "Café/Crème.jpg", composed and decomposed.
+	nfc = "Caf\u00e9/Cr\u00e8me.jpg"
+	nfd = "Cafe\u0301/Cre\u0300me.jpg"
+)
+
+func TestForm_Path(t *testing.T) {
+	testCases := []struct {
+		form pathnorm.Form
+		path string
+		want string
+	}{
+		{"", nfd, nfc},
+		{pathnorm.NFC, nfd, nfc},
+		{pathnorm.NFC, nfc, nfc},
+		{pathnorm.NFD, nfc, nfd},
+		{pathnorm.NFD, nfd, nfd},
+		{pathnorm.Off, nfd, nfd},
+		{pathnorm.Off, nfc, nfc},
+	}
+	for _, tc := range testCases {
+		if got := tc.form.Path(tc.path); tc.want != got {
+			t.Errorf("form %q, path %q, want: %q, got: %q", tc.form, tc.path, tc.want, got)
+		}
+	}
+}
+
+func TestForm_Paths(t *testing.T) {
+	got := pathnorm.NFC.Paths([]string{nfd, "IMG_0001.jpg"})
+	if len(got) != 2 || got[0] != nfc || got[1] != "IMG_0001.jpg" {
+		t.Errorf("want: %q, got: %q", []string{nfc, "IMG_0001.jpg"}, got)
+	}
+	if got := pathnorm.NFC.Paths(nil); got != nil {
+		t.Errorf("want: nil, got: %q", got)
+	}
+}
@@ -0,0 +1,161 @@
+// Package photosapi builds the Google Photos services used to upload files. They are shared by the push
+// command and the uploader package, so both upload files the same way.
+package photosapi
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	gphotos "github.com/gphotosuploader/google-photos-api-client-go/v2"
+	"github.com/gphotosuploader/google-photos-api-client-go/v2/albums"
+	"github.com/gphotosuploader/google-photos-api-client-go/v2/media_items"
+	"github.com/hashicorp/go-retryablehttp"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/exif"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/library"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/ratelimit"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/task"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/transport"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/upload"
+)
+
+// albumsListRate is the maximum number of pages of albums listed per second.
+const albumsListRate = 5
+
+// Options are the settings of the Google Photos client.
+type Options struct {
+	// BaseURL is the base URL of the API. Uses library.DefaultBaseURL by default.
+	BaseURL string
+
+	// Sessions keeps the upload sessions, so an interrupted upload continues where it was.
+	Sessions upload.SessionStore
+
+	// Limiter throttles the uploaded content. A nil Limiter means unlimited.
+	Limiter *ratelimit.Limiter
+
+	// OnProgress, if it's set, is called with the number of bytes sent of a file while uploading it.
+	OnProgress func(path string, n int64)
+
+	// ChunkSize is the size of the chunks the files are uploaded in. Uses upload.DefaultChunkSize by default.
+	ChunkSize int64
+
+	// FSRetry retries opening and reading the uploaded files after transient errors of the file system.
+	FSRetry upload.FSRetry
+
+	Logger log.Logger
+}
+
+// NewClient returns a Google Photos client uploading files with the resumable uploader, throttled by the limiter.
+func NewClient(client *http.Client, opts Options) (*gphotos.Client, error) {
+	endpoints := library.NewEndpoints(opts.BaseURL)
+	uploader := upload.NewResumableUploader(client, opts.Sessions, opts.Logger)
+	uploader.Endpoint = endpoints.Uploads()
+	uploader.RateLimiter = opts.Limiter
+	uploader.OnProgress = opts.OnProgress
+	uploader.ChunkSize = opts.ChunkSize
+	uploader.FSRetry = opts.FSRetry
+
+	// albums and media items requests are retried, like the client library does by default.
+	retrying := RetryingClient(client)
+	albumsRepo, err := albums.NewPhotosLibraryClientWithURL(retrying, endpoints.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+	mediaItemsRepo, err := media_items.NewPhotosLibraryClientWithURL(retrying, endpoints.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+	return gphotos.NewClient(client,
+		gphotos.WithUploader(uploader),
+		gphotos.WithAlbumsService(albums.NewCachedAlbumsService(retrying, albums.WithRepository(albumsRepo))),
+		gphotos.WithMediaItemsService(library.MediaItemsService{
+			Repo:                mediaItemsRepo,
+			Client:              retrying,
+			BatchCreateEndpoint: endpoints.MediaItemsBatchCreate(),
+		}),
+	)
+}
+
+// NewAlbumCache returns the cache of the albums where the files are added, listing the existing albums once,
+// and the service setting their metadata. Requests are sent to the API at baseURL, or library.DefaultBaseURL
+// if it's empty.
+func NewAlbumCache(client *http.Client, photos *gphotos.Client, baseURL string, logger log.Logger) (*task.AlbumCache, *library.AlbumsService) {
+	metadata := library.NewAlbumsService(client)
+	metadata.Endpoint = library.NewEndpoints(baseURL).Albums()
+	// albums are listed once, retrying throttled requests, instead of searching every album by title.
+	lister := library.NewAlbumsService(RetryingClient(client))
+	lister.Endpoint = metadata.Endpoint
+	lister.Limiter = ratelimit.NewLimiter(albumsListRate)
+	cache := task.NewAlbumCache(photos.Albums, logger)
+	cache.Metadata = metadata
+	cache.Lister = lister
+	return cache, metadata
+}
+
+// NewSharedAlbums returns the albums shared with the account, requested to the API at baseURL, or
+// library.DefaultBaseURL if it's empty, retrying throttled requests.
+func NewSharedAlbums(client *http.Client, baseURL string, logger log.Logger) *task.SharedAlbums {
+	service := library.NewSharedAlbumsService(RetryingClient(client))
+	service.Endpoint = library.NewEndpoints(baseURL).SharedAlbums()
+	service.Limiter = ratelimit.NewLimiter(albumsListRate)
+	return task.NewSharedAlbums(service, logger)
+}
+
+// NewMatcher returns the matcher of the files with the media items of the library, searched at baseURL, or
+// library.DefaultBaseURL if it's empty, retrying the failed requests.
+func NewMatcher(client *http.Client, baseURL string) *library.Matcher {
+	search := library.NewSearchService(RetryingClient(client))
+	search.Endpoint = library.NewEndpoints(baseURL).MediaItemsSearch()
+	return library.NewMatcher(search, exif.Reader{})
+}
+
+// NewUploads returns the uploads of the files with the Google Photos client, see upload.TokenReusingUploads.
+// The media items that could have been created by a failed attempt are searched by finder, if it's set, and
+// created again up to retries times, waiting retryDelay before the first retry.
+func NewUploads(photos *gphotos.Client, finder upload.MediaItemFinder, retries int, retryDelay time.Duration) *upload.TokenReusingUploads {
+	uploads := upload.NewTokenReusingUploads(photos.Uploader, photos.MediaItems)
+	uploads.Finder = finder
+	uploads.Retries = retries
+	uploads.RetryDelay = retryDelay
+	return uploads
+}
+
+// RetryingClient returns a HTTP client retrying the failed requests with exponential backoff. Requests that
+// are not idempotent, see transport.IsIdempotent, are only retried if they have not been processed.
+func RetryingClient(client *http.Client) *http.Client {
+	c := retryablehttp.NewClient()
+	c.Logger = nil // Disable DEBUG logs
+	c.CheckRetry = func(ctx context.Context, resp *http.Response, err error) (bool, error) {
+		// the storage of the account is not freed up by retrying.
+		if resp != nil && resp.StatusCode == http.StatusInsufficientStorage {
+			return false, nil
+		}
+		// the request could have been processed despite the error, so it's not sent again.
+		if ctx.Value(notIdempotentKey{}) != nil && (err != nil || resp.StatusCode >= 500) {
+			return false, nil
+		}
+		return retryablehttp.DefaultRetryPolicy(ctx, resp, err)
+	}
+	c.HTTPClient = client
+	retrying := c.StandardClient()
+	retrying.Transport = idempotency{base: retrying.Transport}
+	return retrying
+}
+
+// notIdempotentKey is the key of the context value marking the requests that are not idempotent.
+type notIdempotentKey struct{}
+
+// idempotency is a http.RoundTripper marking the context of the requests that are not idempotent, since
+// retryablehttp only gives the context of a request to its retry policy.
+type idempotency struct {
+	base http.RoundTripper
+}
+
+func (t idempotency) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !transport.IsIdempotent(req) {
+		req = req.WithContext(context.WithValue(req.Context(), notIdempotentKey{}, true))
+	}
+	return t.base.RoundTrip(req)
+}
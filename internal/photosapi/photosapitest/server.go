@@ -0,0 +1,191 @@
+// Package photosapitest provides a fake Google Photos API server, for the tests of the uploads.
+package photosapitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server is a Google Photos API server, implementing the requests made by the uploads. Its fields are
+// protected by its mutex.
+type Server struct {
+	*httptest.Server
+
+	sync.Mutex
+	// Uploaded is the content of the uploaded files.
+	Uploaded []string
+	// UploadedBy are the access tokens of the uploads of the files, by their content.
+	UploadedBy map[string]string
+	// Created are the upload tokens of the created media items.
+	Created []string
+	// CreatedIn are the albums of the requests creating media items, empty if they are not added to any album.
+	CreatedIn []string
+	// Unexpected are the requests not implemented by the server.
+	Unexpected []string
+	// Proxied are the hosts of the requests received as a proxy.
+	Proxied []string
+	// Albums are the titles of the created albums.
+	Albums []string
+	// UploadFailure, if it's set, is the content of the files whose upload is refused by a 400 Bad Request response.
+	UploadFailure string
+	// CreateFailure, if it's set, is the message of the 403 Forbidden response to the media items creation.
+	CreateFailure string
+	// UploadDelay is the time taken by every upload of the content of a file.
+	UploadDelay time.Duration
+	// DropCreates is the number of requests creating media items whose response is lost, once they have been
+	// processed, and Library the filenames of the created media items, as they are searched.
+	DropCreates int
+	Library     []string
+	// Filename is the name of the file of the last upload session started.
+	Filename string
+	// Stalls is the number of uploads of the content of a file that hang until their request is cancelled, and
+	// Cancelled the number of them that have been cancelled.
+	Stalls    int
+	Cancelled int
+	// Requests are the method and the path of all the requests received, in order.
+	Requests []string
+	// Headers are the headers of all the requests received, in order.
+	Headers []http.Header
+}
+
+const (
+	familyAlbum  = `{"id":"shared-family","title":"Family","isWriteable":true,"shareInfo":{"shareToken":"family-token","isJoined":true}}`
+	friendsAlbum = `{"id":"shared-friends","title":"Friends","shareInfo":{"shareToken":"friends-token","isJoined":true}}`
+)
+
+// NewServer returns a started Server, to be closed once the test has finished.
+func NewServer() *Server {
+	api := &Server{UploadedBy: make(map[string]string)}
+	api.Server = httptest.NewServer(http.HandlerFunc(api.handle))
+	return api
+}
+
+// UploadStarted returns true if any upload session has been started.
+func (api *Server) UploadStarted() bool {
+	api.Lock()
+	defer api.Unlock()
+	return api.Filename != ""
+}
+
+// stall returns true if the upload has to hang, counting it.
+func (api *Server) stall() bool {
+	api.Lock()
+	defer api.Unlock()
+	if api.Stalls == 0 {
+		return false
+	}
+	api.Stalls--
+	return true
+}
+
+func (api *Server) handle(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/v1/uploads/session-1" {
+		if api.stall() {
+			// the request is only cancelled once its body has been read.
+			_, _ = ioutil.ReadAll(r.Body)
+			<-r.Context().Done()
+			api.Lock()
+			api.Cancelled++
+			api.Unlock()
+			return
+		}
+		time.Sleep(api.UploadDelay)
+	}
+	api.Lock()
+	defer api.Unlock()
+
+	api.Requests = append(api.Requests, r.Method+" "+r.URL.Path)
+	api.Headers = append(api.Headers, r.Header.Clone())
+	if r.URL.IsAbs() {
+		api.Proxied = append(api.Proxied, r.URL.Host)
+	}
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/uploads" && r.Header.Get("X-Goog-Upload-Command") == "start":
+		api.Filename = r.Header.Get("X-Goog-Upload-File-Name")
+		w.Header().Set("X-Goog-Upload-URL", api.URL+"/v1/uploads/session-1")
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/uploads/session-1" && r.Header.Get("X-Goog-Upload-Command") == "query":
+		// the content of the files is not kept, so their uploads are resumed from the start.
+		w.Header().Set("X-Goog-Upload-Status", "active")
+		w.Header().Set("X-Goog-Upload-Size-Received", "0")
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/uploads/session-1":
+		b, _ := ioutil.ReadAll(r.Body)
+		if api.UploadFailure != "" && string(b) == api.UploadFailure {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		api.Uploaded = append(api.Uploaded, string(b))
+		api.UploadedBy[string(b)] = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		fmt.Fprint(w, "upload-token-1")
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/mediaItems:batchCreate" && api.CreateFailure != "":
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprintf(w, `{"error":{"code":403,"message":%q,"status":"RESOURCE_EXHAUSTED"}}`, api.CreateFailure)
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/mediaItems:batchCreate":
+		var req struct {
+			AlbumID       string `json:"albumId"`
+			NewMediaItems []struct {
+				SimpleMediaItem struct {
+					UploadToken string `json:"uploadToken"`
+				} `json:"simpleMediaItem"`
+			} `json:"newMediaItems"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		for _, item := range req.NewMediaItems {
+			api.Created = append(api.Created, item.SimpleMediaItem.UploadToken)
+			api.Library = append(api.Library, api.Filename)
+		}
+		api.CreatedIn = append(api.CreatedIn, req.AlbumID)
+		if api.DropCreates > 0 {
+			api.DropCreates--
+			if conn, _, err := w.(http.Hijacker).Hijack(); err == nil {
+				_ = conn.Close()
+			}
+			return
+		}
+		fmt.Fprint(w, `{"newMediaItemResults":[{"uploadToken":"upload-token-1","status":{"message":"Success"},"mediaItem":{"id":"media-item-1","mediaMetadata":{}}}]}`)
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/mediaItems:search":
+		items := make([]string, len(api.Library))
+		for i, name := range api.Library {
+			items[i] = fmt.Sprintf(`{"id":"media-item-%d","filename":%q,"mediaMetadata":{}}`, i+1, name)
+		}
+		fmt.Fprintf(w, `{"mediaItems":[%s]}`, strings.Join(items, ","))
+	case r.Method == http.MethodPost && r.URL.Path == "/token":
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"refreshed-token","token_type":"Bearer","expires_in":3600}`)
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/mediaItems/media-item-1":
+		fmt.Fprint(w, `{"id":"media-item-1","productUrl":"https://photos.google.com/lr/photo/media-item-1","mediaMetadata":{}}`)
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/albums":
+		fmt.Fprint(w, `{"albums":[]}`)
+	// the albums shared with the account are a writable one, Family, and a read-only one, Friends.
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/sharedAlbums":
+		fmt.Fprintf(w, `{"sharedAlbums":[%s,%s]}`, familyAlbum, friendsAlbum)
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/sharedAlbums/family-token":
+		fmt.Fprint(w, familyAlbum)
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/sharedAlbums/friends-token":
+		fmt.Fprint(w, friendsAlbum)
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/albums":
+		var req struct {
+			Album struct {
+				Title string `json:"title"`
+			} `json:"album"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		api.Albums = append(api.Albums, req.Album.Title)
+		fmt.Fprintf(w, `{"id":"album-%d","title":%q}`, len(api.Albums), req.Album.Title)
+	default:
+		api.Unexpected = append(api.Unexpected, r.Method+" "+r.URL.String())
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
@@ -0,0 +1,55 @@
+package progress
+
+import (
+	"time"
+)
+
+// DefaultSmoothing is the weight of the last throughput sample in the moving average.
+const DefaultSmoothing = 0.3
+
+// ETA estimates the remaining time of the uploads, using an exponential moving average
+// of the throughput. It's not safe for concurrent use.
+type ETA struct {
+	alpha float64
+	rate  float64 // bytes per second
+	valid bool
+}
+
+// NewETA returns an ETA where alpha, between 0 and 1, is the weight of every new sample.
+// Higher values react faster to throughput changes, lower values are smoother.
+func NewETA(alpha float64) *ETA {
+	if alpha <= 0 || alpha > 1 {
+		alpha = DefaultSmoothing
+	}
+	return &ETA{alpha: alpha}
+}
+
+// Update adds a throughput sample: the number of bytes transferred during elapsed.
+func (e *ETA) Update(bytes int64, elapsed time.Duration) {
+	if elapsed <= 0 {
+		return
+	}
+	sample := float64(bytes) / elapsed.Seconds()
+	if !e.valid {
+		e.rate, e.valid = sample, true
+		return
+	}
+	e.rate = e.alpha*sample + (1-e.alpha)*e.rate
+}
+
+// Rate returns the average throughput, in bytes per second.
+func (e *ETA) Rate() float64 {
+	return e.rate
+}
+
+// Remaining returns the estimated time to transfer the remaining bytes.
+// It returns false if it could not be estimated, e.g. nothing has been transferred yet.
+func (e *ETA) Remaining(bytes int64) (time.Duration, bool) {
+	if bytes <= 0 {
+		return 0, true
+	}
+	if !e.valid || e.rate <= 0 {
+		return 0, false
+	}
+	return time.Duration(float64(bytes) / e.rate * float64(time.Second)).Round(time.Second), true
+}
@@ -0,0 +1,51 @@
+package progress_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/progress"
+)
+
+func TestETA_Remaining(t *testing.T) {
+	// sample is the number of bytes transferred during a period.
+	type sample struct {
+		bytes   int64
+		elapsed time.Duration
+	}
+
+	testCases := []struct {
+		name      string
+		alpha     float64
+		samples   []sample
+		remaining int64
+		want      time.Duration
+		wantOk    bool
+	}{
+		{"Should be unknown without samples", 0.5, nil, 1000, 0, false},
+		{"Should be unknown without throughput", 0.5, []sample{{0, time.Second}}, 1000, 0, false},
+		{"Should be zero if nothing remains", 0.5, nil, 0, 0, true},
+		{"Should use the first sample", 0.5, []sample{{100, time.Second}}, 1000, 10 * time.Second, true},
+		{"Should keep a constant throughput", 0.5, []sample{{100, time.Second}, {200, 2 * time.Second}, {50, 500 * time.Millisecond}}, 1000, 10 * time.Second, true},
+		{"Should average the throughput", 0.5, []sample{{100, time.Second}, {300, time.Second}}, 1000, 5 * time.Second, true},
+		{"Should weight the last samples", 0.5, []sample{{100, time.Second}, {300, time.Second}, {600, time.Second}}, 4000, 10 * time.Second, true},
+		{"Should ignore samples without elapsed time", 0.5, []sample{{100, time.Second}, {1000, 0}}, 1000, 10 * time.Second, true},
+		{"Should use the default smoothing if alpha is invalid", 0, []sample{{100, time.Second}, {200, time.Second}}, 1300, 10 * time.Second, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			eta := progress.NewETA(tc.alpha)
+			for _, s := range tc.samples {
+				eta.Update(s.bytes, s.elapsed)
+			}
+			got, ok := eta.Remaining(tc.remaining)
+			if ok != tc.wantOk {
+				t.Fatalf("want: %t, got: %t", tc.wantOk, ok)
+			}
+			if got != tc.want {
+				t.Errorf("want: %s, got: %s", tc.want, got)
+			}
+		})
+	}
+}
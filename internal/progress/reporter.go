@@ -0,0 +1,157 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+
+	"github.com/schollz/progressbar/v3"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+)
+
+// Renderer renders the progress of the uploads.
+type Renderer interface {
+	Render(s Snapshot, eta string)
+	Finish(s Snapshot)
+}
+
+// Reporter renders the progress of a Tracker on every tick, so the progress is not
+// rendered every time some bytes are uploaded.
+type Reporter struct {
+	tracker  *Tracker
+	renderer Renderer
+	interval time.Duration
+	eta      *ETA
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewReporter returns a Reporter rendering the tracker progress every interval.
+func NewReporter(tracker *Tracker, renderer Renderer, interval time.Duration) *Reporter {
+	return &Reporter{
+		tracker:  tracker,
+		renderer: renderer,
+		interval: interval,
+		eta:      NewETA(DefaultSmoothing),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start starts rendering the progress in background.
+func (r *Reporter) Start() {
+	go func() {
+		defer close(r.done)
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		last, lastTime := r.tracker.Snapshot().DoneBytes, time.Now()
+		for {
+			select {
+			case <-r.stop:
+				r.renderer.Finish(r.tracker.Snapshot())
+				return
+			case now := <-ticker.C:
+				s := r.tracker.Snapshot()
+				r.eta.Update(s.DoneBytes-last, now.Sub(lastTime))
+				last, lastTime = s.DoneBytes, now
+				r.renderer.Render(s, r.remaining(s))
+			}
+		}
+	}()
+}
+
+// Stop stops rendering the progress, rendering it a last time.
+func (r *Reporter) Stop() {
+	close(r.stop)
+	<-r.done
+}
+
+func (r *Reporter) remaining(s Snapshot) string {
+	d, ok := r.eta.Remaining(s.TotalBytes - s.DoneBytes)
+	if !ok {
+		return "unknown"
+	}
+	return d.String()
+}
+
+// BarRenderer renders the progress as an interactive progress bar.
+type BarRenderer struct {
+	w   io.Writer
+	bar *progressbar.ProgressBar
+}
+
+// NewBarRenderer returns a BarRenderer writing to w, that should be a terminal.
+func NewBarRenderer(w io.Writer) *BarRenderer {
+	return &BarRenderer{w: w}
+}
+
+// Render updates the progress bar.
+func (b *BarRenderer) Render(s Snapshot, eta string) {
+	b.update(s, fmt.Sprintf("[%d/%d] %s %s/%s, ETA %s", s.DoneFiles, s.TotalFiles,
+		filepath.Base(s.CurrentFile), FormatBytes(s.CurrentSent), FormatBytes(s.CurrentSize), eta))
+}
+
+// Finish completes the progress bar.
+func (b *BarRenderer) Finish(s Snapshot) {
+	b.update(s, fmt.Sprintf("[%d/%d] Uploading files...", s.DoneFiles, s.TotalFiles))
+	if b.bar != nil {
+		_ = b.bar.Finish()
+		_, _ = fmt.Fprintln(b.w)
+	}
+}
+
+// update renders the snapshot. The bar is created once the size of the files is known,
+// because the bar can't change from an unknown to a known size.
+func (b *BarRenderer) update(s Snapshot, description string) {
+	if s.TotalBytes <= 0 {
+		return
+	}
+	if b.bar == nil {
+		b.bar = progressbar.NewOptions64(s.TotalBytes,
+			progressbar.OptionSetWriter(b.w),
+			progressbar.OptionFullWidth(),
+			progressbar.OptionSetPredictTime(false),
+			progressbar.OptionShowBytes(true),
+		)
+	}
+	b.bar.ChangeMax64(s.TotalBytes)
+	b.bar.Describe(description)
+	_ = b.bar.Set64(s.DoneBytes)
+}
+
+// LogRenderer renders the progress as log lines, useful when the output is not a terminal.
+type LogRenderer struct {
+	logger log.Logger
+}
+
+// NewLogRenderer returns a LogRenderer using the logger.
+func NewLogRenderer(logger log.Logger) *LogRenderer {
+	return &LogRenderer{logger: logger}
+}
+
+// Render logs the progress.
+func (l *LogRenderer) Render(s Snapshot, eta string) {
+	l.logger.Infof("Progress: %d/%d files, %s/%s, uploading '%s' (%s/%s), ETA %s", s.DoneFiles, s.TotalFiles,
+		FormatBytes(s.DoneBytes), FormatBytes(s.TotalBytes), s.CurrentFile, FormatBytes(s.CurrentSent), FormatBytes(s.CurrentSize), eta)
+}
+
+// Finish logs nothing, the summary of the uploads is logged by the caller.
+func (l *LogRenderer) Finish(s Snapshot) {}
+
+// FormatBytes returns a human readable size, e.g. "1.5 MB".
+func FormatBytes(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "kMGTPE"[exp])
+}
@@ -0,0 +1,105 @@
+// Package progress tracks the progress of the uploads and reports it periodically.
+package progress
+
+import (
+	"sync"
+)
+
+// Tracker keeps the progress of the uploads.
+// It's safe for concurrent use, so it could be updated by several workers.
+type Tracker struct {
+	mu sync.Mutex
+
+	files      map[string]*file
+	totalFiles int
+	doneFiles  int
+	totalBytes int64
+	doneBytes  int64
+	current    string
+}
+
+// file is the progress of a single file.
+type file struct {
+	size int64
+	sent int64
+}
+
+// Snapshot is the progress of the uploads at a given time.
+type Snapshot struct {
+	// CurrentFile is the last file that has sent some bytes.
+	CurrentFile string
+	CurrentSent int64
+	CurrentSize int64
+
+	DoneFiles  int
+	TotalFiles int
+	DoneBytes  int64
+	TotalBytes int64
+}
+
+// NewTracker returns an empty Tracker.
+func NewTracker() *Tracker {
+	return &Tracker{
+		files: make(map[string]*file),
+	}
+}
+
+// AddFile adds a file to be uploaded.
+func (t *Tracker) AddFile(path string, size int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, exist := t.files[path]; exist {
+		return
+	}
+	t.files[path] = &file{size: size}
+	t.totalFiles++
+	t.totalBytes += size
+}
+
+// Transferred adds n bytes sent of the given file.
+// Bytes sent over the size of the file, e.g. when an upload is retried, are not counted.
+func (t *Tracker) Transferred(path string, n int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	f, exist := t.files[path]
+	if !exist {
+		return
+	}
+	if f.sent+n > f.size {
+		n = f.size - f.sent
+	}
+	f.sent += n
+	t.doneBytes += n
+	t.current = path
+}
+
+// Done marks the file as processed, whether it has been uploaded or not.
+func (t *Tracker) Done(path string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	f, exist := t.files[path]
+	if !exist {
+		return
+	}
+	t.doneBytes += f.size - f.sent
+	f.sent = f.size
+	t.doneFiles++
+}
+
+// Snapshot returns the current progress.
+func (t *Tracker) Snapshot() Snapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := Snapshot{
+		CurrentFile: t.current,
+		DoneFiles:   t.doneFiles,
+		TotalFiles:  t.totalFiles,
+		DoneBytes:   t.doneBytes,
+		TotalBytes:  t.totalBytes,
+	}
+	if f, exist := t.files[t.current]; exist {
+		s.CurrentSent = f.sent
+		s.CurrentSize = f.size
+	}
+	return s
+}
@@ -0,0 +1,49 @@
+package progress_test
+
+import (
+	"testing"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/progress"
+)
+
+func TestTracker_Snapshot(t *testing.T) {
+	tracker := progress.NewTracker()
+	tracker.AddFile("a.jpg", 100)
+	tracker.AddFile("b.jpg", 200)
+	tracker.AddFile("a.jpg", 100) // added twice, counted once.
+
+	tracker.Transferred("a.jpg", 60)
+	tracker.Transferred("b.jpg", 50)
+	tracker.Transferred("unknown.jpg", 50)
+
+	want := progress.Snapshot{
+		CurrentFile: "b.jpg",
+		CurrentSent: 50,
+		CurrentSize: 200,
+		DoneFiles:   0,
+		TotalFiles:  2,
+		DoneBytes:   110,
+		TotalBytes:  300,
+	}
+	if got := tracker.Snapshot(); got != want {
+		t.Errorf("want: %+v, got: %+v", want, got)
+	}
+
+	// bytes over the file size are not counted, e.g. on retries.
+	tracker.Transferred("a.jpg", 60)
+	tracker.Done("a.jpg")
+	tracker.Done("b.jpg")
+
+	want = progress.Snapshot{
+		CurrentFile: "a.jpg",
+		CurrentSent: 100,
+		CurrentSize: 100,
+		DoneFiles:   2,
+		TotalFiles:  2,
+		DoneBytes:   300,
+		TotalBytes:  300,
+	}
+	if got := tracker.Snapshot(); got != want {
+		t.Errorf("want: %+v, got: %+v", want, got)
+	}
+}
@@ -0,0 +1,59 @@
+// Package prune finds the albums of Google Photos left empty, e.g. once their photos have been deleted.
+package prune
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gphotosuploader/google-photos-api-client-go/v2/albums"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+)
+
+// AlbumLister represents a way to list the albums of the library.
+type AlbumLister interface {
+	List(ctx context.Context) ([]albums.Album, error)
+}
+
+// AlbumSearcher represents a way to check if an album has media items.
+type AlbumSearcher interface {
+	IsAlbumEmpty(ctx context.Context, albumID string) (bool, error)
+}
+
+// Report is the result of a search of empty albums.
+type Report struct {
+	// Checked is the number of albums checked.
+	Checked int
+	// Empty are the albums without media items, in the order they were listed.
+	Empty []albums.Album
+}
+
+// EmptyAlbums finds the albums without media items. The media items of every album are searched, instead
+// of trusting its count, since it could be outdated.
+type EmptyAlbums struct {
+	Albums     AlbumLister
+	MediaItems AlbumSearcher
+	Logger     log.Logger
+}
+
+// Find checks every album, returning the report of the empty ones. The report has the albums checked so
+// far if it fails.
+func (e EmptyAlbums) Find(ctx context.Context) (Report, error) {
+	var report Report
+	list, err := e.Albums.List(ctx)
+	if err != nil {
+		return report, fmt.Errorf("unable to list the albums: %w", err)
+	}
+	for _, album := range list {
+		empty, err := e.MediaItems.IsAlbumEmpty(ctx, album.ID)
+		if err != nil {
+			return report, fmt.Errorf("unable to check album '%s': %w", album.Title, err)
+		}
+		report.Checked++
+		if empty {
+			e.Logger.Debugf("Album '%s' has no media items", album.Title)
+			report.Empty = append(report.Empty, album)
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,79 @@
+package prune_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/gphotosuploader/google-photos-api-client-go/v2/albums"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/prune"
+)
+
+// albumLister lists the albums, or fails with err.
+type albumLister struct {
+	albums []albums.Album
+	err    error
+}
+
+func (l albumLister) List(ctx context.Context) ([]albums.Album, error) {
+	return l.albums, l.err
+}
+
+// albumSearcher has the number of media items of every album, it fails for the missing ones.
+type albumSearcher map[string]int
+
+func (s albumSearcher) IsAlbumEmpty(ctx context.Context, albumID string) (bool, error) {
+	n, ok := s[albumID]
+	if !ok {
+		return false, errors.New("not found")
+	}
+	return n == 0, nil
+}
+
+func TestEmptyAlbums_Find(t *testing.T) {
+	list := []albums.Album{
+		{ID: "id-trip", Title: "Trip"},
+		{ID: "id-deleted", Title: "Deleted"},
+		{ID: "id-family", Title: "Family"},
+		{ID: "id-old", Title: "Old"},
+	}
+	testCases := []struct {
+		name    string
+		lister  albumLister
+		counts  albumSearcher
+		checked int
+		want    []string
+		errExp  bool
+	}{
+		{"Should report empty albums", albumLister{albums: list}, albumSearcher{"id-trip": 3, "id-deleted": 0, "id-family": 12, "id-old": 0}, 4, []string{"Deleted", "Old"}, false},
+		{"Should report none without empty albums", albumLister{albums: list}, albumSearcher{"id-trip": 3, "id-deleted": 1, "id-family": 12, "id-old": 2}, 4, nil, false},
+		{"Should report none without albums", albumLister{}, albumSearcher{}, 0, nil, false},
+		{"Should fail if albums could not be listed", albumLister{err: errors.New("forbidden")}, albumSearcher{}, 0, nil, true},
+		{"Should fail if an album could not be checked", albumLister{albums: list}, albumSearcher{"id-trip": 3, "id-deleted": 0}, 2, []string{"Deleted"}, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := prune.EmptyAlbums{Albums: tc.lister, MediaItems: tc.counts, Logger: log.Discard}
+			report, err := e.Find(context.Background())
+			if tc.errExp && err == nil {
+				t.Fatalf("error was expected, but not produced")
+			}
+			if !tc.errExp && err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			if tc.checked != report.Checked {
+				t.Errorf("want: %v, got: %v", tc.checked, report.Checked)
+			}
+			var got []string
+			for _, a := range report.Empty {
+				got = append(got, a.Title)
+			}
+			if !reflect.DeepEqual(tc.want, got) {
+				t.Errorf("want: %v, got: %v", tc.want, got)
+			}
+		})
+	}
+}
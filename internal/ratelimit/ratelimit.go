@@ -0,0 +1,143 @@
+// Package ratelimit implements a token bucket limiter to throttle readers.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/units"
+)
+
+// maxChunkSize is the maximum number of bytes read at once from a throttled reader,
+// to keep the rate smooth when it's shared between several readers.
+const maxChunkSize = 32 * 1024
+
+// Limiter is a token bucket limiter allowing a number of bytes per second.
+// It's safe for concurrent use, so the aggregated rate of all the readers using it is capped.
+type Limiter struct {
+	mu     sync.Mutex
+	rate   float64 // bytes per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter returns a Limiter allowing bytesPerSecond bytes per second.
+// It returns nil, meaning unlimited, if bytesPerSecond is zero or negative.
+func NewLimiter(bytesPerSecond int64) *Limiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	return &Limiter{
+		rate:  float64(bytesPerSecond),
+		burst: float64(bytesPerSecond),
+		last:  time.Now(),
+	}
+}
+
+// Reader returns a reader reading from r at the rate set by the limiter.
+// A nil Limiter returns r unchanged.
+func (l *Limiter) Reader(r io.Reader) io.Reader {
+	return l.ReaderContext(context.Background(), r)
+}
+
+// ReaderContext returns a reader reading from r at the rate set by the limiter, like Reader. Reads waiting for
+// the limiter fail with the error of ctx once it's done, e.g. when the request sending the content is cancelled.
+// A nil Limiter returns r unchanged.
+func (l *Limiter) ReaderContext(ctx context.Context, r io.Reader) io.Reader {
+	if l == nil {
+		return r
+	}
+	return &reader{ctx: ctx, r: r, limiter: l}
+}
+
+// Wait blocks until n tokens are allowed by the limiter. It allows to limit other
+// units than bytes, e.g. requests. A nil Limiter doesn't wait.
+func (l *Limiter) Wait(n int) {
+	_ = l.WaitContext(context.Background(), n)
+}
+
+// WaitContext blocks until n tokens are allowed by the limiter, like Wait, or ctx is done.
+// It returns the error of ctx if it's done before, giving the tokens back. A nil Limiter doesn't wait.
+func (l *Limiter) WaitContext(ctx context.Context, n int) error {
+	if l == nil {
+		return ctx.Err()
+	}
+	return l.wait(ctx, n)
+}
+
+// wait blocks until n bytes are allowed by the limiter, or ctx is done.
+// Tokens are reserved in advance, so concurrent callers are served in order.
+func (l *Limiter) wait(ctx context.Context, n int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	l.tokens -= float64(n)
+
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// the reserved tokens are not used, so the next callers don't wait for them.
+		l.mu.Lock()
+		l.tokens += float64(n)
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+type reader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *Limiter
+}
+
+// Read reads up to len(p) bytes, waiting for the limiter to allow them.
+func (r *reader) Read(p []byte) (int, error) {
+	if len(p) > maxChunkSize {
+		p = p[:maxChunkSize]
+	}
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if werr := r.limiter.wait(r.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+// Parse returns the number of bytes per second of a rate like "2MB/s", "500KiB/s" or "1000".
+// Units are the ones of units.ParseSize. An empty value or "0" means unlimited, and returns 0.
+func Parse(value string) (int64, error) {
+	s := strings.TrimSpace(value)
+	if strings.HasSuffix(strings.ToUpper(s), "/S") {
+		s = s[:len(s)-len("/s")]
+	}
+	n, err := units.ParseSize(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rate '%s'", value)
+	}
+	return n, nil
+}
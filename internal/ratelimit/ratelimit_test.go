@@ -0,0 +1,166 @@
+package ratelimit_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/ratelimit"
+)
+
+func TestLimiter_Reader(t *testing.T) {
+	const rate = 100 * 1000 // bytes per second
+	const size = 50 * 1000
+
+	testCases := []struct {
+		name    string
+		readers int
+	}{
+		{"Should throttle a single reader", 1},
+		{"Should throttle the aggregated rate of concurrent readers", 5},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			limiter := ratelimit.NewLimiter(rate)
+
+			var wg sync.WaitGroup
+			start := time.Now()
+			for i := 0; i < tc.readers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					r := limiter.Reader(bytes.NewReader(make([]byte, size/tc.readers)))
+					if _, err := io.Copy(ioutil.Discard, r); err != nil {
+						t.Errorf("error was not expected at this point: %s", err)
+					}
+				}()
+			}
+			wg.Wait()
+
+			// size bytes at rate bytes per second, with a small tolerance for the timer.
+			want := time.Duration(size) * time.Second / rate * 9 / 10
+			if got := time.Since(start); got < want {
+				t.Errorf("want: at least %s, got: %s", want, got)
+			}
+		})
+	}
+}
+
+func TestLimiter_ReaderUnlimited(t *testing.T) {
+	limiter := ratelimit.NewLimiter(0)
+	r := bytes.NewReader([]byte("content"))
+	if got := limiter.Reader(r); got != r {
+		t.Errorf("want: the same reader, got: %v", got)
+	}
+}
+
+func TestLimiter_Wait(t *testing.T) {
+	const rate = 100 // requests per second
+	const requests = 20
+
+	limiter := ratelimit.NewLimiter(rate)
+	start := time.Now()
+	for i := 0; i < requests; i++ {
+		limiter.Wait(1)
+	}
+
+	want := time.Duration(requests) * time.Second / rate * 9 / 10
+	if got := time.Since(start); got < want {
+		t.Errorf("want: at least %s, got: %s", want, got)
+	}
+}
+
+func TestLimiter_WaitContext(t *testing.T) {
+	const rate = 100 // requests per second
+
+	// the limiter starts empty, so rate requests wait for a second.
+	limiter := ratelimit.NewLimiter(rate)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := limiter.WaitContext(ctx, rate)
+	if err != context.DeadlineExceeded {
+		t.Errorf("want: %v, got: %v", context.DeadlineExceeded, err)
+	}
+	if got := time.Since(start); got > 500*time.Millisecond {
+		t.Errorf("want: no wait once the context is done, got: %s", got)
+	}
+
+	// the tokens of the cancelled wait are given back.
+	start = time.Now()
+	if err := limiter.WaitContext(context.Background(), rate/10); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if got := time.Since(start); got > 500*time.Millisecond {
+		t.Errorf("want: less than %s, got: %s", 500*time.Millisecond, got)
+	}
+}
+
+func TestLimiter_ReaderContext(t *testing.T) {
+	const rate = 1000 // bytes per second
+
+	limiter := ratelimit.NewLimiter(rate)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	r := limiter.ReaderContext(ctx, bytes.NewReader(make([]byte, 10*rate)))
+	start := time.Now()
+	_, err := io.Copy(ioutil.Discard, r)
+	if err != context.DeadlineExceeded {
+		t.Errorf("want: %v, got: %v", context.DeadlineExceeded, err)
+	}
+	if got := time.Since(start); got > 2*time.Second {
+		t.Errorf("want: no wait once the context is done, got: %s", got)
+	}
+}
+
+func TestLimiter_WaitUnlimited(t *testing.T) {
+	var limiter *ratelimit.Limiter
+	start := time.Now()
+	limiter.Wait(1000)
+	if got := time.Since(start); got > 100*time.Millisecond {
+		t.Errorf("want: no wait, got: %s", got)
+	}
+}
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		name          string
+		input         string
+		want          int64
+		isErrExpected bool
+	}{
+		{"Should return 0 if empty", "", 0, false},
+		{"Should return 0 if zero", "0", 0, false},
+		{"Should parse bytes", "1000", 1000, false},
+		{"Should parse bytes per second", "1000B/s", 1000, false},
+		{"Should parse KB/s", "500KB/s", 500 * 1000, false},
+		{"Should parse MB/s", "2MB/s", 2 * 1000 * 1000, false},
+		{"Should parse decimal MB/s", "1.5MB/s", 1500 * 1000, false},
+		{"Should parse MiB/s", "2MiB/s", 2 * 1024 * 1024, false},
+		{"Should parse lowercase units", "2mb/s", 2 * 1000 * 1000, false},
+		{"Should parse units without /s", "2MB", 2 * 1000 * 1000, false},
+		{"Should parse units with spaces", " 2 MB/s ", 2 * 1000 * 1000, false},
+		{"Should fail if unit is unknown", "2XB/s", 0, true},
+		{"Should fail if number is invalid", "fast", 0, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ratelimit.Parse(tc.input)
+			if tc.isErrExpected && err == nil {
+				t.Fatalf("error was expected, but not produced")
+			}
+			if !tc.isErrExpected && err != nil {
+				t.Fatalf("error was not expected, err: %s", err)
+			}
+			if got != tc.want {
+				t.Errorf("want: %d, got: %d", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+// Package report writes the records of the files processed by the runs, e.g. to be reviewed in a spreadsheet.
+package report
+
+import (
+	"encoding/csv"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Statuses are the values of the status column, what has been done with a file.
+const (
+	StatusUploaded = "uploaded"
+	StatusAttached = "attached"
+	StatusSkipped  = "skipped"
+	StatusFailed   = "failed"
+)
+
+const (
+	// fileMode is the mode of the created reports, they are only readable by the owner.
+	fileMode = 0600
+
+	// flushInterval is how often the written rows are flushed to the file, so they are kept if the run crashes.
+	flushInterval = time.Second
+)
+
+// Header are the columns of the CSV reports.
+var Header = []string{"path", "album", "media_item_id", "bytes", "timestamp", "status", "reason"}
+
+// Row is a file processed by the run.
+type Row struct {
+	Path        string
+	Album       string
+	MediaItemID string
+	Bytes       int64
+	Time        time.Time
+	Status      string
+	// Reason is why the file has been skipped or it has failed, one of the log.Reason* codes.
+	Reason string
+}
+
+// CSV is a report appending a row per processed file to a CSV file. It's safe for concurrent use.
+// Rows are flushed every flushInterval, and when it's closed.
+type CSV struct {
+	mu        sync.Mutex
+	f         *os.File
+	w         *csv.Writer
+	lastFlush time.Time
+}
+
+// OpenCSV opens the report at path, appending the rows to the existing ones. The header is written if the file
+// is created, or it's empty.
+func OpenCSV(path string) (*CSV, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, fileMode)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	r := &CSV{f: f, w: csv.NewWriter(f), lastFlush: time.Now()}
+	if fi.Size() == 0 {
+		if err := r.w.Write(Header); err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Add appends the row of a file. A zero Time is the current time.
+func (r *CSV) Add(row Row) error {
+	if row.Time.IsZero() {
+		row.Time = time.Now()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.w.Write([]string{
+		row.Path,
+		row.Album,
+		row.MediaItemID,
+		strconv.FormatInt(row.Bytes, 10),
+		row.Time.UTC().Format(time.RFC3339),
+		row.Status,
+		row.Reason,
+	}); err != nil {
+		return err
+	}
+	if time.Since(r.lastFlush) < flushInterval {
+		return nil
+	}
+	r.lastFlush = time.Now()
+	r.w.Flush()
+	return r.w.Error()
+}
+
+// Close flushes the rows not written yet, and closes the file.
+func (r *CSV) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.w.Flush()
+	if err := r.w.Error(); err != nil {
+		_ = r.f.Close()
+		return err
+	}
+	return r.f.Close()
+}
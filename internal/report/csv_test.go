@@ -0,0 +1,67 @@
+package report_test
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/report"
+)
+
+func TestCSV(t *testing.T) {
+	dir, err := ioutil.TempDir("", "report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "report.csv")
+
+	// the second run appends its rows, without writing the header again.
+	date := time.Date(2023, 7, 15, 14, 22, 33, 0, time.UTC)
+	runs := [][]report.Row{
+		{
+			{Path: "/photos/IMG_0001.jpg", Album: "Trips", MediaItemID: "media-1", Bytes: 1024, Time: date, Status: report.StatusUploaded},
+			{Path: "/photos/IMG_0002.jpg", Album: "Trips", Bytes: 2048, Time: date, Status: report.StatusFailed, Reason: "upload_failed"},
+		},
+		{
+			{Path: "/photos/IMG_0003.txt", Time: date, Status: report.StatusSkipped, Reason: "excluded"},
+		},
+	}
+	for _, rows := range runs {
+		r, err := report.OpenCSV(path)
+		if err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+		for _, row := range rows {
+			if err := r.Add(row); err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+		}
+		if err := r.Close(); err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	got, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	want := [][]string{
+		report.Header,
+		{"/photos/IMG_0001.jpg", "Trips", "media-1", "1024", "2023-07-15T14:22:33Z", "uploaded", ""},
+		{"/photos/IMG_0002.jpg", "Trips", "", "2048", "2023-07-15T14:22:33Z", "failed", "upload_failed"},
+		{"/photos/IMG_0003.txt", "", "", "0", "2023-07-15T14:22:33Z", "skipped", "excluded"},
+	}
+	if fmt.Sprint(want) != fmt.Sprint(got) {
+		t.Errorf("want: %v, got: %v", want, got)
+	}
+}
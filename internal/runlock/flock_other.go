@@ -0,0 +1,31 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package runlock
+
+import (
+	"os"
+)
+
+// tryLock considers the file locked if its PID is the one of another running process, since flock is not
+// available. Locks of crashed processes are detected by their PID.
+func tryLock(f *os.File, pid int) (bool, error) {
+	if pid == 0 || pid == os.Getpid() {
+		return true, nil
+	}
+	return !processExists(pid), nil
+}
+
+func unlock(f *os.File) error {
+	return nil
+}
+
+// processExists returns true if there is a process with the PID.
+func processExists(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	_ = p.Release()
+	return true
+}
@@ -0,0 +1,135 @@
+// Package runlock prevents concurrent runs using the same application data, e.g. overlapping cron entries
+// writing to the same tracking store. The lock is a file kept locked by the running instance, with its PID.
+package runlock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultFilename is the name of the lock file in the application data folder.
+const DefaultFilename = "run.lock"
+
+// ErrLocked is returned when the lock is held by another running instance.
+var ErrLocked = errors.New("another instance is running")
+
+// LockedError is returned when the lock is held by another running instance, with its PID.
+type LockedError struct {
+	Path string
+	// PID is the process ID of the instance holding the lock, or 0 if it's unknown.
+	PID int
+}
+
+func (e *LockedError) Error() string {
+	if e.PID == 0 {
+		return fmt.Sprintf("%s, lock file '%s' is held", ErrLocked, e.Path)
+	}
+	return fmt.Sprintf("%s (pid %d), lock file '%s' is held", ErrLocked, e.PID, e.Path)
+}
+
+func (e *LockedError) Is(target error) bool { return target == ErrLocked }
+
+// Lock is a lock file held by this process.
+type Lock struct {
+	f    *os.File
+	path string
+
+	// StalePID is the PID recorded by a previous holder that didn't release the lock, e.g. because it
+	// crashed, or 0 if the lock was released properly.
+	StalePID int
+}
+
+// TryAcquire acquires the lock file at path, creating it if it doesn't exist. It returns a *LockedError, that
+// is ErrLocked, if it's held by another running instance.
+func TryAcquire(path string) (*Lock, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	pid := readPID(f)
+
+	locked, err := tryLock(f, pid)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	if !locked {
+		_ = f.Close()
+		return nil, &LockedError{Path: path, PID: pid}
+	}
+
+	l := &Lock{f: f, path: path}
+	// the PID is removed on release, so it's only kept if the previous holder didn't release the lock.
+	if pid != 0 && pid != os.Getpid() {
+		l.StalePID = pid
+	}
+	if err := l.writePID(os.Getpid()); err != nil {
+		_ = l.Release()
+		return nil, err
+	}
+	return l, nil
+}
+
+// Acquire acquires the lock file at path like TryAcquire, but waiting for the lock to be released if it's
+// held by another running instance. It checks it every interval, until the context is done.
+func Acquire(ctx context.Context, path string, interval time.Duration) (*Lock, error) {
+	for {
+		l, err := TryAcquire(path)
+		if !errors.Is(err, ErrLocked) {
+			return l, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w, waiting for it: %s", err, ctx.Err())
+		case <-time.After(interval):
+		}
+	}
+}
+
+// Release releases the lock, removing the PID from the lock file. The file is kept, since another instance
+// could be waiting for it.
+func (l *Lock) Release() error {
+	if l == nil || l.f == nil {
+		return nil
+	}
+	err := l.writePID(0)
+	if unlockErr := unlock(l.f); err == nil {
+		err = unlockErr
+	}
+	if closeErr := l.f.Close(); err == nil {
+		err = closeErr
+	}
+	l.f = nil
+	return err
+}
+
+// writePID replaces the content of the lock file by the PID, or empties it if it's 0.
+func (l *Lock) writePID(pid int) error {
+	if err := l.f.Truncate(0); err != nil {
+		return err
+	}
+	if pid == 0 {
+		return nil
+	}
+	_, err := l.f.WriteAt([]byte(strconv.Itoa(pid)+"\n"), 0)
+	return err
+}
+
+// readPID returns the PID kept in the lock file, or 0 if it's empty or not valid.
+func readPID(f *os.File) int {
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil || pid < 0 {
+		return 0
+	}
+	return pid
+}
@@ -0,0 +1,144 @@
+package runlock_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/runlock"
+)
+
+func TestTryAcquire(t *testing.T) {
+	dir, err := ioutil.TempDir("", "runlock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, runlock.DefaultFilename)
+
+	l, err := runlock.TryAcquire(path)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if got := readPID(t, path); got != os.Getpid() {
+		t.Errorf("want: %d, got: %d", os.Getpid(), got)
+	}
+
+	// the lock is held, like if another instance was running.
+	_, err = runlock.TryAcquire(path)
+	var lockedErr *runlock.LockedError
+	if !errors.Is(err, runlock.ErrLocked) || !errors.As(err, &lockedErr) {
+		t.Fatalf("want: %v, got: %v", runlock.ErrLocked, err)
+	}
+	if lockedErr.PID != os.Getpid() {
+		t.Errorf("want: %d, got: %d", os.Getpid(), lockedErr.PID)
+	}
+
+	if err := l.Release(); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if got := readPID(t, path); got != 0 {
+		t.Errorf("want: no PID after release, got: %d", got)
+	}
+
+	l, err = runlock.TryAcquire(path)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if l.StalePID != 0 {
+		t.Errorf("want: no stale lock, got: %d", l.StalePID)
+	}
+	_ = l.Release()
+}
+
+func TestTryAcquire_StaleLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "runlock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, runlock.DefaultFilename)
+
+	// a crashed instance keeps its PID in the lock file, but not the lock.
+	const crashedPID = 999999
+	if err := ioutil.WriteFile(path, []byte(strconv.Itoa(crashedPID)+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := runlock.TryAcquire(path)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	defer l.Release()
+	if l.StalePID != crashedPID {
+		t.Errorf("want: %d, got: %d", crashedPID, l.StalePID)
+	}
+	if got := readPID(t, path); got != os.Getpid() {
+		t.Errorf("want: %d, got: %d", os.Getpid(), got)
+	}
+}
+
+func TestAcquire(t *testing.T) {
+	dir, err := ioutil.TempDir("", "runlock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, runlock.DefaultFilename)
+
+	t.Run("ShouldWaitUntilTheLockIsReleased", func(t *testing.T) {
+		held, err := runlock.TryAcquire(path)
+		if err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			_ = held.Release()
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		l, err := runlock.Acquire(ctx, path, 10*time.Millisecond)
+		if err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+		_ = l.Release()
+	})
+
+	t.Run("ShouldFailIfTheContextIsDone", func(t *testing.T) {
+		held, err := runlock.TryAcquire(path)
+		if err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+		defer held.Release()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if _, err := runlock.Acquire(ctx, path, 10*time.Millisecond); !errors.Is(err, runlock.ErrLocked) {
+			t.Errorf("want: %v, got: %v", runlock.ErrLocked, err)
+		}
+	})
+}
+
+// readPID returns the PID in the lock file, or 0 if it's empty.
+func readPID(t *testing.T, path string) int {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	s := strings.TrimSpace(string(b))
+	if s == "" {
+		return 0
+	}
+	pid, err := strconv.Atoi(s)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	return pid
+}
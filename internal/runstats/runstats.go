@@ -0,0 +1,100 @@
+// Package runstats counts the results of a run: the files scanned, uploaded, skipped and failed.
+package runstats
+
+import (
+	"sync"
+	"time"
+)
+
+// Snapshot are the totals of a run at a given moment.
+type Snapshot struct {
+	Scanned       int
+	Uploaded      int
+	Skipped       int
+	Failed        int
+	BytesUploaded int64
+	Duration      time.Duration
+}
+
+// RunStats counts the results of a run since it was created.
+// It's safe for concurrent use, and Snapshot returns totals consistent between them.
+type RunStats struct {
+	mu       sync.Mutex
+	counters Snapshot
+	start    time.Time
+	// albums are the number of files uploaded by the name of their album.
+	albums map[string]int
+
+	// now returns the current time.
+	// Useful for testing.
+	now func() time.Time
+}
+
+// New returns a RunStats of a run starting now.
+func New() *RunStats {
+	return &RunStats{start: time.Now(), now: time.Now}
+}
+
+// AddScanned counts files found when scanning folders, skipped ones included.
+func (s *RunStats) AddScanned(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counters.Scanned += n
+}
+
+// AddSkipped counts files not uploaded, without failing.
+func (s *RunStats) AddSkipped(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counters.Skipped += n
+}
+
+// AddUploaded counts an uploaded file, with its size.
+func (s *RunStats) AddUploaded(bytes int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counters.Uploaded++
+	s.counters.BytesUploaded += bytes
+}
+
+// AddUploadedToAlbum counts an uploaded file, with its size, like AddUploaded, and the album it has been added to.
+func (s *RunStats) AddUploadedToAlbum(album string, bytes int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counters.Uploaded++
+	s.counters.BytesUploaded += bytes
+	if album == "" {
+		return
+	}
+	if s.albums == nil {
+		s.albums = make(map[string]int)
+	}
+	s.albums[album]++
+}
+
+// Albums returns the number of files uploaded to every album. Files not added to any album are not included.
+func (s *RunStats) Albums() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	albums := make(map[string]int, len(s.albums))
+	for album, n := range s.albums {
+		albums[album] = n
+	}
+	return albums
+}
+
+// AddFailed counts a failure.
+func (s *RunStats) AddFailed() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counters.Failed++
+}
+
+// Snapshot returns a copy of the totals, and the time since the run started.
+func (s *RunStats) Snapshot() Snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshot := s.counters
+	snapshot.Duration = s.now().Sub(s.start)
+	return snapshot
+}
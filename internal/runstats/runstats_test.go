@@ -0,0 +1,75 @@
+package runstats
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRunStats_Concurrent(t *testing.T) {
+	const goroutines = 50
+	const increments = 1000
+
+	s := New()
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < increments; j++ {
+				s.AddScanned(2)
+				s.AddSkipped(1)
+				s.AddUploaded(10)
+				s.AddFailed()
+				// snapshots taken while counting are consistent, every upload has its bytes.
+				if snapshot := s.Snapshot(); snapshot.BytesUploaded != int64(snapshot.Uploaded)*10 {
+					t.Errorf("inconsistent snapshot: %+v", snapshot)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	got := s.Snapshot()
+	want := Snapshot{
+		Scanned:       2 * goroutines * increments,
+		Uploaded:      goroutines * increments,
+		Skipped:       goroutines * increments,
+		Failed:        goroutines * increments,
+		BytesUploaded: 10 * goroutines * increments,
+	}
+	got.Duration = 0
+	if got != want {
+		t.Errorf("want: %+v, got: %+v", want, got)
+	}
+}
+
+func TestRunStats_Albums(t *testing.T) {
+	s := New()
+	s.AddUploadedToAlbum("Trips", 10)
+	s.AddUploadedToAlbum("Trips", 20)
+	s.AddUploadedToAlbum("Family", 5)
+	s.AddUploadedToAlbum("", 5)
+
+	if got := s.Snapshot(); got.Uploaded != 4 || got.BytesUploaded != 40 {
+		t.Errorf("want: 4 uploads of 40 bytes, got: %+v", got)
+	}
+	got := s.Albums()
+	if len(got) != 2 || got["Trips"] != 2 || got["Family"] != 1 {
+		t.Errorf("want: map[Family:1 Trips:2], got: %v", got)
+	}
+	// the returned albums are a copy.
+	got["Trips"] = 0
+	if s.Albums()["Trips"] != 2 {
+		t.Errorf("want: %d, got: %d", 2, s.Albums()["Trips"])
+	}
+}
+
+func TestRunStats_Duration(t *testing.T) {
+	start := time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC)
+	s := &RunStats{start: start, now: func() time.Time { return start.Add(90 * time.Second) }}
+
+	if got := s.Snapshot().Duration; got != 90*time.Second {
+		t.Errorf("want: %s, got: %s", 90*time.Second, got)
+	}
+}
@@ -0,0 +1,209 @@
+package task
+
+import (
+	"context"
+	"sync"
+
+	"github.com/gphotosuploader/google-photos-api-client-go/v2/albums"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+)
+
+// AlbumLister represents the service to list all the albums where media items could be added.
+type AlbumLister interface {
+	List(ctx context.Context) ([]albums.Album, error)
+}
+
+// AlbumCache returns the ID of albums by its title, creating them if they don't exist.
+// It keeps the albums already created (or existent), reducing a lot the calls to Google Photos API.
+// Failures are not kept, so an album that could not be created is requested again by the next file.
+// It's safe for concurrent use: albums with different titles are requested concurrently, but an album is
+// requested once, the concurrent requests of the same title wait for it and get the same result.
+type AlbumCache struct {
+	service AlbumsService
+	logger  log.Logger
+
+	// Metadata, if it's set, adds the descriptions to the albums when they are created.
+	Metadata AlbumMetadataService
+
+	// Lister, if it's set, lists all the albums once, when the first album is requested, so existent albums are
+	// found by title without any other request, and the missing ones are created without searching them.
+	// Albums are searched one by one using the service if they could not be listed.
+	Lister AlbumLister
+
+	mu           sync.Mutex
+	ids          map[string]string
+	descriptions map[string]string
+	// pending are the albums being requested by title.
+	pending map[string]*albumRequest
+
+	// listMu is held while the albums are listed, so they are listed once.
+	listMu sync.Mutex
+	// index are the IDs of the listed albums by title, and listed, if they have been listed already.
+	index  map[string]string
+	listed bool
+}
+
+// albumRequest is the request of an album in progress, its result is set once done is closed.
+type albumRequest struct {
+	done chan struct{}
+	id   string
+	err  error
+}
+
+// NewAlbumCache returns an empty AlbumCache using the service to get and create albums.
+func NewAlbumCache(service AlbumsService, logger log.Logger) *AlbumCache {
+	return &AlbumCache{
+		service:      service,
+		logger:       logger,
+		ids:          make(map[string]string),
+		descriptions: make(map[string]string),
+		pending:      make(map[string]*albumRequest),
+	}
+}
+
+// SetDescription sets the description added to the album when it's created by GetOrCreate.
+// It's not added to existent albums, since the Library API only allows to change albums created by this tool.
+func (c *AlbumCache) SetDescription(title string, description string) {
+	if description == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.descriptions[title] = description
+}
+
+// GetOrCreate returns the ID of the album, creating it if it doesn't exist.
+// An empty title returns an empty ID, without calling the service.
+func (c *AlbumCache) GetOrCreate(ctx context.Context, title string) (string, error) {
+	if title == "" {
+		return "", nil
+	}
+
+	c.mu.Lock()
+	if id, exist := c.ids[title]; exist {
+		c.mu.Unlock()
+		return id, nil
+	}
+	if req, inProgress := c.pending[title]; inProgress {
+		c.mu.Unlock()
+		select {
+		case <-req.done:
+			return req.id, req.err
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+	req := &albumRequest{done: make(chan struct{})}
+	c.pending[title] = req
+	c.mu.Unlock()
+
+	id, created, err := c.getOrCreate(ctx, title)
+	if err != nil {
+		c.logger.WithFields(log.Fields{"event": log.EventError, "album": title, "reason": log.ReasonAlbumFailed, "error": err}).Failf("Unable to create album '%s': %s", title, err)
+	} else {
+		c.describe(ctx, title, id, created)
+	}
+
+	// the ID is kept before the album is not pending anymore, so it's requested once.
+	c.mu.Lock()
+	if err == nil {
+		c.ids[title] = id
+	}
+	delete(c.pending, title)
+	c.mu.Unlock()
+	req.id, req.err = id, err
+	close(req.done)
+	return id, err
+}
+
+// describe adds the description of the album, if it's set and the album has been created.
+// Failures are logged as warnings, the album is used anyway.
+func (c *AlbumCache) describe(ctx context.Context, title string, id string, created bool) {
+	c.mu.Lock()
+	description, ok := c.descriptions[title]
+	c.mu.Unlock()
+	if !ok || c.Metadata == nil {
+		return
+	}
+	if !created {
+		c.logger.Warnf("Description of album '%s' has not been set, it's only set when the album is created.", title)
+		return
+	}
+	if err := c.Metadata.AddDescription(ctx, id, description); err != nil {
+		c.logger.Warnf("Unable to set the description of album '%s': %s", title, err)
+	}
+}
+
+// getOrCreate returns the ID of the album, found in the listed albums, if they could be listed, or searched using
+// the service otherwise, and if it has been created.
+func (c *AlbumCache) getOrCreate(ctx context.Context, title string) (string, bool, error) {
+	if !c.list(ctx) {
+		return getOrCreateAlbum(ctx, c.service, title)
+	}
+	c.mu.Lock()
+	id, exist := c.index[title]
+	c.mu.Unlock()
+	if exist {
+		return id, false, nil
+	}
+	album, err := c.service.Create(ctx, title)
+	if err != nil {
+		return "", false, err
+	}
+	c.mu.Lock()
+	c.index[title] = album.ID
+	c.mu.Unlock()
+	return album.ID, true, nil
+}
+
+// list builds the index of the albums using the Lister, the first time it's called. It returns true if the
+// index could be built. Albums with the same title are indexed by the first one listed, warning about it.
+func (c *AlbumCache) list(ctx context.Context) bool {
+	if c.Lister == nil {
+		return false
+	}
+	c.listMu.Lock()
+	defer c.listMu.Unlock()
+	if c.listed {
+		return c.index != nil
+	}
+	c.listed = true
+
+	listed, err := c.Lister.List(ctx)
+	if err != nil {
+		c.logger.Warnf("Unable to list the albums, they are searched one by one: %s", err)
+		return false
+	}
+	index := make(map[string]string, len(listed))
+	duplicates := make(map[string]int)
+	for _, album := range listed {
+		if _, exist := index[album.Title]; exist {
+			duplicates[album.Title]++
+			continue
+		}
+		index[album.Title] = album.ID
+	}
+	for title, n := range duplicates {
+		c.logger.Warnf("Found %d albums titled '%s', files are added to the first one listed: %s", n+1, title, index[title])
+	}
+	c.logger.Debugf("Listed %d albums", len(listed))
+	c.mu.Lock()
+	c.index = index
+	c.mu.Unlock()
+	return true
+}
+
+// getOrCreateAlbum returns the created (or existent) album in PhotosService, and if it has been created.
+func getOrCreateAlbum(ctx context.Context, service AlbumsService, title string) (string, bool, error) {
+	if album, err := service.GetByTitle(ctx, title); err == nil {
+		return album.ID, false, nil
+	}
+
+	album, err := service.Create(ctx, title)
+	if err != nil {
+		return "", false, err
+	}
+
+	return album.ID, true, nil
+}
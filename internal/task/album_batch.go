@@ -0,0 +1,195 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/metrics"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/upload"
+)
+
+// MaxAlbumBatchSize is the maximum number of media items added to an album in one request, as allowed by the API.
+const MaxAlbumBatchSize = 50
+
+// AlbumBatch adds media items to albums in batches of up to BatchSize items, instead of one request per media item.
+// Media items are sent once their album has a full batch, or when Flush is called, e.g. at the end of the run.
+// The API adds all the media items of a batch or none of them, so a failed batch is split and its halves are
+// sent again, until the media items that could not be added are found. If the Service is an
+// AlbumItemsResultsService, the result of every media item is used instead.
+// Media items in the album already are added indeed, see upload.IsAlreadyInAlbum. The failures of media items
+// whose batch has been added partially are PartialBatchError.
+// It's safe for concurrent use.
+type AlbumBatch struct {
+	Service AlbumItemsService
+
+	// BatchSize is the maximum number of media items of a batch. Uses MaxAlbumBatchSize by default.
+	BatchSize int
+
+	mu sync.Mutex
+	// pending are the media items not sent yet, by their album ID.
+	pending map[string][]batchItem
+}
+
+// AlbumItemsResultsService represents the service to add media items already uploaded to albums, reporting the
+// result of every media item, instead of failing the whole request.
+type AlbumItemsResultsService interface {
+	AlbumItemsService
+	// AddMediaItemsResults returns the error of every media item of mediaItemIds, in the same order, nil if it
+	// has been added. err is returned if the request has failed, even if some of them could have been added.
+	AddMediaItemsResults(ctx context.Context, albumId string, mediaItemIds []string) (results []error, err error)
+}
+
+// PartialBatchError is the failure of a media item that could not be added to the album, while other media items
+// of its batch have been added.
+type PartialBatchError struct {
+	Err error
+}
+
+func (e *PartialBatchError) Error() string {
+	return e.Err.Error() + " (other media items of the batch have been added)"
+}
+
+func (e *PartialBatchError) Unwrap() error {
+	return e.Err
+}
+
+// IsPartialBatch returns true if err is the failure of a media item whose batch has been added partially.
+func IsPartialBatch(err error) bool {
+	var partial *PartialBatchError
+	return errors.As(err, &partial)
+}
+
+// batchItem is a media item to be added to an album, done is called once it has been added, or it has failed.
+type batchItem struct {
+	mediaItemID string
+	done        func(err error)
+}
+
+// NewAlbumBatch returns the batches of media items added to albums with the service.
+func NewAlbumBatch(service AlbumItemsService) *AlbumBatch {
+	return &AlbumBatch{
+		Service: service,
+		pending: make(map[string][]batchItem),
+	}
+}
+
+// Add enqueues the media item to be added to the album, sending the batch of the album if it's full.
+// done is called once the media item has been added, or it has failed.
+func (b *AlbumBatch) Add(ctx context.Context, albumID string, mediaItemID string, done func(err error)) {
+	b.mu.Lock()
+	b.pending[albumID] = append(b.pending[albumID], batchItem{mediaItemID: mediaItemID, done: done})
+	var full []batchItem
+	if len(b.pending[albumID]) >= b.batchSize() {
+		full = b.pending[albumID]
+		delete(b.pending, albumID)
+	}
+	b.mu.Unlock()
+
+	if full != nil {
+		b.send(ctx, albumID, full)
+	}
+}
+
+// Flush sends the media items not sent yet, of all the albums.
+func (b *AlbumBatch) Flush(ctx context.Context) {
+	b.mu.Lock()
+	pending := b.pending
+	b.pending = make(map[string][]batchItem)
+	b.mu.Unlock()
+
+	for albumID, items := range pending {
+		for len(items) > 0 {
+			n := b.batchSize()
+			if n > len(items) {
+				n = len(items)
+			}
+			b.send(ctx, albumID, items[:n])
+			items = items[n:]
+		}
+	}
+}
+
+// send adds the media items to the album, calling done with the result of every one of them.
+func (b *AlbumBatch) send(ctx context.Context, albumID string, items []batchItem) {
+	ids := make([]string, len(items))
+	for i, item := range items {
+		ids[i] = item.mediaItemID
+	}
+	results := b.add(ctx, albumID, ids)
+	added := false
+	for _, err := range results {
+		if err == nil {
+			added = true
+			break
+		}
+	}
+	for i, item := range items {
+		err := results[i]
+		if err != nil && added {
+			err = &PartialBatchError{Err: err}
+		}
+		item.done(err)
+	}
+}
+
+// add adds the media items to the album, returning the error of every one of them, nil if it has been added.
+// If the request fails, the halves of the batch are added again, unless the failure doesn't depend on the
+// media items, e.g. the quota has been exceeded.
+func (b *AlbumBatch) add(ctx context.Context, albumID string, ids []string) []error {
+	results := make([]error, len(ids))
+	var err error
+	if service, ok := b.Service.(AlbumItemsResultsService); ok {
+		var itemResults []error
+		itemResults, err = service.AddMediaItemsResults(ctx, albumID, ids)
+		if err == nil && len(itemResults) == len(ids) {
+			for i, itemErr := range itemResults {
+				if itemErr != nil && !upload.IsAlreadyInAlbum(itemErr) {
+					metrics.UploadErrors.Inc(errorStatus(itemErr))
+					results[i] = upload.ClassifyError(itemErr)
+				}
+			}
+			return results
+		}
+		if err == nil {
+			err = fmt.Errorf("unexpected number of results, want: %d, got: %d", len(ids), len(itemResults))
+		}
+	} else {
+		err = b.Service.AddMediaItems(ctx, albumID, ids)
+	}
+	// only some of the media items of the batch could be in the album, so the others are sent again.
+	if err == nil || (len(ids) == 1 && upload.IsAlreadyInAlbum(err)) {
+		return results
+	}
+
+	metrics.UploadErrors.Inc(errorStatus(err))
+	err = upload.ClassifyError(err)
+	if len(ids) > 1 && isItemFailure(ctx, err) {
+		half := len(ids) / 2
+		copy(results, b.add(ctx, albumID, ids[:half]))
+		copy(results[half:], b.add(ctx, albumID, ids[half:]))
+		return results
+	}
+	for i := range results {
+		results[i] = err
+	}
+	return results
+}
+
+// batchSize returns the maximum number of media items of a batch.
+func (b *AlbumBatch) batchSize() int {
+	if b.BatchSize <= 0 || b.BatchSize > MaxAlbumBatchSize {
+		return MaxAlbumBatchSize
+	}
+	return b.BatchSize
+}
+
+// isItemFailure returns true if the batch could have failed because of some of its media items, so its
+// halves could be added.
+func isItemFailure(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	return !errors.Is(err, upload.ErrQuotaExceeded) && !errors.Is(err, upload.ErrUnauthorized)
+}
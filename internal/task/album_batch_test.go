@@ -0,0 +1,194 @@
+package task_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"google.golang.org/api/googleapi"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/mock"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/task"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/upload"
+)
+
+func TestAlbumBatch_Flush(t *testing.T) {
+	var mu sync.Mutex
+	var sizes []int
+	batch := task.NewAlbumBatch(&mock.AlbumItemsService{
+		AddMediaItemsFn: func(ctx context.Context, albumId string, mediaItemIds []string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			sizes = append(sizes, len(mediaItemIds))
+			return nil
+		},
+	})
+
+	var added int
+	var wg sync.WaitGroup
+	for i := 0; i < 120; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			batch.Add(context.Background(), "album-id", fmt.Sprintf("media-%d", i), func(err error) {
+				if err != nil {
+					t.Errorf("error was not expected at this point: %s", err)
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				added++
+			})
+		}(i)
+	}
+	wg.Wait()
+	batch.Flush(context.Background())
+
+	if want := []int{50, 50, 20}; fmt.Sprint(want) != fmt.Sprint(sizes) {
+		t.Errorf("want: %v, got: %v", want, sizes)
+	}
+	if added != 120 {
+		t.Errorf("want: %d, got: %d", 120, added)
+	}
+}
+
+func TestAlbumBatch_RetriesFailedItems(t *testing.T) {
+	testCases := []struct {
+		name         string
+		err          error
+		wantFailed   []string
+		wantAttempts int
+	}{
+		{"Should retry the items of the failed batch", errors.New("invalid media item"), []string{"media-3"}, 5},
+		{"Should not retry if the quota is exceeded", upload.ErrQuotaExceeded, []string{"media-0", "media-1", "media-2", "media-3"}, 1},
+		{"Should add the items already in the album", &googleapi.Error{Code: http.StatusConflict, Message: "Media item already in album"}, nil, 5},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			attempts := 0
+			batch := task.NewAlbumBatch(&mock.AlbumItemsService{
+				AddMediaItemsFn: func(ctx context.Context, albumId string, mediaItemIds []string) error {
+					attempts++
+					for _, id := range mediaItemIds {
+						if id == "media-3" {
+							return tc.err
+						}
+					}
+					return nil
+				},
+			})
+			batch.BatchSize = 4
+
+			var failed []string
+			for i := 0; i < 4; i++ {
+				id := fmt.Sprintf("media-%d", i)
+				batch.Add(context.Background(), "album-id", id, func(err error) {
+					if err != nil {
+						failed = append(failed, id)
+					}
+				})
+			}
+
+			if fmt.Sprint(tc.wantFailed) != fmt.Sprint(failed) {
+				t.Errorf("want: %v, got: %v", tc.wantFailed, failed)
+			}
+			if tc.wantAttempts != attempts {
+				t.Errorf("want: %d, got: %d", tc.wantAttempts, attempts)
+			}
+		})
+	}
+}
+
+func TestAlbumBatch_ItemResults(t *testing.T) {
+	attempts := 0
+	batch := task.NewAlbumBatch(&mock.AlbumItemsResultsService{
+		AddMediaItemsResultsFn: func(ctx context.Context, albumId string, mediaItemIds []string) ([]error, error) {
+			attempts++
+			results := make([]error, len(mediaItemIds))
+			for i, id := range mediaItemIds {
+				switch id {
+				case "media-1":
+					results[i] = errors.New("invalid media item")
+				case "media-2":
+					results[i] = &googleapi.Error{Code: http.StatusConflict, Message: "Media item already in album"}
+				}
+			}
+			return results, nil
+		},
+	})
+	batch.BatchSize = 4
+
+	var added, failed []string
+	for i := 0; i < 4; i++ {
+		id := fmt.Sprintf("media-%d", i)
+		batch.Add(context.Background(), "album-id", id, func(err error) {
+			if err != nil {
+				if !task.IsPartialBatch(err) {
+					t.Errorf("partial batch error was expected, got: %s", err)
+				}
+				failed = append(failed, id)
+				return
+			}
+			added = append(added, id)
+		})
+	}
+
+	if want := []string{"media-0", "media-2", "media-3"}; fmt.Sprint(want) != fmt.Sprint(added) {
+		t.Errorf("want: %v, got: %v", want, added)
+	}
+	if want := []string{"media-1"}; fmt.Sprint(want) != fmt.Sprint(failed) {
+		t.Errorf("want: %v, got: %v", want, failed)
+	}
+	// the media items are not sent again, since their results are known.
+	if attempts != 1 {
+		t.Errorf("want: %d, got: %d", 1, attempts)
+	}
+}
+
+func TestAlbumBatch_PartialBatchError(t *testing.T) {
+	testCases := []struct {
+		name        string
+		err         error
+		wantPartial bool
+	}{
+		{"Should report partial batch if other items have been added", errors.New("invalid media item"), true},
+		{"Should not report partial batch if no item has been added", upload.ErrQuotaExceeded, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			batch := task.NewAlbumBatch(&mock.AlbumItemsService{
+				AddMediaItemsFn: func(ctx context.Context, albumId string, mediaItemIds []string) error {
+					for _, id := range mediaItemIds {
+						if id == "media-1" {
+							return tc.err
+						}
+					}
+					return nil
+				},
+			})
+			batch.BatchSize = 2
+
+			var got error
+			for i := 0; i < 2; i++ {
+				batch.Add(context.Background(), "album-id", fmt.Sprintf("media-%d", i), func(err error) {
+					if err != nil {
+						got = err
+					}
+				})
+			}
+			if got == nil {
+				t.Fatalf("error was expected, but not produced")
+			}
+			if tc.wantPartial != task.IsPartialBatch(got) {
+				t.Errorf("want: %t, got: %t", tc.wantPartial, task.IsPartialBatch(got))
+			}
+			if !errors.Is(got, tc.err) {
+				t.Errorf("want: %v, got: %v", tc.err, got)
+			}
+		})
+	}
+}
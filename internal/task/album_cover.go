@@ -0,0 +1,123 @@
+package task
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+)
+
+// Cover photo strategies, choosing among the files uploaded to the album.
+const (
+	CoverPhotoFirst  = "first"
+	CoverPhotoLatest = "latest"
+)
+
+// AlbumMetadataService represents the service to update the albums created by this tool.
+type AlbumMetadataService interface {
+	SetCoverPhoto(ctx context.Context, albumID string, mediaItemID string) error
+	AddDescription(ctx context.Context, albumID string, text string) error
+}
+
+// CoverPhoto is how the cover photo of an album is chosen: the file at Path, if it's set,
+// or the one chosen by Strategy, CoverPhotoFirst or CoverPhotoLatest by modification time.
+type CoverPhoto struct {
+	Path     string
+	Strategy string
+}
+
+// coverCandidate is the file chosen as cover photo of an album.
+type coverCandidate struct {
+	albumID     string
+	path        string
+	mediaItemID string
+	modTime     time.Time
+	applied     bool
+}
+
+// AlbumCovers sets the cover photo of albums, choosing it among the files uploaded to them in this run.
+// It's safe for concurrent use.
+type AlbumCovers struct {
+	service AlbumMetadataService
+	logger  log.Logger
+
+	mu         sync.Mutex
+	covers     map[string]CoverPhoto
+	candidates map[string]*coverCandidate
+}
+
+// NewAlbumCovers returns an AlbumCovers using the service to set the cover photos.
+func NewAlbumCovers(service AlbumMetadataService, logger log.Logger) *AlbumCovers {
+	return &AlbumCovers{
+		service:    service,
+		logger:     logger,
+		covers:     make(map[string]CoverPhoto),
+		candidates: make(map[string]*coverCandidate),
+	}
+}
+
+// Set sets how the cover photo of the album is chosen.
+func (c *AlbumCovers) Set(title string, cover CoverPhoto) {
+	if cover.Path == "" && cover.Strategy == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.covers[title] = cover
+}
+
+// Uploaded records a file uploaded to the album, which could be chosen as its cover photo.
+func (c *AlbumCovers) Uploaded(title string, albumID string, path string, mediaItemID string, modTime time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cover, ok := c.covers[title]
+	if !ok || albumID == "" {
+		return
+	}
+	current := c.candidates[title]
+	switch {
+	case cover.Path != "":
+		if filepath.Clean(path) != filepath.Clean(cover.Path) {
+			return
+		}
+	case cover.Strategy == CoverPhotoFirst:
+		// once it has been set, the first photo doesn't change.
+		if current != nil && (current.applied || !modTime.Before(current.modTime)) {
+			return
+		}
+	case cover.Strategy == CoverPhotoLatest:
+		if current != nil && !modTime.After(current.modTime) {
+			return
+		}
+	}
+	c.candidates[title] = &coverCandidate{albumID: albumID, path: path, mediaItemID: mediaItemID, modTime: modTime}
+}
+
+// Apply sets the cover photo of the albums whose cover has changed since the last call.
+// Cover photos could only be set on albums created by this tool, failures are logged as warnings.
+func (c *AlbumCovers) Apply(ctx context.Context) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for title, cover := range c.covers {
+		candidate, ok := c.candidates[title]
+		if !ok {
+			if cover.Path != "" {
+				c.logger.Debugf("Cover photo of album '%s' has not been set, '%s' has not been uploaded to it in this run", title, cover.Path)
+			}
+			continue
+		}
+		if candidate.applied {
+			continue
+		}
+		if err := c.service.SetCoverPhoto(ctx, candidate.albumID, candidate.mediaItemID); err != nil {
+			c.logger.Warnf("Unable to set the cover photo of album '%s', it could only be set on albums created by this tool: %s", title, err)
+			continue
+		}
+		candidate.applied = true
+		c.logger.Debugf("Cover photo of album '%s' has been set to '%s'", title, candidate.path)
+	}
+}
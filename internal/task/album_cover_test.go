@@ -0,0 +1,154 @@
+package task_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gphotosuploader/google-photos-api-client-go/v2/media_items"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/mock"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/task"
+)
+
+// newMockedAlbumMetadataService returns an AlbumMetadataService keeping the cover photos set by album ID.
+func newMockedAlbumMetadataService(covers map[string]string) *mock.AlbumMetadataService {
+	return &mock.AlbumMetadataService{
+		SetCoverPhotoFn: func(ctx context.Context, albumID string, mediaItemID string) error {
+			if albumID == "not-created-by-app" {
+				return errors.New("request must be made to an album created by the app")
+			}
+			covers[albumID] = mediaItemID
+			return nil
+		},
+	}
+}
+
+func TestAlbumCovers_Apply(t *testing.T) {
+	base := time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC)
+	uploads := []struct {
+		path        string
+		mediaItemID string
+		modTime     time.Time
+	}{
+		{"/photos/IMG_0002.jpg", "media-2", base.Add(time.Hour)},
+		{"/photos/IMG_0001.jpg", "media-1", base},
+		{"/photos/IMG_0003.jpg", "media-3", base.Add(2 * time.Hour)},
+	}
+
+	var testCases = []struct {
+		name  string
+		cover task.CoverPhoto
+		want  string
+	}{
+		{"Should set the first photo", task.CoverPhoto{Strategy: task.CoverPhotoFirst}, "media-1"},
+		{"Should set the latest photo", task.CoverPhoto{Strategy: task.CoverPhotoLatest}, "media-3"},
+		{"Should set the photo at path", task.CoverPhoto{Path: "/photos/IMG_0002.jpg"}, "media-2"},
+		{"Should not set a photo not uploaded", task.CoverPhoto{Path: "/photos/IMG_0004.jpg"}, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			covers := make(map[string]string)
+			c := task.NewAlbumCovers(newMockedAlbumMetadataService(covers), log.Discard)
+			c.Set("Trips", tc.cover)
+			for _, u := range uploads {
+				c.Uploaded("Trips", "trips-id", u.path, u.mediaItemID, u.modTime)
+				// files uploaded to other albums are not candidates.
+				c.Uploaded("Other", "other-id", u.path+".other", "other-"+u.mediaItemID, u.modTime)
+			}
+			c.Apply(context.Background())
+
+			if got := covers["trips-id"]; got != tc.want {
+				t.Errorf("want: %s, got: %s", tc.want, got)
+			}
+			if _, set := covers["other-id"]; set {
+				t.Errorf("cover photo of album without cover settings was not expected to be set")
+			}
+		})
+	}
+}
+
+func TestAlbumCovers_ApplyOnce(t *testing.T) {
+	base := time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC)
+	calls := 0
+	service := &mock.AlbumMetadataService{
+		SetCoverPhotoFn: func(ctx context.Context, albumID string, mediaItemID string) error {
+			calls++
+			return nil
+		},
+	}
+	c := task.NewAlbumCovers(service, log.Discard)
+	c.Set("Trips", task.CoverPhoto{Strategy: task.CoverPhotoFirst})
+
+	c.Uploaded("Trips", "trips-id", "/photos/IMG_0002.jpg", "media-2", base)
+	c.Apply(context.Background())
+	// once it has been set, the first photo doesn't change, even if an older one is uploaded later.
+	c.Uploaded("Trips", "trips-id", "/photos/IMG_0001.jpg", "media-1", base.Add(-time.Hour))
+	c.Apply(context.Background())
+
+	if calls != 1 {
+		t.Errorf("want: 1 call, got: %d", calls)
+	}
+}
+
+func TestAlbumCovers_ApplyNotCreatedByApp(t *testing.T) {
+	covers := make(map[string]string)
+	logger := &mock.Logger{}
+	c := task.NewAlbumCovers(newMockedAlbumMetadataService(covers), logger)
+	c.Set("Existent", task.CoverPhoto{Strategy: task.CoverPhotoLatest})
+	c.Uploaded("Existent", "not-created-by-app", "/photos/IMG_0001.jpg", "media-1", time.Now())
+
+	c.Apply(context.Background())
+
+	if len(covers) != 0 {
+		t.Errorf("want: no cover photos, got: %v", covers)
+	}
+	if !logger.WarnfInvoked {
+		t.Errorf("a warning was expected, but not logged")
+	}
+}
+
+func TestEnqueuedUpload_ProcessRecordsCoverPhoto(t *testing.T) {
+	dir, err := ioutil.TempDir("", "covers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "IMG_0001.jpg")
+	if err := ioutil.WriteFile(path, []byte("photo"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	covers := make(map[string]string)
+	c := task.NewAlbumCovers(newMockedAlbumMetadataService(covers), log.Discard)
+	c.Set("Trips", task.CoverPhoto{Path: path})
+
+	job := &task.EnqueuedUpload{
+		Context: context.Background(),
+		Uploads: &mock.UploadsService{
+			UploadFileToAlbumFn: func(ctx context.Context, albumId string, filePath string) (media_items.MediaItem, error) {
+				return media_items.MediaItem{ID: "uploaded-media-item"}, nil
+			},
+		},
+		FileTracker: &mock.FileTracker{PutFn: func(path string, mediaItemID string) error { return nil }},
+		Logger:      log.Discard,
+		Path:        path,
+		AlbumID:     "trips-id",
+		AlbumName:   "Trips",
+		Covers:      c,
+	}
+	if err := job.Process(); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	c.Apply(context.Background())
+
+	if want := "uploaded-media-item"; covers["trips-id"] != want {
+		t.Errorf("want: %s, got: %s", want, covers["trips-id"])
+	}
+}
@@ -0,0 +1,399 @@
+package task_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gphotosuploader/google-photos-api-client-go/v2/albums"
+	"github.com/gphotosuploader/google-photos-api-client-go/v2/media_items"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/filter"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/library"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/mock"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/task"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/upload"
+)
+
+// newMockedAlbumsService returns an AlbumsService with the given existent albums.
+// Created albums use its title as ID, and creations are counted by title.
+func newMockedAlbumsService(existent map[string]string, created map[string]int) *mock.AlbumsService {
+	return &mock.AlbumsService{
+		GetByTitleFn: func(ctx context.Context, title string) (*albums.Album, error) {
+			if id, ok := existent[title]; ok {
+				return &albums.Album{ID: id, Title: title}, nil
+			}
+			return nil, errors.New("album not found")
+		},
+		CreateFn: func(ctx context.Context, title string) (*albums.Album, error) {
+			created[title]++
+			if title == "should-make-create-fail" {
+				return nil, errors.New("error")
+			}
+			return &albums.Album{ID: title, Title: title}, nil
+		},
+	}
+}
+
+func TestAlbumCache_GetOrCreate(t *testing.T) {
+	testCases := []struct {
+		name          string
+		title         string
+		want          string
+		wantCreated   int
+		isErrExpected bool
+	}{
+		{"Should return empty ID if title is empty", "", "", 0, false},
+		{"Should return existent album", "existent", "existent-id", 0, false},
+		{"Should create album once", "new", "new", 1, false},
+		{"Should try to create album again if it fails", "should-make-create-fail", "", 3, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			created := make(map[string]int)
+			service := newMockedAlbumsService(map[string]string{"existent": "existent-id"}, created)
+			cache := task.NewAlbumCache(service, log.Discard)
+
+			for i := 0; i < 3; i++ {
+				got, err := cache.GetOrCreate(context.Background(), tc.title)
+				if tc.isErrExpected && err == nil {
+					t.Fatalf("error was expected, but not produced")
+				}
+				if !tc.isErrExpected && err != nil {
+					t.Fatalf("error was not expected, err: %s", err)
+				}
+				if got != tc.want {
+					t.Errorf("want: %s, got: %s", tc.want, got)
+				}
+			}
+			if created[tc.title] != tc.wantCreated {
+				t.Errorf("want: %d creations, got: %d", tc.wantCreated, created[tc.title])
+			}
+		})
+	}
+}
+
+func TestAlbumCache_GetOrCreateListedAlbums(t *testing.T) {
+	var pages int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pages++
+		if r.URL.Query().Get("pageToken") == "" {
+			_, _ = w.Write([]byte(`{"albums":[{"id":"trips-id","title":"Trips"},{"id":"family-id","title":"Family"}],"nextPageToken":"page-2"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"albums":[{"id":"birthdays-id","title":"Birthdays"},{"id":"other-trips-id","title":"Trips"}]}`))
+	}))
+	defer srv.Close()
+	lister := library.NewAlbumsService(srv.Client())
+	lister.Endpoint = srv.URL + "/v1/albums"
+
+	created := make(map[string]int)
+	service := newMockedAlbumsService(nil, created)
+	service.GetByTitleFn = func(ctx context.Context, title string) (*albums.Album, error) {
+		t.Errorf("want: listed albums, got: search of album '%s'", title)
+		return nil, errors.New("album not found")
+	}
+	logger := &mock.Logger{}
+	cache := task.NewAlbumCache(service, logger)
+	cache.Lister = lister
+
+	testCases := []struct {
+		title string
+		want  string
+	}{
+		{"Birthdays", "birthdays-id"},
+		{"Trips", "trips-id"},
+		{"New", "New"},
+	}
+	for _, tc := range testCases {
+		got, err := cache.GetOrCreate(context.Background(), tc.title)
+		if err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+		if got != tc.want {
+			t.Errorf("want: %s, got: %s", tc.want, got)
+		}
+	}
+
+	if pages != 2 {
+		t.Errorf("want: %d pages listed, got: %d", 2, pages)
+	}
+	if want := map[string]int{"New": 1}; len(created) != 1 || created["New"] != 1 {
+		t.Errorf("want: %v, got: %v", want, created)
+	}
+	// "Trips" is listed twice.
+	if !logger.WarnfInvoked {
+		t.Errorf("want: warning about duplicated titles, got: none")
+	}
+}
+
+func TestAlbumCache_GetOrCreateListFailed(t *testing.T) {
+	created := make(map[string]int)
+	cache := task.NewAlbumCache(newMockedAlbumsService(map[string]string{"existent": "existent-id"}, created), log.Discard)
+	cache.Lister = &mock.AlbumLister{
+		ListFn: func(ctx context.Context) ([]albums.Album, error) {
+			return nil, errors.New("list failed")
+		},
+	}
+
+	got, err := cache.GetOrCreate(context.Background(), "existent")
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if got != "existent-id" {
+		t.Errorf("want: %s, got: %s", "existent-id", got)
+	}
+	if len(created) != 0 {
+		t.Errorf("want: no albums created, got: %v", created)
+	}
+}
+
+func TestAlbumCache_GetOrCreateConcurrently(t *testing.T) {
+	testCases := []struct {
+		name   string
+		lister task.AlbumLister
+	}{
+		{"Should create album once searching it", nil},
+		{"Should create album once listing albums", &mock.AlbumLister{
+			ListFn: func(ctx context.Context) ([]albums.Album, error) {
+				return []albums.Album{{ID: "trips-id", Title: "Trips"}}, nil
+			},
+		}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var mu sync.Mutex
+			created := make(map[string]int)
+			service := &mock.AlbumsService{
+				GetByTitleFn: func(ctx context.Context, title string) (*albums.Album, error) {
+					return nil, errors.New("album not found")
+				},
+				CreateFn: func(ctx context.Context, title string) (*albums.Album, error) {
+					// the creation takes time, so the other requests are made while it's in progress.
+					time.Sleep(20 * time.Millisecond)
+					mu.Lock()
+					defer mu.Unlock()
+					created[title]++
+					return &albums.Album{ID: title + "-id", Title: title}, nil
+				},
+			}
+			cache := task.NewAlbumCache(service, log.Discard)
+			if tc.lister != nil {
+				cache.Lister = tc.lister
+			}
+
+			var wg sync.WaitGroup
+			for i := 0; i < 50; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					got, err := cache.GetOrCreate(context.Background(), "New")
+					if err != nil {
+						t.Errorf("error was not expected at this point: %s", err)
+					}
+					if got != "New-id" {
+						t.Errorf("want: %s, got: %s", "New-id", got)
+					}
+				}()
+			}
+			wg.Wait()
+
+			if want := map[string]int{"New": 1}; len(created) != 1 || created["New"] != 1 {
+				t.Errorf("want: %v, got: %v", want, created)
+			}
+		})
+	}
+}
+
+func TestAlbumCache_GetOrCreateAfterFailure(t *testing.T) {
+	var creations int
+	service := &mock.AlbumsService{
+		GetByTitleFn: func(ctx context.Context, title string) (*albums.Album, error) {
+			return nil, errors.New("album not found")
+		},
+		CreateFn: func(ctx context.Context, title string) (*albums.Album, error) {
+			creations++
+			if creations == 1 {
+				return nil, errors.New("transient error")
+			}
+			return &albums.Album{ID: title + "-id", Title: title}, nil
+		},
+	}
+	cache := task.NewAlbumCache(service, log.Discard)
+
+	if _, err := cache.GetOrCreate(context.Background(), "New"); err == nil {
+		t.Fatalf("error was expected, but not produced")
+	}
+	for i := 0; i < 2; i++ {
+		got, err := cache.GetOrCreate(context.Background(), "New")
+		if err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+		if got != "New-id" {
+			t.Errorf("want: %s, got: %s", "New-id", got)
+		}
+	}
+	if creations != 2 {
+		t.Errorf("want: %d creations, got: %d", 2, creations)
+	}
+}
+
+func TestAlbumCache_SetDescription(t *testing.T) {
+	testCases := []struct {
+		name        string
+		title       string
+		description string
+		want        map[string]string
+		wantWarning bool
+	}{
+		{"Should add description to created album", "new", "Summer holidays", map[string]string{"new": "Summer holidays"}, false},
+		{"Should not add description to existent album", "existent", "Summer holidays", map[string]string{}, true},
+		{"Should not add empty description", "new", "", map[string]string{}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := make(map[string]string)
+			logger := &mock.Logger{}
+			cache := task.NewAlbumCache(newMockedAlbumsService(map[string]string{"existent": "existent-id"}, make(map[string]int)), logger)
+			cache.Metadata = &mock.AlbumMetadataService{
+				AddDescriptionFn: func(ctx context.Context, albumID string, text string) error {
+					got[albumID] = text
+					return nil
+				},
+			}
+			cache.SetDescription(tc.title, tc.description)
+
+			// the description is added once, since albums are cached.
+			for i := 0; i < 3; i++ {
+				if _, err := cache.GetOrCreate(context.Background(), tc.title); err != nil {
+					t.Fatalf("error was not expected, err: %s", err)
+				}
+			}
+
+			if len(got) != len(tc.want) || got[tc.title] != tc.want[tc.title] {
+				t.Errorf("want: %v, got: %v", tc.want, got)
+			}
+			if logger.WarnfInvoked != tc.wantWarning {
+				t.Errorf("want warning: %t, got: %t", tc.wantWarning, logger.WarnfInvoked)
+			}
+		})
+	}
+}
+
+func TestAlbumCache_UploadFolderToAlbums(t *testing.T) {
+	dir, err := ioutil.TempDir("", "albums")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, f := range []string{"Trips/2023-Italy/a.jpg", "Trips/2023-Italy/b.jpg", "Work/2023-Italy/c.jpg", "Trips/2022-Spain/d.jpg"} {
+		writeFile(t, filepath.Join(dir, f), f)
+	}
+
+	testCases := []struct {
+		name         string
+		createAlbums string
+		separator    string
+		want         map[string]string
+	}{
+		{
+			name:         "Should use the folder name",
+			createAlbums: "folderName",
+			want: map[string]string{
+				"Trips/2023-Italy/a.jpg": "2023-Italy",
+				"Trips/2023-Italy/b.jpg": "2023-Italy",
+				"Work/2023-Italy/c.jpg":  "2023-Italy",
+				"Trips/2022-Spain/d.jpg": "2022-Spain",
+			},
+		},
+		{
+			name:         "Should use the folder path with separator",
+			createAlbums: "folderPath",
+			separator:    " / ",
+			want: map[string]string{
+				"Trips/2023-Italy/a.jpg": "Trips / 2023-Italy",
+				"Trips/2023-Italy/b.jpg": "Trips / 2023-Italy",
+				"Work/2023-Italy/c.jpg":  "Work / 2023-Italy",
+				"Trips/2022-Spain/d.jpg": "Trips / 2022-Spain",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			created := make(map[string]int)
+			cache := task.NewAlbumCache(newMockedAlbumsService(nil, created), log.Discard)
+
+			got := make(map[string]string)
+			uploads := &mock.UploadsService{
+				UploadFileToAlbumFn: func(ctx context.Context, albumId string, filePath string) (media_items.MediaItem, error) {
+					got[upload.RelativePath(dir, filePath)] = albumId
+					return media_items.MediaItem{}, nil
+				},
+			}
+			ft := &mock.FileTracker{
+				ExistFn: func(path string) bool { return false },
+				PutFn:   func(path string, mediaItemID string) error { return nil },
+			}
+
+			job := upload.UploadFolderJob{
+				FileTracker:        ft,
+				SourceFolder:       dir,
+				CreateAlbums:       tc.createAlbums,
+				AlbumPathSeparator: tc.separator,
+				Filter:             filter.MustCompile([]string{"_ALL_FILES_"}, nil),
+			}
+			_, err := job.WalkFolder(log.Discard, func(item upload.FileItem) {
+				albumID, err := cache.GetOrCreate(context.Background(), item.AlbumName)
+				if err != nil {
+					t.Fatalf("error was not expected, err: %s", err)
+				}
+				u := &task.EnqueuedUpload{
+					Context:     context.Background(),
+					Uploads:     uploads,
+					FileTracker: ft,
+					Logger:      log.Discard,
+					Path:        item.Path,
+					AlbumID:     albumID,
+				}
+				if err := u.Process(); err != nil {
+					t.Fatalf("error was not expected, err: %s", err)
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for file, album := range tc.want {
+				if got[file] != album {
+					t.Errorf("want: %s, got: %s, file: %s", album, got[file], file)
+				}
+				if created[album] != 1 {
+					t.Errorf("want: %d creations, got: %d, album: %s", 1, created[album], album)
+				}
+			}
+			if len(created) != len(uniqueValues(tc.want)) {
+				t.Errorf("want: %d albums, got: %v", len(uniqueValues(tc.want)), created)
+			}
+		})
+	}
+}
+
+func uniqueValues(m map[string]string) map[string]bool {
+	result := make(map[string]bool)
+	for _, v := range m {
+		result[v] = true
+	}
+	return result
+}
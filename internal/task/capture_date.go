@@ -0,0 +1,41 @@
+package task
+
+import "time"
+
+// CaptureDater represents a way to derive the date when a photo was taken, e.g. from its name or EXIF metadata.
+type CaptureDater interface {
+	CaptureDate(path string) (time.Time, bool)
+}
+
+// CaptureDateDescriptions adds the date when the photos were taken to their description, so it's shown by Google
+// Photos even for files without EXIF metadata, like scans or exported frames.
+// The Google Photos API doesn't allow to set the creation time of media items, it's read from the metadata of
+// the uploaded file, or it's the upload time. So the description doesn't change the order of the timeline.
+type CaptureDateDescriptions struct {
+	// Descriptions, if it's set, reads the description of the photo, e.g. from its sidecar. The date is added to it.
+	Descriptions DescriptionReader
+	Dates        CaptureDater
+
+	// Layout is the Go layout of the date, e.g. "Taken on 2006-01-02 15:04".
+	Layout string
+}
+
+// Description returns the description of the photo, followed by the date when it was taken, in its own line.
+// It returns the description as is if the date could not be derived.
+func (d CaptureDateDescriptions) Description(path string) (string, error) {
+	var description string
+	if d.Descriptions != nil {
+		var err error
+		if description, err = d.Descriptions.Description(path); err != nil {
+			return "", err
+		}
+	}
+	date, ok := d.Dates.CaptureDate(path)
+	if !ok {
+		return description, nil
+	}
+	if description == "" {
+		return date.Format(d.Layout), nil
+	}
+	return description + "\n" + date.Format(d.Layout), nil
+}
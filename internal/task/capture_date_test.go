@@ -0,0 +1,114 @@
+package task_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gphotosuploader/google-photos-api-client-go/v2/media_items"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/mock"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/task"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/upload"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/xmp"
+)
+
+// captureDates returns the dates by file path.
+type captureDates map[string]time.Time
+
+func (d captureDates) CaptureDate(path string) (time.Time, bool) {
+	date, ok := d[path]
+	return date, ok
+}
+
+// descriptions returns the descriptions by file path, failing for the ones not found.
+type descriptions map[string]string
+
+func (d descriptions) Description(path string) (string, error) {
+	description, ok := d[path]
+	if !ok {
+		return "", errors.New("unreadable sidecar")
+	}
+	return description, nil
+}
+
+func TestCaptureDateDescriptions_Description(t *testing.T) {
+	d := task.CaptureDateDescriptions{
+		Descriptions: descriptions{"described.jpg": "At the beach", "scan.jpg": "", "undated.jpg": "Undated"},
+		Dates: captureDates{
+			"described.jpg": time.Date(2023, 7, 15, 14, 22, 33, 0, time.Local),
+			"scan.jpg":      time.Date(1998, 12, 24, 20, 0, 0, 0, time.Local),
+		},
+		Layout: "Taken on 2006-01-02 15:04",
+	}
+
+	testCases := []struct {
+		name   string
+		path   string
+		want   string
+		errExp bool
+	}{
+		{"Should add the date to the description", "described.jpg", "At the beach\nTaken on 2023-07-15 14:22", false},
+		{"Should use the date if there is no description", "scan.jpg", "Taken on 1998-12-24 20:00", false},
+		{"Should keep the description if there is no date", "undated.jpg", "Undated", false},
+		{"Should fail if the description could not be read", "unreadable.jpg", "", true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := d.Description(tc.path)
+			if tc.errExp && err == nil {
+				t.Fatalf("error was expected, but not produced")
+			}
+			if !tc.errExp && err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			if tc.want != got {
+				t.Errorf("want: %q, got: %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestEnqueuedUpload_ProcessSetsCaptureDateDescription(t *testing.T) {
+	dir, err := ioutil.TempDir("", "capture-date")
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "20230715_142233.jpg")
+	writeFile(t, path, "photo")
+
+	filenameDate, err := upload.NewFilenameDate("", "20060102_150405")
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	folder := &upload.UploadFolderJob{FilenameDate: filenameDate}
+
+	got := ""
+	job := &task.EnqueuedUpload{
+		Context: context.Background(),
+		Uploads: &mock.UploadsService{
+			UploadFileToAlbumWithDescriptionFn: func(ctx context.Context, albumId string, filePath string, description string) (media_items.MediaItem, error) {
+				got = description
+				return media_items.MediaItem{ID: "media-1"}, nil
+			},
+		},
+		FileTracker:  &mock.FileTracker{PutFn: func(path string, mediaItemID string) error { return nil }},
+		Descriptions: task.CaptureDateDescriptions{Descriptions: xmp.Reader{}, Dates: folder, Layout: "2006-01-02 15:04:05"},
+		Logger:       log.Discard,
+
+		Path: path,
+	}
+
+	if err := job.Process(); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if want := "2023-07-15 14:22:33"; want != got {
+		t.Errorf("want: %q, got: %q", want, got)
+	}
+}
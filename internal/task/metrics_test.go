@@ -0,0 +1,108 @@
+package task_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gphotosuploader/google-photos-api-client-go/v2/media_items"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/metrics"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/mock"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/task"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/upload"
+)
+
+func TestEnqueuedUpload_ProcessUpdatesMetrics(t *testing.T) {
+	dir, err := ioutil.TempDir("", "upload-metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "photo.jpg")
+	writeFile(t, path, "content")
+
+	srv := httptest.NewServer(metrics.Default.Handler())
+	defer srv.Close()
+
+	const (
+		uploadedMetric = "gphotos_files_uploaded_total"
+		bytesMetric    = "gphotos_bytes_uploaded_total"
+		durationMetric = "gphotos_upload_duration_seconds_count"
+		errorsMetric   = `gphotos_upload_errors_total{status="503"}`
+	)
+	before := scrape(t, srv.URL)
+
+	newJob := func(err error) *task.EnqueuedUpload {
+		return &task.EnqueuedUpload{
+			Context: context.Background(),
+			Uploads: &mock.UploadsService{
+				UploadFileToAlbumFn: func(ctx context.Context, albumId string, filePath string) (media_items.MediaItem, error) {
+					return media_items.MediaItem{}, err
+				},
+			},
+			FileTracker: &mock.FileTracker{
+				PutFn: func(path string, mediaItemID string) error {
+					return nil
+				},
+			},
+			Logger: log.Discard,
+			Path:   path,
+		}
+	}
+
+	if err := newJob(nil).Process(); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if err := newJob(&upload.StatusError{StatusCode: 503, Status: "503 Service Unavailable"}).Process(); err == nil {
+		t.Fatalf("error was expected at this point")
+	}
+
+	after := scrape(t, srv.URL)
+	want := map[string]float64{
+		uploadedMetric: 1,
+		bytesMetric:    float64(len("content")),
+		durationMetric: 1,
+		errorsMetric:   1,
+	}
+	for name, delta := range want {
+		if got := after[name] - before[name]; got != delta {
+			t.Errorf("want: %v, got: %v, metric: %s", delta, got, name)
+		}
+	}
+}
+
+// scrape returns the samples, by name and labels, exposed at url.
+func scrape(t *testing.T, url string) map[string]float64 {
+	res, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	defer res.Body.Close()
+
+	samples := make(map[string]float64)
+	s := bufio.NewScanner(res.Body)
+	for s.Scan() {
+		line := s.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndex(line, " ")
+		v, err := strconv.ParseFloat(line[i+1:], 64)
+		if err != nil {
+			t.Fatal(fmt.Errorf("invalid sample '%s': %s", line, err))
+		}
+		samples[line[:i]] = v
+	}
+	return samples
+}
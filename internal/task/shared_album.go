@@ -0,0 +1,142 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"google.golang.org/api/googleapi"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/library"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+)
+
+var (
+	// ErrSharedAlbumNotFound is returned when the album is not shared with the account.
+	ErrSharedAlbumNotFound = errors.New("shared album not found")
+
+	// ErrSharedAlbumNotWritable is returned when the account is not allowed to add media items to the shared album.
+	ErrSharedAlbumNotWritable = errors.New("shared album is not writable by the account")
+)
+
+// SharedAlbumsService represents the service to get and join the albums shared with the account.
+type SharedAlbumsService interface {
+	Get(ctx context.Context, shareToken string) (library.SharedAlbum, error)
+	Join(ctx context.Context, shareToken string) (library.SharedAlbum, error)
+	List(ctx context.Context) ([]library.SharedAlbum, error)
+}
+
+// SharedAlbumRef identifies an album shared with the account, by its share token, or by its title if the token
+// is not set.
+type SharedAlbumRef struct {
+	ShareToken string
+	Title      string
+}
+
+// String returns a human readable description of the album.
+func (r SharedAlbumRef) String() string {
+	if r.ShareToken != "" {
+		return fmt.Sprintf("with share token '%s'", r.ShareToken)
+	}
+	return fmt.Sprintf("'%s'", r.Title)
+}
+
+// SharedAlbums resolves the albums shared with the account where media items are added. Shared albums are found
+// apart from the albums of the account, see AlbumCache, since they could be owned by other users and media items
+// could only be added to them if the owner allows it.
+// Albums are resolved once, failures are kept too. It's safe for concurrent use.
+type SharedAlbums struct {
+	service SharedAlbumsService
+	logger  log.Logger
+
+	// DryRun, if it's set, doesn't join the albums the account has not joined yet.
+	DryRun bool
+
+	mu       sync.Mutex
+	resolved map[SharedAlbumRef]sharedAlbumResult
+}
+
+type sharedAlbumResult struct {
+	album library.SharedAlbum
+	err   error
+}
+
+// NewSharedAlbums returns the SharedAlbums using the service to get and join them.
+func NewSharedAlbums(service SharedAlbumsService, logger log.Logger) *SharedAlbums {
+	return &SharedAlbums{
+		service:  service,
+		logger:   logger,
+		resolved: make(map[SharedAlbumRef]sharedAlbumResult),
+	}
+}
+
+// Resolve returns the shared album, joining it if the account has not joined it yet. It returns an error wrapping
+// ErrSharedAlbumNotFound if the album is not shared with the account, or ErrSharedAlbumNotWritable if the account
+// is not allowed to add media items to it.
+func (s *SharedAlbums) Resolve(ctx context.Context, ref SharedAlbumRef) (library.SharedAlbum, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r, exist := s.resolved[ref]; exist {
+		return r.album, r.err
+	}
+
+	album, err := s.resolve(ctx, ref)
+	if err == nil && !album.IsWriteable && (album.IsJoined || !s.DryRun) {
+		err = fmt.Errorf("%w: '%s', its owner must allow collaborators to add photos", ErrSharedAlbumNotWritable, album.Title)
+	}
+	// albums are requested again if the request has been interrupted.
+	if ctx.Err() != nil {
+		return album, err
+	}
+	s.resolved[ref] = sharedAlbumResult{album: album, err: err}
+	return album, err
+}
+
+// resolve returns the shared album by its share token, or by its title among the albums shared with the account.
+func (s *SharedAlbums) resolve(ctx context.Context, ref SharedAlbumRef) (library.SharedAlbum, error) {
+	if ref.ShareToken == "" {
+		return s.findByTitle(ctx, ref.Title)
+	}
+	album, err := s.service.Get(ctx, ref.ShareToken)
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+		return album, fmt.Errorf("%w: %s", ErrSharedAlbumNotFound, ref)
+	}
+	if err != nil || album.IsJoined || album.IsOwned {
+		return album, err
+	}
+	if s.DryRun {
+		s.logger.Infof("[DRY RUN] Shared album '%s' would be joined.", album.Title)
+		return album, nil
+	}
+	album, err = s.service.Join(ctx, ref.ShareToken)
+	if err != nil {
+		return album, fmt.Errorf("unable to join shared album %s: %w", ref, err)
+	}
+	s.logger.Infof("Joined shared album '%s'.", album.Title)
+	return album, nil
+}
+
+// findByTitle returns the album shared with the account with the title. Albums with the same title are found by the
+// first one listed, warning about it.
+func (s *SharedAlbums) findByTitle(ctx context.Context, title string) (library.SharedAlbum, error) {
+	listed, err := s.service.List(ctx)
+	if err != nil {
+		return library.SharedAlbum{}, err
+	}
+	var found []library.SharedAlbum
+	for _, album := range listed {
+		if album.Title == title {
+			found = append(found, album)
+		}
+	}
+	if len(found) == 0 {
+		return library.SharedAlbum{}, fmt.Errorf("%w: '%s'", ErrSharedAlbumNotFound, title)
+	}
+	if len(found) > 1 {
+		s.logger.Warnf("Found %d shared albums titled '%s', files are added to the first one listed: %s", len(found), title, found[0].ID)
+	}
+	return found[0], nil
+}
@@ -0,0 +1,107 @@
+package task_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"google.golang.org/api/googleapi"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/library"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/mock"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/task"
+)
+
+// newMockedSharedAlbumsService returns a SharedAlbumsService with a writable album, "Family", and a read-only one,
+// "Friends", both joined, and a writable album not joined yet, "Cousins". Requests are counted by method.
+func newMockedSharedAlbumsService(requests map[string]int) *mock.SharedAlbumsService {
+	shared := map[string]library.SharedAlbum{
+		"family-token":  {ID: "family-id", Title: "Family", IsWriteable: true, ShareToken: "family-token", IsJoined: true},
+		"friends-token": {ID: "friends-id", Title: "Friends", ShareToken: "friends-token", IsJoined: true},
+		"cousins-token": {ID: "cousins-id", Title: "Cousins", ShareToken: "cousins-token"},
+	}
+	return &mock.SharedAlbumsService{
+		GetFn: func(ctx context.Context, shareToken string) (library.SharedAlbum, error) {
+			requests["get"]++
+			album, ok := shared[shareToken]
+			if !ok {
+				return library.SharedAlbum{}, &googleapi.Error{Code: http.StatusNotFound}
+			}
+			return album, nil
+		},
+		JoinFn: func(ctx context.Context, shareToken string) (library.SharedAlbum, error) {
+			requests["join"]++
+			album := shared[shareToken]
+			album.IsJoined, album.IsWriteable = true, true
+			return album, nil
+		},
+		ListFn: func(ctx context.Context) ([]library.SharedAlbum, error) {
+			requests["list"]++
+			return []library.SharedAlbum{shared["family-token"], shared["friends-token"]}, nil
+		},
+	}
+}
+
+func TestSharedAlbums_Resolve(t *testing.T) {
+	testCases := []struct {
+		name      string
+		ref       task.SharedAlbumRef
+		want      string
+		wantJoins int
+		wantErr   error
+	}{
+		{"Should resolve writable album by share token", task.SharedAlbumRef{ShareToken: "family-token"}, "family-id", 0, nil},
+		{"Should resolve writable album by title", task.SharedAlbumRef{Title: "Family"}, "family-id", 0, nil},
+		{"Should join album not joined yet", task.SharedAlbumRef{ShareToken: "cousins-token"}, "cousins-id", 1, nil},
+		{"Should fail if album is read-only by share token", task.SharedAlbumRef{ShareToken: "friends-token"}, "friends-id", 0, task.ErrSharedAlbumNotWritable},
+		{"Should fail if album is read-only by title", task.SharedAlbumRef{Title: "Friends"}, "friends-id", 0, task.ErrSharedAlbumNotWritable},
+		{"Should fail if share token is unknown", task.SharedAlbumRef{ShareToken: "unknown-token"}, "", 0, task.ErrSharedAlbumNotFound},
+		{"Should fail if title is not shared", task.SharedAlbumRef{Title: "Trips"}, "", 0, task.ErrSharedAlbumNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requests := make(map[string]int)
+			shared := task.NewSharedAlbums(newMockedSharedAlbumsService(requests), log.Discard)
+
+			for i := 0; i < 3; i++ {
+				got, err := shared.Resolve(context.Background(), tc.ref)
+				if tc.wantErr != nil && !errors.Is(err, tc.wantErr) {
+					t.Fatalf("want: %v, got: %v", tc.wantErr, err)
+				}
+				if tc.wantErr == nil && err != nil {
+					t.Fatalf("error was not expected at this point: %s", err)
+				}
+				if got.ID != tc.want {
+					t.Errorf("want: %s, got: %s", tc.want, got.ID)
+				}
+			}
+			if requests["join"] != tc.wantJoins {
+				t.Errorf("want: %d joins, got: %d", tc.wantJoins, requests["join"])
+			}
+			// albums are resolved once.
+			if requests["get"]+requests["list"] != 1 {
+				t.Errorf("want: 1 request, got: %v", requests)
+			}
+		})
+	}
+}
+
+func TestSharedAlbums_ResolveDryRun(t *testing.T) {
+	requests := make(map[string]int)
+	shared := task.NewSharedAlbums(newMockedSharedAlbumsService(requests), log.Discard)
+	shared.DryRun = true
+
+	got, err := shared.Resolve(context.Background(), task.SharedAlbumRef{ShareToken: "cousins-token"})
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if want := "cousins-id"; got.ID != want {
+		t.Errorf("want: %s, got: %s", want, got.ID)
+	}
+	if requests["join"] != 0 {
+		t.Errorf("want: 0 joins, got: %d", requests["join"])
+	}
+}
@@ -2,11 +2,24 @@ package task
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
 
 	"github.com/gphotosuploader/google-photos-api-client-go/v2/albums"
 	"github.com/gphotosuploader/google-photos-api-client-go/v2/media_items"
+	"google.golang.org/api/googleapi"
 
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/convert"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/hook"
 	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/metrics"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/report"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/runstats"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/tempdir"
 	"github.com/gphotosuploader/gphotos-uploader-cli/internal/upload"
 )
 
@@ -19,6 +32,60 @@ type UploadsService interface {
 	UploadFileToAlbum(ctx context.Context, albumId string, filePath string) (media_items.MediaItem, error)
 }
 
+// DescribedUploadsService represents the service to upload files to albums, creating their media items with a description.
+type DescribedUploadsService interface {
+	UploadFileToAlbumWithDescription(ctx context.Context, albumId string, filePath string, description string) (media_items.MediaItem, error)
+}
+
+// DescriptionReader represents a way to read the description of a file, e.g. from its XMP sidecar file.
+type DescriptionReader interface {
+	Description(path string) (string, error)
+}
+
+// AlbumItemsService represents the service to add media items already uploaded to albums.
+type AlbumItemsService interface {
+	AddMediaItems(ctx context.Context, albumId string, mediaItemIds []string) error
+}
+
+// LibraryMatcher represents a way to find the media item of the library matching a file, e.g. uploaded by other means.
+type LibraryMatcher interface {
+	Match(ctx context.Context, path string) (mediaItemID string, found bool, err error)
+}
+
+// Trasher represents a way to move files to the trash, so they could be restored.
+type Trasher interface {
+	Trash(path string) error
+}
+
+// UploadHook represents the user logic run after every upload.
+type UploadHook interface {
+	Run(ctx context.Context, u hook.Upload) error
+}
+
+// UploadReporter represents a record of the processed files, e.g. a report of the run.
+type UploadReporter interface {
+	Report(r UploadResult)
+}
+
+// UploadResult is what has been done with a processed file.
+type UploadResult struct {
+	Path        string
+	AlbumName   string
+	MediaItemID string
+	// Bytes is the size of the file when it was processed.
+	Bytes int64
+	// Status is one of the report.Status* values.
+	Status string
+	// Reason, if it's set, is why the file has been skipped, one of the log.Reason* codes.
+	Reason string
+	// Err is the error of the failed files.
+	Err error
+}
+
+// ErrUploadTimeout is returned when every attempt to upload the content of the file has exceeded the
+// PerFileTimeout of the job.
+var ErrUploadTimeout = errors.New("upload timed out")
+
 type EnqueuedUpload struct {
 	Context     context.Context
 	Uploads     UploadsService
@@ -27,26 +94,279 @@ type EnqueuedUpload struct {
 
 	Path            string
 	AlbumID         string
+	AlbumName       string
 	DeleteOnSuccess bool
+
+	// MediaItemID, if it's set, is the media item of the file, that has been uploaded already. It's only added
+	// to the album using AlbumItems, instead of uploading its content again.
+	MediaItemID string
+	AlbumItems  AlbumItemsService
+
+	// AlbumBatch, if it's set, adds the file to the album in a batch with other files, instead of using AlbumItems
+	// for it alone. Process returns once the file is enqueued, and it's tracked, moved or removed once the batch has
+	// been added. Failures are logged, and counted in Stats.
+	AlbumBatch *AlbumBatch
+	// OnAttachFailed, if it's set, is called with the failure of the file added to the album by AlbumBatch, once
+	// its batch has been sent, e.g. to attempt it again. The failure is a PartialBatchError if other files of the
+	// batch have been added.
+	OnAttachFailed func(path string, err error)
+
+	// Favorite, if it's true, records the file as favorite in the FileTracker, if it's a FavoriteTracker, once
+	// it has been uploaded. The Google Photos API doesn't allow to mark it as favorite.
+	Favorite bool
+
+	// Trash, if it's set, moves the file to the trash after being uploaded, instead of removing it.
+	Trash Trasher
+
+	// MoveToDir, if it's set, is the folder where to move the file after being uploaded.
+	// The file keeps its path relative to SourceFolder.
+	MoveToDir    string
+	SourceFolder string
+
+	// Converter, if it's set, converts HEIC files to JPEG before uploading them.
+	// The original file is the one tracked, moved or removed.
+	Converter convert.Converter
+	// TempDir is the folder of the intermediate files, like the converted photos. Uses the default folder for
+	// temporary files if it's empty.
+	TempDir string
+	// SpaceGuard, if it's set, checks the free space of the volume of TempDir before converting the file. The
+	// file is not converted, nor uploaded, if it's below the minimum.
+	SpaceGuard *tempdir.SpaceGuard
+
+	// Covers, if it's set, records the uploaded file as a candidate to be the cover photo of the album.
+	Covers *AlbumCovers
+
+	// Stats, if it's set, counts the uploaded file, with its size.
+	Stats *runstats.RunStats
+
+	// PerFileTimeout, if it's set, is the maximum duration of an attempt to upload the content of the file. The
+	// attempt is cancelled once it's reached, e.g. because the connection hangs, and the file is attempted again
+	// with a new timeout, up to TimeoutRetries times. The other files are not affected.
+	PerFileTimeout time.Duration
+	TimeoutRetries int
+
+	// OnUpload, if it's set, is run once the file has been uploaded and tracked, before moving or removing it.
+	// It's not run for files only added to the album.
+	OnUpload UploadHook
+
+	// OnUploadFatal, if it's true, fails the upload when OnUpload fails, and the file is neither moved nor removed.
+	// Otherwise, the failure is only logged.
+	OnUploadFatal bool
+
+	// Library, if it's set, searches the library for a media item matching the file before uploading it. Matched
+	// files are tracked with the media item, instead of being uploaded. Matches are heuristic, so they are neither
+	// added to the album, nor moved or removed.
+	Library LibraryMatcher
+
+	// Descriptions, if it's set, reads the description of the file, e.g. from its sidecar, that is set to its media
+	// item if Uploads is a DescribedUploadsService. Files without description are uploaded as usual.
+	Descriptions DescriptionReader
+
+	// DryRun if it is true, the file is neither uploaded nor tracked, it's only logged.
+	DryRun bool
+
+	// Reporter, if it's set, records the result of the file once it has been processed. Files interrupted before
+	// being processed, and the ones of dry-run mode, are not recorded.
+	Reporter UploadReporter
+	// size is the size of the file, read before processing it for the Reporter.
+	size int64
 }
 
 func (job *EnqueuedUpload) Process() error {
-	item := upload.NewFileItem(job.Path)
+	if job.DryRun {
+		if job.MediaItemID != "" {
+			job.Logger.Infof("Would add '%s' to album '%s'", job.Path, job.AlbumName)
+			return nil
+		}
+		job.Logger.Infof("Would upload '%s' to album '%s'", job.Path, job.AlbumName)
+		return nil
+	}
 
-	// Upload the file and add it to PhotosService.
-	if err := job.addMediaToAlbum(job.AlbumID, item); err != nil {
+	// The upload is not started if the run has been cancelled.
+	if err := job.Context.Err(); err != nil {
 		return err
 	}
 
+	item := upload.NewFileItem(job.Path)
+	if job.Reporter != nil {
+		// the size is read before the file could be moved or removed.
+		job.size = item.Size()
+	}
+
+	if job.MediaItemID == "" && job.Library != nil {
+		if matched, err := job.skipIfInLibrary(); matched || err != nil {
+			return err
+		}
+	}
+
+	if job.MediaItemID != "" && job.AlbumBatch != nil {
+		job.AlbumBatch.Add(job.Context, job.AlbumID, job.MediaItemID, func(err error) {
+			job.attached(item, err)
+		})
+		return nil
+	}
+
+	mediaItemID, err := job.createOrAttach(item)
+	if err == nil {
+		err = job.finish(item, mediaItemID)
+	}
+	job.report(mediaItemID, err)
+	return err
+}
+
+// report records the result of the processed file in the Reporter, if it's set. mediaItemID is the media item of
+// the file, and err the error if it has failed. Files interrupted by the cancellation of the run are not recorded.
+func (job *EnqueuedUpload) report(mediaItemID string, err error) {
+	if job.Reporter == nil || (err != nil && job.Context.Err() != nil) {
+		return
+	}
+	r := UploadResult{Path: job.Path, AlbumName: job.AlbumName, MediaItemID: mediaItemID, Bytes: job.size, Status: report.StatusUploaded}
+	if job.MediaItemID != "" {
+		r.Status = report.StatusAttached
+	}
+	if err != nil {
+		r.Status, r.Err = report.StatusFailed, err
+	}
+	job.Reporter.Report(r)
+}
+
+// attached finishes the file added to the album by AlbumBatch, logging the failure if it could not be added.
+func (job *EnqueuedUpload) attached(item upload.FileItem, err error) {
+	if err != nil {
+		if job.Stats != nil {
+			job.Stats.AddFailed()
+		}
+		reason := log.ReasonAttachFailed
+		if IsPartialBatch(err) {
+			reason = log.ReasonPartialBatch
+		}
+		job.Logger.WithFields(log.Fields{
+			"event":  log.EventError,
+			"path":   job.Path,
+			"album":  job.AlbumName,
+			"reason": reason,
+			"error":  err,
+		}).Failf("Unable to add already uploaded '%s' to album '%s': %s", job.Path, job.AlbumName, err)
+		job.report(job.MediaItemID, err)
+		if job.OnAttachFailed != nil {
+			job.OnAttachFailed(job.Path, err)
+		}
+		return
+	}
+	job.logAttached()
+	if err := job.finish(item, job.MediaItemID); err != nil {
+		job.Logger.Warnf("Unable to finish processing '%s': %s", job.Path, err)
+	}
+	job.report(job.MediaItemID, nil)
+}
+
+// finish records the file with the media item once it has been added to the album, and moves or removes it.
+func (job *EnqueuedUpload) finish(item upload.FileItem, mediaItemID string) error {
+	if job.Covers != nil {
+		job.Covers.Uploaded(job.AlbumName, job.AlbumID, job.Path, mediaItemID, modTime(job.Path))
+	}
+
 	// Mark the file as uploaded in the FileTracker.
-	if err := job.FileTracker.Put(job.Path); err != nil {
+	if err := job.track(mediaItemID); err != nil {
 		job.Logger.Warnf("Tracking file as uploaded failed: file=%s, error=%v", job.Path, err)
+		// The file is kept in place, because it would be uploaded again if it's moved.
+		if job.MoveToDir != "" {
+			job.Logger.Warnf("File was not moved because it's not tracked as uploaded: file=%s", job.Path)
+			return nil
+		}
+	}
+
+	if job.MediaItemID == "" {
+		if err := job.runOnUpload(mediaItemID); err != nil {
+			return err
+		}
+	}
+
+	// If was requested, move the file after being uploaded.
+	if job.MoveToDir != "" {
+		return job.move(item)
+	}
+
+	// If was requested, move the file to the trash after being uploaded.
+	if job.Trash != nil {
+		return job.moveToTrash()
 	}
 
 	// If was requested, remove the file after being uploaded.
 	return job.removeIfItWasRequested(item)
 }
 
+// skipIfInLibrary tracks the file, without uploading it, if the library has a media item matching it. It returns
+// true if the file has been skipped. Files are uploaded if the library could not be searched.
+func (job *EnqueuedUpload) skipIfInLibrary() (bool, error) {
+	mediaItemID, found, err := job.Library.Match(job.Context, job.Path)
+	if err != nil {
+		if ctxErr := job.Context.Err(); ctxErr != nil {
+			return false, ctxErr
+		}
+		job.Logger.Warnf("Unable to search the library for '%s', uploading it: %s", job.Path, err)
+		return false, nil
+	}
+	if !found {
+		return false, nil
+	}
+
+	if err := job.FileTracker.Put(job.Path, mediaItemID); err != nil {
+		job.Logger.Warnf("Tracking file as uploaded failed: file=%s, error=%v", job.Path, err)
+	}
+	if job.Stats != nil {
+		job.Stats.AddSkipped(1)
+	}
+	job.Logger.WithFields(log.Fields{
+		"event":         log.EventFileSkipped,
+		"path":          job.Path,
+		"reason":        log.ReasonInLibrary,
+		"media_item_id": mediaItemID,
+	}).Infof("Skipping '%s', it's already in the library as media item '%s'", job.Path, mediaItemID)
+	if job.Reporter != nil {
+		job.Reporter.Report(UploadResult{Path: job.Path, AlbumName: job.AlbumName, MediaItemID: mediaItemID, Bytes: job.size, Status: report.StatusSkipped, Reason: log.ReasonInLibrary})
+	}
+	return true, nil
+}
+
+// runOnUpload runs the OnUpload hook, if it's set. It only returns the error if OnUploadFatal is set.
+func (job *EnqueuedUpload) runOnUpload(mediaItemID string) error {
+	if job.OnUpload == nil {
+		return nil
+	}
+	err := job.OnUpload.Run(job.Context, hook.Upload{Path: job.Path, MediaItemID: mediaItemID, AlbumName: job.AlbumName})
+	if err == nil {
+		return nil
+	}
+	if job.OnUploadFatal {
+		return fmt.Errorf("upload hook failed: %w", err)
+	}
+	job.Logger.Warnf("Upload hook failed: file=%s, error=%v", job.Path, err)
+	return nil
+}
+
+// modTime returns the modification time of the file, or the zero time if it could not be read.
+func modTime(path string) time.Time {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return fi.ModTime()
+}
+
+// errorStatus returns the HTTP status code of the error, or "none" if it's not an HTTP error.
+func errorStatus(err error) string {
+	var statusErr *upload.StatusError
+	if errors.As(err, &statusErr) {
+		return strconv.Itoa(statusErr.StatusCode)
+	}
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		return strconv.Itoa(apiErr.Code)
+	}
+	return "none"
+}
+
 func (job *EnqueuedUpload) ID() string {
 	return job.Path
 }
@@ -60,9 +380,157 @@ func (job *EnqueuedUpload) removeIfItWasRequested(item upload.FileItem) error {
 	return nil
 }
 
-func (job *EnqueuedUpload) addMediaToAlbum(album string, item upload.FileItem) error {
-	if _, err := job.Uploads.UploadFileToAlbum(job.Context, album, item.Path); err != nil {
-		return err
+func (job *EnqueuedUpload) moveToTrash() error {
+	if err := job.Trash.Trash(job.Path); err != nil {
+		job.Logger.Errorf("Trash request failed: file=%s, err=%v", job.Path, err)
+		return nil
+	}
+	job.Logger.Debugf("File has been moved to the trash: file=%s", job.Path)
+	return nil
+}
+
+func (job *EnqueuedUpload) move(item upload.FileItem) error {
+	dst := filepath.Join(job.MoveToDir, upload.RelativePath(job.SourceFolder, job.Path))
+	moved, err := item.MoveTo(dst)
+	if err != nil {
+		job.Logger.Errorf("Move request failed: file=%s, err=%v", job.Path, err)
+		return nil
 	}
+	job.Logger.Debugf("File has been moved: file=%s, destination=%s", job.Path, moved)
 	return nil
 }
+
+// createOrAttach adds the file to the album, returning the ID of its media item. Files already uploaded,
+// whose MediaItemID is set, are only attached to the album. Otherwise, their content is uploaded.
+func (job *EnqueuedUpload) createOrAttach(item upload.FileItem) (string, error) {
+	if job.MediaItemID == "" {
+		return job.uploadBytes(item)
+	}
+	err := job.AlbumItems.AddMediaItems(job.Context, job.AlbumID, []string{job.MediaItemID})
+	if upload.IsAlreadyInAlbum(err) {
+		job.Logger.Debugf("File '%s' is in album '%s' already: %s", job.Path, job.AlbumName, err)
+		err = nil
+	}
+	if err != nil {
+		metrics.UploadErrors.Inc(errorStatus(err))
+		return "", upload.ClassifyError(err)
+	}
+	job.logAttached()
+	return job.MediaItemID, nil
+}
+
+// logAttached logs that the file, already uploaded, has been added to the album.
+func (job *EnqueuedUpload) logAttached() {
+	job.Logger.WithFields(log.Fields{
+		"event": log.EventFileAttached,
+		"path":  job.Path,
+		"album": job.AlbumName,
+	}).Infof("Added already uploaded '%s' to album '%s'", job.Path, job.AlbumName)
+}
+
+// uploadBytes uploads the content of the file, creating its media item in the album, and returns its ID.
+func (job *EnqueuedUpload) uploadBytes(item upload.FileItem) (string, error) {
+	// HEIC files are uploaded as is, unless a converter is set.
+	uploadItem := item
+	if job.Converter != nil && convert.IsHEIC(job.Path) {
+		if err := job.SpaceGuard.Check(); err != nil {
+			return "", err
+		}
+		converted, cleanup, err := convert.ToTempJPEG(job.Context, job.Converter, job.Path, job.TempDir)
+		if err != nil {
+			return "", err
+		}
+		defer cleanup()
+		job.Logger.Debugf("Converted '%s' to JPEG before uploading it", job.Path)
+		uploadItem = upload.NewFileItem(converted)
+	}
+
+	start := time.Now()
+	mediaItem, err := job.uploadWithTimeout(uploadItem.Path, job.description())
+	if err != nil {
+		metrics.UploadErrors.Inc(errorStatus(err))
+		return "", upload.ClassifyError(err)
+	}
+	elapsed := time.Since(start)
+	metrics.FilesUploaded.Inc()
+	metrics.BytesUploaded.Add(float64(uploadItem.Size()))
+	metrics.UploadDuration.Observe(elapsed.Seconds())
+	if job.Stats != nil {
+		job.Stats.AddUploadedToAlbum(job.AlbumName, uploadItem.Size())
+	}
+	job.Logger.WithFields(log.Fields{
+		"event":       log.EventFileUploaded,
+		"path":        job.Path,
+		"album":       job.AlbumName,
+		"bytes":       uploadItem.Size(),
+		"duration_ms": elapsed.Milliseconds(),
+	}).Infof("Uploaded '%s' to album '%s'", job.Path, job.AlbumName)
+	return mediaItem.ID, nil
+}
+
+// uploadWithTimeout uploads the content in path, attempting it again if it exceeds PerFileTimeout. Every attempt
+// has a timeout of its own, and the error returned once all of them have exceeded it wraps ErrUploadTimeout.
+func (job *EnqueuedUpload) uploadWithTimeout(path string, description string) (media_items.MediaItem, error) {
+	if job.PerFileTimeout <= 0 {
+		return job.uploadContent(job.Context, path, description)
+	}
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(job.Context, job.PerFileTimeout)
+		mediaItem, err := job.uploadContent(ctx, path, description)
+		cancel()
+		// the deadline of the run, or its interruption, are not timeouts of the file.
+		if err == nil || job.Context.Err() != nil || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return mediaItem, err
+		}
+		if attempt > job.TimeoutRetries {
+			return media_items.MediaItem{}, fmt.Errorf("%w: '%s' has not been uploaded within %s, %d attempts", ErrUploadTimeout, job.Path, job.PerFileTimeout, attempt)
+		}
+		job.Logger.Warnf("Upload of '%s' has not finished within %s, attempting it again", job.Path, job.PerFileTimeout)
+	}
+}
+
+// uploadContent uploads the content in path to the album, with the description if it's not empty.
+func (job *EnqueuedUpload) uploadContent(ctx context.Context, path string, description string) (media_items.MediaItem, error) {
+	if description != "" {
+		return job.Uploads.(DescribedUploadsService).UploadFileToAlbumWithDescription(ctx, job.AlbumID, path, description)
+	}
+	return job.Uploads.UploadFileToAlbum(ctx, job.AlbumID, path)
+}
+
+// description returns the description of the file, read from the original file, not the converted one. It returns an
+// empty string if it has none, it could not be read, or Uploads can't set it.
+func (job *EnqueuedUpload) description() string {
+	if job.Descriptions == nil {
+		return ""
+	}
+	if _, ok := job.Uploads.(DescribedUploadsService); !ok {
+		return ""
+	}
+	description, err := job.Descriptions.Description(job.Path)
+	if err != nil {
+		job.Logger.Warnf("Unable to read the description of '%s', uploading it without it: %s", job.Path, err)
+		return ""
+	}
+	return description
+}
+
+// track marks the file as uploaded in the FileTracker. If it keeps the albums of the files, the album is
+// recorded too, so the file is not added to it again. Favorites are recorded too, if it keeps them.
+func (job *EnqueuedUpload) track(mediaItemID string) error {
+	// files only attached to the album are tracked already.
+	if job.MediaItemID == "" {
+		if err := job.FileTracker.Put(job.Path, mediaItemID); err != nil {
+			return err
+		}
+	}
+	if favorites, ok := job.FileTracker.(upload.FavoriteTracker); ok && job.Favorite {
+		if err := favorites.MarkFavorite(job.Path); err != nil {
+			return err
+		}
+	}
+	tracker, ok := job.FileTracker.(upload.AlbumTracker)
+	if !ok || job.AlbumName == "" {
+		return nil
+	}
+	return tracker.AddAlbum(job.Path, job.AlbumName)
+}
@@ -0,0 +1,1188 @@
+package task_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gphotosuploader/google-photos-api-client-go/v2/media_items"
+	"google.golang.org/api/googleapi"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/filter"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/hook"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/mock"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/runstats"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/task"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/tempdir"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/upload"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/worker"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/xmp"
+)
+
+const ShouldMakeUploadFail = "should-make-upload-fail"
+
+func TestEnqueuedUpload_ProcessWithWorkers(t *testing.T) {
+	const numberOfFiles = 20
+	var testCases = []struct {
+		numberOfWorkers int
+	}{
+		{numberOfWorkers: 1},
+		{numberOfWorkers: 4},
+		{numberOfWorkers: 10},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("Workers[%d]", tc.numberOfWorkers), func(t *testing.T) {
+			var mu sync.Mutex
+			uploaded := make(map[string]bool)
+			tracked := make(map[string]bool)
+
+			uploads := &mock.UploadsService{
+				UploadFileToAlbumFn: func(ctx context.Context, albumId string, filePath string) (media_items.MediaItem, error) {
+					if filePath == ShouldMakeUploadFail {
+						return media_items.MediaItem{}, errors.New("error")
+					}
+					mu.Lock()
+					defer mu.Unlock()
+					uploaded[filePath] = true
+					return media_items.MediaItem{}, nil
+				},
+			}
+			ft := &mock.FileTracker{
+				PutFn: func(path string, mediaItemID string) error {
+					mu.Lock()
+					defer mu.Unlock()
+					tracked[path] = true
+					return nil
+				},
+			}
+
+			stats := runstats.New()
+			queue := worker.NewJobQueue(tc.numberOfWorkers, log.Discard)
+			queue.Start()
+			defer queue.Stop()
+
+			files := []string{ShouldMakeUploadFail}
+			for i := 0; i < numberOfFiles; i++ {
+				files = append(files, fmt.Sprintf("file-%d.jpg", i))
+			}
+			for _, f := range files {
+				queue.Submit(&task.EnqueuedUpload{
+					Context:     context.Background(),
+					Uploads:     uploads,
+					FileTracker: ft,
+					Logger:      log.Discard,
+					Path:        f,
+					Stats:       stats,
+				})
+			}
+
+			var failed []string
+			for range files {
+				if r := <-queue.ChanJobResults(); r.Err != nil {
+					failed = append(failed, r.ID)
+				}
+			}
+
+			if len(failed) != 1 || failed[0] != ShouldMakeUploadFail {
+				t.Errorf("want: [%s] failed, got: %v", ShouldMakeUploadFail, failed)
+			}
+			if len(uploaded) != numberOfFiles {
+				t.Errorf("want: %d uploaded files, got: %d", numberOfFiles, len(uploaded))
+			}
+			if len(tracked) != numberOfFiles || tracked[ShouldMakeUploadFail] {
+				t.Errorf("want: %d tracked files, got: %d", numberOfFiles, len(tracked))
+			}
+			if got := stats.Snapshot().Uploaded; got != numberOfFiles {
+				t.Errorf("want: %d uploaded files in stats, got: %d", numberOfFiles, got)
+			}
+		})
+	}
+}
+
+func TestEnqueuedUpload_ProcessCancelledMidBatch(t *testing.T) {
+	const numberOfFiles = 6
+	const slowFile = "slow-file.jpg"
+
+	var mu sync.Mutex
+	uploaded := make(map[string]bool)
+	tracked := make(map[string]bool)
+	started := make(chan struct{})
+
+	uploads := &mock.UploadsService{
+		UploadFileToAlbumFn: func(ctx context.Context, albumId string, filePath string) (media_items.MediaItem, error) {
+			if filePath == slowFile {
+				// the upload is in progress when the run is cancelled.
+				close(started)
+				<-ctx.Done()
+				return media_items.MediaItem{}, ctx.Err()
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			uploaded[filePath] = true
+			return media_items.MediaItem{ID: "id-" + filePath}, nil
+		},
+	}
+	ft := &mock.FileTracker{
+		PutFn: func(path string, mediaItemID string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			tracked[path] = true
+			return nil
+		},
+	}
+
+	queue := worker.NewJobQueue(4, log.Discard)
+	queue.Start()
+	defer queue.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	files := []string{slowFile}
+	for i := 0; i < numberOfFiles; i++ {
+		files = append(files, fmt.Sprintf("file-%d.jpg", i))
+	}
+	for _, f := range files {
+		queue.Submit(&task.EnqueuedUpload{
+			Context:     ctx,
+			Uploads:     uploads,
+			FileTracker: ft,
+			Logger:      log.Discard,
+			Path:        f,
+		})
+	}
+
+	<-started
+	cancel()
+
+	for range files {
+		r := <-queue.ChanJobResults()
+		if r.ID == slowFile && !errors.Is(r.Err, context.Canceled) {
+			t.Errorf("want: %v, got: %v", context.Canceled, r.Err)
+		}
+		if r.Err != nil && !errors.Is(r.Err, context.Canceled) {
+			t.Errorf("error was not expected at this point: %s", r.Err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if tracked[slowFile] {
+		t.Errorf("aborted file should not be tracked: %s", slowFile)
+	}
+	for path := range tracked {
+		if !uploaded[path] {
+			t.Errorf("not uploaded file should not be tracked: %s", path)
+		}
+	}
+	if len(tracked) != len(uploaded) {
+		t.Errorf("want: %d tracked files, got: %d", len(uploaded), len(tracked))
+	}
+}
+
+func TestEnqueuedUpload_ProcessDryRun(t *testing.T) {
+	uploads := &mock.UploadsService{
+		UploadFileToAlbumFn: func(ctx context.Context, albumId string, filePath string) (media_items.MediaItem, error) {
+			t.Errorf("upload was not expected in dry-run mode, file: %s", filePath)
+			return media_items.MediaItem{}, nil
+		},
+	}
+	ft := &mock.FileTracker{
+		PutFn: func(path string, mediaItemID string) error {
+			t.Errorf("tracking was not expected in dry-run mode, file: %s", path)
+			return nil
+		},
+		DeleteFn: func(path string) error {
+			t.Errorf("tracking was not expected in dry-run mode, file: %s", path)
+			return nil
+		},
+	}
+
+	job := &task.EnqueuedUpload{
+		Context:     context.Background(),
+		Uploads:     uploads,
+		FileTracker: ft,
+		Logger:      log.Discard,
+
+		Path:            "testdata/non-existent.jpg",
+		AlbumName:       "album",
+		DeleteOnSuccess: true,
+		DryRun:          true,
+	}
+
+	if err := job.Process(); err != nil {
+		t.Errorf("error was not expected at this point: %s", err)
+	}
+}
+
+func TestEnqueuedUpload_ProcessConvertsHEIC(t *testing.T) {
+	var testCases = []struct {
+		name          string
+		path          string
+		converter     bool
+		wantConverted bool
+	}{
+		{"Should convert HEIC file", "IMG_0001.HEIC", true, true},
+		{"Should convert HEIF file", "IMG_0001.heif", true, true},
+		{"Should not convert other files", "IMG_0001.jpg", true, false},
+		{"Should upload HEIC file as is without converter", "IMG_0001.heic", false, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "convert")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			src := filepath.Join(dir, tc.path)
+			if err := ioutil.WriteFile(src, []byte("original"), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			var converted bool
+			var uploaded, tracked string
+			job := &task.EnqueuedUpload{
+				Context: context.Background(),
+				Uploads: &mock.UploadsService{
+					UploadFileToAlbumFn: func(ctx context.Context, albumId string, filePath string) (media_items.MediaItem, error) {
+						uploaded = filePath
+						return media_items.MediaItem{ID: "id"}, nil
+					},
+				},
+				FileTracker: &mock.FileTracker{
+					PutFn: func(path string, mediaItemID string) error {
+						tracked = path
+						return nil
+					},
+				},
+				Logger:  log.Discard,
+				Path:    src,
+				TempDir: filepath.Join(dir, "tmp"),
+			}
+			if tc.converter {
+				job.Converter = &mock.Converter{
+					ToJPEGFn: func(ctx context.Context, src string, dst string) error {
+						converted = true
+						return ioutil.WriteFile(dst, []byte("converted"), 0600)
+					},
+				}
+			}
+
+			if err := job.Process(); err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			if converted != tc.wantConverted {
+				t.Errorf("want: %t, got: %t", tc.wantConverted, converted)
+			}
+			// the converted file is uploaded, but the original one is tracked.
+			if tc.wantConverted == (uploaded == src) {
+				t.Errorf("uploaded file was not expected: %s", uploaded)
+			}
+			if tc.wantConverted && filepath.Ext(uploaded) != ".jpg" {
+				t.Errorf("want: .jpg, got: %s", filepath.Ext(uploaded))
+			}
+			if tc.wantConverted && !strings.HasPrefix(uploaded, job.TempDir+string(filepath.Separator)) {
+				t.Errorf("want: file in %s, got: %s", job.TempDir, uploaded)
+			}
+			if tracked != src {
+				t.Errorf("want: %s, got: %s", src, tracked)
+			}
+			// the converted file is removed once it has been uploaded.
+			if _, err := os.Stat(uploaded); tc.wantConverted && !os.IsNotExist(err) {
+				t.Errorf("converted file was not expected to exist: %s", uploaded)
+			}
+		})
+	}
+}
+
+func TestEnqueuedUpload_ProcessConvertFailed(t *testing.T) {
+	job := &task.EnqueuedUpload{
+		Context: context.Background(),
+		Uploads: &mock.UploadsService{
+			UploadFileToAlbumFn: func(ctx context.Context, albumId string, filePath string) (media_items.MediaItem, error) {
+				t.Errorf("upload was not expected, file: %s", filePath)
+				return media_items.MediaItem{}, nil
+			},
+		},
+		FileTracker: &mock.FileTracker{},
+		Logger:      log.Discard,
+		Path:        "testdata/IMG_0001.heic",
+		Converter: &mock.Converter{
+			ToJPEGFn: func(ctx context.Context, src string, dst string) error {
+				return errors.New("invalid image")
+			},
+		},
+	}
+
+	if err := job.Process(); err == nil {
+		t.Errorf("error was expected at this point")
+	}
+}
+
+func TestEnqueuedUpload_ProcessLowFreeSpace(t *testing.T) {
+	testCases := []struct {
+		name          string
+		free          uint64
+		wantUploaded  bool
+		isErrExpected bool
+	}{
+		{name: "Should convert above the minimum free space", free: 2000, wantUploaded: true},
+		{name: "Should fail below the minimum free space", free: 999, isErrExpected: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "low-free-space")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			converted, uploaded := false, false
+			job := &task.EnqueuedUpload{
+				Context: context.Background(),
+				Uploads: &mock.UploadsService{
+					UploadFileToAlbumFn: func(ctx context.Context, albumId string, filePath string) (media_items.MediaItem, error) {
+						uploaded = true
+						return media_items.MediaItem{ID: "id"}, nil
+					},
+				},
+				FileTracker: &mock.FileTracker{
+					PutFn: func(path string, mediaItemID string) error { return nil },
+				},
+				Logger:  log.Discard,
+				Path:    "testdata/IMG_0001.heic",
+				TempDir: dir,
+				SpaceGuard: &tempdir.SpaceGuard{
+					Path:    dir,
+					MinFree: 1000,
+					FreeSpace: func(path string) (uint64, error) {
+						return tc.free, nil
+					},
+				},
+				Converter: &mock.Converter{
+					ToJPEGFn: func(ctx context.Context, src string, dst string) error {
+						converted = true
+						return ioutil.WriteFile(dst, []byte("converted"), 0600)
+					},
+				},
+			}
+
+			err = job.Process()
+			if tc.isErrExpected && !errors.Is(err, tempdir.ErrLowFreeSpace) {
+				t.Errorf("want: %v, got: %v", tempdir.ErrLowFreeSpace, err)
+			}
+			if !tc.isErrExpected && err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			if converted != tc.wantUploaded || uploaded != tc.wantUploaded {
+				t.Errorf("want: %t, got: converted %t, uploaded %t", tc.wantUploaded, converted, uploaded)
+			}
+			// no intermediate files are left, whether the file has been converted or not.
+			if entries, err := ioutil.ReadDir(dir); err != nil || len(entries) > 0 {
+				t.Errorf("temporary files were not expected: %v, %v", entries, err)
+			}
+		})
+	}
+}
+
+func TestEnqueuedUpload_ProcessPerFileTimeout(t *testing.T) {
+	testCases := []struct {
+		name          string
+		stalls        int
+		wantAttempts  int
+		wantCancelled int
+		isErrExpected bool
+	}{
+		{name: "Should upload without stalling", stalls: 0, wantAttempts: 1, wantCancelled: 0},
+		{name: "Should retry a stalled upload", stalls: 2, wantAttempts: 3, wantCancelled: 2},
+		{name: "Should fail once retries are exhausted", stalls: 5, wantAttempts: 3, wantCancelled: 3, isErrExpected: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			attempts, cancelled := 0, 0
+			job := &task.EnqueuedUpload{
+				Context: context.Background(),
+				Uploads: &mock.UploadsService{
+					UploadFileToAlbumFn: func(ctx context.Context, albumId string, filePath string) (media_items.MediaItem, error) {
+						attempts++
+						if attempts > tc.stalls {
+							return media_items.MediaItem{ID: "id"}, nil
+						}
+						// the connection hangs until the request is cancelled.
+						<-ctx.Done()
+						cancelled++
+						return media_items.MediaItem{}, ctx.Err()
+					},
+				},
+				FileTracker: &mock.FileTracker{
+					PutFn: func(path string, mediaItemID string) error { return nil },
+				},
+				Logger:         log.Discard,
+				Path:           "testdata/image.png",
+				PerFileTimeout: 10 * time.Millisecond,
+				TimeoutRetries: 2,
+			}
+
+			err := job.Process()
+			if tc.isErrExpected && !errors.Is(err, task.ErrUploadTimeout) {
+				t.Errorf("want: %v, got: %v", task.ErrUploadTimeout, err)
+			}
+			// the file has timed out, the run has not been interrupted.
+			if tc.isErrExpected && errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("deadline exceeded was not expected: %v", err)
+			}
+			if !tc.isErrExpected && err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			if tc.wantAttempts != attempts {
+				t.Errorf("want: %d attempts, got: %d", tc.wantAttempts, attempts)
+			}
+			if tc.wantCancelled != cancelled {
+				t.Errorf("want: %d cancelled, got: %d", tc.wantCancelled, cancelled)
+			}
+		})
+	}
+}
+
+func TestEnqueuedUpload_ProcessPerFileTimeoutInterrupted(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	attempts := 0
+	job := &task.EnqueuedUpload{
+		Context: ctx,
+		Uploads: &mock.UploadsService{
+			UploadFileToAlbumFn: func(ctx context.Context, albumId string, filePath string) (media_items.MediaItem, error) {
+				attempts++
+				cancel()
+				<-ctx.Done()
+				return media_items.MediaItem{}, ctx.Err()
+			},
+		},
+		FileTracker:    &mock.FileTracker{},
+		Logger:         log.Discard,
+		Path:           "testdata/image.png",
+		PerFileTimeout: time.Hour,
+		TimeoutRetries: 2,
+	}
+
+	// the interruption of the run is not retried.
+	if err := job.Process(); !errors.Is(err, context.Canceled) {
+		t.Errorf("want: %v, got: %v", context.Canceled, err)
+	}
+	if attempts != 1 {
+		t.Errorf("want: %d attempts, got: %d", 1, attempts)
+	}
+}
+
+func TestEnqueuedUpload_ProcessMoveToDir(t *testing.T) {
+	testCases := []struct {
+		name          string
+		uploadFails   bool
+		trackingFails bool
+		existent      bool
+		wantSource    bool
+		wantMovedTo   string
+		isErrExpected bool
+	}{
+		{name: "Should move uploaded file", wantMovedTo: "folder/photo.jpg"},
+		{name: "Should append suffix on collision", existent: true, wantMovedTo: "folder/photo_1.jpg"},
+		{name: "Should keep the file if upload fails", uploadFails: true, wantSource: true, isErrExpected: true},
+		{name: "Should keep the file if tracking fails", trackingFails: true, wantSource: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "move-to-dir")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			srcFolder := filepath.Join(dir, "src")
+			moveToDir := filepath.Join(dir, "uploaded")
+			src := filepath.Join(srcFolder, "folder", "photo.jpg")
+			writeFile(t, src, "photo")
+			if tc.existent {
+				writeFile(t, filepath.Join(moveToDir, "folder", "photo.jpg"), "existent")
+			}
+
+			uploads := &mock.UploadsService{
+				UploadFileToAlbumFn: func(ctx context.Context, albumId string, filePath string) (media_items.MediaItem, error) {
+					if tc.uploadFails {
+						return media_items.MediaItem{}, errors.New("error")
+					}
+					return media_items.MediaItem{}, nil
+				},
+			}
+			ft := &mock.FileTracker{
+				PutFn: func(path string, mediaItemID string) error {
+					if tc.trackingFails {
+						return errors.New("error")
+					}
+					return nil
+				},
+			}
+
+			job := &task.EnqueuedUpload{
+				Context:     context.Background(),
+				Uploads:     uploads,
+				FileTracker: ft,
+				Logger:      log.Discard,
+
+				Path:         src,
+				MoveToDir:    moveToDir,
+				SourceFolder: srcFolder,
+			}
+
+			err = job.Process()
+			if tc.isErrExpected && err == nil {
+				t.Fatalf("error was expected, but not produced")
+			}
+			if !tc.isErrExpected && err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+
+			if _, err := os.Stat(src); (err == nil) != tc.wantSource {
+				t.Errorf("want source file existence: %t, got: %t", tc.wantSource, err == nil)
+			}
+			if tc.wantMovedTo != "" {
+				b, err := ioutil.ReadFile(filepath.Join(moveToDir, tc.wantMovedTo))
+				if err != nil {
+					t.Fatalf("moved file was expected: %s", err)
+				}
+				if string(b) != "photo" {
+					t.Errorf("want: %s, got: %s", "photo", b)
+				}
+			}
+			if tc.existent {
+				b, _ := ioutil.ReadFile(filepath.Join(moveToDir, "folder", "photo.jpg"))
+				if string(b) != "existent" {
+					t.Errorf("existent file should not be overwritten, got: %s", b)
+				}
+			}
+		})
+	}
+}
+
+func TestEnqueuedUpload_ProcessTrash(t *testing.T) {
+	testCases := []struct {
+		name          string
+		uploadFails   bool
+		want          []string
+		isErrExpected bool
+	}{
+		{name: "Should trash uploaded file", want: []string{"/photos/IMG_0001.jpg"}},
+		{name: "Should not trash file if upload fails", uploadFails: true, isErrExpected: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var trashed []string
+			job := &task.EnqueuedUpload{
+				Context: context.Background(),
+				Uploads: &mock.UploadsService{
+					UploadFileToAlbumFn: func(ctx context.Context, albumId string, filePath string) (media_items.MediaItem, error) {
+						if tc.uploadFails {
+							return media_items.MediaItem{}, errors.New("error")
+						}
+						return media_items.MediaItem{ID: "media-1"}, nil
+					},
+				},
+				FileTracker: &mock.FileTracker{PutFn: func(path string, mediaItemID string) error { return nil }},
+				Trash: &mock.Trasher{
+					TrashFn: func(path string) error {
+						trashed = append(trashed, path)
+						return nil
+					},
+				},
+				Logger: log.Discard,
+
+				Path: "/photos/IMG_0001.jpg",
+			}
+
+			err := job.Process()
+			if tc.isErrExpected && err == nil {
+				t.Fatalf("error was expected, but not produced")
+			}
+			if !tc.isErrExpected && err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			if strings.Join(trashed, "|") != strings.Join(tc.want, "|") {
+				t.Errorf("want: %v, got: %v", tc.want, trashed)
+			}
+		})
+	}
+}
+
+func TestEnqueuedUpload_ProcessLogsUploadEvent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "upload-event")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "photo.jpg")
+	writeFile(t, path, "content")
+
+	var out bytes.Buffer
+	job := &task.EnqueuedUpload{
+		Context: context.Background(),
+		Uploads: &mock.UploadsService{
+			UploadFileToAlbumFn: func(ctx context.Context, albumId string, filePath string) (media_items.MediaItem, error) {
+				return media_items.MediaItem{}, nil
+			},
+		},
+		FileTracker: &mock.FileTracker{
+			PutFn: func(path string, mediaItemID string) error {
+				return nil
+			},
+		},
+		Logger:    log.NewJSONLogger(&out),
+		Path:      path,
+		AlbumName: "Trips",
+	}
+
+	if err := job.Process(); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("output is not a JSON line: %s, err: %s", out.String(), err)
+	}
+
+	want := map[string]interface{}{
+		"level": "info",
+		"event": "file_uploaded",
+		"path":  path,
+		"album": "Trips",
+		"bytes": float64(len("content")),
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("want: %v, got: %v, field: %s", v, got[k], k)
+		}
+	}
+	if _, ok := got["duration_ms"].(float64); !ok {
+		t.Errorf("want: duration_ms, got: %v", got["duration_ms"])
+	}
+	if _, err := time.Parse(time.RFC3339Nano, fmt.Sprint(got["time"])); err != nil {
+		t.Errorf("want: time, got: %v, err: %s", got["time"], err)
+	}
+}
+
+func writeFile(t *testing.T, path string, content string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEnqueuedUpload_ProcessOnUpload(t *testing.T) {
+	testCases := []struct {
+		name          string
+		hookFails     bool
+		fatal         bool
+		wantSource    bool
+		wantWarning   bool
+		isErrExpected bool
+	}{
+		{name: "Should remove the file after running the hook"},
+		{name: "Should only log the hook failure by default", hookFails: true, wantWarning: true},
+		{name: "Should keep the file if the fatal hook fails", hookFails: true, fatal: true, wantSource: true, isErrExpected: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "on-upload")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			src := filepath.Join(dir, "photo.jpg")
+			writeFile(t, src, "photo")
+
+			var got []hook.Upload
+			onUpload := &mock.UploadHook{
+				RunFn: func(ctx context.Context, u hook.Upload) error {
+					got = append(got, u)
+					if tc.hookFails {
+						return errors.New("exit status 1")
+					}
+					return nil
+				},
+			}
+			logger := &mock.Logger{}
+			job := &task.EnqueuedUpload{
+				Context: context.Background(),
+				Uploads: &mock.UploadsService{
+					UploadFileToAlbumFn: func(ctx context.Context, albumId string, filePath string) (media_items.MediaItem, error) {
+						return media_items.MediaItem{ID: "media-1"}, nil
+					},
+				},
+				FileTracker: &mock.FileTracker{PutFn: func(path string, mediaItemID string) error { return nil }},
+				Logger:      logger,
+
+				Path:            src,
+				AlbumName:       "Trips",
+				DeleteOnSuccess: true,
+				OnUpload:        onUpload,
+				OnUploadFatal:   tc.fatal,
+			}
+
+			err = job.Process()
+			if tc.isErrExpected && err == nil {
+				t.Fatalf("error was expected, but not produced")
+			}
+			if !tc.isErrExpected && err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+
+			want := hook.Upload{Path: src, MediaItemID: "media-1", AlbumName: "Trips"}
+			if len(got) != 1 || got[0] != want {
+				t.Errorf("want: %v, got: %v", want, got)
+			}
+			if _, err := os.Stat(src); (err == nil) != tc.wantSource {
+				t.Errorf("want source file existence: %t, got: %t", tc.wantSource, err == nil)
+			}
+			if logger.WarnfInvoked != tc.wantWarning {
+				t.Errorf("want warning: %t, got: %t", tc.wantWarning, logger.WarnfInvoked)
+			}
+		})
+	}
+}
+
+func TestEnqueuedUpload_ProcessAttachesTrackedFile(t *testing.T) {
+	var uploads, tracked int
+	var attached []string
+	var albums []string
+	job := &task.EnqueuedUpload{
+		Context: context.Background(),
+		Uploads: &mock.UploadsService{
+			UploadFileToAlbumFn: func(ctx context.Context, albumId string, filePath string) (media_items.MediaItem, error) {
+				uploads++
+				return media_items.MediaItem{ID: "other-media-item"}, nil
+			},
+		},
+		AlbumItems: &mock.AlbumItemsService{
+			AddMediaItemsFn: func(ctx context.Context, albumId string, mediaItemIds []string) error {
+				attached = append(attached, albumId+"/"+strings.Join(mediaItemIds, ","))
+				return nil
+			},
+		},
+		FileTracker: &mock.AlbumTracker{
+			FileTracker: mock.FileTracker{
+				PutFn: func(path string, mediaItemID string) error {
+					tracked++
+					return nil
+				},
+			},
+			AddAlbumFn: func(path string, album string) error {
+				albums = append(albums, album)
+				return nil
+			},
+		},
+		Logger: log.Discard,
+
+		Path:        "/photos/IMG_0001.jpg",
+		AlbumID:     "trips-id",
+		AlbumName:   "Trips",
+		MediaItemID: "media-1",
+	}
+
+	if err := job.Process(); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if uploads != 0 {
+		t.Errorf("want: no uploads, got: %d", uploads)
+	}
+	if want := []string{"trips-id/media-1"}; strings.Join(attached, "|") != strings.Join(want, "|") {
+		t.Errorf("want: %v, got: %v", want, attached)
+	}
+	if tracked != 0 {
+		t.Errorf("want: no tracking of the upload, got: %d", tracked)
+	}
+	if want := []string{"Trips"}; strings.Join(albums, "|") != strings.Join(want, "|") {
+		t.Errorf("want: %v, got: %v", want, albums)
+	}
+}
+
+func TestEnqueuedUpload_ProcessAlreadyInAlbum(t *testing.T) {
+	dir, err := ioutil.TempDir("", "already-in-album")
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "Trips", "IMG_0001.jpg")
+	writeFile(t, path, "photo")
+
+	// the file was uploaded to another album, and it's in Trips already, but it's not recorded.
+	albums := map[string][]string{path: {"Beach"}}
+	tracker := &mock.AlbumTracker{
+		FileTracker: mock.FileTracker{
+			ExistFn: func(path string) bool {
+				_, ok := albums[path]
+				return ok
+			},
+		},
+		TrackedAlbumsFn: func(path string) (string, []string, bool) {
+			return "media-1", albums[path], true
+		},
+		AddAlbumFn: func(path string, album string) error {
+			albums[path] = append(albums[path], album)
+			return nil
+		},
+	}
+	folder := upload.UploadFolderJob{
+		FileTracker:  tracker,
+		SourceFolder: dir,
+		CreateAlbums: "folderName",
+		Filter:       filter.MustCompile([]string{"_ALL_FILES_"}, nil),
+	}
+
+	var attempts int
+	for run := 0; run < 2; run++ {
+		items, err := folder.ScanFolder(log.Discard)
+		if err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+		for _, item := range items {
+			job := &task.EnqueuedUpload{
+				Context: context.Background(),
+				AlbumItems: &mock.AlbumItemsService{
+					AddMediaItemsFn: func(ctx context.Context, albumId string, mediaItemIds []string) error {
+						attempts++
+						return &googleapi.Error{Code: http.StatusBadRequest, Message: "Media item already in album"}
+					},
+				},
+				FileTracker: tracker,
+				Logger:      log.Discard,
+
+				Path:        item.Path,
+				AlbumID:     "trips-id",
+				AlbumName:   item.AlbumName,
+				MediaItemID: item.MediaItemID,
+			}
+			if err := job.Process(); err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+		}
+	}
+
+	// the second run doesn't add it again, since the album has been recorded.
+	if attempts != 1 {
+		t.Errorf("want: %d, got: %d", 1, attempts)
+	}
+	if want := []string{"Beach", "Trips"}; strings.Join(albums[path], "|") != strings.Join(want, "|") {
+		t.Errorf("want: %v, got: %v", want, albums[path])
+	}
+}
+
+func TestEnqueuedUpload_ProcessAttachesInBatch(t *testing.T) {
+	var attached []string
+	var albums []string
+	batch := task.NewAlbumBatch(&mock.AlbumItemsService{
+		AddMediaItemsFn: func(ctx context.Context, albumId string, mediaItemIds []string) error {
+			attached = append(attached, albumId+"/"+strings.Join(mediaItemIds, ","))
+			return nil
+		},
+	})
+	tracker := &mock.AlbumTracker{
+		FileTracker: mock.FileTracker{
+			PutFn: func(path string, mediaItemID string) error {
+				return nil
+			},
+		},
+		AddAlbumFn: func(path string, album string) error {
+			albums = append(albums, path+"/"+album)
+			return nil
+		},
+	}
+	for _, path := range []string{"/photos/IMG_0001.jpg", "/photos/IMG_0002.jpg"} {
+		job := &task.EnqueuedUpload{
+			Context:     context.Background(),
+			FileTracker: tracker,
+			Logger:      log.Discard,
+
+			Path:        path,
+			AlbumID:     "trips-id",
+			AlbumName:   "Trips",
+			MediaItemID: "media-" + filepath.Base(path),
+			AlbumBatch:  batch,
+		}
+		if err := job.Process(); err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+	}
+	if len(attached) != 0 || len(albums) != 0 {
+		t.Fatalf("want: nothing added before flushing, got: %v, %v", attached, albums)
+	}
+
+	batch.Flush(context.Background())
+	if want := []string{"trips-id/media-IMG_0001.jpg,media-IMG_0002.jpg"}; strings.Join(attached, "|") != strings.Join(want, "|") {
+		t.Errorf("want: %v, got: %v", want, attached)
+	}
+	if want := []string{"/photos/IMG_0001.jpg/Trips", "/photos/IMG_0002.jpg/Trips"}; strings.Join(albums, "|") != strings.Join(want, "|") {
+		t.Errorf("want: %v, got: %v", want, albums)
+	}
+}
+
+func TestEnqueuedUpload_ProcessAttachesPartialBatch(t *testing.T) {
+	var albums []string
+	failed := make(map[string]error)
+	batch := task.NewAlbumBatch(&mock.AlbumItemsResultsService{
+		AddMediaItemsResultsFn: func(ctx context.Context, albumId string, mediaItemIds []string) ([]error, error) {
+			results := make([]error, len(mediaItemIds))
+			for i, id := range mediaItemIds {
+				if id == "media-IMG_0002.jpg" {
+					results[i] = errors.New("invalid media item")
+				}
+			}
+			return results, nil
+		},
+	})
+	tracker := &mock.AlbumTracker{
+		AddAlbumFn: func(path string, album string) error {
+			albums = append(albums, path+"/"+album)
+			return nil
+		},
+	}
+	stats := runstats.New()
+	for _, path := range []string{"/photos/IMG_0001.jpg", "/photos/IMG_0002.jpg", "/photos/IMG_0003.jpg"} {
+		job := &task.EnqueuedUpload{
+			Context:     context.Background(),
+			FileTracker: tracker,
+			Logger:      log.Discard,
+
+			Path:        path,
+			AlbumID:     "trips-id",
+			AlbumName:   "Trips",
+			MediaItemID: "media-" + filepath.Base(path),
+			AlbumBatch:  batch,
+			Stats:       stats,
+			OnAttachFailed: func(path string, err error) {
+				failed[path] = err
+			},
+		}
+		if err := job.Process(); err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+	}
+	batch.Flush(context.Background())
+
+	// only the files added to the album are recorded.
+	if want := []string{"/photos/IMG_0001.jpg/Trips", "/photos/IMG_0003.jpg/Trips"}; strings.Join(albums, "|") != strings.Join(want, "|") {
+		t.Errorf("want: %v, got: %v", want, albums)
+	}
+	if len(failed) != 1 || !task.IsPartialBatch(failed["/photos/IMG_0002.jpg"]) {
+		t.Errorf("want: partial batch error of %s, got: %v", "/photos/IMG_0002.jpg", failed)
+	}
+	if got := stats.Snapshot().Failed; got != 1 {
+		t.Errorf("want: %d, got: %d", 1, got)
+	}
+}
+
+func TestEnqueuedUpload_ProcessSkipsLibraryMatches(t *testing.T) {
+	testCases := []struct {
+		name          string
+		matchID       string
+		matchErr      error
+		wantUploaded  bool
+		wantTrackedID string
+		wantSkipped   int
+	}{
+		{"Should skip file matching a media item", "library-media-item", nil, false, "library-media-item", 1},
+		{"Should upload file not matching any media item", "", nil, true, "uploaded-media-item", 0},
+		{"Should upload file if library could not be searched", "", errors.New("search failed"), true, "uploaded-media-item", 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var uploaded bool
+			var trackedID string
+			var searched string
+			stats := runstats.New()
+			job := &task.EnqueuedUpload{
+				Context: context.Background(),
+				Uploads: &mock.UploadsService{
+					UploadFileToAlbumFn: func(ctx context.Context, albumId string, filePath string) (media_items.MediaItem, error) {
+						uploaded = true
+						return media_items.MediaItem{ID: "uploaded-media-item"}, nil
+					},
+				},
+				FileTracker: &mock.FileTracker{
+					PutFn: func(path string, mediaItemID string) error {
+						trackedID = mediaItemID
+						return nil
+					},
+				},
+				Library: &mock.LibraryMatcher{
+					MatchFn: func(ctx context.Context, path string) (string, bool, error) {
+						searched = path
+						return tc.matchID, tc.matchID != "", tc.matchErr
+					},
+				},
+				Logger: log.Discard,
+				Stats:  stats,
+
+				Path:            "/photos/IMG_0001.jpg",
+				DeleteOnSuccess: true,
+			}
+
+			if err := job.Process(); err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			if searched != "/photos/IMG_0001.jpg" {
+				t.Errorf("want: %s, got: %s", "/photos/IMG_0001.jpg", searched)
+			}
+			if uploaded != tc.wantUploaded {
+				t.Errorf("want uploaded: %t, got: %t", tc.wantUploaded, uploaded)
+			}
+			if trackedID != tc.wantTrackedID {
+				t.Errorf("want: %s, got: %s", tc.wantTrackedID, trackedID)
+			}
+			if got := stats.Snapshot().Skipped; got != tc.wantSkipped {
+				t.Errorf("want: %d skipped, got: %d", tc.wantSkipped, got)
+			}
+		})
+	}
+}
+
+func TestEnqueuedUpload_ProcessTracksAlbum(t *testing.T) {
+	var albums []string
+	job := &task.EnqueuedUpload{
+		Context: context.Background(),
+		Uploads: &mock.UploadsService{
+			UploadFileToAlbumFn: func(ctx context.Context, albumId string, filePath string) (media_items.MediaItem, error) {
+				return media_items.MediaItem{ID: "media-1"}, nil
+			},
+		},
+		FileTracker: &mock.AlbumTracker{
+			FileTracker: mock.FileTracker{PutFn: func(path string, mediaItemID string) error { return nil }},
+			AddAlbumFn: func(path string, album string) error {
+				albums = append(albums, album)
+				return nil
+			},
+		},
+		Logger: log.Discard,
+
+		Path:      "/photos/IMG_0001.jpg",
+		AlbumID:   "trips-id",
+		AlbumName: "Trips",
+	}
+
+	if err := job.Process(); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if len(albums) != 1 || albums[0] != "Trips" {
+		t.Errorf("want: %v, got: %v", []string{"Trips"}, albums)
+	}
+}
+
+func TestEnqueuedUpload_ProcessTracksFavorite(t *testing.T) {
+	testCases := []struct {
+		name     string
+		favorite bool
+		want     []string
+	}{
+		{"Should record favorite", true, []string{"/photos/_favorites/IMG_0001.jpg"}},
+		{"Should not record other files", false, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var favorites []string
+			job := &task.EnqueuedUpload{
+				Context: context.Background(),
+				Uploads: &mock.UploadsService{
+					UploadFileToAlbumFn: func(ctx context.Context, albumId string, filePath string) (media_items.MediaItem, error) {
+						return media_items.MediaItem{ID: "media-1"}, nil
+					},
+				},
+				FileTracker: &mock.FavoriteTracker{
+					FileTracker: mock.FileTracker{PutFn: func(path string, mediaItemID string) error { return nil }},
+					MarkFavoriteFn: func(path string) error {
+						favorites = append(favorites, path)
+						return nil
+					},
+				},
+				Logger: log.Discard,
+
+				Path:     "/photos/_favorites/IMG_0001.jpg",
+				Favorite: tc.favorite,
+			}
+
+			if err := job.Process(); err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			if strings.Join(favorites, "|") != strings.Join(tc.want, "|") {
+				t.Errorf("want: %v, got: %v", tc.want, favorites)
+			}
+		})
+	}
+}
+
+func TestEnqueuedUpload_ProcessSetsDescription(t *testing.T) {
+	dir, err := ioutil.TempDir("", "descriptions")
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	writeFile(t, filepath.Join(dir, "IMG_0001.jpg"), "photo")
+	writeFile(t, filepath.Join(dir, "IMG_0001.xmp"), `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"><rdf:Description xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:description><rdf:Alt><rdf:li xml:lang="x-default">At the beach</rdf:li></rdf:Alt></dc:description></rdf:Description></rdf:RDF></x:xmpmeta>`)
+	writeFile(t, filepath.Join(dir, "IMG_0002.jpg"), "photo")
+
+	testCases := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"Should send description of file with sidecar", "IMG_0001.jpg", "At the beach"},
+		{"Should omit description of file without sidecar", "IMG_0002.jpg", "none"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := ""
+			job := &task.EnqueuedUpload{
+				Context: context.Background(),
+				Uploads: &mock.UploadsService{
+					UploadFileToAlbumFn: func(ctx context.Context, albumId string, filePath string) (media_items.MediaItem, error) {
+						got = "none"
+						return media_items.MediaItem{ID: "media-1"}, nil
+					},
+					UploadFileToAlbumWithDescriptionFn: func(ctx context.Context, albumId string, filePath string, description string) (media_items.MediaItem, error) {
+						got = description
+						return media_items.MediaItem{ID: "media-1"}, nil
+					},
+				},
+				FileTracker:  &mock.FileTracker{PutFn: func(path string, mediaItemID string) error { return nil }},
+				Descriptions: xmp.Reader{},
+				Logger:       log.Discard,
+
+				Path: filepath.Join(dir, tc.path),
+			}
+
+			if err := job.Process(); err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			if tc.want != got {
+				t.Errorf("want: %s, got: %s", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package tempdir
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrLowFreeSpace is returned by SpaceGuard when the free space of the volume is below the minimum.
+var ErrLowFreeSpace = errors.New("not enough free space")
+
+// FreeSpaceFunc returns the number of bytes available to the user in the volume of path.
+type FreeSpaceFunc func(path string) (uint64, error)
+
+// SpaceGuard checks the free space of the volume of a folder before writing large files in it, e.g. the photos
+// converted before uploading them, so the run stops before filling the disk instead of failing mid-write.
+type SpaceGuard struct {
+	// Path is the folder where the files are written.
+	Path string
+	// MinFree is the minimum number of bytes to be kept free. 0 disables the check.
+	MinFree uint64
+	// FreeSpace returns the free space of the volume. Uses FreeSpace if it's not set.
+	FreeSpace FreeSpaceFunc
+}
+
+// Check returns an error wrapping ErrLowFreeSpace if the free space of the volume is below the minimum.
+// A nil guard doesn't check anything. The check is skipped if the free space could not be found, e.g. because
+// the platform doesn't report it, since the files could be written anyway.
+func (g *SpaceGuard) Check() error {
+	if g == nil || g.MinFree == 0 {
+		return nil
+	}
+	freeSpace := g.FreeSpace
+	if freeSpace == nil {
+		freeSpace = FreeSpace
+	}
+	free, err := freeSpace(g.Path)
+	if err != nil {
+		return nil
+	}
+	if free < g.MinFree {
+		return fmt.Errorf("%w in the volume of '%s': %d bytes free, at least %d bytes required", ErrLowFreeSpace, g.Path, free, g.MinFree)
+	}
+	return nil
+}
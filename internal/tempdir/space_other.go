@@ -0,0 +1,15 @@
+//go:build !windows
+// +build !windows
+
+package tempdir
+
+import "syscall"
+
+// FreeSpace returns the number of bytes available to the user in the volume of path.
+func FreeSpace(path string) (uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), nil
+}
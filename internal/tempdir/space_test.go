@@ -0,0 +1,65 @@
+package tempdir_test
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/tempdir"
+)
+
+func TestSpaceGuard_Check(t *testing.T) {
+	testCases := []struct {
+		name          string
+		minFree       uint64
+		free          uint64
+		freeErr       error
+		isErrExpected bool
+	}{
+		{name: "Should proceed above the minimum", minFree: 100, free: 101},
+		{name: "Should proceed at the minimum", minFree: 100, free: 100},
+		{name: "Should fail below the minimum", minFree: 100, free: 99, isErrExpected: true},
+		{name: "Should not check without minimum", minFree: 0, free: 0},
+		{name: "Should proceed if free space is unknown", minFree: 100, freeErr: errors.New("not supported")},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var checked string
+			g := &tempdir.SpaceGuard{
+				Path:    "/tmp/run",
+				MinFree: tc.minFree,
+				FreeSpace: func(path string) (uint64, error) {
+					checked = path
+					return tc.free, tc.freeErr
+				},
+			}
+			err := g.Check()
+			if tc.isErrExpected && !errors.Is(err, tempdir.ErrLowFreeSpace) {
+				t.Errorf("want: %v, got: %v", tempdir.ErrLowFreeSpace, err)
+			}
+			if !tc.isErrExpected && err != nil {
+				t.Errorf("error was not expected at this point: %s", err)
+			}
+			if tc.minFree > 0 && checked != g.Path {
+				t.Errorf("want: %s, got: %s", g.Path, checked)
+			}
+		})
+	}
+}
+
+func TestSpaceGuard_CheckNil(t *testing.T) {
+	var g *tempdir.SpaceGuard
+	if err := g.Check(); err != nil {
+		t.Errorf("error was not expected at this point: %s", err)
+	}
+}
+
+func TestFreeSpace(t *testing.T) {
+	free, err := tempdir.FreeSpace(os.TempDir())
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if free == 0 {
+		t.Errorf("want: free space, got: %d", free)
+	}
+}
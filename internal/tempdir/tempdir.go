@@ -0,0 +1,67 @@
+// Package tempdir keeps the intermediate files of a run, e.g. the photos converted before uploading them, in a
+// folder of their own. It's removed once the run has finished, and the ones left by crashed runs are removed later.
+package tempdir
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Prefix is the prefix of the names of the folders of the runs.
+const Prefix = "gphotos-uploader-"
+
+// StaleAge is the time after which the folder of a run, if it has not been modified, is considered left by a run
+// that could not remove it, e.g. because it crashed.
+const StaleAge = 24 * time.Hour
+
+// Dir is the folder of the intermediate files of a run.
+type Dir struct {
+	// Path is the folder of the run, in the folder for temporary files.
+	Path string
+}
+
+// New creates the folder of a run in base, or in the default folder for temporary files if it's empty.
+// It returns error if base doesn't exist, or it's not writable.
+func New(base string) (*Dir, error) {
+	if base == "" {
+		base = os.TempDir()
+	}
+	path, err := ioutil.TempDir(base, Prefix)
+	if err != nil {
+		return nil, fmt.Errorf("temporary folder '%s' is not writable: %w", base, err)
+	}
+	return &Dir{Path: path}, nil
+}
+
+// Remove removes the folder of the run, and all the files in it.
+func (d *Dir) Remove() error {
+	return os.RemoveAll(d.Path)
+}
+
+// RemoveStale removes the folders of the runs in base not modified within StaleAge, returning their paths.
+// base is the default folder for temporary files if it's empty.
+func RemoveStale(base string, now time.Time) ([]string, error) {
+	if base == "" {
+		base = os.TempDir()
+	}
+	entries, err := ioutil.ReadDir(base)
+	if err != nil {
+		return nil, err
+	}
+	var removed []string
+	for _, fi := range entries {
+		if !fi.IsDir() || !strings.HasPrefix(fi.Name(), Prefix) || now.Sub(fi.ModTime()) < StaleAge {
+			continue
+		}
+		path := filepath.Join(base, fi.Name())
+		if err := os.RemoveAll(path); err != nil {
+			return removed, err
+		}
+		removed = append(removed, path)
+	}
+	return removed, nil
+}
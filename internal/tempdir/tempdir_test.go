@@ -0,0 +1,88 @@
+package tempdir_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/tempdir"
+)
+
+func TestNew(t *testing.T) {
+	base, err := ioutil.TempDir("", "tempdir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(base)
+
+	d, err := tempdir.New(base)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if filepath.Dir(d.Path) != base {
+		t.Errorf("want: folder in %s, got: %s", base, d.Path)
+	}
+	if err := ioutil.WriteFile(filepath.Join(d.Path, "IMG_0001.jpg"), []byte("converted"), 0600); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	if err := d.Remove(); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if _, err := os.Stat(d.Path); !os.IsNotExist(err) {
+		t.Errorf("temporary folder was not expected to exist: %s", d.Path)
+	}
+}
+
+func TestNew_NotWritable(t *testing.T) {
+	base, err := ioutil.TempDir("", "tempdir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(base)
+
+	if _, err := tempdir.New(filepath.Join(base, "missing")); err == nil {
+		t.Errorf("error was expected, but not produced")
+	}
+}
+
+func TestRemoveStale(t *testing.T) {
+	base, err := ioutil.TempDir("", "tempdir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(base)
+
+	now := time.Now()
+	folders := map[string]time.Time{
+		tempdir.Prefix + "crashed": now.Add(-2 * tempdir.StaleAge),
+		tempdir.Prefix + "running": now.Add(-time.Minute),
+		"other-application":        now.Add(-2 * tempdir.StaleAge),
+	}
+	for name, modTime := range folders {
+		path := filepath.Join(base, name)
+		if err := os.MkdirAll(filepath.Join(path, "gphotos-convert"), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := tempdir.RemoveStale(base, now)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if want := []string{filepath.Join(base, tempdir.Prefix+"crashed")}; !reflect.DeepEqual(want, removed) {
+		t.Errorf("want: %v, got: %v", want, removed)
+	}
+	for name := range folders {
+		_, err := os.Stat(filepath.Join(base, name))
+		if exists, want := err == nil, name != tempdir.Prefix+"crashed"; exists != want {
+			t.Errorf("want: %t, got: %t, folder: %s", want, exists, name)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package transport
+
+import "net/http"
+
+// DefaultMaxConnections is the maximum number of connections to every host, if it's not configured. It's more
+// than the default number of workers, so the connections are only waited for by the busiest runs.
+const DefaultMaxConnections = 16
+
+// LimitConnections bounds the connections of t to every host to maxConns, whatever the number of concurrent
+// requests, keeping up to maxConns of them idle to be reused by the next requests. DefaultMaxConnections is used
+// if maxConns is not positive.
+func LimitConnections(t *http.Transport, maxConns int) {
+	if maxConns <= 0 {
+		maxConns = DefaultMaxConnections
+	}
+	t.MaxConnsPerHost = maxConns
+	t.MaxIdleConnsPerHost = maxConns
+	if t.MaxIdleConns != 0 && t.MaxIdleConns < maxConns {
+		t.MaxIdleConns = maxConns
+	}
+}
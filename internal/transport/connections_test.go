@@ -0,0 +1,33 @@
+package transport_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/transport"
+)
+
+func TestLimitConnections(t *testing.T) {
+	testCases := []struct {
+		name     string
+		maxConns int
+		want     int
+	}{
+		{"Should use the configured limit", 4, 4},
+		{"Should use the default limit if it's not configured", 0, transport.DefaultMaxConnections},
+		{"Should use the default limit if it's negative", -1, transport.DefaultMaxConnections},
+		{"Should keep more idle connections than the total if the limit is higher", 200, 200},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rt := http.DefaultTransport.(*http.Transport).Clone()
+			transport.LimitConnections(rt, tc.maxConns)
+			if rt.MaxConnsPerHost != tc.want || rt.MaxIdleConnsPerHost != tc.want {
+				t.Errorf("want: %d connections per host, got: %d connections and %d idle ones", tc.want, rt.MaxConnsPerHost, rt.MaxIdleConnsPerHost)
+			}
+			if rt.MaxIdleConns < tc.want {
+				t.Errorf("want: at least %d idle connections, got: %d", tc.want, rt.MaxIdleConns)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package transport
+
+import (
+	"fmt"
+	"net/http"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// reservedHeaders are the headers set by the requests, or by the HTTP client, that could not be set by Headers.
+var reservedHeaders = map[string]bool{
+	"Authorization":     true,
+	"Connection":        true,
+	"Content-Length":    true,
+	"Content-Type":      true,
+	"Host":              true,
+	"Transfer-Encoding": true,
+	"User-Agent":        true,
+}
+
+// Headers is a http.RoundTripper setting the User-Agent, and other static headers, of every request, e.g. to
+// identify them in a proxy. The User-Agent replaces the one of the request, but the other headers don't replace
+// the ones set by the request already, like the Authorization one.
+type Headers struct {
+	// Base is the underlying RoundTripper. If nil, http.DefaultTransport is used.
+	Base http.RoundTripper
+
+	// UserAgent, if it's set, is the User-Agent of the requests.
+	UserAgent string
+
+	// Header are the headers added to the requests.
+	Header http.Header
+}
+
+// NewHeaders returns a Headers round tripper wrapping base, setting the userAgent and the headers, by their name.
+func NewHeaders(base http.RoundTripper, userAgent string, headers map[string]string) *Headers {
+	h := make(http.Header, len(headers))
+	for name, value := range headers {
+		h.Set(name, value)
+	}
+	return &Headers{Base: base, UserAgent: userAgent, Header: h}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Headers) RoundTrip(req *http.Request) (*http.Response, error) {
+	// the request should not be modified, see http.RoundTripper.
+	req = req.Clone(req.Context())
+	if t.UserAgent != "" {
+		req.Header.Set("User-Agent", t.UserAgent)
+	}
+	for name, values := range t.Header {
+		if _, ok := req.Header[name]; !ok {
+			req.Header[name] = values
+		}
+	}
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
+
+// ValidateHeader returns error if the header could not be set by Headers: its name or its value are invalid, or
+// it's set by the requests, like Authorization or User-Agent.
+func ValidateHeader(name string, value string) error {
+	if !httpguts.ValidHeaderFieldName(name) {
+		return fmt.Errorf("invalid header name '%s'", name)
+	}
+	if reservedHeaders[http.CanonicalHeaderKey(name)] {
+		return fmt.Errorf("header '%s' could not be set, it's set by the requests", name)
+	}
+	if !httpguts.ValidHeaderFieldValue(value) {
+		return fmt.Errorf("invalid value of header '%s'", name)
+	}
+	return nil
+}
+
+// ValidateUserAgent returns error if the User-Agent is not a valid header value.
+func ValidateUserAgent(userAgent string) error {
+	if !httpguts.ValidHeaderFieldValue(userAgent) {
+		return fmt.Errorf("invalid User-Agent '%s'", userAgent)
+	}
+	return nil
+}
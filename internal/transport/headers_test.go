@@ -0,0 +1,78 @@
+package transport_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/transport"
+)
+
+func TestHeaders_RoundTrip(t *testing.T) {
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer server.Close()
+
+	rt := transport.NewHeaders(http.DefaultTransport, "gphotos-uploader-cli/v1.2.3", map[string]string{
+		"x-request-source": "nas",
+		"X-Team":           "photos",
+	})
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("User-Agent", "google-api-go-client/0.5")
+	req.Header.Set("X-Team", "uploads")
+	res, err := (&http.Client{Transport: rt}).Do(req)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	res.Body.Close()
+
+	want := map[string]string{
+		"User-Agent":       "gphotos-uploader-cli/v1.2.3",
+		"X-Request-Source": "nas",
+		// headers set by the request are kept.
+		"Authorization": "Bearer token",
+		"X-Team":        "uploads",
+	}
+	for name, value := range want {
+		if got.Get(name) != value {
+			t.Errorf("want: %s: %s, got: %s", name, value, got.Get(name))
+		}
+	}
+	// the request is not modified.
+	if req.Header.Get("X-Request-Source") != "" || req.Header.Get("User-Agent") != "google-api-go-client/0.5" {
+		t.Errorf("request was not expected to be modified, got: %v", req.Header)
+	}
+}
+
+func TestValidateHeader(t *testing.T) {
+	testCases := []struct {
+		name          string
+		header        string
+		value         string
+		isErrExpected bool
+	}{
+		{"Should success", "X-Request-Source", "nas", false},
+		{"Should fail if name is invalid", "X Request Source", "nas", true},
+		{"Should fail if name is empty", "", "nas", true},
+		{"Should fail if value is invalid", "X-Request-Source", "nas\r\nX-Other: value", true},
+		{"Should fail if it's the authorization header", "authorization", "Bearer token", true},
+		{"Should fail if it's the User-Agent", "User-Agent", "uploader", true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := transport.ValidateHeader(tc.header, tc.value)
+			if tc.isErrExpected && err == nil {
+				t.Errorf("error was expected, but not produced")
+			}
+			if !tc.isErrExpected && err != nil {
+				t.Errorf("error was not expected at this point: %s", err)
+			}
+		})
+	}
+}
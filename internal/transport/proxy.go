@@ -0,0 +1,45 @@
+package transport
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// NewHTTPTransport returns a copy of http.DefaultTransport sending the requests through the proxy at proxyURL, or
+// through the proxy set by the HTTP_PROXY and HTTPS_PROXY environment variables if it's empty. Requests to the
+// hosts in the NO_PROXY environment variable are sent directly in both cases.
+//
+// The environment is read when it's called, unlike http.ProxyFromEnvironment, which reads it only once.
+func NewHTTPTransport(proxyURL string) (*http.Transport, error) {
+	cfg := httpproxy.FromEnvironment()
+	if proxyURL != "" {
+		if err := ValidateProxyURL(proxyURL); err != nil {
+			return nil, err
+		}
+		cfg.HTTPProxy = proxyURL
+		cfg.HTTPSProxy = proxyURL
+	}
+	proxy := cfg.ProxyFunc()
+
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = func(req *http.Request) (*url.URL, error) {
+		return proxy(req.URL)
+	}
+	return t, nil
+}
+
+// ValidateProxyURL returns error if the URL is not the one of a HTTP, HTTPS or SOCKS5 proxy.
+func ValidateProxyURL(proxyURL string) error {
+	u, err := url.Parse(proxyURL)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("invalid proxy URL '%s'", proxyURL)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+		return nil
+	}
+	return fmt.Errorf("invalid proxy URL '%s', the scheme must be http, https or socks5", proxyURL)
+}
@@ -0,0 +1,44 @@
+package transport
+
+import (
+	"context"
+	"net/http"
+)
+
+// RequestBudget represents the number of requests allowed, e.g. in a day.
+type RequestBudget interface {
+	// Take counts a request, returning an error if it's not allowed.
+	Take(ctx context.Context) error
+}
+
+// Quota is a http.RoundTripper counting every request against a budget.
+// Requests not allowed by the budget are not sent, failing with the error returned by it.
+//
+// It should wrap the Retry round tripper, so a request retried is only counted once.
+type Quota struct {
+	// Base is the underlying RoundTripper. If nil, http.DefaultTransport is used.
+	Base http.RoundTripper
+
+	// Budget is where requests are counted.
+	Budget RequestBudget
+}
+
+// NewQuota returns a Quota round tripper wrapping base.
+func NewQuota(base http.RoundTripper, budget RequestBudget) *Quota {
+	return &Quota{Base: base, Budget: budget}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Quota) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.Budget.Take(req.Context()); err != nil {
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+		return nil, err
+	}
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
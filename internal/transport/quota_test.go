@@ -0,0 +1,50 @@
+package transport_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/transport"
+)
+
+var errExhausted = errors.New("exhausted")
+
+// mockedBudget allows a number of requests.
+type mockedBudget struct {
+	remaining int32
+}
+
+func (b *mockedBudget) Take(ctx context.Context) error {
+	if atomic.AddInt32(&b.remaining, -1) < 0 {
+		return errExhausted
+	}
+	return nil
+}
+
+func TestQuota_RoundTrip(t *testing.T) {
+	var calls int32
+	srv := newMockedServer(t, &calls, http.StatusOK)
+	defer srv.Close()
+
+	client := &http.Client{Transport: transport.NewQuota(http.DefaultTransport, &mockedBudget{remaining: 2})}
+	for i := 0; i < 2; i++ {
+		res, err := client.Post(srv.URL, "text/plain", strings.NewReader("payload"))
+		if err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+		_ = res.Body.Close()
+	}
+
+	// requests not allowed by the budget are not sent.
+	_, err := client.Post(srv.URL, "text/plain", strings.NewReader("payload"))
+	if !errors.Is(err, errExhausted) {
+		t.Errorf("want: %s, got: %v", errExhausted, err)
+	}
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("want: %d, got: %d", 2, got)
+	}
+}
@@ -0,0 +1,214 @@
+// Package transport implements http.RoundTripper middlewares used to talk with Google Photos.
+package transport
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultMaxRetries is the maximum number of retries used when none is configured.
+	DefaultMaxRetries = 4
+
+	// DefaultRetryBaseDelay is the delay before the first retry used when none is configured.
+	DefaultRetryBaseDelay = 1 * time.Second
+
+	// DefaultRetryMaxDelay is the maximum delay between two attempts.
+	DefaultRetryMaxDelay = 1 * time.Minute
+
+	// maxMatchedBodySize is the maximum size of the response body matched against RetryableMessages.
+	maxMatchedBodySize = 64 << 10
+)
+
+// Retry is a http.RoundTripper that retries requests failing with a transient error:
+// connection errors, 429 (Too Many Requests) and 5xx status codes.
+// Any other status code, like 400, 401, 403 or 404, is returned immediately, unless its body contains
+// one of the RetryableMessages.
+//
+// Requests creating media items or albums, or adding media items to albums, are not idempotent: they are only
+// retried on a 429 response, since the server could have processed them despite a connection error or a 5xx
+// response. Their callers decide whether to send them again, e.g. upload.TokenReusingUploads.
+//
+// The delay between attempts grows exponentially from BaseDelay, with a random jitter,
+// and is capped by MaxDelay. A Retry-After header sent by the server is honored.
+type Retry struct {
+	// Base is the underlying RoundTripper. If nil, http.DefaultTransport is used.
+	Base http.RoundTripper
+
+	// MaxRetries is the maximum number of retries after the first attempt.
+	MaxRetries int
+
+	// BaseDelay is the delay before the first retry.
+	BaseDelay time.Duration
+
+	// MaxDelay is the maximum delay between two attempts.
+	MaxDelay time.Duration
+
+	// RetryableMessages, if it's set, are the substrings of the error responses that are retried, even if
+	// their status code is not, e.g. a 400 "The upload is too large or has failed". Only 4xx responses
+	// are matched.
+	RetryableMessages []string
+}
+
+// NewRetry returns a Retry round tripper wrapping base.
+func NewRetry(base http.RoundTripper, maxRetries int, baseDelay time.Duration) *Retry {
+	return &Retry{
+		Base:       base,
+		MaxRetries: maxRetries,
+		BaseDelay:  baseDelay,
+		MaxDelay:   DefaultRetryMaxDelay,
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+// Requests with a body are only retried when it could be rewound, see http.Request.GetBody.
+func (t *Retry) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		res, err := t.base().RoundTrip(req)
+		if attempt >= t.MaxRetries || !isRewindable(req) || !t.shouldRetry(req, res, err) {
+			return res, err
+		}
+
+		delay := t.backoff(attempt, res)
+		if res != nil {
+			_ = res.Body.Close()
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// nonIdempotentEndpoints are the suffixes of the paths of the POST requests creating something, that
+// could be created twice if they are sent again.
+var nonIdempotentEndpoints = []string{"/mediaItems:batchCreate", ":batchAddMediaItems", "/albums"}
+
+// IsIdempotent returns true if sending the request again has the same effect than sending it once. Requests
+// creating media items or albums, or adding media items to albums, are not.
+func IsIdempotent(req *http.Request) bool {
+	if req.Method != http.MethodPost {
+		return true
+	}
+	for _, endpoint := range nonIdempotentEndpoints {
+		if strings.HasSuffix(req.URL.Path, endpoint) {
+			return false
+		}
+	}
+	return true
+}
+
+// isRewindable returns true if the request could be sent again.
+func isRewindable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+func (t *Retry) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+// backoff returns the delay to wait before the next attempt.
+func (t *Retry) backoff(attempt int, res *http.Response) time.Duration {
+	maxDelay := t.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = DefaultRetryMaxDelay
+	}
+
+	if res != nil {
+		if d, ok := retryAfter(res.Header.Get("Retry-After")); ok {
+			if d > maxDelay {
+				return maxDelay
+			}
+			return d
+		}
+	}
+
+	delay := t.BaseDelay << uint(attempt)
+	if delay <= 0 || delay > maxDelay {
+		delay = maxDelay
+	}
+	// Use a jitter between 50% and 100% of the delay to spread concurrent attempts.
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// shouldRetry returns true if the request has failed with a transient error. Requests that are not idempotent
+// are only retried if they have not been processed.
+func (t *Retry) shouldRetry(req *http.Request, res *http.Response, err error) bool {
+	if req.Context().Err() != nil {
+		return false
+	}
+	if err != nil {
+		return IsIdempotent(req)
+	}
+	if res.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	if res.StatusCode >= 500 && res.StatusCode != http.StatusNotImplemented {
+		return IsIdempotent(req)
+	}
+	return res.StatusCode >= 400 && res.StatusCode < 500 && t.hasRetryableMessage(res)
+}
+
+// hasRetryableMessage returns true if the body of the response contains one of the RetryableMessages.
+// The body is read, so it's replaced by a copy, and it could still be read if the response is returned.
+func (t *Retry) hasRetryableMessage(res *http.Response) bool {
+	if len(t.RetryableMessages) == 0 {
+		return false
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(res.Body, maxMatchedBodySize))
+	res.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(b), res.Body), Closer: res.Body}
+	if err != nil {
+		return false
+	}
+	for _, m := range t.RetryableMessages {
+		if m != "" && strings.Contains(string(b), m) {
+			return true
+		}
+	}
+	return false
+}
+
+// readCloser is the body of a response whose beginning has been read already.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// retryAfter parses the value of a Retry-After header, as seconds or as an HTTP date.
+func retryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		d := time.Until(date)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
@@ -0,0 +1,222 @@
+package transport_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/transport"
+)
+
+// newMockedServer returns a server replying with the given status codes, in order.
+// The last status code is used once all of them have been sent.
+func newMockedServer(t *testing.T, calls *int32, codes ...int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(calls, 1)) - 1
+		if n >= len(codes) {
+			n = len(codes) - 1
+		}
+		if r.Body != nil {
+			b, _ := ioutil.ReadAll(r.Body)
+			if r.Method == "POST" && string(b) != "payload" {
+				t.Errorf("want: %s, got: %s", "payload", string(b))
+			}
+		}
+		w.WriteHeader(codes[n])
+	}))
+}
+
+func TestRetry_RoundTrip(t *testing.T) {
+	testCases := []struct {
+		name       string
+		codes      []int
+		maxRetries int
+		wantStatus int
+		wantCalls  int32
+	}{
+		{"Should retry on 429", []int{429, 200}, 4, 200, 2},
+		{"Should retry on 5xx", []int{500, 502, 503, 504, 200}, 4, 200, 5},
+		{"Should not retry on 400", []int{400, 200}, 4, 400, 1},
+		{"Should not retry on 401", []int{401, 200}, 4, 401, 1},
+		{"Should not retry on 403", []int{403, 200}, 4, 403, 1},
+		{"Should not retry on 404", []int{404, 200}, 4, 404, 1},
+		{"Should not retry on 501", []int{501, 200}, 4, 501, 1},
+		{"Should stop after MaxRetries", []int{503}, 2, 503, 3},
+		{"Should not retry if MaxRetries is 0", []int{503, 200}, 0, 503, 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls int32
+			srv := newMockedServer(t, &calls, tc.codes...)
+			defer srv.Close()
+
+			client := &http.Client{Transport: transport.NewRetry(nil, tc.maxRetries, time.Millisecond)}
+			res, err := client.Post(srv.URL, "text/plain", strings.NewReader("payload"))
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			_ = res.Body.Close()
+
+			if res.StatusCode != tc.wantStatus {
+				t.Errorf("want: %d, got: %d", tc.wantStatus, res.StatusCode)
+			}
+			if got := atomic.LoadInt32(&calls); got != tc.wantCalls {
+				t.Errorf("want: %d calls, got: %d", tc.wantCalls, got)
+			}
+		})
+	}
+}
+
+func TestRetry_RoundTripNotIdempotent(t *testing.T) {
+	testCases := []struct {
+		name      string
+		path      string
+		codes     []int
+		drop      bool
+		wantCalls int32
+	}{
+		{"Should not retry media items creation on 5xx", "/v1/mediaItems:batchCreate", []int{500, 200}, false, 1},
+		{"Should not retry media items creation on connection errors", "/v1/mediaItems:batchCreate", []int{200}, true, 1},
+		{"Should retry media items creation on 429", "/v1/mediaItems:batchCreate", []int{429, 200}, false, 2},
+		{"Should not retry albums creation on 5xx", "/v1/albums", []int{503, 200}, false, 1},
+		{"Should not retry adding media items to albums on 5xx", "/v1/albums/album-1:batchAddMediaItems", []int{502, 200}, false, 1},
+		{"Should retry other requests on connection errors", "/v1/mediaItems:search", []int{200}, true, 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := int(atomic.AddInt32(&calls, 1)) - 1
+				if tc.drop && n == 0 {
+					// the request is processed, but its response is lost.
+					conn, _, err := w.(http.Hijacker).Hijack()
+					if err != nil {
+						t.Fatal(err)
+					}
+					_ = conn.Close()
+					return
+				}
+				if n >= len(tc.codes) {
+					n = len(tc.codes) - 1
+				}
+				w.WriteHeader(tc.codes[n])
+			}))
+			defer srv.Close()
+
+			client := &http.Client{Transport: transport.NewRetry(nil, 4, time.Millisecond)}
+			res, err := client.Post(srv.URL+tc.path, "application/json", strings.NewReader("payload"))
+			if err == nil {
+				_ = res.Body.Close()
+			}
+			if got := atomic.LoadInt32(&calls); got != tc.wantCalls {
+				t.Errorf("want: %d calls, got: %d", tc.wantCalls, got)
+			}
+		})
+	}
+}
+
+func TestRetry_RoundTripHonorsRetryAfter(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: transport.NewRetry(nil, 4, time.Millisecond)}
+	start := time.Now()
+	res, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	_ = res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("want: %d, got: %d", http.StatusOK, res.StatusCode)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("want: a delay of at least 1s, got: %s", elapsed)
+	}
+}
+
+func TestRetry_RoundTripNotRewindableBody(t *testing.T) {
+	var calls int32
+	srv := newMockedServer(t, &calls, 503, 200)
+	defer srv.Close()
+
+	client := &http.Client{Transport: transport.NewRetry(nil, 4, time.Millisecond)}
+	req, err := http.NewRequest("POST", srv.URL, ioutil.NopCloser(strings.NewReader("payload")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	_ = res.Body.Close()
+
+	if res.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("want: %d, got: %d", http.StatusServiceUnavailable, res.StatusCode)
+	}
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("want: %d calls, got: %d", 1, got)
+	}
+}
+
+func TestRetry_RoundTripRetryableMessages(t *testing.T) {
+	const message = `{"error":{"code":400,"message":"The upload is too large or has failed","status":"INVALID_ARGUMENT"}}`
+	testCases := []struct {
+		name      string
+		messages  []string
+		status    int
+		wantCalls int32
+	}{
+		{"Should retry on 400 with a configured message", []string{"too large or has failed"}, 400, 2},
+		{"Should not retry on 400 without a configured message", []string{"try again later"}, 400, 1},
+		{"Should not retry on 400 without configured messages", nil, 400, 1},
+		{"Should not retry on 501 with a configured message", []string{"too large or has failed"}, 501, 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&calls, 1) == 1 {
+					w.WriteHeader(tc.status)
+					_, _ = w.Write([]byte(message))
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer srv.Close()
+
+			rt := transport.NewRetry(nil, 4, time.Millisecond)
+			rt.RetryableMessages = tc.messages
+			client := &http.Client{Transport: rt}
+			res, err := client.Get(srv.URL)
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			b, _ := ioutil.ReadAll(res.Body)
+			_ = res.Body.Close()
+
+			if got := atomic.LoadInt32(&calls); got != tc.wantCalls {
+				t.Errorf("want: %d calls, got: %d", tc.wantCalls, got)
+			}
+			// the body of the returned response is kept, even if it has been matched.
+			if tc.wantCalls == 1 && string(b) != message {
+				t.Errorf("want: %s, got: %s", message, b)
+			}
+		})
+	}
+}
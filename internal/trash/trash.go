@@ -0,0 +1,30 @@
+// Package trash moves files to the trash of the operating system, so they could be restored later, instead of
+// removing them. It follows the FreeDesktop.org trash specification on Linux and FreeBSD, and uses the trash of
+// the user on macOS. Other platforms are not supported.
+package trash
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrUnsupported is returned when files could not be moved to the trash on this platform.
+var ErrUnsupported = errors.New("trash is not supported on this platform")
+
+// now returns the current time, the deletion date of the trashed files.
+// Useful for testing.
+var now = time.Now
+
+// Supported returns ErrUnsupported if files could not be moved to the trash on this platform.
+func Supported() error {
+	return supported()
+}
+
+// Bin moves files to the trash of the user.
+type Bin struct{}
+
+// Trash moves the file to the trash. Files with the same name already in the trash are kept, a numeric
+// suffix is appended to the name of the trashed one instead, e.g. "photo_1.jpg".
+func (Bin) Trash(path string) error {
+	return moveToTrash(path)
+}
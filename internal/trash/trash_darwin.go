@@ -0,0 +1,49 @@
+//go:build darwin
+// +build darwin
+
+package trash
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+func supported() error {
+	return nil
+}
+
+// moveToTrash moves the file to the trash of the user, in ~/.Trash. Files in other volumes could not be
+// moved, since they are moved by renaming them.
+func moveToTrash(path string) error {
+	if _, err := os.Lstat(path); err != nil {
+		return err
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(home, ".Trash")
+
+	base := filepath.Base(path)
+	ext := filepath.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	for i := 0; ; i++ {
+		name := base
+		if i > 0 {
+			name = fmt.Sprintf("%s_%d%s", stem, i, ext)
+		}
+		dst := filepath.Join(dir, name)
+		if _, err := os.Lstat(dst); err == nil {
+			continue
+		}
+		err := os.Rename(path, dst)
+		if errors.Is(err, syscall.EXDEV) {
+			return fmt.Errorf("file '%s' is not in the volume of the trash: %w", path, err)
+		}
+		return err
+	}
+}
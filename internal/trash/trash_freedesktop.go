@@ -0,0 +1,143 @@
+//go:build linux || freebsd
+// +build linux freebsd
+
+package trash
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// deletionDateLayout is the layout of the DeletionDate of the trash info files, in local time.
+const deletionDateLayout = "2006-01-02T15:04:05"
+
+func supported() error {
+	return nil
+}
+
+// moveToTrash moves the file to the home trash or, if it's in another file system, to the trash at the top
+// directory of its file system, since files are moved by renaming them.
+func moveToTrash(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Lstat(abs); err != nil {
+		return err
+	}
+
+	home, err := homeTrash()
+	if err != nil {
+		return err
+	}
+	err = trashIn(home, abs, abs)
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	top, err := topDir(abs)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(top, abs)
+	if err != nil {
+		return err
+	}
+	return trashIn(filepath.Join(top, fmt.Sprintf(".Trash-%d", os.Getuid())), abs, rel)
+}
+
+// homeTrash returns the trash of the user, in $XDG_DATA_HOME or ~/.local/share.
+func homeTrash() (string, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "Trash"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", "Trash"), nil
+}
+
+// trashIn moves the file at path to the trash dir, writing its info file with infoPath as the original path.
+// The info file is created first, so concurrent trashing of files with the same name don't collide.
+func trashIn(dir string, path string, infoPath string) error {
+	files, info := filepath.Join(dir, "files"), filepath.Join(dir, "info")
+	for _, d := range []string{files, info} {
+		if err := os.MkdirAll(d, 0700); err != nil {
+			return err
+		}
+	}
+
+	base := filepath.Base(path)
+	ext := filepath.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	for i := 0; ; i++ {
+		name := base
+		if i > 0 {
+			name = fmt.Sprintf("%s_%d%s", stem, i, ext)
+		}
+		infoFile := filepath.Join(info, name+".trashinfo")
+		f, err := os.OpenFile(infoFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		// files without info file are not overwritten either.
+		if _, err := os.Lstat(filepath.Join(files, name)); err == nil {
+			f.Close()
+			_ = os.Remove(infoFile)
+			continue
+		}
+
+		_, err = fmt.Fprintf(f, "[Trash Info]\nPath=%s\nDeletionDate=%s\n", (&url.URL{Path: infoPath}).EscapedPath(), now().Format(deletionDateLayout))
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Rename(path, filepath.Join(files, name))
+		}
+		if err != nil {
+			_ = os.Remove(infoFile)
+		}
+		return err
+	}
+}
+
+// topDir returns the top directory of the file system of the file, the one with its own trash.
+func topDir(path string) (string, error) {
+	dev, err := device(path)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Dir(path)
+	for {
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir, nil
+		}
+		d, err := device(parent)
+		if err != nil {
+			return "", err
+		}
+		if d != dev {
+			return dir, nil
+		}
+		dir = parent
+	}
+}
+
+// device returns the ID of the device of the file system containing the file.
+func device(path string) (uint64, error) {
+	var st syscall.Stat_t
+	if err := syscall.Stat(path, &st); err != nil {
+		return 0, err
+	}
+	return uint64(st.Dev), nil
+}
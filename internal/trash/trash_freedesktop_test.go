@@ -0,0 +1,80 @@
+//go:build linux || freebsd
+// +build linux freebsd
+
+package trash
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBin_Trash(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trash")
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	t.Setenv("XDG_DATA_HOME", filepath.Join(dir, "data"))
+	now = func() time.Time { return time.Date(2023, 7, 15, 14, 22, 33, 0, time.Local) }
+	defer func() { now = time.Now }()
+
+	photos := filepath.Join(dir, "my photos")
+	if err := os.MkdirAll(photos, 0700); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	// both files have the same name, so the second one is renamed in the trash.
+	for i, content := range []string{"first", "second"} {
+		path := filepath.Join(photos, "IMG_0001.jpg")
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+		if err := (Bin{}).Trash(path); err != nil {
+			t.Fatalf("error was not expected at this point: %s, file: %d", err, i)
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("want: file moved to the trash, got: %v", err)
+		}
+	}
+
+	trash := filepath.Join(dir, "data", "Trash")
+	testCases := []struct {
+		name    string
+		content string
+	}{
+		{"IMG_0001.jpg", "first"},
+		{"IMG_0001_1.jpg", "second"},
+	}
+	for _, tc := range testCases {
+		b, err := ioutil.ReadFile(filepath.Join(trash, "files", tc.name))
+		if err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+		if string(b) != tc.content {
+			t.Errorf("want: %s, got: %s", tc.content, b)
+		}
+		info, err := ioutil.ReadFile(filepath.Join(trash, "info", tc.name+".trashinfo"))
+		if err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+		want := "[Trash Info]\nPath=" + filepath.ToSlash(dir) + "/my%20photos/IMG_0001.jpg\nDeletionDate=2023-07-15T14:22:33\n"
+		if string(info) != want {
+			t.Errorf("want: %q, got: %q", want, info)
+		}
+	}
+}
+
+func TestBin_TrashMissingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trash")
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	t.Setenv("XDG_DATA_HOME", filepath.Join(dir, "data"))
+
+	if err := (Bin{}).Trash(filepath.Join(dir, "missing.jpg")); !os.IsNotExist(err) {
+		t.Errorf("want: %v, got: %v", os.ErrNotExist, err)
+	}
+}
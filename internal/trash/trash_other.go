@@ -0,0 +1,12 @@
+//go:build !linux && !freebsd && !darwin
+// +build !linux,!freebsd,!darwin
+
+package trash
+
+func supported() error {
+	return ErrUnsupported
+}
+
+func moveToTrash(path string) error {
+	return ErrUnsupported
+}
@@ -0,0 +1,50 @@
+// Package units parses the sizes of the configuration, like "200MB" or "8MiB".
+package units
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var multipliers = map[string]int64{
+	"":    1,
+	"B":   1,
+	"KB":  1000,
+	"MB":  1000 * 1000,
+	"GB":  1000 * 1000 * 1000,
+	"KIB": 1024,
+	"MIB": 1024 * 1024,
+	"GIB": 1024 * 1024 * 1024,
+}
+
+// ParseSize returns the number of bytes of a size like "200MB", "8MiB" or "1000".
+// Units are case insensitive. KB, MB and GB are multiples of 1000, KiB, MiB and GiB of 1024.
+// An empty value or "0" returns 0. Rates, like "2MB/s", are not sizes and are rejected.
+func ParseSize(value string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	if s == "" {
+		return 0, nil
+	}
+	if strings.HasSuffix(s, "/S") {
+		return 0, fmt.Errorf("invalid size '%s': it's a rate, not a size", value)
+	}
+
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i < 0 {
+		i = len(s)
+	}
+	number, unit := s[:i], strings.TrimSpace(s[i:])
+
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size '%s'", value)
+	}
+	multiplier, ok := multipliers[unit]
+	if !ok {
+		return 0, fmt.Errorf("invalid size '%s': unknown unit '%s'", value, unit)
+	}
+	return int64(n * float64(multiplier)), nil
+}
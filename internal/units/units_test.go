@@ -0,0 +1,46 @@
+package units_test
+
+import (
+	"testing"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/units"
+)
+
+func TestParseSize(t *testing.T) {
+	testCases := []struct {
+		name          string
+		input         string
+		want          int64
+		isErrExpected bool
+	}{
+		{"Should return 0 if empty", "", 0, false},
+		{"Should return 0 if zero", "0", 0, false},
+		{"Should parse bytes", "1000", 1000, false},
+		{"Should parse B", "1000B", 1000, false},
+		{"Should parse KB", "500KB", 500 * 1000, false},
+		{"Should parse GB", "2GB", 2 * 1000 * 1000 * 1000, false},
+		{"Should parse decimal MB", "1.5MB", 1500 * 1000, false},
+		{"Should parse MiB", "8MiB", 8 * 1024 * 1024, false},
+		{"Should parse lowercase units", "2mb", 2 * 1000 * 1000, false},
+		{"Should parse units with spaces", " 2 MB ", 2 * 1000 * 1000, false},
+		{"Should fail if it's a rate", "2GB/s", 0, true},
+		{"Should fail if unit is unknown", "2XB", 0, true},
+		{"Should fail if number is invalid", "big", 0, true},
+		{"Should fail if number is negative", "-1MB", 0, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := units.ParseSize(tc.input)
+			if tc.isErrExpected && err == nil {
+				t.Fatalf("error was expected, but not produced")
+			}
+			if !tc.isErrExpected && err != nil {
+				t.Fatalf("error was not expected, err: %s", err)
+			}
+			if got != tc.want {
+				t.Errorf("want: %d, got: %d", tc.want, got)
+			}
+		})
+	}
+}
@@ -1,10 +1,63 @@
 package upload
 
 import (
+	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
+	"time"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/exif"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/filter"
 )
 
+// AlbumFilter is an album where files allowed by its filter are added.
+type AlbumFilter struct {
+	Name   string
+	Filter FileFilterer
+}
+
+// NewAlbumFilter returns the album filter compiling the include and exclude patterns.
+// The error references the album name.
+func NewAlbumFilter(name string, includePatterns []string, excludePatterns []string) (AlbumFilter, error) {
+	f, err := filter.Compile(includePatterns, excludePatterns)
+	if err != nil {
+		return AlbumFilter{}, fmt.Errorf("filter of album '%s' is invalid: %s", name, err)
+	}
+	return AlbumFilter{Name: name, Filter: f}, nil
+}
+
+// routedAlbumName returns the first album which filter allows the file, path is relative to SourceFolder.
+// It returns false if Albums are set, and none of them allows the file.
+func (job *UploadFolderJob) routedAlbumName(path string) (string, bool) {
+	if len(job.Albums) == 0 {
+		return "", true
+	}
+	for _, album := range job.Albums {
+		if album.Filter.IsAllowed(path) {
+			return album.Name, true
+		}
+	}
+	return "", false
+}
+
+// fileAlbumName returns Album name of a file based on the configured parameter.
+// fp is the file path, path is relative to SourceFolder, modTime is the file modification time and md its metadata.
+func (job *UploadFolderJob) fileAlbumName(fp string, path string, modTime time.Time, md *fileMetadata) string {
+	if job.AlbumNameTemplate != nil {
+		// the template has been checked already, files are not added to any album if it fails anyway.
+		name, _ := job.AlbumNameTemplate.Name(path, job.fileDate(fp, modTime, md))
+		return name
+	}
+	switch job.CreateAlbums {
+	case "exifDate":
+		return job.albumNameUsingDate(fp, modTime, md)
+	case "mediaType":
+		return job.albumNameUsingMediaType(fp)
+	}
+	return job.albumName(path)
+}
+
 // albumName returns Album name based on the configured parameter.
 // If configuration option is "Off" or "", it returns empty string.
 func (job *UploadFolderJob) albumName(path string) string {
@@ -12,28 +65,144 @@ func (job *UploadFolderJob) albumName(path string) string {
 	case "Off":
 		return ""
 	case "folderPath":
-		return albumNameUsingFolderPath(path)
+		return albumNameUsingFolderPath(path, job.albumPathSeparator())
 	case "folderName":
+		if job.AlbumHierarchySeparator != "" {
+			return albumNameUsingHierarchy(path, job.AlbumHierarchySeparator, job.AlbumHierarchyDepth)
+		}
 		return albumNameUsingFolderName(path)
 	default:
 		panic("invalid CreateAlbums parameter")
 	}
 }
 
-// albumNameUsingFolderPath returns an AlbumID name using the full Path of the given folder.
-func albumNameUsingFolderPath(path string) string {
+// DefaultAlbumDateFormat is the layout of album names using dates: one album per month, e.g. "2023-07".
+const DefaultAlbumDateFormat = "2006-01"
+
+// albumNameUsingDate returns an AlbumID name using the date when the photo was taken, from its EXIF metadata.
+// If it's missing or could not be read, it uses the modification time of the file.
+func (job *UploadFolderJob) albumNameUsingDate(fp string, modTime time.Time, md *fileMetadata) string {
+	layout := DefaultAlbumDateFormat
+	if job.AlbumDateFormat != "" {
+		layout = job.AlbumDateFormat
+	}
+
+	return job.fileDate(fp, modTime, md).Format(layout)
+}
+
+// fileDate returns the date when the photo was taken, or modTime if it could not be read.
+func (job *UploadFolderJob) fileDate(fp string, modTime time.Time, md *fileMetadata) time.Time {
+	date, err := job.captureTime(fp, md)
+	if err != nil || date.IsZero() {
+		return modTime
+	}
+	return date
+}
+
+// CaptureDate returns the date when the photo was taken, derived like the date of albums when CreateAlbums is
+// exifDate: from its name, if FilenameDate is set and it matches, or its EXIF metadata, or its modification time
+// otherwise. It returns false if the file could not be read.
+func (job *UploadFolderJob) CaptureDate(fp string) (time.Time, bool) {
+	fi, err := os.Stat(fp)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return job.fileDate(fp, fi.ModTime(), job.newFileMetadata(fp)), true
+}
+
+// captureTime returns the date when the photo was taken, from its name, if FilenameDate is set and it matches,
+// or from the CaptureTimeReader, if it's set, or its metadata.
+func (job *UploadFolderJob) captureTime(fp string, md *fileMetadata) (time.Time, error) {
+	if job.FilenameDate != nil {
+		if date, ok := job.FilenameDate.Date(fp); ok {
+			return date, nil
+		}
+	}
+	if job.CaptureTimeReader != nil {
+		return job.CaptureTimeReader.CaptureTime(fp)
+	}
+	m, err := md.get()
+	return m.DateTimeOriginal, err
+}
+
+// fileMetadata reads the EXIF metadata of a file once, the first time it's needed.
+type fileMetadata struct {
+	reader MetadataReader
+	path   string
+
+	read bool
+	md   exif.Metadata
+	err  error
+}
+
+// newFileMetadata returns the metadata of the file, read by the job MetadataReader, or exif.Reader{} if it's not set.
+func (job *UploadFolderJob) newFileMetadata(path string) *fileMetadata {
+	var reader MetadataReader = exif.Reader{}
+	if job.MetadataReader != nil {
+		reader = job.MetadataReader
+	}
+	return &fileMetadata{reader: reader, path: path}
+}
+
+func (m *fileMetadata) get() (exif.Metadata, error) {
+	if !m.read {
+		m.md, m.err = m.reader.Metadata(m.path)
+		m.read = true
+	}
+	return m.md, m.err
+}
+
+const (
+	// DefaultPhotoAlbum is the album of photos when album names use the media type.
+	DefaultPhotoAlbum = "Photos"
+	// DefaultVideoAlbum is the album of videos when album names use the media type.
+	DefaultVideoAlbum = "Videos"
+)
+
+// albumNameUsingMediaType returns the album of the file by its content type, detected with MIMEDetection like
+// for Limits. Files that are neither photos nor videos, or whose content type could not be detected, are added to OtherAlbum.
+func (job *UploadFolderJob) albumNameUsingMediaType(fp string) string {
+	mimeType, err := job.MIMEDetection.ContentType(fp)
+	switch {
+	case err != nil:
+		return job.OtherAlbum
+	case strings.HasPrefix(mimeType, "image/"):
+		if job.PhotoAlbum == "" {
+			return DefaultPhotoAlbum
+		}
+		return job.PhotoAlbum
+	case strings.HasPrefix(mimeType, "video/"):
+		if job.VideoAlbum == "" {
+			return DefaultVideoAlbum
+		}
+		return job.VideoAlbum
+	default:
+		return job.OtherAlbum
+	}
+}
+
+// DefaultAlbumPathSeparator is the separator of folder names when album names use the full folder path.
+const DefaultAlbumPathSeparator = "_"
+
+// albumPathSeparator returns the configured separator, or DefaultAlbumPathSeparator if it's not set.
+func (job *UploadFolderJob) albumPathSeparator() string {
+	if job.AlbumPathSeparator == "" {
+		return DefaultAlbumPathSeparator
+	}
+	return job.AlbumPathSeparator
+}
+
+// albumNameUsingFolderPath returns an AlbumID name using the full Path of the given folder,
+// joining folder names with the separator.
+func albumNameUsingFolderPath(path string, separator string) string {
 	p := filepath.Dir(path)
 	if p == "." {
 		return ""
 	}
 
-	p = strings.ReplaceAll(p, "/", "_")
-
 	// In path starts with '/' remove it before.
-	if p[0] == '_' {
-		return p[1:]
-	}
-	return p
+	p = strings.TrimPrefix(p, "/")
+	return strings.ReplaceAll(p, "/", separator)
 }
 
 // albumNameUsingFolderName returns an AlbumID name using the name of the given folder.
@@ -44,3 +213,33 @@ func albumNameUsingFolderName(path string) string {
 	}
 	return filepath.Base(p)
 }
+
+// DefaultAlbumHierarchySeparator is the separator of folder names of the Hierarchy field of album name templates.
+const DefaultAlbumHierarchySeparator = " › "
+
+// hierarchySeparator returns the separator, or DefaultAlbumHierarchySeparator if it's not set.
+func hierarchySeparator(separator string) string {
+	if separator == "" {
+		return DefaultAlbumHierarchySeparator
+	}
+	return separator
+}
+
+// albumNameUsingHierarchy returns an AlbumID name using the names of the folders in the path of the given folder,
+// joined with the separator, e.g. "2023 › Italy › Rome". Empty folder names are skipped. If depth is greater than
+// zero, only the first depth folders are used, so the files of deeper folders are added to the album of their
+// ancestor.
+func albumNameUsingHierarchy(path string, separator string, depth int) string {
+	var names []string
+	for _, name := range strings.Split(filepath.ToSlash(filepath.Dir(path)), "/") {
+		name = strings.TrimSpace(name)
+		if name == "" || name == "." {
+			continue
+		}
+		if depth > 0 && len(names) == depth {
+			break
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, separator)
+}
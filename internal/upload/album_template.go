@@ -0,0 +1,95 @@
+package upload
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// AlbumTemplateData are the fields of a file that album name templates could reference, e.g. "{{.Year}}/{{.ParentDir}}".
+type AlbumTemplateData struct {
+	// Year, Month and Day are the date when the photo was taken, e.g. "2023", "07" and "15". The date is derived
+	// like when CreateAlbums is exifDate.
+	Year  string
+	Month string
+	Day   string
+
+	// ParentDir is the name of the folder of the file, empty if it's directly in SourceFolder.
+	ParentDir string
+	// Dir is the path of the folder of the file relative to SourceFolder, e.g. "Trips/2023-Italy", empty if it's
+	// directly in SourceFolder.
+	Dir string
+	// Hierarchy is the path of the folder of the file joined by HierarchySeparator, e.g. "Trips › 2023-Italy",
+	// limited to HierarchyDepth folders, empty if it's directly in SourceFolder.
+	Hierarchy string
+	// RelPath is the path of the file relative to SourceFolder, e.g. "Trips/2023-Italy/IMG_0001.jpg".
+	RelPath string
+	// Name is the name of the file without its extension, e.g. "IMG_0001".
+	Name string
+	// Ext is the extension of the file in lower case, without the dot, e.g. "jpg".
+	Ext string
+}
+
+// AlbumNameTemplate computes the album name of every file from a text/template, see AlbumTemplateData.
+type AlbumNameTemplate struct {
+	// HierarchySeparator joins the folder names of the Hierarchy field. Uses DefaultAlbumHierarchySeparator by default.
+	HierarchySeparator string
+	// HierarchyDepth, if it's greater than zero, is the maximum number of folders of the Hierarchy field.
+	HierarchyDepth int
+
+	tmpl *template.Template
+}
+
+// NewAlbumNameTemplate returns the AlbumNameTemplate parsing the text. It returns error if it could not be
+// parsed, or it references fields that AlbumTemplateData doesn't have.
+func NewAlbumNameTemplate(text string) (*AlbumNameTemplate, error) {
+	tmpl, err := template.New("album").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	t := &AlbumNameTemplate{tmpl: tmpl}
+	// unknown fields are only reported when the template is executed.
+	sample := newAlbumTemplateData("Trips/2023-Italy/IMG_0001.jpg", time.Date(2023, 7, 15, 14, 22, 33, 0, time.Local))
+	if _, err := t.execute(sample); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Name returns the album name of the file, path is relative to SourceFolder and date is when the photo was taken.
+// Leading and trailing spaces are removed, so files whose name is empty are not added to any album.
+func (t *AlbumNameTemplate) Name(path string, date time.Time) (string, error) {
+	data := newAlbumTemplateData(path, date)
+	data.Hierarchy = albumNameUsingHierarchy(data.RelPath, hierarchySeparator(t.HierarchySeparator), t.HierarchyDepth)
+	return t.execute(data)
+}
+
+func (t *AlbumNameTemplate) execute(data AlbumTemplateData) (string, error) {
+	var b strings.Builder
+	if err := t.tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// newAlbumTemplateData returns the fields of the file, path is relative to SourceFolder.
+func newAlbumTemplateData(path string, date time.Time) AlbumTemplateData {
+	relPath := strings.TrimPrefix(filepath.ToSlash(path), "/")
+	dir := filepath.ToSlash(filepath.Dir(relPath))
+	if dir == "." {
+		dir = ""
+	}
+	ext := filepath.Ext(relPath)
+	return AlbumTemplateData{
+		Year:      fmt.Sprintf("%04d", date.Year()),
+		Month:     fmt.Sprintf("%02d", date.Month()),
+		Day:       fmt.Sprintf("%02d", date.Day()),
+		ParentDir: albumNameUsingFolderName(relPath),
+		Dir:       dir,
+		RelPath:   relPath,
+		Name:      strings.TrimSuffix(filepath.Base(relPath), ext),
+		Ext:       strings.ToLower(strings.TrimPrefix(ext, ".")),
+	}
+}
@@ -0,0 +1,82 @@
+package upload_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/upload"
+)
+
+func TestAlbumNameTemplate_Name(t *testing.T) {
+	date := time.Date(2023, 7, 5, 14, 22, 33, 0, time.Local)
+	testCases := []struct {
+		name     string
+		template string
+		path     string
+		want     string
+	}{
+		{"Should use the year and the parent folder", "{{.Year}}/{{.ParentDir}}", "Trips/2023-Italy/IMG_0001.jpg", "2023/2023-Italy"},
+		{"Should use the padded month and day", "{{.Year}}-{{.Month}}-{{.Day}}", "IMG_0001.jpg", "2023-07-05"},
+		{"Should use the folder path", "{{.Dir}}", "Trips/2023-Italy/IMG_0001.jpg", "Trips/2023-Italy"},
+		{"Should use the folder hierarchy", "{{.Year}} › {{.Hierarchy}}", "Trips/2023-Italy/IMG_0001.jpg", "2023 › Trips › 2023-Italy"},
+		{"Should use the relative path", "{{.RelPath}}", "Trips/IMG_0001.jpg", "Trips/IMG_0001.jpg"},
+		{"Should use the name and the extension", "{{.Name}} ({{.Ext}})", "Trips/IMG_0001.JPG", "IMG_0001 (jpg)"},
+		{"Should support conditions", "{{if .ParentDir}}{{.ParentDir}}{{else}}Unsorted{{end}}", "IMG_0001.jpg", "Unsorted"},
+		{"Should be empty for files in the source folder", " {{.ParentDir}} ", "IMG_0001.jpg", ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpl, err := upload.NewAlbumNameTemplate(tc.template)
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			got, err := tmpl.Name(tc.path, date)
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			if tc.want != got {
+				t.Errorf("want: %q, got: %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestAlbumNameTemplate_NameHierarchy(t *testing.T) {
+	tmpl, err := upload.NewAlbumNameTemplate("{{.Hierarchy}}")
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	tmpl.HierarchySeparator = " / "
+	tmpl.HierarchyDepth = 2
+
+	got, err := tmpl.Name("2023//Italy/Rome/IMG_0001.jpg", time.Now())
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if want := "2023 / Italy"; want != got {
+		t.Errorf("want: %q, got: %q", want, got)
+	}
+}
+
+func TestNewAlbumNameTemplate(t *testing.T) {
+	testCases := []struct {
+		name     string
+		template string
+		errExp   bool
+	}{
+		{"Should parse a valid template", "{{.Year}}/{{.ParentDir}}", false},
+		{"Should fail if the template could not be parsed", "{{.Year}", true},
+		{"Should fail if the template references an unknown field", "{{.Folder}}", true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := upload.NewAlbumNameTemplate(tc.template)
+			if tc.errExp && err == nil {
+				t.Errorf("error was expected, but not produced")
+			}
+			if !tc.errExp && err != nil {
+				t.Errorf("error was not expected at this point: %s", err)
+			}
+		})
+	}
+}
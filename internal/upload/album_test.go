@@ -1,13 +1,20 @@
 package upload
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/exif"
 )
 
 func TestAlbumName(t *testing.T) {
 	var testData = []struct {
 		name         string
 		createAlbums string
+		separator    string
 
 		in   string
 		want string
@@ -30,12 +37,20 @@ func TestAlbumName(t *testing.T) {
 			in:           "/foo/bar/file.jpg",
 			want:         "foo_bar",
 		},
+		{
+			name:         "createAlbum_With_folderPath_And_Separator",
+			createAlbums: "folderPath",
+			separator:    " - ",
+			in:           "Trips/2023-Italy/file.jpg",
+			want:         "Trips - 2023-Italy",
+		},
 	}
 
 	for _, tt := range testData {
 		t.Run(tt.name, func(t *testing.T) {
 			job := UploadFolderJob{
-				CreateAlbums: tt.createAlbums,
+				CreateAlbums:       tt.createAlbums,
+				AlbumPathSeparator: tt.separator,
 			}
 			got := job.albumName(tt.in)
 			if got != tt.want {
@@ -60,7 +75,7 @@ func TestAlbumNameUsingFolderPath(t *testing.T) {
 		{in: "/foo/bar/", out: "foo_bar"},
 	}
 	for _, tt := range testData {
-		got := albumNameUsingFolderPath(tt.in)
+		got := albumNameUsingFolderPath(tt.in, DefaultAlbumPathSeparator)
 		if got != tt.out {
 			t.Errorf("albumNameUsingFolderPath for '%s' failed: expected '%s', got '%s'", tt.in, tt.out, got)
 		}
@@ -87,3 +102,200 @@ func TestAlbumNameUsingFolderName(t *testing.T) {
 		}
 	}
 }
+
+func TestAlbumNameUsingHierarchy(t *testing.T) {
+	var testData = []struct {
+		name  string
+		in    string
+		depth int
+		out   string
+	}{
+		{name: "ShouldJoinNestedFolders", in: "2023/Italy/Rome/IMG_0001.jpg", out: "2023 › Italy › Rome"},
+		{name: "ShouldUseTheFolderName", in: "2023/IMG_0001.jpg", out: "2023"},
+		{name: "ShouldBeEmptyInSourceFolder", in: "IMG_0001.jpg", out: ""},
+		{name: "ShouldSkipEmptySegments", in: "/2023//Italy/ /Rome/IMG_0001.jpg", out: "2023 › Italy › Rome"},
+		{name: "ShouldLimitTheDepth", in: "2023/Italy/Rome/IMG_0001.jpg", depth: 2, out: "2023 › Italy"},
+		{name: "ShouldNotLimitShallowerFolders", in: "2023/IMG_0001.jpg", depth: 2, out: "2023"},
+	}
+	for _, tt := range testData {
+		t.Run(tt.name, func(t *testing.T) {
+			job := UploadFolderJob{
+				CreateAlbums:            "folderName",
+				AlbumHierarchySeparator: DefaultAlbumHierarchySeparator,
+				AlbumHierarchyDepth:     tt.depth,
+			}
+			if got := job.albumName(tt.in); got != tt.out {
+				t.Errorf("want: %q, got: %q", tt.out, got)
+			}
+		})
+	}
+}
+
+// mockedCaptureTimeReader returns the dates by file path, or exif.ErrNotFound.
+type mockedCaptureTimeReader map[string]time.Time
+
+func (m mockedCaptureTimeReader) CaptureTime(path string) (time.Time, error) {
+	switch path {
+	case "corrupt.jpg":
+		return time.Time{}, exif.ErrCorrupt
+	}
+	t, ok := m[path]
+	if !ok {
+		return time.Time{}, exif.ErrNotFound
+	}
+	return t, nil
+}
+
+func TestAlbumNameUsingDate(t *testing.T) {
+	reader := mockedCaptureTimeReader{
+		"valid.jpg": time.Date(2023, 7, 14, 18, 30, 5, 0, time.Local),
+	}
+	modTime := time.Date(2021, 2, 3, 4, 5, 6, 0, time.Local)
+
+	var testData = []struct {
+		name   string
+		in     string
+		layout string
+		want   string
+	}{
+		{name: "ShouldUseExifDate", in: "valid.jpg", want: "2023-07"},
+		{name: "ShouldUseModTimeWithoutExif", in: "no-exif.jpg", want: "2021-02"},
+		{name: "ShouldUseModTimeWithCorruptExif", in: "corrupt.jpg", want: "2021-02"},
+		{name: "ShouldUseDateFormat", in: "valid.jpg", layout: "2006/01/02", want: "2023/07/14"},
+	}
+	for _, tt := range testData {
+		t.Run(tt.name, func(t *testing.T) {
+			job := UploadFolderJob{
+				CreateAlbums:      "exifDate",
+				AlbumDateFormat:   tt.layout,
+				CaptureTimeReader: reader,
+			}
+			got := job.fileAlbumName(tt.in, tt.in, modTime, job.newFileMetadata(tt.in))
+			if got != tt.want {
+				t.Errorf("albumName for '%s' failed: expected '%s', got '%s'", tt.in, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestAlbumNameUsingTemplate(t *testing.T) {
+	tmpl, err := NewAlbumNameTemplate("{{.Year}}/{{.ParentDir}}")
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	job := UploadFolderJob{
+		// the template overrides CreateAlbums.
+		CreateAlbums:      "folderName",
+		AlbumNameTemplate: tmpl,
+		CaptureTimeReader: mockedCaptureTimeReader{
+			"valid.jpg": time.Date(2023, 7, 14, 18, 30, 5, 0, time.Local),
+		},
+	}
+	modTime := time.Date(2021, 2, 3, 4, 5, 6, 0, time.Local)
+
+	var testData = []struct {
+		name string
+		fp   string
+		in   string
+		want string
+	}{
+		{name: "ShouldUseExifDate", fp: "valid.jpg", in: "Trips/valid.jpg", want: "2023/Trips"},
+		{name: "ShouldUseModTimeWithoutExif", fp: "no-exif.jpg", in: "Trips/no-exif.jpg", want: "2021/Trips"},
+	}
+	for _, tt := range testData {
+		t.Run(tt.name, func(t *testing.T) {
+			got := job.fileAlbumName(tt.fp, tt.in, modTime, job.newFileMetadata(tt.fp))
+			if got != tt.want {
+				t.Errorf("albumName for '%s' failed: expected '%s', got '%s'", tt.in, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestUploadFolderJob_CaptureDate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "capture-date")
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	modTime := time.Date(2021, 2, 3, 4, 5, 6, 0, time.Local)
+	for _, name := range []string{"20230715_142233.jpg", "exif.jpg", "scan.jpg"} {
+		fp := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(fp, []byte("photo"), 0600); err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+		if err := os.Chtimes(fp, modTime, modTime); err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+	}
+	filenameDate, err := NewFilenameDate("", "20060102_150405")
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	job := UploadFolderJob{
+		FilenameDate: filenameDate,
+		CaptureTimeReader: mockedCaptureTimeReader{
+			filepath.Join(dir, "exif.jpg"): time.Date(2022, 8, 1, 9, 0, 0, 0, time.Local),
+		},
+	}
+
+	var testData = []struct {
+		name   string
+		in     string
+		want   time.Time
+		wantOk bool
+	}{
+		{name: "ShouldUseFilenameDate", in: "20230715_142233.jpg", want: time.Date(2023, 7, 15, 14, 22, 33, 0, time.Local), wantOk: true},
+		{name: "ShouldUseExifDate", in: "exif.jpg", want: time.Date(2022, 8, 1, 9, 0, 0, 0, time.Local), wantOk: true},
+		{name: "ShouldUseModTimeWithoutExif", in: "scan.jpg", want: modTime, wantOk: true},
+		{name: "ShouldFailIfFileDoesNotExist", in: "non-existent.jpg", wantOk: false},
+	}
+	for _, tt := range testData {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := job.CaptureDate(filepath.Join(dir, tt.in))
+			if ok != tt.wantOk {
+				t.Fatalf("want: %t, got: %t", tt.wantOk, ok)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("want: %v, got: %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestAlbumNameUsingMediaType(t *testing.T) {
+	var testData = []struct {
+		name       string
+		in         string
+		photoAlbum string
+		videoAlbum string
+		otherAlbum string
+		detection  MIMEDetection
+		want       string
+	}{
+		{name: "ShouldRoutePhoto", in: "testdata/SampleJPGImage.jpg", want: "Photos"},
+		{name: "ShouldRouteVideo", in: "testdata/SampleVideo.mp4", want: "Videos"},
+		{name: "ShouldRouteToConfiguredAlbums", in: "testdata/SampleVideo.mp4", photoAlbum: "Camera", videoAlbum: "Clips", want: "Clips"},
+		{name: "ShouldNotRouteOtherFiles", in: "testdata/SampleText.txt", want: ""},
+		{name: "ShouldRouteOtherFiles", in: "testdata/SampleText.txt", otherAlbum: "Other", want: "Other"},
+		{name: "ShouldRouteNonExistentFile", in: "testdata/non-existent.jpg", otherAlbum: "Other", want: "Other"},
+		{name: "ShouldRouteByExtension", in: "testdata/SampleText.txt", detection: MIMEDetectionExtension, otherAlbum: "Other", want: "Other"},
+		{name: "ShouldRouteByExtensionWithoutReading", in: "testdata/non-existent.mp4", detection: MIMEDetectionExtension, want: "Videos"},
+	}
+	for _, tt := range testData {
+		t.Run(tt.name, func(t *testing.T) {
+			job := UploadFolderJob{
+				CreateAlbums:  "mediaType",
+				PhotoAlbum:    tt.photoAlbum,
+				VideoAlbum:    tt.videoAlbum,
+				OtherAlbum:    tt.otherAlbum,
+				MIMEDetection: tt.detection,
+			}
+			got := job.fileAlbumName(tt.in, tt.in, time.Now(), job.newFileMetadata(tt.in))
+			if got != tt.want {
+				t.Errorf("albumName for '%s' failed: expected '%s', got '%s'", tt.in, tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+//go:build linux
+// +build linux
+
+package upload
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// changeTime returns the last time the file was modified or its metadata changed, e.g. it was moved
+// or copied keeping its modification time. It's the modification time if it could not be read.
+func changeTime(fi os.FileInfo) time.Time {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fi.ModTime()
+	}
+	ctime := time.Unix(int64(st.Ctim.Sec), int64(st.Ctim.Nsec))
+	if ctime.After(fi.ModTime()) {
+		return ctime
+	}
+	return fi.ModTime()
+}
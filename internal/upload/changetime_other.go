@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package upload
+
+import (
+	"os"
+	"time"
+)
+
+// changeTime returns the modification time of the file, since the change time is not available.
+func changeTime(fi os.FileInfo) time.Time {
+	return fi.ModTime()
+}
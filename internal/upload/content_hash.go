@@ -0,0 +1,78 @@
+package upload
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"sync"
+	"time"
+)
+
+// ContentHashes hashes the content of the files with SHA-256, reading them like they are uploaded, see
+// FileItem.Open. The hash of every file is kept for the run, while its size and modification time don't change,
+// so checking its duplicates, sharing its upload token and tracking its content read it once.
+// It implements the Hasher of the file tracker. It's safe for concurrent use.
+type ContentHashes struct {
+	mu     sync.Mutex
+	hashes map[string]contentHash
+}
+
+// contentHash is the hash of a file, with the size and modification time of the file when it was hashed.
+type contentHash struct {
+	hash    string
+	size    int64
+	modTime time.Time
+}
+
+// NewContentHashes returns the ContentHashes without any file hashed.
+func NewContentHashes() *ContentHashes {
+	return &ContentHashes{hashes: make(map[string]contentHash)}
+}
+
+// Hash returns the hex encoded SHA-256 of the content of the file at path. The file is streamed, it's not
+// loaded into memory. A nil ContentHashes hashes the file every time.
+func (c *ContentHashes) Hash(path string) (string, error) {
+	fi, err := appFS.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if c != nil {
+		c.mu.Lock()
+		h, ok := c.hashes[path]
+		c.mu.Unlock()
+		if ok && h.size == fi.Size() && h.modTime.Equal(fi.ModTime()) {
+			return h.hash, nil
+		}
+	}
+
+	// files are hashed concurrently, only the cache is serialized.
+	hash, err := hashContent(path)
+	if err != nil {
+		return "", err
+	}
+	if c != nil {
+		c.mu.Lock()
+		c.hashes[path] = contentHash{hash: hash, size: fi.Size(), modTime: fi.ModTime()}
+		c.mu.Unlock()
+	}
+	return hash, nil
+}
+
+// hashContent returns the hex encoded SHA-256 of the content of the file at path.
+func hashContent(path string) (string, error) {
+	r, _, err := NewFileItem(path).Open()
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if c, ok := r.(io.Closer); ok {
+			_ = c.Close()
+		}
+	}()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,72 @@
+package upload
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+func TestContentHashes_Hash(t *testing.T) {
+	fs := &countingFs{Fs: afero.NewMemMapFs()}
+	setAppFS(t, fs)
+	if err := afero.WriteFile(appFS, "src/existent", []byte(testFileContent), 0644); err != nil {
+		t.Fatalf("error was not expected at this point: err=%s", err)
+	}
+	sum := sha256.Sum256([]byte(testFileContent))
+	want := hex.EncodeToString(sum[:])
+
+	hashes := NewContentHashes()
+	for i := 0; i < 3; i++ {
+		got, err := hashes.Hash("src/existent")
+		if err != nil {
+			t.Fatalf("error was not expected at this point: err=%s", err)
+		}
+		if want != got {
+			t.Errorf("want: %s, got: %s", want, got)
+		}
+	}
+	// the file is read once for the run.
+	if want := int64(len(testFileContent)); want != fs.read {
+		t.Errorf("want: %d, got: %d", want, fs.read)
+	}
+
+	// modified files are hashed again.
+	if err := afero.WriteFile(appFS, "src/existent", []byte("this is the new content"), 0644); err != nil {
+		t.Fatalf("error was not expected at this point: err=%s", err)
+	}
+	if err := appFS.Chtimes("src/existent", time.Now(), time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("error was not expected at this point: err=%s", err)
+	}
+	got, err := hashes.Hash("src/existent")
+	if err != nil {
+		t.Fatalf("error was not expected at this point: err=%s", err)
+	}
+	if got == want {
+		t.Errorf("want: the hash of the new content, got: %s", got)
+	}
+
+	if _, err := hashes.Hash("src/non-existent"); err == nil {
+		t.Errorf("error was expected, but not produced")
+	}
+}
+
+func TestContentHashes_HashNil(t *testing.T) {
+	fs := &countingFs{Fs: afero.NewMemMapFs()}
+	setAppFS(t, fs)
+	if err := afero.WriteFile(appFS, "src/existent", []byte(testFileContent), 0644); err != nil {
+		t.Fatalf("error was not expected at this point: err=%s", err)
+	}
+
+	var hashes *ContentHashes
+	for i := 0; i < 2; i++ {
+		if _, err := hashes.Hash("src/existent"); err != nil {
+			t.Fatalf("error was not expected at this point: err=%s", err)
+		}
+	}
+	if want := int64(2 * len(testFileContent)); want != fs.read {
+		t.Errorf("want: %d, got: %d", want, fs.read)
+	}
+}
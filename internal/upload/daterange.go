@@ -0,0 +1,68 @@
+package upload
+
+import (
+	"fmt"
+	"time"
+)
+
+// dateLayout is the layout of the dates without time, e.g. "2023-07-01".
+const dateLayout = "2006-01-02"
+
+// DateRange is a range of capture dates, both ends included. A zero Since or Until is unbounded.
+type DateRange struct {
+	Since time.Time
+	Until time.Time
+}
+
+// ParseDateRange returns the range between since and until, if they are set. They could be RFC 3339
+// times, e.g. "2023-07-01T10:00:00+02:00", or dates, e.g. "2023-07-01". Dates are in the local time zone,
+// like the EXIF capture dates, and until includes the whole day.
+func ParseDateRange(since string, until string) (DateRange, error) {
+	var r DateRange
+	var err error
+	if since != "" {
+		if r.Since, err = parseDate(since, false); err != nil {
+			return r, err
+		}
+	}
+	if until != "" {
+		if r.Until, err = parseDate(until, true); err != nil {
+			return r, err
+		}
+	}
+	if !r.Since.IsZero() && !r.Until.IsZero() && r.Until.Before(r.Since) {
+		return r, fmt.Errorf("invalid date range, '%s' is before '%s'", until, since)
+	}
+	return r, nil
+}
+
+// parseDate returns the time set by value. Dates are the start of the day, or its end if endOfDay is set.
+func parseDate(value string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation(dateLayout, value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date '%s', use the YYYY-MM-DD or RFC 3339 formats", value)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return t, nil
+}
+
+// IsZero returns true if the range is unbounded.
+func (r DateRange) IsZero() bool {
+	return r.Since.IsZero() && r.Until.IsZero()
+}
+
+// Contains returns true if t is in the range.
+func (r DateRange) Contains(t time.Time) bool {
+	if !r.Since.IsZero() && t.Before(r.Since) {
+		return false
+	}
+	if !r.Until.IsZero() && t.After(r.Until) {
+		return false
+	}
+	return true
+}
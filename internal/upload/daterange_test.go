@@ -0,0 +1,69 @@
+package upload_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/upload"
+)
+
+func TestParseDateRange(t *testing.T) {
+	var testCases = []struct {
+		name          string
+		since         string
+		until         string
+		want          upload.DateRange
+		isErrExpected bool
+	}{
+		{"Should be unbounded without dates", "", "", upload.DateRange{}, false},
+		{"Should parse dates in local time zone", "2023-07-01", "2023-07-31", upload.DateRange{
+			Since: time.Date(2023, 7, 1, 0, 0, 0, 0, time.Local),
+			Until: time.Date(2023, 7, 31, 23, 59, 59, 999999999, time.Local),
+		}, false},
+		{"Should parse RFC 3339 times", "2023-07-01T10:00:00Z", "", upload.DateRange{
+			Since: time.Date(2023, 7, 1, 10, 0, 0, 0, time.UTC),
+		}, false},
+		{"Should fail if date is invalid", "01/07/2023", "", upload.DateRange{}, true},
+		{"Should fail if until is before since", "2023-07-31", "2023-07-01", upload.DateRange{}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := upload.ParseDateRange(tc.since, tc.until)
+			if tc.isErrExpected {
+				if err == nil {
+					t.Errorf("error was expected, but not produced")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			if !got.Since.Equal(tc.want.Since) || !got.Until.Equal(tc.want.Until) {
+				t.Errorf("want: %+v, got: %+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestDateRange_Contains(t *testing.T) {
+	r, err := upload.ParseDateRange("2023-07-01", "2023-07-31")
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	var testCases = []struct {
+		date time.Time
+		want bool
+	}{
+		{time.Date(2023, 6, 30, 23, 59, 59, 0, time.Local), false},
+		{time.Date(2023, 7, 1, 0, 0, 0, 0, time.Local), true},
+		{time.Date(2023, 7, 31, 23, 59, 59, 0, time.Local), true},
+		{time.Date(2023, 8, 1, 0, 0, 0, 0, time.Local), false},
+	}
+	for _, tc := range testCases {
+		if got := r.Contains(tc.date); got != tc.want {
+			t.Errorf("want: %t, got: %t, date: %s", tc.want, got, tc.date)
+		}
+	}
+}
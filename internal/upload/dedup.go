@@ -0,0 +1,42 @@
+package upload
+
+import (
+	"sync"
+)
+
+// RunDedup detects the files with the same content than another file enqueued in the run,
+// so only one of them is uploaded. Files are identified by the SHA-256 of their content.
+// It's safe for concurrent use.
+type RunDedup struct {
+	// Hashes, if it's set, keeps the hashes of the files for the run, so the files checked for duplicates are not
+	// read again, e.g. to track their content.
+	Hashes *ContentHashes
+
+	mu sync.Mutex
+	// seen are the paths of the files enqueued in the run, by their content hash.
+	seen map[string]string
+}
+
+// NewRunDedup returns a RunDedup without any file enqueued.
+func NewRunDedup() *RunDedup {
+	return &RunDedup{seen: make(map[string]string)}
+}
+
+// Claim hashes the file and returns true if it should be enqueued: no other file with the same content
+// has been enqueued before. Otherwise, it returns the path of the file with the same content.
+// Claiming the same path again is not considered a duplicate.
+func (d *RunDedup) Claim(path string) (string, bool, error) {
+	// files are hashed concurrently, only the lookup is serialized.
+	hash, err := d.Hashes.Hash(path)
+	if err != nil {
+		return "", false, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if first, ok := d.seen[hash]; ok && first != path {
+		return first, false, nil
+	}
+	d.seen[hash] = path
+	return "", true, nil
+}
@@ -0,0 +1,97 @@
+package upload_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/upload"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRunDedup_Claim(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dedup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"IMG_0001.jpg":      "photo",
+		"IMG_0001 copy.jpg": "photo",
+		"IMG_0002.jpg":      "other photo",
+	})
+
+	d := upload.NewRunDedup()
+	var testCases = []struct {
+		name      string
+		want      bool
+		wantFirst string
+	}{
+		{"IMG_0001.jpg", true, ""},
+		{"IMG_0001 copy.jpg", false, "IMG_0001.jpg"},
+		{"IMG_0002.jpg", true, ""},
+		{"IMG_0001.jpg", true, ""},
+	}
+	for _, tc := range testCases {
+		first, got, err := d.Claim(filepath.Join(dir, tc.name))
+		if err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+		if got != tc.want {
+			t.Errorf("want: %t, got: %t, file: %s", tc.want, got, tc.name)
+		}
+		if tc.wantFirst != "" && first != filepath.Join(dir, tc.wantFirst) {
+			t.Errorf("want: %s, got: %s", filepath.Join(dir, tc.wantFirst), first)
+		}
+	}
+}
+
+func TestRunDedup_ClaimConcurrently(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dedup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := make(map[string]string)
+	for i := 0; i < 20; i++ {
+		files[fmt.Sprintf("IMG_%04d.jpg", i)] = "photo"
+	}
+	writeFiles(t, dir, files)
+
+	d := upload.NewRunDedup()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var enqueued []string
+	for name := range files {
+		wg.Add(1)
+		go func(path string) {
+			defer wg.Done()
+			if _, ok, err := d.Claim(path); err == nil && ok {
+				mu.Lock()
+				enqueued = append(enqueued, path)
+				mu.Unlock()
+			}
+		}(filepath.Join(dir, name))
+	}
+	wg.Wait()
+
+	if len(enqueued) != 1 {
+		t.Errorf("want: 1 file enqueued, got: %v", enqueued)
+	}
+}
+
+func TestRunDedup_ClaimNonExistent(t *testing.T) {
+	if _, _, err := upload.NewRunDedup().Claim("testdata/non-existent.jpg"); err == nil {
+		t.Errorf("error was expected, but not produced")
+	}
+}
@@ -0,0 +1,34 @@
+package upload
+
+import (
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// tooSmall returns true if the file at fp is an image narrower than MinWidth or shorter than MinHeight, as it's
+// displayed: the width and the height of the rotated images are swapped, given their EXIF orientation. Only the
+// header of the image is read. It returns an error if the file could not be opened.
+func (job *UploadFolderJob) tooSmall(fp string, md *fileMetadata) (bool, error) {
+	name, err := fsName(job.SourceFolder, fp)
+	if err != nil {
+		return false, err
+	}
+	f, err := job.fileSystem().Open(name)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	// files that are not images, or whose format is unknown, e.g. videos, have no dimensions.
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return false, nil
+	}
+	width, height := cfg.Width, cfg.Height
+	if m, err := md.get(); err == nil && m.Rotated() {
+		width, height = height, width
+	}
+	return width < job.MinWidth || height < job.MinHeight, nil
+}
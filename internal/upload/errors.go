@@ -0,0 +1,202 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
+)
+
+// Kinds of upload failures. Errors returned by ClassifyError match one of them with errors.Is.
+var (
+	ErrQuotaExceeded    = errors.New("quota exceeded")
+	ErrStorageFull      = errors.New("storage full")
+	ErrUnauthorized     = errors.New("not authorized")
+	ErrUnsupportedMedia = errors.New("unsupported media")
+	ErrNetwork          = errors.New("network failure")
+)
+
+// apiFailure is the HTTP status code and the message of a failed request, with the underlying error.
+// The status code is zero, and the message empty, if the request has not got a response.
+type apiFailure struct {
+	StatusCode int
+	Message    string
+	Err        error
+}
+
+func (e *apiFailure) describe(kind error) string {
+	if e.Err == nil {
+		return kind.Error()
+	}
+	return fmt.Sprintf("%s: %s", kind, e.Err)
+}
+
+// QuotaError is returned when the requests are throttled, or the daily quota of the Google Photos API has
+// been exceeded. Uploads should be attempted later.
+type QuotaError struct{ apiFailure }
+
+func (e *QuotaError) Error() string        { return e.describe(ErrQuotaExceeded) }
+func (e *QuotaError) Unwrap() error        { return e.Err }
+func (e *QuotaError) Is(target error) bool { return target == ErrQuotaExceeded }
+
+// StorageFullError is returned when the storage of the Google account is full. Other uploads would fail too,
+// until space is freed up or the storage is upgraded.
+type StorageFullError struct{ apiFailure }
+
+func (e *StorageFullError) Error() string        { return e.describe(ErrStorageFull) }
+func (e *StorageFullError) Unwrap() error        { return e.Err }
+func (e *StorageFullError) Is(target error) bool { return target == ErrStorageFull }
+
+// AuthError is returned when the request is not authorized, e.g. because the authorization has expired or
+// doesn't grant access to the album.
+type AuthError struct{ apiFailure }
+
+func (e *AuthError) Error() string        { return e.describe(ErrUnauthorized) }
+func (e *AuthError) Unwrap() error        { return e.Err }
+func (e *AuthError) Is(target error) bool { return target == ErrUnauthorized }
+
+// UnsupportedMediaError is returned when Google Photos rejects the file, e.g. because it's corrupt or its
+// content type is not supported. The upload would fail again if it's retried.
+type UnsupportedMediaError struct{ apiFailure }
+
+func (e *UnsupportedMediaError) Error() string        { return e.describe(ErrUnsupportedMedia) }
+func (e *UnsupportedMediaError) Unwrap() error        { return e.Err }
+func (e *UnsupportedMediaError) Is(target error) bool { return target == ErrUnsupportedMedia }
+
+// NetworkError is returned when Google Photos could not be reached, or it has failed to handle the request.
+// The upload could succeed if it's retried.
+type NetworkError struct{ apiFailure }
+
+func (e *NetworkError) Error() string        { return e.describe(ErrNetwork) }
+func (e *NetworkError) Unwrap() error        { return e.Err }
+func (e *NetworkError) Is(target error) bool { return target == ErrNetwork }
+
+// ClassifyError returns err as a StorageFullError, QuotaError, AuthError, UnsupportedMediaError or NetworkError,
+// given the HTTP response or the connection failure it wraps. Other errors, like cancellations or local failures
+// reading the file, are returned as is.
+func ClassifyError(err error) error {
+	if err == nil || isClassified(err) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+
+	if code, message, uploading, ok := responseOf(err); ok {
+		failure := apiFailure{StatusCode: code, Message: message, Err: err}
+		switch {
+		// a full storage is reported as an exceeded quota too, but it's not renewed.
+		case code == http.StatusInsufficientStorage || isStorageFullMessage(message):
+			return &StorageFullError{failure}
+		case code == http.StatusTooManyRequests || isQuotaMessage(message):
+			return &QuotaError{failure}
+		case code == http.StatusUnauthorized || code == http.StatusForbidden:
+			return &AuthError{failure}
+		// other requests, like creating the media item, fail with 400 Bad Request if the album is not valid.
+		case code == http.StatusBadRequest && uploading, code == http.StatusRequestEntityTooLarge, code == http.StatusUnsupportedMediaType:
+			return &UnsupportedMediaError{failure}
+		case code >= 500:
+			return &NetworkError{failure}
+		}
+		return err
+	}
+
+	var retrieveErr *oauth2.RetrieveError
+	if errors.As(err, &retrieveErr) {
+		failure := apiFailure{Message: string(retrieveErr.Body), Err: err}
+		if retrieveErr.Response != nil {
+			failure.StatusCode = retrieveErr.Response.StatusCode
+		}
+		return &AuthError{failure}
+	}
+	if isConnectionFailure(err) {
+		return &NetworkError{apiFailure{Err: err}}
+	}
+	// media items not created are reported by the status of the item, in a successful response.
+	if isStorageFullMessage(err.Error()) {
+		return &StorageFullError{apiFailure{Message: err.Error(), Err: err}}
+	}
+	return err
+}
+
+// IsAlreadyInAlbum returns true if adding media items to an album failed because they are in the album already,
+// so they have been added indeed. The API responds with 409 Conflict, or with a message saying so.
+func IsAlreadyInAlbum(err error) bool {
+	code, message, uploading, ok := responseOf(err)
+	if !ok || uploading {
+		return false
+	}
+	message = strings.ToLower(message)
+	return code == http.StatusConflict || strings.Contains(message, "already in album") ||
+		strings.Contains(message, "already_exists") || strings.Contains(message, "already exists")
+}
+
+// isClassified returns true if err is one of the upload failure types already.
+func isClassified(err error) bool {
+	return errors.Is(err, ErrStorageFull) || errors.Is(err, ErrQuotaExceeded) || errors.Is(err, ErrUnauthorized) ||
+		errors.Is(err, ErrUnsupportedMedia) || errors.Is(err, ErrNetwork)
+}
+
+// responseOf returns the HTTP status code and the message of the response that err reports, and true
+// if it's the response to the upload of the file content.
+func responseOf(err error) (code int, message string, uploading bool, ok bool) {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode, statusErr.Message, true, true
+	}
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		message := apiErr.Message
+		for _, item := range apiErr.Errors {
+			message += " " + item.Reason
+		}
+		return apiErr.Code, strings.TrimSpace(message), false, true
+	}
+	return 0, "", false, false
+}
+
+// isQuotaMessage returns true if the message of a response reports an exceeded quota, since Google
+// responds with 403 Forbidden too when the daily quota is exceeded.
+func isQuotaMessage(message string) bool {
+	message = strings.ToLower(message)
+	for _, s := range []string{"quota", "ratelimitexceeded", "resource_exhausted"} {
+		if strings.Contains(message, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// isStorageFullMessage returns true if the message reports that the storage of the account is full, e.g.
+// "The remaining storage in the user's account is not enough to perform this operation".
+func isStorageFullMessage(message string) bool {
+	message = strings.ToLower(message)
+	if !strings.Contains(message, "storage") {
+		return false
+	}
+	for _, s := range []string{"full", "not enough", "insufficient", "exceeded"} {
+		if strings.Contains(message, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// isConnectionFailure returns true if the request failed because the connection could not be established
+// or it was closed before getting the response. Errors returned by the transport before sending the
+// request, like an expired authorization, are not connection failures.
+func isConnectionFailure(err error) bool {
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return true
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
@@ -0,0 +1,122 @@
+package upload_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/upload"
+)
+
+func TestClassifyError(t *testing.T) {
+	dialErr := &url.Error{Op: "Post", URL: "https://photoslibrary.googleapis.com/v1/uploads", Err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}}
+	testCases := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"Should be a quota error if it's throttled", &upload.StatusError{StatusCode: http.StatusTooManyRequests, Status: "429 Too Many Requests"}, upload.ErrQuotaExceeded},
+		{"Should be a quota error if the quota is exceeded", &googleapi.Error{Code: http.StatusForbidden, Message: "Quota exceeded for quota metric 'All requests'"}, upload.ErrQuotaExceeded},
+		{"Should be a quota error by its reason", &googleapi.Error{Code: http.StatusForbidden, Errors: []googleapi.ErrorItem{{Reason: "rateLimitExceeded"}}}, upload.ErrQuotaExceeded},
+		{"Should be an auth error if it's unauthorized", &googleapi.Error{Code: http.StatusUnauthorized, Message: "Request had invalid authentication credentials."}, upload.ErrUnauthorized},
+		{"Should be an auth error if it's forbidden", &upload.StatusError{StatusCode: http.StatusForbidden, Status: "403 Forbidden"}, upload.ErrUnauthorized},
+		{"Should be an auth error if the token could not be retrieved", &oauth2.RetrieveError{Response: &http.Response{StatusCode: http.StatusUnauthorized}, Body: []byte("unauthorized_client")}, upload.ErrUnauthorized},
+		{"Should be an unsupported media error if the upload is rejected", &upload.StatusError{StatusCode: http.StatusBadRequest, Status: "400 Bad Request"}, upload.ErrUnsupportedMedia},
+		{"Should be an unsupported media error if the content type is not supported", &googleapi.Error{Code: http.StatusUnsupportedMediaType}, upload.ErrUnsupportedMedia},
+		{"Should be a network error if the server fails", &googleapi.Error{Code: http.StatusServiceUnavailable, Message: "The service is currently unavailable."}, upload.ErrNetwork},
+		{"Should be a network error if the connection fails", fmt.Errorf("uploading file: %w", dialErr), upload.ErrNetwork},
+		{"Should be a storage full error if the storage is insufficient", &googleapi.Error{Code: http.StatusInsufficientStorage}, upload.ErrStorageFull},
+		{"Should be a storage full error instead of a quota error", &googleapi.Error{Code: http.StatusForbidden, Message: "Storage quota exceeded"}, upload.ErrStorageFull},
+		{"Should be a storage full error by the status of the media item", errors.New("media item was not created: The remaining storage in the user's account is not enough to perform this operation."), upload.ErrStorageFull},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := upload.ClassifyError(tc.err)
+			if !errors.Is(got, tc.want) {
+				t.Errorf("want: %s, got: %v", tc.want, got)
+			}
+			if !errors.Is(got, tc.err) {
+				t.Errorf("want: %v wrapped, got: %v", tc.err, got)
+			}
+			if upload.ClassifyError(got) != got {
+				t.Errorf("classified errors were not expected to change")
+			}
+		})
+	}
+}
+
+func TestClassifyError_NotClassified(t *testing.T) {
+	testCases := []struct {
+		name string
+		err  error
+	}{
+		{"Should keep nil", nil},
+		{"Should keep cancellations", fmt.Errorf("uploading file: %w", &url.Error{Op: "Post", URL: "https://example.com", Err: context.Canceled})},
+		{"Should keep local failures", errors.New("open /photos/IMG_0001.jpg: permission denied")},
+		{"Should keep bad requests not uploading the content", &googleapi.Error{Code: http.StatusBadRequest, Message: "Invalid album ID"}},
+		{"Should keep other failures of the transport", &url.Error{Op: "Post", URL: "https://example.com", Err: errors.New("the authorization has expired")}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := upload.ClassifyError(tc.err); got != tc.err {
+				t.Errorf("want: %v, got: %v", tc.err, got)
+			}
+		})
+	}
+}
+
+func TestIsAlreadyInAlbum(t *testing.T) {
+	testCases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"Should be in the album if it conflicts", &googleapi.Error{Code: http.StatusConflict}, true},
+		{"Should be in the album by its message", fmt.Errorf("adding media items: %w", &googleapi.Error{Code: http.StatusBadRequest, Message: "Media item already in album"}), true},
+		{"Should be in the album by its reason", &googleapi.Error{Code: http.StatusBadRequest, Errors: []googleapi.ErrorItem{{Reason: "ALREADY_EXISTS"}}}, true},
+		{"Should not be in the album for other bad requests", &googleapi.Error{Code: http.StatusBadRequest, Message: "Invalid album ID"}, false},
+		{"Should not be in the album for uploads", &upload.StatusError{StatusCode: http.StatusConflict, Status: "409 Conflict"}, false},
+		{"Should not be in the album for local failures", errors.New("already in album"), false},
+		{"Should not be in the album without error", nil, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := upload.IsAlreadyInAlbum(tc.err); got != tc.want {
+				t.Errorf("want: %t, got: %t", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestClassifyError_As(t *testing.T) {
+	err := upload.ClassifyError(fmt.Errorf("creating upload session: %w", &upload.StatusError{
+		StatusCode: http.StatusTooManyRequests,
+		Status:     "429 Too Many Requests",
+		Message:    "RESOURCE_EXHAUSTED Quota exceeded",
+	}))
+
+	var quotaErr *upload.QuotaError
+	if !errors.As(err, &quotaErr) {
+		t.Fatalf("want: QuotaError, got: %T", err)
+	}
+	if quotaErr.StatusCode != http.StatusTooManyRequests || quotaErr.Message != "RESOURCE_EXHAUSTED Quota exceeded" {
+		t.Errorf("want: 429 RESOURCE_EXHAUSTED Quota exceeded, got: %d %s", quotaErr.StatusCode, quotaErr.Message)
+	}
+	var authErr *upload.AuthError
+	if errors.As(err, &authErr) {
+		t.Errorf("AuthError was not expected")
+	}
+	if want := "quota exceeded: creating upload session: unexpected response: 429 Too Many Requests: RESOURCE_EXHAUSTED Quota exceeded"; err.Error() != want {
+		t.Errorf("want: %s, got: %s", want, err)
+	}
+}
@@ -1,8 +1,14 @@
 package upload
 
 import (
+	"fmt"
 	"io"
+	"os"
 	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/spf13/afero"
 )
@@ -11,12 +17,31 @@ var (
 	// fs represents the filesystem to use. By default it uses functions based on `os` package.
 	// In testing, it uses a memory file system.
 	appFS = afero.NewOsFs()
+
+	// moveMu avoids concurrent moves to choose the same destination.
+	moveMu sync.Mutex
 )
 
 // FileItem represents a local file.
 type FileItem struct {
 	Path      string
 	AlbumName string
+	ModTime   time.Time
+
+	// MediaItemID, if it's set, is the media item of the file, that has been uploaded already. It's only
+	// added to the album.
+	MediaItemID string
+
+	// Account, if it's set, is the account where the file is uploaded, given by the route matching it. It's
+	// uploaded to the account of the job otherwise.
+	Account string
+
+	// LivePhotoOf, if it's set, is the photo of the Live Photo whose video is the file. Both are added to the
+	// same album.
+	LivePhotoOf string
+
+	// Favorite is true if the file is in the favorites folder, so it should be marked as favorite.
+	Favorite bool
 }
 
 func NewFileItem(path string) FileItem {
@@ -25,18 +50,19 @@ func NewFileItem(path string) FileItem {
 	}
 }
 
-// Open returns a stream.
+// Open returns a stream of the file, and its size, read from the opened file so they always match.
 // Caller should close it finally.
 func (m FileItem) Open() (io.ReadSeeker, int64, error) {
-	f, err := appFS.Stat(m.Path)
+	r, err := appFS.Open(m.Path)
 	if err != nil {
 		return nil, 0, err
 	}
-	r, err := appFS.Open(m.Path)
+	fi, err := r.Stat()
 	if err != nil {
+		_ = r.Close()
 		return nil, 0, err
 	}
-	return r, f.Size(), nil
+	return r, fi.Size(), nil
 }
 
 // Name returns the filename.
@@ -59,3 +85,67 @@ func (m FileItem) Size() int64 {
 func (m FileItem) Remove() error {
 	return appFS.Remove(m.Path)
 }
+
+// MoveTo moves the file to dst, creating its parent directories, and returns the final destination.
+// If dst already exists, a numeric suffix is appended to the filename, e.g. "photo_1.jpg",
+// so an existing file is never overwritten.
+func (m FileItem) MoveTo(dst string) (string, error) {
+	moveMu.Lock()
+	defer moveMu.Unlock()
+
+	if err := appFS.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return "", err
+	}
+
+	dst, err := availablePath(dst)
+	if err != nil {
+		return "", err
+	}
+
+	if err := appFS.Rename(m.Path, dst); err == nil {
+		return dst, nil
+	}
+
+	// Rename fails when moving across file systems, so the file is copied and removed.
+	if err := copyFile(m.Path, dst); err != nil {
+		return "", err
+	}
+	return dst, appFS.Remove(m.Path)
+}
+
+// availablePath returns the first path, starting from the given one, that doesn't exist.
+func availablePath(path string) (string, error) {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	candidate := path
+	for i := 1; ; i++ {
+		exist, err := afero.Exists(appFS, candidate)
+		if err != nil {
+			return "", err
+		}
+		if !exist {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s_%d%s", base, i, ext)
+	}
+}
+
+// copyFile copies the content of src to dst, that must not exist.
+func copyFile(src, dst string) error {
+	in, err := appFS.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := appFS.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		_ = appFS.Remove(dst)
+		return err
+	}
+	return out.Close()
+}
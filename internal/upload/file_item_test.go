@@ -6,6 +6,15 @@ import (
 	"github.com/spf13/afero"
 )
 
+// setAppFS sets the file system used by the package until the test has finished.
+func setAppFS(t *testing.T, fs afero.Fs) {
+	previous := appFS
+	t.Cleanup(func() {
+		appFS = previous
+	})
+	appFS = fs
+}
+
 func TestFileItem_Name(t *testing.T) {
 	var testCases = []struct {
 		in   string
@@ -53,7 +62,7 @@ func TestFileItem_Open(t *testing.T) {
 		{name: "ShouldReturnSuccessWhenFileExists", in: "src/existent", wantSize: 32, errExpected: false},
 	}
 
-	appFS = afero.NewMemMapFs()
+	setAppFS(t, afero.NewMemMapFs())
 	// create test files and directories
 	if err := appFS.MkdirAll("src/", 0755); err != nil {
 		t.Fatalf("error was not expected at this point: err=%s", err)
@@ -86,7 +95,7 @@ func TestFileItem_Size(t *testing.T) {
 		{name: "ShouldReturnSizeWhenFileExists", in: "src/existent", want: 32},
 	}
 
-	appFS = afero.NewMemMapFs()
+	setAppFS(t, afero.NewMemMapFs())
 	// create test files and directories
 	if err := appFS.MkdirAll("src/", 0755); err != nil {
 		t.Fatalf("error was not expected at this point: err=%s", err)
@@ -113,7 +122,7 @@ func TestFileItem_Remove(t *testing.T) {
 		{name: "ShouldReturnSuccessWhenFileExists", in: "src/existent", errExpected: false},
 	}
 
-	appFS = afero.NewMemMapFs()
+	setAppFS(t, afero.NewMemMapFs())
 	// create test files and directories
 	if err := appFS.MkdirAll("src/", 0755); err != nil {
 		t.Fatalf("error was not expected at this point: err=%s", err)
@@ -133,3 +142,61 @@ func TestFileItem_Remove(t *testing.T) {
 		}
 	}
 }
+
+func TestFileItem_MoveTo(t *testing.T) {
+	var testCases = []struct {
+		name        string
+		in          string
+		dst         string
+		existent    []string
+		want        string
+		errExpected bool
+	}{
+		{name: "ShouldMoveFile", in: "src/folder/photo.jpg", dst: "dst/folder/photo.jpg", want: "dst/folder/photo.jpg"},
+		{name: "ShouldAppendSuffixOnCollision", in: "src/folder/photo.jpg", dst: "dst/folder/photo.jpg", existent: []string{"dst/folder/photo.jpg"}, want: "dst/folder/photo_1.jpg"},
+		{name: "ShouldAppendNextSuffixOnCollision", in: "src/folder/photo.jpg", dst: "dst/folder/photo.jpg", existent: []string{"dst/folder/photo.jpg", "dst/folder/photo_1.jpg"}, want: "dst/folder/photo_2.jpg"},
+		{name: "ShouldFailIfFileDoesNotExist", in: "src/non-existent.jpg", dst: "dst/non-existent.jpg", errExpected: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			setAppFS(t, afero.NewMemMapFs())
+			if !tc.errExpected {
+				if err := afero.WriteFile(appFS, tc.in, []byte("photo"), 0644); err != nil {
+					t.Fatalf("error was not expected at this point: err=%s", err)
+				}
+			}
+			for _, f := range tc.existent {
+				if err := afero.WriteFile(appFS, f, []byte("existent"), 0644); err != nil {
+					t.Fatalf("error was not expected at this point: err=%s", err)
+				}
+			}
+
+			got, err := NewFileItem(tc.in).MoveTo(tc.dst)
+			if tc.errExpected {
+				if err == nil {
+					t.Fatalf("error was expected, but not happened")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error was not expected, err: %s", err)
+			}
+			if got != tc.want {
+				t.Errorf("want: %s, got: %s", tc.want, got)
+			}
+
+			if exist, _ := afero.Exists(appFS, tc.in); exist {
+				t.Errorf("source file should not exist after moving it: %s", tc.in)
+			}
+			if content, _ := afero.ReadFile(appFS, got); string(content) != "photo" {
+				t.Errorf("want: %s, got: %s", "photo", content)
+			}
+			for _, f := range tc.existent {
+				if content, _ := afero.ReadFile(appFS, f); string(content) != "existent" {
+					t.Errorf("existent file should not be overwritten: %s", f)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package upload
+
+import (
+	"os"
+)
+
+// fileKey identifies a file, but it's not available on this platform.
+type fileKey struct{}
+
+// fileID returns false, since the inode of the files is not available.
+func fileID(fi os.FileInfo) (fileKey, bool) {
+	return fileKey{}, false
+}
@@ -0,0 +1,24 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package upload
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileKey identifies a file by its device and inode.
+type fileKey struct {
+	dev uint64
+	ino uint64
+}
+
+// fileID returns the key of the file, or false if its inode could not be read.
+func fileID(fi os.FileInfo) (fileKey, bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileKey{}, false
+	}
+	return fileKey{dev: uint64(st.Dev), ino: uint64(st.Ino)}, true
+}
@@ -0,0 +1,70 @@
+package upload
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// FilenameDate derives the capture date of files from their name, e.g. "20230715_142233.jpg", instead of
+// reading their EXIF metadata, that could be slow on network mounts.
+type FilenameDate struct {
+	pattern *regexp.Regexp
+	layout  string
+}
+
+// NewFilenameDate returns the FilenameDate parsing the dates with the Go layout, e.g. "20060102_150405".
+// If pattern is set, the date is the first capturing group of the regular expression matching the file name,
+// or the whole match if it has no groups. Otherwise, the date is at the start of the file name.
+func NewFilenameDate(pattern string, layout string) (*FilenameDate, error) {
+	if layout == "" {
+		return nil, errors.New("layout could not be empty")
+	}
+	d := &FilenameDate{layout: layout}
+	if pattern == "" {
+		return d, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern '%s': %w", pattern, err)
+	}
+	if re.NumSubexp() > 1 {
+		return nil, fmt.Errorf("invalid pattern '%s': it could have one capturing group at most", pattern)
+	}
+	d.pattern = re
+	return d, nil
+}
+
+// Date returns the date in the name of the file, in the local time zone, like the EXIF capture dates.
+// It returns false if the name doesn't match the pattern, or the date could not be parsed.
+func (d *FilenameDate) Date(path string) (time.Time, bool) {
+	value, ok := d.value(filepath.Base(path))
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation(d.layout, value, time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// value returns the part of the file name with the date.
+func (d *FilenameDate) value(name string) (string, bool) {
+	if d.pattern == nil {
+		if len(name) < len(d.layout) {
+			return "", false
+		}
+		return name[:len(d.layout)], true
+	}
+	m := d.pattern.FindStringSubmatch(name)
+	switch len(m) {
+	case 0:
+		return "", false
+	case 1:
+		return m[0], true
+	}
+	return m[1], true
+}
@@ -0,0 +1,69 @@
+package upload_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/upload"
+)
+
+func TestFilenameDate_Date(t *testing.T) {
+	var testCases = []struct {
+		name    string
+		pattern string
+		layout  string
+		path    string
+		want    time.Time
+		wantOk  bool
+	}{
+		{"Should parse date at the start of the name", "", "20060102_150405", "/photos/20230715_142233.jpg", time.Date(2023, 7, 15, 14, 22, 33, 0, time.Local), true},
+		{"Should parse date of capturing group", `^IMG_(\d{8})_`, "20060102", "/photos/IMG_20230715_142233.jpg", time.Date(2023, 7, 15, 0, 0, 0, 0, time.Local), true},
+		{"Should parse whole match without capturing group", `\d{4}-\d{2}-\d{2}`, "2006-01-02", "/photos/Trip 2023-07-15.jpg", time.Date(2023, 7, 15, 0, 0, 0, 0, time.Local), true},
+		{"Should not match name without date", "", "20060102_150405", "/photos/IMG_0001.jpg", time.Time{}, false},
+		{"Should not match name shorter than layout", "", "20060102_150405", "/photos/a.jpg", time.Time{}, false},
+		{"Should not match name not matching pattern", `^IMG_(\d{8})_`, "20060102", "/photos/DSC_0001.jpg", time.Time{}, false},
+		{"Should not match invalid date", "", "20060102", "/photos/20231345_0001.jpg", time.Time{}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d, err := upload.NewFilenameDate(tc.pattern, tc.layout)
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			got, ok := d.Date(tc.path)
+			if ok != tc.wantOk {
+				t.Fatalf("want: %t, got: %t", tc.wantOk, ok)
+			}
+			if !got.Equal(tc.want) {
+				t.Errorf("want: %v, got: %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestNewFilenameDate(t *testing.T) {
+	var testCases = []struct {
+		name          string
+		pattern       string
+		layout        string
+		isErrExpected bool
+	}{
+		{"Should accept layout without pattern", "", "20060102", false},
+		{"Should fail without layout", `^(\d{8})`, "", true},
+		{"Should fail if pattern is invalid", `^(\d{8}`, "20060102", true},
+		{"Should fail if pattern has several capturing groups", `^(\d{8})_(\d{6})`, "20060102", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := upload.NewFilenameDate(tc.pattern, tc.layout)
+			if tc.isErrExpected && err == nil {
+				t.Fatalf("error was expected, but not produced")
+			}
+			if !tc.isErrExpected && err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+		})
+	}
+}
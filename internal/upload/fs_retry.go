@@ -0,0 +1,82 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"io"
+	"syscall"
+	"time"
+)
+
+// DefaultFSRetries is the number of times opening or reading a file is retried after a transient error.
+const DefaultFSRetries = 3
+
+// DefaultFSRetryDelay is the delay before the first retry of a transient file system error.
+const DefaultFSRetryDelay = 100 * time.Millisecond
+
+// FSRetry retries opening and reading files when the file system fails with a transient error, e.g. EAGAIN or EIO on
+// a network mount. It's distinct from the retries of the HTTP requests. Permanent errors, like a missing file or
+// a denied permission, are not retried. The zero value doesn't retry.
+type FSRetry struct {
+	// Retries is the maximum number of retries after the first attempt.
+	Retries int
+	// Delay is the delay before the first retry, it doubles on every attempt.
+	Delay time.Duration
+}
+
+// IsTransientFSError returns true if the file system error could not happen again if the operation is retried.
+func IsTransientFSError(err error) bool {
+	for _, errno := range []syscall.Errno{syscall.EAGAIN, syscall.EINTR, syscall.EIO, syscall.EBUSY, syscall.ETIMEDOUT, syscall.ESTALE} {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	return false
+}
+
+// do runs op, retrying it while it fails with a transient error. It returns the last error otherwise, or the context
+// error if it's done while waiting for the next attempt.
+func (r FSRetry) do(ctx context.Context, op func() error) error {
+	delay := r.Delay
+	for attempt := 0; ; attempt++ {
+		err := op()
+		if err == nil || attempt >= r.Retries || !IsTransientFSError(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// reader returns a reader of rd retrying the reads failing with a transient error. Reads returning some bytes
+// are not retried, the error is returned by the next one instead.
+func (r FSRetry) reader(ctx context.Context, rd io.Reader) io.Reader {
+	if r.Retries <= 0 {
+		return rd
+	}
+	return &retryingReader{ctx: ctx, r: rd, retry: r}
+}
+
+// retryingReader is a reader retrying the reads failing with a transient error, see FSRetry.
+type retryingReader struct {
+	ctx   context.Context
+	r     io.Reader
+	retry FSRetry
+}
+
+func (r *retryingReader) Read(b []byte) (int, error) {
+	var n int
+	err := r.retry.do(r.ctx, func() error {
+		var err error
+		n, err = r.r.Read(b)
+		if n > 0 && IsTransientFSError(err) {
+			return nil
+		}
+		return err
+	})
+	return n, err
+}
@@ -0,0 +1,206 @@
+package upload
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// WritableFS is a file system whose files could be removed and renamed, as deleting or moving the files
+// once they are uploaded requires. Names are relative to the root of the file system, like fs.FS ones.
+type WritableFS interface {
+	fs.FS
+	Remove(name string) error
+	Rename(oldname string, newname string) error
+}
+
+// fileSystem returns the file system of the source folder.
+func (job *UploadFolderJob) fileSystem() fs.FS {
+	if job.FS == nil {
+		return os.DirFS(job.SourceFolder)
+	}
+	return job.FS
+}
+
+// Writable returns true if the files of the source folder could be deleted or moved once they are
+// uploaded. Files of the local file system, used when FS is not set, are.
+func (job *UploadFolderJob) Writable() bool {
+	if job.FS == nil {
+		return true
+	}
+	_, ok := job.FS.(WritableFS)
+	return ok
+}
+
+// Walk walks the file tree at fp, in the source folder, like it's scanned, calling walkFn for each file or
+// directory: through FS, following symbolic links if FollowSymlinks is set, and without walking into symbolic
+// link loops.
+func (job *UploadFolderJob) Walk(fp string, walkFn filepath.WalkFunc) error {
+	name, err := fsName(job.SourceFolder, fp)
+	if err != nil {
+		return walkFn(fp, nil, err)
+	}
+	fsys, err := fs.Sub(job.fileSystem(), name)
+	if err != nil {
+		return walkFn(fp, nil, err)
+	}
+	return walkFS(fsys, fp, job.FollowSymlinks, walkFn)
+}
+
+// Stat returns the FileInfo of the file at fp, in the source folder, like it's walked: through FS, and
+// following symbolic links only if FollowSymlinks is set.
+func (job *UploadFolderJob) Stat(fp string) (os.FileInfo, error) {
+	name, err := fsName(job.SourceFolder, fp)
+	if err != nil {
+		return nil, err
+	}
+	if job.FollowSymlinks {
+		return fs.Stat(job.fileSystem(), name)
+	}
+	return job.lstat(name)
+}
+
+// errSymlinkLoop is given to walkFn, with its FileInfo, for the directories inside themselves through a
+// symbolic link. They are not walked into, whatever walkFn returns.
+var errSymlinkLoop = errors.New("symbolic link loop")
+
+// walkFS walks the file tree of fsys, calling walkFn for each file or directory in lexical order,
+// like filepath.Walk does. Paths given to walkFn are names of fsys joined to root.
+// Symbolic links are followed if follow is set, like symwalk.Walk does, otherwise walkFn gets their own FileInfo.
+func walkFS(fsys fs.FS, root string, follow bool, walkFn filepath.WalkFunc) error {
+	info, err := fs.Stat(fsys, ".")
+	if err != nil {
+		return walkFn(root, nil, err)
+	}
+	err = walkFSDir(fsys, root, ".", info, follow, nil, walkFn)
+	if err == filepath.SkipDir {
+		return nil
+	}
+	return err
+}
+
+// walkFSDir calls walkFn for the named file, and walks into it if it's a directory, unless it's one of parents.
+func walkFSDir(fsys fs.FS, root string, name string, info fs.FileInfo, follow bool, parents ancestors, walkFn filepath.WalkFunc) error {
+	fp := fsPath(root, name)
+	if !info.IsDir() {
+		return walkFn(fp, info, nil)
+	}
+	parents, ok := parents.enter(info)
+	if !ok {
+		return walkFn(fp, info, errSymlinkLoop)
+	}
+	if err := walkFn(fp, info, nil); err != nil {
+		return err
+	}
+
+	entries, err := fs.ReadDir(fsys, name)
+	if err != nil {
+		return walkFn(fp, info, err)
+	}
+	for _, entry := range entries {
+		child := path.Join(name, entry.Name())
+		fi, err := entryInfo(fsys, child, entry, follow)
+		if err != nil {
+			err = walkFn(fsPath(root, child), nil, err)
+		} else {
+			err = walkFSDir(fsys, root, child, fi, follow, parents, walkFn)
+		}
+		if err == filepath.SkipDir {
+			// a skipped file skips the rest of its directory.
+			if fi != nil && fi.IsDir() {
+				continue
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// entryInfo returns the FileInfo of the named directory entry. Symbolic links are resolved if follow is set,
+// so they are walked into, except the broken ones, and the ones to directories whose inode is unknown,
+// e.g. on Windows, since their loops could not be detected.
+func entryInfo(fsys fs.FS, name string, entry fs.DirEntry, follow bool) (fs.FileInfo, error) {
+	info, err := entry.Info()
+	if err != nil || info.Mode()&fs.ModeSymlink == 0 || !follow {
+		return info, err
+	}
+	target, err := fs.Stat(fsys, name)
+	if err != nil {
+		return info, nil
+	}
+	if _, ok := fileID(target); target.IsDir() && !ok {
+		return info, nil
+	}
+	return target, nil
+}
+
+// ancestors are the directories a directory is in, identified by their inode, to detect the loops of
+// the symbolic links followed.
+type ancestors []fileKey
+
+// enter returns the ancestors of the entries of the directory, or false if the directory is one of its
+// own ancestors. Directories whose inode is unknown are not tracked.
+func (a ancestors) enter(info fs.FileInfo) (ancestors, bool) {
+	key, ok := fileID(info)
+	if !ok {
+		return a, true
+	}
+	for _, k := range a {
+		if k == key {
+			return nil, false
+		}
+	}
+	// the directories of other branches share the array, so it's copied.
+	return append(a[:len(a):len(a)], key), true
+}
+
+// isSymlink returns true if the named file of the source folder is a symbolic link.
+func (job *UploadFolderJob) isSymlink(name string) bool {
+	fi, err := job.lstat(name)
+	return err == nil && fi.Mode()&fs.ModeSymlink != 0
+}
+
+// lstat returns the FileInfo of the named file of the source folder, without following it if it's a
+// symbolic link.
+func (job *UploadFolderJob) lstat(name string) (fs.FileInfo, error) {
+	if job.FS == nil {
+		return os.Lstat(fsPath(job.SourceFolder, name))
+	}
+	// fs.FS doesn't have a Lstat, so the entry is read from its directory.
+	entries, err := fs.ReadDir(job.FS, path.Dir(name))
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.Name() == path.Base(name) {
+			return entry.Info()
+		}
+	}
+	return nil, &fs.PathError{Op: "lstat", Path: name, Err: fs.ErrNotExist}
+}
+
+// fsPath returns the path of the named file of a file system rooted at root.
+func fsPath(root string, name string) string {
+	if name == "." {
+		return root
+	}
+	return filepath.Join(root, filepath.FromSlash(name))
+}
+
+// fsName returns the name of the file at fp, in a file system rooted at root.
+func fsName(root string, fp string) (string, error) {
+	rp, err := filepath.Rel(root, fp)
+	if err != nil {
+		return "", err
+	}
+	name := filepath.ToSlash(rp)
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: "stat", Path: fp, Err: fs.ErrInvalid}
+	}
+	return name, nil
+}
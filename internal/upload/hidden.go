@@ -0,0 +1,29 @@
+package upload
+
+import (
+	"io/fs"
+	"path"
+)
+
+// IsHidden returns if the file at fp, or any of its directories in SourceFolder, is hidden, so it's skipped
+// unless IncludeHidden is set. SourceFolder itself could be hidden.
+func (job *UploadFolderJob) IsHidden(fp string) bool {
+	if job.IncludeHidden {
+		return false
+	}
+	name, err := fsName(job.SourceFolder, fp)
+	if err != nil {
+		return false
+	}
+	fsys := job.fileSystem()
+	for ; name != "."; name = path.Dir(name) {
+		fi, err := fs.Stat(fsys, name)
+		if err != nil {
+			return false
+		}
+		if isHidden(path.Base(name), fi) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+// +build !windows
+
+package upload
+
+import (
+	"os"
+	"strings"
+)
+
+// isHidden returns if the file is hidden, that is, its name starts with a dot.
+func isHidden(name string, fi os.FileInfo) bool {
+	return strings.HasPrefix(name, ".") && name != "." && name != ".."
+}
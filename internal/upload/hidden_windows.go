@@ -0,0 +1,19 @@
+//go:build windows
+// +build windows
+
+package upload
+
+import (
+	"os"
+	"strings"
+	"syscall"
+)
+
+// isHidden returns if the file is hidden, its name starts with a dot or it has the hidden attribute.
+func isHidden(name string, fi os.FileInfo) bool {
+	if strings.HasPrefix(name, ".") && name != "." && name != ".." {
+		return true
+	}
+	attrs, ok := fi.Sys().(*syscall.Win32FileAttributeData)
+	return ok && attrs.FileAttributes&syscall.FILE_ATTRIBUTE_HIDDEN != 0
+}
@@ -0,0 +1,81 @@
+//go:build windows
+// +build windows
+
+package upload_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"syscall"
+	"testing"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/filter"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/mock"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/upload"
+)
+
+// setHidden sets the hidden attribute of the file.
+func setHidden(t *testing.T, path string) {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if err := syscall.SetFileAttributes(p, syscall.FILE_ATTRIBUTE_HIDDEN); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+}
+
+func TestUploadFolderJob_WalkFolderHiddenAttribute(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hidden")
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, d := range []string{"photos", "thumbnails"} {
+		if err := os.MkdirAll(filepath.Join(dir, d), 0700); err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+	}
+	for _, name := range []string{"photos/a.jpg", "photos/b.jpg", "thumbnails/c.jpg"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("jpg"), 0600); err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+	}
+	setHidden(t, filepath.Join(dir, "photos", "b.jpg"))
+	setHidden(t, filepath.Join(dir, "thumbnails"))
+
+	testCases := []struct {
+		name          string
+		includeHidden bool
+		want          []string
+	}{
+		{"Should skip files and directories with the hidden attribute", false, []string{"photos/a.jpg"}},
+		{"Should include files and directories with the hidden attribute", true, []string{"photos/a.jpg", "photos/b.jpg", "thumbnails/c.jpg"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			u := upload.UploadFolderJob{
+				FileTracker:   &mock.FileTracker{ExistFn: func(path string) bool { return false }},
+				SourceFolder:  dir,
+				CreateAlbums:  "Off",
+				Filter:        filter.MustCompile([]string{"_ALL_FILES_"}, nil),
+				IncludeHidden: tc.includeHidden,
+			}
+
+			var got []string
+			if _, err := u.WalkFolder(&mock.Logger{}, func(item upload.FileItem) {
+				got = append(got, filepath.ToSlash(upload.RelativePath(dir, item.Path)))
+			}); err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(tc.want, got) {
+				t.Errorf("want: %v, got: %v", tc.want, got)
+			}
+		})
+	}
+}
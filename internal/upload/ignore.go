@@ -0,0 +1,137 @@
+package upload
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/filter"
+)
+
+// IgnoreFileName is the name of the files listing the exclude patterns of their directory, like `.gitignore`
+// files do. Patterns are matched against the path relative to the directory of the ignore file, and they
+// are applied after the ones of the ignore files in the parent directories, so they could re-include files.
+const IgnoreFileName = ".gphotosignore"
+
+// ignoreRules keeps the filters of the ignore files found when walking a file system, by the name of their
+// directory. It's safe for concurrent use, since directories could be scanned concurrently.
+type ignoreRules struct {
+	fsys fs.FS
+
+	mu sync.Mutex
+	// filters are nil for the directories without an ignore file.
+	filters map[string]*filter.Filter
+}
+
+func newIgnoreRules(fsys fs.FS) *ignoreRules {
+	return &ignoreRules{
+		fsys:    fsys,
+		filters: make(map[string]*filter.Filter),
+	}
+}
+
+// isIgnored returns true if the named file or directory is excluded by the ignore files of its parent directories.
+// Ignore files are applied from the root to the deepest directory, the last one matching the item decides.
+// It returns error if any of the ignore files could not be read or its patterns are not valid.
+func (r *ignoreRules) isIgnored(name string) (bool, error) {
+	var ignored bool
+	for _, dir := range parentDirs(name) {
+		f, err := r.filter(dir)
+		if err != nil {
+			return false, err
+		}
+		if f == nil {
+			continue
+		}
+		rel := name
+		if dir != "." {
+			rel = strings.TrimPrefix(name, dir+"/")
+		}
+		if excluded, matched := f.MatchExcluded(rel); matched {
+			ignored = excluded
+		}
+	}
+	return ignored, nil
+}
+
+// isParentIgnored returns true if any of the parent directories of the named file is excluded by the ignore
+// files. Excluded directories are not scanned, but files could be found by other means, e.g. a watcher.
+func (r *ignoreRules) isParentIgnored(name string) (bool, error) {
+	dirs := parentDirs(name)
+	for _, dir := range dirs[1:] {
+		ignored, err := r.isIgnored(dir)
+		if err != nil || ignored {
+			return ignored, err
+		}
+	}
+	return false, nil
+}
+
+// filter returns the filter of the ignore file in the named directory, or nil if the directory has none.
+func (r *ignoreRules) filter(dir string) (*filter.Filter, error) {
+	r.mu.Lock()
+	f, ok := r.filters[dir]
+	r.mu.Unlock()
+	if ok {
+		return f, nil
+	}
+
+	f, err := readIgnoreFile(r.fsys, path.Join(dir, IgnoreFileName))
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.filters[dir] = f
+	return f, nil
+}
+
+// readIgnoreFile returns the filter of the named ignore file, or nil if it doesn't exist.
+// Every line is an exclude pattern, empty lines and lines starting with `#` are ignored.
+func readIgnoreFile(fsys fs.FS, name string) (*filter.Filter, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ignore file '%s' could not be read: %w", name, err)
+	}
+
+	var patterns []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("ignore file '%s' could not be read: %w", name, err)
+	}
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+
+	f, err := filter.Compile([]string{"_ALL_FILES_"}, patterns)
+	if err != nil {
+		return nil, fmt.Errorf("ignore file '%s' is not valid: %w", name, err)
+	}
+	return f, nil
+}
+
+// parentDirs returns the names of the parent directories of the named file, from the root to the deepest one.
+func parentDirs(name string) []string {
+	dirs := []string{"."}
+	for i, c := range name {
+		if c == '/' {
+			dirs = append(dirs, name[:i])
+		}
+	}
+	return dirs
+}
@@ -0,0 +1,215 @@
+package upload
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+)
+
+const (
+	// DefaultMaxPhotoSize is the maximum size of a photo accepted by Google Photos.
+	DefaultMaxPhotoSize = 200 * 1000 * 1000
+	// DefaultMaxVideoSize is the maximum size of a video accepted by Google Photos.
+	DefaultMaxVideoSize = 10 * 1000 * 1000 * 1000
+
+	// sniffLen is the number of bytes read to detect the content type of a file.
+	sniffLen = 512
+)
+
+// MediaLimit is the maximum size of a kind of media accepted by Google Photos.
+type MediaLimit struct {
+	// Kind is the name of the media, e.g. "photo".
+	Kind string
+	// MIMETypes are the content types of the media.
+	MIMETypes []string
+	// MaxSize is the maximum size of a file, in bytes. Zero means unlimited.
+	MaxSize int64
+}
+
+// Limits are the kinds of media accepted by Google Photos.
+// Files whose content type is not in any of them are not accepted.
+type Limits []MediaLimit
+
+// DefaultLimits returns the kinds of media accepted by Google Photos, with their maximum sizes.
+// Source: https://support.google.com/photos/answer/6193313
+func DefaultLimits() Limits {
+	return Limits{
+		{
+			Kind: "photo",
+			MIMETypes: []string{
+				"image/jpeg", "image/png", "image/gif", "image/webp", "image/bmp", "image/x-icon",
+				"image/tiff", "image/heic", "image/heif", "image/avif", "image/x-raw",
+			},
+			MaxSize: DefaultMaxPhotoSize,
+		},
+		{
+			Kind: "video",
+			MIMETypes: []string{
+				"video/mp4", "video/quicktime", "video/3gpp", "video/avi", "video/x-ms-asf",
+				"video/mpeg", "video/mp2t", "video/webm",
+			},
+			MaxSize: DefaultMaxVideoSize,
+		},
+	}
+}
+
+// SetMaxSize sets the maximum size of a kind of media, if it's not zero.
+func (l Limits) SetMaxSize(kind string, size int64) {
+	if size == 0 {
+		return
+	}
+	for i := range l {
+		if l[i].Kind == kind {
+			l[i].MaxSize = size
+		}
+	}
+}
+
+// RejectedError is returned when a file would be rejected by Google Photos.
+type RejectedError struct {
+	// Reason is a stable code of why the file is rejected, log.ReasonTooLarge or log.ReasonUnsupportedType.
+	Reason  string
+	message string
+}
+
+func (e *RejectedError) Error() string {
+	return e.message
+}
+
+// Check returns a RejectedError if Google Photos would not accept the file, by its content type or size.
+// The content type is detected from the first 512 bytes of the file, not from its extension.
+func (l Limits) Check(path string, size int64) error {
+	mimeType, err := DetectContentType(path)
+	if err != nil {
+		return err
+	}
+	return l.CheckContentType(mimeType, size)
+}
+
+// CheckContentType returns a RejectedError if Google Photos would not accept a file of the content type and size.
+func (l Limits) CheckContentType(mimeType string, size int64) error {
+	for _, limit := range l {
+		if !contains(limit.MIMETypes, mimeType) {
+			continue
+		}
+		if limit.MaxSize > 0 && size > limit.MaxSize {
+			return &RejectedError{
+				Reason:  log.ReasonTooLarge,
+				message: fmt.Sprintf("%s is %d bytes, larger than the maximum of %d bytes", limit.Kind, size, limit.MaxSize),
+			}
+		}
+		return nil
+	}
+	return &RejectedError{
+		Reason:  log.ReasonUnsupportedType,
+		message: fmt.Sprintf("content type '%s' is not supported", mimeType),
+	}
+}
+
+// DetectContentType returns the MIME type of the file, detected from its first 512 bytes.
+func DetectContentType(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	header := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	return sniff(header[:n]), nil
+}
+
+// mediaSignatures are the formats not detected by http.DetectContentType, by their magic number.
+var mediaSignatures = []struct {
+	offset   int
+	magic    []byte
+	mimeType string
+}{
+	{0, []byte("II*\x00"), "image/tiff"},
+	{0, []byte("MM\x00*"), "image/tiff"},
+	{0, []byte("IIRO"), "image/x-raw"},
+	{0, []byte("IIRS"), "image/x-raw"},
+	{0, []byte("MMOR"), "image/x-raw"},
+	{0, []byte("IIU\x00"), "image/x-raw"},
+	{0, []byte("FUJIFILMCCD-RAW"), "image/x-raw"},
+	{6, []byte("HEAPCCDR"), "image/x-raw"},
+	{0, []byte("\x30\x26\xB2\x75\x8E\x66\xCF\x11"), "video/x-ms-asf"},
+	{0, []byte("\x00\x00\x01\xBA"), "video/mpeg"},
+	{0, []byte("\x00\x00\x01\xB3"), "video/mpeg"},
+}
+
+// sniff returns the MIME type of the content. It detects the photo and video formats
+// supported by Google Photos that http.DetectContentType doesn't.
+func sniff(header []byte) string {
+	if mimeType, ok := sniffISOMedia(header); ok {
+		return mimeType
+	}
+	for _, sig := range mediaSignatures {
+		if len(header) >= sig.offset+len(sig.magic) && bytes.Equal(header[sig.offset:sig.offset+len(sig.magic)], sig.magic) {
+			return sig.mimeType
+		}
+	}
+	if isMPEGTransportStream(header) {
+		return "video/mp2t"
+	}
+
+	mimeType := http.DetectContentType(header)
+	// parameters, like the charset of text files, are not needed.
+	if i := strings.IndexByte(mimeType, ';'); i >= 0 {
+		mimeType = mimeType[:i]
+	}
+	return mimeType
+}
+
+// sniffISOMedia detects the ISO base media formats, like HEIC, MOV or MP4, by the brand of its ftyp box.
+func sniffISOMedia(header []byte) (string, bool) {
+	if len(header) < 12 || !bytes.Equal(header[4:8], []byte("ftyp")) {
+		return "", false
+	}
+	brand := string(header[8:12])
+	switch {
+	case brand == "heic" || brand == "heix" || brand == "hevc" || brand == "hevx" || brand == "heim" || brand == "heis":
+		return "image/heic", true
+	case brand == "mif1" || brand == "msf1":
+		return "image/heif", true
+	case brand == "avif" || brand == "avis":
+		return "image/avif", true
+	case brand == "crx ":
+		return "image/x-raw", true
+	case brand == "qt  ":
+		return "video/quicktime", true
+	case brand[:3] == "3gp" || brand[:3] == "3g2":
+		return "video/3gpp", true
+	}
+	return "video/mp4", true
+}
+
+// isMPEGTransportStream returns true if the content is a MPEG transport stream, like MTS or M2TS files.
+// Its packets start with a sync byte, and they are 188 bytes long, or 192 bytes when prefixed by a timestamp.
+func isMPEGTransportStream(header []byte) bool {
+	const syncByte = 0x47
+	for _, layout := range []struct{ offset, packetLen int }{{0, 188}, {4, 192}} {
+		next := layout.offset + layout.packetLen
+		if len(header) > next && header[layout.offset] == syncByte && header[next] == syncByte {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
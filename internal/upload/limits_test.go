@@ -0,0 +1,245 @@
+package upload_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/filter"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/mock"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/upload"
+)
+
+func TestDetectContentType(t *testing.T) {
+	dir, err := ioutil.TempDir("", "limits")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var testCases = []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{"IMG_0001.HEIC", "\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1heic", "image/heic"},
+		{"IMG_0001.MOV", "\x00\x00\x00\x14ftypqt  \x00\x00\x00\x00qt  ", "video/quicktime"},
+		{"IMG_0001.CR3", "\x00\x00\x00\x18ftypcrx \x00\x00\x00\x01crx isom", "image/x-raw"},
+		{"IMG_0001.DNG", "II*\x00\x08\x00\x00\x00", "image/tiff"},
+		// the extension is not used.
+		{"IMG_0001.jpg", "just some text", "text/plain"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(dir, tc.name)
+			if err := ioutil.WriteFile(path, []byte(tc.header), 0600); err != nil {
+				t.Fatal(err)
+			}
+			got, err := upload.DetectContentType(path)
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			if got != tc.want {
+				t.Errorf("want: %s, got: %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestMIMEDetection_ContentType(t *testing.T) {
+	dir, err := ioutil.TempDir("", "limits")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// photos with a misleading extension, or without any.
+	for _, name := range []string{"IMG_0001.mp4", "IMG_0002.ts", "IMG_0003"} {
+		copyFile(t, "testdata/SampleJPGImage.jpg", filepath.Join(dir, name))
+	}
+
+	var testCases = []struct {
+		name      string
+		detection upload.MIMEDetection
+		path      string
+		want      string
+	}{
+		{"Should trust misleading extension", upload.MIMEDetectionExtension, "IMG_0001.mp4", "video/mp4"},
+		{"Should trust ambiguous extension", upload.MIMEDetectionExtension, "IMG_0002.ts", "video/mp2t"},
+		{"Should not detect missing extension", upload.MIMEDetectionExtension, "IMG_0003", "application/octet-stream"},
+		{"Should sniff misleading extension", upload.MIMEDetectionSniff, "IMG_0001.mp4", "image/jpeg"},
+		{"Should sniff ambiguous extension", upload.MIMEDetectionSniff, "IMG_0002.ts", "image/jpeg"},
+		{"Should sniff missing extension", upload.MIMEDetectionSniff, "IMG_0003", "image/jpeg"},
+		{"Should sniff by default", "", "IMG_0001.mp4", "image/jpeg"},
+		{"Should trust known extension when auto", upload.MIMEDetectionAuto, "IMG_0001.mp4", "video/mp4"},
+		{"Should sniff ambiguous extension when auto", upload.MIMEDetectionAuto, "IMG_0002.ts", "image/jpeg"},
+		{"Should sniff missing extension when auto", upload.MIMEDetectionAuto, "IMG_0003", "image/jpeg"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.detection.ContentType(filepath.Join(dir, tc.path))
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			if got != tc.want {
+				t.Errorf("want: %s, got: %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestLimits_Check(t *testing.T) {
+	dir, err := ioutil.TempDir("", "limits")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// a sparse file, larger than the maximum size of a photo, with the content of a JPEG.
+	oversized := filepath.Join(dir, "oversized.jpg")
+	copyFile(t, "testdata/SampleJPGImage.jpg", oversized)
+	if err := os.Truncate(oversized, upload.DefaultMaxPhotoSize+1); err != nil {
+		t.Fatal(err)
+	}
+
+	var testCases = []struct {
+		name       string
+		path       string
+		wantReason string
+	}{
+		{"Should accept JPEG photo", "testdata/SampleJPGImage.jpg", ""},
+		{"Should accept PNG photo", "testdata/SamplePNGImage.png", ""},
+		{"Should accept MP4 video", "testdata/SampleVideo.mp4", ""},
+		{"Should reject oversized photo", oversized, log.ReasonTooLarge},
+		{"Should reject text file", "testdata/SampleText.txt", log.ReasonUnsupportedType},
+		{"Should reject SVG image", "testdata/SampleSVGImage.svg", log.ReasonUnsupportedType},
+		{"Should reject audio file", "testdata/SampleAudio.mp3", log.ReasonUnsupportedType},
+	}
+
+	limits := upload.DefaultLimits()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fi, err := os.Stat(tc.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = limits.Check(tc.path, fi.Size())
+			var rejected *upload.RejectedError
+			if tc.wantReason == "" {
+				if err != nil {
+					t.Errorf("error was not expected at this point: %s", err)
+				}
+				return
+			}
+			if !errors.As(err, &rejected) {
+				t.Fatalf("want: RejectedError, got: %v", err)
+			}
+			if rejected.Reason != tc.wantReason {
+				t.Errorf("want: %s, got: %s", tc.wantReason, rejected.Reason)
+			}
+		})
+	}
+}
+
+func TestLimits_SetMaxSize(t *testing.T) {
+	limits := upload.DefaultLimits()
+	limits.SetMaxSize("photo", 10)
+	// zero keeps the default.
+	limits.SetMaxSize("video", 0)
+
+	err := limits.Check("testdata/SampleJPGImage.jpg", 11)
+	var rejected *upload.RejectedError
+	if !errors.As(err, &rejected) || rejected.Reason != log.ReasonTooLarge {
+		t.Errorf("want: %s, got: %v", log.ReasonTooLarge, err)
+	}
+	if err := limits.Check("testdata/SampleVideo.mp4", upload.DefaultMaxVideoSize); err != nil {
+		t.Errorf("error was not expected at this point: %s", err)
+	}
+}
+
+func TestUploadFolderJob_WalkFolderLimits(t *testing.T) {
+	u := upload.UploadFolderJob{
+		FileTracker:  &mock.FileTracker{ExistFn: func(path string) bool { return false }},
+		SourceFolder: "testdata",
+		CreateAlbums: "Off",
+		Filter:       filter.MustCompile([]string{"Sample*"}, []string{"folder*"}),
+		Limits:       upload.DefaultLimits(),
+	}
+
+	var found []string
+	stats, err := u.WalkFolder(&mock.Logger{}, func(item upload.FileItem) {
+		found = append(found, filepath.Base(item.Path))
+	})
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	// audio, SVG and text files are skipped, photos and videos are found. The folder-symlink link is skipped too.
+	want := upload.WalkStats{Found: 3, SkippedFiltered: 3, SkippedRejected: 3}
+	if stats != want {
+		t.Errorf("want: %+v, got: %+v, found: %v", want, stats, found)
+	}
+}
+
+func TestUploadFolderJob_WalkFolderLimitsMIMEDetection(t *testing.T) {
+	dir, err := ioutil.TempDir("", "limits")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// a text file named like a photo, and a photo without extension.
+	copyFile(t, "testdata/SampleText.txt", filepath.Join(dir, "IMG_0001.jpg"))
+	copyFile(t, "testdata/SampleJPGImage.jpg", filepath.Join(dir, "IMG_0002"))
+
+	var testCases = []struct {
+		detection upload.MIMEDetection
+		want      []string
+	}{
+		{upload.MIMEDetectionExtension, []string{"IMG_0001.jpg"}},
+		{upload.MIMEDetectionSniff, []string{"IMG_0002"}},
+		{upload.MIMEDetectionAuto, []string{"IMG_0001.jpg", "IMG_0002"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.detection), func(t *testing.T) {
+			u := upload.UploadFolderJob{
+				FileTracker:   &mock.FileTracker{ExistFn: func(path string) bool { return false }},
+				SourceFolder:  dir,
+				CreateAlbums:  "Off",
+				Filter:        filter.MustCompile([]string{"_ALL_FILES_"}, nil),
+				Limits:        upload.DefaultLimits(),
+				MIMEDetection: tc.detection,
+			}
+
+			var found []string
+			if _, err := u.WalkFolder(&mock.Logger{}, func(item upload.FileItem) {
+				found = append(found, filepath.Base(item.Path))
+			}); err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			sort.Strings(found)
+			if !reflect.DeepEqual(tc.want, found) {
+				t.Errorf("want: %v, got: %v", tc.want, found)
+			}
+		})
+	}
+}
+
+func copyFile(t *testing.T, src string, dst string) {
+	t.Helper()
+	b, err := ioutil.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(dst, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,67 @@
+package upload
+
+import (
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// livePhotoImageExtensions are the extensions of the photos of Live Photos, in lower case.
+	livePhotoImageExtensions = []string{".heic", ".heif", ".jpg", ".jpeg"}
+	// livePhotoVideoExtensions are the extensions of the videos of Live Photos, in lower case.
+	livePhotoVideoExtensions = []string{".mov", ".mp4"}
+)
+
+// livePhotoOf returns the photo of the Live Photo whose video is the file at fp, if DetectLivePhotos is set: a photo in
+// the same folder with the same name, e.g. "IMG_0001.HEIC" for "IMG_0001.MOV". Extensions are matched in lower and
+// upper case. It returns false if the file is not a video, or no photo has its name.
+func (job *UploadFolderJob) livePhotoOf(fp string) (string, bool) {
+	if !job.DetectLivePhotos {
+		return "", false
+	}
+	ext := filepath.Ext(fp)
+	if !isLivePhotoExtension(livePhotoVideoExtensions, ext) {
+		return "", false
+	}
+	name, err := fsName(job.SourceFolder, fp)
+	if err != nil {
+		return "", false
+	}
+	base := strings.TrimSuffix(name, path.Ext(name))
+	for _, e := range livePhotoImageExtensions {
+		for _, candidate := range []string{e, strings.ToUpper(e)} {
+			fi, err := fs.Stat(job.fileSystem(), base+candidate)
+			if err == nil && !fi.IsDir() {
+				return strings.TrimSuffix(fp, ext) + candidate, true
+			}
+		}
+	}
+	return "", false
+}
+
+// livePhotoAlbumName returns the album of the photo at fp of a Live Photo, so its video is added to the same album.
+// path is relative to SourceFolder.
+func (job *UploadFolderJob) livePhotoAlbumName(fp string, path string) string {
+	name, err := fsName(job.SourceFolder, fp)
+	if err != nil {
+		return ""
+	}
+	fi, err := fs.Stat(job.fileSystem(), name)
+	if err != nil {
+		return ""
+	}
+	return job.fileAlbumName(fp, path, fi.ModTime(), job.newFileMetadata(fp))
+}
+
+// isLivePhotoExtension returns if the extension, in any case, is one of the list.
+func isLivePhotoExtension(list []string, ext string) bool {
+	ext = strings.ToLower(ext)
+	for _, e := range list {
+		if e == ext {
+			return true
+		}
+	}
+	return false
+}
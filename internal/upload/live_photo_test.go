@@ -0,0 +1,119 @@
+package upload_test
+
+import (
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/filter"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/mock"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/upload"
+)
+
+func TestUploadFolderJob_WalkFolderLivePhotos(t *testing.T) {
+	modTime := time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"IMG_0001.HEIC":       {Data: []byte("photo"), ModTime: modTime},
+		"IMG_0001.MOV":        {Data: []byte("video"), ModTime: modTime},
+		"trips/IMG_0002.jpg":  {Data: []byte("photo"), ModTime: modTime},
+		"trips/IMG_0002.mov":  {Data: []byte("video"), ModTime: modTime},
+		"trips/VID_0003.mov":  {Data: []byte("video"), ModTime: modTime},
+		"other/IMG_0002.heic": {Data: []byte("photo"), ModTime: modTime},
+	}
+	photos, err := upload.NewAlbumFilter("Photos", []string{"_IMAGE_EXTENSIONS_"}, nil)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	testCases := []struct {
+		name          string
+		detect        bool
+		albums        []upload.AlbumFilter
+		wantAlbums    map[string]string
+		wantLivePhoto map[string]string
+	}{
+		{
+			name:   "Should add the videos to the album of their photos",
+			detect: true,
+			wantAlbums: map[string]string{
+				"IMG_0001.HEIC":       "Photos",
+				"IMG_0001.MOV":        "Photos",
+				"trips/IMG_0002.jpg":  "Photos",
+				"trips/IMG_0002.mov":  "Photos",
+				"trips/VID_0003.mov":  "Videos",
+				"other/IMG_0002.heic": "Photos",
+			},
+			wantLivePhoto: map[string]string{
+				"IMG_0001.MOV":       "IMG_0001.HEIC",
+				"trips/IMG_0002.mov": "trips/IMG_0002.jpg",
+			},
+		},
+		{
+			name:   "Should route the videos like their photos",
+			detect: true,
+			albums: []upload.AlbumFilter{photos},
+			wantAlbums: map[string]string{
+				"IMG_0001.HEIC":       "Photos",
+				"IMG_0001.MOV":        "Photos",
+				"trips/IMG_0002.jpg":  "Photos",
+				"trips/IMG_0002.mov":  "Photos",
+				"other/IMG_0002.heic": "Photos",
+			},
+			wantLivePhoto: map[string]string{
+				"IMG_0001.MOV":       "IMG_0001.HEIC",
+				"trips/IMG_0002.mov": "trips/IMG_0002.jpg",
+			},
+		},
+		{
+			name:   "Should not detect them if it's not set",
+			detect: false,
+			wantAlbums: map[string]string{
+				"IMG_0001.HEIC":       "Photos",
+				"IMG_0001.MOV":        "Videos",
+				"trips/IMG_0002.jpg":  "Photos",
+				"trips/IMG_0002.mov":  "Videos",
+				"trips/VID_0003.mov":  "Videos",
+				"other/IMG_0002.heic": "Photos",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			u := upload.UploadFolderJob{
+				FileTracker:      &mock.FileTracker{ExistFn: func(path string) bool { return false }},
+				SourceFolder:     "/photos",
+				FS:               fsys,
+				CreateAlbums:     "mediaType",
+				MIMEDetection:    upload.MIMEDetectionExtension,
+				Filter:           filter.MustCompile([]string{"_ALL_FILES_"}, nil),
+				Albums:           tc.albums,
+				DetectLivePhotos: tc.detect,
+			}
+
+			got := make(map[string]upload.FileItem)
+			if _, err := u.WalkFolder(&mock.Logger{}, func(item upload.FileItem) {
+				got[item.Path] = item
+			}); err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+
+			if len(got) != len(tc.wantAlbums) {
+				t.Errorf("want: %v, got: %v", tc.wantAlbums, got)
+			}
+			for name, album := range tc.wantAlbums {
+				item := got[filepath.Join("/photos", name)]
+				if item.AlbumName != album {
+					t.Errorf("want: %s, got: %s, file: %s", album, item.AlbumName, name)
+				}
+				var want string
+				if photo, ok := tc.wantLivePhoto[name]; ok {
+					want = filepath.Join("/photos", photo)
+				}
+				if item.LivePhotoOf != want {
+					t.Errorf("want: %s, got: %s, file: %s", want, item.LivePhotoOf, name)
+				}
+			}
+		})
+	}
+}
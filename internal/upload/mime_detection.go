@@ -0,0 +1,93 @@
+package upload
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// MIMEDetection is the way the content type of the files is detected, to check if Google Photos would accept
+// them and to route them to albums by their media type.
+type MIMEDetection string
+
+const (
+	// MIMEDetectionSniff always detects the content type from the first 512 bytes of the files (default).
+	MIMEDetectionSniff MIMEDetection = "sniff"
+	// MIMEDetectionExtension trusts the extension of the files, without reading them. It's faster, but files
+	// with a misleading extension are misclassified.
+	MIMEDetectionExtension MIMEDetection = "extension"
+	// MIMEDetectionAuto trusts the extension of the files, unless it's missing, unknown or ambiguous, then
+	// their content is read.
+	MIMEDetectionAuto MIMEDetection = "auto"
+)
+
+// unknownContentType is the content type of the files whose extension is missing or unknown.
+const unknownContentType = "application/octet-stream"
+
+// extensionTypes are the content types of the files by their lowercase extension. They match the ones
+// detected from the content of the files, so the same limits apply to both.
+var extensionTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".jpe":  "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".bmp":  "image/bmp",
+	".ico":  "image/x-icon",
+	".tif":  "image/tiff",
+	".tiff": "image/tiff",
+	".heic": "image/heic",
+	".heif": "image/heif",
+	".avif": "image/avif",
+	".dng":  "image/x-raw",
+	".cr2":  "image/x-raw",
+	".cr3":  "image/x-raw",
+	".crw":  "image/x-raw",
+	".nef":  "image/x-raw",
+	".arw":  "image/x-raw",
+	".orf":  "image/x-raw",
+	".rw2":  "image/x-raw",
+	".raf":  "image/x-raw",
+	".svg":  "image/svg+xml",
+	".mp4":  "video/mp4",
+	".m4v":  "video/mp4",
+	".mov":  "video/quicktime",
+	".3gp":  "video/3gpp",
+	".3g2":  "video/3gpp",
+	".avi":  "video/avi",
+	".wmv":  "video/x-ms-asf",
+	".asf":  "video/x-ms-asf",
+	".mpg":  "video/mpeg",
+	".mpeg": "video/mpeg",
+	".mts":  "video/mp2t",
+	".m2ts": "video/mp2t",
+	".ts":   "video/mp2t",
+	".webm": "video/webm",
+	".mp3":  "audio/mpeg",
+	".txt":  "text/plain",
+}
+
+// ambiguousExtensions are the extensions used by several kinds of files, e.g. .ts is used by TypeScript
+// sources too. The content of these files is read when detecting it with MIMEDetectionAuto.
+var ambiguousExtensions = map[string]bool{
+	".ts": true,
+}
+
+// ContentType returns the MIME type of the file. Unless the file is read, files with a missing or unknown
+// extension are "application/octet-stream", so Google Photos would not accept them.
+func (d MIMEDetection) ContentType(path string) (string, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	mimeType, known := extensionTypes[ext]
+	switch d {
+	case MIMEDetectionExtension:
+		if !known {
+			return unknownContentType, nil
+		}
+		return mimeType, nil
+	case MIMEDetectionAuto:
+		if known && !ambiguousExtensions[ext] {
+			return mimeType, nil
+		}
+	}
+	return DetectContentType(path)
+}
@@ -0,0 +1,52 @@
+package upload
+
+import (
+	"fmt"
+	"sort"
+)
+
+const (
+	// OrderNone keeps the order in which files are found (default).
+	OrderNone = "none"
+	// OrderModTimeAsc sorts files from the oldest to the newest modification time.
+	OrderModTimeAsc = "mtime-asc"
+	// OrderModTimeDesc sorts files from the newest to the oldest modification time.
+	OrderModTimeDesc = "mtime-desc"
+	// OrderName sorts files by its path.
+	OrderName = "name"
+)
+
+// SortFileItems sorts the items using the given order. Items with the same
+// modification time are sorted by its path, so the order is deterministic.
+func SortFileItems(items []FileItem, order string) error {
+	var less func(a, b FileItem) bool
+	switch order {
+	case "", OrderNone:
+		return nil
+	case OrderModTimeAsc:
+		less = func(a, b FileItem) bool {
+			if a.ModTime.Equal(b.ModTime) {
+				return a.Path < b.Path
+			}
+			return a.ModTime.Before(b.ModTime)
+		}
+	case OrderModTimeDesc:
+		less = func(a, b FileItem) bool {
+			if a.ModTime.Equal(b.ModTime) {
+				return a.Path < b.Path
+			}
+			return a.ModTime.After(b.ModTime)
+		}
+	case OrderName:
+		less = func(a, b FileItem) bool {
+			return a.Path < b.Path
+		}
+	default:
+		return fmt.Errorf("invalid upload order '%s'", order)
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return less(items[i], items[j])
+	})
+	return nil
+}
@@ -0,0 +1,119 @@
+package upload_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/filter"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/mock"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/upload"
+)
+
+func TestSortFileItems(t *testing.T) {
+	base := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	newItems := func() []upload.FileItem {
+		return []upload.FileItem{
+			{Path: "b.jpg", ModTime: base.Add(2 * time.Hour)},
+			{Path: "d.jpg", ModTime: base},
+			{Path: "a.jpg", ModTime: base.Add(3 * time.Hour)},
+			{Path: "c.jpg", ModTime: base.Add(2 * time.Hour)},
+		}
+	}
+
+	testCases := []struct {
+		name          string
+		order         string
+		want          []string
+		isErrExpected bool
+	}{
+		{"Should keep the order if empty", "", []string{"b.jpg", "d.jpg", "a.jpg", "c.jpg"}, false},
+		{"Should keep the order if none", upload.OrderNone, []string{"b.jpg", "d.jpg", "a.jpg", "c.jpg"}, false},
+		{"Should sort by oldest mtime", upload.OrderModTimeAsc, []string{"d.jpg", "b.jpg", "c.jpg", "a.jpg"}, false},
+		{"Should sort by newest mtime", upload.OrderModTimeDesc, []string{"a.jpg", "b.jpg", "c.jpg", "d.jpg"}, false},
+		{"Should sort by name", upload.OrderName, []string{"a.jpg", "b.jpg", "c.jpg", "d.jpg"}, false},
+		{"Should fail if order is invalid", "size", []string{"b.jpg", "d.jpg", "a.jpg", "c.jpg"}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			items := newItems()
+			err := upload.SortFileItems(items, tc.order)
+			if tc.isErrExpected && err == nil {
+				t.Fatalf("error was expected, but not produced")
+			}
+			if !tc.isErrExpected && err != nil {
+				t.Fatalf("error was not expected, err: %s", err)
+			}
+			for i := range tc.want {
+				if items[i].Path != tc.want[i] {
+					t.Errorf("want: %v, got: %v", tc.want, paths(items))
+					break
+				}
+			}
+		})
+	}
+}
+
+func paths(items []upload.FileItem) []string {
+	var result []string
+	for _, i := range items {
+		result = append(result, i.Path)
+	}
+	return result
+}
+
+func TestSortFileItems_ScannedFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "upload-order")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	base := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	files := map[string]time.Time{
+		"newest.jpg":        base.Add(48 * time.Hour),
+		"oldest.jpg":        base,
+		"folder/middle.jpg": base.Add(24 * time.Hour),
+	}
+	for name, mtime := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	job := upload.UploadFolderJob{
+		FileTracker:  &mock.FileTracker{ExistFn: func(path string) bool { return false }},
+		SourceFolder: dir,
+		CreateAlbums: "Off",
+		Filter:       filter.MustCompile([]string{"_ALL_FILES_"}, nil),
+	}
+	items, err := job.ScanFolder(&mock.Logger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := upload.SortFileItems(items, upload.OrderModTimeAsc); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"oldest.jpg", "folder/middle.jpg", "newest.jpg"}
+	if len(items) != len(want) {
+		t.Fatalf("want: %v, got: %v", want, paths(items))
+	}
+	for i := range want {
+		if items[i].Path != filepath.Join(dir, want[i]) {
+			t.Errorf("want: %v, got: %v", want, paths(items))
+			break
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package upload
+
+import (
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sync"
+)
+
+// parallelWalk walks the file tree of fsys, calling walkFn for each file or directory, like walkFS
+// does, following the symbolic links if follow is set, but reading up to workers directories concurrently.
+// walkFn is called concurrently and in no particular order. If it returns filepath.SkipDir
+// for a directory, the directory is not read. The first other error stops the walk and is returned.
+func parallelWalk(fsys fs.FS, root string, workers int, follow bool, walkFn filepath.WalkFunc) error {
+	info, err := fs.Stat(fsys, ".")
+	if err != nil {
+		return walkFn(root, nil, err)
+	}
+	if err := walkFn(root, info, nil); err != nil || !info.IsDir() {
+		if err == filepath.SkipDir {
+			return nil
+		}
+		return err
+	}
+
+	w := &parallelWalker{
+		fsys:   fsys,
+		root:   root,
+		follow: follow,
+		walkFn: walkFn,
+		sem:    make(chan struct{}, workers),
+	}
+	parents, _ := ancestors(nil).enter(info)
+	w.wg.Add(1)
+	go w.readDir(".", parents)
+	w.wg.Wait()
+	return w.err
+}
+
+// parallelWalker keeps the state of a parallelWalk.
+type parallelWalker struct {
+	fsys   fs.FS
+	root   string
+	follow bool
+	walkFn filepath.WalkFunc
+	// sem bounds the number of directories being read.
+	sem chan struct{}
+	wg  sync.WaitGroup
+
+	mu  sync.Mutex
+	err error
+}
+
+// subdir is a directory to be read, and the directories it's in.
+type subdir struct {
+	name    string
+	parents ancestors
+}
+
+// readDir calls walkFn for every entry of the named directory, and reads its subdirectories concurrently.
+// parents are the directories it's in, including itself.
+func (w *parallelWalker) readDir(dir string, parents ancestors) {
+	defer w.wg.Done()
+	w.sem <- struct{}{}
+	subdirs := w.visitEntries(dir, parents)
+	<-w.sem
+
+	for _, sd := range subdirs {
+		w.wg.Add(1)
+		go w.readDir(sd.name, sd.parents)
+	}
+}
+
+// visitEntries calls walkFn for every entry of the directory, returning the subdirectories to be read.
+func (w *parallelWalker) visitEntries(dir string, parents ancestors) []subdir {
+	if w.failed() {
+		return nil
+	}
+	entries, err := fs.ReadDir(w.fsys, dir)
+	if err != nil {
+		info, _ := fs.Stat(w.fsys, dir)
+		w.visit(dir, info, err)
+		return nil
+	}
+
+	var subdirs []subdir
+	for _, entry := range entries {
+		if w.failed() {
+			return nil
+		}
+		name := path.Join(dir, entry.Name())
+		fi, err := entryInfo(w.fsys, name, entry, w.follow)
+		if err != nil || !fi.IsDir() {
+			w.visit(name, fi, err)
+			continue
+		}
+		dirParents, ok := parents.enter(fi)
+		if !ok {
+			w.visit(name, fi, errSymlinkLoop)
+			continue
+		}
+		if w.visit(name, fi, nil) {
+			subdirs = append(subdirs, subdir{name: name, parents: dirParents})
+		}
+	}
+	return subdirs
+}
+
+// visit calls walkFn for the named file, returning false if it should not be walked into.
+func (w *parallelWalker) visit(name string, info fs.FileInfo, err error) bool {
+	switch err := w.walkFn(fsPath(w.root, name), info, err); err {
+	case nil:
+		return true
+	case filepath.SkipDir:
+		return false
+	default:
+		w.fail(err)
+		return false
+	}
+}
+
+func (w *parallelWalker) fail(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err == nil {
+		w.err = err
+	}
+}
+
+func (w *parallelWalker) failed() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err != nil
+}
@@ -0,0 +1,145 @@
+package upload_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/filter"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/mock"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/upload"
+)
+
+func TestUploadFolderJob_WalkFolderParallel(t *testing.T) {
+	dir, want := createTree(t, 3, 4, 5)
+	defer os.RemoveAll(dir)
+
+	// files in excluded directories are not expected.
+	for path := range want {
+		if strings.Contains(upload.RelativePath(dir, path), "dir-1/") {
+			delete(want, path)
+		}
+	}
+
+	u := upload.UploadFolderJob{
+		FileTracker:  &mock.FileTracker{ExistFn: func(path string) bool { return false }},
+		SourceFolder: dir,
+		CreateAlbums: "Off",
+		Filter:       filter.MustCompile([]string{"_ALL_FILES_"}, []string{"**/dir-1"}),
+		ScanWorkers:  4,
+	}
+
+	// fn is never called concurrently, so found doesn't need to be synchronized.
+	found := make(map[string]int)
+	stats, err := u.WalkFolder(&log.DiscardLogger{}, func(item upload.FileItem) {
+		found[item.Path]++
+	})
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	// excluded directories are pruned, so their files are not counted as filtered.
+	if want := (upload.WalkStats{Found: len(want)}); stats != want {
+		t.Errorf("want: %+v, got: %+v", want, stats)
+	}
+	for path := range want {
+		if found[path] != 1 {
+			t.Errorf("file should be found exactly once, path: %s, got: %d", path, found[path])
+		}
+	}
+	for path := range found {
+		if !want[path] {
+			t.Errorf("file was not expected to be found: %s", path)
+		}
+	}
+}
+
+func TestUploadFolderJob_WalkFolderParallelSameAsSerial(t *testing.T) {
+	walk := func(workers int) map[string]bool {
+		u := upload.UploadFolderJob{
+			FileTracker:  &mock.FileTracker{ExistFn: func(path string) bool { return false }},
+			SourceFolder: "testdata",
+			CreateAlbums: "Off",
+			Filter:       filter.MustCompile([]string{"_ALL_FILES_"}, []string{"folder2"}),
+			ScanWorkers:  workers,
+		}
+		found := make(map[string]bool)
+		if _, err := u.WalkFolder(&log.DiscardLogger{}, func(item upload.FileItem) {
+			found[item.Path] = true
+		}); err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+		return found
+	}
+
+	// symbolic links to folders are followed by both.
+	want, got := walk(1), walk(4)
+	if len(got) != len(want) {
+		t.Errorf("want: %v, got: %v", want, got)
+	}
+	for path := range want {
+		if !got[path] {
+			t.Errorf("file should be found: %s", path)
+		}
+	}
+}
+
+func BenchmarkUploadFolderJob_WalkFolder(b *testing.B) {
+	dir, _ := createTree(b, 3, 8, 20)
+	defer os.RemoveAll(dir)
+
+	for _, workers := range []int{1, 8} {
+		b.Run(fmt.Sprintf("workers-%d", workers), func(b *testing.B) {
+			u := upload.UploadFolderJob{
+				FileTracker:  &mock.FileTracker{ExistFn: func(path string) bool { return false }},
+				SourceFolder: dir,
+				CreateAlbums: "Off",
+				Filter:       filter.MustCompile([]string{"_ALL_FILES_"}, nil),
+				ScanWorkers:  workers,
+			}
+			for i := 0; i < b.N; i++ {
+				if _, err := u.WalkFolder(&log.DiscardLogger{}, func(item upload.FileItem) {}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// createTree creates a folder tree with the given depth, subfolders and files by folder.
+// It returns the folder and the files created.
+func createTree(tb testing.TB, depth int, folders int, files int) (string, map[string]bool) {
+	tb.Helper()
+	root, err := ioutil.TempDir("", "walker")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	created := make(map[string]bool)
+
+	var create func(dir string, level int)
+	create = func(dir string, level int) {
+		for i := 0; i < files; i++ {
+			path := filepath.Join(dir, fmt.Sprintf("file-%d.jpg", i))
+			if err := ioutil.WriteFile(path, []byte(path), 0600); err != nil {
+				tb.Fatal(err)
+			}
+			created[path] = true
+		}
+		if level == depth {
+			return
+		}
+		for i := 0; i < folders; i++ {
+			subdir := filepath.Join(dir, fmt.Sprintf("dir-%d", i))
+			if err := os.Mkdir(subdir, 0700); err != nil {
+				tb.Fatal(err)
+			}
+			create(subdir, level+1)
+		}
+	}
+	create(root, 0)
+	return root, created
+}
@@ -0,0 +1,400 @@
+package upload
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/ratelimit"
+)
+
+// DefaultEndpoint is the Google Photos endpoint for uploads.
+const DefaultEndpoint = "https://photoslibrary.googleapis.com/v1/uploads"
+
+// DefaultChunkSize is the size of the chunks the files are uploaded in.
+const DefaultChunkSize = 8 * 1024 * 1024
+
+// HttpClient represents a HTTP client.
+type HttpClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// SessionStore represents a storage to keep resumable upload sessions.
+type SessionStore interface {
+	Get(key string) []byte
+	Set(key string, value []byte)
+	Delete(key string)
+}
+
+// ResumableUploader implements resumable uploads using Google Photos upload sessions.
+// Upload sessions are kept in a SessionStore, so an interrupted upload continues
+// from the last offset acknowledged by the server instead of starting from scratch.
+// The content is streamed from the file, so the memory used doesn't depend on its size.
+type ResumableUploader struct {
+	client HttpClient
+	store  SessionStore
+	logger log.Logger
+
+	// Endpoint is the URL to create the upload sessions. Uses DefaultEndpoint by default.
+	// Useful for testing.
+	Endpoint string
+
+	// RateLimiter throttles the uploaded content. A nil RateLimiter means unlimited.
+	// It's shared by all the uploads, so concurrent uploads are capped together.
+	RateLimiter *ratelimit.Limiter
+
+	// OnProgress, if it's set, is called with the number of bytes sent of a file while uploading it.
+	// It could be called concurrently by several uploads.
+	OnProgress func(path string, n int64)
+
+	// ChunkSize is the size of the chunks the files are uploaded in. Uses DefaultChunkSize by default.
+	// The offset acknowledged by the server is kept after every chunk, so an interrupted upload loses
+	// one chunk at most. It's rounded to the chunk granularity required by the server, if any.
+	ChunkSize int64
+
+	// FSRetry retries opening and reading the files after transient errors of the file system, e.g. on a
+	// network mount. They are not retried by default.
+	FSRetry FSRetry
+}
+
+// uploadSession represents an upload session kept in the SessionStore, by the path of the file.
+type uploadSession struct {
+	URL    string `json:"url"`
+	Offset int64  `json:"offset"`
+	// Size and ModTime are the ones of the file when the session was created. The session is abandoned
+	// if the file has changed since then.
+	Size    int64 `json:"size"`
+	ModTime int64 `json:"modTime"`
+	// Granularity is the size the chunks must be a multiple of, except the last one, if the server
+	// requires it.
+	Granularity int64 `json:"granularity,omitempty"`
+}
+
+// errSessionExpired is returned when the server doesn't recognize the upload session anymore.
+var errSessionExpired = errors.New("upload session has expired")
+
+// NewResumableUploader returns a ResumableUploader using the authenticated client to upload
+// files and the store to keep the upload sessions.
+func NewResumableUploader(client HttpClient, store SessionStore, logger log.Logger) *ResumableUploader {
+	return &ResumableUploader{
+		client:   client,
+		store:    store,
+		logger:   logger,
+		Endpoint: DefaultEndpoint,
+	}
+}
+
+// UploadFile returns the Google Photos upload token after uploading a file.
+// It resumes the previous upload session of the file, if any. If the server reports
+// that the session has expired, or the file has changed, it falls back to a fresh upload.
+func (u *ResumableUploader) UploadFile(ctx context.Context, filePath string) (string, error) {
+	item := NewFileItem(filePath)
+	var fi os.FileInfo
+	err := u.FSRetry.do(ctx, func() error {
+		var err error
+		fi, err = appFS.Stat(item.Path)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	key := item.Path
+
+	if session, ok := u.previousSession(ctx, key, fi); ok {
+		u.logger.Debugf("Resuming upload session for '%s' at offset %d.", item, session.Offset)
+		token, err := u.upload(ctx, item, key, session)
+		if !errors.Is(err, errSessionExpired) {
+			return token, err
+		}
+		u.logger.Debugf("Upload session for '%s' has expired, starting a new one.", item)
+		u.store.Delete(key)
+	}
+
+	session, err := u.createSession(ctx, item, key, fi)
+	if err != nil {
+		return "", fmt.Errorf("creating upload session: %w", err)
+	}
+	return u.upload(ctx, item, key, session)
+}
+
+// previousSession returns the upload session stored for key, with the offset already
+// received by the server. It returns false if there is no active session for the file.
+func (u *ResumableUploader) previousSession(ctx context.Context, key string, fi os.FileInfo) (uploadSession, bool) {
+	var session uploadSession
+	b := u.store.Get(key)
+	if len(b) == 0 {
+		return session, false
+	}
+	if err := json.Unmarshal(b, &session); err != nil || session.URL == "" {
+		u.store.Delete(key)
+		return session, false
+	}
+	if session.Size != fi.Size() || session.ModTime != fi.ModTime().UnixNano() {
+		u.logger.Debugf("File '%s' has changed since its upload session was created, starting a new one.", key)
+		u.store.Delete(key)
+		return session, false
+	}
+
+	offset, err := u.queryOffset(ctx, session.URL)
+	if err != nil {
+		u.logger.Debugf("Unable to query upload session, starting a new one: %s", err)
+		u.store.Delete(key)
+		return session, false
+	}
+	session.Offset = offset
+	u.saveSession(key, session)
+	return session, true
+}
+
+// queryOffset returns the bytes already received by the server in the upload session.
+func (u *ResumableUploader) queryOffset(ctx context.Context, url string) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("X-Goog-Upload-Command", "query")
+	req.Header.Set("X-Goog-Upload-Protocol", "resumable")
+
+	res, err := u.doSessionRequest(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	// Other known statuses, "final" and "cancelled", means that the session could not be resumed.
+	if status := res.Header.Get("X-Goog-Upload-Status"); status != "active" {
+		return 0, fmt.Errorf("upload session status is '%s'", status)
+	}
+	return strconv.ParseInt(res.Header.Get("X-Goog-Upload-Size-Received"), 10, 64)
+}
+
+// createSession starts a new upload session for the item and keeps it in the store.
+func (u *ResumableUploader) createSession(ctx context.Context, item FileItem, key string, fi os.FileInfo) (uploadSession, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", u.Endpoint, nil)
+	if err != nil {
+		return uploadSession{}, err
+	}
+	req.Header.Set("X-Goog-Upload-Command", "start")
+	req.Header.Set("X-Goog-Upload-Content-Type", "application/octet-stream")
+	req.Header.Set("X-Goog-Upload-File-Name", item.Name())
+	req.Header.Set("X-Goog-Upload-Protocol", "resumable")
+	req.Header.Set("X-Goog-Upload-Raw-Size", strconv.FormatInt(fi.Size(), 10))
+
+	res, err := u.doRequest(req)
+	if err != nil {
+		return uploadSession{}, err
+	}
+	defer res.Body.Close()
+
+	url := res.Header.Get("X-Goog-Upload-URL")
+	if url == "" {
+		return uploadSession{}, errors.New("upload session URL was not returned")
+	}
+	session := uploadSession{URL: url, Size: fi.Size(), ModTime: fi.ModTime().UnixNano()}
+	session.Granularity, _ = strconv.ParseInt(res.Header.Get("X-Goog-Upload-Chunk-Granularity"), 10, 64)
+	u.saveSession(key, session)
+	return session, nil
+}
+
+// upload sends the item content in chunks, starting at the session offset, and returns the upload token.
+// The offset is kept in the store after every chunk, and the session is removed once the upload has
+// finished successfully.
+func (u *ResumableUploader) upload(ctx context.Context, item FileItem, key string, session uploadSession) (string, error) {
+	chunkSize := u.chunkSize(session.Granularity)
+	for {
+		n := session.Size - session.Offset
+		if n > chunkSize {
+			n = chunkSize
+		}
+		last := session.Offset+n == session.Size
+
+		res, err := u.uploadChunk(ctx, item, session, n, last)
+		if err != nil {
+			return "", fmt.Errorf("uploading file: %w", err)
+		}
+		if last {
+			defer res.Body.Close()
+			b, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				return "", fmt.Errorf("reading upload token: %w", err)
+			}
+			u.store.Delete(key)
+			return string(b), nil
+		}
+		_ = res.Body.Close()
+		session.Offset += n
+		u.saveSession(key, session)
+	}
+}
+
+// uploadChunk sends n bytes of the item content, starting at the session offset. The last chunk finalizes
+// the upload, and its response has the upload token.
+func (u *ResumableUploader) uploadChunk(ctx context.Context, item FileItem, session uploadSession, n int64, last bool) (*http.Response, error) {
+	body, err := u.openChunk(ctx, item, session, n)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", session.URL, body)
+	if err != nil {
+		_ = body.Close()
+		return nil, err
+	}
+	// GetBody allows the request to be sent again, e.g. when it's retried after a transient error.
+	req.GetBody = func() (io.ReadCloser, error) {
+		return u.openChunk(ctx, item, session, n)
+	}
+	req.ContentLength = n
+	command := "upload"
+	if last {
+		command = "upload, finalize"
+	}
+	req.Header.Set("X-Goog-Upload-Command", command)
+	req.Header.Set("X-Goog-Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	req.Header.Set("X-Goog-Upload-Protocol", "resumable")
+	return u.doSessionRequest(req)
+}
+
+// chunkSize returns the size of the chunks, rounded down to a multiple of the granularity, if it's set.
+func (u *ResumableUploader) chunkSize(granularity int64) int64 {
+	size := u.ChunkSize
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+	if granularity <= 0 {
+		return size
+	}
+	if size < granularity {
+		return granularity
+	}
+	return size - size%granularity
+}
+
+// openChunk opens n bytes of the item content, starting at the session offset, and throttled by the RateLimiter
+// until ctx is done. It fails if the file has changed its size since the session was created. Transient errors
+// opening and reading the file are retried by FSRetry.
+func (u *ResumableUploader) openChunk(ctx context.Context, item FileItem, session uploadSession, n int64) (io.ReadCloser, error) {
+	var r io.ReadSeeker
+	var size int64
+	err := u.FSRetry.do(ctx, func() error {
+		var err error
+		r, size, err = item.Open()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	closer, ok := r.(io.Closer)
+	if !ok {
+		closer = ioutil.NopCloser(nil)
+	}
+	if size != session.Size {
+		_ = closer.Close()
+		return nil, fmt.Errorf("file '%s' has changed while uploading it", item)
+	}
+	if _, err := r.Seek(session.Offset, io.SeekStart); err != nil {
+		_ = closer.Close()
+		return nil, err
+	}
+	var reader io.Reader = io.LimitReader(u.FSRetry.reader(ctx, r), n)
+	if u.OnProgress != nil {
+		reader = &progressReader{r: reader, path: item.Path, fn: u.OnProgress}
+	}
+	return readCloser{Reader: u.RateLimiter.ReaderContext(ctx, reader), Closer: closer}, nil
+}
+
+// progressReader reports the bytes read from r.
+type progressReader struct {
+	r    io.Reader
+	path string
+	fn   func(path string, n int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.fn(p.path, int64(n))
+	}
+	return n, err
+}
+
+// readCloser groups a Reader and a Closer.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// saveSession keeps the upload session in the store.
+func (u *ResumableUploader) saveSession(key string, session uploadSession) {
+	b, err := json.Marshal(session)
+	if err != nil {
+		return
+	}
+	u.store.Set(key, b)
+}
+
+// doRequest executes the request. Any non-2xx status code is a *StatusError.
+func (u *ResumableUploader) doRequest(req *http.Request) (*http.Response, error) {
+	res, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= 200 && res.StatusCode <= 299 {
+		return res, nil
+	}
+	defer res.Body.Close()
+	return nil, &StatusError{StatusCode: res.StatusCode, Status: res.Status, Message: responseMessage(res.Body)}
+}
+
+// doSessionRequest executes a request to the URL of an existing upload session, like doRequest. 404 and 410
+// mean that the server doesn't recognize the session anymore, so errSessionExpired is returned.
+func (u *ResumableUploader) doSessionRequest(req *http.Request) (*http.Response, error) {
+	res, err := u.doRequest(req)
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && (statusErr.StatusCode == http.StatusNotFound || statusErr.StatusCode == http.StatusGone) {
+		return nil, errSessionExpired
+	}
+	return res, err
+}
+
+// maxMessageSize is the maximum size of the error message read from a response.
+const maxMessageSize = 4 * 1024
+
+// responseMessage returns the message of an error response, the body of the response or the message of
+// the JSON error returned by the Google APIs, e.g. {"error":{"code":429,"message":"Quota exceeded"}}.
+func responseMessage(body io.Reader) string {
+	b, _ := ioutil.ReadAll(io.LimitReader(body, maxMessageSize))
+	var apiErr struct {
+		Error struct {
+			Message string `json:"message"`
+			Status  string `json:"status"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(b, &apiErr) == nil && apiErr.Error.Message != "" {
+		return strings.TrimSpace(apiErr.Error.Status + " " + apiErr.Error.Message)
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// StatusError is returned when the server responds with an unexpected HTTP status code.
+type StatusError struct {
+	StatusCode int
+	Status     string
+	// Message is the message of the response, if any.
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("unexpected response: %s", e.Status)
+	}
+	return fmt.Sprintf("unexpected response: %s: %s", e.Status, e.Message)
+}
@@ -0,0 +1,650 @@
+package upload
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/transport"
+)
+
+const testFileContent = "this is content of existing file"
+
+func TestResumableUploader_UploadFile(t *testing.T) {
+	setAppFS(t, afero.NewMemMapFs())
+	if err := afero.WriteFile(appFS, "src/existent", []byte(testFileContent), 0644); err != nil {
+		t.Fatalf("error was not expected at this point: err=%s", err)
+	}
+
+	t.Run("ShouldUploadFileInANewSession", func(t *testing.T) {
+		srv := newMockedUploadServer(t, 0)
+		defer srv.Close()
+		store := newMockedSessionStore()
+
+		u := NewResumableUploader(http.DefaultClient, store, log.Discard)
+		u.Endpoint = srv.URL + "/uploads"
+
+		got, err := u.UploadFile(context.Background(), "src/existent")
+		if err != nil {
+			t.Fatalf("error was not expected: err=%s", err)
+		}
+		if got != "upload-token" {
+			t.Errorf("want: %s, got: %s", "upload-token", got)
+		}
+		if len(store.data) != 0 {
+			t.Errorf("upload session should be removed after the upload, got: %v", store.data)
+		}
+	})
+
+	t.Run("ShouldReportProgress", func(t *testing.T) {
+		srv := newMockedUploadServer(t, 0)
+		defer srv.Close()
+
+		var sent int64
+		u := NewResumableUploader(http.DefaultClient, newMockedSessionStore(), log.Discard)
+		u.Endpoint = srv.URL + "/uploads"
+		u.OnProgress = func(path string, n int64) {
+			if path != "src/existent" {
+				t.Errorf("want: %s, got: %s", "src/existent", path)
+			}
+			sent += n
+		}
+
+		if _, err := u.UploadFile(context.Background(), "src/existent"); err != nil {
+			t.Fatalf("error was not expected: err=%s", err)
+		}
+		if sent != int64(len(testFileContent)) {
+			t.Errorf("want: %d, got: %d", len(testFileContent), sent)
+		}
+	})
+
+	t.Run("ShouldResumeAPartialUpload", func(t *testing.T) {
+		// the server fails after receiving the first 10 bytes.
+		srv := newMockedUploadServer(t, 10)
+		defer srv.Close()
+		store := newMockedSessionStore()
+
+		u := NewResumableUploader(http.DefaultClient, store, log.Discard)
+		u.Endpoint = srv.URL + "/uploads"
+
+		if _, err := u.UploadFile(context.Background(), "src/existent"); err == nil {
+			t.Fatalf("error was expected, but not produced")
+		}
+		if len(store.data) != 1 {
+			t.Fatalf("upload session should be kept after a failed upload, got: %v", store.data)
+		}
+
+		got, err := u.UploadFile(context.Background(), "src/existent")
+		if err != nil {
+			t.Fatalf("error was not expected: err=%s", err)
+		}
+		if got != "upload-token" {
+			t.Errorf("want: %s, got: %s", "upload-token", got)
+		}
+		if srv.sessions != 1 {
+			t.Errorf("want: %d upload sessions, got: %d", 1, srv.sessions)
+		}
+		if srv.received != testFileContent {
+			t.Errorf("want: %s, got: %s", testFileContent, srv.received)
+		}
+	})
+
+	t.Run("ShouldResumeAfterTheLastUploadedChunk", func(t *testing.T) {
+		// the server fails the third chunk, like if the upload had crashed after two chunks.
+		srv := newMockedUploadServer(t, 0)
+		srv.failChunk = 3
+		defer srv.Close()
+		store := newMockedSessionStore()
+
+		u := NewResumableUploader(http.DefaultClient, store, log.Discard)
+		u.Endpoint = srv.URL + "/uploads"
+		u.ChunkSize = 10
+
+		if _, err := u.UploadFile(context.Background(), "src/existent"); err == nil {
+			t.Fatalf("error was expected, but not produced")
+		}
+		var session uploadSession
+		if err := json.Unmarshal(store.Get("src/existent"), &session); err != nil {
+			t.Fatalf("error was not expected at this point: err=%s", err)
+		}
+		if session.Offset != 20 {
+			t.Errorf("want: %d, got: %d", 20, session.Offset)
+		}
+
+		// a new uploader, like after a restart, only sends the chunks after the stored offset.
+		var sent int64
+		u = NewResumableUploader(http.DefaultClient, store, log.Discard)
+		u.Endpoint = srv.URL + "/uploads"
+		u.ChunkSize = 10
+		u.OnProgress = func(path string, n int64) {
+			sent += n
+		}
+
+		got, err := u.UploadFile(context.Background(), "src/existent")
+		if err != nil {
+			t.Fatalf("error was not expected: err=%s", err)
+		}
+		if got != "upload-token" {
+			t.Errorf("want: %s, got: %s", "upload-token", got)
+		}
+		if want := int64(len(testFileContent) - 20); sent != want {
+			t.Errorf("want: %d, got: %d", want, sent)
+		}
+		if srv.sessions != 1 {
+			t.Errorf("want: %d upload sessions, got: %d", 1, srv.sessions)
+		}
+		if srv.received != testFileContent {
+			t.Errorf("want: %s, got: %s", testFileContent, srv.received)
+		}
+		if len(store.data) != 0 {
+			t.Errorf("upload session should be removed after the upload, got: %v", store.data)
+		}
+	})
+
+	t.Run("ShouldStartANewSessionIfFileHasChanged", func(t *testing.T) {
+		if err := afero.WriteFile(appFS, "src/changing", []byte(testFileContent), 0644); err != nil {
+			t.Fatalf("error was not expected at this point: err=%s", err)
+		}
+		srv := newMockedUploadServer(t, 0)
+		srv.failChunk = 2
+		defer srv.Close()
+		store := newMockedSessionStore()
+
+		u := NewResumableUploader(http.DefaultClient, store, log.Discard)
+		u.Endpoint = srv.URL + "/uploads"
+		u.ChunkSize = 10
+
+		if _, err := u.UploadFile(context.Background(), "src/changing"); err == nil {
+			t.Fatalf("error was expected, but not produced")
+		}
+
+		// the file is larger than when its upload session was created.
+		changed := testFileContent + ", and more content"
+		if err := afero.WriteFile(appFS, "src/changing", []byte(changed), 0644); err != nil {
+			t.Fatalf("error was not expected at this point: err=%s", err)
+		}
+		srv.size = len(changed)
+
+		got, err := u.UploadFile(context.Background(), "src/changing")
+		if err != nil {
+			t.Fatalf("error was not expected: err=%s", err)
+		}
+		if got != "upload-token" {
+			t.Errorf("want: %s, got: %s", "upload-token", got)
+		}
+		if srv.sessions != 2 {
+			t.Errorf("want: %d upload sessions, got: %d", 2, srv.sessions)
+		}
+		if srv.received != changed {
+			t.Errorf("want: %s, got: %s", changed, srv.received)
+		}
+	})
+
+	t.Run("ShouldStartANewSessionIfExpired", func(t *testing.T) {
+		srv := newMockedUploadServer(t, 0)
+		defer srv.Close()
+		store := newMockedSessionStore()
+
+		u := NewResumableUploader(http.DefaultClient, store, log.Discard)
+		u.Endpoint = srv.URL + "/uploads"
+
+		fi, err := appFS.Stat("src/existent")
+		if err != nil {
+			t.Fatalf("error was not expected at this point: err=%s", err)
+		}
+		u.saveSession("src/existent", uploadSession{URL: srv.URL + "/expired-session", Offset: 10, Size: fi.Size(), ModTime: fi.ModTime().UnixNano()})
+
+		got, err := u.UploadFile(context.Background(), "src/existent")
+		if err != nil {
+			t.Fatalf("error was not expected: err=%s", err)
+		}
+		if got != "upload-token" {
+			t.Errorf("want: %s, got: %s", "upload-token", got)
+		}
+		if srv.received != testFileContent {
+			t.Errorf("want: %s, got: %s", testFileContent, srv.received)
+		}
+	})
+
+	t.Run("ShouldAbortIfContextIsCancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// the upload is in progress until the request is aborted.
+		uploading := make(chan struct{})
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("X-Goog-Upload-Command") == "start" {
+				w.Header().Set("X-Goog-Upload-URL", "http://"+r.Host+"/session")
+				return
+			}
+			// the request context is only cancelled on disconnection once the body has been read.
+			_, _ = ioutil.ReadAll(r.Body)
+			close(uploading)
+			<-r.Context().Done()
+		}))
+		defer srv.Close()
+		store := newMockedSessionStore()
+
+		u := NewResumableUploader(http.DefaultClient, store, log.Discard)
+		u.Endpoint = srv.URL + "/uploads"
+
+		errs := make(chan error, 1)
+		go func() {
+			_, err := u.UploadFile(ctx, "src/existent")
+			errs <- err
+		}()
+		<-uploading
+		cancel()
+
+		select {
+		case err := <-errs:
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("want: %v, got: %v", context.Canceled, err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("want: upload aborted, got: in progress")
+		}
+		if len(store.data) != 1 {
+			t.Errorf("upload session should be kept to resume the upload, got: %v", store.data)
+		}
+	})
+
+	t.Run("ShouldClassifyFailedResponses", func(t *testing.T) {
+		testCases := []struct {
+			status      int
+			body        string
+			want        error
+			wantMessage string
+		}{
+			{http.StatusTooManyRequests, `{"error":{"code":429,"message":"Quota exceeded","status":"RESOURCE_EXHAUSTED"}}`, ErrQuotaExceeded, "RESOURCE_EXHAUSTED Quota exceeded"},
+			{http.StatusUnauthorized, `{"error":{"code":401,"message":"Invalid credentials","status":"UNAUTHENTICATED"}}`, ErrUnauthorized, "UNAUTHENTICATED Invalid credentials"},
+			{http.StatusBadRequest, "Invalid media\n", ErrUnsupportedMedia, "Invalid media"},
+			{http.StatusInternalServerError, "", ErrNetwork, ""},
+		}
+		for _, tc := range testCases {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.status)
+				_, _ = w.Write([]byte(tc.body))
+			}))
+			u := NewResumableUploader(http.DefaultClient, newMockedSessionStore(), log.Discard)
+			u.Endpoint = srv.URL + "/uploads"
+
+			_, err := u.UploadFile(context.Background(), "src/existent")
+			srv.Close()
+			err = ClassifyError(err)
+			if !errors.Is(err, tc.want) {
+				t.Errorf("want: %s, got: %v, status: %d", tc.want, err, tc.status)
+			}
+			var statusErr *StatusError
+			if !errors.As(err, &statusErr) || statusErr.StatusCode != tc.status || statusErr.Message != tc.wantMessage {
+				t.Errorf("want: %d %s, got: %v", tc.status, tc.wantMessage, err)
+			}
+		}
+	})
+
+	t.Run("ShouldFailIfTheSessionCouldNotBeCreated", func(t *testing.T) {
+		// e.g. the endpoint is wrong, it's not an expired session.
+		srv := httptest.NewServer(http.NotFoundHandler())
+		defer srv.Close()
+		u := NewResumableUploader(http.DefaultClient, newMockedSessionStore(), log.Discard)
+		u.Endpoint = srv.URL + "/uploads"
+
+		_, err := u.UploadFile(context.Background(), "src/existent")
+		if errors.Is(err, errSessionExpired) {
+			t.Errorf("want: not %v, got: %v", errSessionExpired, err)
+		}
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+			t.Errorf("want: %d, got: %v", http.StatusNotFound, err)
+		}
+	})
+
+	t.Run("ShouldFailIfFileDoesNotExist", func(t *testing.T) {
+		u := NewResumableUploader(http.DefaultClient, newMockedSessionStore(), log.Discard)
+		if _, err := u.UploadFile(context.Background(), "src/non-existent"); err == nil {
+			t.Errorf("error was expected, but not produced")
+		}
+	})
+}
+
+func TestResumableUploader_UploadFileStreamsContent(t *testing.T) {
+	content := strings.Repeat("0123456789abcdef", 20*1024*1024/16)
+	fs := &countingFs{Fs: afero.NewMemMapFs()}
+	setAppFS(t, fs)
+	if err := afero.WriteFile(appFS, "src/large-video.mp4", []byte(content), 0644); err != nil {
+		t.Fatalf("error was not expected at this point: err=%s", err)
+	}
+
+	srv := newMockedUploadServer(t, 0)
+	defer srv.Close()
+	srv.size = len(content)
+	// the first chunk fails, so it's retried by the transport.
+	srv.failChunk = 1
+
+	var lengths []int64
+	client := &requestRecorder{
+		client: &http.Client{Transport: transport.NewRetry(http.DefaultTransport, 1, time.Millisecond)},
+		fn: func(req *http.Request) {
+			if req.Header.Get("X-Goog-Upload-Command") != "start" {
+				lengths = append(lengths, req.ContentLength)
+			}
+		},
+	}
+	u := NewResumableUploader(client, newMockedSessionStore(), log.Discard)
+	u.Endpoint = srv.URL + "/uploads"
+
+	if _, err := u.UploadFile(context.Background(), "src/large-video.mp4"); err != nil {
+		t.Fatalf("error was not expected: err=%s", err)
+	}
+
+	if srv.received != content {
+		t.Errorf("want: %d bytes received, got: %d", len(content), len(srv.received))
+	}
+	if want := []int64{DefaultChunkSize, DefaultChunkSize, int64(len(content)) - 2*DefaultChunkSize}; !reflect.DeepEqual(want, lengths) {
+		t.Errorf("want: %v, got: %v", want, lengths)
+	}
+	// the retried chunk is read again from the file, starting at its offset.
+	if want := []int64{0, 0, DefaultChunkSize, 2 * DefaultChunkSize}; !reflect.DeepEqual(want, fs.seeks) {
+		t.Errorf("want: %v, got: %v", want, fs.seeks)
+	}
+	if want := int64(len(content) + DefaultChunkSize); fs.read != want {
+		t.Errorf("want: %d bytes read, got: %d", want, fs.read)
+	}
+	// the content is read in small pieces, instead of buffering it.
+	if fs.maxRead >= DefaultChunkSize {
+		t.Errorf("want: reads smaller than a chunk, got: %d bytes", fs.maxRead)
+	}
+}
+
+func TestResumableUploader_UploadFileFSRetry(t *testing.T) {
+	eagain := &os.PathError{Op: "open", Path: "src/existent", Err: syscall.EAGAIN}
+	testCases := []struct {
+		name         string
+		retries      int
+		openFailures int
+		openErr      error
+		readFailures int
+		wantOpens    int
+		wantErr      bool
+	}{
+		{"Should upload the file once opening it succeeds", 3, 2, eagain, 0, 3, false},
+		{"Should upload the file once reading it succeeds", 3, 0, nil, 2, 1, false},
+		{"Should fail if opening it fails more times than the retries", 1, 2, eagain, 0, 2, true},
+		{"Should fail without retries if they are disabled", 0, 1, eagain, 0, 1, true},
+		{"Should fail without retries if the file does not exist", 3, 1, &os.PathError{Op: "open", Path: "src/existent", Err: syscall.ENOENT}, 0, 1, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fs := &flakyFs{Fs: afero.NewMemMapFs(), openFailures: tc.openFailures, openErr: tc.openErr, readFailures: tc.readFailures}
+			setAppFS(t, fs)
+			if err := afero.WriteFile(appFS, "src/existent", []byte(testFileContent), 0644); err != nil {
+				t.Fatalf("error was not expected at this point: err=%s", err)
+			}
+			srv := newMockedUploadServer(t, 0)
+			defer srv.Close()
+
+			u := NewResumableUploader(http.DefaultClient, newMockedSessionStore(), log.Discard)
+			u.Endpoint = srv.URL + "/uploads"
+			u.FSRetry = FSRetry{Retries: tc.retries, Delay: time.Millisecond}
+
+			_, err := u.UploadFile(context.Background(), "src/existent")
+			if tc.wantErr && err == nil {
+				t.Errorf("error was expected, but not produced")
+			}
+			if !tc.wantErr && err != nil {
+				t.Fatalf("error was not expected: err=%s", err)
+			}
+			if !tc.wantErr && srv.received != testFileContent {
+				t.Errorf("want: %s, got: %s", testFileContent, srv.received)
+			}
+			if fs.opens != tc.wantOpens {
+				t.Errorf("want: %d opens, got: %d", tc.wantOpens, fs.opens)
+			}
+		})
+	}
+}
+
+func TestIsTransientFSError(t *testing.T) {
+	testCases := []struct {
+		err  error
+		want bool
+	}{
+		{&os.PathError{Op: "open", Path: "photo.jpg", Err: syscall.EAGAIN}, true},
+		{&os.PathError{Op: "read", Path: "photo.jpg", Err: syscall.EIO}, true},
+		{fmt.Errorf("reading: %w", syscall.ESTALE), true},
+		{&os.PathError{Op: "open", Path: "photo.jpg", Err: syscall.ENOENT}, false},
+		{&os.PathError{Op: "open", Path: "photo.jpg", Err: syscall.EACCES}, false},
+		{errors.New("file has changed"), false},
+		{nil, false},
+	}
+	for _, tc := range testCases {
+		if got := IsTransientFSError(tc.err); got != tc.want {
+			t.Errorf("want: %t, got: %t, err: %v", tc.want, got, tc.err)
+		}
+	}
+}
+
+// flakyFs fails opening its files openFailures times with openErr, and reading them readFailures times with EIO.
+type flakyFs struct {
+	afero.Fs
+
+	mu           sync.Mutex
+	opens        int
+	openFailures int
+	openErr      error
+	readFailures int
+}
+
+func (fs *flakyFs) Open(name string) (afero.File, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.opens++
+	if fs.openFailures > 0 {
+		fs.openFailures--
+		return nil, fs.openErr
+	}
+	f, err := fs.Fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &flakyFile{File: f, fs: fs}, nil
+}
+
+type flakyFile struct {
+	afero.File
+	fs *flakyFs
+}
+
+func (f *flakyFile) Read(b []byte) (int, error) {
+	f.fs.mu.Lock()
+	if f.fs.readFailures > 0 {
+		f.fs.readFailures--
+		f.fs.mu.Unlock()
+		return 0, &os.PathError{Op: "read", Path: f.Name(), Err: syscall.EIO}
+	}
+	f.fs.mu.Unlock()
+	return f.File.Read(b)
+}
+
+// requestRecorder calls fn with every request before sending it with the client.
+type requestRecorder struct {
+	client HttpClient
+	fn     func(req *http.Request)
+}
+
+func (r *requestRecorder) Do(req *http.Request) (*http.Response, error) {
+	r.fn(req)
+	return r.client.Do(req)
+}
+
+// countingFs counts the bytes read from its files, and records the offsets they are read from.
+type countingFs struct {
+	afero.Fs
+
+	mu      sync.Mutex
+	read    int64
+	maxRead int
+	seeks   []int64
+}
+
+func (fs *countingFs) Open(name string) (afero.File, error) {
+	f, err := fs.Fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &countingFile{File: f, fs: fs}, nil
+}
+
+type countingFile struct {
+	afero.File
+	fs *countingFs
+}
+
+func (f *countingFile) Read(b []byte) (int, error) {
+	n, err := f.File.Read(b)
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	f.fs.read += int64(n)
+	if n > f.fs.maxRead {
+		f.fs.maxRead = n
+	}
+	return n, err
+}
+
+func (f *countingFile) Seek(offset int64, whence int) (int64, error) {
+	f.fs.mu.Lock()
+	f.fs.seeks = append(f.fs.seeks, offset)
+	f.fs.mu.Unlock()
+	return f.File.Seek(offset, whence)
+}
+
+func TestResumableUploader_chunkSize(t *testing.T) {
+	testCases := []struct {
+		chunkSize   int64
+		granularity int64
+		want        int64
+	}{
+		{0, 0, DefaultChunkSize},
+		{1000, 0, 1000},
+		{1000, 256, 768},
+		{100, 256, 256},
+		{DefaultChunkSize, 256 * 1024, DefaultChunkSize},
+	}
+	for _, tc := range testCases {
+		u := ResumableUploader{ChunkSize: tc.chunkSize}
+		if got := u.chunkSize(tc.granularity); got != tc.want {
+			t.Errorf("want: %d, got: %d, chunkSize: %d, granularity: %d", tc.want, got, tc.chunkSize, tc.granularity)
+		}
+	}
+}
+
+// mockedUploadServer mocks the Google Photos upload endpoint.
+// If failAfter is greater than zero, the first upload fails after receiving failAfter bytes.
+// If failChunk is greater than zero, the upload of that chunk, starting at one, fails once.
+type mockedUploadServer struct {
+	*httptest.Server
+	t         *testing.T
+	failAfter int
+	failChunk int
+	// size is the expected size of the uploaded file.
+	size     int
+	sessions int
+	chunks   int
+	received string
+}
+
+func newMockedUploadServer(t *testing.T, failAfter int) *mockedUploadServer {
+	ms := &mockedUploadServer{t: t, failAfter: failAfter, size: len(testFileContent)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/uploads", ms.handleUploads)
+	mux.HandleFunc("/session", ms.handleSession)
+	mux.HandleFunc("/expired-session", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	ms.Server = httptest.NewServer(mux)
+	return ms
+}
+
+func (ms *mockedUploadServer) handleUploads(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Goog-Upload-Command") != "start" || r.Header.Get("X-Goog-Upload-Raw-Size") != strconv.Itoa(ms.size) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	ms.sessions++
+	ms.received = ""
+	w.Header().Set("X-Goog-Upload-URL", ms.URL+"/session")
+}
+
+func (ms *mockedUploadServer) handleSession(w http.ResponseWriter, r *http.Request) {
+	switch r.Header.Get("X-Goog-Upload-Command") {
+	case "query":
+		w.Header().Set("X-Goog-Upload-Status", "active")
+		w.Header().Set("X-Goog-Upload-Size-Received", strconv.Itoa(len(ms.received)))
+	case "upload", "upload, finalize":
+		if r.Header.Get("X-Goog-Upload-Offset") != strconv.Itoa(len(ms.received)) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			ms.t.Fatalf("error was not expected at this point: err=%s", err)
+		}
+		ms.chunks++
+		if ms.chunks == ms.failChunk {
+			ms.failChunk = 0
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("X-Goog-Upload-Command") == "upload" {
+			ms.received += string(b)
+			return
+		}
+		if ms.failAfter > 0 {
+			ms.received += string(b[:ms.failAfter])
+			ms.failAfter = 0
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		ms.received += string(b)
+		_, _ = w.Write([]byte("upload-token"))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// mockedSessionStore mocks a store of upload sessions.
+type mockedSessionStore struct {
+	data map[string][]byte
+}
+
+func newMockedSessionStore() *mockedSessionStore {
+	return &mockedSessionStore{data: make(map[string][]byte)}
+}
+
+func (s *mockedSessionStore) Get(key string) []byte {
+	return s.data[key]
+}
+
+func (s *mockedSessionStore) Set(key string, value []byte) {
+	s.data[key] = value
+}
+
+func (s *mockedSessionStore) Delete(key string) {
+	delete(s.data, key)
+}
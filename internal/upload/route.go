@@ -0,0 +1,59 @@
+package upload
+
+import (
+	"fmt"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/filter"
+)
+
+// Route uploads the files allowed by its filters to an account other than the one of the job.
+type Route struct {
+	// Account is the account where the files are uploaded, the one of the job if it's empty.
+	Account string
+	// Album, if it's set, is the album of the files, instead of the one given by CreateAlbums or Albums.
+	Album string
+
+	// Filter and MIMEFilter, if they are set, are the patterns and the content types of the files of the route.
+	// The route matches all the files if none of them is set.
+	Filter     FileFilterer
+	MIMEFilter *filter.MIMEFilter
+}
+
+// NewRoute returns the route compiling the patterns and the content types of its files.
+// The error references the account.
+func NewRoute(account string, album string, patterns []string, mimeTypes []string) (Route, error) {
+	r := Route{Account: account, Album: album}
+	if len(patterns) > 0 {
+		f, err := filter.Compile(patterns, nil)
+		if err != nil {
+			return Route{}, fmt.Errorf("patterns of route to account '%s' are invalid: %s", account, err)
+		}
+		r.Filter = f
+	}
+	if len(mimeTypes) > 0 {
+		f, err := filter.NewMIMEFilter(mimeTypes, nil)
+		if err != nil {
+			return Route{}, fmt.Errorf("content types of route to account '%s' are invalid: %s", account, err)
+		}
+		r.MIMEFilter = f
+	}
+	return r, nil
+}
+
+// route returns the first route matching the file, fp is the file path and path is relative to SourceFolder.
+// The content type is detected with MIMEDetection, like for Limits. It returns false if none of them matches it.
+func (job *UploadFolderJob) route(fp string, path string) (Route, bool) {
+	for _, r := range job.Routes {
+		if r.Filter != nil && !r.Filter.IsAllowed(path) {
+			continue
+		}
+		if r.MIMEFilter != nil {
+			mimeType, err := job.MIMEDetection.ContentType(fp)
+			if err != nil || !r.MIMEFilter.IsAllowed(mimeType) {
+				continue
+			}
+		}
+		return r, true
+	}
+	return Route{}, false
+}
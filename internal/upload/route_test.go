@@ -0,0 +1,68 @@
+package upload
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUploadFolderJob_Route(t *testing.T) {
+	dir, err := ioutil.TempDir("", "route")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	raw, err := NewRoute("archive@domain.com", "", []string{"_RAW_EXTENSIONS_"}, nil)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	videos, err := NewRoute("videos@domain.com", "Videos", nil, []string{"video/*"})
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	job := UploadFolderJob{
+		MIMEDetection: MIMEDetectionExtension,
+		Routes:        []Route{raw, videos},
+	}
+
+	testCases := []struct {
+		path        string
+		wantAccount string
+		wantAlbum   string
+		wantRouted  bool
+	}{
+		{"IMG_0001.CR2", "archive@domain.com", "", true},
+		{"Trips/IMG_0002.raf", "archive@domain.com", "", true},
+		{"VID_0003.mp4", "videos@domain.com", "Videos", true},
+		{"IMG_0004.jpg", "", "", false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			fp := filepath.Join(dir, tc.path)
+			got, routed := job.route(fp, tc.path)
+			if routed != tc.wantRouted {
+				t.Errorf("want: %t, got: %t", tc.wantRouted, routed)
+			}
+			if got.Account != tc.wantAccount || got.Album != tc.wantAlbum {
+				t.Errorf("want: %s '%s', got: %s '%s'", tc.wantAccount, tc.wantAlbum, got.Account, got.Album)
+			}
+		})
+	}
+}
+
+func TestNewRoute(t *testing.T) {
+	if _, err := NewRoute("archive@domain.com", "", nil, []string{"video"}); err == nil {
+		t.Errorf("error was expected, but not produced")
+	}
+	r, err := NewRoute("archive@domain.com", "", nil, nil)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	// routes without patterns and content types match all the files.
+	job := UploadFolderJob{Routes: []Route{r}}
+	if got, routed := job.route("IMG_0001.jpg", "IMG_0001.jpg"); !routed || got.Account != "archive@domain.com" {
+		t.Errorf("want: %s, got: %s", "archive@domain.com", got.Account)
+	}
+}
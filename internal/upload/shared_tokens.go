@@ -0,0 +1,135 @@
+package upload
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultSharedUploadTimeout is the time a file waits for the upload of another file with the same content, before
+// uploading its own bytes.
+const DefaultSharedUploadTimeout = 10 * time.Minute
+
+// SharedTokens shares the upload tokens of the files with the same content uploaded in the run, e.g. copies of a
+// photo in different folders, so their bytes are uploaded once, but every file creates its own media item, e.g.
+// in a different album. Files are identified by the SHA-256 of their content.
+//
+// A file whose content is being uploaded by another one waits for it, up to Timeout, and reuses its upload token.
+// If that upload fails, or it takes longer, the file uploads its own bytes. Tokens are kept in memory, so they are
+// only shared in the same run, and only by the files of the same account. It's safe for concurrent use.
+type SharedTokens struct {
+	// Timeout is the time a file waits for the upload of another file with the same content.
+	// Uses DefaultSharedUploadTimeout by default.
+	Timeout time.Duration
+
+	// TokenLifetime is the time an upload token is shared for. Uses DefaultUploadTokenLifetime by default.
+	TokenLifetime time.Duration
+
+	// Hashes, if it's set, keeps the hashes of the files for the run, so the files are not read again to be hashed.
+	Hashes *ContentHashes
+
+	mu sync.Mutex
+	// uploads are the uploads of the files, in progress or finished, by their content hash.
+	uploads map[string]*sharedUpload
+
+	// now returns the current time.
+	// Useful for testing.
+	now func() time.Time
+}
+
+// sharedUpload is the upload of the bytes of a file, whose token is shared with the files with the same content.
+type sharedUpload struct {
+	// done is closed once the upload has finished, token and err are set then.
+	done       chan struct{}
+	token      string
+	err        error
+	uploadedAt time.Time
+}
+
+// NewSharedTokens returns the SharedTokens without any file uploaded.
+func NewSharedTokens() *SharedTokens {
+	return &SharedTokens{
+		uploads: make(map[string]*sharedUpload),
+		now:     time.Now,
+	}
+}
+
+// Upload returns the upload token of the file. It's the token of another file with the same content, if it has been
+// uploaded in the run, or it's being uploaded and it finishes before Timeout. Otherwise, the bytes are uploaded by
+// upload. shared is true if the token has been uploaded by another file.
+// Files whose content could not be read are uploaded, without sharing their token.
+func (s *SharedTokens) Upload(ctx context.Context, filePath string, upload func() (string, error)) (token string, shared bool, err error) {
+	hash, err := s.Hashes.Hash(filePath)
+	if err != nil {
+		token, err = upload()
+		return token, false, err
+	}
+
+	s.mu.Lock()
+	u, ok := s.uploads[hash]
+	if ok && u.finished() && (u.err != nil || s.now().Sub(u.uploadedAt) >= s.tokenLifetime()) {
+		ok = false
+	}
+	if !ok {
+		// the file uploads its bytes for the files with the same content.
+		u = &sharedUpload{done: make(chan struct{})}
+		s.uploads[hash] = u
+		s.mu.Unlock()
+
+		u.token, u.err = upload()
+		u.uploadedAt = s.now()
+		close(u.done)
+		return u.token, false, u.err
+	}
+	s.mu.Unlock()
+
+	timer := time.NewTimer(s.timeout())
+	defer timer.Stop()
+	select {
+	case <-u.done:
+		if u.err == nil {
+			return u.token, true, nil
+		}
+	case <-timer.C:
+	case <-ctx.Done():
+		return "", false, ctx.Err()
+	}
+	// the file is not waited for anymore, so it uploads its own bytes.
+	token, err = upload()
+	return token, false, err
+}
+
+// Forget stops sharing the upload token, e.g. because it has been rejected.
+func (s *SharedTokens) Forget(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for hash, u := range s.uploads {
+		if u.finished() && u.token == token {
+			delete(s.uploads, hash)
+		}
+	}
+}
+
+func (s *SharedTokens) timeout() time.Duration {
+	if s.Timeout <= 0 {
+		return DefaultSharedUploadTimeout
+	}
+	return s.Timeout
+}
+
+func (s *SharedTokens) tokenLifetime() time.Duration {
+	if s.TokenLifetime <= 0 {
+		return DefaultUploadTokenLifetime
+	}
+	return s.TokenLifetime
+}
+
+// finished returns true once the upload has finished, with or without error.
+func (u *sharedUpload) finished() bool {
+	select {
+	case <-u.done:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,139 @@
+package upload_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/upload"
+)
+
+func TestSharedTokens_Upload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"IMG_0001.jpg":      "photo",
+		"IMG_0001 copy.jpg": "photo",
+		"IMG_0002.jpg":      "other photo",
+	})
+	s := upload.NewSharedTokens()
+
+	// the copy waits for the upload of the same content in progress.
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		token, shared, err := s.Upload(context.Background(), filepath.Join(dir, "IMG_0001.jpg"), func() (string, error) {
+			close(started)
+			<-release
+			return "token-1", nil
+		})
+		if err != nil || token != "token-1" || shared {
+			t.Errorf("want: token-1 uploaded, got: %s, shared=%t, err=%v", token, shared, err)
+		}
+	}()
+	<-started
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+	}()
+	token, shared, err := s.Upload(context.Background(), filepath.Join(dir, "IMG_0001 copy.jpg"), func() (string, error) {
+		t.Errorf("file should not be uploaded, its content is being uploaded already")
+		return "token-2", nil
+	})
+	wg.Wait()
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if token != "token-1" || !shared {
+		t.Errorf("want: %s shared, got: %s, shared=%t", "token-1", token, shared)
+	}
+
+	// the token is shared once the upload has finished too, but not by files with another content.
+	if token, shared, _ := s.Upload(context.Background(), filepath.Join(dir, "IMG_0001 copy.jpg"), func() (string, error) {
+		return "token-3", nil
+	}); token != "token-1" || !shared {
+		t.Errorf("want: %s shared, got: %s, shared=%t", "token-1", token, shared)
+	}
+	if token, shared, _ := s.Upload(context.Background(), filepath.Join(dir, "IMG_0002.jpg"), func() (string, error) {
+		return "token-4", nil
+	}); token != "token-4" || shared {
+		t.Errorf("want: %s uploaded, got: %s, shared=%t", "token-4", token, shared)
+	}
+
+	// rejected tokens are not shared anymore.
+	s.Forget("token-1")
+	if token, shared, _ := s.Upload(context.Background(), filepath.Join(dir, "IMG_0001 copy.jpg"), func() (string, error) {
+		return "token-5", nil
+	}); token != "token-5" || shared {
+		t.Errorf("want: %s uploaded, got: %s, shared=%t", "token-5", token, shared)
+	}
+}
+
+func TestSharedTokens_UploadFailed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"IMG_0001.jpg":      "photo",
+		"IMG_0001 copy.jpg": "photo",
+	})
+
+	testCases := []struct {
+		name    string
+		timeout time.Duration
+		err     error
+	}{
+		{"Should upload the file if the other upload fails", time.Minute, errors.New("503 Service Unavailable")},
+		{"Should upload the file if the other upload times out", 20 * time.Millisecond, nil},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := upload.NewSharedTokens()
+			s.Timeout = tc.timeout
+
+			started := make(chan struct{})
+			release := make(chan struct{})
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				_, _, _ = s.Upload(context.Background(), filepath.Join(dir, "IMG_0001.jpg"), func() (string, error) {
+					close(started)
+					<-release
+					return "token-1", tc.err
+				})
+			}()
+			<-started
+			if tc.err != nil {
+				close(release)
+				<-done
+			}
+
+			token, shared, err := s.Upload(context.Background(), filepath.Join(dir, "IMG_0001 copy.jpg"), func() (string, error) {
+				return "token-2", nil
+			})
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			if token != "token-2" || shared {
+				t.Errorf("want: %s uploaded, got: %s, shared=%t", "token-2", token, shared)
+			}
+			if tc.err == nil {
+				close(release)
+				<-done
+			}
+		})
+	}
+}
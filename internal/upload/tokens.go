@@ -0,0 +1,249 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gphotosuploader/google-photos-api-client-go/v2/media_items"
+)
+
+// DefaultUploadTokenLifetime is the time an upload token is reused for. Google Photos keeps the uploaded
+// bytes for a day, so some margin is left to create the media item.
+const DefaultUploadTokenLifetime = 23 * time.Hour
+
+// MediaUploader represents a way to upload the bytes of a file, getting its upload token.
+type MediaUploader interface {
+	UploadFile(ctx context.Context, filePath string) (uploadToken string, err error)
+}
+
+// MediaItemCreator represents a way to create the media item of an uploaded file in an album.
+type MediaItemCreator interface {
+	CreateToAlbum(ctx context.Context, albumId string, mediaItem media_items.SimpleMediaItem) (media_items.MediaItem, error)
+}
+
+// DescribedMediaItemCreator represents a way to create the media item of an uploaded file with a description.
+type DescribedMediaItemCreator interface {
+	CreateToAlbumWithDescription(ctx context.Context, albumId string, mediaItem media_items.SimpleMediaItem, description string) (media_items.MediaItem, error)
+}
+
+// MediaItemFinder represents a way to find the media item of a file in the library, searching it again, e.g.
+// library.Matcher.
+type MediaItemFinder interface {
+	Recheck(ctx context.Context, path string) (mediaItemID string, found bool, err error)
+}
+
+// TokenReusingUploads uploads files to albums, like gphotos.Client does, but it keeps the upload token of
+// the files whose media item could not be created because of a transient failure, e.g. a 500 response.
+// The next attempt to upload the file only creates its media item, instead of uploading its bytes again,
+// unless the file has changed or the token is older than TokenLifetime.
+// Tokens are kept in memory, so they are only reused in the same run. It's safe for concurrent use.
+//
+// The API doesn't accept idempotency keys, so a media item could be created twice if the response of a
+// successful request is lost, e.g. because of a timeout. Before creating it again, the library is searched by
+// Finder, if it's set. That only finds files matched by library.Matcher, others could still be duplicated.
+// For that reason, transport.Retry doesn't retry the requests creating media items, they are retried here.
+type TokenReusingUploads struct {
+	Uploader   MediaUploader
+	MediaItems MediaItemCreator
+	// Finder, if it's set, finds the media items that could have been created by a failed attempt.
+	Finder MediaItemFinder
+	// Retries is the number of times the media item is created again, with the same upload token, if it could
+	// have been created by the failed attempt, e.g. a connection error or a 500 response. They are only retried
+	// if Finder is set, the library is searched before every retry.
+	Retries int
+	// RetryDelay is the delay before the first retry, doubled for every other one.
+	RetryDelay time.Duration
+	// Shared, if it's set, shares the upload tokens of the files with the same content, so their bytes are
+	// uploaded once. Uploads of the same account should use the same one.
+	Shared *SharedTokens
+
+	// TokenLifetime is the time an upload token is reused for. Uses DefaultUploadTokenLifetime by default.
+	TokenLifetime time.Duration
+
+	mu sync.Mutex
+	// tokens are the upload tokens of the files, by the key of their content.
+	tokens map[string]uploadToken
+
+	// now returns the current time.
+	// Useful for testing.
+	now func() time.Time
+}
+
+// uploadToken is the upload token of a file, whose media item has not been created yet.
+type uploadToken struct {
+	token      string
+	uploadedAt time.Time
+	// unconfirmed is true if the media item could have been created, since the response of the failed
+	// attempt didn't confirm the opposite, e.g. because it timed out.
+	unconfirmed bool
+}
+
+// NewTokenReusingUploads returns the uploads using the uploader and the media items service of a gphotos.Client.
+func NewTokenReusingUploads(uploader MediaUploader, mediaItems MediaItemCreator) *TokenReusingUploads {
+	return &TokenReusingUploads{
+		Uploader:   uploader,
+		MediaItems: mediaItems,
+		tokens:     make(map[string]uploadToken),
+		now:        time.Now,
+	}
+}
+
+// UploadFileToAlbum uploads the file, or reuses its previous upload token, and creates its media item in the album.
+func (u *TokenReusingUploads) UploadFileToAlbum(ctx context.Context, albumId string, filePath string) (media_items.MediaItem, error) {
+	return u.UploadFileToAlbumWithDescription(ctx, albumId, filePath, "")
+}
+
+// UploadFileToAlbumWithDescription is like UploadFileToAlbum, but the media item is created with the description,
+// if it's not empty. It fails if MediaItems is not a DescribedMediaItemCreator.
+func (u *TokenReusingUploads) UploadFileToAlbumWithDescription(ctx context.Context, albumId string, filePath string, description string) (media_items.MediaItem, error) {
+	var described DescribedMediaItemCreator
+	if description != "" {
+		var ok bool
+		if described, ok = u.MediaItems.(DescribedMediaItemCreator); !ok {
+			return media_items.MediaItem{}, errors.New("media items with a description are not supported")
+		}
+	}
+
+	// files whose size or modification time could not be read are always uploaded.
+	key, keyErr := contentKey(FileItem{Path: filePath})
+	var previous uploadToken
+	token, reused := "", false
+	if keyErr == nil {
+		previous, reused = u.token(key)
+		token = previous.token
+	}
+	if reused && previous.unconfirmed && u.Finder != nil {
+		// the file is created again if the library could not be searched.
+		if id, found, err := u.Finder.Recheck(ctx, filePath); err == nil && found {
+			u.forget(key)
+			return media_items.MediaItem{ID: id, Filename: filepath.Base(filePath)}, nil
+		}
+	}
+	var err error
+	if !reused {
+		token, err = u.uploadFile(ctx, filePath)
+		if err != nil {
+			return media_items.MediaItem{}, err
+		}
+	}
+
+	simple := media_items.SimpleMediaItem{
+		UploadToken: token,
+		FileName:    filePath,
+	}
+	mediaItem, err := u.create(ctx, albumId, simple, described, description)
+	for retry := 0; err != nil && isUnconfirmed(err) && u.Finder != nil && retry < u.Retries; retry++ {
+		select {
+		case <-ctx.Done():
+			return mediaItem, err
+		case <-time.After(u.RetryDelay << uint(retry)):
+		}
+		if id, found, err := u.Finder.Recheck(ctx, filePath); err == nil && found {
+			if keyErr == nil {
+				u.forget(key)
+			}
+			return media_items.MediaItem{ID: id, Filename: filepath.Base(filePath)}, nil
+		}
+		mediaItem, err = u.create(ctx, albumId, simple, described, description)
+	}
+	if keyErr != nil {
+		return mediaItem, err
+	}
+	switch {
+	case err == nil:
+		u.forget(key)
+	case isTransient(err):
+		if !reused {
+			u.keep(key, uploadToken{token: token, uploadedAt: u.now(), unconfirmed: isUnconfirmed(err)})
+		} else if isUnconfirmed(err) && !previous.unconfirmed {
+			previous.unconfirmed = true
+			u.keep(key, previous)
+		}
+	default:
+		// the token could have been rejected, so the file is uploaded again on the next attempt.
+		u.forget(key)
+		if u.Shared != nil {
+			u.Shared.Forget(token)
+		}
+	}
+	return mediaItem, err
+}
+
+// create creates the media item of the uploaded file in the album, with the description if described is set.
+func (u *TokenReusingUploads) create(ctx context.Context, albumId string, simple media_items.SimpleMediaItem, described DescribedMediaItemCreator, description string) (media_items.MediaItem, error) {
+	if described != nil {
+		return described.CreateToAlbumWithDescription(ctx, albumId, simple, description)
+	}
+	return u.MediaItems.CreateToAlbum(ctx, albumId, simple)
+}
+
+// uploadFile uploads the bytes of the file, returning its upload token. The token of another file with the same
+// content is returned instead, if Shared is set and it has one.
+func (u *TokenReusingUploads) uploadFile(ctx context.Context, filePath string) (string, error) {
+	if u.Shared == nil {
+		return u.Uploader.UploadFile(ctx, filePath)
+	}
+	token, _, err := u.Shared.Upload(ctx, filePath, func() (string, error) {
+		return u.Uploader.UploadFile(ctx, filePath)
+	})
+	return token, err
+}
+
+// token returns the upload token of the file, if it has not expired.
+func (u *TokenReusingUploads) token(key string) (uploadToken, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	t, ok := u.tokens[key]
+	if !ok {
+		return uploadToken{}, false
+	}
+	if u.now().Sub(t.uploadedAt) >= u.tokenLifetime() {
+		delete(u.tokens, key)
+		return uploadToken{}, false
+	}
+	return t, true
+}
+
+func (u *TokenReusingUploads) keep(key string, token uploadToken) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.tokens[key] = token
+}
+
+func (u *TokenReusingUploads) forget(key string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	delete(u.tokens, key)
+}
+
+func (u *TokenReusingUploads) tokenLifetime() time.Duration {
+	if u.TokenLifetime <= 0 {
+		return DefaultUploadTokenLifetime
+	}
+	return u.TokenLifetime
+}
+
+// isTransient returns true if the request could succeed if it's retried, with the same upload token.
+func isTransient(err error) bool {
+	err = ClassifyError(err)
+	return errors.Is(err, ErrNetwork) || errors.Is(err, ErrQuotaExceeded)
+}
+
+// isUnconfirmed returns true if the request could have succeeded despite the error, e.g. a timeout or a 500
+// response, as opposed to a throttled request, which is refused before being processed.
+func isUnconfirmed(err error) bool {
+	return errors.Is(ClassifyError(err), ErrNetwork)
+}
+
+// contentKey returns the key identifying the content of an item, based on its path, size and modification time.
+func contentKey(item FileItem) (string, error) {
+	fi, err := appFS.Stat(item.Path)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s|%d|%d", item.Path, fi.Size(), fi.ModTime().UnixNano()), nil
+}
@@ -0,0 +1,306 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gphotosuploader/google-photos-api-client-go/v2/media_items"
+	"github.com/spf13/afero"
+	"google.golang.org/api/googleapi"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/mock"
+)
+
+const tokensTestFile = "photos/IMG_0001.jpg"
+
+func TestTokenReusingUploads_UploadFileToAlbum(t *testing.T) {
+	testCases := []struct {
+		name          string
+		createErr     error
+		tokenLifetime time.Duration
+		wantUploads   int
+	}{
+		{"Should reuse token after transient failure", &googleapi.Error{Code: http.StatusInternalServerError}, 0, 1},
+		{"Should reuse token after throttled request", &googleapi.Error{Code: http.StatusTooManyRequests}, 0, 1},
+		{"Should upload again after rejected token", &googleapi.Error{Code: http.StatusBadRequest, Message: "Invalid upload token"}, 0, 2},
+		{"Should upload again after token has expired", &googleapi.Error{Code: http.StatusInternalServerError}, time.Nanosecond, 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			setAppFS(t, afero.NewMemMapFs())
+			if err := afero.WriteFile(appFS, tokensTestFile, []byte("photo"), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			var tokens []string
+			uploader := &mock.MediaUploader{
+				UploadFileFn: func(ctx context.Context, filePath string) (string, error) {
+					tokens = append(tokens, fmt.Sprintf("token-%d", len(tokens)+1))
+					return tokens[len(tokens)-1], nil
+				},
+			}
+			// the media item is created on the second attempt.
+			var created []string
+			mediaItems := &mock.MediaItemsCreator{
+				CreateToAlbumFn: func(ctx context.Context, albumId string, mediaItem media_items.SimpleMediaItem) (media_items.MediaItem, error) {
+					created = append(created, mediaItem.UploadToken)
+					if len(created) == 1 {
+						return media_items.MediaItem{}, tc.createErr
+					}
+					return media_items.MediaItem{ID: "media-1"}, nil
+				},
+			}
+			u := NewTokenReusingUploads(uploader, mediaItems)
+			u.TokenLifetime = tc.tokenLifetime
+
+			if _, err := u.UploadFileToAlbum(context.Background(), "album-1", tokensTestFile); err == nil {
+				t.Fatalf("error was expected at this point")
+			}
+			got, err := u.UploadFileToAlbum(context.Background(), "album-1", tokensTestFile)
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+
+			if got.ID != "media-1" {
+				t.Errorf("want: %s, got: %s", "media-1", got.ID)
+			}
+			if len(tokens) != tc.wantUploads {
+				t.Errorf("want: %d uploads, got: %d", tc.wantUploads, len(tokens))
+			}
+			if want := tokens[len(tokens)-1]; created[1] != want {
+				t.Errorf("want: %s, got: %s", want, created[1])
+			}
+		})
+	}
+}
+
+func TestTokenReusingUploads_UploadFileToAlbumChangedFile(t *testing.T) {
+	setAppFS(t, afero.NewMemMapFs())
+	if err := afero.WriteFile(appFS, tokensTestFile, []byte("photo"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	uploads := 0
+	uploader := &mock.MediaUploader{
+		UploadFileFn: func(ctx context.Context, filePath string) (string, error) {
+			uploads++
+			return fmt.Sprintf("token-%d", uploads), nil
+		},
+	}
+	mediaItems := &mock.MediaItemsCreator{
+		CreateToAlbumFn: func(ctx context.Context, albumId string, mediaItem media_items.SimpleMediaItem) (media_items.MediaItem, error) {
+			if mediaItem.UploadToken == "token-1" {
+				return media_items.MediaItem{}, &googleapi.Error{Code: http.StatusServiceUnavailable}
+			}
+			return media_items.MediaItem{ID: "media-1"}, nil
+		},
+	}
+	u := NewTokenReusingUploads(uploader, mediaItems)
+
+	if _, err := u.UploadFileToAlbum(context.Background(), "album-1", tokensTestFile); err == nil {
+		t.Fatalf("error was expected at this point")
+	}
+	// the uploaded bytes are not the ones of the file anymore.
+	if err := afero.WriteFile(appFS, tokensTestFile, []byte("edited photo"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := u.UploadFileToAlbum(context.Background(), "album-1", tokensTestFile); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	if uploads != 2 {
+		t.Errorf("want: %d uploads, got: %d", 2, uploads)
+	}
+}
+
+func TestTokenReusingUploads_UploadFileToAlbumWithDescription(t *testing.T) {
+	testCases := []struct {
+		name        string
+		description string
+		want        string
+	}{
+		{"Should create media item with description", "At the beach", "At the beach"},
+		{"Should create media item without empty description", "", "none"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			setAppFS(t, afero.NewMemMapFs())
+			if err := afero.WriteFile(appFS, tokensTestFile, []byte("photo"), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			uploader := &mock.MediaUploader{
+				UploadFileFn: func(ctx context.Context, filePath string) (string, error) {
+					return "token-1", nil
+				},
+			}
+			got := ""
+			mediaItems := &mock.MediaItemsCreator{
+				CreateToAlbumFn: func(ctx context.Context, albumId string, mediaItem media_items.SimpleMediaItem) (media_items.MediaItem, error) {
+					got = "none"
+					return media_items.MediaItem{ID: "media-1"}, nil
+				},
+				CreateToAlbumWithDescriptionFn: func(ctx context.Context, albumId string, mediaItem media_items.SimpleMediaItem, description string) (media_items.MediaItem, error) {
+					got = description
+					return media_items.MediaItem{ID: "media-1"}, nil
+				},
+			}
+			u := NewTokenReusingUploads(uploader, mediaItems)
+
+			if _, err := u.UploadFileToAlbumWithDescription(context.Background(), "album-1", tokensTestFile, tc.description); err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			if tc.want != got {
+				t.Errorf("want: %s, got: %s", tc.want, got)
+			}
+		})
+	}
+}
+
+// library mocks the media items found by Recheck, by path.
+type library map[string]string
+
+func (l library) Recheck(ctx context.Context, path string) (string, bool, error) {
+	id, ok := l[path]
+	return id, ok, nil
+}
+
+func TestTokenReusingUploads_UploadFileToAlbumLostResponse(t *testing.T) {
+	testCases := []struct {
+		name        string
+		createErr   error
+		created     bool
+		finder      bool
+		wantCreates int
+	}{
+		{"Should not create again media item created by the failed attempt", &googleapi.Error{Code: http.StatusServiceUnavailable}, true, true, 1},
+		{"Should create again media item not created by the failed attempt", &googleapi.Error{Code: http.StatusServiceUnavailable}, false, true, 2},
+		{"Should not search the library after throttled request", &googleapi.Error{Code: http.StatusTooManyRequests}, false, true, 2},
+		{"Should create again without finder", &googleapi.Error{Code: http.StatusServiceUnavailable}, true, false, 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			setAppFS(t, afero.NewMemMapFs())
+			if err := afero.WriteFile(appFS, tokensTestFile, []byte("photo"), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			uploader := &mock.MediaUploader{
+				UploadFileFn: func(ctx context.Context, filePath string) (string, error) {
+					return "token-1", nil
+				},
+			}
+			// the first attempt fails, but the media item could have been created anyway.
+			lib := library{}
+			creates := 0
+			mediaItems := &mock.MediaItemsCreator{
+				CreateToAlbumFn: func(ctx context.Context, albumId string, mediaItem media_items.SimpleMediaItem) (media_items.MediaItem, error) {
+					creates++
+					if creates == 1 {
+						if tc.created {
+							lib[tokensTestFile] = "media-1"
+						}
+						return media_items.MediaItem{}, tc.createErr
+					}
+					return media_items.MediaItem{ID: fmt.Sprintf("media-%d", creates)}, nil
+				},
+			}
+			u := NewTokenReusingUploads(uploader, mediaItems)
+			if tc.finder {
+				u.Finder = lib
+			}
+
+			if _, err := u.UploadFileToAlbum(context.Background(), "album-1", tokensTestFile); err == nil {
+				t.Fatalf("error was expected at this point")
+			}
+			got, err := u.UploadFileToAlbum(context.Background(), "album-1", tokensTestFile)
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+
+			if tc.wantCreates != creates {
+				t.Errorf("want: %d creates, got: %d", tc.wantCreates, creates)
+			}
+			if want := fmt.Sprintf("media-%d", tc.wantCreates); got.ID != want {
+				t.Errorf("want: %s, got: %s", want, got.ID)
+			}
+		})
+	}
+}
+
+func TestTokenReusingUploads_UploadFileToAlbumRetries(t *testing.T) {
+	testCases := []struct {
+		name          string
+		createErr     error
+		created       bool
+		finder        bool
+		wantCreates   int
+		isErrExpected bool
+	}{
+		{"Should find media item created by the failed attempt", &googleapi.Error{Code: http.StatusServiceUnavailable}, true, true, 1, false},
+		{"Should create again media item not created by the failed attempt", &googleapi.Error{Code: http.StatusServiceUnavailable}, false, true, 2, false},
+		{"Should not retry throttled request", &googleapi.Error{Code: http.StatusTooManyRequests}, false, true, 1, true},
+		{"Should not retry without finder", &googleapi.Error{Code: http.StatusServiceUnavailable}, false, false, 1, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			setAppFS(t, afero.NewMemMapFs())
+			if err := afero.WriteFile(appFS, tokensTestFile, []byte("photo"), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			uploads := 0
+			uploader := &mock.MediaUploader{
+				UploadFileFn: func(ctx context.Context, filePath string) (string, error) {
+					uploads++
+					return "token-1", nil
+				},
+			}
+			lib := library{}
+			creates := 0
+			mediaItems := &mock.MediaItemsCreator{
+				CreateToAlbumFn: func(ctx context.Context, albumId string, mediaItem media_items.SimpleMediaItem) (media_items.MediaItem, error) {
+					creates++
+					if creates == 1 {
+						if tc.created {
+							lib[tokensTestFile] = "media-1"
+						}
+						return media_items.MediaItem{}, tc.createErr
+					}
+					return media_items.MediaItem{ID: fmt.Sprintf("media-%d", creates)}, nil
+				},
+			}
+			u := NewTokenReusingUploads(uploader, mediaItems)
+			u.Retries = 2
+			u.RetryDelay = time.Millisecond
+			if tc.finder {
+				u.Finder = lib
+			}
+
+			got, err := u.UploadFileToAlbum(context.Background(), "album-1", tokensTestFile)
+			switch {
+			case tc.isErrExpected && err == nil:
+				t.Fatalf("error was expected, but not produced")
+			case !tc.isErrExpected && err != nil:
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+
+			if tc.wantCreates != creates {
+				t.Errorf("want: %d creates, got: %d", tc.wantCreates, creates)
+			}
+			if uploads != 1 {
+				t.Errorf("want: %d uploads, got: %d", 1, uploads)
+			}
+			if want := fmt.Sprintf("media-%d", tc.wantCreates); !tc.isErrExpected && got.ID != want {
+				t.Errorf("want: %s, got: %s", want, got.ID)
+			}
+		})
+	}
+}
@@ -1,5 +1,12 @@
 package upload
 
+import (
+	"io/fs"
+	"time"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/exif"
+)
+
 // UploadFolderJob represents a job to upload all photos from the specified folder
 type UploadFolderJob struct {
 	FileTracker FileTracker
@@ -7,17 +14,187 @@ type UploadFolderJob struct {
 	SourceFolder string
 	CreateAlbums string
 	Filter       FileFilterer
+
+	// FS, if it's set, is the file system scanned instead of the local SourceFolder, e.g. an in-memory one.
+	// Paths of the files found are still joined to SourceFolder. It's os.DirFS(SourceFolder) by default.
+	// Files could only be deleted or moved once they are uploaded if it's a WritableFS.
+	FS fs.FS
+
+	// AlbumPathSeparator joins folder names when CreateAlbums is folderPath. Uses DefaultAlbumPathSeparator by default.
+	AlbumPathSeparator string
+
+	// AlbumHierarchySeparator, if it's set, joins the names of all the folders in the path of the files, instead
+	// of using the name of their folder, when CreateAlbums is folderName.
+	AlbumHierarchySeparator string
+	// AlbumHierarchyDepth, if it's greater than zero, is the maximum number of folders joined by AlbumHierarchySeparator.
+	AlbumHierarchyDepth int
+
+	// AlbumDateFormat is the layout of album names when CreateAlbums is exifDate. Uses DefaultAlbumDateFormat by default.
+	AlbumDateFormat string
+
+	// AlbumNameTemplate, if it's set, computes the album names of the files, instead of CreateAlbums.
+	AlbumNameTemplate *AlbumNameTemplate
+
+	// PhotoAlbum and VideoAlbum are the albums of photos and videos when CreateAlbums is mediaType. Use DefaultPhotoAlbum
+	// and DefaultVideoAlbum by default. OtherAlbum is the album of the other files, they are not added to any album if it's not set.
+	PhotoAlbum string
+	VideoAlbum string
+	OtherAlbum string
+
+	// Albums, if it's set, are the albums where files are added by their own filters. A file is added to the
+	// first album whose filter allows it, and files not allowed by any of them are skipped.
+	Albums []AlbumFilter
+
+	// Routes, if it's set, uploads files to other accounts by their own filters. A file is uploaded to the account
+	// of the first route matching it, and to the one of the job if none of them matches it.
+	Routes []Route
+
+	// DefaultAlbum, if it's set, is the album of the files not added to any album by CreateAlbums,
+	// AlbumNameTemplate, Albums or Routes.
+	DefaultAlbum string
+
+	// SharedAlbum, if it's set, is the title of the shared album where all the files are added, instead of the
+	// albums given by CreateAlbums, AlbumNameTemplate, Albums or Routes.
+	SharedAlbum string
+
+	// OnlyAlbums, if it's set, are the only albums whose files are uploaded. Files of other albums, or not added
+	// to any album, are skipped.
+	OnlyAlbums []string
+
+	// DetectLivePhotos, if it's true, adds the video of every Live Photo to the album of its photo, so both parts are
+	// kept together. The Google Photos API doesn't allow to upload them as a Live Photo.
+	DetectLivePhotos bool
+
+	// OnSkipped, if it's set, is called for every file skipped by the scan, with the reason why it's skipped,
+	// one of the log.Reason* codes. It could be called concurrently if ScanWorkers is greater than 1.
+	OnSkipped func(path string, reason string)
+
+	// FavoritesFolder, if it's set, is the name of the folders whose files, at any depth, should be marked as favorites.
+	FavoritesFolder string
+
+	// Limits, if it's set, skips the files that Google Photos would reject, by their content type or size.
+	Limits Limits
+
+	// OnUnsupported is what is done with the files rejected by Limits because their content type is not
+	// supported: they are omitted, logging a warning by default, or the walk is aborted, returning an error
+	// wrapping ErrUnsupportedFile.
+	OnUnsupported UnsupportedPolicy
+
+	// MIMEDetection is the way the content type of the files is detected for Limits, and when CreateAlbums is
+	// mediaType. Uses MIMEDetectionSniff by default.
+	MIMEDetection MIMEDetection
+
+	// FollowSymlinks walks into the symbolic links to directories, and uploads the files symbolic links point to.
+	// Links to the directories they are in are skipped, so loops are not scanned forever. Symbolic links are skipped if it's not set.
+	FollowSymlinks bool
+
+	// IncludeHidden uploads the hidden files, and scans the hidden directories. Hidden files are the ones whose name
+	// starts with a dot, and on Windows the ones with the hidden attribute too. They are skipped if it's not set.
+	IncludeHidden bool
+
+	// FailOnError stops the walk, returning the error, if a file or directory could not be read. They are
+	// skipped, and counted as SkippedUnreadable, otherwise.
+	FailOnError bool
+
+	// ScanWorkers is the number of directories scanned concurrently. The folder is scanned serially if it's not greater than 1.
+	ScanWorkers int
+
+	// MinFileAge, if it's set, skips the files modified more recently, since they could still be being written.
+	MinFileAge time.Duration
+
+	// ChangedSince, if it's set, skips the files neither modified nor moved since then, without checking the
+	// FileTracker. It's used to scan only the files changed since the last successful run.
+	ChangedSince time.Time
+
+	// ChangedFiles, if it's not nil, are the only files uploaded, by their path relative to SourceFolder, e.g.
+	// the ones changed in a git repository since a commit. Other files are skipped as unchanged.
+	ChangedFiles map[string]bool
+
+	// ExifFilter, if it's set, skips the files whose EXIF metadata is not allowed by it. Files must be allowed by Filter too.
+	ExifFilter ExifFilterer
+
+	// MIMEFilter, if it's set, skips the files whose content type is not allowed by it. The content type is detected
+	// from the content of the files, whatever MIMEDetection is, so files with a misleading extension are caught.
+	// Files must be allowed by Filter too.
+	MIMEFilter MIMEFilterer
+
+	// MinWidth and MinHeight, if they are set, skip the images smaller than them, e.g. thumbnails with the same
+	// extension as the photos. Their dimensions are read from their header, as they are displayed given their EXIF
+	// orientation. Files that are not JPEG, PNG or GIF images are not skipped.
+	MinWidth  int
+	MinHeight int
+
+	// DateRange, if it's set, skips the files whose capture date is out of it. The capture date is read from the
+	// EXIF metadata, or the modification time is used if it's missing.
+	DateRange DateRange
+
+	// MetadataReader reads the EXIF metadata of the files, used by ExifFilter, DateRange and when CreateAlbums is exifDate.
+	// It's read once per file. Uses exif.Reader{} by default.
+	MetadataReader MetadataReader
+
+	// CaptureTimeReader, if it's set, gets the date of the photos for DateRange and when CreateAlbums is exifDate,
+	// instead of reading it from the metadata.
+	CaptureTimeReader CaptureTimeReader
+
+	// FilenameDate, if it's set, derives the date of the photos for DateRange and when CreateAlbums is exifDate
+	// from their name, without reading their metadata. Files whose name doesn't match use the metadata instead.
+	FilenameDate *FilenameDate
 }
 
 // FileTracker represents a service to track already uploaded files.
 type FileTracker interface {
-	Put(file string) error
+	Put(file string, mediaItemID string) error
 	Exist(file string) bool
 	Delete(file string) error
 }
 
+// AlbumTracker is a FileTracker keeping the albums where the uploaded files have been added, so an
+// uploaded file assigned to another album is only added to it, instead of being uploaded again.
+type AlbumTracker interface {
+	// TrackedAlbums returns the media item of the file, and the names of the albums where it has been added.
+	// It returns false if they are unknown.
+	TrackedAlbums(file string) (mediaItemID string, albums []string, ok bool)
+	// AddAlbum records that the media item of the file has been added to the album.
+	AddAlbum(file string, album string) error
+}
+
+// NearDuplicateTracker is a FileTracker keeping the perceptual hashes of the uploaded images, so an image visually
+// similar to an uploaded one, e.g. a recompressed copy, is not uploaded.
+type NearDuplicateTracker interface {
+	// NearDuplicate returns the uploaded image the file is visually similar to, and true if there's one.
+	NearDuplicate(file string) (original string, ok bool)
+}
+
+// FavoriteTracker is a FileTracker recording the files that should be marked as favorites, since the Google
+// Photos API doesn't allow to mark media items as favorites.
+type FavoriteTracker interface {
+	// MarkFavorite records that the media item of the tracked file should be marked as favorite.
+	MarkFavorite(file string) error
+}
+
+// CaptureTimeReader represents a way to get the date when a photo was taken.
+type CaptureTimeReader interface {
+	CaptureTime(path string) (time.Time, error)
+}
+
+// MetadataReader represents a way to get the EXIF metadata of a photo.
+type MetadataReader interface {
+	Metadata(path string) (exif.Metadata, error)
+}
+
+// ExifFilterer represents a way to filter files by their EXIF metadata.
+type ExifFilterer interface {
+	IsAllowed(md exif.Metadata) bool
+}
+
+// MIMEFilterer represents a way to filter files by their content type.
+type MIMEFilterer interface {
+	IsAllowed(mimeType string) bool
+}
+
 // FileFilterer represents a way to implement include/exclude files filtering.
 type FileFilterer interface {
 	IsAllowed(path string) bool
+	IsAllowedDir(path string) bool
 	IsExcluded(path string) bool
 }
@@ -0,0 +1,39 @@
+package upload
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+)
+
+// UnsupportedPolicy is what is done with the files whose content type is not supported by Google Photos, as
+// found by Limits before uploading them.
+type UnsupportedPolicy string
+
+const (
+	// UnsupportedSkip omits the files, only logging them at debug level.
+	UnsupportedSkip UnsupportedPolicy = "skip"
+	// UnsupportedWarn omits the files, logging a warning for every one of them (default).
+	UnsupportedWarn UnsupportedPolicy = "warn"
+	// UnsupportedFail aborts the run at the first one.
+	UnsupportedFail UnsupportedPolicy = "fail"
+)
+
+// ErrUnsupportedFile is returned when a file is not supported by Google Photos and the policy is UnsupportedFail.
+var ErrUnsupportedFile = errors.New("file not supported by Google Photos")
+
+// Enforce applies the policy to the file, rejected by Limits because its content type is not supported. It
+// returns an error wrapping ErrUnsupportedFile if the run should be aborted, or nil if the file is omitted.
+func (p UnsupportedPolicy) Enforce(logger log.Logger, path string, rejected *RejectedError) error {
+	entry := logger.WithFields(log.Fields{"event": log.EventFileSkipped, "path": path, "reason": rejected.Reason})
+	switch p {
+	case UnsupportedSkip:
+		entry.Debugf("Skipping file '%s', it would be rejected by Google Photos: %s", path, rejected)
+	case UnsupportedFail:
+		return fmt.Errorf("%w: '%s', %s", ErrUnsupportedFile, path, rejected)
+	default:
+		entry.Warnf("Skipping file '%s', it would be rejected by Google Photos: %s", path, rejected)
+	}
+	return nil
+}
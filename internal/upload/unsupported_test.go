@@ -0,0 +1,70 @@
+package upload_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/filter"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/mock"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/upload"
+)
+
+func TestUploadFolderJob_WalkFolderOnUnsupported(t *testing.T) {
+	dir, err := ioutil.TempDir("", "unsupported")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	copyFile(t, "testdata/SampleJPGImage.jpg", filepath.Join(dir, "IMG_0001.jpg"))
+	copyFile(t, "testdata/SampleText.txt", filepath.Join(dir, "notes.txt"))
+
+	var testCases = []struct {
+		policy      upload.UnsupportedPolicy
+		wantStats   upload.WalkStats
+		wantWarning bool
+		wantErr     bool
+	}{
+		{upload.UnsupportedSkip, upload.WalkStats{Found: 1, SkippedRejected: 1}, false, false},
+		{upload.UnsupportedWarn, upload.WalkStats{Found: 1, SkippedRejected: 1}, true, false},
+		{"", upload.WalkStats{Found: 1, SkippedRejected: 1}, true, false},
+		{upload.UnsupportedFail, upload.WalkStats{Found: 1}, false, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.policy), func(t *testing.T) {
+			u := upload.UploadFolderJob{
+				FileTracker:   &mock.FileTracker{ExistFn: func(path string) bool { return false }},
+				SourceFolder:  dir,
+				CreateAlbums:  "Off",
+				Filter:        filter.MustCompile([]string{"_ALL_FILES_"}, nil),
+				Limits:        upload.DefaultLimits(),
+				OnUnsupported: tc.policy,
+			}
+
+			logger := &mock.Logger{}
+			var found []string
+			stats, err := u.WalkFolder(logger, func(item upload.FileItem) {
+				found = append(found, filepath.Base(item.Path))
+			})
+			if tc.wantErr && !errors.Is(err, upload.ErrUnsupportedFile) {
+				t.Fatalf("want: %v, got: %v", upload.ErrUnsupportedFile, err)
+			}
+			if !tc.wantErr && err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			if stats != tc.wantStats {
+				t.Errorf("want: %+v, got: %+v", tc.wantStats, stats)
+			}
+			if len(found) != 1 || found[0] != "IMG_0001.jpg" {
+				t.Errorf("want: [IMG_0001.jpg], got: %v", found)
+			}
+			if logger.WarnfInvoked != tc.wantWarning {
+				t.Errorf("want: warning %v, got: %v", tc.wantWarning, logger.WarnfInvoked)
+			}
+		})
+	}
+}
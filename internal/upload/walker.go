@@ -1,35 +1,216 @@
 package upload
 
 import (
+	"context"
+	"errors"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
-	"github.com/facebookgo/symwalk"
-
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/exif"
 	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/metrics"
 )
 
 // ScanFolder return the list of Items{} to be uploaded. It scans the folder and skip
 // non allowed files (includePatterns & excludePattens).
 func (job *UploadFolderJob) ScanFolder(logger log.Logger) ([]FileItem, error) {
 	var result []FileItem
-	err := symwalk.Walk(job.SourceFolder, job.getItemToUploadFn(&result, logger))
+	_, err := job.WalkFolder(logger, func(item FileItem) {
+		result = append(result, item)
+	})
 	return result, err
 }
 
-func (job *UploadFolderJob) getItemToUploadFn(reqs *[]FileItem, logger log.Logger) filepath.WalkFunc {
+// WalkStats are the number of files found, and skipped, when walking a folder.
+// Files in excluded directories are not visited, so they are not counted.
+type WalkStats struct {
+	Found           int
+	SkippedFiltered int
+	SkippedTracked  int
+	// SkippedRecent are the files modified within MinFileAge, they are not tracked so they are found again later.
+	SkippedRecent int
+	// SkippedRejected are the files that Google Photos would reject, by their content type or size.
+	SkippedRejected int
+	// SkippedUnchanged are the files not changed since ChangedSince, or not in ChangedFiles.
+	SkippedUnchanged int
+	// SkippedUnreadable are the files and directories that could not be read, e.g. because of their permissions.
+	SkippedUnreadable int
+}
+
+// WalkFolder scans the folder and calls fn for every item to be uploaded, as soon as it's found.
+// It skips non allowed files (includePatterns & excludePattens), like ScanFolder does.
+// If ScanWorkers is greater than 1, directories are scanned concurrently, and items are found in no particular order.
+// fn is never called concurrently.
+func (job *UploadFolderJob) WalkFolder(logger log.Logger, fn func(item FileItem)) (WalkStats, error) {
+	return job.WalkFolderContext(context.Background(), logger, fn)
+}
+
+// WalkFolderContext is like WalkFolder, but the scan is aborted, returning the context error,
+// once the context is done.
+func (job *UploadFolderJob) WalkFolderContext(ctx context.Context, logger log.Logger, fn func(item FileItem)) (WalkStats, error) {
+	var stats WalkStats
+	ignores := newIgnoreRules(job.fileSystem())
+	if job.ScanWorkers <= 1 {
+		err := walkFS(job.fileSystem(), job.SourceFolder, job.FollowSymlinks, withContext(ctx, job.getItemToUploadFn(fn, &stats, ignores, logger)))
+		return stats, err
+	}
+
+	// every path is checked concurrently, only the found items and the stats are serialized.
+	var mu sync.Mutex
+	err := parallelWalk(job.fileSystem(), job.SourceFolder, job.ScanWorkers, job.FollowSymlinks, withContext(ctx, func(fp string, fi os.FileInfo, errP error) error {
+		var pathStats WalkStats
+		var items []FileItem
+		err := job.getItemToUploadFn(func(item FileItem) {
+			items = append(items, item)
+		}, &pathStats, ignores, logger)(fp, fi, errP)
+
+		mu.Lock()
+		defer mu.Unlock()
+		stats.add(pathStats)
+		for _, item := range items {
+			fn(item)
+		}
+		return err
+	}))
+	return stats, err
+}
+
+// withContext returns walkFn, that fails with the context error once the context is done.
+func withContext(ctx context.Context, walkFn filepath.WalkFunc) filepath.WalkFunc {
+	return func(fp string, fi os.FileInfo, errP error) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return walkFn(fp, fi, errP)
+	}
+}
+
+func (s *WalkStats) add(other WalkStats) {
+	s.Found += other.Found
+	s.SkippedFiltered += other.SkippedFiltered
+	s.SkippedTracked += other.SkippedTracked
+	s.SkippedRecent += other.SkippedRecent
+	s.SkippedRejected += other.SkippedRejected
+	s.SkippedUnchanged += other.SkippedUnchanged
+	s.SkippedUnreadable += other.SkippedUnreadable
+}
+
+// VisitFile calls fn if the file, in the folder, should be uploaded. It applies the same
+// checks than WalkFolder, so it's useful to process files found by other means, e.g. a watcher.
+func (job *UploadFolderJob) VisitFile(logger log.Logger, path string, fn func(item FileItem)) (WalkStats, error) {
+	logger = log.WithModule(logger, log.ModuleScan)
+	var stats WalkStats
+	name, err := fsName(job.SourceFolder, path)
+	if err != nil {
+		return stats, err
+	}
+	fi, err := fs.Stat(job.fileSystem(), name)
+	if err != nil {
+		return stats, err
+	}
+	if fi.IsDir() {
+		return stats, nil
+	}
+	// symbolic links are skipped, like WalkFolder does, unless they are followed.
+	if !job.FollowSymlinks && job.isSymlink(name) {
+		logger.WithFields(log.Fields{"event": log.EventFileSkipped, "path": path, "reason": log.ReasonSymlink}).Infof("Skipping symbolic link '%s'.", path)
+		stats.SkippedFiltered++
+		job.skipped(path, log.ReasonSymlink)
+		return stats, nil
+	}
+	// files in directories excluded by the ignore files are skipped, like WalkFolder doesn't scan them.
+	ignores := newIgnoreRules(job.fileSystem())
+	ignored, err := ignores.isParentIgnored(name)
+	if err != nil {
+		return stats, err
+	}
+	if ignored {
+		logger.WithFields(log.Fields{"event": log.EventFileSkipped, "path": path, "reason": log.ReasonExcluded}).Debugf("Skipping file '%s', its directory is ignored.", path)
+		stats.SkippedFiltered++
+		job.skipped(path, log.ReasonExcluded)
+		return stats, nil
+	}
+	// files in hidden directories are skipped, like WalkFolder doesn't scan them.
+	if job.IsHidden(path) {
+		logger.WithFields(log.Fields{"event": log.EventFileSkipped, "path": path, "reason": log.ReasonHidden}).Debugf("Skipping hidden file '%s'.", path)
+		stats.SkippedFiltered++
+		job.skipped(path, log.ReasonHidden)
+		return stats, nil
+	}
+	err = job.getItemToUploadFn(fn, &stats, ignores, logger)(path, fi, nil)
+	return stats, err
+}
+
+func (job *UploadFolderJob) getItemToUploadFn(fn func(item FileItem), stats *WalkStats, ignores *ignoreRules, logger log.Logger) filepath.WalkFunc {
+	logger = log.WithModule(logger, log.ModuleScan)
 	return func(fp string, fi os.FileInfo, errP error) error {
+		// followed symbolic links could lead to the directories they are in, that would be scanned forever.
+		if errors.Is(errP, errSymlinkLoop) {
+			logger.WithFields(log.Fields{"event": log.EventFileSkipped, "path": fp, "reason": log.ReasonSymlinkLoop}).Warnf("Skipping directory '%s', a symbolic link loops back to it.", fp)
+			return filepath.SkipDir
+		}
+		// files and directories that could not be read, e.g. directories without read permission, are
+		// skipped, so the rest of the folder is uploaded.
+		if errP != nil {
+			return job.skipUnreadable(fp, errP, stats, logger)
+		}
 		if fi == nil {
 			return nil
 		}
 
 		relativePath := RelativePath(job.SourceFolder, fp)
 
+		// items excluded by the ignore files of their parent directories are skipped, like excluded ones.
+		if name, err := fsName(job.SourceFolder, fp); err == nil && name != "." {
+			if !fi.IsDir() && path.Base(name) == IgnoreFileName {
+				return nil
+			}
+			ignored, err := ignores.isIgnored(name)
+			if err != nil {
+				return err
+			}
+			if ignored && fi.IsDir() {
+				logger.WithFields(log.Fields{"event": log.EventFileSkipped, "path": fp, "reason": log.ReasonExcluded}).Debugf("Skipping ignored directory '%s'.", fp)
+				return filepath.SkipDir
+			}
+			if ignored {
+				logger.WithFields(log.Fields{"event": log.EventFileSkipped, "path": fp, "reason": log.ReasonExcluded}).Debugf("Skipping ignored file '%s'.", fp)
+				stats.SkippedFiltered++
+				job.skipped(fp, log.ReasonExcluded)
+				return nil
+			}
+
+			// hidden directories are not scanned, unless IncludeHidden is set.
+			if !job.IncludeHidden && isHidden(path.Base(name), fi) {
+				if fi.IsDir() {
+					logger.WithFields(log.Fields{"event": log.EventFileSkipped, "path": fp, "reason": log.ReasonHidden}).Debugf("Skipping hidden directory '%s'.", fp)
+					return filepath.SkipDir
+				}
+				logger.WithFields(log.Fields{"event": log.EventFileSkipped, "path": fp, "reason": log.ReasonHidden}).Debugf("Skipping hidden file '%s'.", fp)
+				stats.SkippedFiltered++
+				job.skipped(fp, log.ReasonHidden)
+				return nil
+			}
+		}
+
+		// symbolic links not followed, or broken, are neither uploaded nor walked into.
+		if fi.Mode()&fs.ModeSymlink != 0 {
+			logger.WithFields(log.Fields{"event": log.EventFileSkipped, "path": fp, "reason": log.ReasonSymlink}).Infof("Skipping symbolic link '%s'.", fp)
+			stats.SkippedFiltered++
+			job.skipped(fp, log.ReasonSymlink)
+			return nil
+		}
+
 		// If a directory is excluded, skip it!
 		if fi.IsDir() {
-			if job.Filter.IsExcluded(relativePath) {
-				logger.Debugf("Skipping excluded directory '%s'.", fp)
+			if !job.Filter.IsAllowedDir(relativePath) {
+				logger.WithFields(log.Fields{"event": log.EventFileSkipped, "path": fp, "reason": log.ReasonExcluded}).Debugf("Skipping excluded directory '%s'.", fp)
 				return filepath.SkipDir
 			}
 			return nil
@@ -40,27 +221,251 @@ func (job *UploadFolderJob) getItemToUploadFn(reqs *[]FileItem, logger log.Logge
 		// then set up of includePatterns and excludePatterns.
 
 		if !job.Filter.IsAllowed(relativePath) {
-			logger.Debugf("Skipping excluded file '%s'.", fp)
+			logger.WithFields(log.Fields{"event": log.EventFileSkipped, "path": fp, "reason": log.ReasonExcluded}).Debugf("Skipping excluded file '%s'.", fp)
+			stats.SkippedFiltered++
+			job.skipped(fp, log.ReasonExcluded)
+			return nil
+		}
+
+		// files are routed to albums by their own filters, if they are set. The video of a Live Photo is routed
+		// like its photo.
+		livePhoto, isLivePhoto := job.livePhotoOf(fp)
+		albumName, routed := job.routedAlbumName(relativePath)
+		if isLivePhoto {
+			if name, ok := job.routedAlbumName(RelativePath(job.SourceFolder, livePhoto)); ok {
+				albumName, routed = name, true
+			}
+		}
+		if !routed {
+			logger.WithFields(log.Fields{"event": log.EventFileSkipped, "path": fp, "reason": log.ReasonNoAlbum}).Debugf("Skipping file '%s', not allowed by any album.", fp)
+			stats.SkippedFiltered++
+			job.skipped(fp, log.ReasonNoAlbum)
 			return nil
 		}
 
-		// check completed uploads db for previous uploads
-		if job.FileTracker.Exist(fp) {
-			logger.Debugf("Skipping already uploaded file '%s'.", fp)
+		// files not changed since the last successful run have been checked already.
+		if !job.ChangedSince.IsZero() && changeTime(fi).Before(job.ChangedSince) {
+			logger.WithFields(log.Fields{"event": log.EventFileSkipped, "path": fp, "reason": log.ReasonUnchanged}).Debugf("Skipping file '%s', not changed since the last run.", fp)
+			stats.SkippedUnchanged++
+			job.skipped(fp, log.ReasonUnchanged)
 			return nil
 		}
 
-		logger.Debugf("Upload file '%s' to album '%s'.", fp, job.albumName(relativePath))
+		if job.ChangedFiles != nil && !job.ChangedFiles[relativePath] {
+			logger.WithFields(log.Fields{"event": log.EventFileSkipped, "path": fp, "reason": log.ReasonUnchanged}).Debugf("Skipping file '%s', not in the changed files.", fp)
+			stats.SkippedUnchanged++
+			job.skipped(fp, log.ReasonUnchanged)
+			return nil
+		}
+
+		// the album is resolved once, tracked files are added to it if they are not in it yet.
+		md := job.newFileMetadata(fp)
+		albumName, route := job.fileAlbum(fp, relativePath, albumName, livePhoto, fi.ModTime(), md)
+
+		// check completed uploads db for previous uploads, they are only added to the albums they are not in yet.
+		var mediaItemID string
+		tracked := job.FileTracker.Exist(fp)
+		if tracked {
+			mediaItemID = job.mediaItemToAttach(fp, albumName)
+		}
+		if tracked && mediaItemID == "" {
+			logger.WithFields(log.Fields{"event": log.EventFileSkipped, "path": fp, "reason": log.ReasonAlreadyUploaded}).Debugf("Skipping already uploaded file '%s'.", fp)
+			stats.SkippedTracked++
+			job.skipped(fp, log.ReasonAlreadyUploaded)
+			return nil
+		}
+
+		// files modified too recently could still be being written.
+		if job.MinFileAge > 0 && time.Since(fi.ModTime()) < job.MinFileAge {
+			logger.WithFields(log.Fields{"event": log.EventFileSkipped, "path": fp, "reason": log.ReasonTooRecent}).Debugf("Skipping recently modified file '%s', it will be uploaded later.", fp)
+			stats.SkippedRecent++
+			job.skipped(fp, log.ReasonTooRecent)
+			return nil
+		}
+
+		// files are filtered by their EXIF metadata too, missing or corrupt metadata is empty.
+		if job.ExifFilter != nil {
+			m, err := md.get()
+			if err != nil && !errors.Is(err, exif.ErrNotFound) {
+				logger.Debugf("EXIF metadata of file '%s' could not be read: %s", fp, err)
+			}
+			if !job.ExifFilter.IsAllowed(m) {
+				logger.WithFields(log.Fields{"event": log.EventFileSkipped, "path": fp, "reason": log.ReasonExifMismatch}).Debugf("Skipping file '%s', its EXIF metadata is not allowed.", fp)
+				stats.SkippedFiltered++
+				job.skipped(fp, log.ReasonExifMismatch)
+				return nil
+			}
+		}
+
+		// files are filtered by their capture date too, the modification time is used if it's missing.
+		if !job.DateRange.IsZero() && !job.DateRange.Contains(job.fileDate(fp, fi.ModTime(), md)) {
+			logger.WithFields(log.Fields{"event": log.EventFileSkipped, "path": fp, "reason": log.ReasonOutOfDateRange}).Debugf("Skipping file '%s', its capture date is out of the date range.", fp)
+			stats.SkippedFiltered++
+			job.skipped(fp, log.ReasonOutOfDateRange)
+			return nil
+		}
+
+		// files that could not be read would fail to be uploaded.
+		if err := job.checkReadable(fp); err != nil {
+			return job.skipUnreadable(fp, err, stats, logger)
+		}
+
+		// files are filtered by their content type too, as read from their content.
+		if job.MIMEFilter != nil {
+			mimeType, err := DetectContentType(fp)
+			if err != nil {
+				return job.skipUnreadable(fp, err, stats, logger)
+			}
+			if !job.MIMEFilter.IsAllowed(mimeType) {
+				logger.WithFields(log.Fields{"event": log.EventFileSkipped, "path": fp, "reason": log.ReasonMIMEType}).Debugf("Skipping file '%s', its content type '%s' is not allowed.", fp, mimeType)
+				stats.SkippedFiltered++
+				job.skipped(fp, log.ReasonMIMEType)
+				return nil
+			}
+		}
+
+		// small images, like thumbnails, are skipped by their dimensions, read from their header only.
+		if job.MinWidth > 0 || job.MinHeight > 0 {
+			small, err := job.tooSmall(fp, md)
+			if err != nil {
+				return job.skipUnreadable(fp, err, stats, logger)
+			}
+			if small {
+				logger.WithFields(log.Fields{"event": log.EventFileSkipped, "path": fp, "reason": log.ReasonTooSmall}).Debugf("Skipping file '%s', it's smaller than %dx%d.", fp, job.MinWidth, job.MinHeight)
+				stats.SkippedFiltered++
+				job.skipped(fp, log.ReasonTooSmall)
+				return nil
+			}
+		}
+
+		// files rejected by Google Photos are not uploaded, saving time and quota.
+		if job.Limits != nil {
+			mimeType, err := job.MIMEDetection.ContentType(fp)
+			if err == nil {
+				err = job.Limits.CheckContentType(mimeType, fi.Size())
+			}
+			var rejected *RejectedError
+			if errors.As(err, &rejected) {
+				// unsupported files are omitted, or abort the walk, as set by OnUnsupported.
+				if rejected.Reason == log.ReasonUnsupportedType {
+					if err := job.OnUnsupported.Enforce(logger, fp, rejected); err != nil {
+						return err
+					}
+				} else {
+					logger.WithFields(log.Fields{"event": log.EventFileSkipped, "path": fp, "reason": rejected.Reason}).Warnf("Skipping file '%s', it would be rejected by Google Photos: %s", fp, rejected)
+				}
+				stats.SkippedRejected++
+				job.skipped(fp, rejected.Reason)
+				return nil
+			}
+			if err != nil {
+				// the upload reports the error if the file could not be read.
+				logger.Debugf("Content type of file '%s' could not be detected: %s", fp, err)
+			}
+		}
+
+		// images visually similar to an uploaded one are copies of it, e.g. recompressed, only checked once the
+		// cheaper filters have passed, since the image is decoded.
+		if tracker, ok := job.FileTracker.(NearDuplicateTracker); ok && mediaItemID == "" {
+			if original, found := tracker.NearDuplicate(fp); found {
+				logger.WithFields(log.Fields{"event": log.EventFileSkipped, "path": fp, "reason": log.ReasonNearDuplicate, "duplicate_of": original}).Infof("Skipping file '%s', it's visually similar to the uploaded '%s'.", fp, original)
+				stats.SkippedTracked++
+				job.skipped(fp, log.ReasonNearDuplicate)
+				return nil
+			}
+		}
+
+		if isLivePhoto {
+			logger.Infof("Live Photo detected: '%s' is the video of '%s', adding it to the same album.", fp, livePhoto)
+		}
+		if len(job.OnlyAlbums) > 0 && !slices.Contains(job.OnlyAlbums, albumName) {
+			logger.WithFields(log.Fields{"event": log.EventFileSkipped, "path": fp, "reason": log.ReasonOtherAlbum}).Debugf("Skipping file '%s', its album '%s' is not selected.", fp, albumName)
+			stats.SkippedFiltered++
+			job.skipped(fp, log.ReasonOtherAlbum)
+			return nil
+		}
+		if mediaItemID != "" {
+			logger.Debugf("Add already uploaded file '%s' to album '%s'.", fp, albumName)
+		} else {
+			logger.Debugf("Upload file '%s' to album '%s'.", fp, albumName)
+		}
 
 		// set file upload Options depending on folder upload Options
-		*reqs = append(*reqs, FileItem{
-			Path:      fp,
-			AlbumName: job.albumName(relativePath),
+		stats.Found++
+		fn(FileItem{
+			Path:        fp,
+			AlbumName:   albumName,
+			ModTime:     fi.ModTime(),
+			MediaItemID: mediaItemID,
+			Account:     route.Account,
+			LivePhotoOf: livePhoto,
+			Favorite:    job.isFavorite(relativePath),
 		})
 		return nil
 	}
 }
 
+// fileAlbum returns the album of the file and the route it matches, if any. The album of the route takes
+// precedence over the one of the job, DefaultAlbum is used if there is none, and SharedAlbum over all of them.
+// routedAlbum is the album of the file if it's routed by Albums, and livePhoto the photo of the file if it's the
+// video of a Live Photo.
+func (job *UploadFolderJob) fileAlbum(fp string, path string, routedAlbum string, livePhoto string, modTime time.Time, md *fileMetadata) (string, Route) {
+	albumName := routedAlbum
+	if len(job.Albums) == 0 && livePhoto != "" {
+		albumName = job.livePhotoAlbumName(livePhoto, RelativePath(job.SourceFolder, livePhoto))
+	} else if len(job.Albums) == 0 {
+		albumName = job.fileAlbumName(fp, path, modTime, md)
+	}
+	route, _ := job.route(fp, path)
+	if route.Album != "" {
+		albumName = route.Album
+	}
+	if albumName == "" {
+		albumName = job.DefaultAlbum
+	}
+	if job.SharedAlbum != "" {
+		albumName = job.SharedAlbum
+	}
+	return albumName, route
+}
+
+// mediaItemToAttach returns the media item of the tracked file, if it should be added to the album because it's
+// not in it yet, or an empty string otherwise.
+func (job *UploadFolderJob) mediaItemToAttach(fp string, albumName string) string {
+	if albumName == "" {
+		return ""
+	}
+	tracker, ok := job.FileTracker.(AlbumTracker)
+	if !ok {
+		return ""
+	}
+	mediaItemID, albums, ok := tracker.TrackedAlbums(fp)
+	if !ok {
+		return ""
+	}
+	for _, album := range albums {
+		if album == albumName {
+			return ""
+		}
+	}
+	return mediaItemID
+}
+
+// isFavorite returns true if any of the parent folders of the file, given its path relative to the
+// source folder, is the favorites folder.
+func (job *UploadFolderJob) isFavorite(path string) bool {
+	if job.FavoritesFolder == "" {
+		return false
+	}
+	dirs := strings.Split(filepath.ToSlash(filepath.Dir(path)), "/")
+	for _, dir := range dirs {
+		if dir == job.FavoritesFolder {
+			return true
+		}
+	}
+	return false
+}
+
 // RelativePath returns a path relative to the base.
 // If a relative path could not be calculated or it contains ' ../`,
 // returns the original path.
@@ -74,3 +479,36 @@ func RelativePath(base string, path string) string {
 	}
 	return rp
 }
+
+// checkReadable returns an error if the file at fp could not be opened, e.g. because of its permissions.
+func (job *UploadFolderJob) checkReadable(fp string) error {
+	name, err := fsName(job.SourceFolder, fp)
+	if err != nil {
+		return err
+	}
+	f, err := job.fileSystem().Open(name)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// skipped counts the file at fp skipped for the reason, and reports it to OnSkipped, if it's set.
+func (job *UploadFolderJob) skipped(fp string, reason string) {
+	metrics.FilesSkipped.Inc(reason)
+	if job.OnSkipped != nil {
+		job.OnSkipped(fp, reason)
+	}
+}
+
+// skipUnreadable logs and counts the file or directory at fp that could not be read. It returns the error,
+// stopping the walk, if FailOnError is set.
+func (job *UploadFolderJob) skipUnreadable(fp string, err error, stats *WalkStats, logger log.Logger) error {
+	if job.FailOnError {
+		return err
+	}
+	logger.WithFields(log.Fields{"event": log.EventFileSkipped, "path": fp, "reason": log.ReasonUnreadable, "error": err}).Warnf("Skipping '%s', it could not be read: %s", fp, err)
+	stats.SkippedUnreadable++
+	job.skipped(fp, log.ReasonUnreadable)
+	return nil
+}
@@ -1,9 +1,33 @@
 package upload_test
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
+	"time"
 
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/datastore/filetracker"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/exif"
 	"github.com/gphotosuploader/gphotos-uploader-cli/internal/filter"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/imagehash"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
 	"github.com/gphotosuploader/gphotos-uploader-cli/internal/mock"
 	"github.com/gphotosuploader/gphotos-uploader-cli/internal/upload"
 )
@@ -135,7 +159,7 @@ func TestRelativePath(t *testing.T) {
 func getScanFolderResult(includePatterns []string, excludePatterns []string) (map[string]bool, error) {
 	var results = map[string]bool{}
 	ft := &mock.FileTracker{
-		PutFn: func(path string) error {
+		PutFn: func(path string, mediaItemID string) error {
 			return nil
 		},
 		ExistFn: func(path string) bool {
@@ -152,6 +176,7 @@ func getScanFolderResult(includePatterns []string, excludePatterns []string) (ma
 		SourceFolder:       "testdata",
 		CreateAlbums:       "Off",
 		Filter:             filterFiles,
+		FollowSymlinks:     true,
 	}
 
 	foundItems, err := u.ScanFolder(&mock.Logger{})
@@ -165,3 +190,1566 @@ func getScanFolderResult(includePatterns []string, excludePatterns []string) (ma
 
 	return results, nil
 }
+
+func TestUploadFolderJob_WalkFolderStats(t *testing.T) {
+	ft := &mock.FileTracker{
+		ExistFn: func(path string) bool {
+			return path == "testdata/folder1/SampleJPGImage.jpg"
+		},
+	}
+
+	u := upload.UploadFolderJob{
+		FileTracker:    ft,
+		SourceFolder:   "testdata",
+		CreateAlbums:   "Off",
+		Filter:         filter.MustCompile([]string{"_IMAGE_EXTENSIONS_"}, []string{"folder2/**", "ScreenShot*"}),
+		FollowSymlinks: true,
+	}
+
+	var found int
+	got, err := u.WalkFolder(&mock.Logger{}, func(item upload.FileItem) {
+		found++
+	})
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	// Allowed: SampleJPGImage.jpg, SamplePNGImage.png, folder1/SamplePNGImage.png and folder-symlink/*.
+	// Filtered: SampleAudio.mp3, SampleSVGImage.svg, SampleText.txt, SampleVideo.mp4, ScreenShot* and folder2/*.
+	want := upload.WalkStats{Found: 5, SkippedFiltered: 8, SkippedTracked: 1}
+	if got != want {
+		t.Errorf("want: %+v, got: %+v", want, got)
+	}
+	if found != want.Found {
+		t.Errorf("want: %d, got: %d", want.Found, found)
+	}
+}
+
+func TestUploadFolderJob_VisitFile(t *testing.T) {
+	ft := &mock.FileTracker{
+		ExistFn: func(path string) bool {
+			return path == "testdata/folder1/SampleJPGImage.jpg"
+		},
+	}
+
+	u := upload.UploadFolderJob{
+		FileTracker:  ft,
+		SourceFolder: "testdata",
+		CreateAlbums: "Off",
+		Filter:       filter.MustCompile([]string{"_IMAGE_EXTENSIONS_"}, []string{"ScreenShot*"}),
+	}
+
+	testCases := []struct {
+		name          string
+		path          string
+		want          upload.WalkStats
+		isErrExpected bool
+	}{
+		{"Should visit allowed file", "testdata/SampleJPGImage.jpg", upload.WalkStats{Found: 1}, false},
+		{"Should skip excluded file", "testdata/ScreenShotJPG.jpg", upload.WalkStats{SkippedFiltered: 1}, false},
+		{"Should skip tracked file", "testdata/folder1/SampleJPGImage.jpg", upload.WalkStats{SkippedTracked: 1}, false},
+		{"Should fail if file does not exist", "testdata/non-existent.jpg", upload.WalkStats{}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var found []string
+			got, err := u.VisitFile(&mock.Logger{}, tc.path, func(item upload.FileItem) {
+				found = append(found, item.Path)
+			})
+			if tc.isErrExpected != (err != nil) {
+				t.Fatalf("want error: %t, got: %v", tc.isErrExpected, err)
+			}
+			if got != tc.want {
+				t.Errorf("want: %+v, got: %+v", tc.want, got)
+			}
+			if len(found) != tc.want.Found {
+				t.Errorf("want: %d, got: %d", tc.want.Found, len(found))
+			}
+		})
+	}
+}
+
+func TestUploadFolderJob_WalkFolderMinFileAge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "walker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	files := map[string]time.Time{
+		"fresh.jpg": now,
+		"aged.jpg":  now.Add(-time.Hour),
+	}
+	for name, mtime := range files {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(name), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	u := upload.UploadFolderJob{
+		FileTracker:  &mock.FileTracker{ExistFn: func(path string) bool { return false }},
+		SourceFolder: dir,
+		CreateAlbums: "Off",
+		Filter:       filter.MustCompile([]string{"_ALL_FILES_"}, nil),
+		MinFileAge:   10 * time.Minute,
+	}
+
+	var found []string
+	stats, err := u.WalkFolder(&mock.Logger{}, func(item upload.FileItem) {
+		found = append(found, item.Path)
+	})
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	want := upload.WalkStats{Found: 1, SkippedRecent: 1}
+	if stats != want {
+		t.Errorf("want: %+v, got: %+v", want, stats)
+	}
+	if len(found) != 1 || found[0] != filepath.Join(dir, "aged.jpg") {
+		t.Errorf("want: %v, got: %v", []string{filepath.Join(dir, "aged.jpg")}, found)
+	}
+
+	// the deferred file is found once it is old enough.
+	aged := now.Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "fresh.jpg"), aged, aged); err != nil {
+		t.Fatal(err)
+	}
+	stats, err = u.VisitFile(&mock.Logger{}, filepath.Join(dir, "fresh.jpg"), func(item upload.FileItem) {})
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if want := (upload.WalkStats{Found: 1}); stats != want {
+		t.Errorf("want: %+v, got: %+v", want, stats)
+	}
+}
+
+func TestUploadFolderJob_WalkFolderAlbums(t *testing.T) {
+	jpgAlbum, err := upload.NewAlbumFilter("Photos", []string{"**/*.jpg"}, []string{"ScreenShot*"})
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	pngAlbum, err := upload.NewAlbumFilter("Drawings", []string{"folder1/*.png"}, nil)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	u := upload.UploadFolderJob{
+		FileTracker:    &mock.FileTracker{ExistFn: func(path string) bool { return false }},
+		SourceFolder:   "testdata",
+		CreateAlbums:   "folderName",
+		Filter:         filter.MustCompile([]string{"_ALL_FILES_"}, nil),
+		Albums:         []upload.AlbumFilter{jpgAlbum, pngAlbum},
+		FollowSymlinks: true,
+	}
+
+	got := make(map[string]string)
+	stats, err := u.WalkFolder(&mock.Logger{}, func(item upload.FileItem) {
+		got[item.Path] = item.AlbumName
+	})
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	want := map[string]string{
+		"testdata/SampleJPGImage.jpg":                "Photos",
+		"testdata/folder1/SampleJPGImage.jpg":        "Photos",
+		"testdata/folder2/SampleJPGImage.jpg":        "Photos",
+		"testdata/folder-symlink/SampleJPGImage.jpg": "Photos",
+		"testdata/folder1/SamplePNGImage.png":        "Drawings",
+	}
+	if len(got) != len(want) {
+		t.Errorf("want: %v, got: %v", want, got)
+	}
+	for path, album := range want {
+		if got[path] != album {
+			t.Errorf("want: %s, got: %s, file: %s", album, got[path], path)
+		}
+	}
+
+	// Not allowed by any album: SampleAudio.mp3, SamplePNGImage.png, SampleSVGImage.svg, SampleText.txt,
+	// SampleVideo.mp4, ScreenShotJPG.jpg, ScreenShotPNG.png, folder2/SamplePNGImage.png and folder-symlink/SamplePNGImage.png.
+	if stats.SkippedFiltered != 9 {
+		t.Errorf("want: %d, got: %d", 9, stats.SkippedFiltered)
+	}
+}
+
+func TestUploadFolderJob_WalkFolderSharedAlbum(t *testing.T) {
+	u := upload.UploadFolderJob{
+		FileTracker:  &mock.FileTracker{ExistFn: func(path string) bool { return false }},
+		SourceFolder: "testdata",
+		CreateAlbums: "folderName",
+		Filter:       filter.MustCompile([]string{"**/*.jpg"}, nil),
+		SharedAlbum:  "Family",
+	}
+
+	got := make(map[string]string)
+	_, err := u.WalkFolder(&mock.Logger{}, func(item upload.FileItem) {
+		got[item.Path] = item.AlbumName
+	})
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	// files of every folder are added to the shared album, instead of the one of their folder.
+	if len(got) == 0 {
+		t.Fatalf("want: files found, got: none")
+	}
+	for path, album := range got {
+		if want := "Family"; album != want {
+			t.Errorf("want: %s, got: %s, file: %s", want, album, path)
+		}
+	}
+}
+
+func TestUploadFolderJob_WalkFolderDefaultAlbum(t *testing.T) {
+	dir, err := ioutil.TempDir("", "walker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"IMG_0001.jpg", "Trips/IMG_0002.jpg", "Raw/IMG_0003.raf", "Tracked.jpg", "TrackedRaw.raf"} {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(name), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	route, err := upload.NewRoute("", "Archive", []string{"**/*.raf"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the tracked files are in the default album, and in the one of their route, already.
+	tracked := map[string][]string{
+		filepath.Join(dir, "Tracked.jpg"):    {"Uploaded by CLI"},
+		filepath.Join(dir, "TrackedRaw.raf"): {"Archive"},
+	}
+	u := upload.UploadFolderJob{
+		FileTracker: &mock.AlbumTracker{
+			FileTracker: mock.FileTracker{ExistFn: func(path string) bool { return tracked[path] != nil }},
+			TrackedAlbumsFn: func(path string) (string, []string, bool) {
+				return "media-" + filepath.Base(path), tracked[path], tracked[path] != nil
+			},
+		},
+		SourceFolder: dir,
+		CreateAlbums: "folderName",
+		Filter:       filter.MustCompile([]string{"_ALL_FILES_"}, nil),
+		Routes:       []upload.Route{route},
+		DefaultAlbum: "Uploaded by CLI",
+	}
+
+	got := make(map[string]string)
+	_, err = u.WalkFolder(&mock.Logger{}, func(item upload.FileItem) {
+		got[upload.RelativePath(dir, item.Path)] = item.AlbumName
+	})
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	// the albums given by CreateAlbums and Routes take precedence, only the other files are added to the default one.
+	want := map[string]string{
+		"IMG_0001.jpg":       "Uploaded by CLI",
+		"Trips/IMG_0002.jpg": "Trips",
+		"Raw/IMG_0003.raf":   "Archive",
+	}
+	if fmt.Sprint(want) != fmt.Sprint(got) {
+		t.Errorf("want: %v, got: %v", want, got)
+	}
+}
+
+func TestUploadFolderJob_WalkFolderRoutedTracked(t *testing.T) {
+	dir, err := ioutil.TempDir("", "walker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"Trips/VID_0001.mp4", "Trips/VID_0002.mp4"} {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(name), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	route, err := upload.NewRoute("", "Videos", []string{"**/*.mp4"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the first video is in the album of its route already, the second one is in the album of its folder.
+	tracked := map[string][]string{
+		filepath.Join(dir, "Trips", "VID_0001.mp4"): {"Videos"},
+		filepath.Join(dir, "Trips", "VID_0002.mp4"): {"Trips"},
+	}
+	u := upload.UploadFolderJob{
+		FileTracker: &mock.AlbumTracker{
+			FileTracker: mock.FileTracker{ExistFn: func(path string) bool { return tracked[path] != nil }},
+			TrackedAlbumsFn: func(path string) (string, []string, bool) {
+				return "media-" + filepath.Base(path), tracked[path], tracked[path] != nil
+			},
+		},
+		SourceFolder: dir,
+		CreateAlbums: "folderName",
+		Filter:       filter.MustCompile([]string{"_ALL_FILES_"}, nil),
+		Routes:       []upload.Route{route},
+	}
+
+	got := make(map[string]string)
+	_, err = u.WalkFolder(&mock.Logger{}, func(item upload.FileItem) {
+		got[upload.RelativePath(dir, item.Path)] = item.AlbumName + "/" + item.MediaItemID
+	})
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	// only the video not in the album of its route is added to it.
+	want := map[string]string{
+		filepath.Join("Trips", "VID_0002.mp4"): "Videos/media-VID_0002.mp4",
+	}
+	if fmt.Sprint(want) != fmt.Sprint(got) {
+		t.Errorf("want: %v, got: %v", want, got)
+	}
+}
+
+func TestNewAlbumFilter(t *testing.T) {
+	_, err := upload.NewAlbumFilter("Trips", []string{"re:IMG_[0-9"}, nil)
+	if err == nil {
+		t.Fatalf("error was expected, but not produced")
+	}
+	if !strings.Contains(err.Error(), "'Trips'") {
+		t.Errorf("want: error referencing the album, got: %s", err)
+	}
+}
+
+func TestUploadFolderJob_WalkFolderChangedSince(t *testing.T) {
+	dir, err := ioutil.TempDir("", "walker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"unchanged.jpg", "touched.jpg"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(name), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	u := upload.UploadFolderJob{
+		FileTracker:  &mock.FileTracker{ExistFn: func(path string) bool { return false }},
+		SourceFolder: dir,
+		CreateAlbums: "Off",
+		Filter:       filter.MustCompile([]string{"_ALL_FILES_"}, nil),
+	}
+	walk := func() ([]string, upload.WalkStats) {
+		var found []string
+		stats, err := u.WalkFolder(&mock.Logger{}, func(item upload.FileItem) {
+			found = append(found, filepath.Base(item.Path))
+		})
+		if err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+		return found, stats
+	}
+
+	// the first run checks all the files.
+	time.Sleep(50 * time.Millisecond)
+	firstRunStart := time.Now()
+	if _, stats := walk(); stats != (upload.WalkStats{Found: 2}) {
+		t.Errorf("want: %+v, got: %+v", upload.WalkStats{Found: 2}, stats)
+	}
+
+	// the second run only checks the files changed since the first one started.
+	time.Sleep(50 * time.Millisecond)
+	now := time.Now()
+	if err := os.Chtimes(filepath.Join(dir, "touched.jpg"), now, now); err != nil {
+		t.Fatal(err)
+	}
+	u.ChangedSince = firstRunStart
+	found, stats := walk()
+	if want := (upload.WalkStats{Found: 1, SkippedUnchanged: 1}); stats != want {
+		t.Errorf("want: %+v, got: %+v", want, stats)
+	}
+	if len(found) != 1 || found[0] != "touched.jpg" {
+		t.Errorf("want: %v, got: %v", []string{"touched.jpg"}, found)
+	}
+}
+
+func TestUploadFolderJob_WalkFolderChangedFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "walker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := os.MkdirAll(filepath.Join(dir, "edited"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"unchanged.jpg", "changed.jpg", filepath.Join("edited", "changed.jpg"), filepath.Join("edited", "unchanged.jpg")} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(name), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	u := upload.UploadFolderJob{
+		FileTracker:  &mock.FileTracker{ExistFn: func(path string) bool { return false }},
+		SourceFolder: dir,
+		CreateAlbums: "Off",
+		Filter:       filter.MustCompile([]string{"_ALL_FILES_"}, nil),
+		// changed files that are not in the folder anymore are ignored.
+		ChangedFiles: map[string]bool{"changed.jpg": true, filepath.Join("edited", "changed.jpg"): true, "deleted.jpg": true},
+	}
+	var found []string
+	stats, err := u.WalkFolder(&mock.Logger{}, func(item upload.FileItem) {
+		found = append(found, upload.RelativePath(dir, item.Path))
+	})
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if want := (upload.WalkStats{Found: 2, SkippedUnchanged: 2}); stats != want {
+		t.Errorf("want: %+v, got: %+v", want, stats)
+	}
+	sort.Strings(found)
+	if want := []string{"changed.jpg", filepath.Join("edited", "changed.jpg")}; !reflect.DeepEqual(want, found) {
+		t.Errorf("want: %v, got: %v", want, found)
+	}
+}
+
+func TestUploadFolderJob_WalkFolderChangedSinceKeepsOldModTime(t *testing.T) {
+	switch runtime.GOOS {
+	case "linux", "darwin", "freebsd":
+	default:
+		t.Skip("change time is not available")
+	}
+	dir, err := ioutil.TempDir("", "walker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	since := time.Now().Add(-time.Minute)
+	// a file copied keeping its modification time, older than the last run.
+	path := filepath.Join(dir, "copied.jpg")
+	if err := ioutil.WriteFile(path, []byte("copied"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	old := since.Add(-24 * time.Hour)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	u := upload.UploadFolderJob{
+		FileTracker:  &mock.FileTracker{ExistFn: func(path string) bool { return false }},
+		SourceFolder: dir,
+		CreateAlbums: "Off",
+		Filter:       filter.MustCompile([]string{"_ALL_FILES_"}, nil),
+		ChangedSince: since,
+	}
+	stats, err := u.WalkFolder(&mock.Logger{}, func(item upload.FileItem) {})
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if want := (upload.WalkStats{Found: 1}); stats != want {
+		t.Errorf("want: %+v, got: %+v", want, stats)
+	}
+}
+
+// countingMetadataReader returns the metadata by file name, counting the reads by file name.
+type countingMetadataReader struct {
+	metadata map[string]exif.Metadata
+	reads    map[string]int
+}
+
+func (r *countingMetadataReader) Metadata(path string) (exif.Metadata, error) {
+	name := filepath.Base(path)
+	r.reads[name]++
+	md, ok := r.metadata[name]
+	if !ok {
+		return exif.Metadata{}, exif.ErrNotFound
+	}
+	return md, nil
+}
+
+func TestUploadFolderJob_WalkFolderExifFilter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "walker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	reader := &countingMetadataReader{
+		metadata: map[string]exif.Metadata{
+			"sony.jpg":  {Make: "SONY", Model: "ILCE-7M4", DateTimeOriginal: time.Date(2023, 7, 14, 18, 30, 5, 0, time.Local)},
+			"canon.jpg": {Make: "Canon", Model: "Canon EOS R5", DateTimeOriginal: time.Date(2023, 8, 1, 10, 0, 0, 0, time.Local)},
+		},
+		reads: make(map[string]int),
+	}
+	for _, name := range []string{"sony.jpg", "canon.jpg", "screenshot.png"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(name), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	u := upload.UploadFolderJob{
+		FileTracker:    &mock.FileTracker{ExistFn: func(path string) bool { return false }},
+		SourceFolder:   dir,
+		CreateAlbums:   "exifDate",
+		Filter:         filter.MustCompile([]string{"_ALL_FILES_"}, nil),
+		ExifFilter:     exif.Filter{Model: "ILCE-7M4"},
+		MetadataReader: reader,
+	}
+
+	found := make(map[string]string)
+	stats, err := u.WalkFolder(&mock.Logger{}, func(item upload.FileItem) {
+		found[filepath.Base(item.Path)] = item.AlbumName
+	})
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	// the other camera, and the screenshot without EXIF metadata, are skipped.
+	if want := (upload.WalkStats{Found: 1, SkippedFiltered: 2}); stats != want {
+		t.Errorf("want: %+v, got: %+v", want, stats)
+	}
+	if len(found) != 1 || found["sony.jpg"] != "2023-07" {
+		t.Errorf("want: map[sony.jpg:2023-07], got: %v", found)
+	}
+	// the metadata is read once, by the filter, and used by the album name too.
+	for name, n := range reader.reads {
+		if n != 1 {
+			t.Errorf("want: 1 read, got: %d, file: %s", n, name)
+		}
+	}
+}
+
+func TestUploadFolderJob_WalkFolderMIMEFilter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "walker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	png, err := ioutil.ReadFile("testdata/SamplePNGImage.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jpg, err := ioutil.ReadFile("testdata/SampleJPGImage.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the PNG image has been renamed to .jpg, so its extension is misleading.
+	files := map[string][]byte{"photo.jpg": jpg, "renamed.jpg": png, "photo.png": png}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), content, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		allowed  []string
+		excluded []string
+		want     []string
+	}{
+		{"Should allow files by their true content type", []string{"image/jpeg"}, nil, []string{"photo.jpg"}},
+		{"Should allow files by wildcard", []string{"image/*"}, nil, []string{"photo.jpg", "photo.png", "renamed.jpg"}},
+		{"Should exclude files by their true content type", nil, []string{"image/png"}, []string{"photo.jpg"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mimeFilter, err := filter.NewMIMEFilter(tc.allowed, tc.excluded)
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			u := upload.UploadFolderJob{
+				FileTracker:  &mock.FileTracker{ExistFn: func(path string) bool { return false }},
+				SourceFolder: dir,
+				CreateAlbums: "Off",
+				// both filters must allow the files.
+				Filter:     filter.MustCompile([]string{"**/*.jpg", "**/*.png"}, nil),
+				MIMEFilter: mimeFilter,
+			}
+
+			var found []string
+			stats, err := u.WalkFolder(&mock.Logger{}, func(item upload.FileItem) {
+				found = append(found, filepath.Base(item.Path))
+			})
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			sort.Strings(found)
+			if strings.Join(tc.want, ",") != strings.Join(found, ",") {
+				t.Errorf("want: %v, got: %v", tc.want, found)
+			}
+			if want := len(files) - len(tc.want); stats.SkippedFiltered != want {
+				t.Errorf("want: %d skipped, got: %d", want, stats.SkippedFiltered)
+			}
+		})
+	}
+}
+
+func TestUploadFolderJob_WalkFolderFilenameDate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "walker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	reader := &countingMetadataReader{
+		metadata: map[string]exif.Metadata{
+			"IMG_0001.jpg": {DateTimeOriginal: time.Date(2023, 8, 1, 10, 0, 0, 0, time.Local)},
+			"IMG_0002.jpg": {DateTimeOriginal: time.Date(2022, 1, 1, 10, 0, 0, 0, time.Local)},
+		},
+		reads: make(map[string]int),
+	}
+	for _, name := range []string{"20230715_142233.jpg", "20210101_000000.jpg", "IMG_0001.jpg", "IMG_0002.jpg"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(name), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	filenameDate, err := upload.NewFilenameDate("", "20060102_150405")
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	dateRange, err := upload.ParseDateRange("2023-01-01", "2023-12-31")
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	u := upload.UploadFolderJob{
+		FileTracker:    &mock.FileTracker{ExistFn: func(path string) bool { return false }},
+		SourceFolder:   dir,
+		CreateAlbums:   "exifDate",
+		Filter:         filter.MustCompile([]string{"_ALL_FILES_"}, nil),
+		DateRange:      dateRange,
+		FilenameDate:   filenameDate,
+		MetadataReader: reader,
+	}
+
+	found := make(map[string]string)
+	if _, err := u.WalkFolder(&mock.Logger{}, func(item upload.FileItem) {
+		found[filepath.Base(item.Path)] = item.AlbumName
+	}); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	if want := map[string]string{"20230715_142233.jpg": "2023-07", "IMG_0001.jpg": "2023-08"}; !reflect.DeepEqual(want, found) {
+		t.Errorf("want: %v, got: %v", want, found)
+	}
+	// files whose name has the date are neither filtered nor added to albums by their metadata.
+	if want := map[string]int{"IMG_0001.jpg": 1, "IMG_0002.jpg": 1}; !reflect.DeepEqual(want, reader.reads) {
+		t.Errorf("want: %v, got: %v", want, reader.reads)
+	}
+}
+
+func TestUploadFolderJob_WalkFolderContextCancelled(t *testing.T) {
+	for _, workers := range []int{1, 4} {
+		u := upload.UploadFolderJob{
+			FileTracker:  &mock.FileTracker{ExistFn: func(path string) bool { return false }},
+			SourceFolder: "testdata",
+			CreateAlbums: "Off",
+			Filter:       filter.MustCompile([]string{"_ALL_FILES_"}, nil),
+			ScanWorkers:  workers,
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		var found int
+		_, err := u.WalkFolderContext(ctx, &mock.Logger{}, func(item upload.FileItem) {
+			found++
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("want: %v, got: %v, workers: %d", context.Canceled, err, workers)
+		}
+		if found != 0 {
+			t.Errorf("want: no items, got: %d, workers: %d", found, workers)
+		}
+	}
+}
+
+func TestUploadFolderJob_WalkFolderDateRange(t *testing.T) {
+	dir, err := ioutil.TempDir("", "walker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	since := time.Date(2023, 7, 1, 0, 0, 0, 0, time.Local)
+	// files with EXIF metadata are dated by their capture date, the other ones by their modification time.
+	reader := &countingMetadataReader{
+		metadata: map[string]exif.Metadata{
+			"exif-before.jpg": {DateTimeOriginal: since.Add(-time.Second)},
+			"exif-on.jpg":     {DateTimeOriginal: since},
+			"exif-after.jpg":  {DateTimeOriginal: since.AddDate(0, 0, 1)},
+		},
+		reads: make(map[string]int),
+	}
+	modTimes := map[string]time.Time{
+		"exif-before.jpg":  since.AddDate(0, 1, 0),
+		"exif-on.jpg":      since.AddDate(0, -1, 0),
+		"exif-after.jpg":   since.AddDate(0, -1, 0),
+		"mtime-before.jpg": since.Add(-time.Second),
+		"mtime-on.jpg":     since,
+		"mtime-after.jpg":  since.AddDate(0, 0, 1),
+	}
+	for name, modTime := range modTimes {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(name), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	u := upload.UploadFolderJob{
+		FileTracker:    &mock.FileTracker{ExistFn: func(path string) bool { return false }},
+		SourceFolder:   dir,
+		CreateAlbums:   "Off",
+		Filter:         filter.MustCompile([]string{"_ALL_FILES_"}, nil),
+		DateRange:      upload.DateRange{Since: since},
+		MetadataReader: reader,
+	}
+	var found []string
+	stats, err := u.WalkFolder(&mock.Logger{}, func(item upload.FileItem) {
+		found = append(found, filepath.Base(item.Path))
+	})
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	want := []string{"exif-after.jpg", "exif-on.jpg", "mtime-after.jpg", "mtime-on.jpg"}
+	if strings.Join(found, ",") != strings.Join(want, ",") {
+		t.Errorf("want: %v, got: %v", want, found)
+	}
+	if want := (upload.WalkStats{Found: 4, SkippedFiltered: 2}); stats != want {
+		t.Errorf("want: %+v, got: %+v", want, stats)
+	}
+}
+
+func TestUploadFolderJob_WalkFolderTrackedAlbums(t *testing.T) {
+	tracked := map[string][]string{
+		// uploaded to another album, it's added to folder1.
+		"testdata/folder1/SampleJPGImage.jpg": {"Trips"},
+		// uploaded to folder1 already.
+		"testdata/folder1/SamplePNGImage.png": {"folder1"},
+		// uploaded by previous versions, the albums are unknown.
+		"testdata/folder2/SampleJPGImage.jpg": nil,
+	}
+	ft := &mock.AlbumTracker{
+		FileTracker: mock.FileTracker{
+			ExistFn: func(path string) bool {
+				_, ok := tracked[path]
+				return ok
+			},
+		},
+		TrackedAlbumsFn: func(path string) (string, []string, bool) {
+			albums := tracked[path]
+			return "media-" + filepath.Base(path), albums, len(albums) > 0
+		},
+	}
+
+	u := upload.UploadFolderJob{
+		FileTracker:  ft,
+		SourceFolder: "testdata",
+		CreateAlbums: "folderName",
+		Filter:       filter.MustCompile([]string{"folder1/**", "folder2/**"}, nil),
+	}
+
+	got := make(map[string]string)
+	stats, err := u.WalkFolder(&mock.Logger{}, func(item upload.FileItem) {
+		got[item.Path] = item.MediaItemID
+	})
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	want := map[string]string{
+		"testdata/folder1/SampleJPGImage.jpg": "media-SampleJPGImage.jpg",
+		"testdata/folder2/SamplePNGImage.png": "",
+	}
+	if len(got) != len(want) {
+		t.Errorf("want: %v, got: %v", want, got)
+	}
+	for path, mediaItemID := range want {
+		if id, ok := got[path]; !ok || id != mediaItemID {
+			t.Errorf("want: %q, got: %q, file: %s", mediaItemID, id, path)
+		}
+	}
+	if stats.SkippedTracked != 2 {
+		t.Errorf("want: %d, got: %d", 2, stats.SkippedTracked)
+	}
+}
+
+func TestUploadFolderJob_WalkFolderFS(t *testing.T) {
+	modTime := time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"IMG_0001.jpg":           {Data: []byte("photo"), ModTime: modTime},
+		"notes.txt":              {Data: []byte("text"), ModTime: modTime},
+		"trips/IMG_0002.jpg":     {Data: []byte("photo"), ModTime: modTime},
+		"trips/VID_0001.mp4":     {Data: []byte("video"), ModTime: modTime},
+		"trips/raw/IMG_0003.jpg": {Data: []byte("photo"), ModTime: modTime},
+		"private/IMG_0004.jpg":   {Data: []byte("photo"), ModTime: modTime},
+	}
+	want := []string{
+		filepath.Join("/photos", "IMG_0001.jpg"),
+		filepath.Join("/photos", "trips", "IMG_0002.jpg"),
+		filepath.Join("/photos", "trips", "raw", "IMG_0003.jpg"),
+	}
+
+	for _, workers := range []int{1, 4} {
+		t.Run(fmt.Sprintf("workers-%d", workers), func(t *testing.T) {
+			u := upload.UploadFolderJob{
+				FileTracker:  &mock.FileTracker{ExistFn: func(path string) bool { return false }},
+				SourceFolder: "/photos",
+				FS:           fsys,
+				CreateAlbums: "folderName",
+				Filter:       filter.MustCompile([]string{"_IMAGE_EXTENSIONS_"}, []string{"private"}),
+				ScanWorkers:  workers,
+			}
+
+			got := make(map[string]upload.FileItem)
+			stats, err := u.WalkFolder(&mock.Logger{}, func(item upload.FileItem) {
+				got[item.Path] = item
+			})
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+
+			if len(got) != len(want) {
+				t.Errorf("want: %v, got: %v", want, got)
+			}
+			for _, path := range want {
+				item, ok := got[path]
+				if !ok || !item.ModTime.Equal(modTime) {
+					t.Errorf("file should be found with its modification time: %s, got: %+v", path, item)
+				}
+			}
+			if item := got[filepath.Join("/photos", "trips", "IMG_0002.jpg")]; item.AlbumName != "trips" {
+				t.Errorf("want: %s, got: %s", "trips", item.AlbumName)
+			}
+			// notes.txt and VID_0001.mp4 are filtered, private is pruned.
+			if want := (upload.WalkStats{Found: 3, SkippedFiltered: 2}); stats != want {
+				t.Errorf("want: %+v, got: %+v", want, stats)
+			}
+		})
+	}
+}
+
+func TestUploadFolderJob_VisitFileFS(t *testing.T) {
+	u := upload.UploadFolderJob{
+		FileTracker:  &mock.FileTracker{ExistFn: func(path string) bool { return false }},
+		SourceFolder: "/photos",
+		FS:           fstest.MapFS{"trips/IMG_0001.jpg": {Data: []byte("photo")}},
+		CreateAlbums: "Off",
+		Filter:       filter.MustCompile([]string{"_ALL_FILES_"}, nil),
+	}
+
+	testCases := []struct {
+		name          string
+		path          string
+		want          upload.WalkStats
+		isErrExpected bool
+	}{
+		{"Should visit file", filepath.Join("/photos", "trips", "IMG_0001.jpg"), upload.WalkStats{Found: 1}, false},
+		{"Should not visit directory", filepath.Join("/photos", "trips"), upload.WalkStats{}, false},
+		{"Should fail if file does not exist", filepath.Join("/photos", "IMG_0002.jpg"), upload.WalkStats{}, true},
+		{"Should fail if file is out of the folder", filepath.Join("/videos", "VID_0001.mp4"), upload.WalkStats{}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := u.VisitFile(&mock.Logger{}, tc.path, func(item upload.FileItem) {})
+			if tc.isErrExpected != (err != nil) {
+				t.Fatalf("want error: %t, got: %v", tc.isErrExpected, err)
+			}
+			if got != tc.want {
+				t.Errorf("want: %+v, got: %+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestUploadFolderJob_WalkFolderIgnoreFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		".gphotosignore":            {Data: []byte("# top level rules\n**/*.png\ndrafts\n")},
+		"IMG_0001.jpg":              {Data: []byte("photo")},
+		"IMG_0002.png":              {Data: []byte("photo")},
+		"drafts/IMG_0003.jpg":       {Data: []byte("photo")},
+		"trips/.gphotosignore":      {Data: []byte("*.jpg\n")},
+		"trips/IMG_0004.jpg":        {Data: []byte("photo")},
+		"trips/raw/IMG_0005.jpg":    {Data: []byte("photo")},
+		"trips/keep/.gphotosignore": {Data: []byte("!**/*.png\n!**/*.gif\n")},
+		"trips/keep/IMG_0006.png":   {Data: []byte("photo")},
+		"trips/keep/IMG_0010.gif":   {Data: []byte("photo")},
+		"other/IMG_0007.jpg":        {Data: []byte("photo")},
+		"other/IMG_0008.png":        {Data: []byte("photo")},
+		"other/IMG_0009.gif":        {Data: []byte("photo")},
+	}
+	want := []string{
+		filepath.Join("/photos", "IMG_0001.jpg"),
+		// trips rules without a slash only match its top level items.
+		filepath.Join("/photos", "trips", "raw", "IMG_0005.jpg"),
+		// deeper ignore files re-include the items excluded by the upper ones.
+		filepath.Join("/photos", "trips", "keep", "IMG_0006.png"),
+		// trips rules don't apply out of its subtree.
+		filepath.Join("/photos", "other", "IMG_0007.jpg"),
+	}
+
+	for _, workers := range []int{1, 4} {
+		t.Run(fmt.Sprintf("workers-%d", workers), func(t *testing.T) {
+			u := upload.UploadFolderJob{
+				FileTracker:  &mock.FileTracker{ExistFn: func(path string) bool { return false }},
+				SourceFolder: "/photos",
+				FS:           fsys,
+				CreateAlbums: "Off",
+				// ignore files could not re-include the files excluded by the job filter.
+				Filter:      filter.MustCompile([]string{"_ALL_FILES_"}, []string{"**/*.gif"}),
+				ScanWorkers: workers,
+			}
+
+			var got []string
+			stats, err := u.WalkFolder(&mock.Logger{}, func(item upload.FileItem) {
+				got = append(got, item.Path)
+			})
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+
+			sort.Strings(got)
+			sort.Strings(want)
+			if !reflect.DeepEqual(want, got) {
+				t.Errorf("want: %v, got: %v", want, got)
+			}
+			// drafts is pruned, and the ignore files are not counted.
+			if want := (upload.WalkStats{Found: 4, SkippedFiltered: 5}); stats != want {
+				t.Errorf("want: %+v, got: %+v", want, stats)
+			}
+		})
+	}
+
+	t.Run("VisitFile", func(t *testing.T) {
+		u := upload.UploadFolderJob{
+			FileTracker:  &mock.FileTracker{ExistFn: func(path string) bool { return false }},
+			SourceFolder: "/photos",
+			FS:           fsys,
+			CreateAlbums: "Off",
+			Filter:       filter.MustCompile([]string{"_ALL_FILES_"}, nil),
+		}
+		testCases := []struct {
+			path string
+			want upload.WalkStats
+		}{
+			{filepath.Join("/photos", "drafts", "IMG_0003.jpg"), upload.WalkStats{SkippedFiltered: 1}},
+			{filepath.Join("/photos", "trips", "IMG_0004.jpg"), upload.WalkStats{SkippedFiltered: 1}},
+			{filepath.Join("/photos", "trips", "keep", "IMG_0006.png"), upload.WalkStats{Found: 1}},
+		}
+		for _, tc := range testCases {
+			got, err := u.VisitFile(&mock.Logger{}, tc.path, func(item upload.FileItem) {})
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			if got != tc.want {
+				t.Errorf("path: %s, want: %+v, got: %+v", tc.path, tc.want, got)
+			}
+		}
+	})
+
+	t.Run("InvalidIgnoreFile", func(t *testing.T) {
+		u := upload.UploadFolderJob{
+			FileTracker:  &mock.FileTracker{ExistFn: func(path string) bool { return false }},
+			SourceFolder: "/photos",
+			FS: fstest.MapFS{
+				"trips/.gphotosignore": {Data: []byte("re:(\n")},
+				"trips/IMG_0001.jpg":   {Data: []byte("photo")},
+			},
+			CreateAlbums: "Off",
+			Filter:       filter.MustCompile([]string{"_ALL_FILES_"}, nil),
+		}
+		if _, err := u.ScanFolder(&mock.Logger{}); err == nil {
+			t.Errorf("error was expected at this point")
+		}
+	})
+}
+
+func TestUploadFolderJob_WalkFolderFavorites(t *testing.T) {
+	fsys := fstest.MapFS{
+		"IMG_0001.jpg":                      {Data: []byte("photo")},
+		"_favorites.jpg":                    {Data: []byte("photo")},
+		"_favorites/IMG_0002.jpg":           {Data: []byte("photo")},
+		"trips/IMG_0003.jpg":                {Data: []byte("photo")},
+		"trips/_favorites/IMG_0004.jpg":     {Data: []byte("photo")},
+		"trips/_favorites/raw/IMG_0005.jpg": {Data: []byte("photo")},
+	}
+
+	testCases := []struct {
+		name            string
+		favoritesFolder string
+		want            []string
+	}{
+		{"Should find favorites at any depth", "_favorites", []string{
+			filepath.Join("/photos", "_favorites", "IMG_0002.jpg"),
+			filepath.Join("/photos", "trips", "_favorites", "IMG_0004.jpg"),
+			filepath.Join("/photos", "trips", "_favorites", "raw", "IMG_0005.jpg"),
+		}},
+		{"Should not find favorites without folder", "", nil},
+		{"Should not find favorites with other folder", "favorites", nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			u := upload.UploadFolderJob{
+				FileTracker:     &mock.FileTracker{ExistFn: func(path string) bool { return false }},
+				SourceFolder:    "/photos",
+				FS:              fsys,
+				CreateAlbums:    "Off",
+				Filter:          filter.MustCompile([]string{"_ALL_FILES_"}, nil),
+				FavoritesFolder: tc.favoritesFolder,
+			}
+
+			items, err := u.ScanFolder(&mock.Logger{})
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			if len(items) != len(fsys) {
+				t.Errorf("want: %d, got: %d", len(fsys), len(items))
+			}
+			var got []string
+			for _, item := range items {
+				if item.Favorite {
+					got = append(got, item.Path)
+				}
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(tc.want, got) {
+				t.Errorf("want: %v, got: %v", tc.want, got)
+			}
+		})
+	}
+}
+
+// writableMapFS is an in-memory WritableFS.
+type writableMapFS struct{ fstest.MapFS }
+
+func (fsys writableMapFS) Remove(name string) error {
+	delete(fsys.MapFS, name)
+	return nil
+}
+
+func (fsys writableMapFS) Rename(oldname string, newname string) error {
+	fsys.MapFS[newname] = fsys.MapFS[oldname]
+	delete(fsys.MapFS, oldname)
+	return nil
+}
+
+func TestUploadFolderJob_Writable(t *testing.T) {
+	testCases := []struct {
+		name string
+		fsys fs.FS
+		want bool
+	}{
+		{"Should write to local file system by default", nil, true},
+		{"Should not write to read-only file system", fstest.MapFS{}, false},
+		{"Should write to writable file system", writableMapFS{fstest.MapFS{}}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			u := upload.UploadFolderJob{SourceFolder: "/photos", FS: tc.fsys}
+			if got := u.Writable(); got != tc.want {
+				t.Errorf("want: %t, got: %t", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestUploadFolderJob_WalkFolderSymlinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symbolic links require privileges on Windows")
+	}
+	dir, err := ioutil.TempDir("", "symlinks")
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// photos/loop and photos/parent loop back to the directories they are in, link-excluded is pruned
+	// by its directory pattern, and outside.jpg points out of the source folder.
+	src := filepath.Join(dir, "src")
+	for _, d := range []string{"photos", "excluded"} {
+		if err := os.MkdirAll(filepath.Join(src, d), 0700); err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+	}
+	for _, name := range []string{"photos/a.jpg", "excluded/b.jpg", "../c.jpg"} {
+		if err := ioutil.WriteFile(filepath.Join(src, name), []byte("jpg"), 0600); err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+	}
+	links := map[string]string{
+		"photos/loop":   ".",
+		"photos/parent": "..",
+		"link-excluded": "excluded",
+		"outside.jpg":   "../c.jpg",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(src, name)); err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+	}
+
+	testCases := []struct {
+		name    string
+		follow  bool
+		workers int
+		want    []string
+	}{
+		{"Should skip symlinks", false, 1, []string{"excluded/b.jpg", "photos/a.jpg"}},
+		{"Should skip symlinks in parallel", false, 4, []string{"excluded/b.jpg", "photos/a.jpg"}},
+		{"Should follow symlinks without loops", true, 1, []string{"excluded/b.jpg", "outside.jpg", "photos/a.jpg"}},
+		{"Should follow symlinks without loops in parallel", true, 4, []string{"excluded/b.jpg", "outside.jpg", "photos/a.jpg"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			u := upload.UploadFolderJob{
+				FileTracker:    &mock.FileTracker{ExistFn: func(path string) bool { return false }},
+				SourceFolder:   src,
+				CreateAlbums:   "Off",
+				Filter:         filter.MustCompile([]string{"_ALL_FILES_"}, []string{"link-excluded"}),
+				FollowSymlinks: tc.follow,
+				ScanWorkers:    tc.workers,
+			}
+
+			var got []string
+			if _, err := u.WalkFolder(&mock.Logger{}, func(item upload.FileItem) {
+				got = append(got, upload.RelativePath(src, item.Path))
+			}); err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(tc.want, got) {
+				t.Errorf("want: %v, got: %v", tc.want, got)
+			}
+
+			var visited []string
+			if _, err := u.VisitFile(&mock.Logger{}, filepath.Join(src, "outside.jpg"), func(item upload.FileItem) {
+				visited = append(visited, item.Path)
+			}); err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			if want := map[bool]int{false: 0, true: 1}[tc.follow]; len(visited) != want {
+				t.Errorf("want: %d, got: %d", want, len(visited))
+			}
+		})
+	}
+}
+
+// unreadableFS is a file system whose named files and directories could not be read.
+type unreadableFS struct {
+	fstest.MapFS
+	unreadable map[string]bool
+}
+
+func (fsys unreadableFS) Open(name string) (fs.File, error) {
+	if fsys.unreadable[name] {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+	}
+	return fsys.MapFS.Open(name)
+}
+
+func (fsys unreadableFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if fsys.unreadable[name] {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+	}
+	return fsys.MapFS.ReadDir(name)
+}
+
+func TestUploadFolderJob_WalkFolderUnreadable(t *testing.T) {
+	fsys := unreadableFS{
+		MapFS: fstest.MapFS{
+			"IMG_0001.jpg":         {Data: []byte("photo")},
+			"IMG_0002.jpg":         {Data: []byte("photo")},
+			"trips/IMG_0003.jpg":   {Data: []byte("photo")},
+			"private/IMG_0004.jpg": {Data: []byte("photo")},
+		},
+		unreadable: map[string]bool{"IMG_0001.jpg": true, "private": true},
+	}
+
+	for _, workers := range []int{1, 4} {
+		t.Run(fmt.Sprintf("workers-%d", workers), func(t *testing.T) {
+			u := upload.UploadFolderJob{
+				FileTracker:  &mock.FileTracker{ExistFn: func(path string) bool { return false }},
+				SourceFolder: "/photos",
+				FS:           fsys,
+				CreateAlbums: "Off",
+				Filter:       filter.MustCompile([]string{"_ALL_FILES_"}, nil),
+				ScanWorkers:  workers,
+			}
+
+			var got []string
+			stats, err := u.WalkFolder(&mock.Logger{}, func(item upload.FileItem) {
+				got = append(got, item.Path)
+			})
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+
+			// the unreadable file and directory are skipped, the other files are found.
+			sort.Strings(got)
+			want := []string{filepath.Join("/photos", "IMG_0002.jpg"), filepath.Join("/photos", "trips", "IMG_0003.jpg")}
+			if !reflect.DeepEqual(want, got) {
+				t.Errorf("want: %v, got: %v", want, got)
+			}
+			if want := (upload.WalkStats{Found: 2, SkippedUnreadable: 2}); stats != want {
+				t.Errorf("want: %+v, got: %+v", want, stats)
+			}
+
+			u.FailOnError = true
+			if _, err := u.WalkFolder(&mock.Logger{}, func(item upload.FileItem) {}); !errors.Is(err, fs.ErrPermission) {
+				t.Errorf("want: %v, got: %v", fs.ErrPermission, err)
+			}
+		})
+	}
+}
+
+func TestUploadFolderJob_WalkFolderHidden(t *testing.T) {
+	fsys := fstest.MapFS{
+		"IMG_0001.jpg":                {Data: []byte("photo")},
+		".IMG_0002.jpg":               {Data: []byte("photo")},
+		".thumbnails/IMG_0003.jpg":    {Data: []byte("photo")},
+		"trips/.cache/IMG_0004.jpg":   {Data: []byte("photo")},
+		"trips/IMG_0005.jpg":          {Data: []byte("photo")},
+		".gphotosignore":              {Data: []byte("")},
+		"trips/.sync/deep/IMG_06.jpg": {Data: []byte("photo")},
+	}
+
+	testCases := []struct {
+		name          string
+		includeHidden bool
+		workers       int
+		want          []string
+		wantStats     upload.WalkStats
+	}{
+		{"Should skip hidden files and directories", false, 1, []string{"IMG_0001.jpg", "trips/IMG_0005.jpg"}, upload.WalkStats{Found: 2, SkippedFiltered: 1}},
+		{"Should skip hidden files and directories in parallel", false, 4, []string{"IMG_0001.jpg", "trips/IMG_0005.jpg"}, upload.WalkStats{Found: 2, SkippedFiltered: 1}},
+		{"Should include hidden files and directories", true, 1, []string{".IMG_0002.jpg", ".thumbnails/IMG_0003.jpg", "IMG_0001.jpg", "trips/.cache/IMG_0004.jpg", "trips/.sync/deep/IMG_06.jpg", "trips/IMG_0005.jpg"}, upload.WalkStats{Found: 6}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			u := upload.UploadFolderJob{
+				FileTracker:   &mock.FileTracker{ExistFn: func(path string) bool { return false }},
+				SourceFolder:  "/photos",
+				FS:            fsys,
+				CreateAlbums:  "Off",
+				Filter:        filter.MustCompile([]string{"_ALL_FILES_"}, nil),
+				IncludeHidden: tc.includeHidden,
+				ScanWorkers:   tc.workers,
+			}
+
+			var got []string
+			stats, err := u.WalkFolder(&mock.Logger{}, func(item upload.FileItem) {
+				got = append(got, filepath.ToSlash(upload.RelativePath("/photos", item.Path)))
+			})
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(tc.want, got) {
+				t.Errorf("want: %v, got: %v", tc.want, got)
+			}
+			if stats != tc.wantStats {
+				t.Errorf("want: %+v, got: %+v", tc.wantStats, stats)
+			}
+
+			// files in hidden directories found by other means, e.g. a watcher, are skipped too.
+			var visited int
+			if _, err := u.VisitFile(&mock.Logger{}, filepath.Join("/photos", "trips", ".cache", "IMG_0004.jpg"), func(item upload.FileItem) {
+				visited++
+			}); err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			if want := map[bool]int{false: 0, true: 1}[tc.includeHidden]; visited != want {
+				t.Errorf("want: %d, got: %d", want, visited)
+			}
+		})
+	}
+}
+
+// openedFS is a file system recording the names of the opened files and directories.
+type openedFS struct {
+	fs.FS
+
+	mu     sync.Mutex
+	opened []string
+}
+
+func (f *openedFS) Open(name string) (fs.File, error) {
+	f.mu.Lock()
+	f.opened = append(f.opened, name)
+	f.mu.Unlock()
+	return f.FS.Open(name)
+}
+
+func TestUploadFolderJob_WalkFolderDepth(t *testing.T) {
+	fsys := fstest.MapFS{
+		"IMG_0001.jpg":                       {Data: []byte("photo")},
+		"trips/IMG_0002.jpg":                 {Data: []byte("photo")},
+		"trips/italy/IMG_0003.jpg":           {Data: []byte("photo")},
+		"trips/italy/cache/IMG_0004.jpg":     {Data: []byte("photo")},
+		"trips/italy/cache/tmp/IMG_0005.jpg": {Data: []byte("photo")},
+	}
+	maxDepth := func(d int) *int { return &d }
+
+	testCases := []struct {
+		name       string
+		minDepth   int
+		maxDepth   *int
+		workers    int
+		want       []string
+		wantPruned []string
+	}{
+		{"Should select all the files if depth is unbounded", 0, nil, 1, []string{"IMG_0001.jpg", "trips/IMG_0002.jpg", "trips/italy/IMG_0003.jpg", "trips/italy/cache/IMG_0004.jpg", "trips/italy/cache/tmp/IMG_0005.jpg"}, nil},
+		{"Should select the files of the root only", 0, maxDepth(0), 1, []string{"IMG_0001.jpg"}, []string{"trips"}},
+		{"Should select the files of the top two levels", 0, maxDepth(1), 1, []string{"IMG_0001.jpg", "trips/IMG_0002.jpg"}, []string{"trips/italy"}},
+		{"Should select the files of the top two levels in parallel", 0, maxDepth(1), 4, []string{"IMG_0001.jpg", "trips/IMG_0002.jpg"}, []string{"trips/italy"}},
+		{"Should skip the files shallower than MinDepth", 2, nil, 1, []string{"trips/italy/IMG_0003.jpg", "trips/italy/cache/IMG_0004.jpg", "trips/italy/cache/tmp/IMG_0005.jpg"}, nil},
+		{"Should select the files between MinDepth and MaxDepth", 1, maxDepth(2), 1, []string{"trips/IMG_0002.jpg", "trips/italy/IMG_0003.jpg"}, []string{"trips/italy/cache"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := filter.CompileWithOptions([]string{"_IMAGE_EXTENSIONS_"}, nil, filter.FilterOptions{MinDepth: tc.minDepth, MaxDepth: tc.maxDepth})
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			opened := &openedFS{FS: fsys}
+			u := upload.UploadFolderJob{
+				FileTracker:  &mock.FileTracker{ExistFn: func(path string) bool { return false }},
+				SourceFolder: "/photos",
+				FS:           opened,
+				CreateAlbums: "Off",
+				Filter:       f,
+				ScanWorkers:  tc.workers,
+			}
+
+			var got []string
+			if _, err := u.WalkFolder(&mock.Logger{}, func(item upload.FileItem) {
+				got = append(got, filepath.ToSlash(upload.RelativePath("/photos", item.Path)))
+			}); err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(tc.want, got) {
+				t.Errorf("want: %v, got: %v", tc.want, got)
+			}
+
+			// directories deeper than MaxDepth are not scanned at all.
+			for _, dir := range tc.wantPruned {
+				for _, name := range opened.opened {
+					if name == dir || strings.HasPrefix(name, dir+"/") {
+						t.Errorf("want: '%s' not scanned, got: '%s' opened", dir, name)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestUploadFolderJob_IsHidden(t *testing.T) {
+	u := upload.UploadFolderJob{
+		SourceFolder: "/photos/.library",
+		FS: fstest.MapFS{
+			"IMG_0001.jpg":        {Data: []byte("photo")},
+			".cache/IMG_0002.jpg": {Data: []byte("photo")},
+		},
+	}
+
+	testCases := []struct {
+		path string
+		want bool
+	}{
+		{filepath.Join("/photos", ".library"), false},
+		{filepath.Join("/photos", ".library", "IMG_0001.jpg"), false},
+		{filepath.Join("/photos", ".library", ".cache"), true},
+		{filepath.Join("/photos", ".library", ".cache", "IMG_0002.jpg"), true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			if got := u.IsHidden(tc.path); got != tc.want {
+				t.Errorf("want: %t, got: %t", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestUploadFolderJob_WalkFolderNearDuplicates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "walker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// a photo, a recompressed copy of it, and another photo.
+	photo := image.NewRGBA(image.Rect(0, 0, 320, 240))
+	other := image.NewRGBA(image.Rect(0, 0, 320, 240))
+	for y := 0; y < 240; y++ {
+		for x := 0; x < 320; x++ {
+			v := uint8((x*255/320 + y*255/240) / 2)
+			if (x/80+y/60)%2 == 0 {
+				v = 255 - v/2
+			}
+			photo.Set(x, y, color.RGBA{R: v, G: v / 2, B: 255 - v, A: 255})
+			other.Set(x, y, color.RGBA{R: 255 - v, G: 128 - v/2, B: v, A: 255})
+		}
+	}
+	images := map[string]func(w io.Writer) error{
+		"original.png":     func(w io.Writer) error { return png.Encode(w, photo) },
+		"recompressed.jpg": func(w io.Writer) error { return jpeg.Encode(w, photo, &jpeg.Options{Quality: 30}) },
+		"other.jpg":        func(w io.Writer) error { return jpeg.Encode(w, other, &jpeg.Options{Quality: 90}) },
+	}
+	for name, encode := range images {
+		var b bytes.Buffer
+		if err := encode(&b); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), b.Bytes(), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	repo, err := filetracker.NewLevelDBRepository(filepath.Join(t.TempDir(), "uploads.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker := filetracker.New(repo)
+	defer tracker.Close()
+	tracker.PerceptualHasher = imagehash.DHash{}
+	if err := tracker.Put(filepath.Join(dir, "original.png"), ""); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	testCases := []struct {
+		name      string
+		threshold int
+		want      []string
+		wantStats upload.WalkStats
+	}{
+		{"Should skip near duplicate within the threshold", 5, []string{"other.jpg"}, upload.WalkStats{Found: 1, SkippedTracked: 2}},
+		{"Should upload near duplicate beyond the threshold", 0, []string{"other.jpg", "recompressed.jpg"}, upload.WalkStats{Found: 2, SkippedTracked: 1}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tracker.NearDuplicateThreshold = tc.threshold
+			var skipped []string
+			u := upload.UploadFolderJob{
+				FileTracker:  tracker,
+				SourceFolder: dir,
+				CreateAlbums: "Off",
+				Filter:       filter.MustCompile([]string{"**/*.jpg", "**/*.png"}, nil),
+				OnSkipped: func(path string, reason string) {
+					if reason == log.ReasonNearDuplicate {
+						skipped = append(skipped, filepath.Base(path))
+					}
+				},
+			}
+			var found []string
+			stats, err := u.WalkFolder(&mock.Logger{}, func(item upload.FileItem) {
+				found = append(found, filepath.Base(item.Path))
+			})
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			sort.Strings(found)
+			if !reflect.DeepEqual(tc.want, found) {
+				t.Errorf("want: %v, got: %v", tc.want, found)
+			}
+			if tc.wantStats != stats {
+				t.Errorf("want: %+v, got: %+v", tc.wantStats, stats)
+			}
+			if len(tc.want) == 1 && !reflect.DeepEqual([]string{"recompressed.jpg"}, skipped) {
+				t.Errorf("want: %v, got: %v", []string{"recompressed.jpg"}, skipped)
+			}
+		})
+	}
+}
+
+func TestUploadFolderJob_WalkFolderMinDimensions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "walker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the thumbnail has the extension of the photos, and the rotated photo is stored in portrait.
+	files := map[string]func(w io.Writer) error{
+		"IMG_0001.jpg":       func(w io.Writer) error { return jpeg.Encode(w, image.NewGray(image.Rect(0, 0, 1200, 900)), nil) },
+		"IMG_0001_thumb.jpg": func(w io.Writer) error { return jpeg.Encode(w, image.NewGray(image.Rect(0, 0, 160, 120)), nil) },
+		"IMG_0002.jpg":       func(w io.Writer) error { return jpeg.Encode(w, image.NewGray(image.Rect(0, 0, 900, 1200)), nil) },
+		"screenshot.png":     func(w io.Writer) error { return png.Encode(w, image.NewGray(image.Rect(0, 0, 1200, 100))) },
+		"video.mp4":          func(w io.Writer) error { _, err := w.Write([]byte("not an image")); return err },
+	}
+	for name, encode := range files {
+		var b bytes.Buffer
+		if err := encode(&b); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), b.Bytes(), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reader := &countingMetadataReader{
+		metadata: map[string]exif.Metadata{"IMG_0002.jpg": {Orientation: 6}},
+		reads:    make(map[string]int),
+	}
+
+	testCases := []struct {
+		name      string
+		minWidth  int
+		minHeight int
+		want      []string
+	}{
+		{"Should skip the images smaller than both dimensions", 800, 600, []string{"IMG_0001.jpg", "IMG_0002.jpg", "video.mp4"}},
+		{"Should skip the images narrower than the width", 800, 0, []string{"IMG_0001.jpg", "IMG_0002.jpg", "screenshot.png", "video.mp4"}},
+		{"Should skip the images narrower than the width, as displayed", 1000, 0, []string{"IMG_0001.jpg", "IMG_0002.jpg", "screenshot.png", "video.mp4"}},
+		{"Should skip the images shorter than the height, as displayed", 0, 1000, []string{"video.mp4"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var skipped []string
+			u := upload.UploadFolderJob{
+				FileTracker:    &mock.FileTracker{ExistFn: func(path string) bool { return false }},
+				SourceFolder:   dir,
+				CreateAlbums:   "Off",
+				Filter:         filter.MustCompile([]string{"_ALL_FILES_"}, nil),
+				MetadataReader: reader,
+				MinWidth:       tc.minWidth,
+				MinHeight:      tc.minHeight,
+				OnSkipped: func(path string, reason string) {
+					if reason == log.ReasonTooSmall {
+						skipped = append(skipped, filepath.Base(path))
+					}
+				},
+			}
+
+			var found []string
+			stats, err := u.WalkFolder(&mock.Logger{}, func(item upload.FileItem) {
+				found = append(found, filepath.Base(item.Path))
+			})
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			sort.Strings(found)
+			if strings.Join(tc.want, ",") != strings.Join(found, ",") {
+				t.Errorf("want: %v, got: %v", tc.want, found)
+			}
+			if want := len(files) - len(tc.want); stats.SkippedFiltered != want || len(skipped) != want {
+				t.Errorf("want: %d skipped, got: %d, %v", want, stats.SkippedFiltered, skipped)
+			}
+		})
+	}
+}
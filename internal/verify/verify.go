@@ -0,0 +1,123 @@
+// Package verify reconciles the tracked files with the media items in Google Photos.
+package verify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/gphotosuploader/google-photos-api-client-go/v2/media_items"
+	"google.golang.org/api/googleapi"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/datastore/filetracker"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/ratelimit"
+)
+
+// MediaItemsService represents the Google Photos media items service.
+type MediaItemsService interface {
+	Get(ctx context.Context, mediaItemID string) (*media_items.MediaItem, error)
+}
+
+// FileTracker represents the service tracking the uploaded files.
+type FileTracker interface {
+	Iterate(fn func(file string, item filetracker.TrackedFile) error) error
+	Delete(file string) error
+}
+
+// Report is the result of a verification.
+type Report struct {
+	// Checked is the number of tracked files verified against Google Photos.
+	Checked int
+	// Orphaned are the tracked files that don't exist in the local file system anymore.
+	Orphaned []string
+	// Missing are the tracked files whose media item doesn't exist in Google Photos.
+	Missing []string
+	// Unverifiable are the tracked files without media item, tracked by previous versions.
+	Unverifiable []string
+	// Pruned are the Missing files that are not tracked anymore, so they will be uploaded again.
+	Pruned []string
+}
+
+// Verifier checks that the media items of the tracked files still exist in Google Photos.
+type Verifier struct {
+	MediaItems  MediaItemsService
+	FileTracker FileTracker
+	Logger      log.Logger
+
+	// Limiter limits the requests to Google Photos, to respect the API quotas. Nil means unlimited.
+	Limiter *ratelimit.Limiter
+
+	// Include, if it's set, selects the tracked files to be verified.
+	Include func(file string) bool
+
+	// Fix removes the tracking of Missing files, so they will be uploaded again.
+	Fix bool
+}
+
+// Run verifies the tracked files, returning the report of the verification.
+// Files are only pruned if all the tracked files have been verified.
+func (v Verifier) Run(ctx context.Context) (Report, error) {
+	var report Report
+	err := v.FileTracker.Iterate(func(file string, item filetracker.TrackedFile) error {
+		if v.Include != nil && !v.Include(file) {
+			return nil
+		}
+
+		if _, err := os.Stat(file); os.IsNotExist(err) {
+			v.Logger.Debugf("Tracked file '%s' does not exist locally", file)
+			report.Orphaned = append(report.Orphaned, file)
+		}
+
+		if item.MediaItemID == "" {
+			report.Unverifiable = append(report.Unverifiable, file)
+			return nil
+		}
+
+		if err := v.Limiter.WaitContext(ctx, 1); err != nil {
+			return err
+		}
+		_, err := v.MediaItems.Get(ctx, item.MediaItemID)
+		switch {
+		case isNotFound(err):
+			v.Logger.Debugf("Media item of tracked file '%s' does not exist in Google Photos: %s", file, err)
+			report.Missing = append(report.Missing, file)
+		case err != nil:
+			return fmt.Errorf("unable to verify file '%s': %w", file, err)
+		}
+		report.Checked++
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	if !v.Fix {
+		return report, nil
+	}
+	for _, file := range report.Missing {
+		if err := v.FileTracker.Delete(file); err != nil {
+			return report, fmt.Errorf("unable to prune file '%s': %w", file, err)
+		}
+		report.Pruned = append(report.Pruned, file)
+	}
+	return report, nil
+}
+
+// isNotFound returns true if the error means that the media item doesn't exist.
+// Google Photos responds with 400 Bad Request to media item IDs that are not in the library.
+func isNotFound(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, media_items.ErrMediaItemNotFound) {
+		return true
+	}
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code == http.StatusNotFound || apiErr.Code == http.StatusBadRequest
+	}
+	return false
+}
@@ -0,0 +1,224 @@
+package verify_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/gphotosuploader/google-photos-api-client-go/v2/media_items"
+	"google.golang.org/api/googleapi"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/datastore/filetracker"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/mock"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/ratelimit"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/verify"
+)
+
+func TestVerifier_Run(t *testing.T) {
+	dir, err := ioutil.TempDir("", "verify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	present := filepath.Join(dir, "present.jpg")
+	missing := filepath.Join(dir, "missing.jpg")
+	legacy := filepath.Join(dir, "legacy.jpg")
+	orphaned := filepath.Join(dir, "orphaned.jpg")
+	for _, f := range []string{present, missing, legacy} {
+		if err := ioutil.WriteFile(f, []byte(f), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testCases := []struct {
+		name   string
+		fix    bool
+		pruned []string
+	}{
+		{name: "Should report without pruning", fix: false, pruned: nil},
+		{name: "Should prune missing media items", fix: true, pruned: []string{missing}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tracker := newMemoryTracker(map[string]string{
+				present:  "id-present",
+				missing:  "id-missing",
+				legacy:   "",
+				orphaned: "id-orphaned",
+			})
+			v := verify.Verifier{
+				MediaItems:  mediaItems("id-present", "id-orphaned"),
+				FileTracker: tracker,
+				Logger:      log.Discard,
+				Fix:         tc.fix,
+			}
+
+			got, err := v.Run(context.Background())
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+
+			want := verify.Report{
+				Checked:      3,
+				Orphaned:     []string{orphaned},
+				Missing:      []string{missing},
+				Unverifiable: []string{legacy},
+				Pruned:       tc.pruned,
+			}
+			if !reflect.DeepEqual(want, got) {
+				t.Errorf("want: %+v, got: %+v", want, got)
+			}
+
+			_, tracked := tracker.items[missing]
+			if tracked == tc.fix {
+				t.Errorf("want: tracked %t, got: %t", !tc.fix, tracked)
+			}
+			if len(tracker.items) != 4-len(tc.pruned) {
+				t.Errorf("want: %d, got: %d", 4-len(tc.pruned), len(tracker.items))
+			}
+		})
+	}
+}
+
+func TestVerifier_RunFailsOnUnexpectedErrors(t *testing.T) {
+	tracker := newMemoryTracker(map[string]string{
+		"a.jpg": "id-a",
+		"b.jpg": "id-b",
+	})
+	v := verify.Verifier{
+		MediaItems: &mock.MediaItemsService{
+			GetFn: func(ctx context.Context, mediaItemID string) (*media_items.MediaItem, error) {
+				if mediaItemID == "id-a" {
+					return nil, &googleapi.Error{Code: http.StatusNotFound}
+				}
+				return nil, &googleapi.Error{Code: http.StatusServiceUnavailable}
+			},
+		},
+		FileTracker: tracker,
+		Logger:      log.Discard,
+		Fix:         true,
+	}
+
+	_, err := v.Run(context.Background())
+	if err == nil {
+		t.Fatal("error was expected at this point")
+	}
+	if len(tracker.items) != 2 {
+		t.Errorf("want: %d, got: %d", 2, len(tracker.items))
+	}
+}
+
+func TestVerifier_RunCancelledWhileLimited(t *testing.T) {
+	tracker := newMemoryTracker(map[string]string{
+		"a.jpg": "id-a",
+		"b.jpg": "id-b",
+	})
+	v := verify.Verifier{
+		MediaItems:  mediaItems("id-a", "id-b"),
+		FileTracker: tracker,
+		Logger:      log.Discard,
+		// the limiter starts empty, so the first request waits for a second.
+		Limiter: ratelimit.NewLimiter(1),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := v.Run(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("want: %v, got: %v", context.DeadlineExceeded, err)
+	}
+	if got := time.Since(start); got > 500*time.Millisecond {
+		t.Errorf("want: no wait once the context is done, got: %s", got)
+	}
+}
+
+func TestVerifier_RunWithInclude(t *testing.T) {
+	var requested []string
+	v := verify.Verifier{
+		MediaItems: &mock.MediaItemsService{
+			GetFn: func(ctx context.Context, mediaItemID string) (*media_items.MediaItem, error) {
+				requested = append(requested, mediaItemID)
+				return &media_items.MediaItem{ID: mediaItemID}, nil
+			},
+		},
+		FileTracker: newMemoryTracker(map[string]string{
+			"/photos/a.jpg": "id-a",
+			"/videos/b.mp4": "id-b",
+		}),
+		Logger: log.Discard,
+		Include: func(file string) bool {
+			return filepath.Dir(file) == "/photos"
+		},
+	}
+
+	if _, err := v.Run(context.Background()); err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if !reflect.DeepEqual([]string{"id-a"}, requested) {
+		t.Errorf("want: %v, got: %v", []string{"id-a"}, requested)
+	}
+}
+
+// mediaItems returns a media items service where only the given media items exist.
+func mediaItems(ids ...string) *mock.MediaItemsService {
+	exist := make(map[string]bool)
+	for _, id := range ids {
+		exist[id] = true
+	}
+	return &mock.MediaItemsService{
+		GetFn: func(ctx context.Context, mediaItemID string) (*media_items.MediaItem, error) {
+			if !exist[mediaItemID] {
+				return nil, &googleapi.Error{Code: http.StatusBadRequest, Message: "Invalid media item ID."}
+			}
+			return &media_items.MediaItem{ID: mediaItemID}, nil
+		},
+	}
+}
+
+// memoryTracker implements a FileTracker in memory.
+type memoryTracker struct {
+	items map[string]filetracker.TrackedFile
+}
+
+func newMemoryTracker(mediaItemIDs map[string]string) *memoryTracker {
+	m := &memoryTracker{items: make(map[string]filetracker.TrackedFile)}
+	for file, id := range mediaItemIDs {
+		item := filetracker.NewTrackedFile("hash")
+		item.MediaItemID = id
+		m.items[file] = item
+	}
+	return m
+}
+
+func (m *memoryTracker) Iterate(fn func(file string, item filetracker.TrackedFile) error) error {
+	files := make([]string, 0, len(m.items))
+	for f := range m.items {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		if err := fn(f, m.items[f]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *memoryTracker) Delete(file string) error {
+	if _, exist := m.items[file]; !exist {
+		return errors.New("file is not tracked")
+	}
+	delete(m.items, file)
+	return nil
+}
@@ -0,0 +1,394 @@
+// Package watcher reports new or modified files in a folder while it's running.
+//
+// Changes are notified by the operating system, watching every folder, including the ones created later.
+// Folders could be polled instead, e.g. on network shares where file system notifications are not available.
+// They are polled too if the notifications could not be set up, e.g. when the limit of watched folders is reached.
+// A folder linked from several paths is notified once, by the last path watched, while polling reports its files
+// by every path.
+package watcher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// DefaultInterval is the default time between checks.
+const DefaultInterval = 5 * time.Second
+
+// Watcher watches a folder, and all its subfolders, for new or modified files.
+// Changes are debounced: a file is reported once its size and modification time
+// have not changed between two checks, so files being copied are reported once the copy has finished.
+type Watcher struct {
+	// Interval is the time between checks of the changed files, or between polls if the folders are polled.
+	// Uses DefaultInterval by default.
+	Interval time.Duration
+	// Poll, if it's set, scans all the folders every Interval instead of being notified of their changes.
+	// It works on any file system, including network shares where file system notifications are not available.
+	Poll bool
+
+	// MinAge, if it's set, delays reporting a file until it hasn't been modified for that long.
+	MinAge time.Duration
+	// SizeCheckDelay, if it's set, checks again the size of a stable file after that delay, before reporting it.
+	// Files whose size has changed are reported once they are stable again.
+	SizeCheckDelay time.Duration
+	// SkipDir, if it's set, returns true for the directories that should not be watched.
+	SkipDir func(path string) bool
+	// Walk, if it's set, walks a folder calling walkFn for each file or directory, e.g. through the file
+	// system of a job, following its symbolic links. Uses filepath.Walk, not following them, by default.
+	Walk func(root string, walkFn filepath.WalkFunc) error
+	// Stat, if it's set, returns the FileInfo of a file like it's walked by Walk. Uses os.Lstat by default.
+	Stat func(path string) (os.FileInfo, error)
+
+	root string
+
+	// known are the files already reported, or existing when the watcher was started.
+	known map[string]fileState
+	// pending are the changed files waiting to be stable.
+	pending map[string]fileState
+}
+
+// fileState is the state of a file when it was checked. The files notified as changed are pending with
+// an empty state until they are checked.
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+func (s fileState) equal(other fileState) bool {
+	return s.size == other.size && s.modTime.Equal(other.modTime)
+}
+
+// New returns a Watcher for the root folder.
+func New(root string) *Watcher {
+	return &Watcher{
+		Interval: DefaultInterval,
+		root:     root,
+	}
+}
+
+// Snapshot keeps the state of the files existing now, so the files created or modified since, e.g. while the
+// existing ones are uploaded, are reported once Run is called.
+func (w *Watcher) Snapshot() error {
+	files, _, err := w.scan(w.root)
+	if err != nil {
+		return err
+	}
+	w.known = files
+	return nil
+}
+
+// Run calls fn with the path of every new or modified file until ctx is done.
+// Files existing when Run is called, or when Snapshot was called if it was, are not reported.
+func (w *Watcher) Run(ctx context.Context, fn func(path string)) error {
+	files, dirs, err := w.scan(w.root)
+	if err != nil {
+		return err
+	}
+	w.pending = make(map[string]fileState)
+	if w.known == nil {
+		w.known = files
+	} else {
+		// files changed since the snapshot are checked like the notified ones.
+		w.diff(files)
+	}
+
+	if !w.Poll {
+		notifier, err := watchFolders(dirs)
+		if err == nil {
+			defer notifier.Close()
+			return w.runNotified(ctx, notifier, fn)
+		}
+		// notifications are not available, e.g. the limit of watched folders has been reached.
+	}
+	return w.runPolled(ctx, fn)
+}
+
+// runPolled scans all the folders every Interval, calling fn with the changed files once they are stable.
+func (w *Watcher) runPolled(ctx context.Context, fn func(path string)) error {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			files, _, err := w.scan(w.root)
+			if err != nil {
+				// the root folder could be temporarily unavailable, e.g. an unmounted drive.
+				continue
+			}
+			for _, path := range w.checkSize(ctx, w.changes(files)) {
+				if ctx.Err() != nil {
+					return nil
+				}
+				fn(path)
+			}
+		}
+	}
+}
+
+// runNotified keeps the files notified as changed, checking them every Interval, and calls fn with the
+// ones that are stable.
+func (w *Watcher) runNotified(ctx context.Context, notifier *fsnotify.Watcher, fn func(path string)) error {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-notifier.Events:
+			if !ok {
+				return nil
+			}
+			if event.Name == w.root && event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+				// the root folder could be temporarily unavailable, e.g. an unmounted drive, so it's polled
+				// until it's back.
+				return w.runPolled(ctx, fn)
+			}
+			w.handle(notifier, event)
+		case _, ok := <-notifier.Errors:
+			if !ok {
+				return nil
+			}
+			// events could have been lost, e.g. because the queue of events has overflowed.
+			w.rescan(notifier)
+		case <-ticker.C:
+			for _, path := range w.checkSize(ctx, w.settle()) {
+				if ctx.Err() != nil {
+					return nil
+				}
+				fn(path)
+			}
+		}
+	}
+}
+
+// watchFolders returns a notifier of the changes in the folders.
+func watchFolders(dirs []string) (*fsnotify.Watcher, error) {
+	notifier, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range dirs {
+		if err := notifier.Add(dir); err != nil {
+			_ = notifier.Close()
+			return nil, err
+		}
+	}
+	return notifier, nil
+}
+
+// handle updates the watcher state with the notified event.
+func (w *Watcher) handle(notifier *fsnotify.Watcher, event fsnotify.Event) {
+	if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+		// the folders moved away are not watched anymore, removed ones are not watched already.
+		_ = notifier.Remove(event.Name)
+		w.forget(event.Name)
+		return
+	}
+	fi, err := w.stat(event.Name)
+	if err != nil {
+		// removed since, it's notified too.
+		return
+	}
+	if fi.IsDir() && event.Op&fsnotify.Create != 0 {
+		// folders created, or moved in, are watched too. Their files could have been created before.
+		files, dirs, err := w.scan(event.Name)
+		if err != nil {
+			return
+		}
+		for _, dir := range dirs {
+			_ = notifier.Add(dir)
+		}
+		for path := range files {
+			w.changed(path)
+		}
+		return
+	}
+	if fi.Mode().IsRegular() {
+		w.changed(event.Name)
+	}
+}
+
+// rescan checks all the files again, watching the folders not watched yet.
+func (w *Watcher) rescan(notifier *fsnotify.Watcher) {
+	files, dirs, err := w.scan(w.root)
+	if err != nil {
+		return
+	}
+	for _, dir := range dirs {
+		_ = notifier.Add(dir)
+	}
+	w.diff(files)
+}
+
+// diff sets as pending the files that are not known, or have changed, forgetting the known files removed since.
+func (w *Watcher) diff(files map[string]fileState) {
+	for path := range w.known {
+		if _, ok := files[path]; !ok {
+			delete(w.known, path)
+		}
+	}
+	for path, state := range files {
+		if known, ok := w.known[path]; !ok || !known.equal(state) {
+			w.changed(path)
+		}
+	}
+}
+
+// changed sets the file as pending, if it's not already, to be checked.
+func (w *Watcher) changed(path string) {
+	if _, ok := w.pending[path]; !ok {
+		w.pending[path] = fileState{}
+	}
+}
+
+// forget removes the file, or all the files of the folder, from the watcher state, so they are reported if
+// they are created again.
+func (w *Watcher) forget(path string) {
+	prefix := path + string(filepath.Separator)
+	for p := range w.known {
+		if p == path || strings.HasPrefix(p, prefix) {
+			delete(w.known, p)
+		}
+	}
+	for p := range w.pending {
+		if p == path || strings.HasPrefix(p, prefix) {
+			delete(w.pending, p)
+		}
+	}
+}
+
+// settle returns the pending files that have been stable since the previous check, updating the watcher state.
+func (w *Watcher) settle() []string {
+	var stable []string
+	for path := range w.pending {
+		fi, err := w.stat(path)
+		if err != nil || !fi.Mode().IsRegular() {
+			// removed, or replaced, since it changed.
+			delete(w.pending, path)
+			continue
+		}
+		if w.change(path, fileState{size: fi.Size(), modTime: fi.ModTime()}) {
+			stable = append(stable, path)
+		}
+	}
+	return stable
+}
+
+// changes returns the files that have been stable since the previous poll, updating the watcher state.
+func (w *Watcher) changes(files map[string]fileState) []string {
+	var stable []string
+	for path, state := range files {
+		if w.change(path, state) {
+			stable = append(stable, path)
+		}
+	}
+
+	// removed files are forgotten, so they are reported if they are created again.
+	for path := range w.known {
+		if _, ok := files[path]; !ok {
+			delete(w.known, path)
+		}
+	}
+	for path := range w.pending {
+		if _, ok := files[path]; !ok {
+			delete(w.pending, path)
+		}
+	}
+	return stable
+}
+
+// change updates the state of the file, returning true if it has been stable since the previous check.
+func (w *Watcher) change(path string, state fileState) bool {
+	if known, ok := w.known[path]; ok && known.equal(state) {
+		delete(w.pending, path)
+		return false
+	}
+	if pending, ok := w.pending[path]; ok && pending.equal(state) {
+		if w.MinAge > 0 && time.Since(state.modTime) < w.MinAge {
+			// it's kept pending until it's old enough.
+			return false
+		}
+		delete(w.pending, path)
+		w.known[path] = state
+		return true
+	}
+	w.pending[path] = state
+	return false
+}
+
+// checkSize returns the files whose size has not changed after SizeCheckDelay.
+// Files whose size has changed are pending again.
+func (w *Watcher) checkSize(ctx context.Context, paths []string) []string {
+	if w.SizeCheckDelay <= 0 || len(paths) == 0 {
+		return paths
+	}
+	select {
+	case <-ctx.Done():
+		return nil
+	case <-time.After(w.SizeCheckDelay):
+	}
+	var stable []string
+	for _, path := range paths {
+		fi, err := w.stat(path)
+		if err != nil {
+			// removed while waiting, it's forgotten on the next poll.
+			delete(w.known, path)
+			continue
+		}
+		if fi.Size() != w.known[path].size {
+			delete(w.known, path)
+			w.pending[path] = fileState{size: fi.Size(), modTime: fi.ModTime()}
+			continue
+		}
+		stable = append(stable, path)
+	}
+	return stable
+}
+
+// scan returns the state of all the files in the folder, and the folders to be watched.
+func (w *Watcher) scan(root string) (map[string]fileState, []string, error) {
+	files := make(map[string]fileState)
+	var dirs []string
+	err := w.walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || fi == nil {
+			// files could be removed while scanning.
+			return nil
+		}
+		if fi.IsDir() {
+			if path != w.root && w.SkipDir != nil && w.SkipDir(path) {
+				return filepath.SkipDir
+			}
+			dirs = append(dirs, path)
+			return nil
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		files[path] = fileState{size: fi.Size(), modTime: fi.ModTime()}
+		return nil
+	})
+	return files, dirs, err
+}
+
+// walk walks the folder with Walk, or filepath.Walk if it's not set.
+func (w *Watcher) walk(root string, walkFn filepath.WalkFunc) error {
+	if w.Walk == nil {
+		return filepath.Walk(root, walkFn)
+	}
+	return w.Walk(root, walkFn)
+}
+
+// stat returns the FileInfo of the file with Stat, or os.Lstat if it's not set.
+func (w *Watcher) stat(path string) (os.FileInfo, error) {
+	if w.Stat == nil {
+		return os.Lstat(path)
+	}
+	return w.Stat(path)
+}
@@ -0,0 +1,351 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/upload"
+)
+
+func TestWatcher_Run(t *testing.T) {
+	testCases := []struct {
+		name string
+		poll bool
+	}{
+		{"Should report the files notified as changed", false},
+		{"Should report the files changed between polls", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "watcher")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			writeFile(t, filepath.Join(dir, "existing.jpg"), "existing")
+			if err := os.Mkdir(filepath.Join(dir, "excluded"), 0700); err != nil {
+				t.Fatal(err)
+			}
+
+			w := New(dir)
+			w.Interval = 10 * time.Millisecond
+			w.Poll = tc.poll
+			w.SkipDir = func(path string) bool {
+				return filepath.Base(path) == "excluded"
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			reported := make(chan string, 10)
+			done := make(chan error)
+			go func() {
+				done <- w.Run(ctx, func(path string) {
+					reported <- path
+				})
+			}()
+
+			// gives time to the watcher to take the initial snapshot.
+			time.Sleep(50 * time.Millisecond)
+
+			writeFile(t, filepath.Join(dir, "excluded", "skipped.jpg"), "skipped")
+			newFile := filepath.Join(dir, "new.jpg")
+			writeFile(t, newFile, "new")
+			if got := waitForFile(t, reported); got != newFile {
+				t.Errorf("want: %s, got: %s", newFile, got)
+			}
+
+			// files in directories created after the watcher has started are reported too, including the
+			// ones created with the directory, before it's watched.
+			if err := os.MkdirAll(filepath.Join(dir, "subfolder", "nested"), 0700); err != nil {
+				t.Fatal(err)
+			}
+			subFile := filepath.Join(dir, "subfolder", "nested", "new.jpg")
+			writeFile(t, subFile, "new")
+			if got := waitForFile(t, reported); got != subFile {
+				t.Errorf("want: %s, got: %s", subFile, got)
+			}
+			time.Sleep(50 * time.Millisecond)
+			laterFile := filepath.Join(dir, "subfolder", "nested", "later.jpg")
+			writeFile(t, laterFile, "later")
+			if got := waitForFile(t, reported); got != laterFile {
+				t.Errorf("want: %s, got: %s", laterFile, got)
+			}
+
+			// removed files are reported again once they are created again.
+			if err := os.Remove(newFile); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(50 * time.Millisecond)
+			writeFile(t, newFile, "new again")
+			if got := waitForFile(t, reported); got != newFile {
+				t.Errorf("want: %s, got: %s", newFile, got)
+			}
+
+			cancel()
+			if err := <-done; err != nil {
+				t.Errorf("error was not expected: %s", err)
+			}
+			close(reported)
+			for path := range reported {
+				t.Errorf("file was not expected to be reported: %s", path)
+			}
+		})
+	}
+}
+
+func TestWatcher_RunSnapshot(t *testing.T) {
+	testCases := []struct {
+		name string
+		poll bool
+	}{
+		{"Should report the files changed since the snapshot when notified", false},
+		{"Should report the files changed since the snapshot when polling", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "watcher")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			writeFile(t, filepath.Join(dir, "existing.jpg"), "existing")
+			modified := filepath.Join(dir, "modified.jpg")
+			writeFile(t, modified, "modified")
+
+			w := New(dir)
+			w.Interval = 10 * time.Millisecond
+			w.Poll = tc.poll
+			if err := w.Snapshot(); err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+
+			// files are created, or modified, before Run is called, e.g. while the existing ones are uploaded.
+			created := filepath.Join(dir, "created.jpg")
+			writeFile(t, created, "created")
+			writeFile(t, modified, "modified again")
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			reported := make(chan string, 10)
+			done := make(chan error)
+			go func() {
+				done <- w.Run(ctx, func(path string) {
+					reported <- path
+				})
+			}()
+
+			got := []string{waitForFile(t, reported), waitForFile(t, reported)}
+			sort.Strings(got)
+			if want := []string{created, modified}; fmt.Sprint(want) != fmt.Sprint(got) {
+				t.Errorf("want: %v, got: %v", want, got)
+			}
+
+			// gives time to report the files that were not expected.
+			time.Sleep(50 * time.Millisecond)
+			cancel()
+			if err := <-done; err != nil {
+				t.Errorf("error was not expected: %s", err)
+			}
+			close(reported)
+			for path := range reported {
+				t.Errorf("file was not expected to be reported: %s", path)
+			}
+		})
+	}
+}
+
+func TestWatcher_RunSymlinkLoop(t *testing.T) {
+	// a folder linked from several paths is notified once, by the last path watched.
+	testCases := []struct {
+		name           string
+		poll           bool
+		followSymlinks bool
+		want           []string
+	}{
+		{"Should not report files in symlinked folders by default", false, false, []string{"sub/new.jpg"}},
+		{"Should not report files in symlinked folders by default when polling", true, false, []string{"sub/new.jpg"}},
+		{"Should report files in symlinked folders, without walking into loops", false, true, []string{"sub/new.jpg"}},
+		{"Should report files in symlinked folders, without walking into loops when polling", true, true, []string{"link/new.jpg", "sub/new.jpg"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "watcher")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			if err := os.Mkdir(filepath.Join(dir, "sub"), 0700); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Symlink("..", filepath.Join(dir, "sub", "loop")); err != nil {
+				t.Skipf("symbolic links could not be created: %s", err)
+			}
+			if err := os.Symlink("sub", filepath.Join(dir, "link")); err != nil {
+				t.Fatal(err)
+			}
+
+			job := &upload.UploadFolderJob{SourceFolder: dir, FollowSymlinks: tc.followSymlinks}
+			w := New(dir)
+			w.Interval = 10 * time.Millisecond
+			w.Poll = tc.poll
+			w.Walk = job.Walk
+			w.Stat = job.Stat
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			reported := make(chan string, 10)
+			done := make(chan error)
+			go func() {
+				done <- w.Run(ctx, func(path string) {
+					reported <- path
+				})
+			}()
+
+			// gives time to the watcher to take the initial snapshot.
+			time.Sleep(50 * time.Millisecond)
+
+			writeFile(t, filepath.Join(dir, "sub", "new.jpg"), "new")
+			got := []string{waitForFile(t, reported)}
+			time.Sleep(100 * time.Millisecond)
+			cancel()
+			if err := <-done; err != nil {
+				t.Errorf("error was not expected: %s", err)
+			}
+			close(reported)
+			for path := range reported {
+				got = append(got, path)
+			}
+			for i := range got {
+				got[i] = filepath.ToSlash(upload.RelativePath(dir, got[i]))
+			}
+			sort.Strings(got)
+			if fmt.Sprint(tc.want) != fmt.Sprint(got) {
+				t.Errorf("want: %v, got: %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestWatcher_changes(t *testing.T) {
+	now := time.Now()
+	w := &Watcher{
+		known:   map[string]fileState{"existing.jpg": {size: 10, modTime: now}},
+		pending: map[string]fileState{},
+	}
+
+	polls := []struct {
+		name  string
+		files map[string]fileState
+		want  []string
+	}{
+		{"new file is pending", map[string]fileState{"existing.jpg": {10, now}, "copying.jpg": {100, now}}, nil},
+		{"growing file is still pending", map[string]fileState{"existing.jpg": {10, now}, "copying.jpg": {200, now.Add(time.Second)}}, nil},
+		{"stable file is reported", map[string]fileState{"existing.jpg": {10, now}, "copying.jpg": {200, now.Add(time.Second)}}, []string{"copying.jpg"}},
+		{"reported file is not reported again", map[string]fileState{"existing.jpg": {10, now}, "copying.jpg": {200, now.Add(time.Second)}}, nil},
+		{"modified file is pending", map[string]fileState{"existing.jpg": {20, now.Add(time.Second)}, "copying.jpg": {200, now.Add(time.Second)}}, nil},
+		{"modified file is reported once stable", map[string]fileState{"existing.jpg": {20, now.Add(time.Second)}, "copying.jpg": {200, now.Add(time.Second)}}, []string{"existing.jpg"}},
+	}
+
+	for _, poll := range polls {
+		got := w.changes(poll.files)
+		if len(got) != len(poll.want) || (len(got) > 0 && got[0] != poll.want[0]) {
+			t.Errorf("want: %v, got: %v, poll: %s", poll.want, got, poll.name)
+		}
+	}
+}
+
+func TestWatcher_changesMinAge(t *testing.T) {
+	now := time.Now()
+	old := now.Add(-time.Hour)
+	w := &Watcher{
+		MinAge:  10 * time.Minute,
+		known:   map[string]fileState{},
+		pending: map[string]fileState{},
+	}
+
+	polls := []struct {
+		name  string
+		files map[string]fileState
+		want  []string
+	}{
+		{"new files are pending", map[string]fileState{"fresh.jpg": {10, now}, "aged.jpg": {10, old}}, nil},
+		{"aged file is reported", map[string]fileState{"fresh.jpg": {10, now}, "aged.jpg": {10, old}}, []string{"aged.jpg"}},
+		{"fresh file is still pending", map[string]fileState{"fresh.jpg": {10, now}, "aged.jpg": {10, old}}, nil},
+	}
+
+	for _, poll := range polls {
+		got := w.changes(poll.files)
+		if len(got) != len(poll.want) || (len(got) > 0 && got[0] != poll.want[0]) {
+			t.Errorf("want: %v, got: %v, poll: %s", poll.want, got, poll.name)
+		}
+	}
+	if _, ok := w.pending["fresh.jpg"]; !ok {
+		t.Errorf("want: fresh.jpg pending, got: %v", w.pending)
+	}
+}
+
+func TestWatcher_checkSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	stable := filepath.Join(dir, "stable.jpg")
+	writeFile(t, stable, "stable")
+	growing := filepath.Join(dir, "growing.jpg")
+	writeFile(t, growing, "growing")
+
+	w := &Watcher{
+		SizeCheckDelay: 10 * time.Millisecond,
+		known: map[string]fileState{
+			stable:  {size: int64(len("stable"))},
+			growing: {size: 1},
+		},
+		pending: map[string]fileState{},
+	}
+
+	got := w.checkSize(context.Background(), []string{stable, growing})
+	if len(got) != 1 || got[0] != stable {
+		t.Errorf("want: %v, got: %v", []string{stable}, got)
+	}
+	if _, ok := w.pending[growing]; !ok {
+		t.Errorf("want: %s pending, got: %v", growing, w.pending)
+	}
+	if _, ok := w.known[growing]; ok {
+		t.Errorf("file was not expected to be known: %s", growing)
+	}
+}
+
+func writeFile(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func waitForFile(t *testing.T, reported chan string) string {
+	t.Helper()
+	select {
+	case path := <-reported:
+		return path
+	case <-time.After(2 * time.Second):
+		t.Fatalf("file was not reported")
+		return ""
+	}
+}
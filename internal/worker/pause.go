@@ -0,0 +1,54 @@
+package worker
+
+import "sync"
+
+// Pause pauses the dispatch of the jobs of the queues using it, see JobQueue.SetPause, so several queues could
+// be paused at once. Jobs being processed are not interrupted, and jobs keep being submitted while it's paused.
+// It's safe for concurrent use.
+type Pause struct {
+	mu sync.Mutex
+	// resumed is closed once it's resumed, it's nil if it's not paused.
+	resumed chan struct{}
+}
+
+// NewPause returns a Pause not paused.
+func NewPause() *Pause {
+	return &Pause{}
+}
+
+// Pause stops dispatching new jobs, it returns false if it was paused already.
+func (p *Pause) Pause() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.resumed != nil {
+		return false
+	}
+	p.resumed = make(chan struct{})
+	return true
+}
+
+// Resume dispatches the jobs again, it returns false if it was not paused.
+func (p *Pause) Resume() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.resumed == nil {
+		return false
+	}
+	close(p.resumed)
+	p.resumed = nil
+	return true
+}
+
+// Paused returns true if the dispatch of the jobs is paused.
+func (p *Pause) Paused() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.resumed != nil
+}
+
+// waitResumed returns a channel closed once it's resumed, or nil if it's not paused.
+func (p *Pause) waitResumed() <-chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.resumed
+}
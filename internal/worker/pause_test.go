@@ -0,0 +1,101 @@
+package worker
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+)
+
+// blockingJob is a job that is processed once release is closed, counting the jobs started.
+type blockingJob struct {
+	id      int
+	started *int32
+	release chan struct{}
+}
+
+func (j *blockingJob) ID() string { return fmt.Sprintf("Job #%d", j.id) }
+
+func (j *blockingJob) Process() error {
+	atomic.AddInt32(j.started, 1)
+	<-j.release
+	return nil
+}
+
+func TestQueue_Pause(t *testing.T) {
+	var started int32
+	release := make(chan struct{})
+	pause := NewPause()
+	queue := NewJobQueue(2, &log.DiscardLogger{})
+	queue.SetPause(pause)
+	queue.Start()
+	defer queue.Stop()
+
+	// the job in progress finishes once it's paused.
+	queue.Submit(&blockingJob{id: 1, started: &started, release: release})
+	waitFor(t, func() bool { return atomic.LoadInt32(&started) == 1 })
+	if !pause.Pause() || pause.Pause() || !pause.Paused() {
+		t.Fatalf("want: paused once, got: %v", pause.Paused())
+	}
+	close(release)
+	<-queue.ChanJobResults()
+
+	// jobs keep being submitted, but they are not started while it's paused.
+	for i := 2; i <= 4; i++ {
+		queue.Submit(&blockingJob{id: i, started: &started, release: release})
+	}
+	time.Sleep(100 * time.Millisecond)
+	if got := atomic.LoadInt32(&started); got != 1 {
+		t.Fatalf("want: no job started while paused, got: %d", got-1)
+	}
+
+	if !pause.Resume() || pause.Resume() || pause.Paused() {
+		t.Fatalf("want: resumed once, got: %v", pause.Paused())
+	}
+	for i := 2; i <= 4; i++ {
+		<-queue.ChanJobResults()
+	}
+	if got := atomic.LoadInt32(&started); got != 4 {
+		t.Errorf("want: %d jobs started, got: %d", 4, got)
+	}
+}
+
+func TestQueue_StopWhilePaused(t *testing.T) {
+	var started int32
+	pause := NewPause()
+	pause.Pause()
+	queue := NewJobQueue(1, &log.DiscardLogger{})
+	queue.SetPause(pause)
+	queue.Start()
+
+	queue.Submit(&blockingJob{id: 1, started: &started, release: make(chan struct{})})
+	time.Sleep(50 * time.Millisecond)
+
+	stopped := make(chan struct{})
+	go func() {
+		queue.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("want: the queue stopped while paused, got: it's still running")
+	}
+	if got := atomic.LoadInt32(&started); got != 0 {
+		t.Errorf("want: no job started, got: %d", got)
+	}
+}
+
+// waitFor waits up to a second for cond to be true.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
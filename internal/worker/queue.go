@@ -42,16 +42,22 @@ type JobQueue struct {
 	workersStopped    *sync.WaitGroup
 	jobResults        chan JobResult
 	quit              chan bool
+	// pause, if it's set, pauses the dispatch of the jobs.
+	pause *Pause
 }
 
 // NewJobQueue - creates a new job queue
 func NewJobQueue(maxWorkers int, logger log.Logger) *JobQueue {
+	// we need to ensure that the results channel is big enough to fulfill workers needs
+	return NewJobQueueWithResults(maxWorkers, make(chan JobResult, maxWorkers*10), logger)
+}
+
+// NewJobQueueWithResults - creates a new job queue sending the results to jobResults,
+// so several queues could share the same results channel
+func NewJobQueueWithResults(maxWorkers int, jobResults chan JobResult, logger log.Logger) *JobQueue {
 	workersStopped := sync.WaitGroup{}
 	readyPool := make(chan chan Job, maxWorkers)
 
-	// we need to ensure that the results channel is big enough to fulfill workers needs
-	jobResults := make(chan JobResult, maxWorkers*10)
-
 	// create the pool of workers
 	workers := make([]*Worker, maxWorkers)
 	for i := 0; i < maxWorkers; i++ {
@@ -72,6 +78,17 @@ func (q *JobQueue) ChanJobResults() chan JobResult {
 	return q.jobResults
 }
 
+// Workers - returns the number of workers processing the jobs concurrently
+func (q *JobQueue) Workers() int {
+	return len(q.workers)
+}
+
+// SetPause - pauses the dispatch of the jobs while pause is paused, it must be called before Start.
+// Jobs dispatched already are processed, and the next ones wait for it to be resumed.
+func (q *JobQueue) SetPause(pause *Pause) {
+	q.pause = pause
+}
+
 // Start - starts the worker routines and dispatcher routine
 func (q *JobQueue) Start() {
 	for i := 0; i < len(q.workers); i++ {
@@ -92,18 +109,45 @@ func (q *JobQueue) dispatch() {
 		select {
 		case job := <-q.internalQueue: // We got something in on our queue
 			workerChannel := <-q.readyPool // Check out an available worker
-			workerChannel <- job           // Send the request to the channel
-		case <-q.quit:
-			for i := 0; i < len(q.workers); i++ {
-				q.workers[i].Stop()
+			if !q.waitResumed() {
+				// the job is not processed, the queue is stopped while paused.
+				q.stopWorkers()
+				return
 			}
-			q.workersStopped.Wait()
-			q.dispatcherStopped.Done()
+			workerChannel <- job // Send the request to the channel
+		case <-q.quit:
+			q.stopWorkers()
 			return
 		}
 	}
 }
 
+// waitResumed waits for the queue to be resumed, if it's paused. It returns false if it's stopped meanwhile.
+func (q *JobQueue) waitResumed() bool {
+	if q.pause == nil {
+		return true
+	}
+	for {
+		resumed := q.pause.waitResumed()
+		if resumed == nil {
+			return true
+		}
+		select {
+		case <-resumed:
+		case <-q.quit:
+			return false
+		}
+	}
+}
+
+func (q *JobQueue) stopWorkers() {
+	for i := 0; i < len(q.workers); i++ {
+		q.workers[i].Stop()
+	}
+	q.workersStopped.Wait()
+	q.dispatcherStopped.Done()
+}
+
 // Submit - adds a new job to be processed, uses a subroutine to avoid deadlock when the queue is full
 func (q *JobQueue) Submit(job Job) {
 	go func(job Job) { q.internalQueue <- job }(job)
@@ -68,3 +68,32 @@ func TestQueue(t *testing.T) {
 	}
 
 }
+
+func TestQueue_SharedResults(t *testing.T) {
+	var logger = &log.DiscardLogger{}
+	var ops uint64
+
+	results := make(chan JobResult, 10)
+	queues := []*JobQueue{NewJobQueueWithResults(1, results, logger), NewJobQueueWithResults(3, results, logger)}
+	for _, q := range queues {
+		q.Start()
+		defer q.Stop()
+	}
+	if queues[0].Workers() != 1 || queues[1].Workers() != 3 {
+		t.Errorf("invalid workers: want=1 3, got=%d %d", queues[0].Workers(), queues[1].Workers())
+	}
+
+	// send jobs to both queues
+	for i := 0; i < 10; i++ {
+		queues[i%2].Submit(&TestJob{id: i + 1, opsPtr: &ops})
+	}
+
+	// get results from the shared channel
+	for i := 0; i < 10; i++ {
+		<-results
+	}
+
+	if want := uint64(10000); ops != want {
+		t.Errorf("invalid jobResults: want=%d, got=%d", want, ops)
+	}
+}
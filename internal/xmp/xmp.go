@@ -0,0 +1,145 @@
+// Package xmp reads the description of photos from their XMP sidecar files, like the ones written by Lightroom
+// or darktable.
+package xmp
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// nsDC is the namespace of the Dublin Core properties, like dc:description and dc:title.
+	nsDC = "http://purl.org/dc/elements/1.1/"
+	// nsRDF is the namespace of the RDF elements, like rdf:li.
+	nsRDF = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	// nsXML is the namespace of the xml:lang attribute.
+	nsXML = "http://www.w3.org/XML/1998/namespace"
+
+	// defaultLang is the language of the default value of language alternatives.
+	defaultLang = "x-default"
+)
+
+// sidecarExts are the extensions of the sidecar files, in the order they are looked for.
+var sidecarExts = []string{".xmp", ".XMP"}
+
+// Metadata are the Dublin Core properties of a photo. Missing properties are empty.
+type Metadata struct {
+	Title       string
+	Description string
+}
+
+// Reader reads the description of photos from their sidecar file.
+type Reader struct{}
+
+// Description returns the description of the photo read from its sidecar file, or its title if it has no
+// description. It returns an empty string if the photo has no sidecar file.
+func (Reader) Description(path string) (string, error) {
+	sidecar, ok := Sidecar(path)
+	if !ok {
+		return "", nil
+	}
+	f, err := os.Open(sidecar)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	m, err := Read(f)
+	if err != nil {
+		return "", err
+	}
+	if m.Description != "" {
+		return m.Description, nil
+	}
+	return m.Title, nil
+}
+
+// Sidecar returns the path of the sidecar file of the photo, named either like the photo without its
+// extension, e.g. IMG_0001.xmp, or like the photo, e.g. IMG_0001.jpg.xmp. It returns false if there is none.
+func Sidecar(path string) (string, bool) {
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+	for _, name := range []string{base, path} {
+		for _, ext := range sidecarExts {
+			fi, err := os.Stat(name + ext)
+			if err == nil && fi.Mode().IsRegular() {
+				return name + ext, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Read returns the title and the description of the XMP packet. Language alternatives use the default
+// language value, or the first value if there is none. Both properties could be set as attributes too.
+func Read(r io.Reader) (Metadata, error) {
+	var m Metadata
+	d := xml.NewDecoder(r)
+
+	// property is the Dublin Core property being read, if any, and found, if its default value has been read.
+	var property *string
+	var found bool
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			return m, nil
+		}
+		if err != nil {
+			return Metadata{}, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			for _, attr := range t.Attr {
+				if attr.Name.Space == nsDC && m.field(attr.Name.Local) != nil && *m.field(attr.Name.Local) == "" {
+					*m.field(attr.Name.Local) = strings.TrimSpace(attr.Value)
+				}
+			}
+			if t.Name.Space == nsDC && m.field(t.Name.Local) != nil {
+				property, found = m.field(t.Name.Local), false
+				continue
+			}
+			if property == nil || t.Name.Space != nsRDF || t.Name.Local != "li" {
+				continue
+			}
+			var value string
+			if err := d.DecodeElement(&value, &t); err != nil {
+				return Metadata{}, err
+			}
+			value = strings.TrimSpace(value)
+			isDefault := lang(t) == defaultLang
+			if !found && (*property == "" || isDefault) {
+				*property = value
+				found = isDefault
+			}
+		case xml.EndElement:
+			if t.Name.Space == nsDC && m.field(t.Name.Local) != nil {
+				property = nil
+			}
+		}
+	}
+}
+
+// field returns the field of the Dublin Core property, or nil if it's not read.
+func (m *Metadata) field(name string) *string {
+	switch name {
+	case "title":
+		return &m.Title
+	case "description":
+		return &m.Description
+	}
+	return nil
+}
+
+// lang returns the xml:lang attribute of the element.
+func lang(e xml.StartElement) string {
+	for _, attr := range e.Attr {
+		if attr.Name.Space == nsXML && attr.Name.Local == "lang" {
+			return attr.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,105 @@
+package xmp_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/xmp"
+)
+
+const packet = `<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about="" xmlns:dc="http://purl.org/dc/elements/1.1/">
+   <dc:title>
+    <rdf:Alt>
+     <rdf:li xml:lang="x-default">At the beach</rdf:li>
+    </rdf:Alt>
+   </dc:title>
+   <dc:description>
+    <rdf:Alt>
+     <rdf:li xml:lang="es-ES">En la playa con la familia</rdf:li>
+     <rdf:li xml:lang="x-default">At the beach with the family</rdf:li>
+    </rdf:Alt>
+   </dc:description>
+   <dc:subject>
+    <rdf:Bag>
+     <rdf:li>beach</rdf:li>
+    </rdf:Bag>
+   </dc:subject>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>`
+
+func TestRead(t *testing.T) {
+	testCases := []struct {
+		name   string
+		input  string
+		want   xmp.Metadata
+		errExp bool
+	}{
+		{"Should read title and default description", packet, xmp.Metadata{Title: "At the beach", Description: "At the beach with the family"}, false},
+		{"Should read the first value without default language", `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns:dc="http://purl.org/dc/elements/1.1/"><rdf:Description><dc:description><rdf:Alt><rdf:li xml:lang="fr">À la plage</rdf:li><rdf:li xml:lang="de">Am Strand</rdf:li></rdf:Alt></dc:description></rdf:Description></rdf:RDF>`, xmp.Metadata{Description: "À la plage"}, false},
+		{"Should read attributes", `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"><rdf:Description xmlns:dc="http://purl.org/dc/elements/1.1/" dc:title="At the beach"/></rdf:RDF>`, xmp.Metadata{Title: "At the beach"}, false},
+		{"Should return empty metadata without Dublin Core properties", `<x:xmpmeta xmlns:x="adobe:ns:meta/"/>`, xmp.Metadata{}, false},
+		{"Should fail if XML is invalid", `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF>`, xmp.Metadata{}, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := xmp.Read(strings.NewReader(tc.input))
+			if tc.errExp && err == nil {
+				t.Fatalf("error was expected, but not produced")
+			}
+			if !tc.errExp && err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			if tc.want != got {
+				t.Errorf("want: %v, got: %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestReader_Description(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xmp")
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"IMG_0001.jpg":     "",
+		"IMG_0001.xmp":     packet,
+		"IMG_0002.jpg":     "",
+		"IMG_0002.jpg.xmp": `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"><rdf:Description xmlns:dc="http://purl.org/dc/elements/1.1/" dc:title="Only a title"/></rdf:RDF>`,
+		"IMG_0003.jpg":     "",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+	}
+
+	testCases := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"Should read description of sidecar without the photo extension", "IMG_0001.jpg", "At the beach with the family"},
+		{"Should read title of sidecar with the photo extension", "IMG_0002.jpg", "Only a title"},
+		{"Should return empty description without sidecar", "IMG_0003.jpg", ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := xmp.Reader{}.Description(filepath.Join(dir, tc.path))
+			if err != nil {
+				t.Fatalf("error was not expected at this point: %s", err)
+			}
+			if tc.want != got {
+				t.Errorf("want: %v, got: %v", tc.want, got)
+			}
+		})
+	}
+}
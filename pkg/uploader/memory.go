@@ -0,0 +1,65 @@
+package uploader
+
+import (
+	"sync"
+)
+
+// memoryTracker is a FileTracker keeping the uploaded files in memory, so they are only tracked in the same run.
+type memoryTracker struct {
+	mu    sync.Mutex
+	files map[string]string
+}
+
+func newMemoryTracker() *memoryTracker {
+	return &memoryTracker{files: make(map[string]string)}
+}
+
+func (t *memoryTracker) Put(file string, mediaItemID string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.files[file] = mediaItemID
+	return nil
+}
+
+func (t *memoryTracker) Exist(file string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, ok := t.files[file]
+	return ok
+}
+
+func (t *memoryTracker) Delete(file string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.files, file)
+	return nil
+}
+
+// memorySessions is a upload.SessionStore keeping the upload sessions in memory, so interrupted uploads
+// are only resumed in the same run.
+type memorySessions struct {
+	mu       sync.Mutex
+	sessions map[string][]byte
+}
+
+func newMemorySessions() *memorySessions {
+	return &memorySessions{sessions: make(map[string][]byte)}
+}
+
+func (s *memorySessions) Get(key string) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessions[key]
+}
+
+func (s *memorySessions) Set(key string, value []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[key] = value
+}
+
+func (s *memorySessions) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, key)
+}
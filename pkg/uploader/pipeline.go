@@ -0,0 +1,100 @@
+package uploader
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/task"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/upload"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/xmp"
+)
+
+// AlbumResolver represents the service returning the ID of an album by its title, creating it if it doesn't exist.
+type AlbumResolver interface {
+	GetOrCreate(ctx context.Context, title string) (string, error)
+}
+
+// Pipeline prepares the upload of every file, the same way for the Uploader and the push command: the files with
+// the same content than other file of the run are skipped, the album of the file is resolved, and the upload is
+// built to track the file once it's uploaded. It's safe for concurrent use.
+type Pipeline struct {
+	// Uploads uploads the content of the files, creating their media items.
+	Uploads task.UploadsService
+	// Albums, if it's set, resolves the albums of the files, including the ones without album name, e.g. to add
+	// all of them to the same album. Files are not added to any album otherwise.
+	Albums AlbumResolver
+	// FileTracker tracks the uploaded files.
+	FileTracker FileTracker
+	Logger      log.Logger
+
+	// Dedup, if it's set, skips the files with the same content than other file prepared before in the run.
+	Dedup *upload.RunDedup
+
+	// Build, if it's set, completes the upload of every file, e.g. with the actions done after uploading it.
+	// It's called before resolving the album of the file.
+	Build func(job *task.EnqueuedUpload, item upload.FileItem)
+}
+
+// DuplicateError is returned by Prepare when the file has the same content than other file of the run.
+type DuplicateError struct {
+	Path string
+	// Of is the path of the file with the same content, prepared first.
+	Of string
+}
+
+func (e *DuplicateError) Error() string {
+	return fmt.Sprintf("file '%s' has the same content than '%s'", e.Path, e.Of)
+}
+
+// AlbumError is returned by Prepare when the album of the file could not be resolved.
+type AlbumError struct {
+	Album string
+	Err   error
+}
+
+func (e *AlbumError) Error() string {
+	return fmt.Sprintf("unable to create the album '%s': %s", e.Album, e.Err)
+}
+
+func (e *AlbumError) Unwrap() error {
+	return e.Err
+}
+
+// Prepare returns the upload of the file, to be processed. It returns a DuplicateError if the file has the same
+// content than other file of the run, or an AlbumError if its album could not be resolved.
+// Files that could not be hashed are not considered duplicated.
+func (p *Pipeline) Prepare(ctx context.Context, item upload.FileItem) (*task.EnqueuedUpload, error) {
+	if p.Dedup != nil {
+		first, ok, err := p.Dedup.Claim(item.Path)
+		if err != nil {
+			p.Logger.Warnf("Unable to check if '%s' is a duplicate of other file: %s", item.Path, err)
+		} else if !ok {
+			return nil, &DuplicateError{Path: item.Path, Of: first}
+		}
+	}
+
+	job := &task.EnqueuedUpload{
+		Context:      ctx,
+		Uploads:      p.Uploads,
+		FileTracker:  p.FileTracker,
+		Logger:       p.Logger,
+		Path:         item.Path,
+		AlbumName:    item.AlbumName,
+		MediaItemID:  item.MediaItemID,
+		Favorite:     item.Favorite,
+		Descriptions: xmp.Reader{},
+	}
+	if p.Build != nil {
+		p.Build(job, item)
+	}
+
+	if p.Albums != nil {
+		id, err := p.Albums.GetOrCreate(ctx, item.AlbumName)
+		if err != nil {
+			return nil, &AlbumError{Album: item.AlbumName, Err: err}
+		}
+		job.AlbumID = id
+	}
+	return job, nil
+}
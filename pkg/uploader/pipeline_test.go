@@ -0,0 +1,84 @@
+package uploader_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/mock"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/task"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/upload"
+	"github.com/gphotosuploader/gphotos-uploader-cli/pkg/uploader"
+)
+
+// albumResolver returns the ID of the albums by title, failing for the missing ones.
+type albumResolver map[string]string
+
+func (r albumResolver) GetOrCreate(ctx context.Context, title string) (string, error) {
+	id, ok := r[title]
+	if !ok {
+		return "", errors.New("album could not be created")
+	}
+	return id, nil
+}
+
+func TestPipeline_Prepare(t *testing.T) {
+	dir, err := ioutil.TempDir("", "uploader")
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	writePhotos(t, dir, "IMG_0001.jpg", "copy/IMG_0001.jpg")
+	first, copied := filepath.Join(dir, "IMG_0001.jpg"), filepath.Join(dir, "copy", "IMG_0001.jpg")
+
+	var built []string
+	p := &uploader.Pipeline{
+		Uploads:     &mock.UploadsService{},
+		Albums:      albumResolver{"": "", "Trips": "trips-id"},
+		FileTracker: &mock.FileTracker{},
+		Logger:      log.Discard,
+		Dedup:       upload.NewRunDedup(),
+		Build: func(job *task.EnqueuedUpload, item upload.FileItem) {
+			built = append(built, item.Path)
+		},
+	}
+
+	job, err := p.Prepare(context.Background(), upload.FileItem{Path: first, AlbumName: "Trips"})
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if job.Path != first || job.AlbumName != "Trips" || job.AlbumID != "trips-id" {
+		t.Errorf("want: %s added to %s, got: %s added to %s (%s)", first, "trips-id", job.Path, job.AlbumID, job.AlbumName)
+	}
+	if len(built) != 1 || built[0] != first {
+		t.Errorf("want: %v, got: %v", []string{first}, built)
+	}
+
+	// files with the same content than a prepared one are skipped.
+	_, err = p.Prepare(context.Background(), upload.FileItem{Path: copied})
+	var duplicateErr *uploader.DuplicateError
+	if !errors.As(err, &duplicateErr) || duplicateErr.Of != first {
+		t.Errorf("want: duplicate of %s, got: %v", first, err)
+	}
+
+	p.Dedup = nil
+	_, err = p.Prepare(context.Background(), upload.FileItem{Path: copied, AlbumName: "Missing"})
+	var albumErr *uploader.AlbumError
+	if !errors.As(err, &albumErr) || albumErr.Album != "Missing" {
+		t.Errorf("want: album error of %s, got: %v", "Missing", err)
+	}
+
+	// files are not added to any album without albums.
+	p.Albums = nil
+	job, err = p.Prepare(context.Background(), upload.FileItem{Path: copied, AlbumName: "Missing"})
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if job.AlbumID != "" {
+		t.Errorf("want: no album, got: %s", job.AlbumID)
+	}
+}
@@ -0,0 +1,231 @@
+// Package uploader uploads files to Google Photos from other Go programs, the same way the push command does.
+//
+// The Uploader is built from an authenticated HTTP client, e.g. the one returned by an oauth2.Config:
+//
+//	u, err := uploader.New(httpClient, uploader.Config{})
+//	if err != nil {
+//		return err
+//	}
+//	results, err := u.UploadDir(ctx, uploader.DirOptions{Path: "/home/me/Pictures", CreateAlbums: "folderName"})
+//
+// Uploaded files are tracked by the Config.Tracker, so they are not uploaded again. They are only tracked in
+// memory by default.
+package uploader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/config"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/filter"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/log"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/photosapi"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/transport"
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/upload"
+)
+
+// DefaultWorkers is the number of files uploaded concurrently by UploadDir.
+const DefaultWorkers = 5
+
+// allFiles is the include pattern of every supported file.
+const allFiles = "_ALL_FILES_"
+
+// FileTracker represents a service to track already uploaded files, by their path.
+type FileTracker interface {
+	Put(file string, mediaItemID string) error
+	Exist(file string) bool
+	Delete(file string) error
+}
+
+// Config are the settings of the Uploader.
+type Config struct {
+	// BaseURL is the base URL of the Google Photos Library API. Uses the Google one by default.
+	BaseURL string
+
+	// Workers is the number of files uploaded concurrently by UploadDir. Uses DefaultWorkers by default.
+	Workers int
+
+	// ChunkSize is the size, in bytes, of the chunks the files are uploaded in. Uses 8MB by default.
+	ChunkSize int64
+
+	// RetryDelay is the delay before looking for a media item whose creation has failed, and creating it
+	// again if it's not found. It's doubled on every retry. Uses 1s by default.
+	RetryDelay time.Duration
+
+	// Tracker keeps the uploaded files, so they are not uploaded again. They are kept in memory by default.
+	Tracker FileTracker
+}
+
+// DirOptions are the settings of the upload of a folder.
+type DirOptions struct {
+	// Path is the folder to upload, including its subfolders.
+	Path string
+
+	// CreateAlbums is the album where files are added: "folderPath", "folderName", "exifDate" or "mediaType".
+	// Files are not added to any album if it's empty or "Off".
+	CreateAlbums string
+
+	// IncludePatterns are the patterns of the files to upload, like the ones of the configuration file.
+	// Every supported file is uploaded by default.
+	IncludePatterns []string
+
+	// ExcludePatterns are the patterns of the files to skip.
+	ExcludePatterns []string
+}
+
+// Result is the outcome of the upload of a file.
+type Result struct {
+	// Path is the path of the file.
+	Path string
+
+	// AlbumName is the album where the file has been added, if any.
+	AlbumName string
+
+	// MediaItemID is the media item created for the file. It's empty if it has failed.
+	MediaItemID string
+
+	// Err is the reason why the upload has failed, or nil if it has been uploaded.
+	Err error
+}
+
+// Uploader uploads files to Google Photos. It's safe for concurrent use.
+type Uploader struct {
+	tracker  FileTracker
+	workers  int
+	pipeline Pipeline
+	logger   log.Logger
+}
+
+// New returns an Uploader sending the requests with client, that must be authenticated already.
+func New(client *http.Client, cfg Config) (*Uploader, error) {
+	if client == nil {
+		return nil, errors.New("client could not be nil")
+	}
+	logger := log.Discard
+	photos, err := photosapi.NewClient(client, photosapi.Options{
+		BaseURL:   cfg.BaseURL,
+		Sessions:  newMemorySessions(),
+		ChunkSize: cfg.ChunkSize,
+		Logger:    logger,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create the Google Photos client: %w", err)
+	}
+	albums, _ := photosapi.NewAlbumCache(client, photos, cfg.BaseURL, logger)
+
+	// media items that could have been created by a failed attempt are searched before creating them again,
+	// like push does.
+	finder := photosapi.NewMatcher(client, cfg.BaseURL)
+	retryDelay := cfg.RetryDelay
+	if retryDelay <= 0 {
+		retryDelay = transport.DefaultRetryBaseDelay
+	}
+	u := &Uploader{
+		tracker: cfg.Tracker,
+		workers: cfg.Workers,
+		logger:  logger,
+	}
+	if u.tracker == nil {
+		u.tracker = newMemoryTracker()
+	}
+	u.pipeline = Pipeline{
+		Uploads:     photosapi.NewUploads(photos, finder, transport.DefaultMaxRetries, retryDelay),
+		Albums:      albums,
+		FileTracker: u.tracker,
+		Logger:      logger,
+	}
+	if u.workers < 1 {
+		u.workers = DefaultWorkers
+	}
+	return u, nil
+}
+
+// UploadFile uploads the file, without adding it to any album, and tracks it. It returns the upload error
+// in the Result too.
+func (u *Uploader) UploadFile(ctx context.Context, path string) (Result, error) {
+	r := u.upload(ctx, upload.FileItem{Path: path})
+	return r, r.Err
+}
+
+// UploadDir uploads the files of the folder that are not tracked already, adding them to their albums.
+// It returns the Result of every file found, even if it has failed. The error is only returned if the
+// folder could not be scanned, or the context is done.
+func (u *Uploader) UploadDir(ctx context.Context, opts DirOptions) ([]Result, error) {
+	// files are not added to any album by default.
+	if opts.CreateAlbums != "" && !config.IsValidCreateAlbums(opts.CreateAlbums) {
+		return nil, fmt.Errorf("invalid CreateAlbums '%s'", opts.CreateAlbums)
+	}
+	include := opts.IncludePatterns
+	if len(include) == 0 {
+		include = []string{allFiles}
+	}
+	fileFilter, err := filter.Compile(include, opts.ExcludePatterns)
+	if err != nil {
+		return nil, fmt.Errorf("invalid patterns: %w", err)
+	}
+	job := &upload.UploadFolderJob{
+		FileTracker:  u.tracker,
+		SourceFolder: opts.Path,
+		CreateAlbums: opts.CreateAlbums,
+		Filter:       fileFilter,
+		Limits:       upload.DefaultLimits(),
+	}
+
+	var results []Result
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, u.workers)
+	_, err = job.WalkFolderContext(ctx, u.logger, func(item upload.FileItem) {
+		mu.Lock()
+		i := len(results)
+		results = append(results, Result{Path: item.Path, AlbumName: item.AlbumName})
+		mu.Unlock()
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			r := u.upload(ctx, item)
+			mu.Lock()
+			results[i] = r
+			mu.Unlock()
+		}()
+	})
+	wg.Wait()
+	if err != nil {
+		return results, err
+	}
+	return results, ctx.Err()
+}
+
+// upload uploads the file to its album, creating it if it doesn't exist.
+func (u *Uploader) upload(ctx context.Context, item upload.FileItem) Result {
+	r := Result{Path: item.Path, AlbumName: item.AlbumName}
+	tracker := &resultTracker{FileTracker: u.tracker}
+	pipeline := u.pipeline
+	pipeline.FileTracker = tracker
+	job, err := pipeline.Prepare(ctx, item)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	r.Err = job.Process()
+	r.MediaItemID = tracker.mediaItemID
+	return r
+}
+
+// resultTracker is a FileTracker keeping the media item of the tracked file, to return it in the Result.
+type resultTracker struct {
+	FileTracker
+	mediaItemID string
+}
+
+func (t *resultTracker) Put(file string, mediaItemID string) error {
+	t.mediaItemID = mediaItemID
+	return t.FileTracker.Put(file, mediaItemID)
+}
@@ -0,0 +1,158 @@
+package uploader_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/gphotosuploader/gphotos-uploader-cli/internal/photosapi/photosapitest"
+	"github.com/gphotosuploader/gphotos-uploader-cli/pkg/uploader"
+)
+
+// photo starts as a JPEG file, in order to be accepted by the content type checks.
+const photo = "\xff\xd8\xff\xe0\x00\x10JFIF\x00photo"
+
+func writePhotos(t *testing.T, dir string, names ...string) {
+	for _, name := range names {
+		fp := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(fp), 0700); err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+		if err := ioutil.WriteFile(fp, []byte(photo), 0600); err != nil {
+			t.Fatalf("error was not expected at this point: %s", err)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := uploader.New(nil, uploader.Config{}); err == nil {
+		t.Errorf("error was expected, but not produced")
+	}
+}
+
+func TestUploader_UploadFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "uploader")
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	writePhotos(t, dir, "IMG_0001.jpg")
+
+	api := photosapitest.NewServer()
+	defer api.Close()
+	u, err := uploader.New(api.Client(), uploader.Config{BaseURL: api.URL})
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	got, err := u.UploadFile(context.Background(), filepath.Join(dir, "IMG_0001.jpg"))
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if want := "media-item-1"; want != got.MediaItemID {
+		t.Errorf("want: %v, got: %v", want, got.MediaItemID)
+	}
+	if len(api.Unexpected) > 0 {
+		t.Errorf("want: no unexpected requests, got: %v", api.Unexpected)
+	}
+
+	if _, err := u.UploadFile(context.Background(), filepath.Join(dir, "missing.jpg")); err == nil {
+		t.Errorf("error was expected, but not produced")
+	}
+}
+
+func TestUploader_UploadFileLostCreateResponse(t *testing.T) {
+	dir, err := ioutil.TempDir("", "uploader")
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	writePhotos(t, dir, "IMG_0001.jpg")
+
+	api := photosapitest.NewServer()
+	defer api.Close()
+	api.DropCreates = 1
+	u, err := uploader.New(api.Client(), uploader.Config{BaseURL: api.URL, RetryDelay: time.Millisecond})
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	got, err := u.UploadFile(context.Background(), filepath.Join(dir, "IMG_0001.jpg"))
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	// the media item is found in the library, instead of being created again.
+	if want := "media-item-1"; want != got.MediaItemID {
+		t.Errorf("want: %v, got: %v", want, got.MediaItemID)
+	}
+	if want := 1; want != len(api.Created) {
+		t.Errorf("want: %v, got: %v", want, api.Created)
+	}
+	if len(api.Unexpected) > 0 {
+		t.Errorf("want: no unexpected requests, got: %v", api.Unexpected)
+	}
+}
+
+func TestUploader_UploadDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "uploader")
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	writePhotos(t, dir, "2021/IMG_0001.jpg", "2021/IMG_0002.jpg", "2022/IMG_0003.jpg", "2022/IMG_0004.png")
+
+	api := photosapitest.NewServer()
+	defer api.Close()
+	u, err := uploader.New(api.Client(), uploader.Config{BaseURL: api.URL, Workers: 2})
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+
+	opts := uploader.DirOptions{Path: dir, CreateAlbums: "folderName", ExcludePatterns: []string{"**/*.png"}}
+	results, err := u.UploadDir(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("want: %v, got: %v", 3, len(results))
+	}
+	for _, r := range results {
+		if r.Err != nil {
+			t.Errorf("error was not expected at this point: %s", r.Err)
+		}
+		if r.MediaItemID == "" {
+			t.Errorf("want: a media item for '%s', got: none", r.Path)
+		}
+		if want := filepath.Base(filepath.Dir(r.Path)); want != r.AlbumName {
+			t.Errorf("want: %v, got: %v", want, r.AlbumName)
+		}
+	}
+	sort.Strings(api.Albums)
+	if want := "[2021 2022]"; want != fmt.Sprint(api.Albums) {
+		t.Errorf("want: %v, got: %v", want, api.Albums)
+	}
+	if len(api.Unexpected) > 0 {
+		t.Errorf("want: no unexpected requests, got: %v", api.Unexpected)
+	}
+
+	// the second upload skips the files, since they have been tracked.
+	results, err = u.UploadDir(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("error was not expected at this point: %s", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("want: %v, got: %v", 0, results)
+	}
+	if want := 3; want != len(api.Uploaded) {
+		t.Errorf("want: %v, got: %v", want, len(api.Uploaded))
+	}
+
+	if _, err := u.UploadDir(context.Background(), uploader.DirOptions{Path: dir, CreateAlbums: "foo"}); err == nil {
+		t.Errorf("error was expected, but not produced")
+	}
+}